@@ -12,6 +12,7 @@ MODULES=(
   "httpx"
   "idgen"
   "sietch"
+  "sietch/sietchtest"
   "wp"
 )
 
@@ -0,0 +1,214 @@
+// Command service is a small HTTP API that shows how the gofw packages fit
+// together:
+//
+//   - an httpx client with retries, a circuit breaker and timeouts calls a
+//     downstream pricing service
+//   - accounts are read through a sietch CachedRepository (CockroachDB + Redis)
+//   - orders are written in a transaction that also debits the account
+//   - httpx and the service share one Prometheus registry (served on /metrics)
+//     and one OpenTelemetry tracer provider
+//   - SIGINT/SIGTERM drain in-flight requests before the stores are closed
+//
+// Configuration is read from the environment:
+//
+//	LISTEN_ADDR  address to serve on (default ":8080")
+//	PRICING_URL  base URL of the pricing service (default "http://localhost:8081")
+//	SIETCH_DSN   CockroachDB DSN; in-memory stores are used when empty
+//	REDIS_ADDR   Redis address for the account cache; in-memory when empty
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/seb7887/gofw/httpx"
+	"github.com/seb7887/gofw/httpx/backoff"
+	"github.com/seb7887/gofw/httpx/policy"
+	"github.com/seb7887/gofw/sietch"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func main() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if err := run(logger); err != nil {
+		logger.Error("service stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// One registry and one tracer provider for every component. Install an SDK
+	// provider with otel.SetTracerProvider to export spans; the default is a no-op.
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	provider := otel.GetTracerProvider()
+
+	stores, closeStores, err := newStores(ctx, os.Getenv("SIETCH_DSN"), os.Getenv("REDIS_ADDR"))
+	if err != nil {
+		return err
+	}
+	defer closeStores()
+
+	pricing := NewPricingClient(newPricingHTTPClient(getenv("PRICING_URL", "http://localhost:8081"), registry, provider, logger))
+
+	svc, err := NewService(stores, pricing, registry, provider, logger)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              getenv("LISTEN_ADDR", ":8080"),
+		Handler:           svc.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("listening", "addr", srv.Addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Stop accepting connections and let in-flight requests finish; the
+	// deferred closeStores runs only after Shutdown returns
+	logger.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newPricingHTTPClient builds the resilient client used for the downstream.
+// Tracing comes first so every retry attempt is part of the request span.
+func newPricingHTTPClient(baseURL string, registry prometheus.Registerer, provider trace.TracerProvider, logger *slog.Logger) *httpx.Client {
+	return httpx.NewClient(
+		httpx.WithBaseURL(baseURL),
+		httpx.WithLogger(logger),
+		httpx.WithOTEL(provider),
+		httpx.WithMetrics(registry),
+		httpx.WithRetry(policy.RetryConfig{
+			MaxAttempts: 3,
+			Backoff: &backoff.ExponentialBackoff{
+				Initial: 50 * time.Millisecond,
+				Max:     time.Second,
+				Factor:  2.0,
+				Jitter:  true,
+			},
+		}),
+		httpx.WithCircuitBreaker(policy.CircuitBreakerConfig{
+			ErrorThreshold:   50,
+			MinRequests:      10,
+			SleepWindow:      5 * time.Second,
+			SuccessThreshold: 2,
+		}),
+		httpx.WithTimeout(policy.TimeoutConfig{
+			Request: 2 * time.Second,
+		}),
+	)
+}
+
+// newStores connects to CockroachDB and Redis, or falls back to in-memory
+// stores when they are not configured. The returned func closes the connections.
+func newStores(ctx context.Context, dsn, redisAddr string) (Stores, func(), error) {
+	accountID := func(a *Account) string { return a.ID }
+	orderID := func(o *Order) string { return o.ID }
+
+	var closers []func()
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	var stores Stores
+	if redisAddr != "" {
+		client := redis.NewClient(&redis.Options{Addr: redisAddr})
+		closers = append(closers, func() { _ = client.Close() })
+		stores.AccountCache = sietch.NewRedisConnector[Account, string](client, 5*time.Minute, accountID,
+			func(id string) string { return "service:account:" + id })
+	} else {
+		stores.AccountCache = sietch.NewInMemoryConnector[Account, string](accountID)
+	}
+
+	if dsn == "" {
+		stores.Accounts = sietch.NewInMemoryConnector[Account, string](accountID)
+		stores.Orders = sietch.NewInMemoryConnector[Order, string](orderID)
+		stores.Tx = &localTx{}
+		return stores, closeAll, nil
+	}
+
+	pool, err := sietch.NewCockroachDBConnPool(ctx, dsn)
+	if err != nil {
+		closeAll()
+		return Stores{}, nil, err
+	}
+	closers = append(closers, pool.Close)
+
+	accounts, err := sietch.NewCockroachDBConnector[Account, string](pool, "service_accounts", accountID)
+	if err != nil {
+		closeAll()
+		return Stores{}, nil, err
+	}
+	orders, err := sietch.NewCockroachDBConnector[Order, string](pool, "service_orders", orderID)
+	if err != nil {
+		closeAll()
+		return Stores{}, nil, err
+	}
+
+	// Create the tables if needed; there are no fixtures, so the teardown is not kept
+	b := sietch.NewBootstrap()
+	sietch.AddFixtures[Account, string](b, accounts)
+	sietch.AddFixtures[Order, string](b, orders)
+	if _, err := b.Run(ctx); err != nil {
+		closeAll()
+		return Stores{}, nil, err
+	}
+
+	stores.Accounts = accounts
+	stores.Orders = orders
+	stores.Tx = sietch.NewTransactionManager(pool)
+	return stores, closeAll, nil
+}
+
+// localTx serializes "transactions" for the in-memory stores. It provides
+// isolation between requests but no rollback, which is enough for the demo.
+type localTx struct {
+	mu sync.Mutex
+}
+
+func (l *localTx) WithTx(ctx context.Context, fn sietch.MultiRepoTxFunc) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fn(ctx)
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/seb7887/gofw/httpx"
+)
+
+// ErrUnknownSKU is returned when the pricing service does not know a SKU
+var ErrUnknownSKU = errors.New("unknown sku")
+
+// PricingClient calls the downstream pricing service
+type PricingClient struct {
+	client *httpx.Client
+}
+
+// NewPricingClient wraps an httpx client whose base URL points at the pricing service
+func NewPricingClient(client *httpx.Client) *PricingClient {
+	return &PricingClient{client: client}
+}
+
+// UnitPrice returns the price of one unit of sku, in cents
+func (p *PricingClient) UnitPrice(ctx context.Context, sku string) (int64, error) {
+	resp, err := p.client.Get(ctx, "/prices/"+url.PathEscape(sku))
+	if err != nil {
+		return 0, fmt.Errorf("pricing: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return 0, ErrUnknownSKU
+	case resp.StatusCode != http.StatusOK:
+		return 0, fmt.Errorf("pricing: unexpected status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		UnitPrice int64 `json:"unit_price"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("pricing: decode response: %w", err)
+	}
+	return body.UnitPrice, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/seb7887/gofw/sietch"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Account is a customer balance, read through the cache
+type Account struct {
+	ID      string `db:"id"`
+	Owner   string `db:"owner"`
+	Balance int64  `db:"balance"`
+}
+
+// Order is a purchase debited from an account
+type Order struct {
+	ID        string    `db:"id"`
+	AccountID string    `db:"account_id"`
+	SKU       string    `db:"sku"`
+	Quantity  int64     `db:"quantity"`
+	Total     int64     `db:"total"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// ErrInsufficientFunds is returned when an order exceeds the account balance
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// TxRunner runs fn atomically across repositories.
+// *sietch.TransactionManager implements it for CockroachDB.
+type TxRunner interface {
+	WithTx(ctx context.Context, fn sietch.MultiRepoTxFunc) error
+}
+
+// Stores groups the repositories the service works with
+type Stores struct {
+	// Accounts is the primary account store; transactional writes go here
+	Accounts sietch.Repository[Account, string]
+	// AccountCache holds cached accounts (Redis in production)
+	AccountCache sietch.Repository[Account, string]
+	// Orders is the primary order store
+	Orders sietch.Repository[Order, string]
+	// Tx runs multi-repository transactions over Accounts and Orders
+	Tx TxRunner
+}
+
+// Service is the HTTP API of the example
+type Service struct {
+	accounts     *sietch.CachedRepository[Account, string]
+	accountStore sietch.Repository[Account, string]
+	accountCache sietch.Repository[Account, string]
+	orders       sietch.Repository[Order, string]
+	tx           TxRunner
+	pricing      *PricingClient
+	tracer       trace.Tracer
+	logger       *slog.Logger
+	requests     *prometheus.CounterVec
+	latency      *prometheus.HistogramVec
+	registry     *prometheus.Registry
+}
+
+// NewService wires the API. Metrics are registered in registry, which is also
+// served on /metrics, and every request is traced with a span from provider.
+func NewService(stores Stores, pricing *PricingClient, registry *prometheus.Registry, provider trace.TracerProvider, logger *slog.Logger) (*Service, error) {
+	s := &Service{
+		accounts:     sietch.NewCachedRepository(stores.Accounts, stores.AccountCache, 5*time.Minute),
+		accountStore: stores.Accounts,
+		accountCache: stores.AccountCache,
+		orders:       stores.Orders,
+		tx:           stores.Tx,
+		pricing:      pricing,
+		tracer:       provider.Tracer("github.com/seb7887/gofw/examples/service"),
+		logger:       logger,
+		registry:     registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "service_http_requests_total",
+			Help: "Requests served by the example service",
+		}, []string{"route", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "service_http_request_duration_seconds",
+			Help:    "Latency of requests served by the example service",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route"}),
+	}
+
+	for _, c := range []prometheus.Collector{s.requests, s.latency} {
+		if err := registry.Register(c); err != nil {
+			return nil, fmt.Errorf("register metrics: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Handler returns the routes of the service
+func (s *Service) Handler() http.Handler {
+	mux := http.NewServeMux()
+	s.route(mux, "POST /accounts", s.createAccount)
+	s.route(mux, "GET /accounts/{id}", s.getAccount)
+	s.route(mux, "POST /orders", s.createOrder)
+	s.route(mux, "GET /orders/{id}", s.getOrder)
+	mux.Handle("GET /metrics", promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+// route registers a handler wrapped in a span and request metrics
+func (s *Service) route(mux *http.ServeMux, pattern string, h func(http.ResponseWriter, *http.Request) (int, error)) {
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx, span := s.tracer.Start(r.Context(), pattern, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		code, err := h(w, r.WithContext(ctx))
+		if err != nil {
+			span.RecordError(err)
+			if code >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, err.Error())
+				s.logger.ErrorContext(ctx, "request failed", "route", pattern, "error", err)
+			}
+			writeJSON(w, code, map[string]string{"error": err.Error()})
+		}
+		span.SetAttributes(attribute.Int("http.status_code", code))
+
+		s.requests.WithLabelValues(pattern, strconv.Itoa(code)).Inc()
+		s.latency.WithLabelValues(pattern).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (s *Service) createAccount(w http.ResponseWriter, r *http.Request) (int, error) {
+	var req struct {
+		Owner   string `json:"owner"`
+		Balance int64  `json:"balance"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Owner == "" || req.Balance < 0 {
+		return http.StatusBadRequest, errors.New("owner and a non-negative balance are required")
+	}
+
+	account := &Account{ID: uuid.NewString(), Owner: req.Owner, Balance: req.Balance}
+	if err := s.accounts.Create(r.Context(), account); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	writeJSON(w, http.StatusCreated, account)
+	return http.StatusCreated, nil
+}
+
+func (s *Service) getAccount(w http.ResponseWriter, r *http.Request) (int, error) {
+	account, err := s.accounts.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, sietch.ErrItemNotFound) {
+		return http.StatusNotFound, err
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	writeJSON(w, http.StatusOK, account)
+	return http.StatusOK, nil
+}
+
+func (s *Service) createOrder(w http.ResponseWriter, r *http.Request) (int, error) {
+	var req struct {
+		AccountID string `json:"account_id"`
+		SKU       string `json:"sku"`
+		Quantity  int64  `json:"quantity"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AccountID == "" || req.SKU == "" || req.Quantity <= 0 {
+		return http.StatusBadRequest, errors.New("account_id, sku and a positive quantity are required")
+	}
+	ctx := r.Context()
+
+	// Call the downstream before opening the transaction, so no locks are held
+	// while waiting on the network
+	price, err := s.pricing.UnitPrice(ctx, req.SKU)
+	if errors.Is(err, ErrUnknownSKU) {
+		return http.StatusUnprocessableEntity, err
+	} else if err != nil {
+		return http.StatusBadGateway, err
+	}
+
+	order := &Order{
+		ID:        uuid.NewString(),
+		AccountID: req.AccountID,
+		SKU:       req.SKU,
+		Quantity:  req.Quantity,
+		Total:     price * req.Quantity,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	// Debit and record the order atomically. Writes go to the primary stores:
+	// the cache is only invalidated once the transaction has committed.
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		account, err := s.accountStore.Get(ctx, req.AccountID)
+		if err != nil {
+			return err
+		}
+		if account.Balance < order.Total {
+			return ErrInsufficientFunds
+		}
+		account.Balance -= order.Total
+		if err := s.accountStore.Update(ctx, account); err != nil {
+			return err
+		}
+		return s.orders.Create(ctx, order)
+	})
+	switch {
+	case errors.Is(err, sietch.ErrItemNotFound):
+		return http.StatusNotFound, err
+	case errors.Is(err, ErrInsufficientFunds):
+		return http.StatusConflict, err
+	case err != nil:
+		return http.StatusInternalServerError, err
+	}
+
+	if err := s.accountCache.Delete(ctx, req.AccountID); err != nil && !errors.Is(err, sietch.ErrItemNotFound) && !errors.Is(err, sietch.ErrNoDeleteItem) {
+		s.logger.WarnContext(ctx, "cache invalidation failed", "account", req.AccountID, "error", err)
+	}
+
+	writeJSON(w, http.StatusCreated, order)
+	return http.StatusCreated, nil
+}
+
+func (s *Service) getOrder(w http.ResponseWriter, r *http.Request) (int, error) {
+	order, err := s.orders.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, sietch.ErrItemNotFound) {
+		return http.StatusNotFound, err
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	writeJSON(w, http.StatusOK, order)
+	return http.StatusOK, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
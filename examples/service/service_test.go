@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seb7887/gofw/httpx/httpxtest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// newTestService runs the service against in-memory stores and a fake pricing service
+func newTestService(t *testing.T, pricingHandler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	pricingSrv := httpxtest.NewTestServerWithOptions(httpxtest.WithHandler(pricingHandler))
+	t.Cleanup(pricingSrv.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	registry := prometheus.NewRegistry()
+	provider := noop.NewTracerProvider()
+
+	stores, closeStores, err := newStores(context.Background(), "", "")
+	if err != nil {
+		t.Fatalf("newStores failed: %v", err)
+	}
+	t.Cleanup(closeStores)
+
+	pricing := NewPricingClient(newPricingHTTPClient(pricingSrv.URL, registry, provider, logger))
+	svc, err := NewService(stores, pricing, registry, provider, logger)
+	if err != nil {
+		t.Fatalf("NewService failed: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func prices(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/prices/widget":
+		_, _ = w.Write([]byte(`{"sku":"widget","unit_price":250}`))
+	case "/prices/broken":
+		w.WriteHeader(http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func call(t *testing.T, method, url string, body any, out any) int {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, url, reader)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("decode %s %s: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestService_OrderFlow(t *testing.T) {
+	srv := newTestService(t, prices)
+
+	var account Account
+	if code := call(t, http.MethodPost, srv.URL+"/accounts", map[string]any{"owner": "alice", "balance": 1000}, &account); code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating account, got %d", code)
+	}
+
+	// Warm the cache so the order has to invalidate it
+	var cached Account
+	if code := call(t, http.MethodGet, srv.URL+"/accounts/"+account.ID, nil, &cached); code != http.StatusOK || cached.Balance != 1000 {
+		t.Fatalf("Expected account with balance 1000, got %d %+v", code, cached)
+	}
+
+	var order Order
+	code := call(t, http.MethodPost, srv.URL+"/orders", map[string]any{"account_id": account.ID, "sku": "widget", "quantity": 3}, &order)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating order, got %d", code)
+	}
+	if order.Total != 750 {
+		t.Errorf("Expected total 750, got %d", order.Total)
+	}
+
+	var stored Order
+	if code := call(t, http.MethodGet, srv.URL+"/orders/"+order.ID, nil, &stored); code != http.StatusOK || stored.ID != order.ID {
+		t.Errorf("Expected stored order, got %d %+v", code, stored)
+	}
+
+	var debited Account
+	call(t, http.MethodGet, srv.URL+"/accounts/"+account.ID, nil, &debited)
+	if debited.Balance != 250 {
+		t.Errorf("Expected balance 250 after the order, got %d", debited.Balance)
+	}
+
+	t.Run("Insufficient funds", func(t *testing.T) {
+		code := call(t, http.MethodPost, srv.URL+"/orders", map[string]any{"account_id": account.ID, "sku": "widget", "quantity": 2}, nil)
+		if code != http.StatusConflict {
+			t.Errorf("Expected 409, got %d", code)
+		}
+	})
+
+	t.Run("Unknown account", func(t *testing.T) {
+		code := call(t, http.MethodPost, srv.URL+"/orders", map[string]any{"account_id": "missing", "sku": "widget", "quantity": 1}, nil)
+		if code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", code)
+		}
+	})
+
+	t.Run("Unknown SKU", func(t *testing.T) {
+		code := call(t, http.MethodPost, srv.URL+"/orders", map[string]any{"account_id": account.ID, "sku": "gadget", "quantity": 1}, nil)
+		if code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected 422, got %d", code)
+		}
+	})
+
+	t.Run("Downstream failure", func(t *testing.T) {
+		code := call(t, http.MethodPost, srv.URL+"/orders", map[string]any{"account_id": account.ID, "sku": "broken", "quantity": 1}, nil)
+		if code != http.StatusBadGateway {
+			t.Errorf("Expected 502, got %d", code)
+		}
+	})
+
+	t.Run("Invalid request", func(t *testing.T) {
+		code := call(t, http.MethodPost, srv.URL+"/orders", map[string]any{"sku": "widget"}, nil)
+		if code != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", code)
+		}
+	})
+}
+
+func TestService_Metrics(t *testing.T) {
+	srv := newTestService(t, prices)
+
+	var account Account
+	call(t, http.MethodPost, srv.URL+"/accounts", map[string]any{"owner": "bob", "balance": 500}, &account)
+	call(t, http.MethodPost, srv.URL+"/orders", map[string]any{"account_id": account.ID, "sku": "widget", "quantity": 1}, nil)
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	// Service and httpx metrics are exposed from the shared registry
+	for _, name := range []string{
+		`service_http_requests_total{code="201",route="POST /orders"} 1`,
+		"http_client_request_duration_seconds",
+	} {
+		if !strings.Contains(string(body), name) {
+			t.Errorf("Expected /metrics to contain %q", name)
+		}
+	}
+}
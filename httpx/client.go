@@ -2,26 +2,54 @@ package httpx
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"sync"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seb7887/gofw/httpx/observability"
 	"github.com/seb7887/gofw/httpx/policy"
 )
 
 // Client is the main HTTP client that orchestrates transport and policies.
-// It is thread-safe and immutable after creation.
+// It is thread-safe. Its configuration only changes through Reload.
 type Client struct {
+	// mu guards the configuration fields swapped by Reload
+	mu sync.RWMutex
+
 	// transport is the underlying HTTP executor
 	transport Transport
 
 	// baseURL is prepended to all request paths
 	baseURL string
 
+	// apiVersion is added to every request according to versionStrategy
+	apiVersion      string
+	versionStrategy VersionStrategy
+
+	// routeTemplater names spans and labels metrics after logical routes
+	routeTemplater observability.RouteTemplater
+
+	// jsonCodec encodes and decodes bodies for DoJSON; nil means StdJSONCodec
+	jsonCodec JSONCodec
+
 	// policies is the chain of resilience policies
 	policies []policy.Policy
 
 	// executor is the final chained executor (policies + transport)
 	executor policy.Executor
+
+	// version identifies the current configuration push
+	version string
+
+	// logger receives configuration change records
+	logger *slog.Logger
+
+	// collectors caches metrics collectors per registry and config so Reload
+	// can re-apply WithMetrics without registering the metrics twice
+	collectors map[collectorKey]*observability.MetricsCollector
 }
 
 // NewClient creates a new HTTP client with the provided options.
@@ -37,27 +65,240 @@ type Client struct {
 func NewClient(opts ...ClientOption) *Client {
 	// Default configuration
 	c := &Client{
-		transport: NewDefaultTransport(),
-		baseURL:   "",
-		policies:  []policy.Policy{},
+		transport:  NewDefaultTransport(),
+		baseURL:    "",
+		policies:   []policy.Policy{},
+		logger:     slog.Default(),
+		collectors: make(map[collectorKey]*observability.MetricsCollector),
 	}
 
+	c.configure(opts)
+
+	return c
+}
+
+// configure applies options and builds the policy chain.
+func (c *Client) configure(opts []ClientOption) {
 	// Apply options
 	for _, opt := range opts {
 		opt.apply(c)
 	}
 
+	// Connect policies that report to each other
+	c.wirePolicies()
+	c.placeResponseLimits()
+
 	// Build the policy chain
 	c.executor = policy.Chain(c.policies, c.transport.Do)
 
-	return c
+	c.publishVersion()
+}
+
+// Reload replaces the client configuration with the one described by opts,
+// as if they had been passed to NewClient. The transport, logger and metrics
+// collectors are kept unless opts override them. A structured diff of the
+// changes is logged and the config version metric is updated.
+//
+// Policies are rebuilt, so circuit breaker and bulkhead state starts fresh.
+// Requests already in flight finish with the previous configuration.
+//
+// Example:
+//
+//	changes := client.Reload(
+//	    httpx.WithConfigVersion("2024-06-01.2"),
+//	    httpx.WithBaseURL(cfg.BaseURL),
+//	    httpx.WithRetry(policy.RetryConfig{MaxAttempts: cfg.MaxAttempts}),
+//	)
+func (c *Client) Reload(opts ...ClientOption) []ConfigChange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	old := c.snapshot()
+
+	next := &Client{
+		transport:  c.transport,
+		policies:   []policy.Policy{},
+		logger:     c.logger,
+		collectors: c.collectors,
+	}
+	next.configure(opts)
+
+	c.transport = next.transport
+	c.baseURL = next.baseURL
+	c.apiVersion = next.apiVersion
+	c.versionStrategy = next.versionStrategy
+	c.routeTemplater = next.routeTemplater
+	c.jsonCodec = next.jsonCodec
+	c.policies = next.policies
+	c.executor = next.executor
+	c.version = next.version
+	c.logger = next.logger
+
+	current := c.snapshot()
+	changes := DiffConfig(old, current)
+	logConfigChanges(c.logger, old, current, changes)
+
+	return changes
+}
+
+// Snapshot returns the effective configuration of the client.
+func (c *Client) Snapshot() ConfigSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snapshot()
+}
+
+// snapshot builds a ConfigSnapshot. Must be called with the lock held.
+func (c *Client) snapshot() ConfigSnapshot {
+	s := ConfigSnapshot{
+		Version:    c.version,
+		BaseURL:    c.baseURL,
+		APIVersion: c.apiVersion,
+	}
+	if c.apiVersion != "" {
+		s.APIVersionStrategy = c.versionStrategy.String()
+	}
+
+	for _, p := range c.policies {
+		switch v := p.(type) {
+		case *policy.RetryPolicy:
+			cfg := v.Config()
+			s.Retry = &cfg
+		case *policy.CircuitBreakerPolicy:
+			cfg := v.Config()
+			s.CircuitBreaker = &cfg
+		case *policy.TimeoutPolicy:
+			cfg := v.Config()
+			s.Timeout = &cfg
+		case *policy.BulkheadPolicy:
+			cfg := v.Config()
+			s.Bulkhead = &cfg
+		case *policy.ResponseLimitPolicy:
+			cfg := v.Config()
+			s.ResponseLimit = &cfg
+		}
+	}
+
+	return s
+}
+
+// metricsCollector returns the collector for a registry, creating it on first use.
+func (c *Client) metricsCollector(registry prometheus.Registerer, cfg observability.MetricsConfig) *observability.MetricsCollector {
+	key := collectorKey{registry: registry, config: fmt.Sprintf("%s|%s|%v", cfg.Namespace, cfg.ClientName, cfg.ConstLabels)}
+	if collector, ok := c.collectors[key]; ok {
+		return collector
+	}
+	collector := observability.NewMetricsCollectorWithConfig(registry, cfg)
+	c.collectors[key] = collector
+	return collector
+}
+
+// collectorKey identifies a metrics collector by registry and config
+type collectorKey struct {
+	registry prometheus.Registerer
+	config   string
+}
+
+// publishVersion records the configuration version in every metrics policy.
+func (c *Client) publishVersion() {
+	if c.version == "" {
+		return
+	}
+	for _, p := range c.policies {
+		if m, ok := p.(*policy.MetricsPolicy); ok {
+			m.Collector().SetConfigVersion(c.version)
+		}
+	}
+}
+
+// responseLimitPolicy returns the client's response limit policy, adding it
+// to the chain on first use so that limit options share a single instance.
+func (c *Client) responseLimitPolicy() *policy.ResponseLimitPolicy {
+	for _, p := range c.policies {
+		if rl, ok := p.(*policy.ResponseLimitPolicy); ok {
+			return rl
+		}
+	}
+	rl := policy.NewResponseLimitPolicy(policy.ResponseLimitConfig{})
+	c.policies = append(c.policies, rl)
+	return rl
+}
+
+// wirePolicies connects policies that need to report to each other,
+// e.g. response limit violations feeding circuit breakers and metrics, and
+// hands the route templater to the observability policies.
+func (c *Client) wirePolicies() {
+	var breakers []*policy.CircuitBreakerPolicy
+	var metrics *policy.MetricsPolicy
+	var limits []*policy.ResponseLimitPolicy
+	var bulkheads []*policy.BulkheadPolicy
+
+	for _, p := range c.policies {
+		switch v := p.(type) {
+		case *policy.CircuitBreakerPolicy:
+			breakers = append(breakers, v)
+		case *policy.MetricsPolicy:
+			metrics = v
+			v.SetRouteTemplater(c.routeTemplater)
+		case *policy.InstrumentationPolicy:
+			v.SetRouteTemplater(c.routeTemplater)
+		case *policy.ResponseLimitPolicy:
+			limits = append(limits, v)
+		case *policy.BulkheadPolicy:
+			bulkheads = append(bulkheads, v)
+		}
+	}
+
+	if metrics != nil {
+		for _, bp := range bulkheads {
+			bp.AttachMetrics(metrics.Collector())
+		}
+	}
+
+	for _, rl := range limits {
+		for _, cb := range breakers {
+			rl.AttachCircuitBreaker(cb)
+		}
+		if metrics != nil {
+			rl.AttachMetrics(metrics.Collector())
+		}
+	}
+}
+
+// placeResponseLimits moves the response limit policies to the end of the
+// chain, next to the transport, so that responses rejected by their
+// Content-Length reach the circuit breakers as errors before they record the
+// call, whatever the order of the options.
+func (c *Client) placeResponseLimits() {
+	var limits, others []policy.Policy
+	for _, p := range c.policies {
+		if _, ok := p.(*policy.ResponseLimitPolicy); ok {
+			limits = append(limits, p)
+		} else {
+			others = append(others, p)
+		}
+	}
+	c.policies = append(others, limits...)
 }
 
 // Do executes an HTTP request with all configured policies applied.
 // This is the most flexible method, allowing full control over the request.
 func (c *Client) Do(ctx context.Context, req *Request) (*http.Response, error) {
+	c.mu.RLock()
+	baseURL, executor := c.baseURL, c.executor
+	version, strategy := c.apiVersion, c.versionStrategy
+	if IsRawMode(ctx) {
+		executor = c.rawExecutor()
+	}
+	c.mu.RUnlock()
+
+	cfg := applyOptions(req.Options)
+	if cfg.apiVersion != nil {
+		version = *cfg.apiVersion
+	}
+
 	// Convert to http.Request
-	httpReq, err := req.toHTTPRequest(c.baseURL)
+	httpReq, err := req.toHTTPRequest(strategy.versionedBaseURL(baseURL, version))
 	if err != nil {
 		return nil, &RequestError{
 			Err:     err,
@@ -65,11 +306,44 @@ func (c *Client) Do(ctx context.Context, req *Request) (*http.Response, error) {
 			Cause:   "invalid_request",
 		}
 	}
+	strategy.apply(httpReq, version)
 
-	// TODO: Apply per-request options (timeout overrides, policy disabling, etc)
-	// For now, just execute with the client's policy chain
+	if cfg.streaming {
+		ctx = policy.WithStreaming(ctx)
+	}
+	if overrides, ok := cfg.overrides(); ok {
+		ctx = policy.WithOverrides(ctx, overrides)
+	}
+	if cfg.timeout != nil && !cfg.disableTimeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *cfg.timeout)
+		resp, err := executor(ctx, httpReq)
+		return resp, cancelOnClose(resp, err, cancel)
+	}
+
+	return executor(ctx, httpReq)
+}
+
+// cancelOnClose calls cancel once the response body is closed, or at once
+// when there is no body to read, so a request deadline covers the body too.
+func cancelOnClose(resp *http.Response, err error, cancel context.CancelFunc) error {
+	if err != nil || resp == nil || resp.Body == nil {
+		cancel()
+		return err
+	}
+	resp.Body = &cancelingBody{ReadCloser: resp.Body, cancel: cancel}
+	return nil
+}
+
+// cancelingBody releases the context of its request when closed.
+type cancelingBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
 
-	return c.executor(ctx, httpReq)
+func (b *cancelingBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
 }
 
 // Get executes a GET request to the specified path.
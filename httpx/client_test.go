@@ -6,9 +6,12 @@ import (
 	"io"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/seb7887/gofw/httpx"
+	"github.com/seb7887/gofw/httpx/backoff"
 	"github.com/seb7887/gofw/httpx/httpxtest"
+	"github.com/seb7887/gofw/httpx/policy"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -92,3 +95,196 @@ func TestClient_WithTestServer(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 	assert.Equal(t, 1, server.RequestCount())
 }
+
+func TestClient_ResponseBodyLimitPerHost(t *testing.T) {
+	mockTransport := &httpxtest.MockTransport{
+		Func: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode:    http.StatusInternalServerError,
+				Body:          io.NopCloser(bytes.NewBufferString("a very large error page")),
+				ContentLength: 23,
+			}, nil
+		},
+	}
+
+	client := httpx.NewClient(
+		httpx.WithTransport(mockTransport),
+		httpx.WithBaseURL("http://upstream.example.com"),
+		httpx.WithMaxResponseBytes(1024),
+		httpx.WithResponseBodyLimitPerHost(map[string]int64{"upstream.example.com": 8}),
+	)
+
+	_, err := client.Get(context.Background(), "/errors")
+	assert.ErrorIs(t, err, httpx.ErrResponseTooLarge)
+}
+
+func TestClient_ResponseBodyLimitCheckedBeforeBreaker(t *testing.T) {
+	mockTransport := &httpxtest.MockTransport{
+		Func: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode:    http.StatusOK,
+				Body:          io.NopCloser(bytes.NewBufferString("way too large")),
+				ContentLength: 13,
+			}, nil
+		},
+	}
+
+	// The limit option comes first, yet the breaker sees the rejection
+	client := httpx.NewClient(
+		httpx.WithTransport(mockTransport),
+		httpx.WithBaseURL("http://upstream.example.com"),
+		httpx.WithMaxResponseBytes(4),
+		httpx.WithCircuitBreaker(policy.CircuitBreakerConfig{
+			ErrorThreshold: 50,
+			MinRequests:    2,
+			SleepWindow:    time.Minute,
+		}),
+	)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Get(context.Background(), "/report")
+		assert.ErrorIs(t, err, httpx.ErrResponseTooLarge)
+	}
+	// The open breaker rejects the call before it reaches the transport
+	_, err := client.Get(context.Background(), "/report")
+	assert.Error(t, err)
+	assert.Equal(t, 2, mockTransport.CallCount)
+}
+
+func TestClient_StreamingRequestsUseStreamPool(t *testing.T) {
+	mockTransport := &httpxtest.MockTransport{
+		Func: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString("data: 1\n\n")),
+			}, nil
+		},
+	}
+
+	client := httpx.NewClient(
+		httpx.WithTransport(mockTransport),
+		httpx.WithBaseURL("http://events.example.com"),
+		httpx.WithBulkhead(policy.BulkheadConfig{MaxConcurrent: 1, MaxConcurrentStreams: 1}),
+	)
+
+	ctx := context.Background()
+	stream, err := client.Do(ctx, &httpx.Request{
+		Method:  http.MethodGet,
+		Path:    "/sse",
+		Options: []httpx.RequestOption{httpx.WithStreaming()},
+	})
+	require.NoError(t, err)
+	defer stream.Body.Close()
+
+	// The open stream does not block unary requests
+	resp, err := client.Get(ctx, "/status")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// But a second stream is rejected until the first is closed
+	_, err = client.Do(ctx, &httpx.Request{
+		Method:  http.MethodGet,
+		Path:    "/sse",
+		Options: []httpx.RequestOption{httpx.WithStreaming()},
+	})
+	assert.Error(t, err)
+}
+
+func TestClient_RequestOptions(t *testing.T) {
+	failing := func() *httpxtest.MockTransport {
+		return &httpxtest.MockTransport{
+			Func: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+			},
+		}
+	}
+	retry := httpx.WithRetry(policy.RetryConfig{
+		MaxAttempts:    3,
+		Backoff:        backoff.NewConstantBackoff(time.Millisecond),
+		OnlyIdempotent: true,
+	})
+	do := func(client *httpx.Client, method string, opts ...httpx.RequestOption) (*http.Response, error) {
+		return client.Do(context.Background(), &httpx.Request{Method: method, Path: "/", Options: opts})
+	}
+
+	t.Run("WithoutRetry", func(t *testing.T) {
+		mockTransport := failing()
+		client := httpx.NewClient(httpx.WithTransport(mockTransport), retry)
+
+		_, err := do(client, http.MethodGet, httpx.WithoutRetry())
+		require.NoError(t, err)
+		assert.Equal(t, 1, mockTransport.CallCount)
+
+		_, err = do(client, http.MethodGet)
+		require.Error(t, err, "retries are exhausted")
+		assert.Equal(t, 4, mockTransport.CallCount)
+	})
+
+	t.Run("WithRetryable", func(t *testing.T) {
+		mockTransport := failing()
+		client := httpx.NewClient(httpx.WithTransport(mockTransport), retry)
+
+		_, err := do(client, http.MethodPost, httpx.WithRetryable(true))
+		require.Error(t, err, "retries are exhausted")
+		assert.Equal(t, 3, mockTransport.CallCount, "POST opted in to retries")
+
+		_, err = do(client, http.MethodGet, httpx.WithRetryable(false))
+		require.NoError(t, err)
+		assert.Equal(t, 4, mockTransport.CallCount, "GET opted out of retries")
+	})
+
+	t.Run("WithoutCircuitBreaker", func(t *testing.T) {
+		mockTransport := failing()
+		client := httpx.NewClient(
+			httpx.WithTransport(mockTransport),
+			httpx.WithCircuitBreaker(policy.CircuitBreakerConfig{ErrorThreshold: 50, MinRequests: 1, SleepWindow: time.Minute}),
+		)
+
+		_, err := do(client, http.MethodGet)
+		require.NoError(t, err)
+		_, err = do(client, http.MethodGet)
+		require.Error(t, err, "the circuit is open")
+
+		resp, err := do(client, http.MethodGet, httpx.WithoutCircuitBreaker())
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, 2, mockTransport.CallCount)
+	})
+
+	t.Run("WithoutBulkhead", func(t *testing.T) {
+		client := httpx.NewClient(
+			httpx.WithTransport(failing()),
+			httpx.WithBulkhead(policy.BulkheadConfig{MaxConcurrent: 1, MaxConcurrentStreams: 1}),
+		)
+		stream, err := do(client, http.MethodGet, httpx.WithStreaming())
+		require.NoError(t, err)
+		defer stream.Body.Close()
+
+		_, err = do(client, http.MethodGet, httpx.WithStreaming())
+		require.Error(t, err, "the streaming pool is full")
+		_, err = do(client, http.MethodGet, httpx.WithStreaming(), httpx.WithoutBulkhead())
+		require.NoError(t, err)
+	})
+
+	t.Run("WithRequestTimeout", func(t *testing.T) {
+		mockTransport := &httpxtest.MockTransport{
+			Func: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(time.Second):
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+				}
+			},
+		}
+		client := httpx.NewClient(
+			httpx.WithTransport(mockTransport),
+			httpx.WithTimeout(policy.TimeoutConfig{Request: time.Minute}),
+		)
+
+		start := time.Now()
+		_, err := do(client, http.MethodGet, httpx.WithRequestTimeout(20*time.Millisecond))
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+}
@@ -0,0 +1,142 @@
+package httpx
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+
+	"github.com/seb7887/gofw/httpx/policy"
+)
+
+// ConfigSnapshot captures the effective configuration of a client, including
+// policy defaults. Policies that are not configured are nil.
+type ConfigSnapshot struct {
+	// Version identifies the configuration push (see WithConfigVersion)
+	Version string
+
+	BaseURL string
+
+	// APIVersion is the version added to every request; APIVersionStrategy
+	// describes how ("path", "header:<name>" or "query:<name>")
+	APIVersion         string
+	APIVersionStrategy string
+
+	Retry          *policy.RetryConfig
+	CircuitBreaker *policy.CircuitBreakerConfig
+	Timeout        *policy.TimeoutConfig
+	Bulkhead       *policy.BulkheadConfig
+	ResponseLimit  *policy.ResponseLimitConfig
+}
+
+// ConfigChange describes one setting that differs between two snapshots.
+// Field is a dotted path such as "Retry.MaxAttempts" or "ResponseLimit.PerHost[api.internal]".
+// Unset values are reported as "<unset>" and custom functions as "func".
+type ConfigChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// DiffConfig returns the settings that differ between old and new, sorted by field.
+// Custom functions (ShouldRetry, ShouldTrip) are compared by presence only.
+func DiffConfig(old, new ConfigSnapshot) []ConfigChange {
+	before := make(map[string]string)
+	after := make(map[string]string)
+	flattenConfig("", reflect.ValueOf(old), before)
+	flattenConfig("", reflect.ValueOf(new), after)
+	delete(before, "Version")
+	delete(after, "Version")
+
+	fields := make(map[string]struct{}, len(before)+len(after))
+	for f := range before {
+		fields[f] = struct{}{}
+	}
+	for f := range after {
+		fields[f] = struct{}{}
+	}
+
+	var changes []ConfigChange
+	for f := range fields {
+		o, ok := before[f]
+		if !ok {
+			o = "<unset>"
+		}
+		n, ok := after[f]
+		if !ok {
+			n = "<unset>"
+		}
+		if o != n {
+			changes = append(changes, ConfigChange{Field: f, Old: o, New: n})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+// flattenConfig renders v into out as dotted field paths mapped to string values.
+func flattenConfig(prefix string, v reflect.Value, out map[string]string) {
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		if v.Kind() == reflect.Interface {
+			// Record the implementation so swapping e.g. backoff strategies shows up
+			out[join("Type")] = v.Elem().Type().String()
+		}
+		flattenConfig(prefix, v.Elem(), out)
+		return
+	case reflect.Func:
+		if !v.IsNil() {
+			out[prefix] = "func"
+		}
+		return
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			flattenConfig(fmt.Sprintf("%s[%v]", prefix, key), v.MapIndex(key), out)
+		}
+		return
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				flattenConfig(join(t.Field(i).Name), v.Field(i), out)
+			}
+		}
+		return
+	}
+
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		out[prefix] = s.String()
+		return
+	}
+	out[prefix] = fmt.Sprint(v.Interface())
+}
+
+// logConfigChanges logs a structured record of the differences between two snapshots.
+func logConfigChanges(logger *slog.Logger, old, new ConfigSnapshot, changes []ConfigChange) {
+	attrs := make([]any, 0, len(changes))
+	for _, c := range changes {
+		attrs = append(attrs, slog.Group(c.Field, slog.String("old", c.Old), slog.String("new", c.New)))
+	}
+
+	logger.Info("httpx: client configuration reloaded",
+		slog.String("old_version", old.Version),
+		slog.String("new_version", new.Version),
+		slog.Int("changed", len(changes)),
+		slog.Group("changes", attrs...),
+	)
+}
@@ -0,0 +1,117 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/seb7887/gofw/httpx"
+	"github.com/seb7887/gofw/httpx/backoff"
+	"github.com/seb7887/gofw/httpx/httpxtest"
+	"github.com/seb7887/gofw/httpx/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Snapshot(t *testing.T) {
+	client := httpx.NewClient(
+		httpx.WithConfigVersion("v1"),
+		httpx.WithBaseURL("http://api.internal"),
+		httpx.WithRetry(policy.RetryConfig{MaxAttempts: 5}),
+		httpx.WithTimeout(policy.TimeoutConfig{}),
+	)
+
+	snap := client.Snapshot()
+	assert.Equal(t, "v1", snap.Version)
+	assert.Equal(t, "http://api.internal", snap.BaseURL)
+	require.NotNil(t, snap.Retry)
+	assert.Equal(t, 5, snap.Retry.MaxAttempts)
+	require.NotNil(t, snap.Timeout)
+	assert.Equal(t, 30*time.Second, snap.Timeout.Request, "snapshot includes defaults")
+	assert.Nil(t, snap.CircuitBreaker)
+}
+
+func TestDiffConfig(t *testing.T) {
+	old := httpx.ConfigSnapshot{
+		Retry:         &policy.RetryConfig{MaxAttempts: 3, Backoff: backoff.NewConstantBackoff(time.Second)},
+		Timeout:       &policy.TimeoutConfig{Request: 10 * time.Second},
+		ResponseLimit: &policy.ResponseLimitConfig{PerHost: map[string]int64{"a": 1}},
+	}
+	new := httpx.ConfigSnapshot{
+		Retry:         &policy.RetryConfig{MaxAttempts: 3, Backoff: backoff.NewLinearBackoff(time.Second)},
+		Timeout:       &policy.TimeoutConfig{Request: 5 * time.Second},
+		ResponseLimit: &policy.ResponseLimitConfig{PerHost: map[string]int64{"a": 1, "b": 2}},
+	}
+
+	changes := httpx.DiffConfig(old, new)
+	assert.Equal(t, []httpx.ConfigChange{
+		{Field: "ResponseLimit.PerHost[b]", Old: "<unset>", New: "2"},
+		{Field: "Retry.Backoff.Type", Old: "*backoff.ConstantBackoff", New: "*backoff.LinearBackoff"},
+		{Field: "Timeout.Request", Old: "10s", New: "5s"},
+	}, changes)
+
+	assert.Empty(t, httpx.DiffConfig(old, old))
+}
+
+func TestClient_Reload(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	registry := prometheus.NewRegistry()
+
+	var hosts []string
+	mockTransport := &httpxtest.MockTransport{
+		Func: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			hosts = append(hosts, req.URL.Host)
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		},
+	}
+
+	client := httpx.NewClient(
+		httpx.WithTransport(mockTransport),
+		httpx.WithLogger(logger),
+		httpx.WithConfigVersion("v1"),
+		httpx.WithMetrics(registry),
+		httpx.WithBaseURL("http://old.internal"),
+		httpx.WithRetry(policy.RetryConfig{MaxAttempts: 3}),
+	)
+
+	changes := client.Reload(
+		httpx.WithConfigVersion("v2"),
+		httpx.WithMetrics(registry), // re-applying must not register metrics twice
+		httpx.WithBaseURL("http://new.internal"),
+		httpx.WithRetry(policy.RetryConfig{MaxAttempts: 5}),
+	)
+
+	assert.Contains(t, changes, httpx.ConfigChange{Field: "BaseURL", Old: "http://old.internal", New: "http://new.internal"})
+	assert.Contains(t, changes, httpx.ConfigChange{Field: "Retry.MaxAttempts", Old: "3", New: "5"})
+
+	// Transport is kept and the new base URL is used
+	resp, err := client.Get(context.Background(), "/ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"new.internal"}, hosts)
+
+	// Structured diff log
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &record))
+	assert.Equal(t, "v1", record["old_version"])
+	assert.Equal(t, "v2", record["new_version"])
+	retry := record["changes"].(map[string]any)["Retry.MaxAttempts"].(map[string]any)
+	assert.Equal(t, "3", retry["old"])
+	assert.Equal(t, "5", retry["new"])
+
+	// Version metric only reports the current version
+	expected := `
+# HELP http_client_config_version Client configuration version currently in effect (always 1)
+# TYPE http_client_config_version gauge
+http_client_config_version{version="v2"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "http_client_config_version"))
+}
@@ -0,0 +1,292 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// AddressFamily controls which IP family is dialed first for dual-stack hosts.
+type AddressFamily int
+
+const (
+	// AddressFamilyAuto uses the standard library behavior: the family of the
+	// first resolved address is tried first (RFC 6724 ordering).
+	AddressFamilyAuto AddressFamily = iota
+	// PreferIPv4 tries IPv4 addresses first and falls back to IPv6.
+	PreferIPv4
+	// PreferIPv6 tries IPv6 addresses first and falls back to IPv4.
+	PreferIPv6
+	// IPv4Only never dials IPv6 addresses.
+	IPv4Only
+	// IPv6Only never dials IPv4 addresses.
+	IPv6Only
+)
+
+// String returns the name of the address family preference.
+func (f AddressFamily) String() string {
+	switch f {
+	case AddressFamilyAuto:
+		return "auto"
+	case PreferIPv4:
+		return "prefer_ipv4"
+	case PreferIPv6:
+		return "prefer_ipv6"
+	case IPv4Only:
+		return "ipv4_only"
+	case IPv6Only:
+		return "ipv6_only"
+	default:
+		return fmt.Sprintf("AddressFamily(%d)", int(f))
+	}
+}
+
+// defaultFallbackDelay matches the standard library's Happy Eyeballs delay.
+const defaultFallbackDelay = 300 * time.Millisecond
+
+// DialerConfig configures how connections are established.
+type DialerConfig struct {
+	// Timeout is the maximum time for a single connection attempt.
+	// Default: 30s
+	Timeout time.Duration
+
+	// KeepAlive is the TCP keep-alive period.
+	// Default: 30s
+	KeepAlive time.Duration
+
+	// FallbackDelay is how long to wait for the preferred address family
+	// before racing a connection to the other family (Happy Eyeballs, RFC 6555).
+	// Zero uses the standard 300ms; a negative value disables the race, so the
+	// other family is only tried after every preferred address has failed.
+	FallbackDelay time.Duration
+
+	// AddressFamily selects which IP family is dialed first, or restricts
+	// dialing to one family.
+	// Default: AddressFamilyAuto
+	AddressFamily AddressFamily
+
+	// Resolver resolves host names. Default: net.DefaultResolver
+	Resolver *net.Resolver
+}
+
+func (c DialerConfig) withDefaults() DialerConfig {
+	if c.Timeout == 0 {
+		c.Timeout = 30 * time.Second
+	}
+	if c.KeepAlive == 0 {
+		c.KeepAlive = 30 * time.Second
+	}
+	if c.Resolver == nil {
+		c.Resolver = net.DefaultResolver
+	}
+	return c
+}
+
+// Dialer establishes connections according to a DialerConfig.
+// Its DialContext method can be used as http.Transport.DialContext.
+type Dialer struct {
+	config DialerConfig
+	dialer *net.Dialer
+}
+
+// NewDialer creates a dialer with the given configuration.
+func NewDialer(config DialerConfig) *Dialer {
+	config = config.withDefaults()
+	return &Dialer{
+		config: config,
+		dialer: &net.Dialer{
+			Timeout:       config.Timeout,
+			KeepAlive:     config.KeepAlive,
+			FallbackDelay: config.FallbackDelay,
+			Resolver:      config.Resolver,
+		},
+	}
+}
+
+// Config returns the dialer configuration, including defaults.
+func (d *Dialer) Config() DialerConfig {
+	return d.config
+}
+
+// DialContext connects to the address on the named network.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch d.config.AddressFamily {
+	case IPv4Only:
+		return d.dialer.DialContext(ctx, restrictNetwork(network, "4"), address)
+	case IPv6Only:
+		return d.dialer.DialContext(ctx, restrictNetwork(network, "6"), address)
+	case PreferIPv4, PreferIPv6:
+		return d.dialPreferred(ctx, network, address)
+	default:
+		return d.dialer.DialContext(ctx, network, address)
+	}
+}
+
+// restrictNetwork turns "tcp" into "tcp4"/"tcp6"; networks that already name a family are kept.
+func restrictNetwork(network, family string) string {
+	switch network {
+	case "tcp", "udp", "ip":
+		return network + family
+	}
+	return network
+}
+
+// dialPreferred resolves the host itself so the preferred family is tried
+// first, racing the other family after FallbackDelay.
+func (d *Dialer) dialPreferred(ctx context.Context, network, address string) (net.Conn, error) {
+	if network != "tcp" {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+
+	addrs, err := d.config.Resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	primaries, fallbacks := partitionAddrs(addrs, d.config.AddressFamily == PreferIPv4)
+
+	if len(fallbacks) == 0 {
+		return d.dialSerial(ctx, primaries, port)
+	}
+	if len(primaries) == 0 {
+		return d.dialSerial(ctx, fallbacks, port)
+	}
+	return d.dialParallel(ctx, primaries, fallbacks, port)
+}
+
+// partitionAddrs splits addresses into the preferred family and the rest,
+// keeping the resolver's order within each group.
+func partitionAddrs(addrs []net.IPAddr, preferIPv4 bool) (primaries, fallbacks []net.IPAddr) {
+	for _, addr := range addrs {
+		if (addr.IP.To4() != nil) == preferIPv4 {
+			primaries = append(primaries, addr)
+		} else {
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	return primaries, fallbacks
+}
+
+// dialSerial tries each address in order and returns the first connection.
+func (d *Dialer) dialSerial(ctx context.Context, addrs []net.IPAddr, port string) (net.Conn, error) {
+	var firstErr error
+	for _, addr := range addrs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		conn, err := d.dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = errors.New("httpx: no addresses to dial")
+	}
+	return nil, firstErr
+}
+
+// dialParallel races primaries against fallbacks, starting the fallbacks after
+// FallbackDelay or as soon as every primary has failed.
+func (d *Dialer) dialParallel(ctx context.Context, primaries, fallbacks []net.IPAddr, port string) (net.Conn, error) {
+	delay := d.config.FallbackDelay
+	if delay == 0 {
+		delay = defaultFallbackDelay
+	}
+	if delay < 0 {
+		conn, err := d.dialSerial(ctx, primaries, port)
+		if err == nil {
+			return conn, nil
+		}
+		return d.dialSerial(ctx, fallbacks, port)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	results := make(chan result, 2)
+	race := func(addrs []net.IPAddr, primary bool) {
+		conn, err := d.dialSerial(ctx, addrs, port)
+		results <- result{conn: conn, err: err, primary: primary}
+	}
+
+	go race(primaries, true)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var primaryErr, fallbackErr error
+	fallbackStarted := false
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				go race(fallbacks, false)
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				// Close the losing connection, if the other dial also succeeds
+				if pending > 0 {
+					go func() {
+						if other := <-results; other.conn != nil {
+							other.conn.Close()
+						}
+					}()
+				}
+				return res.conn, nil
+			}
+			if res.primary {
+				primaryErr = res.err
+			} else {
+				fallbackErr = res.err
+			}
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				go race(fallbacks, false)
+			}
+			if pending == 0 {
+				if primaryErr != nil {
+					return nil, primaryErr
+				}
+				return nil, fallbackErr
+			}
+		}
+	}
+}
+
+// NewDefaultTransportWithDialer creates a transport with the same defaults as
+// NewDefaultTransport that connects through a Dialer built from config.
+func NewDefaultTransportWithDialer(config DialerConfig) *DefaultTransport {
+	return &DefaultTransport{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext:         NewDialer(config).DialContext,
+				MaxIdleConns:        100,
+				MaxIdleConnsPerHost: 10,
+				IdleConnTimeout:     90 * time.Second,
+				ForceAttemptHTTP2:   true,
+			},
+		},
+	}
+}
@@ -0,0 +1,63 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionAddrs(t *testing.T) {
+	addrs := []net.IPAddr{
+		{IP: net.ParseIP("2001:db8::1")},
+		{IP: net.ParseIP("192.0.2.1")},
+		{IP: net.ParseIP("2001:db8::2")},
+		{IP: net.ParseIP("192.0.2.2")},
+	}
+
+	primaries, fallbacks := partitionAddrs(addrs, true)
+	assert.Equal(t, []net.IPAddr{addrs[1], addrs[3]}, primaries)
+	assert.Equal(t, []net.IPAddr{addrs[0], addrs[2]}, fallbacks)
+
+	primaries, fallbacks = partitionAddrs(addrs, false)
+	assert.Equal(t, []net.IPAddr{addrs[0], addrs[2]}, primaries)
+	assert.Equal(t, []net.IPAddr{addrs[1], addrs[3]}, fallbacks)
+}
+
+func TestDialParallel(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	listening := []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}
+	// Nothing listens on ::1 (or IPv6 is unavailable), so these dials fail
+	refused := []net.IPAddr{{IP: net.ParseIP("::1")}}
+
+	for _, delay := range []time.Duration{-1, 0, 5 * time.Millisecond} {
+		d := NewDialer(DialerConfig{FallbackDelay: delay, Timeout: time.Second})
+
+		conn, err := d.dialParallel(context.Background(), refused, listening, port)
+		require.NoError(t, err, "fallback after failed primaries (delay %v)", delay)
+		conn.Close()
+
+		conn, err = d.dialParallel(context.Background(), listening, refused, port)
+		require.NoError(t, err, "primary success (delay %v)", delay)
+		conn.Close()
+
+		_, err = d.dialParallel(context.Background(), refused, refused, port)
+		assert.Error(t, err, "all addresses fail (delay %v)", delay)
+	}
+}
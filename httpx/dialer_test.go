@@ -0,0 +1,75 @@
+package httpx_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/httpx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialer_AddressFamily(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+	ctx := context.Background()
+
+	t.Run("IPv4Only dials IPv4", func(t *testing.T) {
+		d := httpx.NewDialer(httpx.DialerConfig{AddressFamily: httpx.IPv4Only})
+		conn, err := d.DialContext(ctx, "tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		require.NoError(t, err)
+		conn.Close()
+	})
+
+	t.Run("IPv6Only rejects IPv4 addresses", func(t *testing.T) {
+		d := httpx.NewDialer(httpx.DialerConfig{AddressFamily: httpx.IPv6Only})
+		_, err := d.DialContext(ctx, "tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		assert.Error(t, err)
+	})
+
+	t.Run("PreferIPv6 falls back to IPv4", func(t *testing.T) {
+		d := httpx.NewDialer(httpx.DialerConfig{AddressFamily: httpx.PreferIPv6, FallbackDelay: 10 * time.Millisecond})
+		conn, err := d.DialContext(ctx, "tcp", fmt.Sprintf("localhost:%d", port))
+		require.NoError(t, err)
+		assert.Equal(t, "127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String())
+		conn.Close()
+	})
+}
+
+func TestWithDialer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := httpx.NewClient(
+		httpx.WithBaseURL(server.URL),
+		httpx.WithDialer(httpx.DialerConfig{AddressFamily: httpx.PreferIPv4}),
+	)
+
+	resp, err := client.Get(context.Background(), "/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestAddressFamily_String(t *testing.T) {
+	assert.Equal(t, "prefer_ipv4", httpx.PreferIPv4.String())
+	assert.Equal(t, "auto", httpx.AddressFamilyAuto.String())
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+
+	"github.com/seb7887/gofw/httpx/policy"
 )
 
 // Sentinel errors that can be checked using errors.Is
@@ -19,6 +21,12 @@ var (
 
 	// ErrMaxRetriesExceeded is returned when all retry attempts have been exhausted.
 	ErrMaxRetriesExceeded = errors.New("max retry attempts exceeded")
+
+	// ErrRampUpShed is returned when a request is shed while a recovered circuit ramps up.
+	ErrRampUpShed = policy.ErrRampUpShed
+
+	// ErrResponseTooLarge is returned when a response body exceeds its size budget.
+	ErrResponseTooLarge = policy.ErrResponseTooLarge
 )
 
 // RequestError provides rich context about failed HTTP requests.
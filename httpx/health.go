@@ -0,0 +1,116 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/seb7887/gofw/httpx/policy"
+)
+
+// Health check preset defaults.
+const (
+	// DefaultHealthCheckTimeout bounds a whole health check, including the dial.
+	DefaultHealthCheckTimeout = 2 * time.Second
+
+	// DefaultHealthCheckDialTimeout bounds connection establishment.
+	DefaultHealthCheckDialTimeout = time.Second
+
+	// healthCheckBodyLimit is how much of a response body is drained so the
+	// connection can be reused.
+	healthCheckBodyLimit = 4 << 10
+)
+
+// ErrUnhealthy is returned by HealthCheckClient.Check when the target
+// responds with a non-2xx status.
+var ErrUnhealthy = errors.New("health check failed")
+
+// HealthStatus is the outcome of a health check.
+type HealthStatus string
+
+const (
+	// HealthUp means the target responded with a 2xx status.
+	HealthUp HealthStatus = "up"
+	// HealthDown means the target responded with a non-2xx status.
+	HealthDown HealthStatus = "down"
+	// HealthUnreachable means no response was received (dial error, timeout, etc).
+	HealthUnreachable HealthStatus = "unreachable"
+)
+
+// HealthCheckClient probes a single health endpoint. It is a Client preset
+// with short timeouts and no retries, circuit breaker, metrics or config
+// logging, so probes neither mask failures nor skew the metrics of the
+// resilient clients that talk to the same service.
+type HealthCheckClient struct {
+	client *Client
+}
+
+// NewHealthCheckClient creates a health check client for target, the full URL
+// of the health endpoint. opts are applied after the preset, so they can
+// replace the transport (e.g. in tests) or tighten the timeout; avoid adding
+// retry or circuit breaker policies, which defeat the purpose of a probe.
+//
+// Example:
+//
+//	probe := httpx.NewHealthCheckClient("http://service-b:8080/healthz")
+//	status, latency, err := probe.Check(ctx)
+func NewHealthCheckClient(target string, opts ...ClientOption) *HealthCheckClient {
+	preset := []ClientOption{
+		WithTransport(newHealthCheckTransport()),
+		WithBaseURL(target),
+		WithTimeout(policy.TimeoutConfig{Request: DefaultHealthCheckTimeout}),
+		WithLogger(slog.New(slog.DiscardHandler)),
+	}
+
+	return &HealthCheckClient{client: NewClient(append(preset, opts...)...)}
+}
+
+// newHealthCheckTransport keeps a single idle connection and fails fast on
+// dial, TLS and response header stalls.
+func newHealthCheckTransport() *DefaultTransport {
+	return NewDefaultTransportWithClient(&http.Client{
+		Transport: &http.Transport{
+			DialContext:           NewDialer(DialerConfig{Timeout: DefaultHealthCheckDialTimeout}).DialContext,
+			MaxIdleConns:          1,
+			MaxIdleConnsPerHost:   1,
+			IdleConnTimeout:       30 * time.Second,
+			TLSHandshakeTimeout:   DefaultHealthCheckDialTimeout,
+			ResponseHeaderTimeout: DefaultHealthCheckTimeout,
+		},
+		// Redirects on a health endpoint are a misconfiguration, report them as-is
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	})
+}
+
+// Check sends a GET request to the target and reports its status and the
+// time until the response headers were received. The response body is
+// drained and closed. err is nil only for HealthUp; for HealthDown it wraps
+// ErrUnhealthy.
+func (h *HealthCheckClient) Check(ctx context.Context) (HealthStatus, time.Duration, error) {
+	start := time.Now()
+	resp, err := h.client.Do(ctx, &Request{
+		Method: http.MethodGet,
+		Options: []RequestOption{
+			WithoutRetry(),
+			WithoutCircuitBreaker(),
+			WithoutBulkhead(),
+		},
+	})
+	latency := time.Since(start)
+	if err != nil {
+		return HealthUnreachable, latency, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, healthCheckBodyLimit))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return HealthDown, latency, fmt.Errorf("%w: status %d", ErrUnhealthy, resp.StatusCode)
+	}
+	return HealthUp, latency, nil
+}
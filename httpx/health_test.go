@@ -0,0 +1,82 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/httpx"
+	"github.com/seb7887/gofw/httpx/httpxtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthCheckClient_Check(t *testing.T) {
+	t.Run("2xx is up", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/healthz", r.URL.Path)
+			_, _ = w.Write([]byte(`{"status":"ok"}`))
+		}))
+		defer server.Close()
+
+		status, latency, err := httpx.NewHealthCheckClient(server.URL + "/healthz").Check(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, httpx.HealthUp, status)
+		assert.Positive(t, latency)
+	})
+
+	t.Run("Non-2xx is down and not retried", func(t *testing.T) {
+		transport := &httpxtest.MockTransport{
+			Response: &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+			},
+		}
+		probe := httpx.NewHealthCheckClient("http://svc/healthz", httpx.WithTransport(transport))
+
+		status, _, err := probe.Check(context.Background())
+
+		assert.Equal(t, httpx.HealthDown, status)
+		assert.ErrorIs(t, err, httpx.ErrUnhealthy)
+		assert.Equal(t, 1, transport.CallCount)
+	})
+
+	t.Run("Transport error is unreachable", func(t *testing.T) {
+		transport := &httpxtest.MockTransport{
+			Func: func(context.Context, *http.Request) (*http.Response, error) {
+				return nil, errors.New("connection refused")
+			},
+		}
+		probe := httpx.NewHealthCheckClient("http://svc/healthz", httpx.WithTransport(transport))
+
+		status, _, err := probe.Check(context.Background())
+
+		assert.Equal(t, httpx.HealthUnreachable, status)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, httpx.ErrUnhealthy)
+	})
+
+	t.Run("Slow target times out", func(t *testing.T) {
+		transport := &httpxtest.MockTransport{
+			Func: func(ctx context.Context, _ *http.Request) (*http.Response, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		}
+		probe := httpx.NewHealthCheckClient("http://svc/healthz", httpx.WithTransport(transport))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		status, latency, err := probe.Check(ctx)
+
+		assert.Equal(t, httpx.HealthUnreachable, status)
+		assert.Error(t, err)
+		assert.Less(t, latency, httpx.DefaultHealthCheckTimeout)
+	})
+}
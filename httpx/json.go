@@ -0,0 +1,156 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// JSONCodec marshals request bodies and unmarshals response bodies for
+// DoJSON, GetJSON and PostJSON. The default is encoding/json.
+//
+// sonic's API values (sonic.ConfigDefault, sonic.ConfigStd) implement it
+// directly; package-level codecs such as go-json can be adapted with
+// JSONCodecFuncs.
+type JSONCodec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodecFuncs adapts a pair of Marshal/Unmarshal functions to JSONCodec.
+//
+// Example:
+//
+//	httpx.WithJSONCodec(httpx.JSONCodecFuncs{
+//	    MarshalFunc:   gojson.Marshal,
+//	    UnmarshalFunc: gojson.Unmarshal,
+//	})
+type JSONCodecFuncs struct {
+	MarshalFunc   func(v any) ([]byte, error)
+	UnmarshalFunc func(data []byte, v any) error
+}
+
+// Marshal calls MarshalFunc.
+func (f JSONCodecFuncs) Marshal(v any) ([]byte, error) {
+	return f.MarshalFunc(v)
+}
+
+// Unmarshal calls UnmarshalFunc.
+func (f JSONCodecFuncs) Unmarshal(data []byte, v any) error {
+	return f.UnmarshalFunc(data, v)
+}
+
+// StdJSONCodec is the encoding/json codec used when no other is configured.
+var StdJSONCodec JSONCodec = JSONCodecFuncs{
+	MarshalFunc:   json.Marshal,
+	UnmarshalFunc: json.Unmarshal,
+}
+
+// DoJSON executes req with in encoded as its JSON body and decodes a 2xx
+// response body into out. A nil in sends no body and a nil out discards the
+// response body. Content-Type and Accept default to application/json.
+// Responses declaring a Content-Type other than JSON (application/json or
+// a +json type, in any case) are not decoded and fail.
+//
+// Decoded responses are returned with their body consumed and closed. Other
+// responses are returned with the body unread so the caller can inspect the
+// error payload; the caller must close it.
+func (c *Client) DoJSON(ctx context.Context, req *Request, in, out any) (*http.Response, error) {
+	c.mu.RLock()
+	codec := c.jsonCodec
+	c.mu.RUnlock()
+	if codec == nil {
+		codec = StdJSONCodec
+	}
+
+	headers := Headers{"Accept": "application/json"}
+	for k, v := range req.Headers {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+
+	jsonReq := *req
+	jsonReq.Headers = headers
+	if in != nil {
+		data, err := codec.Marshal(in)
+		if err != nil {
+			return nil, &RequestError{
+				Err:   fmt.Errorf("encode JSON body: %w", err),
+				Cause: "invalid_request",
+			}
+		}
+		if _, ok := headers["Content-Type"]; !ok {
+			headers["Content-Type"] = "application/json"
+		}
+		jsonReq.Body = bytes.NewReader(data)
+	}
+
+	resp, err := c.Do(ctx, &jsonReq)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	resp.Body = http.NoBody
+	if err != nil {
+		return resp, err
+	}
+	if out == nil || len(data) == 0 {
+		return resp, nil
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" && !isJSONMediaType(contentType) {
+		return resp, fmt.Errorf("httpx: decode JSON response: unexpected Content-Type %q", contentType)
+	}
+	if err := codec.Unmarshal(data, out); err != nil {
+		return resp, fmt.Errorf("httpx: decode JSON response: %w", err)
+	}
+	return resp, nil
+}
+
+// isJSONMediaType reports whether a Content-Type names JSON, e.g.
+// application/json, Application/JSON; charset=UTF-8 or application/problem+json
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// GetJSON executes a GET request and decodes a 2xx JSON response into out.
+// See DoJSON for how the response body is handled.
+func (c *Client) GetJSON(ctx context.Context, path string, out any, headers ...Headers) (*http.Response, error) {
+	h := Headers{}
+	if len(headers) > 0 {
+		h = headers[0]
+	}
+
+	return c.DoJSON(ctx, &Request{
+		Method:  http.MethodGet,
+		Path:    path,
+		Headers: h,
+	}, nil, out)
+}
+
+// PostJSON executes a POST request with in as the JSON body and decodes a
+// 2xx JSON response into out. See DoJSON for how the response body is handled.
+func (c *Client) PostJSON(ctx context.Context, path string, in, out any, headers ...Headers) (*http.Response, error) {
+	h := Headers{}
+	if len(headers) > 0 {
+		h = headers[0]
+	}
+
+	return c.DoJSON(ctx, &Request{
+		Method:  http.MethodPost,
+		Path:    path,
+		Headers: h,
+	}, in, out)
+}
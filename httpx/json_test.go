@@ -0,0 +1,283 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	gojson "github.com/goccy/go-json"
+	"github.com/seb7887/gofw/httpx"
+	"github.com/seb7887/gofw/httpx/httpxtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonUser struct {
+	ID    int64    `json:"id"`
+	Name  string   `json:"name"`
+	Email string   `json:"email"`
+	Tags  []string `json:"tags"`
+}
+
+func jsonResponse(status int, body string) func(context.Context, *http.Request) (*http.Response, error) {
+	return func(context.Context, *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Body:       io.NopCloser(bytes.NewBufferString(body)),
+		}, nil
+	}
+}
+
+// countingCodec wraps encoding/json and records how often it is used
+type countingCodec struct {
+	marshals, unmarshals int
+}
+
+func (c *countingCodec) Marshal(v any) ([]byte, error) {
+	c.marshals++
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v any) error {
+	c.unmarshals++
+	return json.Unmarshal(data, v)
+}
+
+func TestClient_PostJSON(t *testing.T) {
+	mockTransport := &httpxtest.MockTransport{
+		Func: jsonResponse(http.StatusCreated, `{"id":7,"name":"alice","email":"a@example.com","tags":["x"]}`),
+	}
+	client := httpx.NewClient(
+		httpx.WithTransport(mockTransport),
+		httpx.WithBaseURL("http://example.com"),
+	)
+
+	var out jsonUser
+	resp, err := client.PostJSON(context.Background(), "/users", jsonUser{Name: "alice"}, &out)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, jsonUser{ID: 7, Name: "alice", Email: "a@example.com", Tags: []string{"x"}}, out)
+
+	lastReq := mockTransport.LastRequest()
+	assert.Equal(t, http.MethodPost, lastReq.Method)
+	assert.Equal(t, "application/json", lastReq.Header.Get("Content-Type"))
+	assert.Equal(t, "application/json", lastReq.Header.Get("Accept"))
+	body, _ := io.ReadAll(lastReq.Body)
+	assert.JSONEq(t, `{"id":0,"name":"alice","email":"","tags":null}`, string(body))
+}
+
+func TestClient_GetJSON(t *testing.T) {
+	mockTransport := &httpxtest.MockTransport{
+		Func: jsonResponse(http.StatusOK, `{"id":1,"name":"bob"}`),
+	}
+	client := httpx.NewClient(httpx.WithTransport(mockTransport))
+
+	var out jsonUser
+	_, err := client.GetJSON(context.Background(), "/users/1", &out, httpx.Headers{"Accept": "application/vnd.api+json"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "bob", out.Name)
+
+	lastReq := mockTransport.LastRequest()
+	assert.Nil(t, lastReq.Body)
+	assert.Empty(t, lastReq.Header.Get("Content-Type"))
+	assert.Equal(t, "application/vnd.api+json", lastReq.Header.Get("Accept"), "caller headers take precedence")
+}
+
+func TestClient_DoJSON(t *testing.T) {
+	t.Run("Non-2xx responses are returned unread", func(t *testing.T) {
+		client := httpx.NewClient(httpx.WithTransport(&httpxtest.MockTransport{
+			Func: jsonResponse(http.StatusBadRequest, `{"error":"bad name"}`),
+		}))
+
+		var out jsonUser
+		resp, err := client.PostJSON(context.Background(), "/users", jsonUser{}, &out)
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, jsonUser{}, out)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, `{"error":"bad name"}`, string(body))
+	})
+
+	t.Run("Empty body and nil out", func(t *testing.T) {
+		client := httpx.NewClient(httpx.WithTransport(&httpxtest.MockTransport{
+			Func: jsonResponse(http.StatusNoContent, ""),
+		}))
+
+		var out jsonUser
+		_, err := client.DoJSON(context.Background(), &httpx.Request{Method: http.MethodDelete, Path: "/users/1"}, nil, &out)
+		require.NoError(t, err)
+
+		_, err = client.PostJSON(context.Background(), "/users", jsonUser{}, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("Encode errors fail before sending", func(t *testing.T) {
+		mockTransport := &httpxtest.MockTransport{Func: jsonResponse(http.StatusOK, "{}")}
+		client := httpx.NewClient(httpx.WithTransport(mockTransport))
+
+		_, err := client.PostJSON(context.Background(), "/users", map[string]any{"ch": make(chan int)}, nil)
+
+		var reqErr *httpx.RequestError
+		require.True(t, errors.As(err, &reqErr))
+		assert.Equal(t, "invalid_request", reqErr.Cause)
+		assert.Equal(t, 0, mockTransport.CallCount)
+	})
+
+	t.Run("Response Content-Type", func(t *testing.T) {
+		for contentType, decoded := range map[string]bool{
+			"application/json":                true,
+			"Application/JSON; charset=UTF-8": true,
+			"application/problem+json":        true,
+			"text/html":                       false,
+			"application/json;;":              false,
+		} {
+			client := httpx.NewClient(httpx.WithTransport(&httpxtest.MockTransport{
+				Func: func(context.Context, *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {contentType}},
+						Body:       io.NopCloser(bytes.NewBufferString(`{"id":1}`)),
+					}, nil
+				},
+			}))
+
+			var out jsonUser
+			_, err := client.GetJSON(context.Background(), "/users/1", &out)
+			if decoded {
+				assert.NoError(t, err, contentType)
+				assert.Equal(t, int64(1), out.ID, contentType)
+			} else {
+				assert.ErrorContains(t, err, "unexpected Content-Type", contentType)
+			}
+		}
+	})
+
+	t.Run("Request headers in any case", func(t *testing.T) {
+		mockTransport := &httpxtest.MockTransport{Func: jsonResponse(http.StatusOK, "{}")}
+		client := httpx.NewClient(httpx.WithTransport(mockTransport))
+
+		_, err := client.PostJSON(context.Background(), "/users", jsonUser{}, nil, httpx.Headers{"content-type": "application/vnd.api+json"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"application/vnd.api+json"}, mockTransport.LastRequest().Header.Values("Content-Type"))
+	})
+
+	t.Run("Decode errors", func(t *testing.T) {
+		client := httpx.NewClient(httpx.WithTransport(&httpxtest.MockTransport{
+			Func: jsonResponse(http.StatusOK, `{"id":"not a number"}`),
+		}))
+
+		var out jsonUser
+		resp, err := client.GetJSON(context.Background(), "/users/1", &out)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "decode JSON response")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestWithJSONCodec(t *testing.T) {
+	codec := &countingCodec{}
+	client := httpx.NewClient(
+		httpx.WithTransport(&httpxtest.MockTransport{Func: jsonResponse(http.StatusOK, `{"id":1}`)}),
+		httpx.WithJSONCodec(codec),
+	)
+
+	var out jsonUser
+	_, err := client.PostJSON(context.Background(), "/users", jsonUser{Name: "alice"}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, codec.marshals)
+	assert.Equal(t, 1, codec.unmarshals)
+
+	t.Run("JSONCodecFuncs adapts functions", func(t *testing.T) {
+		var calls int
+		client.Reload(httpx.WithJSONCodec(httpx.JSONCodecFuncs{
+			MarshalFunc: json.Marshal,
+			UnmarshalFunc: func(data []byte, v any) error {
+				calls++
+				return json.Unmarshal(data, v)
+			},
+		}))
+
+		_, err := client.GetJSON(context.Background(), "/users/1", &out)
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, 1, codec.unmarshals, "the codec is replaced on reload")
+	})
+}
+
+// pooledCodec is encoding/json with reused encode buffers
+type pooledCodec struct {
+	buffers sync.Pool
+}
+
+func (c *pooledCodec) Marshal(v any) ([]byte, error) {
+	buf, _ := c.buffers.Get().(*bytes.Buffer)
+	if buf == nil {
+		buf = new(bytes.Buffer)
+	}
+	defer c.buffers.Put(buf)
+	buf.Reset()
+
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+func (c *pooledCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+var benchCodecs = []struct {
+	name  string
+	codec httpx.JSONCodec
+}{
+	{"encoding/json", httpx.StdJSONCodec},
+	{"encoding/json pooled", &pooledCodec{}},
+	{"sonic", sonic.ConfigDefault},
+	{"sonic std", sonic.ConfigStd},
+	{"go-json", httpx.JSONCodecFuncs{MarshalFunc: gojson.Marshal, UnmarshalFunc: gojson.Unmarshal}},
+}
+
+// BenchmarkClient_PostJSON measures codecs through the full client path:
+// request encoding, the policy chain, the transport and response decoding.
+func BenchmarkClient_PostJSON(b *testing.B) {
+	users := make([]jsonUser, 50)
+	for i := range users {
+		users[i] = jsonUser{ID: int64(i), Name: "user", Email: "user@example.com", Tags: []string{"a", "b", "c"}}
+	}
+	payload, _ := json.Marshal(users)
+
+	for _, bc := range benchCodecs {
+		b.Run(bc.name, func(b *testing.B) {
+			client := httpx.NewClient(
+				httpx.WithTransport(&httpxtest.MockTransport{Func: func(context.Context, *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewReader(payload)),
+					}, nil
+				}}),
+				httpx.WithJSONCodec(bc.codec),
+			)
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.SetBytes(int64(2 * len(payload)))
+			for b.Loop() {
+				var out []jsonUser
+				if _, err := client.PostJSON(ctx, "/users", users, &out); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
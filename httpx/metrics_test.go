@@ -0,0 +1,87 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seb7887/gofw/httpx"
+	"github.com/seb7887/gofw/httpx/httpxtest"
+	"github.com/seb7887/gofw/httpx/observability"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func okTransport() *httpxtest.MockTransport {
+	return &httpxtest.MockTransport{
+		Func: func(context.Context, *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+		},
+	}
+}
+
+func TestWithMetricsConfig(t *testing.T) {
+	t.Run("Named clients share a registry", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		billing := httpx.NewClient(
+			httpx.WithTransport(okTransport()),
+			httpx.WithBaseURL("http://billing"),
+			httpx.WithMetricsConfig(registry, observability.MetricsConfig{ClientName: "billing", ConstLabels: prometheus.Labels{"team": "payments"}}),
+		)
+		search := httpx.NewClient(
+			httpx.WithTransport(okTransport()),
+			httpx.WithBaseURL("http://search"),
+			httpx.WithMetricsConfig(registry, observability.MetricsConfig{ClientName: "search", ConstLabels: prometheus.Labels{"team": "discovery"}}),
+		)
+
+		_, err := billing.Get(context.Background(), "/invoices")
+		require.NoError(t, err)
+		_, err = search.Get(context.Background(), "/q")
+		require.NoError(t, err)
+		_, err = search.Get(context.Background(), "/q")
+		require.NoError(t, err)
+
+		httpxtest.AssertMetricValueWithLabels(t, registry, "http_client_request_duration_seconds",
+			map[string]string{"client": "billing", "team": "payments"}, 1)
+		httpxtest.AssertMetricValueWithLabels(t, registry, "http_client_request_duration_seconds",
+			map[string]string{"client": "search", "team": "discovery"}, 2)
+	})
+
+	t.Run("Namespace prefixes metric names", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		client := httpx.NewClient(
+			httpx.WithTransport(okTransport()),
+			httpx.WithMetricsConfig(registry, observability.MetricsConfig{Namespace: "payments"}),
+		)
+
+		_, err := client.Get(context.Background(), "/")
+		require.NoError(t, err)
+
+		gather := func(name string) error {
+			_, err := httpxtest.GetMetricValue(registry, name, nil)
+			return err
+		}
+		assert.NoError(t, gather("payments_http_client_request_duration_seconds"))
+		assert.Error(t, gather("http_client_request_duration_seconds"))
+	})
+
+	t.Run("Identical configs share metrics instead of panicking", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		first := httpx.NewClient(httpx.WithTransport(okTransport()), httpx.WithMetrics(registry))
+
+		var second *httpx.Client
+		require.NotPanics(t, func() {
+			second = httpx.NewClient(httpx.WithTransport(okTransport()), httpx.WithMetrics(registry))
+		})
+
+		_, err := first.Get(context.Background(), "/")
+		require.NoError(t, err)
+		_, err = second.Get(context.Background(), "/")
+		require.NoError(t, err)
+
+		httpxtest.AssertMetricValueWithLabels(t, registry, "http_client_request_duration_seconds", nil, 2)
+	})
+}
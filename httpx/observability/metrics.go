@@ -1,38 +1,79 @@
 package observability
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 // MetricsCollector provides Prometheus metrics collection for HTTP requests.
 type MetricsCollector struct {
-	requestDuration      *prometheus.HistogramVec
-	circuitBreakerState  *prometheus.GaugeVec
-	circuitBreakerFails  *prometheus.CounterVec
-	retryAttempts        *prometheus.CounterVec
-	activeRequests       *prometheus.GaugeVec
-	bulkheadRejections   *prometheus.CounterVec
+	requestDuration       *prometheus.HistogramVec
+	circuitBreakerState   *prometheus.GaugeVec
+	circuitBreakerFails   *prometheus.CounterVec
+	retryAttempts         *prometheus.CounterVec
+	activeRequests        *prometheus.GaugeVec
+	bulkheadRejections    *prometheus.CounterVec
+	bulkheadInUse         *prometheus.GaugeVec
+	configVersion         *prometheus.GaugeVec
+	responseLimitExceeded *prometheus.CounterVec
+	rawRequests           *prometheus.CounterVec
+}
+
+// MetricsConfig distinguishes the metrics of clients that share a registry.
+type MetricsConfig struct {
+	// Namespace prefixes every metric name, e.g. "billing" exposes
+	// billing_http_client_request_duration_seconds
+	Namespace string
+
+	// ClientName is added to every metric as the constant label client="<name>"
+	ClientName string
+
+	// ConstLabels are added to every metric
+	ConstLabels prometheus.Labels
+}
+
+// constLabels merges ClientName into ConstLabels
+func (c MetricsConfig) constLabels() prometheus.Labels {
+	if c.ClientName == "" {
+		return c.ConstLabels
+	}
+	labels := prometheus.Labels{"client": c.ClientName}
+	for k, v := range c.ConstLabels {
+		labels[k] = v
+	}
+	return labels
 }
 
 // NewMetricsCollector creates a new Prometheus metrics collector.
 // If registry is nil, uses the default Prometheus registry.
 func NewMetricsCollector(registry prometheus.Registerer) *MetricsCollector {
+	return NewMetricsCollectorWithConfig(registry, MetricsConfig{})
+}
+
+// NewMetricsCollectorWithConfig creates a Prometheus metrics collector whose
+// metrics are prefixed and labeled according to cfg.
+// If registry is nil, uses the default Prometheus registry.
+//
+// Collectors created with the same registry and config share their metrics
+// instead of failing to register them twice.
+func NewMetricsCollectorWithConfig(registry prometheus.Registerer, cfg MetricsConfig) *MetricsCollector {
 	if registry == nil {
 		registry = prometheus.DefaultRegisterer
 	}
 
-	factory := promauto.With(registry)
+	namespace, labels := cfg.Namespace, cfg.constLabels()
 
 	return &MetricsCollector{
-		requestDuration: factory.NewHistogramVec(
+		requestDuration: register(registry, prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
-				Name: "http_client_request_duration_seconds",
-				Help: "HTTP client request duration in seconds",
+				Namespace:   namespace,
+				Name:        "http_client_request_duration_seconds",
+				ConstLabels: labels,
+				Help:        "HTTP client request duration in seconds",
 				Buckets: []float64{
 					0.001, // 1ms
 					0.005, // 5ms
@@ -46,57 +87,129 @@ func NewMetricsCollector(registry prometheus.Registerer) *MetricsCollector {
 					10.0,  // 10s
 				},
 			},
-			[]string{"method", "status_code", "host"},
-		),
+			[]string{"method", "status_code", "host", "route"},
+		)),
 
-		circuitBreakerState: factory.NewGaugeVec(
+		circuitBreakerState: register(registry, prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "http_client_circuit_breaker_state",
-				Help: "Circuit breaker state (0=closed, 1=open, 2=half-open)",
+				Namespace:   namespace,
+				Name:        "http_client_circuit_breaker_state",
+				ConstLabels: labels,
+				Help:        "Circuit breaker state (0=closed, 1=open, 2=half-open)",
 			},
 			[]string{"host"},
-		),
+		)),
 
-		circuitBreakerFails: factory.NewCounterVec(
+		circuitBreakerFails: register(registry, prometheus.NewCounterVec(
 			prometheus.CounterOpts{
-				Name: "http_client_circuit_breaker_failures_total",
-				Help: "Total number of circuit breaker failures",
+				Namespace:   namespace,
+				Name:        "http_client_circuit_breaker_failures_total",
+				ConstLabels: labels,
+				Help:        "Total number of circuit breaker failures",
 			},
 			[]string{"host"},
-		),
+		)),
 
-		retryAttempts: factory.NewCounterVec(
+		retryAttempts: register(registry, prometheus.NewCounterVec(
 			prometheus.CounterOpts{
-				Name: "http_client_retries_total",
-				Help: "Total number of retry attempts",
+				Namespace:   namespace,
+				Name:        "http_client_retries_total",
+				ConstLabels: labels,
+				Help:        "Total number of retry attempts",
 			},
 			[]string{"method", "host", "reason"},
-		),
+		)),
 
-		activeRequests: factory.NewGaugeVec(
+		activeRequests: register(registry, prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "http_client_active_requests",
-				Help: "Number of active HTTP requests",
+				Namespace:   namespace,
+				Name:        "http_client_active_requests",
+				ConstLabels: labels,
+				Help:        "Number of active HTTP requests",
 			},
 			[]string{"host"},
-		),
+		)),
+
+		bulkheadRejections: register(registry, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   namespace,
+				Name:        "http_client_rejected_requests_total",
+				ConstLabels: labels,
+				Help:        "Total number of requests rejected by bulkhead",
+			},
+			[]string{"host", "pool"},
+		)),
+
+		bulkheadInUse: register(registry, prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "http_client_bulkhead_in_use",
+				ConstLabels: labels,
+				Help:        "Number of bulkhead slots currently held",
+			},
+			[]string{"host", "pool"},
+		)),
+
+		configVersion: register(registry, prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "http_client_config_version",
+				ConstLabels: labels,
+				Help:        "Client configuration version currently in effect (always 1)",
+			},
+			[]string{"version"},
+		)),
 
-		bulkheadRejections: factory.NewCounterVec(
+		responseLimitExceeded: register(registry, prometheus.NewCounterVec(
 			prometheus.CounterOpts{
-				Name: "http_client_rejected_requests_total",
-				Help: "Total number of requests rejected by bulkhead",
+				Namespace:   namespace,
+				Name:        "http_client_response_limit_exceeded_total",
+				ConstLabels: labels,
+				Help:        "Total number of responses that exceeded their body size budget",
 			},
 			[]string{"host"},
-		),
+		)),
+
+		rawRequests: register(registry, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   namespace,
+				Name:        "http_client_raw_requests_total",
+				ConstLabels: labels,
+				Help:        "Total number of requests sent in raw mode, bypassing all policies",
+			},
+			[]string{"host"},
+		)),
+	}
+}
+
+// register registers c, or returns the collector already registered under
+// the same descriptors.
+func register[C prometheus.Collector](registry prometheus.Registerer, c C) C {
+	if err := registry.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
 	}
+	return c
 }
 
 // RecordRequestDuration records the duration of an HTTP request.
 func (m *MetricsCollector) RecordRequestDuration(method, host string, statusCode int, duration time.Duration) {
+	m.RecordRouteRequestDuration(method, host, "", statusCode, duration)
+}
+
+// RecordRouteRequestDuration records the duration of an HTTP request to a
+// templated route (e.g. "/users/{id}"). An empty route leaves the label blank.
+func (m *MetricsCollector) RecordRouteRequestDuration(method, host, route string, statusCode int, duration time.Duration) {
 	m.requestDuration.WithLabelValues(
 		method,
 		strconv.Itoa(statusCode),
 		host,
+		route,
 	).Observe(duration.Seconds())
 }
 
@@ -127,9 +240,39 @@ func (m *MetricsCollector) DecrementActiveRequests(host string) {
 	m.activeRequests.WithLabelValues(host).Dec()
 }
 
-// IncrementBulkheadRejections increments the bulkhead rejection counter.
+// IncrementBulkheadRejections increments the bulkhead rejection counter of
+// the unary pool.
+//
+// Deprecated: use IncrementPoolBulkheadRejections, which labels the pool.
 func (m *MetricsCollector) IncrementBulkheadRejections(host string) {
-	m.bulkheadRejections.WithLabelValues(host).Inc()
+	m.IncrementPoolBulkheadRejections(host, "unary")
+}
+
+// IncrementPoolBulkheadRejections increments the bulkhead rejection counter.
+// pool: "unary" or "streaming"
+func (m *MetricsCollector) IncrementPoolBulkheadRejections(host, pool string) {
+	m.bulkheadRejections.WithLabelValues(host, pool).Inc()
+}
+
+// SetBulkheadInUse sets the number of bulkhead slots held in a pool.
+func (m *MetricsCollector) SetBulkheadInUse(host, pool string, inUse int) {
+	m.bulkheadInUse.WithLabelValues(host, pool).Set(float64(inUse))
+}
+
+// IncrementResponseLimitExceeded increments the oversized response counter.
+func (m *MetricsCollector) IncrementResponseLimitExceeded(host string) {
+	m.responseLimitExceeded.WithLabelValues(host).Inc()
+}
+
+// IncrementRawRequests increments the raw mode request counter.
+func (m *MetricsCollector) IncrementRawRequests(host string) {
+	m.rawRequests.WithLabelValues(host).Inc()
+}
+
+// SetConfigVersion marks version as the configuration in effect, replacing any previous version.
+func (m *MetricsCollector) SetConfigVersion(version string) {
+	m.configVersion.Reset()
+	m.configVersion.WithLabelValues(version).Set(1)
 }
 
 // NormalizeHost normalizes a host string for use in metrics.
@@ -19,8 +19,9 @@ const (
 // OTELInstrumenter provides OpenTelemetry instrumentation for HTTP requests.
 // It creates spans, injects trace context into headers, and records request metadata.
 type OTELInstrumenter struct {
-	tracer     trace.Tracer
-	propagator propagation.TextMapPropagator
+	tracer         trace.Tracer
+	propagator     propagation.TextMapPropagator
+	routeTemplater RouteTemplater
 }
 
 // NewOTELInstrumenter creates a new OTEL instrumenter with the given tracer provider.
@@ -39,11 +40,18 @@ func NewOTELInstrumenter(provider trace.TracerProvider) *OTELInstrumenter {
 // StartSpan creates a new span for an HTTP request and returns the updated context.
 // The span includes standard HTTP semantic conventions attributes.
 func (o *OTELInstrumenter) StartSpan(ctx context.Context, req *http.Request) (context.Context, trace.Span) {
-	// Create span with HTTP method as operation name
+	// Create span with HTTP method (and route, when templated) as operation name
 	spanName := fmt.Sprintf("HTTP %s", req.Method)
+	route := o.routeTemplater.Route(req)
+	if route != "" {
+		spanName += " " + route
+	}
 	ctx, span := o.tracer.Start(ctx, spanName,
 		trace.WithSpanKind(trace.SpanKindClient),
 	)
+	if route != "" {
+		span.SetAttributes(attribute.String("http.route", route))
+	}
 
 	// Add HTTP semantic convention attributes
 	span.SetAttributes(
@@ -64,6 +72,11 @@ func (o *OTELInstrumenter) StartSpan(ctx context.Context, req *http.Request) (co
 	return ctx, span
 }
 
+// SetRouteTemplater sets the templater used to name spans after logical routes.
+func (o *OTELInstrumenter) SetRouteTemplater(templater RouteTemplater) {
+	o.routeTemplater = templater
+}
+
 // EndSpan completes the span with response information.
 // Records status code, errors, and marks the span as error if applicable.
 func (o *OTELInstrumenter) EndSpan(span trace.Span, resp *http.Response, err error) {
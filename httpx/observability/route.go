@@ -0,0 +1,15 @@
+package observability
+
+import "net/http"
+
+// RouteTemplater maps a request to its logical route, e.g. "/users/123" to
+// "/users/{id}", so span names and metric labels have bounded cardinality.
+type RouteTemplater func(req *http.Request) string
+
+// Route returns the templated route of req, or "" if the templater is nil.
+func (t RouteTemplater) Route(req *http.Request) string {
+	if t == nil {
+		return ""
+	}
+	return t(req)
+}
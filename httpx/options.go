@@ -1,6 +1,7 @@
 package httpx
 
 import (
+	"log/slog"
 	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -9,7 +10,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
-// ClientOption configures a Client during creation.
+// ClientOption configures a Client during creation (or Reload).
 type ClientOption interface {
 	apply(*Client)
 }
@@ -54,6 +55,27 @@ func WithTransport(transport Transport) ClientOption {
 	}
 }
 
+// WithDialer replaces the transport with a default transport whose connections
+// are established according to config. Use it to tune Happy Eyeballs and the
+// IP family preference for dual-stack destinations. It replaces transports set
+// by earlier WithHTTPClient/WithTransport options, and is replaced by later ones.
+//
+// Example:
+//
+//	client := httpx.NewClient(
+//	    httpx.WithDialer(httpx.DialerConfig{
+//	        AddressFamily: httpx.PreferIPv4,
+//	        FallbackDelay: 50 * time.Millisecond,
+//	    }),
+//	)
+func WithDialer(config DialerConfig) ClientOption {
+	return &funcClientOption{
+		f: func(c *Client) {
+			c.transport = NewDefaultTransportWithDialer(config)
+		},
+	}
+}
+
 // WithBaseURL sets the base URL that will be prepended to all request paths.
 // The path from each request will be joined with this base URL.
 //
@@ -71,6 +93,43 @@ func WithBaseURL(baseURL string) ClientOption {
 	}
 }
 
+// WithAPIVersion adds an API version to every request, so call sites don't
+// hardcode version segments. By default the version is inserted as a path
+// segment after the base URL; use WithVersionStrategy to send it in a header or
+// query parameter instead. Override it per request with WithRequestAPIVersion.
+//
+// Example:
+//
+//	client := httpx.NewClient(
+//	    httpx.WithBaseURL("http://service-b:8080"),
+//	    httpx.WithAPIVersion("v2"),
+//	)
+//	// client.Get(ctx, "/users") will request http://service-b:8080/v2/users
+func WithAPIVersion(version string) ClientOption {
+	return &funcClientOption{
+		f: func(c *Client) {
+			c.apiVersion = version
+		},
+	}
+}
+
+// WithVersionStrategy sets how the API version is added to requests.
+// Default: VersionInPath
+//
+// Example:
+//
+//	client := httpx.NewClient(
+//	    httpx.WithAPIVersion("2024-06-01"),
+//	    httpx.WithVersionStrategy(httpx.VersionInHeader("X-API-Version")),
+//	)
+func WithVersionStrategy(strategy VersionStrategy) ClientOption {
+	return &funcClientOption{
+		f: func(c *Client) {
+			c.versionStrategy = strategy
+		},
+	}
+}
+
 // WithPolicy adds a custom policy to the client's policy chain.
 // Policies are applied in the order they are added.
 func WithPolicy(p policy.Policy) ClientOption {
@@ -189,10 +248,141 @@ func WithOTEL(provider trace.TracerProvider) ClientOption {
 //	    httpx.WithCircuitBreaker(...),
 //	)
 func WithMetrics(registry prometheus.Registerer) ClientOption {
+	return WithMetricsConfig(registry, observability.MetricsConfig{})
+}
+
+// WithMetricsConfig enables Prometheus metrics collection with a metric
+// namespace and constant labels, so several clients can report to the same
+// registry and dashboards can tell them apart. Clients sharing a registry and
+// namespace must use the same label names (a ClientName and the same
+// ConstLabels keys): Prometheus rejects metrics of one name with different
+// label names.
+//
+// Example:
+//
+//	billing := httpx.NewClient(
+//	    httpx.WithMetricsConfig(prometheus.DefaultRegisterer, observability.MetricsConfig{ClientName: "billing"}),
+//	)
+//	search := httpx.NewClient(
+//	    httpx.WithMetricsConfig(prometheus.DefaultRegisterer, observability.MetricsConfig{ClientName: "search"}),
+//	)
+func WithMetricsConfig(registry prometheus.Registerer, cfg observability.MetricsConfig) ClientOption {
+	return &funcClientOption{
+		f: func(c *Client) {
+			c.policies = append(c.policies, policy.NewMetricsPolicy(c.metricsCollector(registry, cfg)))
+		},
+	}
+}
+
+// WithRouteTemplater sets how requests are mapped to logical routes for span
+// names (the http.route attribute) and the route label of
+// http_client_request_duration_seconds, so /users/123/orders/456 and
+// /users/7/orders/8 are reported as one route. The templater must return a
+// bounded set of values; TemplateIDSegments covers the common case.
+// It applies to WithOTEL and WithMetrics regardless of option order.
+//
+// Example:
+//
+//	client := httpx.NewClient(
+//	    httpx.WithOTEL(provider),
+//	    httpx.WithMetrics(registry),
+//	    httpx.WithRouteTemplater(httpx.TemplateIDSegments),
+//	)
+func WithRouteTemplater(templater func(*http.Request) string) ClientOption {
+	return &funcClientOption{
+		f: func(c *Client) {
+			c.routeTemplater = templater
+		},
+	}
+}
+
+// WithJSONCodec sets the codec DoJSON, GetJSON and PostJSON use to encode
+// request bodies and decode responses. Use it to swap encoding/json for a
+// faster implementation on hot paths.
+//
+// Example:
+//
+//	client := httpx.NewClient(
+//	    httpx.WithBaseURL("http://service-b:8080"),
+//	    httpx.WithJSONCodec(sonic.ConfigStd),
+//	)
+func WithJSONCodec(codec JSONCodec) ClientOption {
+	return &funcClientOption{
+		f: func(c *Client) {
+			c.jsonCodec = codec
+		},
+	}
+}
+
+// WithMaxResponseBytes limits the size of every response body.
+// Responses over the limit fail with ErrResponseTooLarge and count as circuit
+// breaker failures. Per-host budgets set with WithResponseBodyLimitPerHost take
+// precedence over this global limit.
+//
+// Example:
+//
+//	client := httpx.NewClient(
+//	    httpx.WithCircuitBreaker(...),
+//	    httpx.WithMaxResponseBytes(10 << 20), // 10MB
+//	)
+func WithMaxResponseBytes(maxBytes int64) ClientOption {
+	return &funcClientOption{
+		f: func(c *Client) {
+			c.responseLimitPolicy().SetMaxBytes(maxBytes)
+		},
+	}
+}
+
+// WithResponseBodyLimitPerHost sets response body budgets for specific hosts.
+// A violation fails the request with ErrResponseTooLarge, counts as a circuit
+// breaker failure for that host and increments the
+// http_client_response_limit_exceeded_total metric.
+//
+// Limits are checked next to the transport, so responses rejected by their
+// Content-Length reach the breaker as errors whatever the order of the
+// options. Bodies without a Content-Length are checked while being read and
+// reported to the breaker directly.
+//
+// Example:
+//
+//	client := httpx.NewClient(
+//	    httpx.WithCircuitBreaker(...),
+//	    httpx.WithResponseBodyLimitPerHost(map[string]int64{
+//	        "reports.internal:8080": 50 << 20,
+//	        "flaky.example.com":     1 << 20,
+//	    }),
+//	)
+func WithResponseBodyLimitPerHost(limits map[string]int64) ClientOption {
+	return &funcClientOption{
+		f: func(c *Client) {
+			p := c.responseLimitPolicy()
+			for host, limit := range limits {
+				p.SetHostLimit(host, limit)
+			}
+		},
+	}
+}
+
+// WithConfigVersion labels the client configuration with a version (e.g. a
+// config push ID or git SHA). The version is included in Snapshot, in the
+// log record written by Reload and, when metrics are enabled, in the
+// http_client_config_version metric.
+func WithConfigVersion(version string) ClientOption {
+	return &funcClientOption{
+		f: func(c *Client) {
+			c.version = version
+		},
+	}
+}
+
+// WithLogger sets the logger used for configuration change records.
+// Default: slog.Default()
+func WithLogger(logger *slog.Logger) ClientOption {
 	return &funcClientOption{
 		f: func(c *Client) {
-			collector := observability.NewMetricsCollector(registry)
-			c.policies = append(c.policies, policy.NewMetricsPolicy(collector))
+			if logger != nil {
+				c.logger = logger
+			}
 		},
 	}
 }
@@ -3,10 +3,35 @@ package policy
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"sync"
+
+	"github.com/seb7887/gofw/httpx/observability"
+)
+
+// Bulkhead pool names, used as the "pool" metric label.
+const (
+	PoolUnary     = "unary"
+	PoolStreaming = "streaming"
 )
 
+// streamingKey is the context key marking a request as streaming.
+type streamingKey struct{}
+
+// WithStreaming marks requests made with ctx as long-lived streams (SSE,
+// chunked downloads, watch endpoints) so the bulkhead accounts them in the
+// streaming pool instead of the unary pool.
+func WithStreaming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamingKey{}, true)
+}
+
+// IsStreaming reports whether ctx was marked with WithStreaming.
+func IsStreaming(ctx context.Context) bool {
+	streaming, _ := ctx.Value(streamingKey{}).(bool)
+	return streaming
+}
+
 // BulkheadConfig configures the bulkhead (concurrency limiting) behavior.
 type BulkheadConfig struct {
 	// MaxConcurrent is the maximum number of concurrent requests allowed.
@@ -17,6 +42,13 @@ type BulkheadConfig struct {
 	// When false, applies globally across all hosts.
 	// Default: true (per-host isolation)
 	PerHost bool
+
+	// MaxConcurrentStreams is the size of a separate pool for requests marked
+	// as streaming (see WithStreaming). Streaming requests hold their slot
+	// until the response body is closed, so they cannot starve unary requests.
+	// If 0, streaming requests share the unary pool and release their slot
+	// when headers are received, as unary requests do.
+	MaxConcurrentStreams int
 }
 
 // bulkhead represents a single semaphore for concurrency control.
@@ -28,10 +60,13 @@ type bulkhead struct {
 // BulkheadPolicy implements concurrency limiting to prevent resource exhaustion.
 // It uses a semaphore pattern (buffered channel) to limit concurrent requests.
 type BulkheadPolicy struct {
-	mu         sync.RWMutex
-	bulkheads  map[string]*bulkhead // host -> bulkhead (if PerHost=true)
-	global     *bulkhead            // global bulkhead (if PerHost=false)
-	config     BulkheadConfig
+	mu        sync.RWMutex
+	bulkheads map[string]*bulkhead // host -> bulkhead (if PerHost=true)
+	global    *bulkhead            // global bulkhead (if PerHost=false)
+	streams   map[string]*bulkhead // host -> streaming bulkhead (if PerHost=true)
+	stream    *bulkhead            // global streaming bulkhead (if PerHost=false)
+	config    BulkheadConfig
+	collector *observability.MetricsCollector
 }
 
 // NewBulkheadPolicy creates a new bulkhead policy with the given configuration.
@@ -47,45 +82,92 @@ func NewBulkheadPolicy(config BulkheadConfig) *BulkheadPolicy {
 
 	if config.PerHost {
 		bp.bulkheads = make(map[string]*bulkhead)
+		bp.streams = make(map[string]*bulkhead)
 	} else {
-		// Create global bulkhead
+		// Create global bulkheads
 		bp.global = newBulkhead(config.MaxConcurrent)
+		if config.MaxConcurrentStreams > 0 {
+			bp.stream = newBulkhead(config.MaxConcurrentStreams)
+		}
 	}
 
 	return bp
 }
 
+// Config returns the effective configuration, including defaults.
+func (bp *BulkheadPolicy) Config() BulkheadConfig {
+	return bp.config
+}
+
+// AttachMetrics records pool usage and rejections in the given collector.
+func (bp *BulkheadPolicy) AttachMetrics(collector *observability.MetricsCollector) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.collector = collector
+}
+
 // Execute implements the Policy interface by limiting concurrency.
 func (bp *BulkheadPolicy) Execute(ctx context.Context, req *http.Request, next Executor) (*http.Response, error) {
+	if OverridesFromContext(ctx).DisableBulkhead {
+		return next(ctx, req)
+	}
+
+	host := req.URL.Host
+	streaming := IsStreaming(ctx) && bp.config.MaxConcurrentStreams > 0
+
 	// Get the appropriate bulkhead
+	pool := PoolUnary
 	var b *bulkhead
-	if bp.config.PerHost {
-		b = bp.getBulkheadForHost(req.URL.Host)
-	} else {
+	switch {
+	case streaming && bp.config.PerHost:
+		pool = PoolStreaming
+		b = bp.getBulkhead(bp.streams, host, bp.config.MaxConcurrentStreams)
+	case streaming:
+		pool = PoolStreaming
+		b = bp.stream
+	case bp.config.PerHost:
+		b = bp.getBulkheadForHost(host)
+	default:
 		b = bp.global
 	}
 
 	// Try to acquire semaphore (non-blocking)
 	select {
 	case b.semaphore <- struct{}{}:
-		// Acquired - release when done
-		defer func() {
-			<-b.semaphore
-		}()
-
-		// Execute request
-		return next(ctx, req)
-
+		bp.recordInUse(host, pool, b)
 	default:
 		// Semaphore full - fail fast
+		bp.recordRejection(host, pool)
 		return nil, errors.New("bulkhead capacity exceeded")
 	}
+
+	release := func() {
+		<-b.semaphore
+		bp.recordInUse(host, pool, b)
+	}
+
+	resp, err := next(ctx, req)
+
+	// Streams keep their slot until the caller is done with the body
+	if streaming && err == nil && resp != nil && resp.Body != nil {
+		resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+		return resp, nil
+	}
+
+	release()
+	return resp, err
 }
 
 // getBulkheadForHost returns the bulkhead for a given host, creating one if needed.
 func (bp *BulkheadPolicy) getBulkheadForHost(host string) *bulkhead {
+	return bp.getBulkhead(bp.bulkheads, host, bp.config.MaxConcurrent)
+}
+
+// getBulkhead returns the bulkhead for a host from pools, creating one with
+// the given capacity if needed.
+func (bp *BulkheadPolicy) getBulkhead(pools map[string]*bulkhead, host string, maxConcurrent int) *bulkhead {
 	bp.mu.RLock()
-	b, exists := bp.bulkheads[host]
+	b, exists := pools[host]
 	bp.mu.RUnlock()
 
 	if exists {
@@ -97,12 +179,12 @@ func (bp *BulkheadPolicy) getBulkheadForHost(host string) *bulkhead {
 	defer bp.mu.Unlock()
 
 	// Double-check after acquiring write lock
-	if b, exists := bp.bulkheads[host]; exists {
+	if b, exists := pools[host]; exists {
 		return b
 	}
 
-	b = newBulkhead(bp.config.MaxConcurrent)
-	bp.bulkheads[host] = b
+	b = newBulkhead(maxConcurrent)
+	pools[host] = b
 
 	return b
 }
@@ -137,3 +219,61 @@ func (bp *BulkheadPolicy) ActiveRequests(host string) int {
 
 	return 0
 }
+
+// ActiveStreams returns the number of streaming requests currently holding a
+// slot for a given host (or globally, if not using per-host isolation).
+func (bp *BulkheadPolicy) ActiveStreams(host string) int {
+	if bp.config.PerHost {
+		bp.mu.RLock()
+		b, exists := bp.streams[host]
+		bp.mu.RUnlock()
+
+		if !exists {
+			return 0
+		}
+
+		return len(b.semaphore)
+	}
+
+	if bp.stream != nil {
+		return len(bp.stream.semaphore)
+	}
+
+	return 0
+}
+
+// recordInUse publishes the current pool usage.
+func (bp *BulkheadPolicy) recordInUse(host, pool string, b *bulkhead) {
+	bp.mu.RLock()
+	collector := bp.collector
+	bp.mu.RUnlock()
+
+	if collector != nil {
+		collector.SetBulkheadInUse(observability.NormalizeHost(host), pool, len(b.semaphore))
+	}
+}
+
+// recordRejection counts a request rejected because its pool was full.
+func (bp *BulkheadPolicy) recordRejection(host, pool string) {
+	bp.mu.RLock()
+	collector := bp.collector
+	bp.mu.RUnlock()
+
+	if collector != nil {
+		collector.IncrementPoolBulkheadRejections(observability.NormalizeHost(host), pool)
+	}
+}
+
+// releasingBody releases a bulkhead slot once the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close implements io.Closer.
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,119 @@
+package policy_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/seb7887/gofw/httpx/observability"
+	"github.com/seb7887/gofw/httpx/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func okExecutor(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("event: ping\n\n")),
+	}, nil
+}
+
+func TestBulkheadPolicy_StreamsUseSeparatePool(t *testing.T) {
+	bp := policy.NewBulkheadPolicy(policy.BulkheadConfig{
+		MaxConcurrent:        1,
+		MaxConcurrentStreams: 2,
+		PerHost:              true,
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://events.example.com/sse", nil)
+	streamCtx := policy.WithStreaming(context.Background())
+
+	// Two open streams fill the streaming pool
+	s1, err := bp.Execute(streamCtx, req, okExecutor)
+	require.NoError(t, err)
+	s2, err := bp.Execute(streamCtx, req, okExecutor)
+	require.NoError(t, err)
+	assert.Equal(t, 2, bp.ActiveStreams("events.example.com"))
+
+	_, err = bp.Execute(streamCtx, req, okExecutor)
+	assert.EqualError(t, err, "bulkhead capacity exceeded")
+
+	// Unary requests are unaffected by open streams
+	resp, err := bp.Execute(context.Background(), req, okExecutor)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 0, bp.ActiveRequests("events.example.com"))
+
+	// Closing a stream body frees its slot (closing twice releases once)
+	s1.Body.Close()
+	s1.Body.Close()
+	assert.Equal(t, 1, bp.ActiveStreams("events.example.com"))
+
+	_, err = bp.Execute(streamCtx, req, okExecutor)
+	require.NoError(t, err)
+	s2.Body.Close()
+}
+
+func TestBulkheadPolicy_StreamingWithoutPoolSharesUnary(t *testing.T) {
+	bp := policy.NewBulkheadPolicy(policy.BulkheadConfig{MaxConcurrent: 1})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://events.example.com/sse", nil)
+	resp, err := bp.Execute(policy.WithStreaming(context.Background()), req, okExecutor)
+	require.NoError(t, err)
+
+	// Slot released on return, as for unary requests
+	assert.Equal(t, 0, bp.ActiveRequests(""))
+	assert.Equal(t, 0, bp.ActiveStreams(""))
+	resp.Body.Close()
+}
+
+func TestBulkheadPolicy_FailedStreamReleasesSlot(t *testing.T) {
+	bp := policy.NewBulkheadPolicy(policy.BulkheadConfig{MaxConcurrentStreams: 1})
+
+	failing := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://events.example.com/sse", nil)
+	ctx := policy.WithStreaming(context.Background())
+
+	_, err := bp.Execute(ctx, req, failing)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, 0, bp.ActiveStreams(""))
+}
+
+func TestBulkheadPolicy_Metrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	bp := policy.NewBulkheadPolicy(policy.BulkheadConfig{MaxConcurrent: 1, MaxConcurrentStreams: 1, PerHost: true})
+	bp.AttachMetrics(observability.NewMetricsCollector(registry))
+
+	req, _ := http.NewRequest(http.MethodGet, "http://events.example.com/sse", nil)
+	ctx := policy.WithStreaming(context.Background())
+
+	stream, err := bp.Execute(ctx, req, okExecutor)
+	require.NoError(t, err)
+	_, err = bp.Execute(ctx, req, okExecutor)
+	require.Error(t, err)
+
+	expected := `
+# HELP http_client_bulkhead_in_use Number of bulkhead slots currently held
+# TYPE http_client_bulkhead_in_use gauge
+http_client_bulkhead_in_use{host="events.example.com",pool="streaming"} 1
+# HELP http_client_rejected_requests_total Total number of requests rejected by bulkhead
+# TYPE http_client_rejected_requests_total counter
+http_client_rejected_requests_total{host="events.example.com",pool="streaming"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"http_client_bulkhead_in_use", "http_client_rejected_requests_total"))
+
+	stream.Body.Close()
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP http_client_bulkhead_in_use Number of bulkhead slots currently held
+# TYPE http_client_bulkhead_in_use gauge
+http_client_bulkhead_in_use{host="events.example.com",pool="streaming"} 0
+`), "http_client_bulkhead_in_use"))
+}
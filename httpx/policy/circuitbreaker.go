@@ -3,6 +3,7 @@ package policy
 import (
 	"context"
 	"errors"
+	"math"
 	"net/http"
 	"sync"
 	"time"
@@ -59,8 +60,29 @@ type CircuitBreakerConfig struct {
 	// ShouldTrip is a custom function to determine if an error should count toward opening the circuit.
 	// If nil, all errors and 5xx status codes count as failures.
 	ShouldTrip func(*http.Response, error) bool
+
+	// RampUp when set, gradually restores traffic after the circuit closes
+	// instead of letting full traffic through at once.
+	// Default: nil (no ramp-up)
+	RampUp *RampUpConfig
+}
+
+// RampUpConfig configures traffic shaping after a circuit recovers.
+// The window is split evenly between the steps; during each step only the
+// given fraction of requests is admitted and the rest fail fast with ErrRampUpShed.
+type RampUpConfig struct {
+	// Window is the total duration of the ramp-up.
+	// Default: 30 seconds
+	Window time.Duration
+
+	// Steps are the admitted fractions (0-1] for each slice of the window, in order.
+	// Default: 0.1, 0.5, 1.0
+	Steps []float64
 }
 
+// ErrRampUpShed is returned for requests shed while a recovered circuit ramps up.
+var ErrRampUpShed = errors.New("circuit breaker ramp-up: request shed")
+
 // circuitBreaker maintains the state for a single circuit.
 type circuitBreaker struct {
 	mu sync.RWMutex
@@ -71,6 +93,13 @@ type circuitBreaker struct {
 	requests         int
 	lastStateChange  time.Time
 	config           CircuitBreakerConfig
+
+	// ramp-up tracking, active while rampStart is non-zero; the counters
+	// cover the current step only
+	rampStart    time.Time
+	rampStep     int
+	rampTotal    int
+	rampAdmitted int
 }
 
 // CircuitBreakerPolicy implements the circuit breaker pattern to prevent cascading failures.
@@ -96,6 +125,16 @@ func NewCircuitBreakerPolicy(config CircuitBreakerConfig) *CircuitBreakerPolicy
 	if config.SuccessThreshold == 0 {
 		config.SuccessThreshold = 2
 	}
+	if config.RampUp != nil {
+		rampUp := *config.RampUp
+		if rampUp.Window == 0 {
+			rampUp.Window = 30 * time.Second
+		}
+		if len(rampUp.Steps) == 0 {
+			rampUp.Steps = []float64{0.1, 0.5, 1.0}
+		}
+		config.RampUp = &rampUp
+	}
 
 	return &CircuitBreakerPolicy{
 		breakers: make(map[string]*circuitBreaker),
@@ -103,8 +142,24 @@ func NewCircuitBreakerPolicy(config CircuitBreakerConfig) *CircuitBreakerPolicy
 	}
 }
 
+// Config returns the effective configuration, including defaults.
+func (cb *CircuitBreakerPolicy) Config() CircuitBreakerConfig {
+	config := cb.config
+	if config.RampUp != nil {
+		rampUp := *config.RampUp
+		rampUp.Steps = append([]float64(nil), rampUp.Steps...)
+		config.RampUp = &rampUp
+	}
+	return config
+}
+
 // Execute implements the Policy interface by checking circuit breaker state.
 func (cb *CircuitBreakerPolicy) Execute(ctx context.Context, req *http.Request, next Executor) (*http.Response, error) {
+	// Bypassed requests are neither rejected nor recorded
+	if OverridesFromContext(ctx).DisableCircuitBreaker {
+		return next(ctx, req)
+	}
+
 	// Get or create circuit breaker for this host
 	breaker := cb.getBreakerForHost(req.URL.Host)
 
@@ -113,6 +168,11 @@ func (cb *CircuitBreakerPolicy) Execute(ctx context.Context, req *http.Request,
 		return nil, errors.New("circuit breaker is open")
 	}
 
+	// Shed part of the traffic while a recovered circuit ramps up
+	if !breaker.admitRampUp() {
+		return nil, ErrRampUpShed
+	}
+
 	// Execute request
 	resp, err := next(ctx, req)
 
@@ -206,6 +266,76 @@ func (b *circuitBreaker) canExecute() bool {
 	}
 }
 
+// rampFraction returns the currently admitted fraction of traffic.
+// Callers must hold b.mu.
+func (b *circuitBreaker) rampFraction(now time.Time) float64 {
+	step := b.rampStepAt(now)
+	if step < 0 {
+		return 1
+	}
+	return b.config.RampUp.Steps[step]
+}
+
+// rampStepAt returns the index of the ramp-up step at now, or -1 when the
+// circuit is not ramping up. Callers must hold b.mu.
+func (b *circuitBreaker) rampStepAt(now time.Time) int {
+	if b.rampStart.IsZero() || b.state != StateClosed {
+		return -1
+	}
+
+	elapsed := now.Sub(b.rampStart)
+	if elapsed >= b.config.RampUp.Window {
+		// Ramp-up finished
+		b.rampStart = time.Time{}
+		return -1
+	}
+
+	steps := b.config.RampUp.Steps
+	stepLen := b.config.RampUp.Window / time.Duration(len(steps))
+	idx := int(elapsed / stepLen)
+	if idx >= len(steps) {
+		idx = len(steps) - 1
+	}
+	return idx
+}
+
+// admitRampUp decides whether a request is admitted during ramp-up.
+// Admission is spread evenly: after n requests in a step, ceil(fraction*n)
+// are admitted. The counters restart with every step, so that the requests
+// shed by an earlier step do not inflate the admissions of the next one.
+func (b *circuitBreaker) admitRampUp() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	step := b.rampStepAt(time.Now())
+	if step < 0 {
+		return true
+	}
+	if step != b.rampStep {
+		b.startRampStep(step)
+	}
+
+	fraction := b.config.RampUp.Steps[step]
+	if fraction >= 1 {
+		return true
+	}
+
+	b.rampTotal++
+	if float64(b.rampAdmitted) < math.Ceil(fraction*float64(b.rampTotal)) {
+		b.rampAdmitted++
+		return true
+	}
+	return false
+}
+
+// startRampStep resets the admission counters for a ramp-up step.
+// Callers must hold b.mu.
+func (b *circuitBreaker) startRampStep(step int) {
+	b.rampStep = step
+	b.rampTotal = 0
+	b.rampAdmitted = 0
+}
+
 // recordResult records the result of a request and updates circuit state.
 func (b *circuitBreaker) recordResult(isFailure bool) {
 	b.mu.Lock()
@@ -222,6 +352,18 @@ func (b *circuitBreaker) recordResult(isFailure bool) {
 	}
 }
 
+// recordLateFailure reclassifies an already recorded success as a failure.
+func (b *circuitBreaker) recordLateFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.successes > 0 {
+		b.successes--
+	}
+	b.handleFailure()
+}
+
 // handleFailure handles a failed request based on current state.
 func (b *circuitBreaker) handleFailure() {
 	switch b.state {
@@ -258,10 +400,39 @@ func (b *circuitBreaker) handleSuccess() {
 			b.failures = 0
 			b.requests = 0
 			b.lastStateChange = time.Now()
+
+			// Start ramping traffic back up
+			if b.config.RampUp != nil {
+				b.rampStart = b.lastStateChange
+				b.startRampStep(0)
+			}
 		}
 	}
 }
 
+// RecordFailure counts a failure for a host that was detected after Execute
+// returned, e.g. a response body that turned out to be invalid while being read.
+// The request is reclassified from success to failure rather than counted twice.
+func (cb *CircuitBreakerPolicy) RecordFailure(host string) {
+	cb.getBreakerForHost(host).recordLateFailure()
+}
+
+// AllowedFraction returns the fraction of traffic currently admitted for a host.
+// It is 1 unless the host's circuit recently closed and is ramping up.
+func (cb *CircuitBreakerPolicy) AllowedFraction(host string) float64 {
+	cb.mu.RLock()
+	breaker, exists := cb.breakers[host]
+	cb.mu.RUnlock()
+
+	if !exists {
+		return 1
+	}
+
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	return breaker.rampFraction(time.Now())
+}
+
 // State returns the current state of the circuit breaker for a given host.
 // This is useful for metrics and monitoring.
 func (cb *CircuitBreakerPolicy) State(host string) CircuitState {
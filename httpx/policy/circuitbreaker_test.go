@@ -0,0 +1,147 @@
+package policy_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/httpx/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerPolicy_RampUpAfterRecovery(t *testing.T) {
+	breaker := policy.NewCircuitBreakerPolicy(policy.CircuitBreakerConfig{
+		ErrorThreshold:   50,
+		MinRequests:      1,
+		SleepWindow:      10 * time.Millisecond,
+		SuccessThreshold: 1,
+		RampUp: &policy.RampUpConfig{
+			Window: 200 * time.Millisecond,
+			Steps:  []float64{0.25, 1.0},
+		},
+	})
+
+	failing := true
+	executor := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if failing {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}
+	host := "fragile.example.com"
+	call := func() error {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+host, nil)
+		_, err := breaker.Execute(context.Background(), req, executor)
+		return err
+	}
+
+	// Trip the circuit, then recover through half-open
+	require.Error(t, call())
+	assert.Equal(t, policy.StateOpen, breaker.State(host))
+
+	time.Sleep(20 * time.Millisecond)
+	failing = false
+	require.NoError(t, call())
+	assert.Equal(t, policy.StateClosed, breaker.State(host))
+	assert.Equal(t, 0.25, breaker.AllowedFraction(host))
+
+	// First step: a quarter of the requests are admitted, the rest are shed
+	admitted, shed := 0, 0
+	for i := 0; i < 8; i++ {
+		err := call()
+		switch {
+		case err == nil:
+			admitted++
+		case errors.Is(err, policy.ErrRampUpShed):
+			shed++
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	assert.Equal(t, 2, admitted)
+	assert.Equal(t, 6, shed)
+	assert.Equal(t, policy.StateClosed, breaker.State(host), "shed requests must not count as failures")
+
+	// After the window, full traffic is restored
+	time.Sleep(220 * time.Millisecond)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, call())
+	}
+	assert.Equal(t, 1.0, breaker.AllowedFraction(host))
+}
+
+func TestCircuitBreakerPolicy_NoRampUpByDefault(t *testing.T) {
+	breaker := policy.NewCircuitBreakerPolicy(policy.CircuitBreakerConfig{
+		MinRequests:      1,
+		SleepWindow:      10 * time.Millisecond,
+		SuccessThreshold: 1,
+	})
+
+	failing := true
+	executor := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if failing {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://service.example.com", nil)
+
+	_, _ = breaker.Execute(context.Background(), req, executor)
+	time.Sleep(20 * time.Millisecond)
+	failing = false
+
+	for i := 0; i < 5; i++ {
+		_, err := breaker.Execute(context.Background(), req, executor)
+		require.NoError(t, err)
+	}
+}
+
+func TestCircuitBreakerPolicy_RampUpCountsEachStep(t *testing.T) {
+	breaker := policy.NewCircuitBreakerPolicy(policy.CircuitBreakerConfig{
+		ErrorThreshold:   50,
+		MinRequests:      1,
+		SleepWindow:      10 * time.Millisecond,
+		SuccessThreshold: 1,
+		RampUp: &policy.RampUpConfig{
+			Window: 400 * time.Millisecond,
+			Steps:  []float64{0.1, 0.5},
+		},
+	})
+
+	failing := true
+	executor := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if failing {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}
+	host := "fragile.example.com"
+	call := func() error {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+host, nil)
+		_, err := breaker.Execute(context.Background(), req, executor)
+		return err
+	}
+
+	require.Error(t, call())
+	time.Sleep(20 * time.Millisecond)
+	failing = false
+	require.NoError(t, call())
+
+	// Shed most of the first step
+	for i := 0; i < 10; i++ {
+		_ = call()
+	}
+
+	// The second step starts from fresh counters: the requests shed by the
+	// first one must not let a burst through
+	time.Sleep(220 * time.Millisecond)
+	require.Equal(t, 0.5, breaker.AllowedFraction(host))
+	var admitted []bool
+	for i := 0; i < 4; i++ {
+		admitted = append(admitted, call() == nil)
+	}
+	assert.Equal(t, []bool{true, false, true, false}, admitted)
+}
@@ -36,6 +36,11 @@ func (i *InstrumentationPolicy) Execute(ctx context.Context, req *http.Request,
 	return resp, err
 }
 
+// SetRouteTemplater sets the templater used to name spans after logical routes.
+func (i *InstrumentationPolicy) SetRouteTemplater(templater observability.RouteTemplater) {
+	i.instrumenter.SetRouteTemplater(templater)
+}
+
 // Instrumenter returns the underlying OTEL instrumenter.
 // This allows policies to add custom attributes and events to the current span.
 func (i *InstrumentationPolicy) Instrumenter() *observability.OTELInstrumenter {
@@ -12,7 +12,8 @@ import (
 // It tracks request duration, active requests, and integrates with other policies
 // to record circuit breaker states, retries, and bulkhead rejections.
 type MetricsPolicy struct {
-	collector *observability.MetricsCollector
+	collector      *observability.MetricsCollector
+	routeTemplater observability.RouteTemplater
 }
 
 // NewMetricsPolicy creates a new metrics policy with the given collector.
@@ -40,16 +41,22 @@ func (m *MetricsPolicy) Execute(ctx context.Context, req *http.Request, next Exe
 	duration := time.Since(startTime)
 
 	// Record metrics
+	route := m.routeTemplater.Route(req)
 	if resp != nil {
-		m.collector.RecordRequestDuration(req.Method, host, resp.StatusCode, duration)
+		m.collector.RecordRouteRequestDuration(req.Method, host, route, resp.StatusCode, duration)
 	} else if err != nil {
 		// Record as 0 status code for errors
-		m.collector.RecordRequestDuration(req.Method, host, 0, duration)
+		m.collector.RecordRouteRequestDuration(req.Method, host, route, 0, duration)
 	}
 
 	return resp, err
 }
 
+// SetRouteTemplater sets the templater used for the route label of request metrics.
+func (m *MetricsPolicy) SetRouteTemplater(templater observability.RouteTemplater) {
+	m.routeTemplater = templater
+}
+
 // Collector returns the underlying metrics collector.
 // This allows other policies to record their specific metrics.
 func (m *MetricsPolicy) Collector() *observability.MetricsCollector {
@@ -0,0 +1,39 @@
+package policy
+
+import (
+	"context"
+	"time"
+)
+
+// Overrides change how the policies treat a single request. httpx sets them
+// from the request options of Client.Do.
+type Overrides struct {
+	// Timeout replaces the timeout policy's timeout; the deadline is set on
+	// the context by the caller
+	Timeout *time.Duration
+
+	// Retryable enables retries of non-idempotent methods, or disables retries
+	Retryable *bool
+
+	// DisableRetry, DisableCircuitBreaker, DisableTimeout and DisableBulkhead
+	// make their policy pass the request through
+	DisableRetry          bool
+	DisableCircuitBreaker bool
+	DisableTimeout        bool
+	DisableBulkhead       bool
+}
+
+// overridesKey is the context key of the request overrides.
+type overridesKey struct{}
+
+// WithOverrides attaches overrides to requests made with ctx.
+func WithOverrides(ctx context.Context, overrides Overrides) context.Context {
+	return context.WithValue(ctx, overridesKey{}, overrides)
+}
+
+// OverridesFromContext returns the overrides attached with WithOverrides,
+// or none.
+func OverridesFromContext(ctx context.Context) Overrides {
+	overrides, _ := ctx.Value(overridesKey{}).(Overrides)
+	return overrides
+}
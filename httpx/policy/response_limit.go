@@ -0,0 +1,200 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/seb7887/gofw/httpx/observability"
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds its size budget.
+var ErrResponseTooLarge = errors.New("response body exceeds size limit")
+
+// ResponseLimitConfig configures response body size budgets.
+type ResponseLimitConfig struct {
+	// MaxBytes is the budget applied to hosts without a PerHost entry.
+	// If 0, responses from those hosts are not limited.
+	MaxBytes int64
+
+	// PerHost overrides MaxBytes for specific hosts (matched against req.URL.Host).
+	PerHost map[string]int64
+}
+
+// failureRecorder is implemented by policies that can be told about failures
+// detected after they returned (e.g. while the caller reads the body).
+type failureRecorder interface {
+	RecordFailure(host string)
+}
+
+// ResponseLimitPolicy enforces response body size budgets.
+// Responses whose Content-Length exceeds the budget fail immediately with
+// ErrResponseTooLarge. Bodies without a Content-Length are wrapped so that
+// reads past the budget fail with ErrResponseTooLarge.
+//
+// Every violation is counted as a circuit breaker failure (when a circuit
+// breaker is attached) and recorded in the response limit metric (when a
+// metrics collector is attached). The client attaches both automatically.
+type ResponseLimitPolicy struct {
+	mu        sync.RWMutex
+	config    ResponseLimitConfig
+	breakers  []failureRecorder
+	collector *observability.MetricsCollector
+}
+
+// NewResponseLimitPolicy creates a new response limit policy with the given configuration.
+func NewResponseLimitPolicy(config ResponseLimitConfig) *ResponseLimitPolicy {
+	perHost := make(map[string]int64, len(config.PerHost))
+	for host, limit := range config.PerHost {
+		perHost[host] = limit
+	}
+	config.PerHost = perHost
+
+	return &ResponseLimitPolicy{
+		config: config,
+	}
+}
+
+// Config returns the current configuration.
+func (p *ResponseLimitPolicy) Config() ResponseLimitConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	config := p.config
+	config.PerHost = make(map[string]int64, len(p.config.PerHost))
+	for host, limit := range p.config.PerHost {
+		config.PerHost[host] = limit
+	}
+	return config
+}
+
+// SetMaxBytes sets the default budget for hosts without a per-host entry.
+func (p *ResponseLimitPolicy) SetMaxBytes(maxBytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config.MaxBytes = maxBytes
+}
+
+// SetHostLimit sets the budget for a specific host.
+func (p *ResponseLimitPolicy) SetHostLimit(host string, maxBytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config.PerHost[host] = maxBytes
+}
+
+// AttachCircuitBreaker makes budget violations count as failures of the given breaker.
+func (p *ResponseLimitPolicy) AttachCircuitBreaker(cb *CircuitBreakerPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.breakers = append(p.breakers, cb)
+}
+
+// AttachMetrics records budget violations in the given collector.
+func (p *ResponseLimitPolicy) AttachMetrics(collector *observability.MetricsCollector) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.collector = collector
+}
+
+// Limit returns the budget for a host, or 0 if the host is not limited.
+func (p *ResponseLimitPolicy) Limit(host string) int64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if limit, ok := p.config.PerHost[host]; ok {
+		return limit
+	}
+	return p.config.MaxBytes
+}
+
+// Execute implements the Policy interface by enforcing the host's response budget.
+func (p *ResponseLimitPolicy) Execute(ctx context.Context, req *http.Request, next Executor) (*http.Response, error) {
+	resp, err := next(ctx, req)
+	if err != nil || resp == nil || resp.Body == nil {
+		return resp, err
+	}
+
+	host := req.URL.Host
+	limit := p.Limit(host)
+	if limit <= 0 {
+		return resp, nil
+	}
+
+	// Declared size over budget - fail without reading the body
+	if resp.ContentLength > limit {
+		resp.Body.Close()
+		p.recordViolation(host, false)
+		return nil, ErrResponseTooLarge
+	}
+
+	// Requests bypassing the breakers are not reported to them late either
+	late := !OverridesFromContext(ctx).DisableCircuitBreaker
+	resp.Body = &limitedBody{
+		body:  resp.Body,
+		limit: limit,
+		onExceeded: func() {
+			p.recordViolation(host, late)
+		},
+	}
+
+	return resp, nil
+}
+
+// recordViolation updates metrics and, for violations detected after the
+// breaker already saw a successful response (late), reports the failure to it.
+// Eager violations are returned as errors and counted by the breaker itself.
+func (p *ResponseLimitPolicy) recordViolation(host string, late bool) {
+	p.mu.RLock()
+	breakers := p.breakers
+	collector := p.collector
+	p.mu.RUnlock()
+
+	if collector != nil {
+		collector.IncrementResponseLimitExceeded(observability.NormalizeHost(host))
+	}
+
+	if late {
+		for _, b := range breakers {
+			b.RecordFailure(host)
+		}
+	}
+}
+
+// limitedBody fails reads once more than limit bytes have been read.
+type limitedBody struct {
+	body       io.ReadCloser
+	limit      int64
+	read       int64
+	exceeded   bool
+	onExceeded func()
+}
+
+// Read implements io.Reader.
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, ErrResponseTooLarge
+	}
+
+	// Read at most one byte past the budget to detect overflow
+	if remaining := b.limit - b.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := b.body.Read(p)
+	b.read += int64(n)
+
+	if b.read > b.limit {
+		b.exceeded = true
+		b.onExceeded()
+		return n - int(b.read-b.limit), ErrResponseTooLarge
+	}
+
+	return n, err
+}
+
+// Close implements io.Closer.
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,118 @@
+package policy_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/httpx/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func bodyResponse(body string, contentLength int64) *http.Response {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: contentLength,
+	}
+}
+
+func TestResponseLimitPolicy_ContentLengthOverBudget(t *testing.T) {
+	limitPolicy := policy.NewResponseLimitPolicy(policy.ResponseLimitConfig{
+		PerHost: map[string]int64{"big.example.com": 10},
+	})
+
+	executor := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return bodyResponse(strings.Repeat("x", 20), 20), nil
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://big.example.com/report", nil)
+	resp, err := limitPolicy.Execute(context.Background(), req, executor)
+
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, err, policy.ErrResponseTooLarge)
+}
+
+func TestResponseLimitPolicy_StreamedBodyOverBudget(t *testing.T) {
+	limitPolicy := policy.NewResponseLimitPolicy(policy.ResponseLimitConfig{MaxBytes: 8})
+
+	executor := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return bodyResponse("0123456789abcdef", -1), nil
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://stream.example.com", nil)
+	resp, err := limitPolicy.Execute(context.Background(), req, executor)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(resp.Body)
+	assert.ErrorIs(t, err, policy.ErrResponseTooLarge)
+	assert.Equal(t, "01234567", string(data), "should return exactly the budgeted bytes")
+}
+
+func TestResponseLimitPolicy_WithinBudget(t *testing.T) {
+	limitPolicy := policy.NewResponseLimitPolicy(policy.ResponseLimitConfig{
+		MaxBytes: 4,
+		PerHost:  map[string]int64{"api.example.com": 16},
+	})
+
+	executor := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return bodyResponse("0123456789abcdef", -1), nil
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://api.example.com", nil)
+	resp, err := limitPolicy.Execute(context.Background(), req, executor)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", string(data))
+	assert.Equal(t, int64(16), limitPolicy.Limit("api.example.com"))
+	assert.Equal(t, int64(4), limitPolicy.Limit("other.example.com"))
+}
+
+func TestResponseLimitPolicy_ViolationsTripBreaker(t *testing.T) {
+	breaker := policy.NewCircuitBreakerPolicy(policy.CircuitBreakerConfig{
+		ErrorThreshold: 50,
+		MinRequests:    2,
+		SleepWindow:    time.Minute,
+	})
+	limitPolicy := policy.NewResponseLimitPolicy(policy.ResponseLimitConfig{MaxBytes: 4})
+	limitPolicy.AttachCircuitBreaker(breaker)
+
+	transport := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return bodyResponse("way too large", -1), nil
+	}
+	executor := policy.Chain([]policy.Policy{breaker, limitPolicy}, transport)
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://flaky.example.com", nil)
+		resp, err := executor(context.Background(), req)
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, resp.Body)
+		assert.True(t, errors.Is(err, policy.ErrResponseTooLarge))
+	}
+
+	assert.Equal(t, policy.StateOpen, breaker.State("flaky.example.com"))
+}
+
+func TestRetryPolicy_DoesNotRetryOversizedResponses(t *testing.T) {
+	retryPolicy := policy.NewRetryPolicy(policy.RetryConfig{MaxAttempts: 3})
+
+	attempts := 0
+	executor := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		attempts++
+		return nil, policy.ErrResponseTooLarge
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", bytes.NewReader(nil))
+	_, err := retryPolicy.Execute(context.Background(), req, executor)
+
+	assert.ErrorIs(t, err, policy.ErrResponseTooLarge)
+	assert.Equal(t, 1, attempts)
+}
@@ -60,13 +60,26 @@ func NewRetryPolicy(config RetryConfig) *RetryPolicy {
 	}
 }
 
+// Config returns the effective configuration, including defaults.
+func (r *RetryPolicy) Config() RetryConfig {
+	config := r.config
+	config.RetryableStatusCodes = append([]int(nil), r.config.RetryableStatusCodes...)
+	return config
+}
+
 // Execute implements the Policy interface by retrying failed requests.
 func (r *RetryPolicy) Execute(ctx context.Context, req *http.Request, next Executor) (*http.Response, error) {
 	var lastResp *http.Response
 	var lastErr error
 
+	// Per-request overrides win over the method
+	overrides := OverridesFromContext(ctx)
+	if overrides.DisableRetry || (overrides.Retryable != nil && !*overrides.Retryable) {
+		return next(ctx, req)
+	}
+
 	// Check if method is idempotent
-	if r.config.OnlyIdempotent && !isIdempotent(req.Method) {
+	if r.config.OnlyIdempotent && !isIdempotent(req.Method) && overrides.Retryable == nil {
 		// Non-idempotent method - execute once without retry
 		return next(ctx, req)
 	}
@@ -132,6 +145,11 @@ func (r *RetryPolicy) shouldRetry(resp *http.Response, err error) bool {
 		return r.config.ShouldRetry(resp, err)
 	}
 
+	// Oversized responses are deterministic - retrying only repeats the transfer
+	if errors.Is(err, ErrResponseTooLarge) {
+		return false
+	}
+
 	// Network error - always retry
 	if err != nil {
 		return true
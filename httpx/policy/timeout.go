@@ -37,10 +37,24 @@ func NewTimeoutPolicy(config TimeoutConfig) *TimeoutPolicy {
 	}
 }
 
+// Config returns the effective configuration, including defaults.
+func (t *TimeoutPolicy) Config() TimeoutConfig {
+	return t.config
+}
+
 // Execute implements the Policy interface by applying timeout to the request.
+// Requests with a timeout override keep the deadline of their context.
 func (t *TimeoutPolicy) Execute(ctx context.Context, req *http.Request, next Executor) (*http.Response, error) {
+	overrides := OverridesFromContext(ctx)
+	if overrides.DisableTimeout {
+		return next(ctx, req)
+	}
+
 	// Create context with timeout
-	timeoutCtx, cancel := context.WithTimeout(ctx, t.config.Request)
+	timeoutCtx, cancel := ctx, context.CancelFunc(func() {})
+	if overrides.Timeout == nil {
+		timeoutCtx, cancel = context.WithTimeout(ctx, t.config.Request)
+	}
 	defer cancel()
 
 	// Execute request with timeout context
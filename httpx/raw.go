@@ -0,0 +1,53 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/seb7887/gofw/httpx/observability"
+	"github.com/seb7887/gofw/httpx/policy"
+)
+
+// rawModeKey is the context key marking requests that bypass every policy.
+type rawModeKey struct{}
+
+// WithRawMode marks requests made with ctx as raw: they skip the whole policy
+// chain (retries, circuit breaker, timeout, bulkhead, tracing, metrics) and go
+// straight to the transport. It is meant for incident debugging, to tell
+// whether a failure comes from the remote service or from the policies.
+// Raw requests are counted in http_client_raw_requests_total when the client
+// has metrics enabled, so forgotten raw mode shows up on dashboards.
+//
+// Example:
+//
+//	resp, err := client.Get(httpx.WithRawMode(ctx), "/users/42")
+func WithRawMode(ctx context.Context) context.Context {
+	return context.WithValue(ctx, rawModeKey{}, true)
+}
+
+// IsRawMode reports whether ctx was marked with WithRawMode.
+func IsRawMode(ctx context.Context) bool {
+	raw, _ := ctx.Value(rawModeKey{}).(bool)
+	return raw
+}
+
+// rawExecutor returns an executor that calls the transport directly,
+// counting requests in the client's metrics if it has any.
+// Must be called with the lock held.
+func (c *Client) rawExecutor() policy.Executor {
+	transport := c.transport
+
+	var metrics *observability.MetricsCollector
+	for _, p := range c.policies {
+		if m, ok := p.(*policy.MetricsPolicy); ok {
+			metrics = m.Collector()
+		}
+	}
+
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if metrics != nil {
+			metrics.IncrementRawRequests(observability.NormalizeHost(req.URL.Host))
+		}
+		return transport.Do(ctx, req)
+	}
+}
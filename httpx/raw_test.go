@@ -0,0 +1,67 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seb7887/gofw/httpx"
+	"github.com/seb7887/gofw/httpx/backoff"
+	"github.com/seb7887/gofw/httpx/httpxtest"
+	"github.com/seb7887/gofw/httpx/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRawMode(t *testing.T) {
+	newClient := func(registry *prometheus.Registry) (*httpx.Client, *httpxtest.MockTransport) {
+		transport := &httpxtest.MockTransport{
+			Func: func(context.Context, *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+			},
+		}
+		client := httpx.NewClient(
+			httpx.WithTransport(transport),
+			httpx.WithBaseURL("http://example.com"),
+			httpx.WithRetry(policy.RetryConfig{MaxAttempts: 3, Backoff: backoff.NewConstantBackoff(time.Millisecond)}),
+			httpx.WithMetrics(registry),
+		)
+		return client, transport
+	}
+
+	t.Run("Bypasses the policy chain", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		client, transport := newClient(registry)
+
+		resp, err := client.Get(httpx.WithRawMode(context.Background()), "/users")
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, 1, transport.CallCount, "raw requests must not be retried")
+		httpxtest.AssertMetricValueWithLabels(t, registry, "http_client_raw_requests_total",
+			map[string]string{"host": "example.com"}, 1)
+
+		_, err = httpxtest.GetMetricValue(registry, "http_client_request_duration_seconds", nil)
+		assert.Error(t, err, "raw requests must not be recorded by the metrics policy")
+	})
+
+	t.Run("Normal requests still use the policies", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		client, transport := newClient(registry)
+
+		_, _ = client.Get(context.Background(), "/users")
+
+		assert.Equal(t, 3, transport.CallCount)
+		_, err := httpxtest.GetMetricValue(registry, "http_client_raw_requests_total", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("IsRawMode", func(t *testing.T) {
+		assert.True(t, httpx.IsRawMode(httpx.WithRawMode(context.Background())))
+		assert.False(t, httpx.IsRawMode(context.Background()))
+	})
+}
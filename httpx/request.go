@@ -4,6 +4,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/seb7887/gofw/httpx/policy"
 )
 
 // Headers is a convenience type for HTTP headers.
@@ -52,6 +54,12 @@ type requestConfig struct {
 
 	// DisableBulkhead disables bulkhead policy for this request
 	disableBulkhead bool
+
+	// Streaming marks the request as a long-lived stream for bulkhead accounting
+	streaming bool
+
+	// APIVersion overrides the client's API version; empty sends no version
+	apiVersion *string
 }
 
 // funcOption wraps a function to implement RequestOption
@@ -124,6 +132,42 @@ func WithoutBulkhead() RequestOption {
 	}
 }
 
+// WithStreaming marks the request as a long-lived stream (SSE, watch endpoints,
+// large downloads). When the bulkhead has a streaming pool configured
+// (BulkheadConfig.MaxConcurrentStreams), the request is counted against that
+// pool and holds its slot until the response body is closed.
+func WithStreaming() RequestOption {
+	return &funcOption{
+		f: func(cfg *requestConfig) {
+			cfg.streaming = true
+		},
+	}
+}
+
+// WithRequestAPIVersion overrides the client's API version (see WithAPIVersion)
+// for this request, using the client's version strategy. An empty version
+// sends the request unversioned.
+func WithRequestAPIVersion(version string) RequestOption {
+	return &funcOption{
+		f: func(cfg *requestConfig) {
+			cfg.apiVersion = &version
+		},
+	}
+}
+
+// overrides returns the policy overrides of the config, if it has any.
+func (cfg *requestConfig) overrides() (policy.Overrides, bool) {
+	overrides := policy.Overrides{
+		Timeout:               cfg.timeout,
+		Retryable:             cfg.retryable,
+		DisableRetry:          cfg.disableRetry,
+		DisableCircuitBreaker: cfg.disableCircuitBreaker,
+		DisableTimeout:        cfg.disableTimeout,
+		DisableBulkhead:       cfg.disableBulkhead,
+	}
+	return overrides, overrides != policy.Overrides{}
+}
+
 // applyOptions applies all request options to the config.
 func applyOptions(opts []RequestOption) *requestConfig {
 	cfg := &requestConfig{}
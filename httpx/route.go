@@ -0,0 +1,46 @@
+package httpx
+
+import (
+	"net/http"
+	"strings"
+)
+
+// TemplateIDSegments is a route templater that replaces path segments that
+// look like identifiers (numbers, UUIDs and long hex strings) with "{id}":
+// "/users/123/orders/9f1c0e2a-..." becomes "/users/{id}/orders/{id}".
+// Use it with WithRouteTemplater.
+func TemplateIDSegments(req *http.Request) string {
+	segments := strings.Split(req.URL.Path, "/")
+	for i, seg := range segments {
+		if isIDSegment(seg) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// isIDSegment reports whether a path segment is a number, a UUID or a hex
+// string of at least 16 characters (e.g. an object ID or hash).
+func isIDSegment(seg string) bool {
+	if seg == "" {
+		return false
+	}
+
+	digits, hex := true, true
+	for _, r := range seg {
+		isDigit := r >= '0' && r <= '9'
+		isHex := isDigit || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+		digits = digits && isDigit
+		hex = hex && (isHex || r == '-')
+	}
+	if digits {
+		return true
+	}
+	if !hex {
+		return false
+	}
+	if len(seg) == 36 && strings.Count(seg, "-") == 4 {
+		return true
+	}
+	return !strings.Contains(seg, "-") && len(seg) >= 16
+}
@@ -0,0 +1,114 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seb7887/gofw/httpx"
+	"github.com/seb7887/gofw/httpx/httpxtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// spanRecorder is a tracer provider that records the names of started spans
+type spanRecorder struct {
+	embedded.TracerProvider
+
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *spanRecorder) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return &recordingTracer{recorder: r}
+}
+
+type recordingTracer struct {
+	embedded.Tracer
+	recorder *spanRecorder
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	t.recorder.mu.Lock()
+	t.recorder.names = append(t.recorder.names, name)
+	t.recorder.mu.Unlock()
+	return noop.NewTracerProvider().Tracer("").Start(ctx, name, opts...)
+}
+
+func TestTemplateIDSegments(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/users/123/orders/456", "/users/{id}/orders/{id}"},
+		{"/users/9f1c0e2a-4b7d-4c1e-8a9b-0d2f3e4a5b6c", "/users/{id}"},
+		{"/objects/507f1f77bcf86cd799439011/raw", "/objects/{id}/raw"},
+		{"/v2/users/me", "/v2/users/me"},
+		{"/files/cafe", "/files/cafe"},
+		{"/", "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+			assert.Equal(t, tt.expected, httpx.TemplateIDSegments(req))
+		})
+	}
+}
+
+func TestWithRouteTemplater(t *testing.T) {
+	mockTransport := &httpxtest.MockTransport{
+		Func: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString("ok"))}, nil
+		},
+	}
+	registry := prometheus.NewRegistry()
+	recorder := &spanRecorder{}
+
+	// The templater is applied regardless of its position among the options
+	client := httpx.NewClient(
+		httpx.WithTransport(mockTransport),
+		httpx.WithBaseURL("http://example.com"),
+		httpx.WithRouteTemplater(httpx.TemplateIDSegments),
+		httpx.WithOTEL(recorder),
+		httpx.WithMetrics(registry),
+	)
+
+	ctx := context.Background()
+	for _, path := range []string{"/users/1/orders/2", "/users/3/orders/4"} {
+		resp, err := client.Get(ctx, path)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	httpxtest.AssertMetricValueWithLabels(t, registry, "http_client_request_duration_seconds",
+		map[string]string{"route": "/users/{id}/orders/{id}", "method": "GET", "status_code": "200"}, 2)
+	assert.Equal(t, []string{"HTTP GET /users/{id}/orders/{id}", "HTTP GET /users/{id}/orders/{id}"}, recorder.names)
+
+	t.Run("Without templater", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		recorder := &spanRecorder{}
+		client := httpx.NewClient(
+			httpx.WithTransport(mockTransport),
+			httpx.WithBaseURL("http://example.com"),
+			httpx.WithOTEL(recorder),
+			httpx.WithMetrics(registry),
+		)
+
+		resp, err := client.Get(ctx, "/users/1")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		httpxtest.AssertMetricValueWithLabels(t, registry, "http_client_request_duration_seconds",
+			map[string]string{"route": ""}, 1)
+		assert.Equal(t, []string{"HTTP GET"}, recorder.names)
+	})
+}
@@ -0,0 +1,79 @@
+package httpx
+
+import (
+	"net/http"
+	"strings"
+)
+
+// versionLocation identifies where a VersionStrategy puts the API version.
+type versionLocation int
+
+const (
+	versionInPath versionLocation = iota
+	versionInHeader
+	versionInQuery
+)
+
+// VersionStrategy controls how the API version set with WithAPIVersion (or
+// WithRequestAPIVersion) is added to requests. The zero value is VersionInPath.
+type VersionStrategy struct {
+	location versionLocation
+	name     string
+}
+
+// VersionInPath inserts the version as a path segment between the base URL
+// and the request path: base "http://svc/api", version "v2" and path "/users"
+// request "http://svc/api/v2/users".
+var VersionInPath = VersionStrategy{location: versionInPath}
+
+// VersionInHeader sends the version in the named header (e.g. "X-API-Version").
+// A header already set on the request takes precedence.
+func VersionInHeader(name string) VersionStrategy {
+	return VersionStrategy{location: versionInHeader, name: name}
+}
+
+// VersionInQuery sends the version as the named query parameter (e.g. "api-version").
+// A parameter already present in the request path takes precedence.
+func VersionInQuery(name string) VersionStrategy {
+	return VersionStrategy{location: versionInQuery, name: name}
+}
+
+// String describes the strategy, e.g. "path" or "header:X-API-Version".
+func (s VersionStrategy) String() string {
+	switch s.location {
+	case versionInHeader:
+		return "header:" + s.name
+	case versionInQuery:
+		return "query:" + s.name
+	default:
+		return "path"
+	}
+}
+
+// versionedBaseURL returns the base URL to use for a request with the given version.
+func (s VersionStrategy) versionedBaseURL(baseURL, version string) string {
+	if version == "" || s.location != versionInPath {
+		return baseURL
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + strings.Trim(version, "/")
+}
+
+// apply adds the version to a header or query parameter of the request.
+func (s VersionStrategy) apply(req *http.Request, version string) {
+	if version == "" {
+		return
+	}
+
+	switch s.location {
+	case versionInHeader:
+		if req.Header.Get(s.name) == "" {
+			req.Header.Set(s.name, version)
+		}
+	case versionInQuery:
+		q := req.URL.Query()
+		if !q.Has(s.name) {
+			q.Set(s.name, version)
+			req.URL.RawQuery = q.Encode()
+		}
+	}
+}
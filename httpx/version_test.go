@@ -0,0 +1,104 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/seb7887/gofw/httpx"
+	"github.com/seb7887/gofw/httpx/httpxtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newVersionMock() *httpxtest.MockTransport {
+	return &httpxtest.MockTransport{
+		Response: &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString("ok")),
+		},
+	}
+}
+
+func TestAPIVersion_Path(t *testing.T) {
+	mockTransport := newVersionMock()
+	client := httpx.NewClient(
+		httpx.WithTransport(mockTransport),
+		httpx.WithBaseURL("http://example.com/api/"),
+		httpx.WithAPIVersion("v2"),
+	)
+	ctx := context.Background()
+
+	_, err := client.Get(ctx, "/users?page=2")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/api/v2/users?page=2", mockTransport.LastRequest().URL.String())
+
+	// Per-request override
+	_, err = client.Do(ctx, &httpx.Request{
+		Method:  http.MethodGet,
+		Path:    "/users",
+		Options: []httpx.RequestOption{httpx.WithRequestAPIVersion("v3")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/api/v3/users", mockTransport.LastRequest().URL.String())
+
+	// Empty override sends the request unversioned
+	_, err = client.Do(ctx, &httpx.Request{
+		Method:  http.MethodGet,
+		Path:    "/health",
+		Options: []httpx.RequestOption{httpx.WithRequestAPIVersion("")},
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, mockTransport.LastRequest().URL.Path, "v2")
+}
+
+func TestAPIVersion_Header(t *testing.T) {
+	mockTransport := newVersionMock()
+	client := httpx.NewClient(
+		httpx.WithTransport(mockTransport),
+		httpx.WithBaseURL("http://example.com"),
+		httpx.WithAPIVersion("2024-06-01"),
+		httpx.WithVersionStrategy(httpx.VersionInHeader("X-API-Version")),
+	)
+	ctx := context.Background()
+
+	_, err := client.Get(ctx, "/users")
+	require.NoError(t, err)
+	req := mockTransport.LastRequest()
+	assert.Equal(t, "http://example.com/users", req.URL.String())
+	assert.Equal(t, "2024-06-01", req.Header.Get("X-API-Version"))
+
+	// An explicit header wins
+	_, err = client.Get(ctx, "/users", httpx.Headers{"X-API-Version": "2023-01-01"})
+	require.NoError(t, err)
+	assert.Equal(t, "2023-01-01", mockTransport.LastRequest().Header.Get("X-API-Version"))
+}
+
+func TestAPIVersion_Query(t *testing.T) {
+	mockTransport := newVersionMock()
+	client := httpx.NewClient(
+		httpx.WithTransport(mockTransport),
+		httpx.WithBaseURL("http://example.com"),
+		httpx.WithAPIVersion("1.2"),
+		httpx.WithVersionStrategy(httpx.VersionInQuery("api-version")),
+	)
+
+	_, err := client.Get(context.Background(), "/users?page=2")
+	require.NoError(t, err)
+	query := mockTransport.LastRequest().URL.Query()
+	assert.Equal(t, "1.2", query.Get("api-version"))
+	assert.Equal(t, "2", query.Get("page"))
+}
+
+func TestAPIVersion_Reload(t *testing.T) {
+	client := httpx.NewClient(
+		httpx.WithTransport(newVersionMock()),
+		httpx.WithAPIVersion("v1"),
+	)
+
+	changes := client.Reload(httpx.WithAPIVersion("v2"))
+	assert.Contains(t, changes, httpx.ConfigChange{Field: "APIVersion", Old: "v1", New: "v2"})
+	assert.Equal(t, "path", client.Snapshot().APIVersionStrategy)
+}
@@ -0,0 +1,263 @@
+package sietch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultAuditTable is the table audit entries are stored in
+const DefaultAuditTable = "audit_log"
+
+// AuditOperation is the kind of change recorded by an audit entry
+type AuditOperation string
+
+const (
+	AuditCreate AuditOperation = "create"
+	AuditUpdate AuditOperation = "update"
+	AuditDelete AuditOperation = "delete"
+)
+
+// AuditEntry records a change to an entity. Before is empty for creates and
+// After for deletes; both hold the JSON encoding of the entity.
+type AuditEntry struct {
+	ID        string          `db:"id"`
+	Entity    string          `db:"entity"`
+	EntityID  string          `db:"entity_id"`
+	Operation AuditOperation  `db:"operation"`
+	Actor     string          `db:"actor"`
+	Before    json.RawMessage `db:"before"`
+	After     json.RawMessage `db:"after"`
+	CreatedAt time.Time       `db:"created_at"`
+}
+
+// AuditEntryID returns the ID of an audit entry, for use as the getID
+// function of the audit log repository
+func AuditEntryID(e *AuditEntry) string {
+	return e.ID
+}
+
+// AuditTableDef returns the schema of an audit log table, indexed by entity
+// and by time. Create it with Bootstrap or AutoMigrate.
+func AuditTableDef(table string) *TableDef {
+	return &TableDef{
+		Name: table,
+		Columns: []ColumnDef{
+			{Name: "id", Type: ColumnType("UUID"), PrimaryKey: true},
+			{Name: "entity", Type: ColumnTypeText, NotNull: true},
+			{Name: "entity_id", Type: ColumnTypeText, NotNull: true},
+			{Name: "operation", Type: ColumnTypeText, NotNull: true},
+			{Name: "actor", Type: ColumnTypeText, NotNull: true, DefaultValue: "''"},
+			{Name: "before", Type: ColumnTypeJSON},
+			{Name: "after", Type: ColumnTypeJSON},
+			{Name: "created_at", Type: ColumnType("TIMESTAMPTZ"), NotNull: true, DefaultValue: "now()"},
+		},
+		Indexes: []IndexDef{
+			{Name: "idx_" + table + "_entity", Type: IndexTypeBTree, Columns: []string{"entity", "entity_id", "created_at"}},
+			{Name: "idx_" + table + "_created_at", Type: IndexTypeBTree, Columns: []string{"created_at"}},
+		},
+	}
+}
+
+// AuditStore persists audit entries
+type AuditStore interface {
+	Record(ctx context.Context, entry *AuditEntry) error
+}
+
+// RepositoryAuditStore stores audit entries in a repository, e.g. a
+// CockroachDB connector on the audit_log table. A connector on the same
+// database as the audited entities writes the entries in the transaction of
+// the change when the context carries one (see TransactionManager); a
+// separate connector keeps the log apart from the data.
+type RepositoryAuditStore struct {
+	repo Repository[AuditEntry, string]
+}
+
+// NewRepositoryAuditStore creates an audit store writing to repo
+func NewRepositoryAuditStore(repo Repository[AuditEntry, string]) *RepositoryAuditStore {
+	return &RepositoryAuditStore{repo: repo}
+}
+
+// Record creates entry in the repository
+func (s *RepositoryAuditStore) Record(ctx context.Context, entry *AuditEntry) error {
+	return s.repo.Create(ctx, entry)
+}
+
+// actorKey is the context key type for the acting user
+type actorKey struct{}
+
+// WithActor returns a context recording actor as the author of the changes
+// made with it
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set with WithActor
+func ActorFromContext(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+// AuditOptions configures an AuditHook
+type AuditOptions struct {
+	// Entity is recorded as the entity name, the name of T if empty
+	Entity string
+	// Actor extracts the actor from the context, ActorFromContext if nil
+	Actor func(ctx context.Context) string
+	// Now returns the time of an entry, time.Now if nil
+	Now func() time.Time
+}
+
+// auditPendingTTL is how long a before snapshot waits for its After hook.
+// Snapshots of operations that failed are dropped after it.
+const auditPendingTTL = time.Minute
+
+type auditSnapshot struct {
+	data json.RawMessage
+	at   time.Time
+}
+
+// AuditHook records an audit entry for every Create, Update and Delete.
+// Before snapshots of updates and deletes are read with reader in the Before
+// hooks and written together with the after snapshot in the After hooks; a
+// nil reader records no before snapshots. Register it with a HookRegistry.
+//
+// The reader must return a copy of the stored entity: with an InMemoryConnector
+// an item updated in place is its own before snapshot.
+type AuditHook[T any, ID comparable] struct {
+	BaseHook[T, ID]
+
+	store  AuditStore
+	reader Repository[T, ID]
+	getID  func(*T) ID
+	entity string
+	actor  func(ctx context.Context) string
+	now    func() time.Time
+
+	mu        sync.Mutex
+	pending   map[any]auditSnapshot // keyed by item pointer for updates, by ID for deletes
+	lastSweep time.Time
+}
+
+// NewAuditHook creates a hook recording the changes to entities of type T in
+// store
+func NewAuditHook[T any, ID comparable](store AuditStore, reader Repository[T, ID], getID func(*T) ID, opts AuditOptions) *AuditHook[T, ID] {
+	h := &AuditHook[T, ID]{
+		store:   store,
+		reader:  reader,
+		getID:   getID,
+		entity:  opts.Entity,
+		actor:   opts.Actor,
+		now:     opts.Now,
+		pending: make(map[any]auditSnapshot),
+	}
+	if h.entity == "" {
+		h.entity = reflect.TypeFor[T]().Name()
+	}
+	if h.actor == nil {
+		h.actor = func(ctx context.Context) string {
+			actor, _ := ActorFromContext(ctx)
+			return actor
+		}
+	}
+	if h.now == nil {
+		h.now = time.Now
+	}
+	return h
+}
+
+func (h *AuditHook[T, ID]) AfterCreate(ctx context.Context, item *T) error {
+	after, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit snapshot: %w", err)
+	}
+	return h.record(ctx, AuditCreate, h.getID(item), nil, after)
+}
+
+func (h *AuditHook[T, ID]) BeforeUpdate(ctx context.Context, item *T) error {
+	return h.snapshot(ctx, item, h.getID(item))
+}
+
+func (h *AuditHook[T, ID]) AfterUpdate(ctx context.Context, item *T) error {
+	after, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit snapshot: %w", err)
+	}
+	return h.record(ctx, AuditUpdate, h.getID(item), h.take(item), after)
+}
+
+func (h *AuditHook[T, ID]) BeforeDelete(ctx context.Context, id ID) error {
+	return h.snapshot(ctx, id, id)
+}
+
+func (h *AuditHook[T, ID]) AfterDelete(ctx context.Context, id ID) error {
+	return h.record(ctx, AuditDelete, id, h.take(id), nil)
+}
+
+// snapshot reads the stored entity with the given id and keeps it under key
+// until the After hook. A missing entity has no snapshot.
+func (h *AuditHook[T, ID]) snapshot(ctx context.Context, key any, id ID) error {
+	if h.reader == nil {
+		return nil
+	}
+	item, err := h.reader.Get(ctx, id)
+	if errors.Is(err, ErrItemNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read audit snapshot: %w", err)
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit snapshot: %w", err)
+	}
+
+	now := h.now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now.Sub(h.lastSweep) > auditPendingTTL {
+		for k, s := range h.pending {
+			if now.Sub(s.at) > auditPendingTTL {
+				delete(h.pending, k)
+			}
+		}
+		h.lastSweep = now
+	}
+	h.pending[key] = auditSnapshot{data: data, at: now}
+	return nil
+}
+
+// take removes and returns the snapshot kept under key
+func (h *AuditHook[T, ID]) take(key any) json.RawMessage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.pending[key]
+	if !ok {
+		return nil
+	}
+	delete(h.pending, key)
+	return s.data
+}
+
+func (h *AuditHook[T, ID]) record(ctx context.Context, op AuditOperation, id ID, before, after json.RawMessage) error {
+	entry := &AuditEntry{
+		ID:        uuid.NewString(),
+		Entity:    h.entity,
+		EntityID:  fmt.Sprint(id),
+		Operation: op,
+		Actor:     h.actor(ctx),
+		Before:    before,
+		After:     after,
+		CreatedAt: h.now().UTC(),
+	}
+	if err := h.store.Record(ctx, entry); err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
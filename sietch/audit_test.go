@@ -0,0 +1,119 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestAuditHook(t *testing.T) {
+	ctx := WithActor(context.Background(), "alice")
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	accounts := NewInMemoryConnector[testutils.Account, int64](getID)
+	log := NewInMemoryConnector[AuditEntry, string](AuditEntryID)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	hooks := NewHookRegistry[testutils.Account, int64]()
+	hooks.AddHook(NewAuditHook(NewRepositoryAuditStore(log), accounts, getID, AuditOptions{
+		Now: func() time.Time { return now },
+	}))
+
+	created := &testutils.Account{ID: 1, Balance: 10}
+	if err := accounts.Create(ctx, created); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := hooks.ExecuteAfterCreate(ctx, created); err != nil {
+		t.Fatalf("AfterCreate failed: %v", err)
+	}
+
+	updated := &testutils.Account{ID: 1, Balance: 20}
+	if err := hooks.ExecuteBeforeUpdate(ctx, updated); err != nil {
+		t.Fatalf("BeforeUpdate failed: %v", err)
+	}
+	if err := accounts.Update(ctx, updated); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := hooks.ExecuteAfterUpdate(context.Background(), updated); err != nil {
+		t.Fatalf("AfterUpdate failed: %v", err)
+	}
+
+	if err := hooks.ExecuteBeforeDelete(ctx, 1); err != nil {
+		t.Fatalf("BeforeDelete failed: %v", err)
+	}
+	if err := accounts.Delete(ctx, 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := hooks.ExecuteAfterDelete(ctx, 1); err != nil {
+		t.Fatalf("AfterDelete failed: %v", err)
+	}
+
+	entries, err := log.Query(ctx, &Filter{})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d (%v)", len(entries), err)
+	}
+	byOp := make(map[AuditOperation]AuditEntry)
+	for _, e := range entries {
+		if e.Entity != "Account" || e.EntityID != "1" || !e.CreatedAt.Equal(now) || e.ID == "" {
+			t.Errorf("Unexpected entry %+v", e)
+		}
+		byOp[e.Operation] = e
+	}
+
+	expected := map[AuditOperation][2]string{
+		AuditCreate: {"", `{"ID":1,"Balance":10}`},
+		AuditUpdate: {`{"ID":1,"Balance":10}`, `{"ID":1,"Balance":20}`},
+		AuditDelete: {`{"ID":1,"Balance":20}`, ""},
+	}
+	for op, snapshots := range expected {
+		e := byOp[op]
+		if string(e.Before) != snapshots[0] || string(e.After) != snapshots[1] {
+			t.Errorf("Unexpected %s snapshots %s -> %s", op, e.Before, e.After)
+		}
+	}
+	if byOp[AuditCreate].Actor != "alice" || byOp[AuditUpdate].Actor != "" {
+		t.Errorf("Expected the actor to come from the context, got %q and %q", byOp[AuditCreate].Actor, byOp[AuditUpdate].Actor)
+	}
+}
+
+type failingAuditStore struct{}
+
+func (failingAuditStore) Record(context.Context, *AuditEntry) error {
+	return errors.New("store down")
+}
+
+func TestAuditHook_Snapshots(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	accounts := NewInMemoryConnector[testutils.Account, int64](getID)
+	now := time.Now()
+	hook := NewAuditHook(failingAuditStore{}, accounts, getID, AuditOptions{
+		Entity: "accounts",
+		Now:    func() time.Time { return now },
+	})
+
+	if err := hook.AfterCreate(ctx, &testutils.Account{ID: 1}); err == nil {
+		t.Error("Expected the store error to be returned")
+	}
+
+	if err := hook.BeforeDelete(ctx, 1); err != nil || len(hook.pending) != 0 {
+		t.Errorf("Expected no snapshot of a missing entity, got %v (%v)", hook.pending, err)
+	}
+
+	_ = accounts.Create(ctx, &testutils.Account{ID: 1})
+	_ = accounts.Create(ctx, &testutils.Account{ID: 2})
+	if err := hook.BeforeDelete(ctx, 1); err != nil || len(hook.pending) != 1 {
+		t.Fatalf("Expected a pending snapshot, got %v (%v)", hook.pending, err)
+	}
+
+	// The delete of 1 never completes, its snapshot expires
+	now = now.Add(2 * auditPendingTTL)
+	if err := hook.BeforeDelete(ctx, 2); err != nil {
+		t.Fatalf("BeforeDelete failed: %v", err)
+	}
+	if _, ok := hook.pending[int64(2)]; !ok || len(hook.pending) != 1 {
+		t.Errorf("Expected only the snapshot of 2 to be pending, got %v", hook.pending)
+	}
+}
@@ -0,0 +1,126 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultChunkSize is the chunk size used when ChunkOptions.ChunkSize is not set
+const DefaultChunkSize = 500
+
+// ChunkOptions configures ChunkedBatchCreate and ChunkedBatchUpsert
+type ChunkOptions struct {
+	// ChunkSize is the number of items written per batch call. Default: DefaultChunkSize
+	ChunkSize int
+
+	// StartAt is the index of the first item to write. Set it to
+	// PartialBatchError.Next to resume an interrupted batch.
+	StartAt int
+
+	// OnChunk is called after each chunk has been written
+	OnChunk func(ChunkProgress)
+}
+
+// ChunkProgress reports a chunk that has been written
+type ChunkProgress struct {
+	Range    BatchRange    // Items written by this chunk
+	Written  int           // Items written so far, including StartAt
+	Total    int           // Total number of items
+	Duration time.Duration // Time taken by this chunk
+}
+
+// BatchRange is the half-open range [Start, End) of item indexes
+type BatchRange struct {
+	Start int
+	End   int
+}
+
+// PartialBatchError is returned when a chunked batch stops before writing
+// every item. Completed chunks stay written; resume with ChunkOptions.StartAt
+// set to Next. It unwraps to the error that stopped the batch.
+type PartialBatchError struct {
+	Completed []BatchRange // Chunks written by this call, in order
+	Next      int          // Index of the first item not written
+	Err       error
+}
+
+func (e *PartialBatchError) Error() string {
+	return fmt.Sprintf("batch stopped at item %d after %d chunks: %v", e.Next, len(e.Completed), e.Err)
+}
+
+func (e *PartialBatchError) Unwrap() error {
+	return e.Err
+}
+
+// ChunkedBatchCreate creates items in chunks of opts.ChunkSize, one
+// BatchCreate call (and, for CockroachDB, one transaction) per chunk.
+//
+// When ctx has a deadline, a chunk is not started unless the time left is
+// longer than the slowest chunk so far, so the batch stops between chunks
+// instead of losing a chunk to a rollback. Any stop returns a
+// *PartialBatchError describing what was written.
+//
+// Example:
+//
+//	err := sietch.ChunkedBatchCreate(ctx, repo, items, sietch.ChunkOptions{ChunkSize: 1000})
+//	var partial *sietch.PartialBatchError
+//	if errors.As(err, &partial) {
+//	    // later: resume from partial.Next
+//	    err = sietch.ChunkedBatchCreate(ctx2, repo, items, sietch.ChunkOptions{StartAt: partial.Next})
+//	}
+func ChunkedBatchCreate[T any, ID comparable](ctx context.Context, repo Repository[T, ID], items []T, opts ChunkOptions) error {
+	return writeChunks(ctx, items, opts, repo.BatchCreate)
+}
+
+// ChunkedBatchUpsert upserts items in chunks of opts.ChunkSize, one
+// BatchUpsert call per chunk. See ChunkedBatchCreate.
+func ChunkedBatchUpsert[T any, ID comparable](ctx context.Context, repo Repository[T, ID], items []T, opts ChunkOptions) error {
+	return writeChunks(ctx, items, opts, repo.BatchUpsert)
+}
+
+func writeChunks[T any](ctx context.Context, items []T, opts ChunkOptions, write func(context.Context, []T) error) error {
+	size := opts.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if opts.StartAt < 0 || opts.StartAt > len(items) {
+		return fmt.Errorf("start index %d out of range [0, %d]", opts.StartAt, len(items))
+	}
+
+	var completed []BatchRange
+	var slowest time.Duration
+	stop := func(next int, err error) error {
+		return &PartialBatchError{Completed: completed, Next: next, Err: err}
+	}
+
+	for start := opts.StartAt; start < len(items); start += size {
+		end := min(start+size, len(items))
+
+		if err := ctx.Err(); err != nil {
+			return stop(start, err)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < slowest {
+			return stop(start, context.DeadlineExceeded)
+		}
+
+		began := time.Now()
+		if err := write(ctx, items[start:end]); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+				err = fmt.Errorf("%w: %w", ctxErr, err)
+			}
+			return stop(start, err)
+		}
+		elapsed := time.Since(began)
+		slowest = max(slowest, elapsed)
+
+		r := BatchRange{Start: start, End: end}
+		completed = append(completed, r)
+		if opts.OnChunk != nil {
+			opts.OnChunk(ChunkProgress{Range: r, Written: end, Total: len(items), Duration: elapsed})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type chunkItem struct {
+	ID int64 `db:"id"`
+}
+
+// chunkRepo records BatchCreate calls and can delay or fail them
+type chunkRepo struct {
+	Repository[chunkItem, int64]
+	delay  time.Duration
+	failAt int // call number that fails, 0 for none
+	calls  [][]chunkItem
+}
+
+func (r *chunkRepo) BatchCreate(ctx context.Context, items []chunkItem) error {
+	r.calls = append(r.calls, items)
+	if len(r.calls) == r.failAt {
+		return ErrItemAlreadyExists
+	}
+	time.Sleep(r.delay)
+	return r.Repository.BatchCreate(ctx, items)
+}
+
+func chunkItems(n int) []chunkItem {
+	items := make([]chunkItem, n)
+	for i := range items {
+		items[i] = chunkItem{ID: int64(i)}
+	}
+	return items
+}
+
+func newChunkRepo() *chunkRepo {
+	return &chunkRepo{Repository: NewInMemoryConnector[chunkItem, int64](func(i *chunkItem) int64 { return i.ID })}
+}
+
+func TestChunkedBatchCreate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Writes every chunk and reports progress", func(t *testing.T) {
+		repo := newChunkRepo()
+		var progress []BatchRange
+		err := ChunkedBatchCreate[chunkItem, int64](ctx, repo, chunkItems(10), ChunkOptions{
+			ChunkSize: 4,
+			OnChunk: func(p ChunkProgress) {
+				progress = append(progress, p.Range)
+				if p.Written != p.Range.End || p.Total != 10 {
+					t.Errorf("Unexpected progress %+v", p)
+				}
+			},
+		})
+		if err != nil {
+			t.Fatalf("ChunkedBatchCreate failed: %v", err)
+		}
+
+		expected := []BatchRange{{0, 4}, {4, 8}, {8, 10}}
+		if !reflect.DeepEqual(progress, expected) {
+			t.Errorf("Expected progress %v, got %v", expected, progress)
+		}
+		if count, _ := repo.Count(ctx, nil); count != 10 {
+			t.Errorf("Expected 10 items, got %d", count)
+		}
+	})
+
+	t.Run("Failure returns the completed ranges and resumes", func(t *testing.T) {
+		repo := newChunkRepo()
+		repo.failAt = 2
+		items := chunkItems(10)
+
+		err := ChunkedBatchCreate[chunkItem, int64](ctx, repo, items, ChunkOptions{ChunkSize: 4})
+
+		var partial *PartialBatchError
+		if !errors.As(err, &partial) {
+			t.Fatalf("Expected *PartialBatchError, got %v", err)
+		}
+		if !errors.Is(err, ErrItemAlreadyExists) {
+			t.Errorf("Expected the chunk error to be wrapped, got %v", err)
+		}
+		if partial.Next != 4 || !reflect.DeepEqual(partial.Completed, []BatchRange{{0, 4}}) {
+			t.Errorf("Unexpected partial result %+v", partial)
+		}
+
+		repo.failAt = 0
+		if err := ChunkedBatchCreate[chunkItem, int64](ctx, repo, items, ChunkOptions{ChunkSize: 4, StartAt: partial.Next}); err != nil {
+			t.Fatalf("Resume failed: %v", err)
+		}
+		if count, _ := repo.Count(ctx, nil); count != 10 {
+			t.Errorf("Expected 10 items after resuming, got %d", count)
+		}
+	})
+
+	t.Run("Stops between chunks before the deadline", func(t *testing.T) {
+		repo := newChunkRepo()
+		repo.delay = 40 * time.Millisecond
+
+		dctx, cancel := context.WithTimeout(ctx, 60*time.Millisecond)
+		defer cancel()
+		err := ChunkedBatchCreate[chunkItem, int64](dctx, repo, chunkItems(10), ChunkOptions{ChunkSize: 4})
+
+		var partial *PartialBatchError
+		if !errors.As(err, &partial) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected a deadline PartialBatchError, got %v", err)
+		}
+		if partial.Next != 4 || len(repo.calls) != 1 {
+			t.Errorf("Expected to stop after the first chunk, got next %d after %d calls", partial.Next, len(repo.calls))
+		}
+	})
+
+	t.Run("Canceled context", func(t *testing.T) {
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		err := ChunkedBatchCreate[chunkItem, int64](cctx, newChunkRepo(), chunkItems(3), ChunkOptions{})
+		var partial *PartialBatchError
+		if !errors.As(err, &partial) || partial.Next != 0 || !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected a canceled PartialBatchError at 0, got %v", err)
+		}
+	})
+
+	t.Run("Invalid start", func(t *testing.T) {
+		if err := ChunkedBatchCreate[chunkItem, int64](ctx, newChunkRepo(), chunkItems(3), ChunkOptions{StartAt: 4}); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+func TestChunkedBatchUpsert(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[chunkItem, int64](func(i *chunkItem) int64 { return i.ID })
+
+	items := chunkItems(5)
+	for range 2 {
+		if err := ChunkedBatchUpsert[chunkItem, int64](ctx, repo, items, ChunkOptions{ChunkSize: 2}); err != nil {
+			t.Fatalf("ChunkedBatchUpsert failed: %v", err)
+		}
+	}
+	if count, _ := repo.Count(ctx, nil); count != 5 {
+		t.Errorf("Expected 5 items, got %d", count)
+	}
+}
@@ -0,0 +1,158 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Bootstrap prepares repositories for examples, demos and integration tests:
+// it creates tables for SQL connectors, loads fixtures and returns a teardown
+// that undoes both.
+//
+// Example:
+//
+//	b := sietch.NewBootstrap()
+//	sietch.AddFixtures(b, accounts, Account{ID: 1, Balance: 100}, Account{ID: 2, Balance: 50})
+//	sietch.AddFixtures(b, orders, seedOrders...)
+//
+//	teardown, err := b.Run(ctx)
+//	if err != nil { ... }
+//	defer teardown(context.Background())
+type Bootstrap struct {
+	// DropTables makes the teardown drop the tables of SQL connectors instead
+	// of deleting the seeded fixtures. Only enable it for throwaway databases,
+	// since tables are created with IF NOT EXISTS and may predate the bootstrap.
+	DropTables bool
+
+	steps []bootstrapStep
+}
+
+// bootstrapStep prepares one repository
+type bootstrapStep struct {
+	name     string
+	setup    func(ctx context.Context) error
+	teardown func(ctx context.Context, dropTables bool) error
+}
+
+// schemaManager is implemented by connectors that can create their own table
+type schemaManager interface {
+	createTable(ctx context.Context) error
+	dropTable(ctx context.Context) error
+}
+
+// itemIdentifier is implemented by connectors that can extract an item's ID
+type itemIdentifier[T any, ID comparable] interface {
+	itemID(item *T) ID
+}
+
+// NewBootstrap creates an empty bootstrap
+func NewBootstrap() *Bootstrap {
+	return &Bootstrap{}
+}
+
+// AddFixtures registers a repository and the items to load into it. Steps run
+// in registration order, so register referenced entities first. CockroachDB
+// tables are created from the entity's db tags (see InferTableDef).
+func AddFixtures[T any, ID comparable](b *Bootstrap, repo Repository[T, ID], fixtures ...T) *Bootstrap {
+	name := fmt.Sprintf("%T", *new(T))
+
+	b.steps = append(b.steps, bootstrapStep{
+		name: name,
+		setup: func(ctx context.Context) error {
+			if sm, ok := repo.(schemaManager); ok {
+				if err := sm.createTable(ctx); err != nil {
+					return fmt.Errorf("create table: %w", err)
+				}
+			}
+			if len(fixtures) == 0 {
+				return nil
+			}
+			if err := repo.BatchCreate(ctx, fixtures); err != nil {
+				return fmt.Errorf("load fixtures: %w", err)
+			}
+			return nil
+		},
+		teardown: func(ctx context.Context, dropTables bool) error {
+			if sm, ok := repo.(schemaManager); ok && dropTables {
+				return sm.dropTable(ctx)
+			}
+
+			identifier, ok := repo.(itemIdentifier[T, ID])
+			if !ok {
+				return fmt.Errorf("%w: cannot determine fixture IDs", ErrUnsupportedOperation)
+			}
+			var errs []error
+			for i := range fixtures {
+				err := repo.Delete(ctx, identifier.itemID(&fixtures[i]))
+				if err != nil && !errors.Is(err, ErrItemNotFound) && !errors.Is(err, ErrNoDeleteItem) {
+					errs = append(errs, err)
+				}
+			}
+			return errors.Join(errs...)
+		},
+	})
+	return b
+}
+
+// Run executes every registered step and returns a teardown that reverts them
+// in reverse order. If a step fails, the steps that completed before it are
+// torn down before the error is returned.
+func (b *Bootstrap) Run(ctx context.Context) (func(context.Context) error, error) {
+	dropTables := b.DropTables
+	steps := append([]bootstrapStep(nil), b.steps...)
+
+	var done []bootstrapStep
+	teardown := func(ctx context.Context) error {
+		var errs []error
+		for i := len(done) - 1; i >= 0; i-- {
+			if err := done[i].teardown(ctx, dropTables); err != nil {
+				errs = append(errs, fmt.Errorf("teardown %s: %w", done[i].name, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	for _, step := range steps {
+		// A failed step is not torn down: its fixtures may have collided with
+		// existing data, which must not be deleted
+		if err := step.setup(ctx); err != nil {
+			setupErr := fmt.Errorf("bootstrap %s: %w", step.name, err)
+			if tdErr := teardown(ctx); tdErr != nil {
+				return nil, errors.Join(setupErr, tdErr)
+			}
+			return nil, setupErr
+		}
+		done = append(done, step)
+	}
+
+	return teardown, nil
+}
+
+// createTable creates the connector's table from T's db tags if it does not exist
+func (r *CockroachDBConnector[T, ID]) createTable(ctx context.Context) error {
+	def, err := InferTableDef[T](r.tableName)
+	if err != nil {
+		return err
+	}
+	_, err = r.pool.Exec(ctx, GenerateCreateTableSQL(def))
+	return err
+}
+
+// dropTable drops the connector's table
+func (r *CockroachDBConnector[T, ID]) dropTable(ctx context.Context) error {
+	_, err := r.pool.Exec(ctx, GenerateDropTableSQL(r.tableName))
+	return err
+}
+
+func (r *CockroachDBConnector[T, ID]) itemID(item *T) ID {
+	return r.getID(item)
+}
+
+func (r *InMemoryConnector[T, ID]) itemID(item *T) ID {
+	return r.getID(item)
+}
+
+func (r *RedisConnector[T, ID]) itemID(item *T) ID {
+	return r.getID(item)
+}
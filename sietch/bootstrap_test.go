@@ -0,0 +1,68 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestBootstrap_RunAndTeardown(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	accounts := NewInMemoryConnector[testutils.Account, int64](getID)
+	orders := NewInMemoryConnector[order, int64](func(o *order) int64 { return o.ID })
+	_ = accounts.Create(ctx, &testutils.Account{ID: 99, Balance: 1}) // pre-existing data
+
+	b := NewBootstrap()
+	AddFixtures(b, accounts, testutils.Account{ID: 1, Balance: 10}, testutils.Account{ID: 2, Balance: 20})
+	AddFixtures(b, orders, order{ID: 1, Status: "new"})
+
+	teardown, err := b.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if n, _ := accounts.Count(ctx, &Filter{}); n != 3 {
+		t.Errorf("Expected 3 accounts after bootstrap, got %d", n)
+	}
+	if n, _ := orders.Count(ctx, &Filter{}); n != 1 {
+		t.Errorf("Expected 1 order after bootstrap, got %d", n)
+	}
+
+	if err := teardown(ctx); err != nil {
+		t.Fatalf("teardown failed: %v", err)
+	}
+	if n, _ := accounts.Count(ctx, &Filter{}); n != 1 {
+		t.Errorf("Expected only the pre-existing account after teardown, got %d", n)
+	}
+	if n, _ := orders.Count(ctx, &Filter{}); n != 0 {
+		t.Errorf("Expected no orders after teardown, got %d", n)
+	}
+
+	// Tearing down twice is harmless
+	if err := teardown(ctx); err != nil {
+		t.Errorf("second teardown failed: %v", err)
+	}
+}
+
+func TestBootstrap_FailedStepRevertsPreviousSteps(t *testing.T) {
+	ctx := context.Background()
+	accounts := NewInMemoryConnector[testutils.Account, int64](func(a *testutils.Account) int64 { return a.ID })
+	orders := NewInMemoryConnector[order, int64](func(o *order) int64 { return o.ID })
+	_ = orders.Create(ctx, &order{ID: 7, Status: "existing"})
+
+	b := NewBootstrap()
+	AddFixtures(b, accounts, testutils.Account{ID: 1})
+	AddFixtures(b, orders, order{ID: 7, Status: "fixture"})
+
+	if _, err := b.Run(ctx); !errors.Is(err, ErrItemAlreadyExists) {
+		t.Fatalf("Expected ErrItemAlreadyExists, got %v", err)
+	}
+	if n, _ := accounts.Count(ctx, &Filter{}); n != 0 {
+		t.Errorf("Expected completed steps to be torn down, got %d accounts", n)
+	}
+	if existing, err := orders.Get(ctx, 7); err != nil || existing.Status != "existing" {
+		t.Errorf("Pre-existing data of the failed step must be kept, got %+v, %v", existing, err)
+	}
+}
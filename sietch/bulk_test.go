@@ -0,0 +1,160 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type bulkItem struct {
+	ID     int64   `db:"id"`
+	Status string  `db:"status"`
+	Score  float64 `db:"score"`
+	Note   *string `db:"note"`
+}
+
+func TestCockroachDBBulkQueries(t *testing.T) {
+	conn, err := NewCockroachDBConnector[bulkItem, int64](
+		&pgxpool.Pool{},
+		"items",
+		func(i *bulkItem) int64 { return i.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	t.Run("UpdateWhere", func(t *testing.T) {
+		filter := NewFilter().
+			Where("status", OpEqual, "pending").
+			Where("score", OpLessThan, 10).
+			Build()
+
+		query, args, err := conn.updateWhereQuery(filter, map[string]any{"status": "archived", "note": nil})
+		if err != nil {
+			t.Fatalf("updateWhereQuery failed: %v", err)
+		}
+		expected := `UPDATE "items" SET "note" = $1, "status" = $2 WHERE "status" = $3 AND "score" < $4`
+		if query != expected {
+			t.Errorf("Expected: %s\nGot: %s", expected, query)
+		}
+		if !reflect.DeepEqual(args, []any{nil, "archived", "pending", 10}) {
+			t.Errorf("Unexpected args: %v", args)
+		}
+	})
+
+	t.Run("DeleteWhere", func(t *testing.T) {
+		filter := NewFilter().Where("status", OpIn, []string{"archived", "deleted"}).Build()
+
+		query, args, err := conn.deleteWhereQuery(filter)
+		if err != nil {
+			t.Fatalf("deleteWhereQuery failed: %v", err)
+		}
+		expected := `DELETE FROM "items" WHERE "status" IN ($1, $2)`
+		if query != expected {
+			t.Errorf("Expected: %s\nGot: %s", expected, query)
+		}
+		if len(args) != 2 {
+			t.Errorf("Expected 2 args, got %d", len(args))
+		}
+	})
+
+	t.Run("Rejects unsafe filters", func(t *testing.T) {
+		if _, _, err := conn.deleteWhereQuery(&Filter{}); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("expected ErrInvalidFilter without conditions, got %v", err)
+		}
+		limited := NewFilter().Where("status", OpEqual, "x").Limit(1).Build()
+		if _, _, err := conn.deleteWhereQuery(limited); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("expected ErrInvalidFilter with limit, got %v", err)
+		}
+		filter := NewFilter().Where("status", OpEqual, "x").Build()
+		if _, _, err := conn.updateWhereQuery(filter, map[string]any{"bogus": 1}); err == nil {
+			t.Error("expected error for unknown update column")
+		}
+		if _, _, err := conn.updateWhereQuery(filter, nil); err == nil {
+			t.Error("expected error for empty updates")
+		}
+	})
+}
+
+func TestInMemoryConnector_UpdateWhere(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[bulkItem, int64](func(i *bulkItem) int64 { return i.ID })
+	note := "keep"
+	_ = repo.BatchCreate(ctx, []bulkItem{
+		{ID: 1, Status: "pending", Score: 5, Note: &note},
+		{ID: 2, Status: "pending", Score: 50},
+		{ID: 3, Status: "active", Score: 1},
+	})
+
+	filter := NewFilter().Where("status", OpEqual, "pending").Where("score", OpLessThan, 10).Build()
+	n, err := repo.UpdateWhere(ctx, filter, map[string]any{"status": "archived", "score": 7, "note": nil})
+	if err != nil {
+		t.Fatalf("UpdateWhere failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1 updated item, got %d", n)
+	}
+
+	got, _ := repo.Get(ctx, 1)
+	if got.Status != "archived" || got.Score != 7 || got.Note != nil {
+		t.Errorf("Unexpected item after update: %+v", got)
+	}
+	if other, _ := repo.Get(ctx, 2); other.Status != "pending" {
+		t.Errorf("Non-matching item was updated: %+v", other)
+	}
+
+	t.Run("Invalid assignment leaves data untouched", func(t *testing.T) {
+		all := NewFilter().Where("score", OpGreaterThanOrEqual, 0).Build()
+		if _, err := repo.UpdateWhere(ctx, all, map[string]any{"status": "x", "score": "high"}); err == nil {
+			t.Fatal("expected error assigning string to float field")
+		}
+		if item, _ := repo.Get(ctx, 3); item.Status != "active" {
+			t.Errorf("item changed despite failed update: %+v", item)
+		}
+	})
+
+	t.Run("Pointer fields accept plain values", func(t *testing.T) {
+		byID := NewFilter().Where("id", OpEqual, int64(2)).Build()
+		if _, err := repo.UpdateWhere(ctx, byID, map[string]any{"note": "hello"}); err != nil {
+			t.Fatalf("UpdateWhere failed: %v", err)
+		}
+		if item, _ := repo.Get(ctx, 2); item.Note == nil || *item.Note != "hello" {
+			t.Errorf("Unexpected note: %v", item.Note)
+		}
+	})
+
+	t.Run("Changing the ID to an existing one fails", func(t *testing.T) {
+		byID := NewFilter().Where("id", OpEqual, int64(2)).Build()
+		if _, err := repo.UpdateWhere(ctx, byID, map[string]any{"id": int64(3)}); !errors.Is(err, ErrItemAlreadyExists) {
+			t.Errorf("expected ErrItemAlreadyExists, got %v", err)
+		}
+	})
+}
+
+func TestInMemoryConnector_DeleteWhere(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[bulkItem, int64](func(i *bulkItem) int64 { return i.ID })
+	_ = repo.BatchCreate(ctx, []bulkItem{
+		{ID: 1, Status: "archived"},
+		{ID: 2, Status: "archived"},
+		{ID: 3, Status: "active"},
+	})
+
+	n, err := repo.DeleteWhere(ctx, NewFilter().Where("status", OpEqual, "archived").Build())
+	if err != nil {
+		t.Fatalf("DeleteWhere failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 deleted items, got %d", n)
+	}
+	if count, _ := repo.Count(ctx, &Filter{}); count != 1 {
+		t.Errorf("Expected 1 remaining item, got %d", count)
+	}
+
+	if _, err := repo.DeleteWhere(ctx, &Filter{}); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected ErrInvalidFilter without conditions, got %v", err)
+	}
+}
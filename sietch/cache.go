@@ -2,6 +2,8 @@ package sietch
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"time"
 )
 
@@ -15,10 +17,16 @@ const (
 	// CacheStrategyWriteAround writes only to base storage, invalidates cache
 	CacheStrategyWriteAround CacheStrategy = "write_around"
 
-	// CacheStrategyWriteBack writes to cache first, async to base storage
+	// CacheStrategyWriteBack writes to cache first, async to base storage.
+	// A worker flushes the writes to base in order and in batches, see
+	// WriteBackOptions.
 	CacheStrategyWriteBack CacheStrategy = "write_back"
 )
 
+// DefaultLoadTimeout bounds the loads shared by concurrent Gets, see
+// CachedRepository.SetLoadTimeout and CoalescingRepository.SetLoadTimeout
+const DefaultLoadTimeout = 5 * time.Second
+
 // CachedRepository wraps a base repository with a caching layer
 // It provides automatic caching for Get operations and cache invalidation for mutations
 type CachedRepository[T any, ID comparable] struct {
@@ -26,6 +34,29 @@ type CachedRepository[T any, ID comparable] struct {
 	cache    Repository[T, ID] // Cache layer (e.g., Redis)
 	ttl      time.Duration     // Time-to-live for cached items
 	strategy CacheStrategy     // Caching strategy
+
+	l1       *l1Cache[T, ID]        // in-process cache in front of cache, see SetL1
+	negative *l1Cache[struct{}, ID] // IDs known not to exist, see SetNegativeCaching
+	getID    func(*T) ID
+
+	bus       InvalidationBus // shares evictions with other instances, see SetInvalidationBus
+	busSource string          // ID of this instance on the bus
+
+	writeBack     *writeBackQueue[T, ID] // writes waiting for base, see SetWriteBack
+	writeBackOnce sync.Once
+
+	loads *CoalescingRepository[T, ID] // cache misses being loaded, see cacheLoader
+}
+
+// cacheLoader is the repository coalesced by CachedRepository.Get: its Get
+// loads an item missing from the L1
+type cacheLoader[T any, ID comparable] struct {
+	Repository[T, ID]
+	load func(ctx context.Context, id ID) (*T, error)
+}
+
+func (l *cacheLoader[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	return l.load(ctx, id)
 }
 
 // NewCachedRepository creates a new cached repository
@@ -37,12 +68,7 @@ func NewCachedRepository[T any, ID comparable](
 	cache Repository[T, ID],
 	ttl time.Duration,
 ) *CachedRepository[T, ID] {
-	return &CachedRepository[T, ID]{
-		base:     base,
-		cache:    cache,
-		ttl:      ttl,
-		strategy: CacheStrategyWriteThrough,
-	}
+	return NewCachedRepositoryWithStrategy(base, cache, ttl, CacheStrategyWriteThrough)
 }
 
 // NewCachedRepositoryWithStrategy creates a cached repository with a specific strategy
@@ -52,27 +78,59 @@ func NewCachedRepositoryWithStrategy[T any, ID comparable](
 	ttl time.Duration,
 	strategy CacheStrategy,
 ) *CachedRepository[T, ID] {
-	return &CachedRepository[T, ID]{
+	r := &CachedRepository[T, ID]{
 		base:     base,
 		cache:    cache,
 		ttl:      ttl,
 		strategy: strategy,
 	}
+	r.loads = NewCoalescingRepository[T, ID](&cacheLoader[T, ID]{Repository: base, load: r.load})
+	return r
+}
+
+// SetLoadTimeout bounds the loads shared by concurrent Gets, so a stuck load
+// cannot block every reader of a key: once it expires, the load and its
+// callers fail with context.DeadlineExceeded. Zero leaves loads unbounded.
+func (r *CachedRepository[T, ID]) SetLoadTimeout(timeout time.Duration) {
+	r.loads.SetLoadTimeout(timeout)
 }
 
-// Get tries cache first, falls back to base on cache miss
+// Get tries the L1 and cache first, falls back to base on cache miss.
+// Concurrent Gets missing the same ID share one load, so a hot key expiring
+// reaches the cache and base once rather than once per caller; see
+// CoalescingRepository.Get. Gets inside a transaction load on their own.
 func (r *CachedRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	if item, ok := r.l1.get(id); ok {
+		return item, nil
+	}
+	if _, missing := r.negative.get(id); missing {
+		return nil, ErrItemNotFound
+	}
+
+	return r.loads.Get(ctx, id)
+}
+
+// load reads an item missing from the L1 from the cache, or from base
+// populating the cache
+func (r *CachedRepository[T, ID]) load(ctx context.Context, id ID) (*T, error) {
+	gen, negativeGen := r.l1.generation(), r.negative.generation()
+
 	// Try cache first
 	item, err := r.cache.Get(ctx, id)
 	if err == nil {
+		r.l1.store(id, item, gen)
 		return item, nil
 	}
 
 	// Cache miss or error - get from base
 	item, err = r.base.Get(ctx, id)
 	if err != nil {
+		if errors.Is(err, ErrItemNotFound) {
+			r.negative.store(id, &struct{}{}, negativeGen)
+		}
 		return nil, err
 	}
+	r.l1.store(id, item, gen)
 
 	// Populate cache asynchronously (fire and forget)
 	go func() {
@@ -82,9 +140,82 @@ func (r *CachedRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
 	return item, nil
 }
 
-// Create creates in base and manages cache based on strategy
+// GetMany serves what it can from the L1 and cache and fetches the misses from base in one call
+func (r *CachedRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	var local map[ID]*T
+	if r.l1 != nil || r.negative != nil {
+		local = make(map[ID]*T)
+		var remote []ID
+		for _, id := range ids {
+			if item, ok := r.l1.get(id); ok {
+				local[id] = item
+			} else if _, missing := r.negative.get(id); !missing {
+				remote = append(remote, id)
+			}
+		}
+		if len(remote) == 0 {
+			return local, nil
+		}
+		ids = remote
+	}
+	gen, negativeGen := r.l1.generation(), r.negative.generation()
+
+	results, err := r.cache.GetMany(ctx, ids)
+	if err != nil {
+		results = make(map[ID]*T, len(ids))
+	}
+	for id, item := range results {
+		r.l1.store(id, item, gen)
+	}
+
+	var misses []ID
+	for _, id := range ids {
+		if _, ok := results[id]; !ok {
+			misses = append(misses, id)
+		}
+	}
+	for id, item := range local {
+		results[id] = item
+	}
+	if len(misses) == 0 {
+		return results, nil
+	}
+
+	fetched, err := r.base.GetMany(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+
+	toCache := make([]T, 0, len(fetched))
+	for id, item := range fetched {
+		results[id] = item
+		toCache = append(toCache, *item)
+		r.l1.store(id, item, gen)
+	}
+	if r.negative != nil {
+		for _, id := range misses {
+			if _, ok := fetched[id]; !ok {
+				r.negative.store(id, &struct{}{}, negativeGen)
+			}
+		}
+	}
+
+	// Populate cache asynchronously (fire and forget)
+	if len(toCache) > 0 {
+		go func() {
+			_ = r.cache.BatchUpsert(context.Background(), toCache)
+		}()
+	}
+
+	return results, nil
+}
+
+// Create creates in base and manages cache based on strategy.
+// With CacheStrategyWriteBack, conflicts only surface when flushing.
 func (r *CachedRepository[T, ID]) Create(ctx context.Context, item *T) error {
-	// Always create in base first
+	if r.strategy == CacheStrategyWriteBack {
+		return r.writeBehindItem(ctx, "Create", item)
+	}
 	if err := r.base.Create(ctx, item); err != nil {
 		return err
 	}
@@ -96,18 +227,18 @@ func (r *CachedRepository[T, ID]) Create(ctx context.Context, item *T) error {
 		_ = r.cache.Upsert(ctx, item)
 	case CacheStrategyWriteAround:
 		// Don't write to cache, let next Get populate it
-	case CacheStrategyWriteBack:
-		// Write to cache asynchronously
-		go func() {
-			_ = r.cache.Upsert(context.Background(), item)
-		}()
 	}
 
+	r.written(ctx, item)
+
 	return nil
 }
 
 // Update updates in base and invalidates/updates cache
 func (r *CachedRepository[T, ID]) Update(ctx context.Context, item *T) error {
+	if r.strategy == CacheStrategyWriteBack {
+		return r.writeBehindItem(ctx, "Update", item)
+	}
 	if err := r.base.Update(ctx, item); err != nil {
 		return err
 	}
@@ -120,22 +251,24 @@ func (r *CachedRepository[T, ID]) Update(ctx context.Context, item *T) error {
 		// Invalidate cache - next Get will repopulate
 		// Note: We use Upsert instead of Delete to avoid errors if key doesn't exist
 		_ = r.cache.Upsert(ctx, item)
-	case CacheStrategyWriteBack:
-		go func() {
-			_ = r.cache.Upsert(context.Background(), item)
-		}()
 	}
 
+	r.written(ctx, item)
+
 	return nil
 }
 
 // Delete deletes from base and invalidates cache
 func (r *CachedRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	if r.strategy == CacheStrategyWriteBack {
+		return r.deleteBehind(ctx, []ID{id})
+	}
 	if err := r.base.Delete(ctx, id); err != nil {
 		return err
 	}
 
 	// Remove from cache (ignore errors)
+	r.deleted(ctx, id)
 	_ = r.cache.Delete(ctx, id)
 
 	return nil
@@ -146,6 +279,11 @@ func (r *CachedRepository[T, ID]) Query(ctx context.Context, filter *Filter) ([]
 	return r.base.Query(ctx, filter)
 }
 
+// FindOne delegates to base
+func (r *CachedRepository[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	return r.base.FindOne(ctx, filter)
+}
+
 // Count delegates to base
 func (r *CachedRepository[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
 	return r.base.Count(ctx, filter)
@@ -153,6 +291,9 @@ func (r *CachedRepository[T, ID]) Count(ctx context.Context, filter *Filter) (in
 
 // BatchCreate creates in base and manages cache
 func (r *CachedRepository[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	if r.strategy == CacheStrategyWriteBack {
+		return r.writeBehind(ctx, "Create", items)
+	}
 	if err := r.base.BatchCreate(ctx, items); err != nil {
 		return err
 	}
@@ -162,11 +303,16 @@ func (r *CachedRepository[T, ID]) BatchCreate(ctx context.Context, items []T) er
 		_ = r.cache.BatchUpsert(ctx, items)
 	}
 
+	r.writtenBatch(ctx, items)
+
 	return nil
 }
 
 // BatchUpdate updates in base and invalidates cache entries
 func (r *CachedRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	if r.strategy == CacheStrategyWriteBack {
+		return r.writeBehind(ctx, "Update", items)
+	}
 	if err := r.base.BatchUpdate(ctx, items); err != nil {
 		return err
 	}
@@ -176,16 +322,22 @@ func (r *CachedRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) er
 		_ = r.cache.BatchUpsert(ctx, items)
 	}
 
+	r.writtenBatch(ctx, items)
+
 	return nil
 }
 
 // BatchDelete deletes from base and invalidates cache entries
 func (r *CachedRepository[T, ID]) BatchDelete(ctx context.Context, ids []ID) error {
+	if r.strategy == CacheStrategyWriteBack {
+		return r.deleteBehind(ctx, ids)
+	}
 	if err := r.base.BatchDelete(ctx, ids); err != nil {
 		return err
 	}
 
 	// Remove from cache
+	r.deleted(ctx, ids...)
 	_ = r.cache.BatchDelete(ctx, ids)
 
 	return nil
@@ -198,6 +350,9 @@ func (r *CachedRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, erro
 
 // Upsert upserts in base and manages cache
 func (r *CachedRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	if r.strategy == CacheStrategyWriteBack {
+		return r.writeBehindItem(ctx, "Upsert", item)
+	}
 	if err := r.base.Upsert(ctx, item); err != nil {
 		return err
 	}
@@ -205,17 +360,18 @@ func (r *CachedRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
 	switch r.strategy {
 	case CacheStrategyWriteThrough:
 		_ = r.cache.Upsert(ctx, item)
-	case CacheStrategyWriteBack:
-		go func() {
-			_ = r.cache.Upsert(context.Background(), item)
-		}()
 	}
 
+	r.written(ctx, item)
+
 	return nil
 }
 
 // BatchUpsert upserts in base and manages cache
 func (r *CachedRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	if r.strategy == CacheStrategyWriteBack {
+		return r.writeBehind(ctx, "Upsert", items)
+	}
 	if err := r.base.BatchUpsert(ctx, items); err != nil {
 		return err
 	}
@@ -224,13 +380,62 @@ func (r *CachedRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) er
 		_ = r.cache.BatchUpsert(ctx, items)
 	}
 
+	r.writtenBatch(ctx, items)
+
 	return nil
 }
 
-// InvalidateCache removes all items from cache (if supported)
-// Note: This may not be supported by all cache implementations
+// UpdateWhere updates in base and evicts the matching entries from cache.
+// The cache still holds the pre-update values, so the same filter selects them.
+// Caches without filtered deletes (e.g. Redis) keep serving stale entries until their TTL expires.
+func (r *CachedRepository[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	// queued writes go first, so they don't overwrite the update
+	if err := r.drainWriteBack(ctx); err != nil {
+		return 0, err
+	}
+	n, err := r.base.UpdateWhere(ctx, filter, updates)
+	if err != nil {
+		return n, err
+	}
+
+	// the L1 cannot tell which entries matched
+	r.cleared(ctx)
+	_, _ = r.cache.DeleteWhere(ctx, filter)
+
+	return n, nil
+}
+
+// DeleteWhere deletes from base and evicts the matching entries from cache (if supported)
+func (r *CachedRepository[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	if err := r.drainWriteBack(ctx); err != nil {
+		return 0, err
+	}
+	n, err := r.base.DeleteWhere(ctx, filter)
+	if err != nil {
+		return n, err
+	}
+
+	// the L1 cannot tell which entries matched
+	r.cleared(ctx)
+	_, _ = r.cache.DeleteWhere(ctx, filter)
+
+	return n, nil
+}
+
+// InvalidateCache removes all items from the L1 and the cache, which must
+// implement Clearable; otherwise only the L1 is cleared and
+// ErrUnsupportedOperation returned. Queued write-back writes are flushed
+// first, as the cache holds them until then.
 func (r *CachedRepository[T, ID]) InvalidateCache(ctx context.Context) error {
-	// This would require a "clear all" operation which isn't in the Repository interface
-	// For now, this is a no-op. Implementations can add this if needed.
-	return nil
+	if err := r.drainWriteBack(ctx); err != nil {
+		return err
+	}
+	r.negative.clear()
+	r.cleared(ctx)
+
+	clearable, ok := r.cache.(Clearable)
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	return clearable.Clear(ctx)
 }
@@ -0,0 +1,156 @@
+package sietch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// InvalidationBus carries cache invalidations between the instances of a
+// service, so a write on one evicts the in-process entries of the others
+type InvalidationBus interface {
+	// Publish sends message to every subscriber, the publisher included
+	Publish(ctx context.Context, message []byte) error
+
+	// Subscribe calls handle with every published message until ctx is
+	// done. It returns once the subscription is active.
+	Subscribe(ctx context.Context, handle func(message []byte)) error
+}
+
+// invalidation is the message of an InvalidationBus
+type invalidation[ID comparable] struct {
+	Source string `json:"source"`        // instance that wrote, which skips it
+	IDs    []ID   `json:"ids,omitempty"` // evicted IDs
+	All    bool   `json:"all,omitempty"` // evict every entry
+}
+
+// SetInvalidationBus shares the evictions of the L1 and negative cache with
+// the other instances subscribed to bus: every write through this repository
+// publishes the IDs it changed, and the entries of IDs published by others
+// are evicted. Call it after SetL1 or SetNegativeCaching, before the
+// repository is used; messages are received until ctx is done.
+//
+// Publishing is best effort, like cache writes: a lost message leaves other
+// instances with stale entries until their TTL expires.
+func (r *CachedRepository[T, ID]) SetInvalidationBus(ctx context.Context, bus InvalidationBus) error {
+	if r.l1 == nil && r.negative == nil {
+		return fmt.Errorf("invalidation needs an L1 or negative cache")
+	}
+	source := uuid.NewString()
+	err := bus.Subscribe(ctx, func(message []byte) {
+		var msg invalidation[ID]
+		if err := json.Unmarshal(message, &msg); err != nil || msg.Source == source {
+			return
+		}
+		if msg.All {
+			r.l1.clear()
+			r.negative.clear()
+			return
+		}
+		r.l1.evict(msg.IDs...)
+		r.negative.evict(msg.IDs...)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to invalidations: %w", err)
+	}
+	r.bus, r.busSource = bus, source
+	return nil
+}
+
+// publish tells other instances to evict ids, or every entry if all
+func (r *CachedRepository[T, ID]) publish(ctx context.Context, ids []ID, all bool) {
+	if r.bus == nil || (len(ids) == 0 && !all) {
+		return
+	}
+	message, err := json.Marshal(invalidation[ID]{Source: r.busSource, IDs: ids, All: all})
+	if err != nil {
+		return
+	}
+	_ = r.bus.Publish(ctx, message)
+}
+
+// ChannelInvalidationBus is an in-process InvalidationBus, connecting the
+// cached repositories of one process or of tests
+type ChannelInvalidationBus struct {
+	mu          sync.RWMutex
+	subscribers map[int]func([]byte)
+	next        int
+}
+
+// NewChannelInvalidationBus creates an in-process invalidation bus
+func NewChannelInvalidationBus() *ChannelInvalidationBus {
+	return &ChannelInvalidationBus{subscribers: make(map[int]func([]byte))}
+}
+
+// Publish calls every subscriber synchronously
+func (b *ChannelInvalidationBus) Publish(_ context.Context, message []byte) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, handle := range b.subscribers {
+		handle(message)
+	}
+	return nil
+}
+
+func (b *ChannelInvalidationBus) Subscribe(ctx context.Context, handle func([]byte)) error {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.subscribers[id] = handle
+	b.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	})
+	return nil
+}
+
+// RedisInvalidationBus is an InvalidationBus over a Redis pub/sub channel
+type RedisInvalidationBus struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewRedisInvalidationBus creates an invalidation bus publishing to channel,
+// which should be distinct per entity type
+func NewRedisInvalidationBus(client *redis.Client, channel string) *RedisInvalidationBus {
+	return &RedisInvalidationBus{client: client, channel: channel}
+}
+
+func (b *RedisInvalidationBus) Publish(ctx context.Context, message []byte) error {
+	return b.client.Publish(ctx, b.channel, message).Err()
+}
+
+// Subscribe receives the messages of the channel in a goroutine. Messages
+// published while the connection is down are lost; go-redis reconnects and
+// resubscribes.
+func (b *RedisInvalidationBus) Subscribe(ctx context.Context, handle func([]byte)) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+
+	go func() {
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				handle([]byte(msg.Payload))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
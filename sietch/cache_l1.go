@@ -0,0 +1,225 @@
+package sietch
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultL1TTL is how long an L1 entry is served when L1Options.TTL is zero
+	DefaultL1TTL = 30 * time.Second
+	// DefaultL1Size is the number of L1 entries when L1Options.Size is zero
+	DefaultL1Size = 10000
+)
+
+// L1Options configures the in-process cache of a CachedRepository
+type L1Options struct {
+	// TTL is how long an item is served from memory before it is read from
+	// the cache repository again, DefaultL1TTL if zero. It bounds how stale
+	// an item may be after another instance changed it.
+	TTL time.Duration
+
+	// Size is the number of items kept, DefaultL1Size if zero; the least
+	// recently used item is evicted first
+	Size int
+}
+
+// SetL1 puts an in-process cache in front of the cache repository, so hot
+// items are served without a round trip. getID extracts the ID of written
+// items to update their entries. Writes through this repository update or
+// evict entries immediately, writes of other instances only once the TTL
+// expired.
+func (r *CachedRepository[T, ID]) SetL1(getID func(*T) ID, opts L1Options) error {
+	getID, err := resolveGetID(getID)
+	if err != nil {
+		return err
+	}
+	if opts.TTL < 0 || opts.Size < 0 {
+		return fmt.Errorf("L1 TTL and size cannot be negative")
+	}
+	if opts.TTL == 0 {
+		opts.TTL = DefaultL1TTL
+	}
+	if opts.Size == 0 {
+		opts.Size = DefaultL1Size
+	}
+	r.getID = getID
+	r.l1 = newL1Cache[T, ID](opts)
+	return nil
+}
+
+// l1Cache is an LRU map of items expiring after a TTL. Items are stored and
+// returned as copies, so callers cannot change cached entries.
+type l1Cache[T any, ID comparable] struct {
+	opts L1Options
+
+	mu      sync.Mutex
+	entries map[ID]*list.Element // of *l1Entry
+	lru     list.List            // most recently used first
+
+	// gen changes on every eviction; loads started before it changed may
+	// carry stale items and are not stored
+	gen uint64
+}
+
+type l1Entry[T any, ID comparable] struct {
+	id      ID
+	item    T
+	expires time.Time
+}
+
+func newL1Cache[T any, ID comparable](opts L1Options) *l1Cache[T, ID] {
+	return &l1Cache[T, ID]{opts: opts, entries: make(map[ID]*list.Element)}
+}
+
+// get returns a copy of the entry of id, if cached and not expired
+func (c *l1Cache[T, ID]) get(id ID) (*T, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*l1Entry[T, ID])
+	if time.Now().After(entry.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, id)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	item := entry.item
+	return &item, true
+}
+
+// generation returns the current generation, to be passed to store after
+// loading an item
+func (c *l1Cache[T, ID]) generation() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// store caches a copy of item, unless entries were evicted since gen was
+// read: the item may have been loaded before a write evicting it
+func (c *l1Cache[T, ID]) store(id ID, item *T, gen uint64) {
+	if c == nil || item == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	c.set(id, *item)
+}
+
+// put caches a copy of item written through the repository
+func (c *l1Cache[T, ID]) put(id ID, item *T) {
+	if c == nil || item == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++ // in-flight loads may hold the previous value
+	c.set(id, *item)
+}
+
+// set stores item, evicting the least recently used entry if full.
+// Must be called with the lock held.
+func (c *l1Cache[T, ID]) set(id ID, item T) {
+	expires := time.Now().Add(c.opts.TTL)
+	if el, ok := c.entries[id]; ok {
+		entry := el.Value.(*l1Entry[T, ID])
+		entry.item, entry.expires = item, expires
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[id] = c.lru.PushFront(&l1Entry[T, ID]{id: id, item: item, expires: expires})
+	if c.lru.Len() > c.opts.Size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*l1Entry[T, ID]).id)
+	}
+}
+
+// evict removes the entries of ids
+func (c *l1Cache[T, ID]) evict(ids ...ID) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for _, id := range ids {
+		if el, ok := c.entries[id]; ok {
+			c.lru.Remove(el)
+			delete(c.entries, id)
+		}
+	}
+}
+
+// clear removes every entry
+func (c *l1Cache[T, ID]) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = make(map[ID]*list.Element)
+	c.lru.Init()
+}
+
+// written updates the L1 entries of items written through the repository:
+// write-through and write-back cache them, write-around evicts them. Their IDs are no
+// longer cached as missing, and other instances evict them too.
+func (r *CachedRepository[T, ID]) written(ctx context.Context, items ...*T) {
+	if r.l1 == nil && r.negative == nil {
+		return
+	}
+	ids := make([]ID, len(items))
+	for i, item := range items {
+		ids[i] = r.getID(item)
+		r.negative.evict(ids[i])
+		if r.strategy != CacheStrategyWriteAround {
+			r.l1.put(ids[i], item)
+		} else {
+			r.l1.evict(ids[i])
+		}
+	}
+	r.publish(ctx, ids, false)
+}
+
+// writtenBatch is written for the items of a batch
+func (r *CachedRepository[T, ID]) writtenBatch(ctx context.Context, items []T) {
+	if r.l1 == nil && r.negative == nil {
+		return
+	}
+	pointers := make([]*T, len(items))
+	for i := range items {
+		pointers[i] = &items[i]
+	}
+	r.written(ctx, pointers...)
+}
+
+// deleted evicts the entries of deleted items, here and in other instances
+func (r *CachedRepository[T, ID]) deleted(ctx context.Context, ids ...ID) {
+	r.l1.evict(ids...)
+	r.publish(ctx, ids, false)
+}
+
+// cleared evicts every L1 entry, here and in other instances
+func (r *CachedRepository[T, ID]) cleared(ctx context.Context) {
+	r.l1.clear()
+	r.publish(ctx, nil, true)
+}
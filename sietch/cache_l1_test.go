@@ -0,0 +1,118 @@
+package sietch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+// countingRepository counts the Get and GetMany calls reaching a repository
+type countingRepository struct {
+	Repository[testutils.Account, int64]
+	gets int
+}
+
+func (r *countingRepository) Get(ctx context.Context, id int64) (*testutils.Account, error) {
+	r.gets++
+	return r.Repository.Get(ctx, id)
+}
+
+func (r *countingRepository) GetMany(ctx context.Context, ids []int64) (map[int64]*testutils.Account, error) {
+	r.gets++
+	return r.Repository.GetMany(ctx, ids)
+}
+
+func TestCachedRepository_L1(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	newRepo := func(opts L1Options) (*CachedRepository[testutils.Account, int64], *countingRepository) {
+		// both tiers hold the items, so no read populates the cache asynchronously
+		items := []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}, {ID: 3, Balance: 30}}
+		base := NewInMemoryConnector[testutils.Account](getID)
+		_ = base.BatchCreate(ctx, items)
+		cache := &countingRepository{Repository: NewInMemoryConnector[testutils.Account](getID)}
+		_ = cache.BatchCreate(ctx, items)
+		repo := NewCachedRepository[testutils.Account, int64](base, cache, time.Minute)
+		if err := repo.SetL1(getID, opts); err != nil {
+			t.Fatalf("SetL1 failed: %v", err)
+		}
+		return repo, cache
+	}
+
+	t.Run("Hot keys skip the cache", func(t *testing.T) {
+		repo, cache := newRepo(L1Options{})
+		for range 3 {
+			if got, err := repo.Get(ctx, 1); err != nil || got.Balance != 10 {
+				t.Fatalf("Expected item 1, got %+v (%v)", got, err)
+			}
+		}
+		if cache.gets != 1 {
+			t.Errorf("Expected 1 cache read, got %d", cache.gets)
+		}
+
+		got, _ := repo.Get(ctx, 1)
+		got.Balance = 0
+		if again, _ := repo.Get(ctx, 1); again.Balance != 10 {
+			t.Error("Expected L1 entries to be copies")
+		}
+
+		found, err := repo.GetMany(ctx, []int64{1, 2})
+		if err != nil || len(found) != 2 || found[2].Balance != 20 {
+			t.Errorf("Expected items 1 and 2, got %v (%v)", found, err)
+		}
+		if _, _ = repo.GetMany(ctx, []int64{1, 2}); cache.gets != 2 {
+			t.Errorf("Expected GetMany to read the cache once, got %d reads", cache.gets)
+		}
+	})
+
+	t.Run("Writes update entries", func(t *testing.T) {
+		repo, cache := newRepo(L1Options{})
+		_, _ = repo.Get(ctx, 1)
+		_ = repo.Update(ctx, &testutils.Account{ID: 1, Balance: 11})
+		if got, _ := repo.Get(ctx, 1); got.Balance != 11 || cache.gets != 1 {
+			t.Errorf("Expected the written item from the L1, got %+v after %d reads", got, cache.gets)
+		}
+
+		_ = repo.Delete(ctx, 1)
+		if _, err := repo.Get(ctx, 1); err == nil {
+			t.Error("Expected deleted items to be evicted")
+		}
+
+		_, _ = repo.Get(ctx, 2)
+		_, _ = repo.UpdateWhere(ctx, NewFilter().Where("id", OpEqual, int64(2)).Build(), map[string]any{"balance": 21})
+		if got, _ := repo.Get(ctx, 2); got.Balance != 21 {
+			t.Errorf("Expected UpdateWhere to clear the L1, got %+v", got)
+		}
+	})
+
+	t.Run("Size and TTL", func(t *testing.T) {
+		repo, cache := newRepo(L1Options{Size: 2, TTL: 20 * time.Millisecond})
+		for _, id := range []int64{1, 2, 1, 3} {
+			_, _ = repo.Get(ctx, id)
+		}
+		reads := cache.gets
+		if _, _ = repo.Get(ctx, 1); cache.gets != reads {
+			t.Error("Expected recently used items to stay")
+		}
+		if _, _ = repo.Get(ctx, 2); cache.gets != reads+1 {
+			t.Error("Expected the least recently used item to be evicted")
+		}
+
+		time.Sleep(30 * time.Millisecond)
+		if _, _ = repo.Get(ctx, 2); cache.gets != reads+2 {
+			t.Error("Expected expired items to be read again")
+		}
+	})
+
+	t.Run("Stale loads are dropped", func(t *testing.T) {
+		l1 := newL1Cache[testutils.Account, int64](L1Options{TTL: time.Minute, Size: 10})
+		gen := l1.generation()
+		l1.evict(1) // a write while the load was in flight
+		l1.store(1, &testutils.Account{ID: 1}, gen)
+		if _, ok := l1.get(1); ok {
+			t.Error("Expected the stale load not to be stored")
+		}
+	})
+}
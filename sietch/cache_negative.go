@@ -0,0 +1,50 @@
+package sietch
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultNegativeTTL is how long an ID is cached as missing when
+	// NegativeCacheOptions.TTL is zero
+	DefaultNegativeTTL = 5 * time.Second
+	// DefaultNegativeSize is the number of missing IDs kept when
+	// NegativeCacheOptions.Size is zero
+	DefaultNegativeSize = 10000
+)
+
+// NegativeCacheOptions configures the caching of IDs not found in base
+type NegativeCacheOptions struct {
+	// TTL is how long an ID is reported missing without asking base again,
+	// DefaultNegativeTTL if zero. Keep it short: items created by other
+	// instances are only seen once it expires.
+	TTL time.Duration
+
+	// Size is the number of missing IDs kept, DefaultNegativeSize if zero;
+	// the least recently used is evicted first
+	Size int
+}
+
+// SetNegativeCaching caches the IDs base reports missing, so repeated Gets
+// of them return ErrItemNotFound without reaching the cache or base. getID
+// extracts the ID of written items: creating or upserting an item through
+// this repository drops its ID immediately.
+func (r *CachedRepository[T, ID]) SetNegativeCaching(getID func(*T) ID, opts NegativeCacheOptions) error {
+	getID, err := resolveGetID(getID)
+	if err != nil {
+		return err
+	}
+	if opts.TTL < 0 || opts.Size < 0 {
+		return fmt.Errorf("negative cache TTL and size cannot be negative")
+	}
+	if opts.TTL == 0 {
+		opts.TTL = DefaultNegativeTTL
+	}
+	if opts.Size == 0 {
+		opts.Size = DefaultNegativeSize
+	}
+	r.getID = getID
+	r.negative = newL1Cache[struct{}, ID](L1Options{TTL: opts.TTL, Size: opts.Size})
+	return nil
+}
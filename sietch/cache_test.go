@@ -0,0 +1,395 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+// gatedRepository blocks the first Get until released or its context is
+// done, counting Gets
+type gatedRepository struct {
+	Repository[testutils.Account, int64]
+	gets    atomic.Int32
+	release chan struct{}
+}
+
+func (r *gatedRepository) Get(ctx context.Context, id int64) (*testutils.Account, error) {
+	if r.gets.Add(1) == 1 {
+		select {
+		case <-r.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return r.Repository.Get(ctx, id)
+}
+
+func TestCachedRepository_Singleflight(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	newRepo := func() (*CachedRepository[testutils.Account, int64], *gatedRepository) {
+		base := &gatedRepository{Repository: NewInMemoryConnector[testutils.Account](getID), release: make(chan struct{})}
+		_ = base.Repository.Create(ctx, &testutils.Account{ID: 1, Balance: 10})
+		return NewCachedRepository[testutils.Account, int64](base, NewInMemoryConnector[testutils.Account](getID), time.Minute), base
+	}
+
+	t.Run("Concurrent misses share one load", func(t *testing.T) {
+		repo, base := newRepo()
+		results := make([]*testutils.Account, 10)
+		var wg sync.WaitGroup
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i], _ = repo.Get(ctx, 1)
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(base.release)
+		wg.Wait()
+
+		if n := base.gets.Load(); n != 1 {
+			t.Errorf("Expected 1 base Get, got %d", n)
+		}
+		for _, item := range results {
+			if item == nil || item.Balance != 10 {
+				t.Fatalf("Expected item 1, got %+v", item)
+			}
+		}
+		results[0].Balance = 0
+		if results[1].Balance != 10 {
+			t.Error("Expected every caller to get its own copy")
+		}
+	})
+
+	t.Run("Stuck loads time out", func(t *testing.T) {
+		repo, base := newRepo()
+		defer close(base.release)
+		repo.SetLoadTimeout(10 * time.Millisecond)
+
+		if _, err := repo.Get(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected the load to time out, got %v", err)
+		}
+		item, err := repo.Get(ctx, 1)
+		if err != nil || item.Balance != 10 {
+			t.Fatalf("Expected the next Get to load the item, got %+v (%v)", item, err)
+		}
+	})
+
+	t.Run("Gets in transactions load on their own", func(t *testing.T) {
+		repo, base := newRepo()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = repo.Get(ctx, 1) // blocks on the gate
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		txCtx := context.WithValue(ctx, poolTxsKey{}, map[*pgxpool.Pool]pgx.Tx{})
+		item, err := repo.Get(txCtx, 1)
+		if err != nil || item.Balance != 10 {
+			t.Fatalf("Expected the item without waiting for the shared load, got %+v (%v)", item, err)
+		}
+		close(base.release)
+		<-done
+	})
+}
+
+func TestCachedRepository_NegativeCaching(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	base := &countingRepository{Repository: NewInMemoryConnector[testutils.Account](getID)}
+	_ = base.Create(ctx, &testutils.Account{ID: 1, Balance: 10})
+	repo := NewCachedRepository[testutils.Account, int64](base, NewInMemoryConnector[testutils.Account](getID), time.Minute)
+	if err := repo.SetNegativeCaching(getID, NegativeCacheOptions{TTL: time.Minute}); err != nil {
+		t.Fatalf("SetNegativeCaching failed: %v", err)
+	}
+
+	for range 3 {
+		if _, err := repo.Get(ctx, 42); !errors.Is(err, ErrItemNotFound) {
+			t.Fatalf("Expected ErrItemNotFound, got %v", err)
+		}
+	}
+	if base.gets != 1 {
+		t.Errorf("Expected the missing ID to reach base once, got %d", base.gets)
+	}
+
+	found, err := repo.GetMany(ctx, []int64{1, 42, 43})
+	if err != nil || len(found) != 1 || base.gets != 2 {
+		t.Errorf("Expected 1 item and a base read for the uncached IDs, got %v (%v) after %d reads", found, err, base.gets)
+	}
+	if _, _ = repo.GetMany(ctx, []int64{43}); base.gets != 2 {
+		t.Error("Expected IDs missing from GetMany to be cached")
+	}
+
+	if err := repo.Create(ctx, &testutils.Account{ID: 42, Balance: 42}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if got, err := repo.Get(ctx, 42); err != nil || got.Balance != 42 {
+		t.Errorf("Expected the created item, got %+v (%v)", got, err)
+	}
+
+	// the CockroachDB connector reports missing rows as ErrItemNotFound too
+	tx := &recordingTx{}
+	sql := NewCachedRepository[testutils.Account, int64](&cockroachDBTx[testutils.Account, int64]{connector: newBatchConnector(t), tx: tx, ctx: ctx}, NewInMemoryConnector[testutils.Account](getID), time.Minute)
+	_ = sql.SetNegativeCaching(getID, NegativeCacheOptions{TTL: time.Minute})
+	if _, err := sql.Get(ctx, 42); !errors.Is(err, ErrItemNotFound) || !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("Expected ErrItemNotFound wrapping pgx.ErrNoRows, got %v", err)
+	}
+	if _, err := sql.Get(ctx, 42); !errors.Is(err, ErrItemNotFound) || len(tx.log) != 1 {
+		t.Errorf("Expected the missing row to be cached, got %v after %d queries", err, len(tx.log))
+	}
+}
+
+func TestCachedRepository_InvalidationBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	base := NewInMemoryConnector[testutils.Account](getID)
+	cache := NewInMemoryConnector[testutils.Account](getID)
+	_ = base.Create(ctx, &testutils.Account{ID: 1, Balance: 10})
+	_ = cache.Create(ctx, &testutils.Account{ID: 1, Balance: 10})
+
+	// two instances sharing the database and Redis
+	bus := NewChannelInvalidationBus()
+	instances := make([]*CachedRepository[testutils.Account, int64], 2)
+	for i := range instances {
+		instances[i] = NewCachedRepository[testutils.Account, int64](base, cache, time.Minute)
+		_ = instances[i].SetL1(getID, L1Options{TTL: time.Hour})
+		_ = instances[i].SetNegativeCaching(getID, NegativeCacheOptions{TTL: time.Hour})
+		if err := instances[i].SetInvalidationBus(ctx, bus); err != nil {
+			t.Fatalf("SetInvalidationBus failed: %v", err)
+		}
+	}
+
+	_, _ = instances[0].Get(ctx, 1)
+	_, _ = instances[0].Get(ctx, 2)
+	_ = instances[1].Update(ctx, &testutils.Account{ID: 1, Balance: 11})
+	_ = instances[1].Create(ctx, &testutils.Account{ID: 2, Balance: 20})
+
+	if got, err := instances[0].Get(ctx, 1); err != nil || got.Balance != 11 {
+		t.Errorf("Expected the update of the other instance, got %+v (%v)", got, err)
+	}
+	if got, err := instances[0].Get(ctx, 2); err != nil || got.Balance != 20 {
+		t.Errorf("Expected the item created by the other instance, got %+v (%v)", got, err)
+	}
+
+	if err := NewCachedRepository[testutils.Account, int64](base, cache, time.Minute).SetInvalidationBus(ctx, bus); err == nil {
+		t.Error("Expected an error without an L1")
+	}
+}
+
+// flakyRepository fails the next failures writes with a transient error
+type flakyRepository struct {
+	Repository[testutils.Account, int64]
+	failures atomic.Int32
+	writes   atomic.Int32
+}
+
+func (r *flakyRepository) fail() error {
+	r.writes.Add(1)
+	if r.failures.Add(-1) >= 0 {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func (r *flakyRepository) BatchUpsert(ctx context.Context, items []testutils.Account) error {
+	if err := r.fail(); err != nil {
+		return err
+	}
+	return r.Repository.BatchUpsert(ctx, items)
+}
+
+func TestCachedRepository_WriteBack(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	newRepo := func(base Repository[testutils.Account, int64], opts WriteBackOptions) (*CachedRepository[testutils.Account, int64], *[]error) {
+		repo := NewCachedRepositoryWithStrategy[testutils.Account, int64](base, NewInMemoryConnector[testutils.Account](getID), time.Minute, CacheStrategyWriteBack)
+		errs := new([]error)
+		opts.OnError = func(err error) { *errs = append(*errs, err) }
+		if err := repo.SetWriteBack(opts); err != nil {
+			t.Fatalf("SetWriteBack failed: %v", err)
+		}
+		return repo, errs
+	}
+
+	t.Run("Writes reach base in order", func(t *testing.T) {
+		base := NewInMemoryConnector[testutils.Account](getID)
+		_ = base.Create(ctx, &testutils.Account{ID: 2})
+		repo, _ := newRepo(base, WriteBackOptions{FlushInterval: time.Hour})
+
+		_ = repo.Create(ctx, &testutils.Account{ID: 1, Balance: 10})
+		_ = repo.Update(ctx, &testutils.Account{ID: 1, Balance: 11})
+		_ = repo.Delete(ctx, 2)
+		if got, err := repo.Get(ctx, 1); err != nil || got.Balance != 11 {
+			t.Errorf("Expected the queued write from the cache, got %+v (%v)", got, err)
+		}
+		if exists, _ := base.Exists(ctx, 1); exists {
+			t.Error("Expected no base write before the flush")
+		}
+
+		if err := repo.Flush(ctx); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		if got, err := base.Get(ctx, 1); err != nil || got.Balance != 11 {
+			t.Errorf("Expected the flushed item, got %+v (%v)", got, err)
+		}
+		if exists, _ := base.Exists(ctx, 2); exists {
+			t.Error("Expected the flushed delete")
+		}
+	})
+
+	t.Run("Transient failures are retried", func(t *testing.T) {
+		base := &flakyRepository{Repository: NewInMemoryConnector[testutils.Account](getID)}
+		base.failures.Store(2)
+		repo, errs := newRepo(base, WriteBackOptions{Backoff: time.Millisecond})
+
+		_ = repo.BatchUpsert(ctx, []testutils.Account{{ID: 1}, {ID: 2}})
+		_ = repo.Flush(ctx)
+		if n, _ := base.Count(ctx, nil); n != 2 || base.writes.Load() != 3 || len(*errs) != 0 {
+			t.Errorf("Expected 2 items after 3 attempts, got %d after %d (%v)", n, base.writes.Load(), *errs)
+		}
+	})
+
+	t.Run("Permanent failures are reported", func(t *testing.T) {
+		base := NewInMemoryConnector[testutils.Account](getID)
+		_ = base.Create(ctx, &testutils.Account{ID: 1})
+		repo, errs := newRepo(base, WriteBackOptions{FlushInterval: time.Hour})
+
+		_ = repo.BatchCreate(ctx, []testutils.Account{{ID: 1, Balance: 5}, {ID: 2, Balance: 6}})
+		flushErr := repo.Flush(ctx)
+		reported := *errs
+		var writeErr *WriteBackError[testutils.Account, int64]
+		if len(reported) != 1 || !errors.As(reported[0], &writeErr) || !errors.Is(writeErr, ErrItemAlreadyExists) || writeErr.Op != "Create" || len(writeErr.Items) != 1 || writeErr.Items[0].Balance != 5 {
+			t.Errorf("Expected only the failed create to be reported, got %v", reported)
+		}
+		if !errors.Is(flushErr, ErrItemAlreadyExists) {
+			t.Errorf("Expected Flush to return the failure, got %v", flushErr)
+		}
+		if exists, _ := base.Exists(ctx, 2); !exists {
+			t.Error("Expected the other create of the batch to reach base")
+		}
+		if err := repo.Flush(ctx); err != nil {
+			t.Errorf("Expected failures to be returned once, got %v", err)
+		}
+	})
+
+	t.Run("Close drains the queue", func(t *testing.T) {
+		base := NewInMemoryConnector[testutils.Account](getID)
+		repo, _ := newRepo(base, WriteBackOptions{FlushInterval: time.Hour})
+		_ = repo.Create(ctx, &testutils.Account{ID: 1})
+		if err := repo.Close(ctx); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if exists, _ := base.Exists(ctx, 1); !exists {
+			t.Error("Expected Close to flush the queued write")
+		}
+		if err := repo.Create(ctx, &testutils.Account{ID: 2}); !errors.Is(err, ErrRepositoryClosed) {
+			t.Errorf("Expected ErrRepositoryClosed, got %v", err)
+		}
+	})
+
+	t.Run("Writes are queued whole or not at all", func(t *testing.T) {
+		base := &hangingRepository{Repository: NewInMemoryConnector[testutils.Account](getID), started: make(chan struct{}, 1)}
+		cache := NewInMemoryConnector[testutils.Account](getID)
+		repo := NewCachedRepositoryWithStrategy[testutils.Account, int64](base, cache, time.Minute, CacheStrategyWriteBack)
+		_ = repo.SetWriteBack(WriteBackOptions{QueueSize: 2, BatchSize: 1, WriteTimeout: time.Hour})
+
+		_ = repo.Create(ctx, &testutils.Account{ID: 1}) // hangs in base
+		<-base.started
+		_ = repo.Create(ctx, &testutils.Account{ID: 2}) // leaves room for one write
+
+		batchCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		if err := repo.BatchCreate(batchCtx, []testutils.Account{{ID: 3}, {ID: 4}}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the batch to time out waiting for room, got %v", err)
+		}
+		if n, _ := cache.Count(ctx, nil); n != 2 {
+			t.Errorf("Expected the timed out batch to leave the cache untouched, got %d items", n)
+		}
+		if err := repo.BatchCreate(ctx, []testutils.Account{{ID: 5}, {ID: 6}, {ID: 7}}); err == nil {
+			t.Error("Expected a batch larger than the queue to fail")
+		}
+
+		closeCtx, cancelClose := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancelClose()
+		_ = repo.Close(closeCtx)
+	})
+
+	t.Run("Close gives up on a full queue", func(t *testing.T) {
+		base := &hangingRepository{Repository: NewInMemoryConnector[testutils.Account](getID), started: make(chan struct{}, 1)}
+		repo, errs := newRepo(base, WriteBackOptions{QueueSize: 1, BatchSize: 1, WriteTimeout: time.Hour})
+
+		_ = repo.Create(ctx, &testutils.Account{ID: 1}) // hangs in base
+		<-base.started
+		_ = repo.Create(ctx, &testutils.Account{ID: 2}) // fills the queue
+		blocked := make(chan error)
+		go func() { blocked <- repo.Create(ctx, &testutils.Account{ID: 3}) }()
+		time.Sleep(10 * time.Millisecond)
+
+		closeCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		if err := repo.Close(closeCtx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected Close to give up, got %v", err)
+		}
+		if err := <-blocked; !errors.Is(err, ErrRepositoryClosed) {
+			t.Errorf("Expected the blocked write to fail with ErrRepositoryClosed, got %v", err)
+		}
+		if len(*errs) != 2 {
+			t.Errorf("Expected the unflushed writes to be reported, got %v", *errs)
+		}
+	})
+}
+
+// hangingRepository blocks writes until their context is done
+type hangingRepository struct {
+	Repository[testutils.Account, int64]
+	started chan struct{}
+}
+
+func (r *hangingRepository) Create(ctx context.Context, _ *testutils.Account) error {
+	select {
+	case r.started <- struct{}{}:
+	default:
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestCachedRepository_InvalidateCache(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	base := NewInMemoryConnector[testutils.Account](getID)
+	cache := NewInMemoryConnector[testutils.Account](getID)
+	_ = base.Create(ctx, &testutils.Account{ID: 1, Balance: 10})
+	_ = cache.Create(ctx, &testutils.Account{ID: 1, Balance: 99}) // stale
+
+	repo := NewCachedRepository[testutils.Account, int64](base, cache, time.Minute)
+	_ = repo.SetL1(getID, L1Options{})
+	if got, _ := repo.Get(ctx, 1); got.Balance != 99 {
+		t.Fatalf("Expected the stale cached item, got %+v", got)
+	}
+	if err := repo.InvalidateCache(ctx); err != nil {
+		t.Fatalf("InvalidateCache failed: %v", err)
+	}
+	if n, _ := cache.Count(ctx, nil); n != 0 {
+		t.Errorf("Expected an empty cache, got %d items", n)
+	}
+	if got, _ := repo.Get(ctx, 1); got.Balance != 10 {
+		t.Errorf("Expected the item from base, got %+v", got)
+	}
+
+	unclearable := NewCachedRepository[testutils.Account, int64](base, &countingRepository{Repository: cache}, time.Minute) // hides Clear
+	if err := unclearable.InvalidateCache(ctx); !errors.Is(err, ErrUnsupportedOperation) {
+		t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+	}
+}
@@ -0,0 +1,529 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWriteBackQueueSize is the number of writes waiting for base
+	// before writers block, when WriteBackOptions.QueueSize is zero
+	DefaultWriteBackQueueSize = 1000
+	// DefaultWriteBackBatchSize is the number of writes flushed at once
+	// when WriteBackOptions.BatchSize is zero
+	DefaultWriteBackBatchSize = 100
+	// DefaultWriteBackInterval is how often writes are flushed when
+	// WriteBackOptions.FlushInterval is zero
+	DefaultWriteBackInterval = 100 * time.Millisecond
+	// DefaultWriteBackRetries is how often a failed flush is retried when
+	// WriteBackOptions.MaxRetries is zero
+	DefaultWriteBackRetries = 3
+	// DefaultWriteBackBackoff is the delay before the first retry when
+	// WriteBackOptions.Backoff is zero
+	DefaultWriteBackBackoff = 100 * time.Millisecond
+	// DefaultWriteBackTimeout bounds each write to base when
+	// WriteBackOptions.WriteTimeout is zero
+	DefaultWriteBackTimeout = 10 * time.Second
+)
+
+// WriteBackOptions configures the flush worker of CacheStrategyWriteBack
+type WriteBackOptions struct {
+	// QueueSize bounds the writes waiting for base; when full, writes block
+	// until the worker catches up or their context is done. A write waits
+	// for room for all of its items before touching the cache, so batches
+	// larger than the queue fail.
+	QueueSize int
+
+	// BatchSize is the most writes flushed to base in one call
+	BatchSize int
+
+	// FlushInterval is how long a write may wait for a batch to fill
+	FlushInterval time.Duration
+
+	// MaxRetries is how often a failed flush is retried, doubling the
+	// Backoff delay each time; negative disables retries. Writes failing
+	// with ErrItemAlreadyExists, ErrNoUpdateItem, ErrNoDeleteItem,
+	// ErrConstraintViolation, ErrVersionConflict or ErrValidation are not
+	// retried.
+	MaxRetries int
+	Backoff    time.Duration
+
+	// WriteTimeout bounds each write to base, so a hung backend cannot stall
+	// the worker; timed out writes are retried
+	WriteTimeout time.Duration
+
+	// OnError receives a *WriteBackError for every flush that failed for
+	// good, or was still queued when Close gave up. Those writes remain in
+	// the cache but never reach base. When a batch fails with one of the
+	// errors above, its writes are retried one by one, so only the failing
+	// ones are reported.
+	OnError func(err error)
+}
+
+// WriteBackError reports writes of a write-back cache that could not be
+// flushed to base
+type WriteBackError[T any, ID comparable] struct {
+	Op    string // "Create", "Update", "Upsert" or "Delete"
+	Items []T    // written items, unless Op is "Delete"
+	IDs   []ID   // deleted IDs if Op is "Delete"
+	Err   error
+}
+
+func (e *WriteBackError[T, ID]) Error() string {
+	return fmt.Sprintf("write-back %s of %d items failed: %v", e.Op, len(e.Items)+len(e.IDs), e.Err)
+}
+
+func (e *WriteBackError[T, ID]) Unwrap() error {
+	return e.Err
+}
+
+// flushRequest asks the worker to write the queued writes; ack receives the
+// failures since the last reporting flush if report is set, nil otherwise
+type flushRequest struct {
+	ack    chan error
+	report bool
+}
+
+// writeBackOp is a write waiting for base
+type writeBackOp[T any, ID comparable] struct {
+	op   string
+	item T  // unset for deletes
+	id   ID // deleted ID, or ID of the item when known
+}
+
+// writeBackQueue flushes writes to base in order, batching consecutive
+// writes of the same kind
+type writeBackQueue[T any, ID comparable] struct {
+	base Repository[T, ID]
+	opts WriteBackOptions
+
+	mu        sync.Mutex
+	closed    bool
+	closing   chan struct{}  // closed by Close
+	enqueuers sync.WaitGroup // reservations not yet put or canceled, see reserve
+	queued    int            // writes reserved or in ops, at most QueueSize
+	freed     chan struct{}  // closed when the worker takes writes off ops, if waited on
+
+	ops     chan writeBackOp[T, ID]
+	flushes chan flushRequest
+	writes  context.Context    // parent of the writes to base, see abort
+	abort   context.CancelFunc // cancels writes when Close gives up waiting
+	done    chan struct{}      // closed when the worker exited
+
+	failures []error // writes failed since the last flush, owned by the worker
+}
+
+// SetWriteBack configures the flush worker used by CacheStrategyWriteBack,
+// which starts with the default options on the first write otherwise. It
+// must be called before the first write.
+func (r *CachedRepository[T, ID]) SetWriteBack(opts WriteBackOptions) error {
+	if opts.QueueSize < 0 || opts.BatchSize < 0 || opts.FlushInterval < 0 || opts.Backoff < 0 {
+		return fmt.Errorf("write-back options cannot be negative")
+	}
+	if r.strategy != CacheStrategyWriteBack {
+		return fmt.Errorf("write-back options need %s", CacheStrategyWriteBack)
+	}
+	r.writeBackOnce.Do(func() {
+		r.writeBack = newWriteBackQueue(r.base, opts)
+	})
+	return nil
+}
+
+// queue returns the write-back queue, starting it with the default options
+// if SetWriteBack was not called
+func (r *CachedRepository[T, ID]) queue() *writeBackQueue[T, ID] {
+	r.writeBackOnce.Do(func() {
+		r.writeBack = newWriteBackQueue(r.base, WriteBackOptions{})
+	})
+	return r.writeBack
+}
+
+// Flush waits until the writes queued by CacheStrategyWriteBack before the
+// call reached base, or failed for good, and returns the *WriteBackErrors of
+// the writes that failed since the previous Flush. It returns at once for
+// other strategies.
+func (r *CachedRepository[T, ID]) Flush(ctx context.Context) error {
+	if r.strategy != CacheStrategyWriteBack {
+		return nil
+	}
+	return r.queue().flush(ctx, true)
+}
+
+// drainWriteBack waits until the writes queued by CacheStrategyWriteBack
+// reached base or failed, leaving their failures to Flush
+func (r *CachedRepository[T, ID]) drainWriteBack(ctx context.Context) error {
+	if r.strategy != CacheStrategyWriteBack {
+		return nil
+	}
+	return r.queue().flush(ctx, false)
+}
+
+// Close stops accepting writes and waits until the queued ones are flushed,
+// or ctx is done; writes still queued then are reported to OnError. Close
+// is a no-op for strategies other than CacheStrategyWriteBack.
+func (r *CachedRepository[T, ID]) Close(ctx context.Context) error {
+	if r.strategy != CacheStrategyWriteBack {
+		return nil
+	}
+	return r.queue().close(ctx)
+}
+
+func newWriteBackQueue[T any, ID comparable](base Repository[T, ID], opts WriteBackOptions) *writeBackQueue[T, ID] {
+	if opts.QueueSize == 0 {
+		opts.QueueSize = DefaultWriteBackQueueSize
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = DefaultWriteBackBatchSize
+	}
+	if opts.FlushInterval == 0 {
+		opts.FlushInterval = DefaultWriteBackInterval
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultWriteBackRetries
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = DefaultWriteBackBackoff
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = DefaultWriteBackTimeout
+	}
+	q := &writeBackQueue[T, ID]{
+		base:    base,
+		opts:    opts,
+		closing: make(chan struct{}),
+		ops:     make(chan writeBackOp[T, ID], opts.QueueSize),
+		flushes: make(chan flushRequest),
+		done:    make(chan struct{}),
+	}
+	q.writes, q.abort = context.WithCancel(context.Background())
+	go q.run()
+	return q
+}
+
+// reserve makes room in the queue for n writes, blocking while it is full
+// until the worker catches up, ctx is done or the queue is closed. Writes
+// are reserved before the cache is written, so a write either fails
+// without effect or is queued whole. The reservation must end with put or
+// cancel. The lock is not held while blocking, so Close never waits for a
+// full queue.
+func (q *writeBackQueue[T, ID]) reserve(ctx context.Context, n int) error {
+	if n > q.opts.QueueSize {
+		return fmt.Errorf("%d writes exceed the write-back queue size of %d", n, q.opts.QueueSize)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if q.closed {
+			return ErrRepositoryClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if q.queued+n <= q.opts.QueueSize {
+			q.queued += n
+			q.enqueuers.Add(1)
+			return nil
+		}
+
+		if q.freed == nil {
+			q.freed = make(chan struct{})
+		}
+		freed := q.freed
+		q.mu.Unlock()
+		select {
+		case <-freed:
+		case <-q.closing:
+		case <-ctx.Done():
+		}
+		q.mu.Lock()
+	}
+}
+
+// put queues the writes of a reservation; the reserved room keeps it from
+// blocking
+func (q *writeBackQueue[T, ID]) put(ops ...writeBackOp[T, ID]) {
+	defer q.enqueuers.Done()
+	for _, op := range ops {
+		q.ops <- op
+	}
+}
+
+// cancel gives back the room of a reservation of n writes
+func (q *writeBackQueue[T, ID]) cancel(n int) {
+	defer q.enqueuers.Done()
+	q.release(n)
+}
+
+// release frees the room of n writes, waking the writers waiting for it
+func (q *writeBackQueue[T, ID]) release(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.queued -= n
+	if q.freed != nil {
+		close(q.freed)
+		q.freed = nil
+	}
+}
+
+func (q *writeBackQueue[T, ID]) flush(ctx context.Context, report bool) error {
+	ack := make(chan error, 1)
+	select {
+	case q.flushes <- flushRequest{ack: ack, report: report}:
+	case <-q.done:
+		return ErrRepositoryClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-ack:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *writeBackQueue[T, ID]) close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.closing)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		q.abort()
+		<-q.done
+		return ctx.Err()
+	}
+}
+
+// run is the flush worker: it collects writes until a batch is full, the
+// interval passed, a flush is requested or the queue is closed
+func (q *writeBackQueue[T, ID]) run() {
+	defer close(q.done)
+	ticker := time.NewTicker(q.opts.FlushInterval)
+	defer ticker.Stop()
+
+	var pending []writeBackOp[T, ID]
+	for {
+		select {
+		case op := <-q.ops:
+			q.release(1)
+			pending = append(pending, op)
+			if len(pending) >= q.opts.BatchSize {
+				q.write(pending)
+				pending = nil
+			}
+		case <-ticker.C:
+			q.write(pending)
+			pending = nil
+		case req := <-q.flushes:
+			// writes queued before the request
+			q.write(q.drain(pending))
+			pending = nil
+			if req.report {
+				req.ack <- errors.Join(q.failures...)
+				q.failures = nil
+			} else {
+				req.ack <- nil
+			}
+		case <-q.closing:
+			// reservations fail once closing, then nothing is put anymore
+			q.enqueuers.Wait()
+			q.write(q.drain(pending))
+			return
+		}
+	}
+}
+
+// drain appends the queued writes to pending
+func (q *writeBackQueue[T, ID]) drain(pending []writeBackOp[T, ID]) []writeBackOp[T, ID] {
+	for {
+		select {
+		case op := <-q.ops:
+			q.release(1)
+			pending = append(pending, op)
+		default:
+			return pending
+		}
+	}
+}
+
+// write flushes ops in order, one batch per run of writes of the same kind
+func (q *writeBackQueue[T, ID]) write(ops []writeBackOp[T, ID]) {
+	for start := 0; start < len(ops); {
+		end := start + 1
+		for end < len(ops) && end-start < q.opts.BatchSize && ops[end].op == ops[start].op {
+			end++
+		}
+		q.writeBatch(ops[start:end])
+		start = end
+	}
+}
+
+// writeBatch writes ops of one kind, retrying with backoff. If the batch
+// fails for good because of some of its writes, they are written one by one
+// so only the failing ones are reported.
+func (q *writeBackQueue[T, ID]) writeBatch(ops []writeBackOp[T, ID]) {
+	batch := &WriteBackError[T, ID]{Op: ops[0].op}
+	for _, op := range ops {
+		if op.op == "Delete" {
+			batch.IDs = append(batch.IDs, op.id)
+		} else {
+			batch.Items = append(batch.Items, op.item)
+		}
+	}
+
+	batch.Err = q.retry(batch)
+	if len(ops) > 1 && permanentWriteError(batch.Err) {
+		for i := range ops {
+			q.writeBatch(ops[i : i+1])
+		}
+		return
+	}
+	if batch.Err != nil {
+		q.failures = append(q.failures, batch)
+		if q.opts.OnError != nil {
+			q.opts.OnError(batch)
+		}
+	}
+}
+
+// retry applies batch until it succeeds, fails for good or the retries run
+// out, and returns its last error
+func (q *writeBackQueue[T, ID]) retry(batch *WriteBackError[T, ID]) error {
+	backoff := q.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		if q.writes.Err() != nil {
+			return ErrRepositoryClosed
+		}
+		err := q.apply(batch)
+		if err == nil || permanentWriteError(err) || attempt >= q.opts.MaxRetries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-q.writes.Done():
+		}
+	}
+}
+
+// apply runs the writes of batch on base, bounded by the write timeout and
+// canceled if Close gives up. Items are copied, as base may change them.
+func (q *writeBackQueue[T, ID]) apply(batch *WriteBackError[T, ID]) error {
+	ctx, cancel := context.WithTimeout(q.writes, q.opts.WriteTimeout)
+	defer cancel()
+
+	if batch.Op == "Delete" {
+		if len(batch.IDs) == 1 {
+			return q.base.Delete(ctx, batch.IDs[0])
+		}
+		return q.base.BatchDelete(ctx, batch.IDs)
+	}
+
+	items := slices.Clone(batch.Items)
+	switch batch.Op {
+	case "Create":
+		if len(items) == 1 {
+			return q.base.Create(ctx, &items[0])
+		}
+		return q.base.BatchCreate(ctx, items)
+	case "Update":
+		if len(items) == 1 {
+			return q.base.Update(ctx, &items[0])
+		}
+		return q.base.BatchUpdate(ctx, items)
+	default:
+		if len(items) == 1 {
+			return q.base.Upsert(ctx, &items[0])
+		}
+		return q.base.BatchUpsert(ctx, items)
+	}
+}
+
+// permanentWriteError reports whether retrying a write failing with err is
+// pointless
+func permanentWriteError(err error) bool {
+	for _, target := range []error{ErrItemAlreadyExists, ErrNoUpdateItem, ErrNoDeleteItem, ErrConstraintViolation, ErrVersionConflict, ErrValidation} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBehindItem is writeBehind for a single item
+func (r *CachedRepository[T, ID]) writeBehindItem(ctx context.Context, op string, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	return r.writeBehind(ctx, op, []T{*item})
+}
+
+// writeBehind writes items to the cache, which serves them until they are
+// flushed, and queues their write to base
+func (r *CachedRepository[T, ID]) writeBehind(ctx context.Context, op string, items []T) error {
+	q := r.queue()
+	if err := q.reserve(ctx, len(items)); err != nil {
+		return err
+	}
+	if err := r.cache.BatchUpsert(ctx, items); err != nil {
+		q.cancel(len(items))
+		return err
+	}
+	ops := make([]writeBackOp[T, ID], len(items))
+	for i := range items {
+		ops[i] = writeBackOp[T, ID]{op: op, item: items[i]}
+	}
+	q.put(ops...)
+	r.writtenBatch(ctx, items)
+	return nil
+}
+
+// deleteBehind deletes ids from the cache and queues their deletion in base
+func (r *CachedRepository[T, ID]) deleteBehind(ctx context.Context, ids []ID) error {
+	q := r.queue()
+	if err := q.reserve(ctx, len(ids)); err != nil {
+		return err
+	}
+	if err := r.deleteCached(ctx, ids); err != nil {
+		q.cancel(len(ids))
+		return err
+	}
+	ops := make([]writeBackOp[T, ID], len(ids))
+	for i, id := range ids {
+		ops[i] = writeBackOp[T, ID]{op: "Delete", id: id}
+	}
+	q.put(ops...)
+	r.deleted(ctx, ids...)
+	return nil
+}
+
+// deleteCached deletes ids from the cache, some of which may not be cached
+func (r *CachedRepository[T, ID]) deleteCached(ctx context.Context, ids []ID) error {
+	if err := r.cache.BatchDelete(ctx, ids); isMissingError(err) {
+		// some were not cached; caches may stop at the first one
+		for _, id := range ids {
+			if err := r.cache.Delete(ctx, id); err != nil && !isMissingError(err) {
+				return err
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// isMissingError reports whether err is a delete of items that do not exist
+func isMissingError(err error) bool {
+	return errors.Is(err, ErrItemNotFound) || errors.Is(err, ErrNoDeleteItem)
+}
@@ -0,0 +1,46 @@
+// Command sietchgen generates typed repository facades from a JSON spec.
+//
+// Usage:
+//
+//	//go:generate go run github.com/seb7887/gofw/sietch/cmd/sietchgen -spec account_facade.json -out account_facade_gen.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/seb7887/gofw/sietch/gen"
+)
+
+func main() {
+	specPath := flag.String("spec", "", "path to the JSON facade spec")
+	outPath := flag.String("out", "", "output file (default: stdout)")
+	flag.Parse()
+
+	if *specPath == "" {
+		fmt.Fprintln(os.Stderr, "sietchgen: -spec is required")
+		os.Exit(2)
+	}
+
+	spec, err := gen.LoadSpec(*specPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sietchgen: %v\n", err)
+		os.Exit(1)
+	}
+
+	src, err := gen.Generate(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sietchgen: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *outPath == "" {
+		_, _ = os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*outPath, src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "sietchgen: %v\n", err)
+		os.Exit(1)
+	}
+}
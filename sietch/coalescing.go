@@ -0,0 +1,203 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CoalescingRepository wraps a repository so that concurrent Gets and GetManys
+// for the same ID share a single call to the base repository. This keeps a
+// burst of identical primary-key lookups (e.g. during a cache cold start) from
+// reaching the database as hundreds of queries. All other methods delegate to
+// the base.
+//
+// Each caller receives its own shallow copy of the item: callers may set its
+// fields freely, but slices, maps and pointers are shared with the other
+// callers of the same call and must not be modified in place.
+type CoalescingRepository[T any, ID comparable] struct {
+	Repository[T, ID]
+
+	mu          sync.Mutex
+	inflight    map[ID]*getCall[T]
+	loadTimeout time.Duration
+}
+
+// getCall is an in-flight Get shared by every caller asking for the same ID
+type getCall[T any] struct {
+	done chan struct{}
+	item *T
+	err  error
+}
+
+// NewCoalescingRepository creates a repository that coalesces concurrent Gets
+func NewCoalescingRepository[T any, ID comparable](base Repository[T, ID]) *CoalescingRepository[T, ID] {
+	return &CoalescingRepository[T, ID]{
+		Repository:  base,
+		inflight:    make(map[ID]*getCall[T]),
+		loadTimeout: DefaultLoadTimeout,
+	}
+}
+
+// SetLoadTimeout bounds the shared calls to the base repository. They outlive
+// the caller that started them, so without a bound a stuck call would hold
+// its IDs, and every caller joining it, forever. Zero leaves them unbounded.
+func (r *CoalescingRepository[T, ID]) SetLoadTimeout(timeout time.Duration) {
+	r.loadTimeout = timeout
+}
+
+// Get returns the item with the given ID, joining an in-flight Get for the same
+// ID if there is one. The shared call runs with the first caller's context
+// values but not its cancellation, so one caller giving up does not fail the
+// others, and is bounded by the load timeout; every caller still stops
+// waiting when its own context is done. Gets inside a transaction bypass
+// coalescing.
+func (r *CoalescingRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	if inTransaction(ctx) {
+		return r.Repository.Get(ctx, id)
+	}
+
+	r.mu.Lock()
+	call, ok := r.inflight[id]
+	if !ok {
+		call = &getCall[T]{done: make(chan struct{})}
+		r.inflight[id] = call
+		go r.execute(ctx, id, call)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	if call.item == nil {
+		return nil, nil
+	}
+	item := *call.item
+	return &item, nil
+}
+
+// GetMany returns the items with the given IDs, joining the in-flight Gets
+// and GetManys for any of them and fetching the others from the base in a
+// single call shared the same way as Gets. GetManys inside a transaction
+// bypass coalescing.
+func (r *CoalescingRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	if inTransaction(ctx) {
+		return r.Repository.GetMany(ctx, ids)
+	}
+
+	calls := make(map[ID]*getCall[T], len(ids))
+	var missing []ID
+	r.mu.Lock()
+	for _, id := range ids {
+		if _, ok := calls[id]; ok {
+			continue
+		}
+		call, ok := r.inflight[id]
+		if !ok {
+			call = &getCall[T]{done: make(chan struct{})}
+			r.inflight[id] = call
+			missing = append(missing, id)
+		}
+		calls[id] = call
+	}
+	r.mu.Unlock()
+
+	if len(missing) > 0 {
+		started := make(map[ID]*getCall[T], len(missing))
+		for _, id := range missing {
+			started[id] = calls[id]
+		}
+		go r.executeMany(ctx, started)
+	}
+
+	results := make(map[ID]*T, len(calls))
+	for id, call := range calls {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if isNotFound(call.err) {
+			continue
+		}
+		if call.err != nil {
+			return nil, call.err
+		}
+		if call.item != nil {
+			item := *call.item
+			results[id] = &item
+		}
+	}
+	return results, nil
+}
+
+// loadContext returns the context of a shared call started by a caller with
+// ctx: its values without its cancellation, bounded by the load timeout
+func (r *CoalescingRepository[T, ID]) loadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = context.WithoutCancel(ctx)
+	if r.loadTimeout > 0 {
+		return context.WithTimeout(ctx, r.loadTimeout)
+	}
+	return ctx, func() {}
+}
+
+func (r *CoalescingRepository[T, ID]) execute(ctx context.Context, id ID, call *getCall[T]) {
+	ctx, cancel := r.loadContext(ctx)
+	defer cancel()
+	defer func() {
+		if p := recover(); p != nil {
+			call.item, call.err = nil, fmt.Errorf("get panicked: %v", p)
+		}
+		r.mu.Lock()
+		delete(r.inflight, id)
+		r.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.item, call.err = r.Repository.Get(ctx, id)
+}
+
+// executeMany fetches the IDs of calls from the base in one GetMany; the IDs
+// it does not return are not found
+func (r *CoalescingRepository[T, ID]) executeMany(ctx context.Context, calls map[ID]*getCall[T]) {
+	ctx, cancel := r.loadContext(ctx)
+	defer cancel()
+
+	var items map[ID]*T
+	var err error
+	defer func() {
+		if p := recover(); p != nil {
+			items, err = nil, fmt.Errorf("get many panicked: %v", p)
+		}
+		r.mu.Lock()
+		for id, call := range calls {
+			switch item, ok := items[id]; {
+			case err != nil:
+				call.err = err
+			case ok && item != nil:
+				call.item = item
+			default:
+				call.err = ErrItemNotFound
+			}
+			delete(r.inflight, id)
+		}
+		r.mu.Unlock()
+		for _, call := range calls {
+			close(call.done)
+		}
+	}()
+
+	ids := make([]ID, 0, len(calls))
+	for id := range calls {
+		ids = append(ids, id)
+	}
+	items, err = r.Repository.GetMany(ctx, ids)
+}
@@ -0,0 +1,244 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+// slowGetRepository counts Gets and GetManys and blocks them until release
+// is closed or their context is done
+type slowGetRepository struct {
+	*InMemoryConnector[testutils.Account, int64]
+	calls     atomic.Int32
+	manyCalls atomic.Int32
+	manyIDs   chan []int64
+	release   chan struct{}
+	getErr    error // returned by Gets once released, if set
+}
+
+func (r *slowGetRepository) Get(ctx context.Context, id int64) (*testutils.Account, error) {
+	r.calls.Add(1)
+	select {
+	case <-r.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if r.getErr != nil {
+		return nil, r.getErr
+	}
+	return r.InMemoryConnector.Get(ctx, id)
+}
+
+func (r *slowGetRepository) GetMany(ctx context.Context, ids []int64) (map[int64]*testutils.Account, error) {
+	r.manyCalls.Add(1)
+	r.manyIDs <- ids
+	select {
+	case <-r.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return r.InMemoryConnector.GetMany(ctx, ids)
+}
+
+func newSlowGetRepository(t *testing.T) *slowGetRepository {
+	base := NewInMemoryConnector[testutils.Account, int64](func(a *testutils.Account) int64 { return a.ID })
+	for _, account := range []*testutils.Account{{ID: 1, Balance: 100}, {ID: 2, Balance: 200}} {
+		if err := base.Create(context.Background(), account); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	return &slowGetRepository{InMemoryConnector: base, manyIDs: make(chan []int64, 10), release: make(chan struct{})}
+}
+
+func TestCoalescingRepository_Get(t *testing.T) {
+	base := newSlowGetRepository(t)
+	repo := NewCoalescingRepository[testutils.Account, int64](base)
+
+	const callers = 50
+	var wg sync.WaitGroup
+	results := make([]*testutils.Account, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = repo.Get(context.Background(), 1)
+		}(i)
+	}
+
+	// Let every caller join the in-flight call before releasing it
+	time.Sleep(50 * time.Millisecond)
+	close(base.release)
+	wg.Wait()
+
+	if n := base.calls.Load(); n != 1 {
+		t.Errorf("Expected 1 base Get, got %d", n)
+	}
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil || results[i] == nil || results[i].Balance != 100 {
+			t.Fatalf("caller %d: unexpected result %+v, %v", i, results[i], errs[i])
+		}
+	}
+
+	// Each caller gets its own copy
+	results[0].Balance = 0
+	if results[1].Balance != 100 {
+		t.Error("callers share the same item")
+	}
+
+	// Later calls are not coalesced with completed ones
+	if _, err := repo.Get(context.Background(), 1); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if n := base.calls.Load(); n != 2 {
+		t.Errorf("Expected 2 base Gets, got %d", n)
+	}
+}
+
+func TestCoalescingRepository_Errors(t *testing.T) {
+	base := newSlowGetRepository(t)
+	close(base.release)
+	repo := NewCoalescingRepository[testutils.Account, int64](base)
+
+	if _, err := repo.Get(context.Background(), 42); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+}
+
+func TestCoalescingRepository_CallerCancellation(t *testing.T) {
+	base := newSlowGetRepository(t)
+	repo := NewCoalescingRepository[testutils.Account, int64](base)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := repo.Get(ctx, 1)
+		leaderErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	follower := make(chan error, 1)
+	go func() {
+		_, err := repo.Get(context.Background(), 1)
+		follower <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for the cancelled caller, got %v", err)
+	}
+
+	close(base.release)
+	if err := <-follower; err != nil {
+		t.Errorf("Follower should not be affected by the first caller's cancellation, got %v", err)
+	}
+	if n := base.calls.Load(); n != 1 {
+		t.Errorf("Expected 1 base Get, got %d", n)
+	}
+}
+
+func TestCoalescingRepository_GetMany(t *testing.T) {
+	base := newSlowGetRepository(t)
+	repo := NewCoalescingRepository[testutils.Account, int64](base)
+
+	getResult := make(chan *testutils.Account, 1)
+	go func() {
+		item, _ := repo.Get(context.Background(), 1)
+		getResult <- item
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// The GetManys join the Get for 1 and share one fetch of the others
+	var wg sync.WaitGroup
+	results := make([]map[int64]*testutils.Account, 5)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = repo.GetMany(context.Background(), []int64{1, 2, 42, 2})
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(base.release)
+	wg.Wait()
+
+	if item := <-getResult; item == nil || item.Balance != 100 {
+		t.Fatalf("Get: unexpected result %+v", item)
+	}
+	if n := base.calls.Load(); n != 1 {
+		t.Errorf("Expected 1 base Get, got %d", n)
+	}
+	if n := base.manyCalls.Load(); n != 1 {
+		t.Fatalf("Expected 1 base GetMany, got %d", n)
+	}
+	ids := <-base.manyIDs
+	if len(ids) != 2 {
+		t.Errorf("Expected the base GetMany to fetch 2 and 42 only, got %v", ids)
+	}
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatalf("caller %d: GetMany failed: %v", i, errs[i])
+		}
+		if len(results[i]) != 2 || results[i][1].Balance != 100 || results[i][2].Balance != 200 {
+			t.Fatalf("caller %d: unexpected results %+v", i, results[i])
+		}
+	}
+
+	// Each caller gets its own copy
+	results[0][2].Balance = 0
+	if results[1][2].Balance != 200 {
+		t.Error("callers share the same item")
+	}
+}
+
+func TestCoalescingRepository_GetManyJoinsMissingGet(t *testing.T) {
+	base := newSlowGetRepository(t)
+	base.getErr = pgx.ErrNoRows // as returned by a bare CockroachDB row scan
+	repo := NewCoalescingRepository[testutils.Account, int64](base)
+
+	go func() { _, _ = repo.Get(context.Background(), 42) }()
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan struct{})
+	var results map[int64]*testutils.Account
+	var err error
+	go func() {
+		defer close(done)
+		results, err = repo.GetMany(context.Background(), []int64{1, 42})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(base.release)
+	<-done
+
+	if err != nil || len(results) != 1 || results[1] == nil {
+		t.Errorf("Expected the missing ID to be left out, got %v (%v)", results, err)
+	}
+}
+
+func TestCoalescingRepository_LoadTimeout(t *testing.T) {
+	base := newSlowGetRepository(t)
+	repo := NewCoalescingRepository[testutils.Account, int64](base)
+	repo.SetLoadTimeout(20 * time.Millisecond)
+
+	// A stuck shared call gives up after the timeout and frees its ID
+	if _, err := repo.Get(context.Background(), 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if _, err := repo.GetMany(context.Background(), []int64{1, 2}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	close(base.release)
+	if item, err := repo.Get(context.Background(), 1); err != nil || item.Balance != 100 {
+		t.Errorf("Get after the timeout: unexpected result %+v, %v", item, err)
+	}
+}
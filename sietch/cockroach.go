@@ -2,10 +2,14 @@ package sietch
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"reflect"
+	"slices"
+	"sort"
 	"strings"
 )
 
@@ -14,6 +18,24 @@ type CockroachDBConnector[T any, ID comparable] struct {
 	tableName string
 	getID     func(*T) ID
 	columns   []string
+	codec     *entityCodec
+	batchSize int            // statements per pgx batch, see SetBatchSize
+	watchdog  *QueryWatchdog // cancels runaway queries, see SetWatchdog
+
+	rowsPerStatement int // rows per BatchCreate INSERT, see SetRowsPerStatement
+
+	statements  *crudStatements // CRUD statements built once per connector
+	usePrepared bool            // run statements by name, see SetUsePreparedStatements
+
+	connects *ConnectCounter // reports pool construct errors, see SetConnectCounter
+
+	softDelete *softDeleteConfig // set for SoftDeletable entities, see SetSoftDeleteOptions
+
+	logger QueryLogger // logs every statement, see SetLogger
+
+	txRetry TxRetryOptions // reruns of serialization failures, see SetTxRetryOptions
+
+	onConflict *upsertConfig // conflict target and columns of upserts, see SetUpsertOptions
 }
 
 func NewCockroachDBConnPool(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
@@ -38,22 +60,25 @@ func quoteIdentifier(name string) string {
 	return `"` + name + `"`
 }
 
-// NewCockroachDBConnector CockroachDB implementation of Repository interface
+// NewCockroachDBConnector CockroachDB implementation of Repository interface.
+// getID may be nil when T has a pk-tagged field of type ID, see PKAccessor.
 func NewCockroachDBConnector[T any, ID comparable](pool *pgxpool.Pool, tableName string, getID func(*T) ID) (*CockroachDBConnector[T, ID], error) {
 	if pool == nil {
 		return nil, fmt.Errorf("pool cannot be nil")
 	}
-	if getID == nil {
-		return nil, fmt.Errorf("getID function cannot be nil")
+	getID, err := resolveGetID(getID)
+	if err != nil {
+		return nil, err
 	}
 	if err := sanitizeIdentifier(tableName); err != nil {
 		return nil, fmt.Errorf("invalid table name: %w", err)
 	}
 	
-	columns, err := getColumns[T]()
+	codec, err := newEntityCodec[T]()
 	if err != nil {
 		return nil, err
 	}
+	columns := codec.columns
 	
 	// Validar nombres de columnas
 	for _, col := range columns {
@@ -62,38 +87,43 @@ func NewCockroachDBConnector[T any, ID comparable](pool *pgxpool.Pool, tableName
 		}
 	}
 
-	return &CockroachDBConnector[T, ID]{
-		pool:      pool,
-		tableName: tableName,
-		getID:     getID,
-		columns:   columns,
-	}, nil
-}
-
-func getColumns[T any]() ([]string, error) {
-	var t T
-	typ := reflect.TypeOf(t)
-	if typ.Kind() == reflect.Ptr {
-		typ = typ.Elem()
+	softDelete, err := newSoftDeleteConfig[T](nil, columns)
+	if err != nil {
+		return nil, err
 	}
-	if typ.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("columns must be a struct")
+	onConflict, err := newUpsertConfig(codec, UpsertOptions{})
+	if err != nil {
+		return nil, err
 	}
 
-	var columns []string
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		tag := field.Tag.Get("db")
-		if tag != "" {
-			columns = append(columns, tag)
-		}
+	r := &CockroachDBConnector[T, ID]{
+		pool:       pool,
+		tableName:  tableName,
+		getID:      getID,
+		columns:    columns,
+		codec:      codec,
+		softDelete: softDelete,
+		onConflict: onConflict,
 	}
+	r.buildStatements()
+	return r, nil
+}
 
-	if len(columns) == 0 {
-		return nil, fmt.Errorf("no columns found")
+// buildStatements precomputes the CRUD statements of the connector
+func (r *CockroachDBConnector[T, ID]) buildStatements() {
+	r.statements = newCRUDStatements(r.tableName, r.codec, r.onConflict)
+	if r.softDelete != nil {
+		r.softDelete.apply(r.statements, r.tableName, r.columns[r.codec.pk])
 	}
+	r.statements.name(r.tableName)
+}
 
-	return columns, nil
+func getColumns[T any]() ([]string, error) {
+	codec, err := newEntityCodec[T]()
+	if err != nil {
+		return nil, err
+	}
+	return codec.columns, nil
 }
 
 func joinColumns(columns []string) string {
@@ -117,44 +147,26 @@ func buildPlaceholders(n int) string {
 }
 
 func (r *CockroachDBConnector[T, ID]) getValues(item *T) ([]any, error) {
-	v := reflect.ValueOf(item)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
-	if v.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("item must be a struct")
-	}
-	typ := v.Type()
-	var values []any
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		tag := field.Tag.Get("db")
-		if tag != "" {
-			values = append(values, v.Field(i).Interface())
-		}
-	}
-	if len(values) != len(r.columns) {
-		return nil, fmt.Errorf("number of values does not match the number of columns")
+	return r.codec.values(reflect.ValueOf(item).Elem())
+}
+
+// getInsertValues returns the values of the columns written by inserts
+func (r *CockroachDBConnector[T, ID]) getInsertValues(item *T) ([]any, error) {
+	values, err := r.getValues(item)
+	if err != nil {
+		return nil, err
 	}
+	return pick(values, r.codec.inserts), nil
+}
 
-	return values, nil
+// getReturnedDestinations returns pointers to the fields of the columns
+// whose values inserts return
+func (r *CockroachDBConnector[T, ID]) getReturnedDestinations(item *T) []any {
+	return pick(r.codec.scanDestinations(reflect.ValueOf(item).Elem()), r.codec.returned)
 }
 
 func (r *CockroachDBConnector[T, ID]) getScanDestinations(ptr *T) ([]any, error) {
-	v := reflect.ValueOf(ptr).Elem()
-	typ := v.Type()
-	var dests []any
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		tag := field.Tag.Get("db")
-		if tag != "" {
-			dests = append(dests, v.Field(i).Addr().Interface())
-		}
-	}
-	if len(dests) != len(r.columns) {
-		return nil, fmt.Errorf("number of values does not match the number of columns")
-	}
-	return dests, nil
+	return r.codec.scanDestinations(reflect.ValueOf(ptr).Elem()), nil
 }
 
 func (r *CockroachDBConnector[T, ID]) Create(ctx context.Context, item *T) error {
@@ -162,96 +174,136 @@ func (r *CockroachDBConnector[T, ID]) Create(ctx context.Context, item *T) error
 		return fmt.Errorf("item cannot be nil")
 	}
 
-	values, err := r.getValues(item)
+	return r.insert(ctx, r.getQueryable(ctx), item)
+}
+
+// insert runs the insert statement for item on q, scanning the values the
+// database generated for the omitted and generated columns back into item
+func (r *CockroachDBConnector[T, ID]) insert(ctx context.Context, q Queryable, item *T) error {
+	values, err := r.getInsertValues(item)
 	if err != nil {
 		return err
 	}
 
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-		quoteIdentifier(r.tableName),
-		joinQuotedColumns(r.columns),
-		buildPlaceholders(len(r.columns)),
-	)
+	if len(r.codec.returned) == 0 {
+		_, err = q.Exec(ctx, r.statement(stmtInsert), values...)
+		return translateWriteError(err)
+	}
+	err = q.QueryRow(ctx, r.statement(stmtInsert), values...).Scan(r.getReturnedDestinations(item)...)
+	return translateWriteError(err)
+}
 
+func (r *CockroachDBConnector[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	var t T
 	queryable := r.getQueryable(ctx)
-	_, err = queryable.Exec(ctx, query, values...)
-
-	// Check for duplicate key error
-	if err != nil && strings.Contains(err.Error(), "duplicate key") {
-		return ErrItemAlreadyExists
+	row := queryable.QueryRow(ctx, r.readStatement(ctx, stmtGet), id)
+	dests, err := r.getScanDestinations(&t)
+	if err != nil {
+		return nil, err
 	}
 
-	return err
+	err = row.Scan(dests...)
+
+	return &t, translateReadError(err)
 }
 
-func (r *CockroachDBConnector[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+// GetForUpdate is Get with SELECT ... FOR UPDATE, for use within a
+// transaction of the TransactionManager (see RowLocker)
+func (r *CockroachDBConnector[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
 	var t T
-	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1",
-		joinQuotedColumns(r.columns),
-		quoteIdentifier(r.tableName),
-		quoteIdentifier(r.columns[0]),
-	)
-
 	queryable := r.getQueryable(ctx)
-	row := queryable.QueryRow(ctx, query, id)
+	row := queryable.QueryRow(ctx, r.readStatement(ctx, stmtGetForUpdate), id)
 	dests, err := r.getScanDestinations(&t)
 	if err != nil {
 		return nil, err
 	}
 
 	err = row.Scan(dests...)
-
-	return &t, err
+	return &t, translateReadError(err)
 }
 
-func (r *CockroachDBConnector[T, ID]) BatchCreate(ctx context.Context, items []T) error {
-	if len(items) == 0 {
-		return nil
+// GetMany fetches the items with the given IDs in a single query.
+// IDs that do not exist are absent from the returned map.
+func (r *CockroachDBConnector[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	if len(ids) == 0 {
+		return map[ID]*T{}, nil
 	}
 
-	tx, err := r.pool.Begin(ctx)
+	query, args, err := r.getManyQuery(ids)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if r.softDelete.hides(ctx) {
+		query += " AND " + r.softDelete.liveSQL()
 	}
 
-	defer func() {
+	queryable := r.getQueryable(ctx)
+	rows, err := queryable.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanByID(rows)
+}
+
+// getManyQuery builds SELECT ... WHERE id IN (...) for the distinct IDs
+func (r *CockroachDBConnector[T, ID]) getManyQuery(ids []ID) (string, []any, error) {
+	seen := make(map[ID]struct{}, len(ids))
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		value, err := DefaultConverters.normalizeValue(id)
 		if err != nil {
-			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-				// TODO: Log rollback error: rollbackErr
-			}
-		} else {
-			if commitErr := tx.Commit(ctx); commitErr != nil {
-				// TODO: Log commit error: commitErr
-				err = commitErr // Set error so it gets returned
-			}
+			return "", nil, fmt.Errorf("id %v: %w", id, err)
 		}
-	}()
+		args = append(args, value)
+	}
 
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-		quoteIdentifier(r.tableName),
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IN (%s)",
 		joinQuotedColumns(r.columns),
-		buildPlaceholders(len(r.columns)),
+		quoteIdentifier(r.tableName),
+		quoteIdentifier(r.columns[r.codec.pk]),
+		buildPlaceholders(len(args)),
 	)
+	return query, args, nil
+}
 
-	for _, item := range items {
-		values, err := r.getValues(&item)
+// scanByID scans full rows into a map keyed by each item's ID
+func (r *CockroachDBConnector[T, ID]) scanByID(rows pgx.Rows) (map[ID]*T, error) {
+	results := make(map[ID]*T)
+	for rows.Next() {
+		var item T
+		dests, err := r.getScanDestinations(&item)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		_, err = tx.Exec(ctx, query, values...)
-		if err != nil {
-			return err
+		if err := rows.Scan(dests...); err != nil {
+			return nil, err
 		}
+		results[r.getID(&item)] = &item
 	}
+	return results, rows.Err()
+}
 
-	return nil
+func (r *CockroachDBConnector[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	return r.batchTx(ctx, func(tx pgx.Tx) error {
+		return r.batchCreate(ctx, tx, items)
+	})
 }
 
 func (r *CockroachDBConnector[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
 	if filter == nil {
 		return nil, fmt.Errorf("filter cannot be nil")
 	}
-	query, args, err := r.queryBuilder(filter)
+	query, args, err := r.queryBuilder(r.softDelete.scope(ctx, filter))
 	if err != nil {
 		return nil, err
 	}
@@ -263,6 +315,11 @@ func (r *CockroachDBConnector[T, ID]) Query(ctx context.Context, filter *Filter)
 	}
 	defer rows.Close()
 
+	// Grouped queries select a subset of the columns
+	if len(filter.GroupBy) > 0 {
+		return scanRowsByName[T](rows)
+	}
+
 	var results []T
 	for rows.Next() {
 		var item T
@@ -279,11 +336,17 @@ func (r *CockroachDBConnector[T, ID]) Query(ctx context.Context, filter *Filter)
 	return results, rows.Err()
 }
 
+// FindOne returns the first row matching the filter, or ErrItemNotFound
+func (r *CockroachDBConnector[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	return findOne(ctx, filter, r.Query)
+}
+
 // Count returns the number of items matching the filter
 func (r *CockroachDBConnector[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
 	if filter == nil {
 		return 0, fmt.Errorf("filter cannot be nil")
 	}
+	filter = r.softDelete.scope(ctx, filter)
 
 	var args []any
 	argIndex := 1
@@ -311,164 +374,159 @@ func (r *CockroachDBConnector[T, ID]) Update(ctx context.Context, item *T) error
 		return fmt.Errorf("item cannot be nil")
 	}
 
-	values, err := r.getValues(item)
-	if err != nil {
-		return err
-	}
+	return r.update(ctx, r.getQueryable(ctx), item)
+}
 
-	var setClause []string
-	numCols := len(r.columns)
-	for i := 1; i < numCols; i++ {
-		setClause = append(setClause, fmt.Sprintf("%s = $%d", quoteIdentifier(r.columns[i]), i))
+func (r *CockroachDBConnector[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	if len(items) == 0 {
+		return nil
 	}
 
-	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d",
-		quoteIdentifier(r.tableName),
-		strings.Join(setClause, ", "),
-		quoteIdentifier(r.columns[0]),
-		numCols,
-	)
-
-	id := r.getID(item)
-	args := append(values[1:], id)
+	return r.batchTx(ctx, func(tx pgx.Tx) error {
+		return r.batchUpdate(ctx, tx, items)
+	})
+}
 
+func (r *CockroachDBConnector[T, ID]) Delete(ctx context.Context, id ID) error {
 	queryable := r.getQueryable(ctx)
-	ct, err := queryable.Exec(ctx, query, args...)
+	ct, err := queryable.Exec(ctx, r.statement(stmtDelete), id)
 	if err != nil {
-		return err
+		return translateWriteError(err)
 	}
 
 	if ct.RowsAffected() == 0 {
-		return ErrNoUpdateItem
+		return ErrNoDeleteItem
 	}
 
 	return nil
 }
 
-func (r *CockroachDBConnector[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+func (r *CockroachDBConnector[T, ID]) BatchDelete(ctx context.Context, items []ID) error {
 	if len(items) == 0 {
 		return nil
 	}
 
-	tx, err := r.pool.Begin(ctx)
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		if err != nil {
-			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-				// TODO: Log rollback error: rollbackErr
-			}
-		} else {
-			if commitErr := tx.Commit(ctx); commitErr != nil {
-				// TODO: Log commit error: commitErr
-				err = commitErr // Set error so it gets returned
-			}
-		}
-	}()
-
-	numCols := len(r.columns)
-	var setClauses []string
-	for i := 1; i < numCols; i++ {
-		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", quoteIdentifier(r.columns[i]), i))
-	}
-
-	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d",
-		quoteIdentifier(r.tableName),
-		strings.Join(setClauses, ", "),
-		quoteIdentifier(r.columns[0]),
-		numCols,
-	)
+	return r.batchTx(ctx, func(tx pgx.Tx) error {
+		return r.batchDelete(ctx, tx, items)
+	})
+}
 
-	_, err = tx.Prepare(ctx, "batch_update_stmt", query)
+// UpdateWhere sets the given columns on every row matching the filter conditions in one statement.
+// Versioned rows get their version incremented, so concurrent versioned updates conflict.
+func (r *CockroachDBConnector[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	query, args, err := r.updateWhereQuery(filter, updates)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	for _, item := range items {
-		values, err := r.getValues(&item)
-		if err != nil {
-			return err
-		}
-
-		id := r.getID(&item)
-		args := append(values[1:], id)
-		ct, err := tx.Exec(ctx, "batch_update_stmt", args...)
-		if err != nil {
-			return err
-		}
-
-		if ct.RowsAffected() == 0 {
-			return fmt.Errorf("batch update item %v does not exist", item)
-		}
+	queryable := r.getQueryable(ctx)
+	ct, err := queryable.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, translateWriteError(err)
 	}
-
-	return nil
+	return ct.RowsAffected(), nil
 }
 
-func (r *CockroachDBConnector[T, ID]) Delete(ctx context.Context, id ID) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1",
-		quoteIdentifier(r.tableName),
-		quoteIdentifier(r.columns[0]),
-	)
-
-	queryable := r.getQueryable(ctx)
-	ct, err := queryable.Exec(ctx, query, id)
+// DeleteWhere deletes every row matching the filter conditions in one statement
+func (r *CockroachDBConnector[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	query, args, err := r.deleteWhereQuery(filter)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if ct.RowsAffected() == 0 {
-		return ErrNoDeleteItem
+	queryable := r.getQueryable(ctx)
+	ct, err := queryable.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, translateWriteError(err)
 	}
-
-	return nil
+	return ct.RowsAffected(), nil
 }
 
-func (r *CockroachDBConnector[T, ID]) BatchDelete(ctx context.Context, items []ID) error {
-	if len(items) == 0 {
-		return nil
+// updateWhereQuery builds UPDATE ... SET ... WHERE ...; columns are set in
+// sorted order so identical updates produce identical statements
+func (r *CockroachDBConnector[T, ID]) updateWhereQuery(filter *Filter, updates map[string]any) (string, []any, error) {
+	if err := validateBulkFilter(filter); err != nil {
+		return "", nil, err
+	}
+	if len(updates) == 0 {
+		return "", nil, fmt.Errorf("updates cannot be empty")
 	}
 
-	tx, err := r.pool.Begin(ctx)
-	if err != nil {
-		return err
+	columns := make([]string, 0, len(updates))
+	for col := range updates {
+		columns = append(columns, col)
 	}
+	sort.Strings(columns)
 
-	defer func() {
+	var setClauses []string
+	var args []any
+	argIndex := 1
+	for _, col := range columns {
+		if err := r.validateFilterField(col); err != nil {
+			return "", nil, err
+		}
+		i := slices.Index(r.columns, col)
+		if r.codec.flags[i]&colReadonly != 0 {
+			return "", nil, fmt.Errorf("column '%s' is read-only", col)
+		}
+		if i == r.codec.version {
+			return "", nil, fmt.Errorf("column '%s' is the version column", col)
+		}
+		value, err := DefaultConverters.normalizeValue(updates[col])
 		if err != nil {
-			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-				// TODO: Log rollback error: rollbackErr
-			}
-		} else {
-			if commitErr := tx.Commit(ctx); commitErr != nil {
-				// TODO: Log commit error: commitErr
-				err = commitErr // Set error so it gets returned
-			}
+			return "", nil, fmt.Errorf("field %s: %w", col, err)
 		}
-	}()
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", quoteIdentifier(col), argIndex))
+		args = append(args, value)
+		argIndex++
+	}
+	if r.codec.version >= 0 {
+		// versioned updates of the matched rows conflict with this one
+		v := quoteIdentifier(r.columns[r.codec.version])
+		setClauses = append(setClauses, fmt.Sprintf("%s = %s + 1", v, v))
+	}
 
-	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1",
+	whereClause, whereArgs, err := r.buildWhereClause(r.liveConditions(filter.Conditions), &argIndex)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s",
 		quoteIdentifier(r.tableName),
-		quoteIdentifier(r.columns[0]),
+		strings.Join(setClauses, ", "),
+		whereClause,
 	)
-	_, err = tx.Prepare(ctx, "batch_delete_stmt", query)
+	return query, append(args, whereArgs...), nil
+}
+
+// deleteWhereQuery builds DELETE FROM ... WHERE ..., or an UPDATE of the
+// soft delete columns for soft-deletable entities
+func (r *CockroachDBConnector[T, ID]) deleteWhereQuery(filter *Filter) (string, []any, error) {
+	if err := validateBulkFilter(filter); err != nil {
+		return "", nil, err
+	}
+
+	argIndex := 1
+	whereClause, args, err := r.buildWhereClause(r.liveConditions(filter.Conditions), &argIndex)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
-	for _, id := range items {
-		ct, err := tx.Exec(ctx, "batch_delete_stmt", id)
-		if err != nil {
-			return err
-		}
-		if ct.RowsAffected() == 0 {
-			return fmt.Errorf("%v row not deleted", id)
-		}
+	if r.softDelete != nil {
+		query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", quoteIdentifier(r.tableName), r.softDelete.setSQL(true), whereClause)
+		return query, args, nil
 	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", quoteIdentifier(r.tableName), whereClause)
+	return query, args, nil
+}
 
-	return nil
+// liveConditions adds the condition skipping soft-deleted rows to the
+// conditions of a bulk write
+func (r *CockroachDBConnector[T, ID]) liveConditions(conditions []Condition) []Condition {
+	if r.softDelete == nil {
+		return conditions
+	}
+	return append(slices.Clip(conditions), r.softDelete.live())
 }
 
 // validateFilterField checks if a field exists in the known columns
@@ -489,6 +547,16 @@ func (r *CockroachDBConnector[T, ID]) validateFilterField(field string) error {
 }
 
 func (r *CockroachDBConnector[T, ID]) queryBuilder(filter *Filter) (string, []any, error) {
+	columns := r.columns
+	if filter != nil && len(filter.GroupBy) > 0 {
+		// Grouped queries return the grouped columns only
+		columns = filter.GroupBy
+	}
+	return r.selectQuery(filter, columns)
+}
+
+// selectQuery builds a SELECT of the given columns with the filter's clauses
+func (r *CockroachDBConnector[T, ID]) selectQuery(filter *Filter, columns []string) (string, []any, error) {
 	var args []any
 	argIndex := 1
 
@@ -497,7 +565,7 @@ func (r *CockroachDBConnector[T, ID]) queryBuilder(filter *Filter) (string, []an
 	if filter != nil && filter.Distinct {
 		selectClause += "DISTINCT "
 	}
-	selectClause += joinQuotedColumns(r.columns)
+	selectClause += joinQuotedColumns(columns)
 
 	query := selectClause + " FROM " + quoteIdentifier(r.tableName)
 
@@ -511,9 +579,21 @@ func (r *CockroachDBConnector[T, ID]) queryBuilder(filter *Filter) (string, []an
 		args = append(args, whereArgs...)
 	}
 
+	// Build GROUP BY / HAVING clauses
+	if filter != nil && len(filter.GroupBy) > 0 {
+		groupClause, groupArgs, err := r.buildGroupByClause(filter, &argIndex)
+		if err != nil {
+			return "", nil, err
+		}
+		query += " " + groupClause
+		args = append(args, groupArgs...)
+	} else if filter != nil && len(filter.Having) > 0 {
+		return "", nil, fmt.Errorf("HAVING requires GROUP BY")
+	}
+
 	// Build ORDER BY clause
 	if filter != nil && len(filter.Sort) > 0 {
-		orderByClause, err := r.buildOrderByClause(filter.Sort)
+		orderByClause, err := r.buildOrderByClause(filter.Sort, filter.GroupBy)
 		if err != nil {
 			return "", nil, err
 		}
@@ -530,6 +610,14 @@ func (r *CockroachDBConnector[T, ID]) queryBuilder(filter *Filter) (string, []an
 		query += fmt.Sprintf(" OFFSET %d", *filter.Offset)
 	}
 
+	// Add row locking clause
+	if filter != nil && filter.Lock != LockNone {
+		if filter.Lock != LockForUpdate && filter.Lock != LockForShare {
+			return "", nil, fmt.Errorf("unsupported lock mode %q", filter.Lock)
+		}
+		query += " " + string(filter.Lock)
+	}
+
 	return query, args, nil
 }
 
@@ -561,19 +649,47 @@ func (r *CockroachDBConnector[T, ID]) buildConditionClause(condition Condition,
 }
 
 func (r *CockroachDBConnector[T, ID]) buildLeafCondition(condition Condition, argIndex *int) (string, []any, error) {
+	if condition.Operator == OpExists {
+		return buildExistsCondition(quoteIdentifier(r.tableName), r.validateFilterField, condition.Value, 1, argIndex)
+	}
+
 	// Validate field
 	if err := r.validateFilterField(condition.Field); err != nil {
 		return "", nil, err
 	}
 
-	field := quoteIdentifier(condition.Field)
+	if condition.Operator == OpInSubquery {
+		return buildInSubqueryCondition(quoteIdentifier(condition.Field), condition.Value, 1, argIndex)
+	}
+
+	return buildFieldCondition(quoteIdentifier(condition.Field), condition, argIndex)
+}
+
+// buildFieldCondition builds the SQL for a leaf condition against a field
+// expression (a quoted column, or an aggregate such as COUNT(*) in HAVING)
+func buildFieldCondition(field string, condition Condition, argIndex *int) (string, []any, error) {
 	var clause string
 	var args []any
 
 	switch condition.Operator {
-	case OpEqual, OpNotEqual, OpGreaterThan, OpLessThan, OpGreaterThanOrEqual, OpLessThanOrEqual, OpLike, OpILike:
+	case OpEqual, OpNotEqual:
+		if isNullValue(condition.Value) {
+			// = NULL never matches in SQL: compare with NULL like InMemory does
+			op := OpIsNull
+			if condition.Operator == OpNotEqual {
+				op = OpIsNotNull
+			}
+			clause = fmt.Sprintf("%s %s", field, op)
+			break
+		}
+		fallthrough
+	case OpGreaterThan, OpLessThan, OpGreaterThanOrEqual, OpLessThanOrEqual, OpLike, OpILike, OpRegex, OpIRegex:
+		value, err := DefaultConverters.normalizeValue(condition.Value)
+		if err != nil {
+			return "", nil, err
+		}
 		clause = fmt.Sprintf("%s %s $%d", field, condition.Operator, *argIndex)
-		args = append(args, condition.Value)
+		args = append(args, value)
 		*argIndex++
 
 	case OpIn, OpNotIn:
@@ -589,8 +705,12 @@ func (r *CockroachDBConnector[T, ID]) buildLeafCondition(condition Condition, ar
 
 		placeholders := make([]string, v.Len())
 		for i := 0; i < v.Len(); i++ {
+			value, err := DefaultConverters.normalizeValue(v.Index(i).Interface())
+			if err != nil {
+				return "", nil, err
+			}
 			placeholders[i] = fmt.Sprintf("$%d", *argIndex)
-			args = append(args, v.Index(i).Interface())
+			args = append(args, value)
 			*argIndex++
 		}
 		clause = fmt.Sprintf("%s %s (%s)", field, condition.Operator, strings.Join(placeholders, ", "))
@@ -613,6 +733,39 @@ func (r *CockroachDBConnector[T, ID]) buildLeafCondition(condition Condition, ar
 		args = append(args, v.Index(0).Interface(), v.Index(1).Interface())
 		*argIndex += 2
 
+	case OpJSONContains, OpJSONPathExists, OpJSONGet:
+		return buildJSONCondition(field, condition, argIndex)
+
+	case OpArrayContains, OpArrayContainedBy, OpArrayOverlaps:
+		v := reflect.ValueOf(condition.Value)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return "", nil, fmt.Errorf("%s operator requires slice value", condition.Operator)
+		}
+		sqlOp := map[ComparisonOperator]string{
+			OpArrayContains:    "@>",
+			OpArrayContainedBy: "<@",
+			OpArrayOverlaps:    "&&",
+		}[condition.Operator]
+		clause = fmt.Sprintf("%s %s $%d", field, sqlOp, *argIndex)
+		args = append(args, condition.Value)
+		*argIndex++
+
+	case OpArrayAny:
+		clause = fmt.Sprintf("$%d = ANY(%s)", *argIndex, field)
+		args = append(args, condition.Value)
+		*argIndex++
+
+	case OpFullText:
+		fts, err := toFullTextQuery(condition.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		// The configuration is bound as an argument and cast, never inlined
+		clause = fmt.Sprintf("to_tsvector($%d::REGCONFIG, %s) @@ plainto_tsquery($%d::REGCONFIG, $%d)",
+			*argIndex, field, *argIndex, *argIndex+1)
+		args = append(args, fts.Language, fts.Query)
+		*argIndex += 2
+
 	default:
 		return "", nil, fmt.Errorf("unsupported operator: %s", condition.Operator)
 	}
@@ -620,6 +773,287 @@ func (r *CockroachDBConnector[T, ID]) buildLeafCondition(condition Condition, ar
 	return clause, args, nil
 }
 
+// buildExistsCondition builds EXISTS (SELECT 1 FROM ...) for a Subquery.
+// outer is the qualified name of the enclosing table and validateOuter checks
+// the outer columns of the correlation. The subquery table is aliased by
+// depth so a table can be correlated with itself.
+func buildExistsCondition(outer string, validateOuter func(string) error, value any, depth int, argIndex *int) (string, []any, error) {
+	sq, ok := value.(Subquery)
+	if p, isPtr := value.(*Subquery); isPtr && p != nil {
+		sq, ok = *p, true
+	}
+	if !ok {
+		return "", nil, fmt.Errorf("EXISTS operator requires a Subquery value")
+	}
+	if err := sanitizeIdentifier(sq.Table); err != nil {
+		return "", nil, err
+	}
+	if err := validateSubqueryFilter(sq.Filter); err != nil {
+		return "", nil, err
+	}
+
+	alias := quoteIdentifier(fmt.Sprintf("%s_%d", sq.Table, depth))
+	var clauses []string
+	var args []any
+
+	for _, c := range sq.Correlation {
+		if err := sanitizeIdentifier(c.Column); err != nil {
+			return "", nil, err
+		}
+		if err := validateOuter(c.Outer); err != nil {
+			return "", nil, err
+		}
+		clauses = append(clauses, fmt.Sprintf("%s.%s = %s.%s", alias, quoteIdentifier(c.Column), outer, quoteIdentifier(c.Outer)))
+	}
+
+	if sq.Filter != nil {
+		for _, condition := range sq.Filter.Conditions {
+			clause, condArgs, err := buildSubqueryCondition(alias, sanitizeIdentifier, condition, depth, argIndex)
+			if err != nil {
+				return "", nil, err
+			}
+			clauses = append(clauses, clause)
+			args = append(args, condArgs...)
+		}
+	}
+
+	query := fmt.Sprintf("EXISTS (SELECT 1 FROM %s AS %s", quoteIdentifier(sq.Table), alias)
+	if len(clauses) > 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+	return query + ")", args, nil
+}
+
+// buildInSubqueryCondition builds field IN (SELECT ...) for an InSubquery.
+// The source columns are validated by the source connector.
+func buildInSubqueryCondition(field string, value any, depth int, argIndex *int) (string, []any, error) {
+	sq, ok := value.(InSubquery)
+	if p, isPtr := value.(*InSubquery); isPtr && p != nil {
+		sq, ok = *p, true
+	}
+	if !ok || sq.Source == nil {
+		return "", nil, fmt.Errorf("IN SUBQUERY operator requires an InSubquery value with a source")
+	}
+	table, validate, ok := sq.Source.subqueryTable()
+	if !ok {
+		return "", nil, fmt.Errorf("%w: IN SUBQUERY source has no SQL table", ErrUnsupportedOperation)
+	}
+	if err := validate(sq.Field); err != nil {
+		return "", nil, err
+	}
+	if err := validateSubqueryFilter(sq.Filter); err != nil {
+		return "", nil, err
+	}
+
+	alias := quoteIdentifier(fmt.Sprintf("%s_%d", table, depth))
+	query := fmt.Sprintf("%s IN (SELECT %s.%s FROM %s AS %s", field, alias, quoteIdentifier(sq.Field), quoteIdentifier(table), alias)
+
+	var clauses []string
+	var args []any
+	if sq.Filter != nil {
+		for _, condition := range sq.Filter.Conditions {
+			clause, condArgs, err := buildSubqueryCondition(alias, validate, condition, depth, argIndex)
+			if err != nil {
+				return "", nil, err
+			}
+			clauses = append(clauses, clause)
+			args = append(args, condArgs...)
+		}
+	}
+	if len(clauses) > 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+	return query + ")", args, nil
+}
+
+// validateSubqueryFilter rejects subquery filters with anything but conditions
+func validateSubqueryFilter(f *Filter) error {
+	if f != nil && (len(f.Sort) > 0 || f.Limit != nil || f.Offset != nil ||
+		f.Distinct || len(f.GroupBy) > 0 || len(f.Having) > 0) {
+		return fmt.Errorf("%w: subqueries only support conditions", ErrInvalidFilter)
+	}
+	return nil
+}
+
+// buildSubqueryCondition builds a condition of a subquery on alias. validate
+// checks the subquery columns; for EXISTS the table's columns are not known,
+// so they are only sanitized.
+func buildSubqueryCondition(alias string, validate func(string) error, condition Condition, depth int, argIndex *int) (string, []any, error) {
+	if condition.IsComposite() {
+		var clauses []string
+		var args []any
+		for _, nested := range condition.Conditions {
+			clause, nestedArgs, err := buildSubqueryCondition(alias, validate, nested, depth, argIndex)
+			if err != nil {
+				return "", nil, err
+			}
+			clauses = append(clauses, clause)
+			args = append(args, nestedArgs...)
+		}
+		return joinLogical(condition.LogicalOp, clauses, args)
+	}
+
+	if condition.Operator == OpExists {
+		return buildExistsCondition(alias, validate, condition.Value, depth+1, argIndex)
+	}
+
+	if err := validate(condition.Field); err != nil {
+		return "", nil, err
+	}
+	field := alias + "." + quoteIdentifier(condition.Field)
+	if condition.Operator == OpInSubquery {
+		return buildInSubqueryCondition(field, condition.Value, depth+1, argIndex)
+	}
+	return buildFieldCondition(field, condition, argIndex)
+}
+
+// subqueryTable implements SubquerySource
+func (r *CockroachDBConnector[T, ID]) subqueryTable() (string, func(string) error, bool) {
+	return r.tableName, r.validateFilterField, true
+}
+
+// subqueryValues implements SubquerySource for in-memory repositories that
+// reference this table
+func (r *CockroachDBConnector[T, ID]) subqueryValues(ctx context.Context, filter *Filter, field string) ([]any, error) {
+	if err := r.validateFilterField(field); err != nil {
+		return nil, err
+	}
+	if err := validateSubqueryFilter(filter); err != nil {
+		return nil, err
+	}
+	sub := &Filter{Distinct: true}
+	if filter != nil {
+		sub.Conditions = filter.Conditions
+	}
+	query, args, err := r.selectQuery(r.softDelete.scope(ctx, sub), []string{field})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.getQueryable(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []any
+	for rows.Next() {
+		row, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, row[0])
+	}
+	return values, rows.Err()
+}
+
+// buildJSONCondition builds the SQL for JSONB operators.
+// Path keys and documents are always bound as arguments, never inlined.
+func buildJSONCondition(field string, condition Condition, argIndex *int) (string, []any, error) {
+	switch condition.Operator {
+	case OpJSONContains:
+		doc, err := json.Marshal(condition.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("@> operator requires a JSON-encodable value: %w", err)
+		}
+		clause := fmt.Sprintf("%s @> $%d::JSONB", field, *argIndex)
+		*argIndex++
+		return clause, []any{string(doc)}, nil
+
+	case OpJSONPathExists:
+		path, err := toJSONPath(condition.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		clause := fmt.Sprintf("(%s #> $%d) IS NOT NULL", field, *argIndex)
+		*argIndex++
+		return clause, []any{path}, nil
+
+	case OpJSONGet:
+		jp, ok := condition.Value.(JSONPath)
+		if !ok {
+			return "", nil, fmt.Errorf("->> operator requires a JSONPath value")
+		}
+		if len(jp.Path) == 0 {
+			return "", nil, fmt.Errorf("->> operator requires a non-empty path")
+		}
+		op := jp.Operator
+		if op == "" {
+			op = OpEqual
+		}
+		switch op {
+		case OpEqual, OpNotEqual, OpGreaterThan, OpLessThan, OpGreaterThanOrEqual, OpLessThanOrEqual, OpLike, OpILike, OpRegex, OpIRegex:
+		default:
+			return "", nil, fmt.Errorf("unsupported operator for JSON path comparison: %s", op)
+		}
+
+		var extract string
+		var pathArg any
+		if len(jp.Path) == 1 {
+			extract = fmt.Sprintf("(%s ->> $%d)", field, *argIndex)
+			pathArg = jp.Path[0]
+		} else {
+			extract = fmt.Sprintf("(%s #>> $%d)", field, *argIndex)
+			pathArg = jp.Path
+		}
+		*argIndex++
+
+		// Extracted values are text, cast so numeric and boolean comparisons behave
+		switch jp.Value.(type) {
+		case bool:
+			extract += "::BOOLEAN"
+		default:
+			if _, isNum := toFloat64(jp.Value); isNum {
+				extract += "::NUMERIC"
+			}
+		}
+
+		clause := fmt.Sprintf("%s %s $%d", extract, op, *argIndex)
+		*argIndex++
+		return clause, []any{pathArg, jp.Value}, nil
+	}
+
+	return "", nil, fmt.Errorf("unsupported operator: %s", condition.Operator)
+}
+
+// toFullTextQuery normalizes a query string or FullTextQuery value
+func toFullTextQuery(value any) (FullTextQuery, error) {
+	var fts FullTextQuery
+	switch v := value.(type) {
+	case string:
+		fts.Query = v
+	case FullTextQuery:
+		fts = v
+	default:
+		return fts, fmt.Errorf("@@ operator requires a string or FullTextQuery value")
+	}
+	if strings.TrimSpace(fts.Query) == "" {
+		return fts, fmt.Errorf("@@ operator requires a non-empty query")
+	}
+	if fts.Language == "" {
+		fts.Language = "simple"
+	}
+	return fts, nil
+}
+
+// toJSONPath normalizes a key string or []string into a path
+func toJSONPath(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil, fmt.Errorf("JSON path cannot be empty")
+		}
+		return []string{v}, nil
+	case []string:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("JSON path cannot be empty")
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("JSON path must be a string or []string")
+	}
+}
+
 func (r *CockroachDBConnector[T, ID]) buildCompositeCondition(condition Condition, argIndex *int) (string, []any, error) {
 	if len(condition.Conditions) == 0 {
 		return "", nil, fmt.Errorf("composite condition must have nested conditions")
@@ -638,8 +1072,13 @@ func (r *CockroachDBConnector[T, ID]) buildCompositeCondition(condition Conditio
 		args = append(args, nestedArgs...)
 	}
 
+	return joinLogical(condition.LogicalOp, clauses, args)
+}
+
+// joinLogical combines nested clauses with a logical operator
+func joinLogical(op LogicalOperator, clauses []string, args []any) (string, []any, error) {
 	var result string
-	switch condition.LogicalOp {
+	switch op {
 	case LogicalAND:
 		result = "(" + strings.Join(clauses, " AND ") + ")"
 	case LogicalOR:
@@ -650,136 +1089,215 @@ func (r *CockroachDBConnector[T, ID]) buildCompositeCondition(condition Conditio
 		}
 		result = "NOT (" + clauses[0] + ")"
 	default:
-		return "", nil, fmt.Errorf("unsupported logical operator: %s", condition.LogicalOp)
+		return "", nil, fmt.Errorf("unsupported logical operator: %s", op)
 	}
 
 	return result, args, nil
 }
 
-func (r *CockroachDBConnector[T, ID]) buildOrderByClause(sortFields []SortField) (string, error) {
+func (r *CockroachDBConnector[T, ID]) buildOrderByClause(sortFields []SortField, groupBy []string) (string, error) {
 	var parts []string
 
 	for _, sf := range sortFields {
+		var nulls string
+		switch sf.Nulls {
+		case NullsDefault:
+		case NullsFirst, NullsLast:
+			nulls = " " + string(sf.Nulls)
+		default:
+			return "", fmt.Errorf("invalid nulls ordering '%s' for field '%s'", sf.Nulls, sf.Field)
+		}
+
+		if sf.Field == RandomField {
+			parts = append(parts, "random()")
+			continue
+		}
+
+		// Grouped queries can only sort by group columns or the group count
+		if len(groupBy) > 0 {
+			if sf.Field == CountField {
+				parts = append(parts, fmt.Sprintf("COUNT(*) %s%s", sf.Direction, nulls))
+				continue
+			}
+			if !containsString(groupBy, sf.Field) {
+				return "", fmt.Errorf("cannot sort by '%s': not in GROUP BY", sf.Field)
+			}
+		}
+
 		// Validate field
 		if err := r.validateFilterField(sf.Field); err != nil {
 			return "", err
 		}
 
-		parts = append(parts, fmt.Sprintf("%s %s", quoteIdentifier(sf.Field), sf.Direction))
+		parts = append(parts, fmt.Sprintf("%s %s%s", quoteIdentifier(sf.Field), sf.Direction, nulls))
 	}
 
 	return "ORDER BY " + strings.Join(parts, ", "), nil
 }
 
-// Exists checks if an entity with the given ID exists
-func (r *CockroachDBConnector[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
-	query := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s = $1)",
-		quoteIdentifier(r.tableName),
-		quoteIdentifier(r.columns[0]),
-	)
+// buildGroupByClause builds the GROUP BY clause of a grouped query from its
+// validated GroupBy columns, and the HAVING clause ANDing its Having conditions
+func (r *CockroachDBConnector[T, ID]) buildGroupByClause(filter *Filter, argIndex *int) (string, []any, error) {
+	for _, field := range filter.GroupBy {
+		if err := r.validateFilterField(field); err != nil {
+			return "", nil, err
+		}
+	}
+	clause := "GROUP BY " + joinQuotedColumns(filter.GroupBy)
 
-	queryable := r.getQueryable(ctx)
-	var exists bool
-	err := queryable.QueryRow(ctx, query, id).Scan(&exists)
-	return exists, err
+	if len(filter.Having) == 0 {
+		return clause, nil, nil
+	}
+
+	var parts []string
+	var args []any
+	for _, condition := range filter.Having {
+		part, condArgs, err := r.buildHavingCondition(condition, filter.GroupBy, argIndex)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, part)
+		args = append(args, condArgs...)
+	}
+
+	return clause + " HAVING " + strings.Join(parts, " AND "), args, nil
 }
 
-// Upsert creates a new entity or updates an existing one using ON CONFLICT
-func (r *CockroachDBConnector[T, ID]) Upsert(ctx context.Context, item *T) error {
-	if item == nil {
-		return fmt.Errorf("item cannot be nil")
+// buildHavingCondition builds a HAVING condition. Leaf conditions must target
+// CountField or a grouped column.
+func (r *CockroachDBConnector[T, ID]) buildHavingCondition(condition Condition, groupBy []string, argIndex *int) (string, []any, error) {
+	if condition.IsComposite() {
+		var clauses []string
+		var args []any
+		for _, nested := range condition.Conditions {
+			clause, nestedArgs, err := r.buildHavingCondition(nested, groupBy, argIndex)
+			if err != nil {
+				return "", nil, err
+			}
+			clauses = append(clauses, clause)
+			args = append(args, nestedArgs...)
+		}
+		return joinLogical(condition.LogicalOp, clauses, args)
 	}
 
-	values, err := r.getValues(item)
-	if err != nil {
-		return err
+	if condition.Field == CountField {
+		return buildFieldCondition("COUNT(*)", condition, argIndex)
+	}
+	if !containsString(groupBy, condition.Field) {
+		return "", nil, fmt.Errorf("HAVING field '%s' must be in GROUP BY or be CountField", condition.Field)
 	}
+	return r.buildLeafCondition(condition, argIndex)
+}
 
-	// Build the SET clause for ON CONFLICT DO UPDATE
-	var setClauses []string
-	numCols := len(r.columns)
-	for i := 1; i < numCols; i++ {
-		setClauses = append(setClauses, fmt.Sprintf("%s = EXCLUDED.%s",
-			quoteIdentifier(r.columns[i]),
-			quoteIdentifier(r.columns[i]),
-		))
+// GroupCount counts the rows matching the filter per distinct combination of
+// the filter's GroupBy columns. Having conditions may reference CountField.
+func (r *CockroachDBConnector[T, ID]) GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error) {
+	query, args, err := r.groupCountQuery(r.softDelete.scope(ctx, filter))
+	if err != nil {
+		return nil, err
 	}
 
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
-		quoteIdentifier(r.tableName),
-		joinQuotedColumns(r.columns),
-		buildPlaceholders(len(r.columns)),
-		quoteIdentifier(r.columns[0]),
-		strings.Join(setClauses, ", "),
-	)
+	rows, err := r.getQueryable(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	queryable := r.getQueryable(ctx)
-	_, err = queryable.Exec(ctx, query, values...)
-	return err
+	return scanGroupCounts[T](rows, filter.GroupBy)
 }
 
-// BatchUpsert creates or updates multiple entities using ON CONFLICT
-func (r *CockroachDBConnector[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
-	if len(items) == 0 {
-		return nil
+// groupCountQuery builds the SQL for GroupCount
+func (r *CockroachDBConnector[T, ID]) groupCountQuery(filter *Filter) (string, []any, error) {
+	if filter == nil || len(filter.GroupBy) == 0 {
+		return "", nil, fmt.Errorf("GroupCount requires GROUP BY fields")
+	}
+	if filter.Distinct {
+		return "", nil, fmt.Errorf("GroupCount does not support DISTINCT")
 	}
 
-	tx, err := r.pool.Begin(ctx)
+	query, args, err := r.queryBuilder(filter)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
-	defer func() {
-		if err != nil {
-			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-				// TODO: Log rollback error: rollbackErr
-			}
-		} else {
-			if commitErr := tx.Commit(ctx); commitErr != nil {
-				// TODO: Log commit error: commitErr
-				err = commitErr
-			}
-		}
-	}()
+	// Add the count after the grouped columns
+	selectList := "SELECT " + joinQuotedColumns(filter.GroupBy)
+	return selectList + ", COUNT(*)" + strings.TrimPrefix(query, selectList), args, nil
+}
 
-	// Build the SET clause for ON CONFLICT DO UPDATE
-	var setClauses []string
-	numCols := len(r.columns)
-	for i := 1; i < numCols; i++ {
-		setClauses = append(setClauses, fmt.Sprintf("%s = EXCLUDED.%s",
-			quoteIdentifier(r.columns[i]),
-			quoteIdentifier(r.columns[i]),
-		))
+// scanGroupCounts scans (group columns..., count) rows into GroupCounts
+func scanGroupCounts[T any](rows pgx.Rows, groupBy []string) ([]GroupCount, error) {
+	types, err := dbFieldTypes[T]()
+	if err != nil {
+		return nil, err
 	}
 
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
-		quoteIdentifier(r.tableName),
-		joinQuotedColumns(r.columns),
-		buildPlaceholders(len(r.columns)),
-		quoteIdentifier(r.columns[0]),
-		strings.Join(setClauses, ", "),
-	)
+	var results []GroupCount
+	for rows.Next() {
+		dests := make([]any, len(groupBy)+1)
+		for i, field := range groupBy {
+			dests[i] = reflect.New(types[field]).Interface()
+		}
+		var count int64
+		dests[len(groupBy)] = &count
 
-	for _, item := range items {
-		values, err := r.getValues(&item)
-		if err != nil {
-			return err
+		if err := rows.Scan(dests...); err != nil {
+			return nil, err
 		}
-		_, err = tx.Exec(ctx, query, values...)
-		if err != nil {
-			return err
+
+		key := make(map[string]any, len(groupBy))
+		for i, field := range groupBy {
+			key[field] = reflect.ValueOf(dests[i]).Elem().Interface()
 		}
+		results = append(results, GroupCount{Key: key, Count: count})
 	}
 
-	return nil
+	return results, rows.Err()
+}
+
+// Exists checks if an entity with the given ID exists
+func (r *CockroachDBConnector[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	queryable := r.getQueryable(ctx)
+	var exists bool
+	err := queryable.QueryRow(ctx, r.readStatement(ctx, stmtExists), id).Scan(&exists)
+	return exists, err
+}
+
+// Upsert creates a new entity or updates an existing one using ON CONFLICT
+func (r *CockroachDBConnector[T, ID]) Upsert(ctx context.Context, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+
+	return r.upsert(ctx, r.getQueryable(ctx), item)
+}
+
+// BatchUpsert creates or updates multiple entities using ON CONFLICT
+func (r *CockroachDBConnector[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	return r.batchTx(ctx, func(tx pgx.Tx) error {
+		return r.batchUpsert(ctx, tx, items)
+	})
 }
 
 // getQueryable returns the queryable (pool or tx) from the context
 // If a transaction exists in the context, it returns the transaction
 // Otherwise, it returns the pool
 func (r *CockroachDBConnector[T, ID]) getQueryable(ctx context.Context) Queryable {
-	if tx, ok := getTxFromContext(ctx); ok {
-		return tx
+	var queryable Queryable = r.pool
+	if tx, ok, err := getPoolTxFromContext(ctx, r.pool); err != nil {
+		return failedQueryable{err: err}
+	} else if ok {
+		queryable = tx
+	}
+	if r.watchdog != nil {
+		queryable = &watchedQueryable{Queryable: queryable, watchdog: r.watchdog, table: r.tableName}
+	}
+	if r.logger != nil {
+		queryable = &loggedQueryable{Queryable: queryable, logger: r.logger}
 	}
-	return r.pool
+	return queryable
 }
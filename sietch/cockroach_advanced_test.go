@@ -101,6 +101,27 @@ func TestCockroachDBQueryBuilderAdvanced(t *testing.T) {
 		}
 	})
 
+	t.Run("OpRegex and OpIRegex operators", func(t *testing.T) {
+		filter := NewFilter().
+			Where("id", OpRegex, "^[0-9]+$").
+			Where("balance", OpIRegex, "^a").
+			Build()
+
+		query, args, err := conn.queryBuilder(filter)
+		if err != nil {
+			t.Fatalf("queryBuilder failed: %v", err)
+		}
+
+		expectedQuery := `SELECT "id", "balance" FROM "accounts" WHERE "id" ~ $1 AND "balance" ~* $2`
+		if query != expectedQuery {
+			t.Errorf("Expected: %s\nGot: %s", expectedQuery, query)
+		}
+
+		if len(args) != 2 || args[0] != "^[0-9]+$" {
+			t.Errorf("Expected pattern args, got %v", args)
+		}
+	})
+
 	t.Run("OpIsNull operator", func(t *testing.T) {
 		filter := NewFilter().
 			Where("balance", OpIsNull, nil).
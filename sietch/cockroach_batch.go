@@ -0,0 +1,457 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DefaultBatchSize is the number of statements sent per pgx batch (one
+// network round trip) by the CockroachDB batch operations
+const DefaultBatchSize = 1000
+
+// DefaultRowsPerStatement is the number of rows BatchCreate and BatchUpdate
+// write with each multi-row statement
+const DefaultRowsPerStatement = 100
+
+// maxStatementParams is the wire protocol limit on bind parameters per statement
+const maxStatementParams = 65535
+
+// batchSender is implemented by pgx.Tx and *pgxpool.Pool
+type batchSender interface {
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// SetBatchSize sets how many statements BatchCreate, BatchUpdate, BatchDelete
+// and BatchUpsert send per round trip. Larger batches mean fewer round trips
+// but bigger messages; a size <= 0 restores DefaultBatchSize. Every chunk of
+// a call still runs in the same transaction. Not safe to call concurrently
+// with batch operations.
+func (r *CockroachDBConnector[T, ID]) SetBatchSize(size int) {
+	r.batchSize = size
+}
+
+// effectiveBatchSize returns the configured batch size or DefaultBatchSize
+func (r *CockroachDBConnector[T, ID]) effectiveBatchSize() int {
+	if r.batchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return r.batchSize
+}
+
+// SetRowsPerStatement sets how many rows BatchCreate and BatchUpdate write
+// per statement. A size <= 0 restores DefaultRowsPerStatement; sizes are
+// capped so a statement never exceeds 65535 bind parameters. Not safe to
+// call concurrently with batch operations.
+func (r *CockroachDBConnector[T, ID]) SetRowsPerStatement(rows int) {
+	r.rowsPerStatement = rows
+}
+
+// effectiveRowsPerStatement returns the configured rows per statement or
+// DefaultRowsPerStatement, capped by the bind parameter limit
+func (r *CockroachDBConnector[T, ID]) effectiveRowsPerStatement() int {
+	rows := r.rowsPerStatement
+	if rows <= 0 {
+		rows = DefaultRowsPerStatement
+	}
+	return max(1, min(rows, maxStatementParams/len(r.columns)))
+}
+
+// batchTx runs fn in a new transaction, committing if it returns nil. In
+// the transaction of ctx, it runs in a savepoint of that transaction.
+func (r *CockroachDBConnector[T, ID]) batchTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	var begin func(ctx context.Context) (pgx.Tx, error) = r.pool.Begin
+	if outer, ok, err := getPoolTxFromContext(ctx, r.pool); err != nil {
+		return err
+	} else if ok {
+		begin = outer.Begin
+	}
+	tx, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+	tx = r.loggedTx(tx)
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// execBatch queues n statements built by stmt and sends them in pgx batches
+// of at most size statements. check, if not nil, inspects the command tag of
+// each statement, e.g. to report rows that were not found.
+func execBatch(ctx context.Context, sender batchSender, size, n int, stmt func(i int) (string, []any, error), check func(i int, ct pgconn.CommandTag) error) error {
+	return sendBatches(ctx, sender, size, n, stmt, func(i int, results pgx.BatchResults) error {
+		ct, err := results.Exec()
+		if err != nil {
+			return translateWriteError(err)
+		}
+		if check != nil {
+			return check(i, ct)
+		}
+		return nil
+	})
+}
+
+// queryBatch is execBatch for statements returning rows, which are passed
+// to collect one statement at a time
+func queryBatch(ctx context.Context, sender batchSender, size, n int, stmt func(i int) (string, []any, error), collect func(i int, rows pgx.Rows) error) error {
+	return sendBatches(ctx, sender, size, n, stmt, func(i int, results pgx.BatchResults) error {
+		rows, err := results.Query()
+		if err != nil {
+			return translateWriteError(err)
+		}
+		defer rows.Close()
+		if err := collect(i, rows); err != nil {
+			return translateWriteError(err)
+		}
+		return nil
+	})
+}
+
+// sendBatches queues n statements built by stmt and sends them in pgx
+// batches of at most size statements, reading the result of statement i
+// with read
+func sendBatches(ctx context.Context, sender batchSender, size, n int, stmt func(i int) (string, []any, error), read func(i int, results pgx.BatchResults) error) error {
+	for start := 0; start < n; start += size {
+		end := min(start+size, n)
+
+		batch := &pgx.Batch{}
+		for i := start; i < end; i++ {
+			sql, args, err := stmt(i)
+			if err != nil {
+				return err
+			}
+			batch.Queue(sql, args...)
+		}
+
+		if err := sendBatch(ctx, sender, batch, start, read); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendBatch sends a single batch whose first statement is item offset
+func sendBatch(ctx context.Context, sender batchSender, batch *pgx.Batch, offset int, read func(i int, results pgx.BatchResults) error) (err error) {
+	results := sender.SendBatch(ctx, batch)
+	defer func() {
+		if closeErr := results.Close(); err == nil && closeErr != nil {
+			err = translateWriteError(closeErr)
+		}
+	}()
+
+	for i := 0; i < batch.Len(); i++ {
+		if err := read(offset+i, results); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchCreate inserts items through sender with multi-row INSERT statements.
+// Values generated by the database for the omitted and generated columns
+// are scanned back into items.
+func (r *CockroachDBConnector[T, ID]) batchCreate(ctx context.Context, sender batchSender, items []T) error {
+	if len(r.codec.returned) == 0 {
+		rows := r.effectiveRowsPerStatement()
+		return execBatch(ctx, sender, r.effectiveBatchSize(), (len(items)+rows-1)/rows, func(i int) (string, []any, error) {
+			return r.insertValuesQuery(items[i*rows:min((i+1)*rows, len(items))], "")
+		}, nil)
+	}
+
+	// The order of RETURNING rows is unspecified, so they are matched to
+	// the items by primary key. A key the database assigns can't be
+	// matched: each item is then inserted by a statement of its own.
+	if !slices.Contains(r.codec.inserts, r.codec.pk) {
+		return queryBatch(ctx, sender, r.effectiveBatchSize(), len(items), func(i int) (string, []any, error) {
+			return r.insertValuesQuery(items[i:i+1], returningClause(r.codec))
+		}, func(i int, result pgx.Rows) error {
+			if !result.Next() {
+				if err := result.Err(); err != nil {
+					return err
+				}
+				return fmt.Errorf("insert returned no row")
+			}
+			return result.Scan(r.getReturnedDestinations(&items[i])...)
+		})
+	}
+
+	returned := append([]int{r.codec.pk}, r.codec.returned...)
+	returning := " RETURNING " + joinQuotedColumns(pick(r.columns, returned))
+	rows := r.effectiveRowsPerStatement()
+	return queryBatch(ctx, sender, r.effectiveBatchSize(), (len(items)+rows-1)/rows, func(i int) (string, []any, error) {
+		return r.insertValuesQuery(items[i*rows:min((i+1)*rows, len(items))], returning)
+	}, func(i int, result pgx.Rows) error {
+		chunk := items[i*rows : min((i+1)*rows, len(items))]
+		byKey := make(map[any]int, len(chunk))
+		for j := range chunk {
+			byKey[r.pkKey(reflect.ValueOf(&chunk[j]).Elem())] = j
+		}
+		for result.Next() {
+			var row T
+			v := reflect.ValueOf(&row).Elem()
+			if err := result.Scan(pick(r.codec.scanDestinations(v), returned)...); err != nil {
+				return err
+			}
+			key := r.pkKey(v)
+			j, ok := byKey[key]
+			if !ok {
+				return fmt.Errorf("insert returned a row of no item")
+			}
+			delete(byKey, key)
+			item := reflect.ValueOf(&chunk[j]).Elem()
+			for _, c := range r.codec.returned {
+				settableField(item, r.codec.fields[c]).Set(settableField(v, r.codec.fields[c]))
+			}
+		}
+		return result.Err()
+	})
+}
+
+// pkKey returns the primary key field of v, an entity, as a map key
+func (r *CockroachDBConnector[T, ID]) pkKey(v reflect.Value) any {
+	field, ok := fieldByIndex(v, r.codec.fields[r.codec.pk])
+	if !ok {
+		return nil
+	}
+	if field.Comparable() {
+		return field.Interface()
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// insertValuesQuery builds a single INSERT statement for items, followed by
+// returning: INSERT INTO "t" ("a", "b") VALUES ($1, $2), ($3, $4)
+func (r *CockroachDBConnector[T, ID]) insertValuesQuery(items []T, returning string) (string, []any, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "INSERT INTO %s (%s) ", quoteIdentifier(r.tableName), joinQuotedColumns(pick(r.columns, r.codec.inserts)))
+	args, err := r.writeValues(&sb, items, r.getInsertValues)
+	if err != nil {
+		return "", nil, err
+	}
+	sb.WriteString(returning)
+	return sb.String(), args, nil
+}
+
+// writeValues writes a VALUES list with a row of placeholders per item to sb
+// and returns the arguments for them, the values of each item from getValues
+func (r *CockroachDBConnector[T, ID]) writeValues(sb *strings.Builder, items []T, getValues func(*T) ([]any, error)) ([]any, error) {
+	args := make([]any, 0, len(items)*len(r.columns))
+
+	sb.WriteString("VALUES ")
+	for i := range items {
+		values, err := getValues(&items[i])
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteByte('(')
+		for j := range values {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(sb, "$%d", len(args)+j+1)
+		}
+		sb.WriteByte(')')
+		args = append(args, values...)
+	}
+	return args, nil
+}
+
+// batchUpdate updates items through sender with one UPDATE ... FROM (VALUES
+// ...) statement per chunk of rows, then fails with a MissingItemsError
+// listing every item that does not exist and, for versioned entities, a
+// VersionConflictError listing those whose version changed. When an ID
+// appears more than once the last item wins, as it would with one UPDATE
+// per item.
+func (r *CockroachDBConnector[T, ID]) batchUpdate(ctx context.Context, sender batchSender, items []T) error {
+	items = lastByID(items, r.getID)
+	rows := r.effectiveRowsPerStatement()
+	statements := (len(items) + rows - 1) / rows
+
+	updated := make(map[ID]struct{}, len(items))
+	err := queryBatch(ctx, sender, r.effectiveBatchSize(), statements, func(i int) (string, []any, error) {
+		return r.updateValuesQuery(items[i*rows : min((i+1)*rows, len(items))])
+	}, func(_ int, rows pgx.Rows) error {
+		var id ID
+		_, err := pgx.ForEachRow(rows, []any{&id}, func() error {
+			updated[id] = struct{}{}
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var missing []ID
+	for i := range items {
+		id := r.getID(&items[i])
+		if _, ok := updated[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if r.codec.version < 0 {
+		return &MissingItemsError[ID]{IDs: missing}
+	}
+	return r.splitVersionConflicts(ctx, sender, missing)
+}
+
+// splitVersionConflicts reports which of the ids a versioned update did not
+// match exist, and so have a different version, and which don't
+func (r *CockroachDBConnector[T, ID]) splitVersionConflicts(ctx context.Context, sender batchSender, ids []ID) error {
+	query := r.existsStatement()
+	exists := make([]bool, len(ids))
+	err := queryBatch(ctx, sender, r.effectiveBatchSize(), len(ids), func(i int) (string, []any, error) {
+		return query, []any{ids[i]}, nil
+	}, func(i int, rows pgx.Rows) error {
+		_, err := pgx.ForEachRow(rows, []any{&exists[i]}, func() error { return nil })
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var missing, conflicts []ID
+	for i, id := range ids {
+		if exists[i] {
+			conflicts = append(conflicts, id)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return batchUpdateError(missing, conflicts)
+}
+
+// batchUpdateError reports the missing and conflicting items of a batch
+// update, joining a MissingItemsError and a VersionConflictError when there
+// are both, or returns nil
+func batchUpdateError[ID comparable](missing, conflicts []ID) error {
+	var errs []error
+	if len(missing) > 0 {
+		errs = append(errs, &MissingItemsError[ID]{IDs: missing})
+	}
+	if len(conflicts) > 0 {
+		errs = append(errs, &VersionConflictError[ID]{IDs: conflicts})
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// updateValuesQuery builds a single UPDATE statement for items, joining the
+// table with their values and returning the IDs that were found:
+//
+//	UPDATE "t" AS t SET "b" = v."b"
+//	FROM (SELECT "a", "b" FROM "t" WHERE false UNION ALL VALUES ($1, $2), ($3, $4)) AS v ("a", "b")
+//	WHERE t."a" = v."a" RETURNING t."a"
+//
+// The empty SELECT gives the VALUES columns the types of the table's, which
+// bare placeholders would not have.
+func (r *CockroachDBConnector[T, ID]) updateValuesQuery(items []T) (string, []any, error) {
+	table := quoteIdentifier(r.tableName)
+	columns := joinQuotedColumns(r.columns)
+	pk := quoteIdentifier(r.columns[r.codec.pk])
+
+	setClauses := make([]string, 0, len(r.codec.updates)+1)
+	for i, col := range r.columns {
+		col = quoteIdentifier(col)
+		if i == r.codec.version {
+			setClauses = append(setClauses, fmt.Sprintf("%s = t.%s + 1", col, col))
+		} else if slices.Contains(r.codec.updates, i) {
+			setClauses = append(setClauses, fmt.Sprintf("%s = v.%s", col, col))
+		}
+	}
+	where := fmt.Sprintf("t.%s = v.%s", pk, pk)
+	if r.codec.version >= 0 {
+		v := quoteIdentifier(r.columns[r.codec.version])
+		where += fmt.Sprintf(" AND t.%s = v.%s", v, v)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "UPDATE %s AS t SET %s FROM (SELECT %s FROM %s WHERE false UNION ALL ",
+		table, strings.Join(setClauses, ", "), columns, table)
+	args, err := r.writeValues(&sb, items, r.getValues)
+	if err != nil {
+		return "", nil, err
+	}
+	fmt.Fprintf(&sb, ") AS v (%s) WHERE %s RETURNING t.%s", columns, where, pk)
+	return sb.String(), args, nil
+}
+
+// lastByID returns items without the earlier occurrences of repeated IDs
+func lastByID[T any, ID comparable](items []T, getID func(*T) ID) []T {
+	last := make(map[ID]int, len(items))
+	for i := range items {
+		last[getID(&items[i])] = i
+	}
+	if len(last) == len(items) {
+		return items
+	}
+
+	unique := make([]T, 0, len(last))
+	for i := range items {
+		if last[getID(&items[i])] == i {
+			unique = append(unique, items[i])
+		}
+	}
+	return unique
+}
+
+// batchDelete deletes ids through sender, failing if any of them does not exist
+func (r *CockroachDBConnector[T, ID]) batchDelete(ctx context.Context, sender batchSender, ids []ID) error {
+	query := r.statement(stmtDelete)
+
+	return execBatch(ctx, sender, r.effectiveBatchSize(), len(ids), func(i int) (string, []any, error) {
+		return query, []any{ids[i]}, nil
+	}, func(i int, ct pgconn.CommandTag) error {
+		if ct.RowsAffected() == 0 {
+			return fmt.Errorf("%v row not deleted", ids[i])
+		}
+		return nil
+	})
+}
+
+// batchUpsert inserts or updates items through sender using ON CONFLICT. For
+// versioned entities it fails with a VersionConflictError listing the
+// existing rows whose version differs.
+func (r *CockroachDBConnector[T, ID]) batchUpsert(ctx context.Context, sender batchSender, items []T) error {
+	query := r.statement(stmtUpsert)
+
+	var check func(i int, ct pgconn.CommandTag) error
+	var conflicts []ID
+	if r.codec.version >= 0 {
+		check = func(i int, ct pgconn.CommandTag) error {
+			if ct.RowsAffected() == 0 {
+				conflicts = append(conflicts, r.getID(&items[i]))
+			}
+			return nil
+		}
+	}
+
+	err := execBatch(ctx, sender, r.effectiveBatchSize(), len(items), func(i int) (string, []any, error) {
+		values, err := r.getInsertValues(&items[i])
+		return query, values, err
+	}, check)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &VersionConflictError[ID]{IDs: conflicts}
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+// fakeBatchSender records sent batches and answers each statement with tag,
+// or with the rows returned by rows for queries
+type fakeBatchSender struct {
+	batches []*pgx.Batch
+	tag     func(sql string, args []any) (pgconn.CommandTag, error)
+	rows    func(sql string, args []any) ([][]any, error)
+}
+
+func (f *fakeBatchSender) SendBatch(_ context.Context, b *pgx.Batch) pgx.BatchResults {
+	f.batches = append(f.batches, b)
+	return &fakeBatchResults{sender: f, batch: b}
+}
+
+type fakeBatchResults struct {
+	pgx.BatchResults
+	sender *fakeBatchSender
+	batch  *pgx.Batch
+	next   int
+}
+
+func (r *fakeBatchResults) Exec() (pgconn.CommandTag, error) {
+	q := r.batch.QueuedQueries[r.next]
+	r.next++
+	if r.sender.tag == nil {
+		return pgconn.NewCommandTag("INSERT 0 1"), nil
+	}
+	return r.sender.tag(q.SQL, q.Arguments)
+}
+
+func (r *fakeBatchResults) Query() (pgx.Rows, error) {
+	q := r.batch.QueuedQueries[r.next]
+	r.next++
+	if r.sender.rows == nil {
+		return &fakeRows{}, nil
+	}
+	values, err := r.sender.rows(q.SQL, q.Arguments)
+	return &fakeRows{values: values, err: err}, nil
+}
+
+func (r *fakeBatchResults) Close() error { return nil }
+
+// fakeRows returns values, then err
+type fakeRows struct {
+	pgx.Rows
+	values [][]any
+	next   int
+	err    error
+}
+
+func (r *fakeRows) Next() bool {
+	if r.err != nil || r.next >= len(r.values) {
+		return false
+	}
+	r.next++
+	return true
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.values[r.next-1][i]))
+	}
+	return nil
+}
+
+func (r *fakeRows) Err() error                    { return r.err }
+func (r *fakeRows) Close()                        {}
+func (r *fakeRows) CommandTag() pgconn.CommandTag { return pgconn.NewCommandTag("UPDATE 0") }
+
+func newBatchConnector(t *testing.T) *CockroachDBConnector[testutils.Account, int64] {
+	t.Helper()
+	conn, err := NewCockroachDBConnector[testutils.Account, int64](&pgxpool.Pool{}, "accounts", func(a *testutils.Account) int64 { return a.ID })
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+	return conn
+}
+
+func TestCockroachDBConnector_BatchChunks(t *testing.T) {
+	ctx := context.Background()
+	accounts := make([]testutils.Account, 2500)
+	for i := range accounts {
+		accounts[i] = testutils.Account{ID: int64(i), Balance: i}
+	}
+
+	t.Run("Create uses multi-row inserts", func(t *testing.T) {
+		conn := newBatchConnector(t)
+		sender := &fakeBatchSender{}
+
+		if err := conn.batchCreate(ctx, sender, accounts); err != nil {
+			t.Fatalf("batchCreate failed: %v", err)
+		}
+
+		if len(sender.batches) != 1 || sender.batches[0].Len() != 25 {
+			t.Fatalf("Expected 25 statements in 1 round trip, got %d round trips", len(sender.batches))
+		}
+		q := sender.batches[0].QueuedQueries[24]
+		if len(q.Arguments) != 200 || q.Arguments[198] != int64(2499) {
+			t.Errorf("Expected the last 100 rows in the last statement, got %d args", len(q.Arguments))
+		}
+	})
+
+	t.Run("Configured rows per statement", func(t *testing.T) {
+		conn := newBatchConnector(t)
+		conn.SetRowsPerStatement(2)
+		conn.SetBatchSize(2)
+		sender := &fakeBatchSender{}
+
+		if err := conn.batchCreate(ctx, sender, accounts[:5]); err != nil {
+			t.Fatalf("batchCreate failed: %v", err)
+		}
+
+		if len(sender.batches) != 2 || sender.batches[0].Len() != 2 || sender.batches[1].Len() != 1 {
+			t.Fatalf("Expected 3 statements in 2 round trips, got %d round trips", len(sender.batches))
+		}
+		q := sender.batches[0].QueuedQueries[1]
+		expected := `INSERT INTO "accounts" ("id", "balance") VALUES ($1, $2), ($3, $4)`
+		if q.SQL != expected || !reflect.DeepEqual(q.Arguments, []any{int64(2), 2, int64(3), 3}) {
+			t.Errorf("Unexpected statement %s %v", q.SQL, q.Arguments)
+		}
+		if last := sender.batches[1].QueuedQueries[0]; len(last.Arguments) != 2 {
+			t.Errorf("Expected a single row in the last statement, got %v", last.Arguments)
+		}
+	})
+
+	t.Run("Rows per statement respect the parameter limit", func(t *testing.T) {
+		conn := newBatchConnector(t)
+		conn.SetRowsPerStatement(1_000_000)
+		if rows := conn.effectiveRowsPerStatement(); rows != 65535/2 {
+			t.Errorf("Expected %d rows per statement, got %d", 65535/2, rows)
+		}
+	})
+
+	t.Run("Configured batch size", func(t *testing.T) {
+		conn := newBatchConnector(t)
+		conn.SetBatchSize(100)
+		sender := &fakeBatchSender{}
+
+		if err := conn.batchUpsert(ctx, sender, accounts[:250]); err != nil {
+			t.Fatalf("batchUpsert failed: %v", err)
+		}
+		if len(sender.batches) != 3 {
+			t.Errorf("Expected 3 round trips, got %d", len(sender.batches))
+		}
+		if !strings.Contains(sender.batches[0].QueuedQueries[0].SQL, "ON CONFLICT") {
+			t.Errorf("Expected an upsert, got %s", sender.batches[0].QueuedQueries[0].SQL)
+		}
+	})
+
+	t.Run("Update joins the values of each chunk", func(t *testing.T) {
+		conn := newBatchConnector(t)
+		conn.SetRowsPerStatement(2)
+		sender := &fakeBatchSender{rows: returnIDs(nil)}
+
+		if err := conn.batchUpdate(ctx, sender, accounts[:5]); err != nil {
+			t.Fatalf("batchUpdate failed: %v", err)
+		}
+		if len(sender.batches) != 1 || sender.batches[0].Len() != 3 {
+			t.Fatalf("Expected 3 statements in 1 round trip, got %d round trips", len(sender.batches))
+		}
+		q := sender.batches[0].QueuedQueries[0]
+		expected := `UPDATE "accounts" AS t SET "balance" = v."balance" ` +
+			`FROM (SELECT "id", "balance" FROM "accounts" WHERE false UNION ALL VALUES ($1, $2), ($3, $4)) AS v ("id", "balance") ` +
+			`WHERE t."id" = v."id" RETURNING t."id"`
+		if q.SQL != expected || !reflect.DeepEqual(q.Arguments, []any{int64(0), 0, int64(1), 1}) {
+			t.Errorf("Unexpected statement %s %v", q.SQL, q.Arguments)
+		}
+	})
+
+	t.Run("Update reports every missing item", func(t *testing.T) {
+		conn := newBatchConnector(t)
+		conn.SetRowsPerStatement(10)
+		sender := &fakeBatchSender{rows: returnIDs(map[any]bool{int64(3): true, int64(17): true})}
+
+		err := conn.batchUpdate(ctx, sender, accounts[:25])
+		var missing *MissingItemsError[int64]
+		if !errors.As(err, &missing) || !reflect.DeepEqual(missing.IDs, []int64{3, 17}) {
+			t.Fatalf("Expected items 3 and 17 to be reported, got %v", err)
+		}
+		if !errors.Is(err, ErrNoUpdateItem) {
+			t.Errorf("Expected ErrNoUpdateItem, got %v", err)
+		}
+		if sender.batches[0].Len() != 3 {
+			t.Errorf("Expected every chunk to run, got %d statements", sender.batches[0].Len())
+		}
+	})
+
+	t.Run("Update keeps the last item of a repeated ID", func(t *testing.T) {
+		conn := newBatchConnector(t)
+		sender := &fakeBatchSender{rows: returnIDs(nil)}
+
+		items := []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}, {ID: 1, Balance: 30}}
+		if err := conn.batchUpdate(ctx, sender, items); err != nil {
+			t.Fatalf("batchUpdate failed: %v", err)
+		}
+		if args := sender.batches[0].QueuedQueries[0].Arguments; !reflect.DeepEqual(args, []any{int64(2), 20, int64(1), 30}) {
+			t.Errorf("Unexpected arguments %v", args)
+		}
+	})
+
+	t.Run("Missing rows fail the batch", func(t *testing.T) {
+		conn := newBatchConnector(t)
+		conn.SetBatchSize(10)
+		sender := &fakeBatchSender{tag: func(_ string, args []any) (pgconn.CommandTag, error) {
+			if args[0] == int64(15) {
+				return pgconn.NewCommandTag("DELETE 0"), nil
+			}
+			return pgconn.NewCommandTag("DELETE 1"), nil
+		}}
+
+		err := conn.batchDelete(ctx, sender, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21})
+		if err == nil || !strings.Contains(err.Error(), "15 row not deleted") {
+			t.Errorf("Expected the missing row to be reported, got %v", err)
+		}
+		if len(sender.batches) != 2 {
+			t.Errorf("Expected to stop after the failing batch, got %d batches", len(sender.batches))
+		}
+	})
+
+	t.Run("Statement errors are translated", func(t *testing.T) {
+		conn := newBatchConnector(t)
+		sender := &fakeBatchSender{tag: func(string, []any) (pgconn.CommandTag, error) {
+			return pgconn.CommandTag{}, &pgconn.PgError{Code: "23505"}
+		}}
+
+		if err := conn.batchCreate(ctx, sender, accounts[:3]); !errors.Is(err, ErrItemAlreadyExists) {
+			t.Errorf("Expected ErrItemAlreadyExists, got %v", err)
+		}
+	})
+}
+
+// returnIDs answers UPDATE ... RETURNING statements on accounts with the IDs
+// in their arguments, except the missing ones
+func returnIDs(missing map[any]bool) func(string, []any) ([][]any, error) {
+	return func(_ string, args []any) ([][]any, error) {
+		var rows [][]any
+		for i := 0; i < len(args); i += 2 {
+			if !missing[args[i]] {
+				rows = append(rows, []any{args[i]})
+			}
+		}
+		return rows, nil
+	}
+}
@@ -0,0 +1,72 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+// fakeCopier drains the row source like the COPY protocol does
+type fakeCopier struct {
+	table   pgx.Identifier
+	columns []string
+	rows    [][]any
+	err     error
+}
+
+func (f *fakeCopier) CopyFrom(_ context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	f.table, f.columns = table, columns
+	for src.Next() {
+		values, err := src.Values()
+		if err != nil {
+			return 0, err
+		}
+		f.rows = append(f.rows, values)
+	}
+	if f.err != nil {
+		return 0, f.err
+	}
+	return int64(len(f.rows)), src.Err()
+}
+
+func TestCockroachDBConnector_BulkInsert(t *testing.T) {
+	ctx := context.Background()
+	conn := newBatchConnector(t)
+	var _ BulkInserter[testutils.Account] = conn
+
+	t.Run("Copies every row", func(t *testing.T) {
+		dst := &fakeCopier{}
+		n, err := conn.copyFrom(ctx, dst, []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}})
+		if err != nil {
+			t.Fatalf("copyFrom failed: %v", err)
+		}
+		if n != 2 {
+			t.Errorf("Expected 2 rows, got %d", n)
+		}
+		if !reflect.DeepEqual(dst.table, pgx.Identifier{"accounts"}) || !reflect.DeepEqual(dst.columns, []string{"id", "balance"}) {
+			t.Errorf("Unexpected target %v %v", dst.table, dst.columns)
+		}
+		expected := [][]any{{int64(1), 10}, {int64(2), 20}}
+		if !reflect.DeepEqual(dst.rows, expected) {
+			t.Errorf("Expected rows %v, got %v", expected, dst.rows)
+		}
+	})
+
+	t.Run("Errors are translated", func(t *testing.T) {
+		dst := &fakeCopier{err: &pgconn.PgError{Code: "23505"}}
+		if _, err := conn.copyFrom(ctx, dst, []testutils.Account{{ID: 1}}); !errors.Is(err, ErrItemAlreadyExists) {
+			t.Errorf("Expected ErrItemAlreadyExists, got %v", err)
+		}
+	})
+
+	t.Run("Empty input", func(t *testing.T) {
+		if n, err := conn.BulkInsert(ctx, nil); n != 0 || err != nil {
+			t.Errorf("Expected a no-op, got %d, %v", n, err)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package sietch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLSTATE codes for integrity constraint violations
+const (
+	pgCodeNotNullViolation    = "23502"
+	pgCodeForeignKeyViolation = "23503"
+	pgCodeUniqueViolation     = "23505"
+	pgCodeCheckViolation      = "23514"
+)
+
+// constraintDetailColumns matches the key list in details such as
+// `Key (email)=(a@b.com) already exists.` or `Key (tenant_id, slug)=(1, x) is not present in table "tenants".`
+var constraintDetailColumns = regexp.MustCompile(`Key \(([^)]+)\)=`)
+
+// translateReadError reports a missing row as ErrItemNotFound, like the
+// other connectors, keeping pgx.ErrNoRows in the chain
+func translateReadError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrItemNotFound, err)
+	}
+	return err
+}
+
+// isNotFound reports whether err is a missing item, also matching a bare
+// pgx.ErrNoRows from queries that bypass translateReadError
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrItemNotFound) || errors.Is(err, pgx.ErrNoRows)
+}
+
+// translateWriteError converts constraint violations reported by the database
+// into a *ConstraintError, leaving other errors unchanged
+func translateWriteError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	var kind ConstraintKind
+	switch pgErr.Code {
+	case pgCodeUniqueViolation:
+		kind = ConstraintUnique
+	case pgCodeForeignKeyViolation:
+		kind = ConstraintForeignKey
+	case pgCodeNotNullViolation:
+		kind = ConstraintNotNull
+	case pgCodeCheckViolation:
+		kind = ConstraintCheck
+	default:
+		return err
+	}
+
+	return &ConstraintError{
+		Kind:       kind,
+		Constraint: pgErr.ConstraintName,
+		Table:      pgErr.TableName,
+		Columns:    constraintColumns(pgErr),
+		Detail:     pgErr.Detail,
+		Err:        err,
+	}
+}
+
+// constraintColumns extracts the affected columns from the error fields or detail message
+func constraintColumns(pgErr *pgconn.PgError) []string {
+	if pgErr.ColumnName != "" {
+		return []string{pgErr.ColumnName}
+	}
+
+	m := constraintDetailColumns.FindStringSubmatch(pgErr.Detail)
+	if m == nil {
+		return nil
+	}
+
+	var columns []string
+	for _, col := range strings.Split(m[1], ",") {
+		col = strings.Trim(strings.TrimSpace(col), `"`)
+		if col != "" {
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
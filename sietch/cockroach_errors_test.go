@@ -0,0 +1,113 @@
+package sietch
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTranslateWriteError(t *testing.T) {
+	tests := []struct {
+		name        string
+		pgErr       *pgconn.PgError
+		wantKind    ConstraintKind
+		wantColumns []string
+		wantExists  bool
+	}{
+		{
+			name: "unique",
+			pgErr: &pgconn.PgError{
+				Code:           "23505",
+				ConstraintName: "accounts_email_key",
+				TableName:      "accounts",
+				Detail:         "Key (email)=(a@b.com) already exists.",
+			},
+			wantKind:    ConstraintUnique,
+			wantColumns: []string{"email"},
+			wantExists:  true,
+		},
+		{
+			name: "composite foreign key",
+			pgErr: &pgconn.PgError{
+				Code:           "23503",
+				ConstraintName: "projects_tenant_fk",
+				Detail:         `Key (tenant_id, "region")=(1, eu) is not present in table "tenants".`,
+			},
+			wantKind:    ConstraintForeignKey,
+			wantColumns: []string{"tenant_id", "region"},
+		},
+		{
+			name:        "not null uses column name",
+			pgErr:       &pgconn.PgError{Code: "23502", ColumnName: "owner"},
+			wantKind:    ConstraintNotNull,
+			wantColumns: []string{"owner"},
+		},
+		{
+			name:     "check without detail",
+			pgErr:    &pgconn.PgError{Code: "23514", ConstraintName: "balance_positive"},
+			wantKind: ConstraintCheck,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := translateWriteError(fmt.Errorf("exec: %w", tt.pgErr))
+
+			var ce *ConstraintError
+			if !errors.As(err, &ce) {
+				t.Fatalf("expected *ConstraintError, got %T: %v", err, err)
+			}
+			if ce.Kind != tt.wantKind {
+				t.Errorf("Kind = %s, want %s", ce.Kind, tt.wantKind)
+			}
+			if ce.Constraint != tt.pgErr.ConstraintName {
+				t.Errorf("Constraint = %q, want %q", ce.Constraint, tt.pgErr.ConstraintName)
+			}
+			if !reflect.DeepEqual(ce.Columns, tt.wantColumns) {
+				t.Errorf("Columns = %v, want %v", ce.Columns, tt.wantColumns)
+			}
+			if !errors.Is(err, ErrConstraintViolation) {
+				t.Error("expected errors.Is(err, ErrConstraintViolation)")
+			}
+			if got := errors.Is(err, ErrItemAlreadyExists); got != tt.wantExists {
+				t.Errorf("errors.Is(err, ErrItemAlreadyExists) = %v, want %v", got, tt.wantExists)
+			}
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) {
+				t.Error("expected the driver error to remain reachable via errors.As")
+			}
+		})
+	}
+}
+
+func TestTranslateWriteError_PassThrough(t *testing.T) {
+	if translateWriteError(nil) != nil {
+		t.Error("nil error should stay nil")
+	}
+
+	plain := errors.New("connection reset")
+	if got := translateWriteError(plain); got != plain {
+		t.Errorf("non-pg error should be returned unchanged, got %v", got)
+	}
+
+	serialization := &pgconn.PgError{Code: "40001"}
+	if got := translateWriteError(serialization); got != error(serialization) {
+		t.Errorf("non-constraint pg error should be returned unchanged, got %v", got)
+	}
+}
+
+func TestConstraintError_Error(t *testing.T) {
+	err := &ConstraintError{
+		Kind:       ConstraintUnique,
+		Constraint: "accounts_email_key",
+		Columns:    []string{"email"},
+		Detail:     "Key (email)=(a@b.com) already exists.",
+	}
+	want := "unique constraint violation on accounts_email_key (email): Key (email)=(a@b.com) already exists."
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
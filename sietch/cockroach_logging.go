@@ -0,0 +1,222 @@
+package sietch
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SetLogger sets the logger receiving every statement the connector runs,
+// with its SQL, arguments and duration; nil disables logging (see
+// LoggableRepository). Statements run through WithTx and batch operations
+// are logged too. Not safe to call concurrently with queries.
+func (r *CockroachDBConnector[T, ID]) SetLogger(logger QueryLogger) {
+	r.logger = logger
+}
+
+// GetLogger returns the connector's logger, nil if logging is disabled
+func (r *CockroachDBConnector[T, ID]) GetLogger() QueryLogger {
+	return r.logger
+}
+
+// loggedTx wraps tx so its statements are logged, if the connector has a logger
+func (r *CockroachDBConnector[T, ID]) loggedTx(tx pgx.Tx) pgx.Tx {
+	if r.logger == nil {
+		return tx
+	}
+	return &loggingTx{Tx: tx, logger: r.logger}
+}
+
+// operationKey carries the repository operation a statement runs for
+type operationKey struct{}
+
+// withOperation tags the statements run with ctx with a repository
+// operation, such as "Get", for their log entries
+func withOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// statementOperation names the operation of a statement in its log entry: the
+// repository operation of ctx if any, else the first keyword of the SQL
+func statementOperation(ctx context.Context, sql string) string {
+	if op, ok := ctx.Value(operationKey{}).(string); ok {
+		return op
+	}
+	if keyword, _, _ := strings.Cut(strings.TrimSpace(sql), " "); keyword != "" {
+		return strings.ToUpper(keyword)
+	}
+	return "Query"
+}
+
+// loggedQueryable logs every statement run through it
+type loggedQueryable struct {
+	Queryable
+	logger QueryLogger
+}
+
+func (l *loggedQueryable) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return logExec(ctx, l.logger, l.Queryable, sql, args)
+}
+
+func (l *loggedQueryable) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return logRows(ctx, l.logger, l.Queryable, sql, args)
+}
+
+func (l *loggedQueryable) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return logRow(ctx, l.logger, l.Queryable, sql, args)
+}
+
+// loggingTx is a transaction logging its statements and batches
+type loggingTx struct {
+	pgx.Tx
+	logger QueryLogger
+}
+
+func (l *loggingTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return logExec(ctx, l.logger, l.Tx, sql, args)
+}
+
+func (l *loggingTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return logRows(ctx, l.logger, l.Tx, sql, args)
+}
+
+func (l *loggingTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return logRow(ctx, l.logger, l.Tx, sql, args)
+}
+
+// SendBatch logs the batch once its results are closed, as one entry with
+// its distinct statements joined by "; " and the arguments of all of them
+func (l *loggingTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	var statements []string
+	var args []any
+	seen := make(map[string]bool)
+	for _, q := range b.QueuedQueries {
+		if !seen[q.SQL] {
+			seen[q.SQL] = true
+			statements = append(statements, q.SQL)
+		}
+		args = append(args, q.Arguments...)
+	}
+	query := strings.Join(statements, "; ")
+
+	return &loggedBatchResults{
+		BatchResults: l.Tx.SendBatch(ctx, b),
+		logger:       l.logger,
+		ctx:          ctx,
+		operation:    statementOperation(ctx, query),
+		query:        query,
+		args:         args,
+		start:        time.Now(),
+	}
+}
+
+func logExec(ctx context.Context, logger QueryLogger, q Queryable, sql string, args []any) (pgconn.CommandTag, error) {
+	start := time.Now()
+	ct, err := q.Exec(ctx, sql, args...)
+	logQuery(logger, ctx, statementOperation(ctx, sql), sql, args, start, err)
+	return ct, err
+}
+
+func logRows(ctx context.Context, logger QueryLogger, q Queryable, sql string, args []any) (pgx.Rows, error) {
+	start := time.Now()
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		logQuery(logger, ctx, statementOperation(ctx, sql), sql, args, start, err)
+		return nil, err
+	}
+	return &loggedRows{Rows: rows, logger: logger, ctx: ctx, query: sql, args: args, start: start}, nil
+}
+
+func logRow(ctx context.Context, logger QueryLogger, q Queryable, sql string, args []any) pgx.Row {
+	return &loggedRow{row: q.QueryRow(ctx, sql, args...), logger: logger, ctx: ctx, query: sql, args: args, start: time.Now()}
+}
+
+// loggedRows logs its query once the rows are read or closed, so the
+// duration covers fetching them
+type loggedRows struct {
+	pgx.Rows
+	logger QueryLogger
+	ctx    context.Context
+	query  string
+	args   []any
+	start  time.Time
+	logged bool
+}
+
+func (r *loggedRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.log()
+	return false
+}
+
+func (r *loggedRows) Close() {
+	r.Rows.Close()
+	r.log()
+}
+
+func (r *loggedRows) log() {
+	if r.logged {
+		return
+	}
+	r.logged = true
+	logQuery(r.logger, r.ctx, statementOperation(r.ctx, r.query), r.query, r.args, r.start, r.Rows.Err())
+}
+
+// loggedRow logs its query once it is scanned, as pgx runs it lazily
+type loggedRow struct {
+	row    pgx.Row
+	logger QueryLogger
+	ctx    context.Context
+	query  string
+	args   []any
+	start  time.Time
+}
+
+func (r *loggedRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	logQuery(r.logger, r.ctx, statementOperation(r.ctx, r.query), r.query, r.args, r.start, err)
+	return err
+}
+
+// loggedBatchResults logs its batch when closed, with the first error the
+// statements returned
+type loggedBatchResults struct {
+	pgx.BatchResults
+	logger    QueryLogger
+	ctx       context.Context
+	operation string
+	query     string
+	args      []any
+	start     time.Time
+	err       error
+}
+
+func (r *loggedBatchResults) Exec() (pgconn.CommandTag, error) {
+	ct, err := r.BatchResults.Exec()
+	r.record(err)
+	return ct, err
+}
+
+func (r *loggedBatchResults) Query() (pgx.Rows, error) {
+	rows, err := r.BatchResults.Query()
+	r.record(err)
+	return rows, err
+}
+
+func (r *loggedBatchResults) Close() error {
+	err := r.BatchResults.Close()
+	r.record(err)
+	logQuery(r.logger, r.ctx, r.operation, r.query, r.args, r.start, r.err)
+	return err
+}
+
+func (r *loggedBatchResults) record(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
@@ -0,0 +1,60 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+)
+
+// SetSoftDeleteOptions configures the soft delete columns of a SoftDeletable
+// entity and whether reads include deleted rows by default; the defaults are
+// DefaultSoftDeleteOptions. It rebuilds the CRUD statements, so call
+// PrepareStatements again before using prepared statements. Not safe to call
+// concurrently with queries.
+func (r *CockroachDBConnector[T, ID]) SetSoftDeleteOptions(opts *SoftDeleteOptions) error {
+	if !isSoftDeletable[T]() {
+		return fmt.Errorf("%w: entity does not implement SoftDeletable", ErrUnsupportedOperation)
+	}
+	softDelete, err := newSoftDeleteConfig[T](opts, r.columns)
+	if err != nil {
+		return err
+	}
+	r.softDelete = softDelete
+	r.buildStatements()
+	return nil
+}
+
+// WithDeleted returns a view of the connector whose reads include
+// soft-deleted rows (see SoftDeleter)
+func (r *CockroachDBConnector[T, ID]) WithDeleted() Repository[T, ID] {
+	return &withDeletedRepository[T, ID]{repo: r}
+}
+
+// Restore clears the soft delete columns of a deleted row
+func (r *CockroachDBConnector[T, ID]) Restore(ctx context.Context, id ID) error {
+	return r.restore(ctx, r.getQueryable(ctx), id)
+}
+
+func (r *CockroachDBConnector[T, ID]) restore(ctx context.Context, q Queryable, id ID) error {
+	if r.softDelete == nil {
+		return fmt.Errorf("%w: entity does not implement SoftDeletable", ErrUnsupportedOperation)
+	}
+	ct, err := q.Exec(ctx, r.statement(stmtRestore), id)
+	if err != nil {
+		return translateWriteError(err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNoUpdateItem
+	}
+	return nil
+}
+
+// WithDeleted returns a view of the transaction whose reads include
+// soft-deleted rows
+func (t *cockroachDBTx[T, ID]) WithDeleted() Repository[T, ID] {
+	return &withDeletedRepository[T, ID]{repo: t}
+}
+
+// Restore clears the soft delete columns of a deleted row within the transaction
+func (t *cockroachDBTx[T, ID]) Restore(ctx context.Context, id ID) error {
+	return t.connector.restore(ctx, t.tx, id)
+}
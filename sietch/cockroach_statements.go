@@ -0,0 +1,178 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// statementKind identifies one of the precomputed CRUD statements
+type statementKind int
+
+const (
+	stmtInsert statementKind = iota
+	stmtGet
+	stmtUpdate
+	stmtDelete
+	stmtExists
+	stmtUpsert
+	stmtGetForUpdate
+	stmtGetWithDeleted          // soft-deletable entities only
+	stmtGetForUpdateWithDeleted // soft-deletable entities only
+	stmtExistsWithDeleted       // soft-deletable entities only
+	stmtRestore                 // soft-deletable entities only
+	numStatements
+)
+
+// statementSuffixes name the prepared statement of each kind
+var statementSuffixes = [numStatements]string{"insert", "get", "update", "delete", "exists", "upsert", "get_for_update",
+	"get_with_deleted", "get_for_update_with_deleted", "exists_with_deleted", "restore"}
+
+// PreparedStatement is a CRUD statement of a CockroachDB connector
+type PreparedStatement struct {
+	Name string // e.g. "sietch_accounts_get_1c9a3f0e", the last part hashing SQL
+	SQL  string
+}
+
+// crudStatements holds the CRUD statements of a connector, built once from
+// its table and columns instead of with fmt.Sprintf on every call
+type crudStatements [numStatements]PreparedStatement
+
+// newCRUDStatements builds the statements for table from the columns of
+// codec. Inserts skip the omitted and generated columns, updates the primary
+// key and the readonly and generated ones; upserts resolve conflicts as
+// configured by onConflict. Versioned updates and upserts check and
+// increment the version and return the new one.
+func newCRUDStatements(table string, codec *entityCodec, onConflict *upsertConfig) *crudStatements {
+	columns := codec.columns
+	quotedTable := quoteIdentifier(table)
+	quotedColumns := joinQuotedColumns(columns)
+	insertColumns := joinQuotedColumns(pick(columns, codec.inserts))
+	numInsert := len(codec.inserts)
+	pk := quoteIdentifier(columns[codec.pk])
+
+	setClauses := make([]string, 0, len(codec.updates))
+	for _, i := range codec.updates {
+		col := quoteIdentifier(columns[i])
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", col, len(setClauses)+1))
+	}
+
+	updateSQL := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d", quotedTable, strings.Join(setClauses, ", "), pk, len(setClauses)+1)
+	if codec.version >= 0 {
+		v := quoteIdentifier(columns[codec.version])
+		updateSQL = fmt.Sprintf("UPDATE %s SET %s, %s = %s + 1 WHERE %s = $%d AND %s = $%d RETURNING %s",
+			quotedTable, strings.Join(setClauses, ", "), v, v, pk, len(setClauses)+1, v, len(setClauses)+2, v)
+	}
+	upsertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)%s",
+		quotedTable, insertColumns, buildPlaceholders(numInsert), onConflict.onConflictSQL(table, codec))
+
+	s := &crudStatements{}
+	s[stmtInsert].SQL = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)%s", quotedTable, insertColumns, buildPlaceholders(numInsert), returningClause(codec))
+	s[stmtGet].SQL = fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1", quotedColumns, quotedTable, pk)
+	s[stmtUpdate].SQL = updateSQL
+	s[stmtDelete].SQL = fmt.Sprintf("DELETE FROM %s WHERE %s = $1", quotedTable, pk)
+	s[stmtExists].SQL = fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s = $1)", quotedTable, pk)
+	s[stmtUpsert].SQL = upsertSQL
+	s[stmtGetForUpdate].SQL = s[stmtGet].SQL + " FOR UPDATE"
+	return s
+}
+
+// name names the statements of s after table, their kind and a hash of
+// their SQL, so connectors of different entities or options on the same
+// table never prepare different statements under the same name on a shared
+// connection
+func (s *crudStatements) name(table string) {
+	for kind := range s {
+		h := fnv.New32a()
+		h.Write([]byte(s[kind].SQL))
+		s[kind].Name = fmt.Sprintf("sietch_%s_%s_%08x", table, statementSuffixes[kind], h.Sum32())
+	}
+}
+
+// returningClause returns the RETURNING clause of inserts, which read back
+// the values the database generated for the omitted and generated columns,
+// or "" when every column is written
+func returningClause(codec *entityCodec) string {
+	if len(codec.returned) == 0 {
+		return ""
+	}
+	return " RETURNING " + joinQuotedColumns(pick(codec.columns, codec.returned))
+}
+
+// PreparedStatements returns the CRUD statements of the connector with the
+// names they are prepared under by PrepareStatements, e.g. to correlate
+// pg_stat_statements or crdb_internal entries with repository calls.
+func (r *CockroachDBConnector[T, ID]) PreparedStatements() []PreparedStatement {
+	stmts := make([]PreparedStatement, 0, numStatements)
+	for _, stmt := range r.statements {
+		// Statements of other entity kinds are left empty
+		if stmt.SQL != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// PrepareStatements prepares the CRUD statements of the connector on conn.
+// Call it from the pool's AfterConnect hook so every connection has them
+// before enabling SetUsePreparedStatements.
+//
+// Example:
+//
+//	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+//	    return repo.PrepareStatements(ctx, conn)
+//	}
+func (r *CockroachDBConnector[T, ID]) PrepareStatements(ctx context.Context, conn *pgx.Conn) error {
+	for _, stmt := range r.PreparedStatements() {
+		if _, err := conn.Prepare(ctx, stmt.Name, stmt.SQL); err != nil {
+			return fmt.Errorf("failed to prepare %s: %w", stmt.Name, err)
+		}
+	}
+	return nil
+}
+
+// SetUsePreparedStatements makes Create, Get, GetForUpdate, Update, Delete,
+// Exists, Upsert, BatchDelete and BatchUpsert run their statements by prepared name instead of
+// by SQL text. Every connection of the pool must have run PrepareStatements,
+// otherwise those calls fail. When disabled (the default) pgx still caches
+// the statements per connection under generated names. Not safe to call
+// concurrently with queries.
+func (r *CockroachDBConnector[T, ID]) SetUsePreparedStatements(enabled bool) {
+	r.usePrepared = enabled
+}
+
+// readStatement returns the statement to run for a read kind, the one that
+// includes soft-deleted rows if the reads of ctx include them
+func (r *CockroachDBConnector[T, ID]) readStatement(ctx context.Context, kind statementKind) string {
+	if r.softDelete != nil && !r.softDelete.hides(ctx) {
+		switch kind {
+		case stmtGet:
+			kind = stmtGetWithDeleted
+		case stmtGetForUpdate:
+			kind = stmtGetForUpdateWithDeleted
+		case stmtExists:
+			kind = stmtExistsWithDeleted
+		}
+	}
+	return r.statement(kind)
+}
+
+// existsStatement returns the statement checking whether a row exists,
+// soft-deleted or not, e.g. to tell version conflicts from missing rows
+func (r *CockroachDBConnector[T, ID]) existsStatement() string {
+	if r.softDelete != nil {
+		return r.statement(stmtExistsWithDeleted)
+	}
+	return r.statement(stmtExists)
+}
+
+// statement returns the SQL, or prepared name, to run for kind
+func (r *CockroachDBConnector[T, ID]) statement(kind statementKind) string {
+	if r.usePrepared {
+		return r.statements[kind].Name
+	}
+	return r.statements[kind].SQL
+}
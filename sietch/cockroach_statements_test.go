@@ -0,0 +1,335 @@
+package sietch
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestCockroachDBConnector_Statements(t *testing.T) {
+	conn := newBatchConnector(t)
+
+	expected := map[string]string{
+		"sietch_accounts_insert_e30f5ea4": `INSERT INTO "accounts" ("id", "balance") VALUES ($1, $2)`,
+		"sietch_accounts_get_958c8ae4":    `SELECT "id", "balance" FROM "accounts" WHERE "id" = $1`,
+		"sietch_accounts_update_c6e57d0f": `UPDATE "accounts" SET "balance" = $1 WHERE "id" = $2`,
+		"sietch_accounts_delete_abdcf362": `DELETE FROM "accounts" WHERE "id" = $1`,
+		"sietch_accounts_exists_8c8de511": `SELECT EXISTS(SELECT 1 FROM "accounts" WHERE "id" = $1)`,
+		"sietch_accounts_upsert_7bc821b4": `INSERT INTO "accounts" ("id", "balance") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "balance" = EXCLUDED."balance"`,
+
+		"sietch_accounts_get_for_update_c36cf218": `SELECT "id", "balance" FROM "accounts" WHERE "id" = $1 FOR UPDATE`,
+	}
+
+	t.Run("Statements are precomputed", func(t *testing.T) {
+		stmts := conn.PreparedStatements()
+		if len(stmts) != len(expected) {
+			t.Fatalf("Expected %d statements, got %d", len(expected), len(stmts))
+		}
+		for _, stmt := range stmts {
+			if expected[stmt.Name] != stmt.SQL {
+				t.Errorf("Unexpected statement %s: %s", stmt.Name, stmt.SQL)
+			}
+		}
+	})
+
+	t.Run("Prepared mode runs statements by name", func(t *testing.T) {
+		if got := conn.statement(stmtGet); got != expected["sietch_accounts_get_958c8ae4"] {
+			t.Errorf("Expected SQL text by default, got %s", got)
+		}
+		conn.SetUsePreparedStatements(true)
+		defer conn.SetUsePreparedStatements(false)
+		if got := conn.statement(stmtGet); got != "sietch_accounts_get_958c8ae4" {
+			t.Errorf("Expected the prepared name, got %s", got)
+		}
+	})
+
+	t.Run("Names differ with the SQL", func(t *testing.T) {
+		type wideAccount struct {
+			ID      int64  `db:"id"`
+			Balance int64  `db:"balance"`
+			Owner   string `db:"owner"`
+		}
+		wide, err := NewCockroachDBConnector[wideAccount, int64](&pgxpool.Pool{}, "accounts", func(a *wideAccount) int64 { return a.ID })
+		if err != nil {
+			t.Fatalf("Failed to create connector: %v", err)
+		}
+		// Only identical statements share a name
+		for _, stmt := range wide.PreparedStatements() {
+			if sql, clash := expected[stmt.Name]; clash && sql != stmt.SQL {
+				t.Errorf("Statement %s of another entity on the same table has a different SQL: %s", stmt.Name, stmt.SQL)
+			}
+		}
+	})
+
+	t.Run("Returned statements are a copy", func(t *testing.T) {
+		conn.PreparedStatements()[0].SQL = "DROP TABLE accounts"
+		if conn.statement(stmtInsert) != expected["sietch_accounts_insert_e30f5ea4"] {
+			t.Error("Expected the connector statements to be unaffected")
+		}
+	})
+}
+
+type stampedDocument struct {
+	ID        int64  `db:"id,pk"`
+	Title     string `db:"title"`
+	SearchVec string `db:"search_vec,generated"`
+}
+
+type taggedDocument struct {
+	Title     string    `db:"title"`
+	ID        int64     `db:"id,pk,omit"`
+	Body      string    `db:"body"`
+	CreatedAt time.Time `db:"created_at,readonly"`
+	SearchVec string    `db:"search_vec,generated"`
+	Draft     bool      `db:"-"`
+}
+
+func TestCockroachDBConnector_TagOptions(t *testing.T) {
+	conn, err := NewCockroachDBConnector[taggedDocument, int64](&pgxpool.Pool{}, "documents", func(d *taggedDocument) int64 { return d.ID })
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	expected := map[statementKind]string{
+		stmtInsert: `INSERT INTO "documents" ("title", "body", "created_at") VALUES ($1, $2, $3) RETURNING "id", "search_vec"`,
+		stmtGet:    `SELECT "title", "id", "body", "created_at", "search_vec" FROM "documents" WHERE "id" = $1`,
+		stmtUpdate: `UPDATE "documents" SET "title" = $1, "body" = $2 WHERE "id" = $3`,
+		stmtDelete: `DELETE FROM "documents" WHERE "id" = $1`,
+		stmtUpsert: `INSERT INTO "documents" ("title", "body", "created_at") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET "title" = EXCLUDED."title", "body" = EXCLUDED."body"`,
+	}
+	for kind, sql := range expected {
+		if got := conn.statement(kind); got != sql {
+			t.Errorf("Expected: %s\nGot: %s", sql, got)
+		}
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	doc := taggedDocument{Title: "t", ID: 7, Body: "b", CreatedAt: now, SearchVec: "ignored"}
+	values, err := conn.getInsertValues(&doc)
+	if err != nil || len(values) != 3 || values[0] != "t" || values[2] != now {
+		t.Errorf("Unexpected insert values %v (%v)", values, err)
+	}
+	all, _ := conn.getValues(&doc)
+	if args := conn.updateArgs(all, doc.ID); len(args) != 3 || args[0] != "t" || args[1] != "b" || args[2] != int64(7) {
+		t.Errorf("Unexpected update args %v", args)
+	}
+
+	query, _, err := conn.updateValuesQuery([]taggedDocument{doc})
+	if err != nil {
+		t.Fatalf("updateValuesQuery failed: %v", err)
+	}
+	if !strings.HasPrefix(query, `UPDATE "documents" AS t SET "title" = v."title", "body" = v."body" FROM`) || !strings.HasSuffix(query, `WHERE t."id" = v."id" RETURNING t."id"`) {
+		t.Errorf("Unexpected batch update statement %s", query)
+	}
+
+	_, _, sqlErr := conn.updateWhereQuery(NewFilter().Where("id", OpEqual, 7).Build(), map[string]any{"created_at": now})
+	if sqlErr == nil {
+		t.Error("Expected UpdateWhere to reject a readonly column")
+	}
+
+	// InMemory, the test double, rejects the same columns
+	ctx := context.Background()
+	mem := NewInMemoryConnector[taggedDocument, int64](nil)
+	_ = mem.Create(ctx, &doc)
+	filter := NewFilter().Where("id", OpEqual, int64(7)).Build()
+	if _, err := mem.UpdateWhere(ctx, filter, map[string]any{"created_at": now}); err == nil || err.Error() != sqlErr.Error() {
+		t.Errorf("Expected %v from InMemory, got %v", sqlErr, err)
+	}
+	if _, err := mem.UpdateWhere(ctx, filter, map[string]any{"search_vec": "x"}); err == nil {
+		t.Error("Expected InMemory UpdateWhere to reject a generated column")
+	}
+	if n, err := mem.UpdateWhere(ctx, filter, map[string]any{"title": "u"}); err != nil || n != 1 {
+		t.Errorf("Expected InMemory UpdateWhere to set writable columns, got %d (%v)", n, err)
+	}
+}
+
+func TestEntityCodec_TagOptionErrors(t *testing.T) {
+	type twoKeys struct {
+		A int64 `db:"a,pk"`
+		B int64 `db:"b,pk"`
+	}
+	if _, err := newEntityCodec[twoKeys](); err == nil {
+		t.Error("Expected two pk columns to be rejected")
+	}
+	type versionKey struct {
+		Name    string `db:"name"`
+		Version int64  `db:"version,pk,version"`
+	}
+	if _, err := newEntityCodec[versionKey](); err == nil {
+		t.Error("Expected the version column not to be the primary key")
+	}
+	type readonlyVersion struct {
+		ID      int64 `db:"id"`
+		Version int64 `db:"version,version,readonly"`
+	}
+	if _, err := newEntityCodec[readonlyVersion](); err == nil {
+		t.Error("Expected a readonly version column to be rejected")
+	}
+}
+
+// returningQueryable answers inserts with the generated id and search_vec
+type returningQueryable struct {
+	Queryable
+	sql  string
+	next int64
+}
+
+func (q *returningQueryable) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	q.sql = sql
+	q.next++
+	return fakeRow{values: []any{q.next, "vec:" + args[0].(string)}}
+}
+
+type fakeRow struct {
+	values []any
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.values[i]))
+	}
+	return nil
+}
+
+func TestCockroachDBConnector_InsertReturning(t *testing.T) {
+	ctx := context.Background()
+	conn, err := NewCockroachDBConnector[taggedDocument, int64](&pgxpool.Pool{}, "documents", nil)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	t.Run("Create scans generated values into the item", func(t *testing.T) {
+		q := &returningQueryable{next: 41}
+		doc := taggedDocument{Title: "a", Body: "b"}
+		if err := conn.insert(ctx, q, &doc); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+		if !strings.HasSuffix(q.sql, ` RETURNING "id", "search_vec"`) {
+			t.Errorf("Expected a RETURNING clause, got %s", q.sql)
+		}
+		if doc.ID != 42 || doc.SearchVec != "vec:a" {
+			t.Errorf("Expected generated values in the item, got %+v", doc)
+		}
+	})
+
+	t.Run("BatchCreate inserts items with assigned keys one by one", func(t *testing.T) {
+		next := int64(0)
+		sender := &fakeBatchSender{rows: func(sql string, args []any) ([][]any, error) {
+			next++
+			return [][]any{{next, "vec:" + args[0].(string)}}, nil
+		}}
+
+		docs := []taggedDocument{{Title: "x"}, {Title: "y"}, {Title: "z"}}
+		if err := conn.batchCreate(ctx, sender, docs); err != nil {
+			t.Fatalf("batchCreate failed: %v", err)
+		}
+		expected := `INSERT INTO "documents" ("title", "body", "created_at") VALUES ($1, $2, $3) RETURNING "id", "search_vec"`
+		if q := sender.batches[0].QueuedQueries; len(q) != 3 || q[0].SQL != expected {
+			t.Errorf("Expected 3 statements like: %s\nGot: %v", expected, q)
+		}
+		for i, doc := range docs {
+			if doc.ID != int64(i+1) || doc.SearchVec != "vec:"+doc.Title {
+				t.Errorf("Expected generated values in item %d, got %+v", i, doc)
+			}
+		}
+	})
+
+	t.Run("BatchCreate matches returned rows by primary key", func(t *testing.T) {
+		stamped, err := NewCockroachDBConnector[stampedDocument, int64](&pgxpool.Pool{}, "documents", nil)
+		if err != nil {
+			t.Fatalf("Failed to create connector: %v", err)
+		}
+		sender := &fakeBatchSender{rows: func(sql string, args []any) ([][]any, error) {
+			var rows [][]any
+			for i := len(args) - 2; i >= 0; i -= 2 { // in reverse order
+				rows = append(rows, []any{args[i], "vec:" + args[i+1].(string)})
+			}
+			return rows, nil
+		}}
+
+		docs := []stampedDocument{{ID: 1, Title: "x"}, {ID: 2, Title: "y"}, {ID: 3, Title: "z"}}
+		if err := stamped.batchCreate(ctx, sender, docs); err != nil {
+			t.Fatalf("batchCreate failed: %v", err)
+		}
+		expected := `INSERT INTO "documents" ("id", "title") VALUES ($1, $2), ($3, $4), ($5, $6) RETURNING "id", "search_vec"`
+		if q := sender.batches[0].QueuedQueries[0]; q.SQL != expected {
+			t.Errorf("Expected: %s\nGot: %s", expected, q.SQL)
+		}
+		for i, doc := range docs {
+			if doc.SearchVec != "vec:"+doc.Title {
+				t.Errorf("Expected the generated value of item %d, got %+v", i, doc)
+			}
+		}
+	})
+
+	t.Run("Entities without generated columns don't use RETURNING", func(t *testing.T) {
+		if got := newBatchConnector(t).statement(stmtInsert); strings.Contains(got, "RETURNING") {
+			t.Errorf("Unexpected RETURNING clause: %s", got)
+		}
+	})
+}
+
+func TestCockroachDBConnector_UpsertOptions(t *testing.T) {
+	conn, err := NewCockroachDBConnector[taggedDocument, int64](&pgxpool.Pool{}, "documents", func(d *taggedDocument) int64 { return d.ID })
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+	insert := `INSERT INTO "documents" ("title", "body", "created_at") VALUES ($1, $2, $3)`
+
+	tests := []struct {
+		name     string
+		opts     UpsertOptions
+		expected string
+	}{
+		{"conflict columns", UpsertOptions{ConflictColumns: []string{"title", "body"}},
+			` ON CONFLICT ("title", "body") DO UPDATE SET "title" = EXCLUDED."title", "body" = EXCLUDED."body"`},
+		{"conflict constraint", UpsertOptions{ConflictConstraint: "documents_title_key", UpdateColumns: []string{"body"}},
+			` ON CONFLICT ON CONSTRAINT "documents_title_key" DO UPDATE SET "body" = EXCLUDED."body"`},
+		{"excluded columns", UpsertOptions{ExcludeColumns: []string{"title"}},
+			` ON CONFLICT ("id") DO UPDATE SET "body" = EXCLUDED."body"`},
+		{"nothing to update", UpsertOptions{ExcludeColumns: []string{"title", "body"}},
+			` ON CONFLICT ("id") DO NOTHING`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := conn.SetUpsertOptions(tt.opts); err != nil {
+				t.Fatalf("SetUpsertOptions failed: %v", err)
+			}
+			if got := conn.statement(stmtUpsert); got != insert+tt.expected {
+				t.Errorf("Expected: %s\nGot: %s", insert+tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("Versioned upserts still increment the version", func(t *testing.T) {
+		conn := newVersionedConnector(t)
+		if err := conn.SetUpsertOptions(UpsertOptions{ExcludeColumns: []string{"balance"}}); err != nil {
+			t.Fatalf("SetUpsertOptions failed: %v", err)
+		}
+		expected := `INSERT INTO "accounts" ("id", "version", "balance") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET ` +
+			`"version" = "accounts"."version" + 1 WHERE "accounts"."version" = EXCLUDED."version" RETURNING "version"`
+		if got := conn.statement(stmtUpsert); got != expected {
+			t.Errorf("Unexpected upsert statement %s", got)
+		}
+	})
+
+	t.Run("Invalid options are rejected", func(t *testing.T) {
+		for _, opts := range []UpsertOptions{
+			{ConflictColumns: []string{"id"}, ConflictConstraint: "documents_pkey"},
+			{ConflictColumns: []string{"missing"}},
+			{ConflictConstraint: `bad"name`},
+			{UpdateColumns: []string{"created_at"}},
+			{UpdateColumns: []string{"search_vec"}},
+			{ExcludeColumns: []string{"missing"}},
+		} {
+			if err := conn.SetUpsertOptions(opts); err == nil {
+				t.Errorf("Expected %+v to be rejected", opts)
+			}
+		}
+	})
+}
@@ -14,6 +14,33 @@ type Queryable interface {
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 }
 
+// failedQueryable fails every statement with err, e.g. for a pool that does
+// not participate in the transaction of the context
+type failedQueryable struct {
+	err error
+}
+
+func (q failedQueryable) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, q.err
+}
+
+func (q failedQueryable) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, q.err
+}
+
+func (q failedQueryable) QueryRow(context.Context, string, ...any) pgx.Row {
+	return failedRow(q)
+}
+
+// failedRow is the row of a failedQueryable
+type failedRow struct {
+	err error
+}
+
+func (r failedRow) Scan(...any) error {
+	return r.err
+}
+
 // cockroachDBTx wraps a CockroachDBConnector to use a transaction instead of the pool
 type cockroachDBTx[T any, ID comparable] struct {
 	connector *CockroachDBConnector[T, ID]
@@ -25,11 +52,34 @@ type cockroachDBTx[T any, ID comparable] struct {
 // If the function returns an error, the transaction is rolled back.
 // If the function returns nil, the transaction is committed.
 // If the function panics, the transaction is rolled back and the panic is re-raised.
+// If the transaction fails with a serialization failure, it is rolled back and
+// the function runs again in a new transaction, see SetTxRetryOptions; the
+// function must not have side effects outside the transaction.
 func (r *CockroachDBConnector[T, ID]) WithTx(ctx context.Context, fn TxFunc[T, ID]) error {
-	tx, err := r.pool.Begin(ctx)
+	return r.WithTxOptions(ctx, TxOptions{}, fn)
+}
+
+// WithTxOptions is WithTx with the isolation level, access mode and priority
+// of opts instead of the pool defaults
+func (r *CockroachDBConnector[T, ID]) WithTxOptions(ctx context.Context, opts TxOptions, fn TxFunc[T, ID]) error {
+	return retryTx(ctx, r.txRetry.withTable(r.tableName), func() error {
+		return r.runTx(ctx, opts, fn)
+	})
+}
+
+// SetTxRetryOptions configures how WithTx reruns transactions failing with a
+// serialization failure, DefaultTxMaxRetries times by default
+func (r *CockroachDBConnector[T, ID]) SetTxRetryOptions(opts TxRetryOptions) {
+	r.txRetry = opts
+}
+
+// runTx runs fn in one transaction
+func (r *CockroachDBConnector[T, ID]) runTx(ctx context.Context, opts TxOptions, fn TxFunc[T, ID]) error {
+	tx, err := beginTx(ctx, r.pool, opts)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
+	tx = r.loggedTx(tx)
 
 	// Create transaction-scoped repository
 	txRepo := &cockroachDBTx[T, ID]{
@@ -71,73 +121,70 @@ func (t *cockroachDBTx[T, ID]) Create(ctx context.Context, item *T) error {
 		return fmt.Errorf("item cannot be nil")
 	}
 
-	values, err := t.connector.getValues(item)
-	if err != nil {
-		return err
-	}
-
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-		quoteIdentifier(t.connector.tableName),
-		joinQuotedColumns(t.connector.columns),
-		buildPlaceholders(len(t.connector.columns)),
-	)
-	_, err = t.tx.Exec(ctx, query, values...)
+	return t.connector.insert(ctx, t.tx, item)
+}
 
-	// Check for duplicate key error
-	if err != nil && contains(err.Error(), "duplicate key") {
-		return ErrItemAlreadyExists
+func (t *cockroachDBTx[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	var item T
+	row := t.tx.QueryRow(ctx, t.connector.readStatement(ctx, stmtGet), id)
+	dests, err := t.connector.getScanDestinations(&item)
+	if err != nil {
+		return nil, err
 	}
 
-	return err
+	err = row.Scan(dests...)
+	return &item, translateReadError(err)
 }
 
-func (t *cockroachDBTx[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+// GetForUpdate reads an item and locks its row until the transaction ends
+func (t *cockroachDBTx[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
 	var item T
-	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1",
-		joinQuotedColumns(t.connector.columns),
-		quoteIdentifier(t.connector.tableName),
-		quoteIdentifier(t.connector.columns[0]),
-	)
-	row := t.tx.QueryRow(ctx, query, id)
+	row := t.tx.QueryRow(ctx, t.connector.readStatement(ctx, stmtGetForUpdate), id)
 	dests, err := t.connector.getScanDestinations(&item)
 	if err != nil {
 		return nil, err
 	}
 
 	err = row.Scan(dests...)
-	return &item, err
+	return &item, translateReadError(err)
 }
 
-func (t *cockroachDBTx[T, ID]) BatchCreate(ctx context.Context, items []T) error {
-	if len(items) == 0 {
-		return nil
+// GetMany fetches the items with the given IDs within the transaction
+func (t *cockroachDBTx[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	if len(ids) == 0 {
+		return map[ID]*T{}, nil
 	}
 
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-		quoteIdentifier(t.connector.tableName),
-		joinQuotedColumns(t.connector.columns),
-		buildPlaceholders(len(t.connector.columns)),
-	)
+	query, args, err := t.connector.getManyQuery(ids)
+	if err != nil {
+		return nil, err
+	}
+	if t.connector.softDelete.hides(ctx) {
+		query += " AND " + t.connector.softDelete.liveSQL()
+	}
 
-	for _, item := range items {
-		values, err := t.connector.getValues(&item)
-		if err != nil {
-			return err
-		}
-		_, err = t.tx.Exec(ctx, query, values...)
-		if err != nil {
-			return err
-		}
+	rows, err := t.tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
+	return t.connector.scanByID(rows)
+}
+
+func (t *cockroachDBTx[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	return t.connector.batchCreate(ctx, t.tx, items)
 }
 
 func (t *cockroachDBTx[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
 	if filter == nil {
 		return nil, fmt.Errorf("filter cannot be nil")
 	}
-	query, args, err := t.connector.queryBuilder(filter)
+	query, args, err := t.connector.queryBuilder(t.connector.softDelete.scope(ctx, filter))
 	if err != nil {
 		return nil, err
 	}
@@ -147,6 +194,11 @@ func (t *cockroachDBTx[T, ID]) Query(ctx context.Context, filter *Filter) ([]T,
 	}
 	defer rows.Close()
 
+	// Grouped queries select a subset of the columns
+	if len(filter.GroupBy) > 0 {
+		return scanRowsByName[T](rows)
+	}
+
 	var results []T
 	for rows.Next() {
 		var item T
@@ -163,41 +215,17 @@ func (t *cockroachDBTx[T, ID]) Query(ctx context.Context, filter *Filter) ([]T,
 	return results, rows.Err()
 }
 
+// FindOne returns the first row matching the filter within the transaction, or ErrItemNotFound
+func (t *cockroachDBTx[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	return findOne(ctx, filter, t.Query)
+}
+
 func (t *cockroachDBTx[T, ID]) Update(ctx context.Context, item *T) error {
 	if item == nil {
 		return fmt.Errorf("item cannot be nil")
 	}
 
-	values, err := t.connector.getValues(item)
-	if err != nil {
-		return err
-	}
-
-	var setClause []string
-	numCols := len(t.connector.columns)
-	for i := 1; i < numCols; i++ {
-		setClause = append(setClause, fmt.Sprintf("%s = $%d", quoteIdentifier(t.connector.columns[i]), i))
-	}
-
-	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d",
-		quoteIdentifier(t.connector.tableName),
-		joinString(setClause, ", "),
-		quoteIdentifier(t.connector.columns[0]),
-		numCols,
-	)
-
-	id := t.connector.getID(item)
-	args := append(values[1:], id)
-	ct, err := t.tx.Exec(ctx, query, args...)
-	if err != nil {
-		return err
-	}
-
-	if ct.RowsAffected() == 0 {
-		return ErrNoUpdateItem
-	}
-
-	return nil
+	return t.connector.update(ctx, t.tx, item)
 }
 
 func (t *cockroachDBTx[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
@@ -205,54 +233,13 @@ func (t *cockroachDBTx[T, ID]) BatchUpdate(ctx context.Context, items []T) error
 		return nil
 	}
 
-	numCols := len(t.connector.columns)
-	var setClauses []string
-	for i := 1; i < numCols; i++ {
-		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", quoteIdentifier(t.connector.columns[i]), i))
-	}
-
-	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d",
-		quoteIdentifier(t.connector.tableName),
-		joinString(setClauses, ", "),
-		quoteIdentifier(t.connector.columns[0]),
-		numCols,
-	)
-
-	_, err := t.tx.Prepare(ctx, "tx_batch_update_stmt", query)
-	if err != nil {
-		return err
-	}
-
-	for _, item := range items {
-		values, err := t.connector.getValues(&item)
-		if err != nil {
-			return err
-		}
-
-		id := t.connector.getID(&item)
-		args := append(values[1:], id)
-		ct, err := t.tx.Exec(ctx, "tx_batch_update_stmt", args...)
-		if err != nil {
-			return err
-		}
-
-		if ct.RowsAffected() == 0 {
-			return fmt.Errorf("batch update item %v does not exist", item)
-		}
-	}
-
-	return nil
+	return t.connector.batchUpdate(ctx, t.tx, items)
 }
 
 func (t *cockroachDBTx[T, ID]) Delete(ctx context.Context, id ID) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1",
-		quoteIdentifier(t.connector.tableName),
-		quoteIdentifier(t.connector.columns[0]),
-	)
-
-	ct, err := t.tx.Exec(ctx, query, id)
+	ct, err := t.tx.Exec(ctx, t.connector.statement(stmtDelete), id)
 	if err != nil {
-		return err
+		return translateWriteError(err)
 	}
 
 	if ct.RowsAffected() == 0 {
@@ -267,32 +254,14 @@ func (t *cockroachDBTx[T, ID]) BatchDelete(ctx context.Context, items []ID) erro
 		return nil
 	}
 
-	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1",
-		quoteIdentifier(t.connector.tableName),
-		quoteIdentifier(t.connector.columns[0]),
-	)
-	_, err := t.tx.Prepare(ctx, "tx_batch_delete_stmt", query)
-	if err != nil {
-		return err
-	}
-
-	for _, id := range items {
-		ct, err := t.tx.Exec(ctx, "tx_batch_delete_stmt", id)
-		if err != nil {
-			return err
-		}
-		if ct.RowsAffected() == 0 {
-			return fmt.Errorf("%v row not deleted", id)
-		}
-	}
-
-	return nil
+	return t.connector.batchDelete(ctx, t.tx, items)
 }
 
 func (t *cockroachDBTx[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
 	if filter == nil {
 		return 0, fmt.Errorf("filter cannot be nil")
 	}
+	filter = t.connector.softDelete.scope(ctx, filter)
 
 	var args []any
 	argIndex := 1
@@ -314,15 +283,54 @@ func (t *cockroachDBTx[T, ID]) Count(ctx context.Context, filter *Filter) (int64
 	return count, err
 }
 
+// UpdateWhere sets the given columns on every row matching the filter within the transaction
+func (t *cockroachDBTx[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	query, args, err := t.connector.updateWhereQuery(filter, updates)
+	if err != nil {
+		return 0, err
+	}
+
+	ct, err := t.tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, translateWriteError(err)
+	}
+	return ct.RowsAffected(), nil
+}
+
+// DeleteWhere deletes every row matching the filter within the transaction
+func (t *cockroachDBTx[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	query, args, err := t.connector.deleteWhereQuery(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	ct, err := t.tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, translateWriteError(err)
+	}
+	return ct.RowsAffected(), nil
+}
+
+// GroupCount counts rows per group within the transaction
+func (t *cockroachDBTx[T, ID]) GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error) {
+	query, args, err := t.connector.groupCountQuery(t.connector.softDelete.scope(ctx, filter))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := t.tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanGroupCounts[T](rows, filter.GroupBy)
+}
+
 // Exists checks if an entity with the given ID exists within the transaction
 func (t *cockroachDBTx[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
-	query := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s = $1)",
-		quoteIdentifier(t.connector.tableName),
-		quoteIdentifier(t.connector.columns[0]),
-	)
-
 	var exists bool
-	err := t.tx.QueryRow(ctx, query, id).Scan(&exists)
+	err := t.tx.QueryRow(ctx, t.connector.readStatement(ctx, stmtExists), id).Scan(&exists)
 	return exists, err
 }
 
@@ -332,31 +340,7 @@ func (t *cockroachDBTx[T, ID]) Upsert(ctx context.Context, item *T) error {
 		return fmt.Errorf("item cannot be nil")
 	}
 
-	values, err := t.connector.getValues(item)
-	if err != nil {
-		return err
-	}
-
-	// Build the SET clause for ON CONFLICT DO UPDATE
-	var setClauses []string
-	numCols := len(t.connector.columns)
-	for i := 1; i < numCols; i++ {
-		setClauses = append(setClauses, fmt.Sprintf("%s = EXCLUDED.%s",
-			quoteIdentifier(t.connector.columns[i]),
-			quoteIdentifier(t.connector.columns[i]),
-		))
-	}
-
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
-		quoteIdentifier(t.connector.tableName),
-		joinQuotedColumns(t.connector.columns),
-		buildPlaceholders(len(t.connector.columns)),
-		quoteIdentifier(t.connector.columns[0]),
-		joinString(setClauses, ", "),
-	)
-
-	_, err = t.tx.Exec(ctx, query, values...)
-	return err
+	return t.connector.upsert(ctx, t.tx, item)
 }
 
 // BatchUpsert creates or updates multiple entities within the transaction
@@ -365,36 +349,7 @@ func (t *cockroachDBTx[T, ID]) BatchUpsert(ctx context.Context, items []T) error
 		return nil
 	}
 
-	// Build the SET clause for ON CONFLICT DO UPDATE
-	var setClauses []string
-	numCols := len(t.connector.columns)
-	for i := 1; i < numCols; i++ {
-		setClauses = append(setClauses, fmt.Sprintf("%s = EXCLUDED.%s",
-			quoteIdentifier(t.connector.columns[i]),
-			quoteIdentifier(t.connector.columns[i]),
-		))
-	}
-
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
-		quoteIdentifier(t.connector.tableName),
-		joinQuotedColumns(t.connector.columns),
-		buildPlaceholders(len(t.connector.columns)),
-		quoteIdentifier(t.connector.columns[0]),
-		joinString(setClauses, ", "),
-	)
-
-	for _, item := range items {
-		values, err := t.connector.getValues(&item)
-		if err != nil {
-			return err
-		}
-		_, err = t.tx.Exec(ctx, query, values...)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return t.connector.batchUpsert(ctx, t.tx, items)
 }
 
 // Helper functions
@@ -2,6 +2,7 @@ package sietch
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/seb7887/gofw/sietch/internal/testutils"
@@ -35,26 +36,21 @@ func TestInMemoryTransactionalInterface(t *testing.T) {
 func TestRedisTransactionalInterface(t *testing.T) {
 	ctx := context.Background()
 
-	t.Run("RedisConnector returns ErrUnsupportedOperation for WithTx", func(t *testing.T) {
-		// Create a minimal Redis connector (will fail on actual operations but that's OK for this test)
-		repo := &RedisConnector[testutils.Account, int64]{}
-
-		err := repo.WithTx(ctx, func(txRepo Repository[testutils.Account, int64]) error {
-			return nil
-		})
+	t.Run("RedisConnector implements Transactional", func(t *testing.T) {
+		var repo Repository[testutils.Account, int64] = &RedisConnector[testutils.Account, int64]{}
 
-		if err != ErrUnsupportedOperation {
-			t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+		if _, ok := repo.(Transactional[testutils.Account, int64]); !ok {
+			t.Error("RedisConnector should implement Transactional interface")
 		}
 	})
 
-	t.Run("RedisConnector returns ErrUnsupportedOperation for Count", func(t *testing.T) {
+	t.Run("RedisConnector returns ErrUnsupportedOperation for Count without a key prefix", func(t *testing.T) {
 		repo := &RedisConnector[testutils.Account, int64]{}
 
 		filter := &Filter{}
 		count, err := repo.Count(ctx, filter)
 
-		if err != ErrUnsupportedOperation {
+		if !errors.Is(err, ErrUnsupportedOperation) {
 			t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
 		}
 		if count != 0 {
@@ -0,0 +1,127 @@
+package sietch
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// UpsertOptions configures the ON CONFLICT clause of Upsert and BatchUpsert
+// on the CockroachDB connector
+type UpsertOptions struct {
+	// ConflictColumns are the columns of the unique index an insert
+	// conflicts on, the primary key if empty
+	ConflictColumns []string
+
+	// ConflictConstraint names the constraint an insert conflicts on
+	// instead (ON CONFLICT ON CONSTRAINT); exclusive with ConflictColumns
+	ConflictConstraint string
+
+	// UpdateColumns restricts the columns set on conflict; every column
+	// updates write if empty
+	UpdateColumns []string
+
+	// ExcludeColumns are never set on conflict, e.g. "created_at"
+	ExcludeColumns []string
+}
+
+// upsertConfig is the resolved ON CONFLICT clause of a connector
+type upsertConfig struct {
+	target  string // e.g. ("id") or ON CONSTRAINT "accounts_email_key"
+	updates []int  // columns set from EXCLUDED on conflict
+}
+
+// newUpsertConfig validates opts against the columns of codec. Only columns
+// written by both inserts and updates can be set on conflict.
+func newUpsertConfig(codec *entityCodec, opts UpsertOptions) (*upsertConfig, error) {
+	known := func(col string) error {
+		if !slices.Contains(codec.columns, col) {
+			return fmt.Errorf("unknown column '%s'", col)
+		}
+		return sanitizeIdentifier(col)
+	}
+
+	c := &upsertConfig{}
+	switch {
+	case len(opts.ConflictColumns) > 0 && opts.ConflictConstraint != "":
+		return nil, fmt.Errorf("ConflictColumns and ConflictConstraint are exclusive")
+	case opts.ConflictConstraint != "":
+		if err := sanitizeIdentifier(opts.ConflictConstraint); err != nil {
+			return nil, fmt.Errorf("invalid conflict constraint: %w", err)
+		}
+		c.target = "ON CONSTRAINT " + quoteIdentifier(opts.ConflictConstraint)
+	case len(opts.ConflictColumns) > 0:
+		for _, col := range opts.ConflictColumns {
+			if err := known(col); err != nil {
+				return nil, fmt.Errorf("invalid conflict column: %w", err)
+			}
+		}
+		c.target = "(" + joinQuotedColumns(opts.ConflictColumns) + ")"
+	default:
+		c.target = "(" + quoteIdentifier(codec.columns[codec.pk]) + ")"
+	}
+
+	for _, col := range slices.Concat(opts.UpdateColumns, opts.ExcludeColumns) {
+		if err := known(col); err != nil {
+			return nil, fmt.Errorf("invalid update column: %w", err)
+		}
+	}
+	for _, i := range codec.updates {
+		if codec.flags[i]&colOmit != 0 || slices.Contains(opts.ExcludeColumns, codec.columns[i]) {
+			continue
+		}
+		if len(opts.UpdateColumns) == 0 || slices.Contains(opts.UpdateColumns, codec.columns[i]) {
+			c.updates = append(c.updates, i)
+		}
+	}
+	for _, col := range opts.UpdateColumns {
+		if !slices.ContainsFunc(c.updates, func(i int) bool { return codec.columns[i] == col }) && !slices.Contains(opts.ExcludeColumns, col) {
+			return nil, fmt.Errorf("column '%s' cannot be updated on conflict", col)
+		}
+	}
+	return c, nil
+}
+
+// onConflictSQL returns the ON CONFLICT clause of the upsert of table. With
+// no column to set and no version to increment, conflicting rows are left
+// unchanged.
+func (c *upsertConfig) onConflictSQL(table string, codec *entityCodec) string {
+	clauses := make([]string, 0, len(c.updates)+1)
+	for _, i := range c.updates {
+		col := quoteIdentifier(codec.columns[i])
+		clauses = append(clauses, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+	}
+	if codec.version < 0 {
+		if len(clauses) == 0 {
+			return fmt.Sprintf(" ON CONFLICT %s DO NOTHING", c.target)
+		}
+		return fmt.Sprintf(" ON CONFLICT %s DO UPDATE SET %s", c.target, strings.Join(clauses, ", "))
+	}
+
+	quotedTable := quoteIdentifier(table)
+	v := quoteIdentifier(codec.columns[codec.version])
+	clauses = append(clauses, fmt.Sprintf("%s = %s.%s + 1", v, quotedTable, v))
+	return fmt.Sprintf(" ON CONFLICT %s DO UPDATE SET %s WHERE %s.%s = EXCLUDED.%s RETURNING %s",
+		c.target, strings.Join(clauses, ", "), quotedTable, v, v, v)
+}
+
+// SetUpsertOptions configures the conflict target of Upsert and BatchUpsert
+// and the columns they overwrite, e.g. to upsert by a unique email and never
+// overwrite created_at:
+//
+//	repo.SetUpsertOptions(sietch.UpsertOptions{
+//	    ConflictColumns: []string{"email"},
+//	    ExcludeColumns:  []string{"created_at"},
+//	})
+//
+// It rebuilds the CRUD statements, so call PrepareStatements again before
+// using prepared statements. Not safe to call concurrently with queries.
+func (r *CockroachDBConnector[T, ID]) SetUpsertOptions(opts UpsertOptions) error {
+	onConflict, err := newUpsertConfig(r.codec, opts)
+	if err != nil {
+		return err
+	}
+	r.onConflict = onConflict
+	r.buildStatements()
+	return nil
+}
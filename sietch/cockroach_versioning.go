@@ -0,0 +1,89 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// update runs the update statement for item on q. Versioned updates that
+// match no row fail with ErrVersionConflict if the row exists.
+func (r *CockroachDBConnector[T, ID]) update(ctx context.Context, q Queryable, item *T) error {
+	values, err := r.getValues(item)
+	if err != nil {
+		return err
+	}
+	id := r.getID(item)
+	args := r.updateArgs(values, id)
+
+	if r.codec.version < 0 {
+		ct, err := q.Exec(ctx, r.statement(stmtUpdate), args...)
+		if err != nil {
+			return translateWriteError(err)
+		}
+		if ct.RowsAffected() == 0 {
+			return ErrNoUpdateItem
+		}
+		return nil
+	}
+
+	var version int64
+	err = q.QueryRow(ctx, r.statement(stmtUpdate), args...).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		if err := q.QueryRow(ctx, r.existsStatement(), id).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrVersionConflict
+		}
+		return ErrNoUpdateItem
+	}
+	if err != nil {
+		return translateWriteError(err)
+	}
+	r.setItemVersion(item, version)
+	return nil
+}
+
+// upsert runs the upsert statement for item on q. Versioned upserts of an
+// existing row fail with ErrVersionConflict if its version differs.
+func (r *CockroachDBConnector[T, ID]) upsert(ctx context.Context, q Queryable, item *T) error {
+	values, err := r.getInsertValues(item)
+	if err != nil {
+		return err
+	}
+
+	if r.codec.version < 0 {
+		_, err = q.Exec(ctx, r.statement(stmtUpsert), values...)
+		return translateWriteError(err)
+	}
+
+	var version int64
+	err = q.QueryRow(ctx, r.statement(stmtUpsert), values...).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrVersionConflict
+	}
+	if err != nil {
+		return translateWriteError(err)
+	}
+	r.setItemVersion(item, version)
+	return nil
+}
+
+// updateArgs orders the column values of an item for the update statement:
+// the columns set by updates, then the ID and the version
+func (r *CockroachDBConnector[T, ID]) updateArgs(values []any, id ID) []any {
+	args := append(pick(values, r.codec.updates), id)
+	if r.codec.version < 0 {
+		return args
+	}
+	return append(args, values[r.codec.version])
+}
+
+// setItemVersion stores version in the version field of item
+func (r *CockroachDBConnector[T, ID]) setItemVersion(item *T, version int64) {
+	setVersion(settableField(reflect.ValueOf(item).Elem(), r.codec.fields[r.codec.version]), version)
+}
@@ -0,0 +1,521 @@
+package sietch
+
+import (
+	"bytes"
+	"cmp"
+	"database/sql/driver"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/shopspring/decimal"
+)
+
+// Converter normalizes values of a Go type so every connector stores,
+// compares and encodes them the same way.
+type Converter interface {
+	// Normalize returns the canonical value stored by every connector.
+	// It may also accept alternate inputs (e.g. a string for a UUID) and must
+	// return a value of the registered type.
+	Normalize(v any) (any, error)
+
+	// Compare orders two normalized values (-1, 0, 1)
+	Compare(a, b any) int
+
+	// EncodeString returns the text encoding of a value (used for Redis keys and documents)
+	EncodeString(v any) (string, error)
+
+	// DecodeString parses a value from its text encoding
+	DecodeString(s string) (any, error)
+}
+
+// ConverterRegistry maps Go types to their converters
+type ConverterRegistry struct {
+	mu         sync.RWMutex
+	converters map[reflect.Type]Converter
+	keys       []compareKey // fallbacks for types without a converter, in registration order
+}
+
+// compareKey makes the values of types implementing iface comparable by the
+// value key returns
+type compareKey struct {
+	iface reflect.Type
+	key   func(v any) (any, error)
+}
+
+// NewConverterRegistry creates a registry with the built-in converters for
+// time.Time (normalized to UTC), uuid.UUID, decimal.Decimal, pgtype.Numeric
+// and netip.Prefix (inet), and compares other driver.Valuer types by their
+// driver value
+func NewConverterRegistry() *ConverterRegistry {
+	r := &ConverterRegistry{converters: make(map[reflect.Type]Converter)}
+	r.Register(reflect.TypeOf(time.Time{}), NewTimeConverter(time.UTC))
+	r.Register(reflect.TypeOf(uuid.UUID{}), UUIDConverter{})
+	r.Register(reflect.TypeOf(decimal.Decimal{}), DecimalConverter{})
+	r.Register(reflect.TypeOf(pgtype.Numeric{}), NumericConverter{})
+	r.Register(reflect.TypeOf(netip.Prefix{}), InetConverter{})
+	r.RegisterCompareKey(valuerType, func(v any) (any, error) {
+		return v.(driver.Valuer).Value()
+	})
+	return r
+}
+
+// DefaultConverters is the registry used by all connectors
+var DefaultConverters = NewConverterRegistry()
+
+// Register sets the converter for a type, replacing any existing one
+func (r *ConverterRegistry) Register(t reflect.Type, c Converter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.converters[t] = c
+}
+
+// RegisterConverter registers a converter for T in DefaultConverters
+func RegisterConverter[T any](c Converter) {
+	DefaultConverters.Register(reflect.TypeOf((*T)(nil)).Elem(), c)
+}
+
+// Lookup returns the converter registered for a type
+func (r *ConverterRegistry) Lookup(t reflect.Type) (Converter, bool) {
+	if t == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.converters[t]
+	return c, ok
+}
+
+// RegisterCompareKey makes values of types implementing iface, directly or
+// through a pointer receiver, comparable in memory by the value key returns
+// when the type has no converter of its own. Keys are compared as numbers,
+// strings, times, byte slices or booleans. Fallbacks are tried in
+// registration order.
+func (r *ConverterRegistry) RegisterCompareKey(iface reflect.Type, key func(v any) (any, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = append(r.keys, compareKey{iface: iface, key: key})
+}
+
+// compareKeyFor returns the fallback matching t, a type without a converter,
+// and whether it is implemented through a pointer receiver
+func (r *ConverterRegistry) compareKeyFor(t reflect.Type) (compareKey, bool, bool) {
+	if t == nil {
+		return compareKey{}, false, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.converters[t]; ok {
+		return compareKey{}, false, false
+	}
+	for _, k := range r.keys {
+		if t.Implements(k.iface) {
+			return k, false, true
+		}
+		if t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(k.iface) {
+			return k, true, true
+		}
+	}
+	return compareKey{}, false, false
+}
+
+// hasCompareKey reports whether values of t are compared through a fallback key
+func (r *ConverterRegistry) hasCompareKey(t reflect.Type) bool {
+	_, _, ok := r.compareKeyFor(t)
+	return ok
+}
+
+// compareKeyOf returns the key v is compared by, if its type has a fallback
+func (r *ConverterRegistry) compareKeyOf(v any) (any, bool) {
+	k, viaPointer, ok := r.compareKeyFor(reflect.TypeOf(v))
+	if !ok {
+		return nil, false
+	}
+	if viaPointer {
+		p := reflect.New(reflect.TypeOf(v))
+		p.Elem().Set(reflect.ValueOf(v))
+		v = p.Interface()
+	}
+	key, err := k.key(v)
+	if err != nil {
+		return nil, false
+	}
+	return key, true
+}
+
+// normalizeValue normalizes v with its type's converter, returning v unchanged if none is registered
+func (r *ConverterRegistry) normalizeValue(v any) (any, error) {
+	c, ok := r.Lookup(reflect.TypeOf(v))
+	if !ok {
+		return v, nil
+	}
+	return c.Normalize(v)
+}
+
+// normalizeStruct normalizes every converter-backed field of a struct in
+// place, embedded ones included. ptr may be a shallow copy of an item: the
+// embedded pointers it goes through are copied rather than written to.
+func (r *ConverterRegistry) normalizeStruct(ptr any) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	v = v.Elem()
+	for _, f := range namedFields(v.Type()) {
+		field, ok := fieldByIndex(v, f.index)
+		if !ok || !field.CanSet() {
+			continue
+		}
+		c, ok := r.Lookup(field.Type())
+		if !ok {
+			continue
+		}
+		normalized, err := c.Normalize(field.Interface())
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+		detachedField(v, f.index).Set(reflect.ValueOf(normalized))
+	}
+	return nil
+}
+
+// compareConverted compares a field value against a condition value using the
+// field type's converter. The condition value is normalized first, so e.g. a
+// UUID string can be compared against a uuid.UUID field.
+//
+// Values of types without a converter but with a fallback compare key (e.g.
+// driver.Valuer implementations) are compared by their keys instead.
+func (r *ConverterRegistry) compareConverted(a, b any) (int, bool) {
+	c, ok := r.Lookup(reflect.TypeOf(a))
+	if !ok {
+		return r.compareByKey(a, b)
+	}
+	na, err := c.Normalize(a)
+	if err != nil {
+		return 0, false
+	}
+	nb, err := c.Normalize(b)
+	if err != nil {
+		return 0, false
+	}
+	return c.Compare(na, nb), true
+}
+
+// compareByKey compares a, whose type has a fallback compare key, against b.
+// A b without a fallback of its own is converted like a SQL argument, so
+// plain Go numbers compare against the int64 and float64 driver values.
+func (r *ConverterRegistry) compareByKey(a, b any) (int, bool) {
+	ka, ok := r.compareKeyOf(a)
+	if !ok {
+		return 0, false
+	}
+	kb, ok := r.compareKeyOf(b)
+	if !ok {
+		var err error
+		if kb, err = driver.DefaultParameterConverter.ConvertValue(b); err != nil {
+			return 0, false
+		}
+	}
+	return compareKeys(ka, kb)
+}
+
+// compareKeys orders two plain values of the same family: numbers of any Go
+// type, strings, times, byte slices or booleans
+func compareKeys(a, b any) (int, bool) {
+	if af, ok := toFloat64(a); ok {
+		bf, ok := toFloat64(b)
+		if !ok {
+			return 0, false
+		}
+		return cmp.Compare(af, bf), true
+	}
+
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		return strings.Compare(av, bv), ok
+	case time.Time:
+		bv, ok := b.(time.Time)
+		return av.Compare(bv), ok
+	case []byte:
+		bv, ok := b.([]byte)
+		return bytes.Compare(av, bv), ok
+	case bool:
+		bv, ok := b.(bool)
+		if !ok || av == bv {
+			return 0, ok
+		}
+		if av {
+			return 1, true
+		}
+		return -1, true
+	}
+	return 0, false
+}
+
+// TimeConverter normalizes time.Time values to a single location so values
+// with different zones compare and encode identically
+type TimeConverter struct {
+	Location *time.Location
+}
+
+// NewTimeConverter creates a time converter for the given location (UTC if nil)
+func NewTimeConverter(loc *time.Location) TimeConverter {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return TimeConverter{Location: loc}
+}
+
+// Normalize implements Converter. Accepts time.Time, *time.Time, RelativeTime
+// (resolved against the current time) and RFC 3339 strings.
+func (c TimeConverter) Normalize(v any) (any, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.In(c.Location), nil
+	case RelativeTime:
+		return t.Time().In(c.Location), nil
+	case *time.Time:
+		if t == nil {
+			return nil, fmt.Errorf("cannot normalize nil time")
+		}
+		return t.In(c.Location), nil
+	case string:
+		return c.DecodeString(t)
+	default:
+		return nil, fmt.Errorf("cannot convert %T to time.Time", v)
+	}
+}
+
+// Compare implements Converter
+func (c TimeConverter) Compare(a, b any) int {
+	return a.(time.Time).Compare(b.(time.Time))
+}
+
+// EncodeString implements Converter
+func (c TimeConverter) EncodeString(v any) (string, error) {
+	n, err := c.Normalize(v)
+	if err != nil {
+		return "", err
+	}
+	return n.(time.Time).Format(time.RFC3339Nano), nil
+}
+
+// DecodeString implements Converter
+func (c TimeConverter) DecodeString(s string) (any, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, err
+	}
+	return t.In(c.Location), nil
+}
+
+// UUIDConverter handles uuid.UUID values and their string forms
+type UUIDConverter struct{}
+
+// Normalize implements Converter. Accepts uuid.UUID, [16]byte and strings.
+func (UUIDConverter) Normalize(v any) (any, error) {
+	switch u := v.(type) {
+	case uuid.UUID:
+		return u, nil
+	case [16]byte:
+		return uuid.UUID(u), nil
+	case string:
+		return uuid.Parse(u)
+	case []byte:
+		return uuid.ParseBytes(u)
+	default:
+		return nil, fmt.Errorf("cannot convert %T to uuid.UUID", v)
+	}
+}
+
+// Compare implements Converter
+func (UUIDConverter) Compare(a, b any) int {
+	ua, ub := a.(uuid.UUID), b.(uuid.UUID)
+	return bytes.Compare(ua[:], ub[:])
+}
+
+// EncodeString implements Converter
+func (c UUIDConverter) EncodeString(v any) (string, error) {
+	n, err := c.Normalize(v)
+	if err != nil {
+		return "", err
+	}
+	return n.(uuid.UUID).String(), nil
+}
+
+// DecodeString implements Converter
+func (UUIDConverter) DecodeString(s string) (any, error) {
+	return uuid.Parse(s)
+}
+
+// DecimalConverter handles decimal.Decimal values so that numerically equal
+// decimals (1.5 and 1.50) compare as equal
+type DecimalConverter struct{}
+
+// Normalize implements Converter. Accepts decimal.Decimal, strings and Go numbers.
+func (DecimalConverter) Normalize(v any) (any, error) {
+	switch d := v.(type) {
+	case decimal.Decimal:
+		return d, nil
+	case string:
+		return decimal.NewFromString(d)
+	default:
+		if f, ok := toFloat64(v); ok {
+			return decimal.NewFromFloat(f), nil
+		}
+		return nil, fmt.Errorf("cannot convert %T to decimal.Decimal", v)
+	}
+}
+
+// Compare implements Converter
+func (DecimalConverter) Compare(a, b any) int {
+	return a.(decimal.Decimal).Cmp(b.(decimal.Decimal))
+}
+
+// EncodeString implements Converter
+func (c DecimalConverter) EncodeString(v any) (string, error) {
+	n, err := c.Normalize(v)
+	if err != nil {
+		return "", err
+	}
+	return n.(decimal.Decimal).String(), nil
+}
+
+// DecodeString implements Converter
+func (DecimalConverter) DecodeString(s string) (any, error) {
+	return decimal.NewFromString(s)
+}
+
+// NumericConverter handles pgtype.Numeric values so they compare numerically
+// like decimal.Decimal. NULL sorts before every number; -Infinity, Infinity
+// and NaN sort as in PostgreSQL, NaN being greater than any other value.
+type NumericConverter struct{}
+
+// Normalize implements Converter. Accepts pgtype.Numeric, decimal.Decimal,
+// strings and Go numbers.
+func (NumericConverter) Normalize(v any) (any, error) {
+	switch n := v.(type) {
+	case pgtype.Numeric:
+		return n, nil
+	case decimal.Decimal:
+		return pgtype.Numeric{Int: n.Coefficient(), Exp: n.Exponent(), Valid: true}, nil
+	case string:
+		return NumericConverter{}.DecodeString(n)
+	default:
+		d, err := DecimalConverter{}.Normalize(v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %T to pgtype.Numeric", v)
+		}
+		return NumericConverter{}.Normalize(d)
+	}
+}
+
+// Compare implements Converter
+func (NumericConverter) Compare(a, b any) int {
+	na, nb := a.(pgtype.Numeric), b.(pgtype.Numeric)
+	if ra, rb := numericRank(na), numericRank(nb); ra != rb || ra != 2 {
+		return cmp.Compare(ra, rb)
+	}
+	return numericDecimal(na).Cmp(numericDecimal(nb))
+}
+
+// numericRank orders the kinds of numeric values: NULL, -Infinity, finite
+// numbers, Infinity and NaN
+func numericRank(n pgtype.Numeric) int {
+	switch {
+	case !n.Valid:
+		return 0
+	case n.NaN:
+		return 4
+	case n.InfinityModifier == pgtype.NegativeInfinity:
+		return 1
+	case n.InfinityModifier == pgtype.Infinity:
+		return 3
+	}
+	return 2
+}
+
+// numericDecimal returns the value of a finite numeric
+func numericDecimal(n pgtype.Numeric) decimal.Decimal {
+	if n.Int == nil {
+		return decimal.Zero
+	}
+	return decimal.NewFromBigInt(n.Int, n.Exp)
+}
+
+// EncodeString implements Converter
+func (c NumericConverter) EncodeString(v any) (string, error) {
+	n, err := c.Normalize(v)
+	if err != nil {
+		return "", err
+	}
+	value, err := n.(pgtype.Numeric).Value()
+	if err != nil || value == nil {
+		return "", fmt.Errorf("cannot encode NULL numeric")
+	}
+	return value.(string), nil
+}
+
+// DecodeString implements Converter
+func (NumericConverter) DecodeString(s string) (any, error) {
+	var n pgtype.Numeric
+	if err := n.Scan(s); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// InetConverter handles netip.Prefix values, the type pgx scans inet and
+// cidr columns into. Plain addresses are normalized to single-host prefixes.
+type InetConverter struct{}
+
+// Normalize implements Converter. Accepts netip.Prefix, netip.Addr and
+// strings in either form.
+func (InetConverter) Normalize(v any) (any, error) {
+	switch p := v.(type) {
+	case netip.Prefix:
+		return p, nil
+	case netip.Addr:
+		return netip.PrefixFrom(p, p.BitLen()), nil
+	case string:
+		return InetConverter{}.DecodeString(p)
+	default:
+		return nil, fmt.Errorf("cannot convert %T to netip.Prefix", v)
+	}
+}
+
+// Compare implements Converter. Prefixes order by address, then by length.
+func (InetConverter) Compare(a, b any) int {
+	pa, pb := a.(netip.Prefix), b.(netip.Prefix)
+	if c := pa.Addr().Compare(pb.Addr()); c != 0 {
+		return c
+	}
+	return cmp.Compare(pa.Bits(), pb.Bits())
+}
+
+// EncodeString implements Converter
+func (c InetConverter) EncodeString(v any) (string, error) {
+	n, err := c.Normalize(v)
+	if err != nil {
+		return "", err
+	}
+	return n.(netip.Prefix).String(), nil
+}
+
+// DecodeString implements Converter
+func (InetConverter) DecodeString(s string) (any, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, err
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	return netip.ParsePrefix(s)
+}
@@ -0,0 +1,342 @@
+package sietch
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+type ledgerEntry struct {
+	ID     int64           `db:"id" json:"id"`
+	Ref    uuid.UUID       `db:"ref" json:"ref"`
+	Amount decimal.Decimal `db:"amount" json:"amount"`
+	At     time.Time       `db:"at" json:"at"`
+}
+
+func TestConverterRegistry_Builtins(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	local := time.Date(2024, 1, 1, 9, 0, 0, 0, tokyo)
+
+	n, err := DefaultConverters.normalizeValue(local)
+	if err != nil {
+		t.Fatalf("normalizeValue failed: %v", err)
+	}
+	if got := n.(time.Time); got.Location() != time.UTC || !got.Equal(local) {
+		t.Errorf("Expected UTC instant equal to input, got %v", got)
+	}
+
+	c, _ := DefaultConverters.Lookup(reflect.TypeOf(uuid.UUID{}))
+	id := uuid.New()
+	s, err := c.EncodeString(id)
+	if err != nil || s != id.String() {
+		t.Errorf("Expected %s, got %s (%v)", id, s, err)
+	}
+	decoded, err := c.DecodeString(s)
+	if err != nil || decoded.(uuid.UUID) != id {
+		t.Errorf("UUID round trip failed: %v", err)
+	}
+
+	if cmp, ok := DefaultConverters.compareConverted(decimal.RequireFromString("1.50"), "1.5"); !ok || cmp != 0 {
+		t.Errorf("Expected 1.50 == 1.5, got %d (%v)", cmp, ok)
+	}
+	if _, ok := DefaultConverters.compareConverted(uuid.New(), "not-a-uuid"); ok {
+		t.Error("Expected invalid UUID string not to be comparable")
+	}
+}
+
+func TestConverterRegistry_Custom(t *testing.T) {
+	type cents int64
+	r := NewConverterRegistry()
+	r.Register(reflect.TypeOf(time.Time{}), NewTimeConverter(time.FixedZone("X", 3600)))
+
+	n, _ := r.normalizeValue(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if n.(time.Time).Hour() != 1 {
+		t.Errorf("Expected custom location to be applied, got %v", n)
+	}
+	if _, ok := r.Lookup(reflect.TypeOf(cents(0))); ok {
+		t.Error("Expected no converter for unregistered type")
+	}
+	if v, _ := r.normalizeValue(cents(5)); v != cents(5) {
+		t.Error("Expected unregistered values to pass through")
+	}
+}
+
+func TestInMemoryConverters(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[ledgerEntry, int64](func(e *ledgerEntry) int64 { return e.ID })
+
+	ref := uuid.New()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	nyc := time.FixedZone("EST", -5*3600)
+	repo.BatchCreate(ctx, []ledgerEntry{
+		{ID: 1, Ref: ref, Amount: decimal.RequireFromString("10.50"), At: base},
+		{ID: 2, Ref: uuid.New(), Amount: decimal.RequireFromString("2"), At: base.Add(time.Hour).In(nyc)},
+		{ID: 3, Ref: uuid.New(), Amount: decimal.RequireFromString("100"), At: base.Add(-time.Hour)},
+	})
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected int
+	}{
+		{"uuid string equality", NewFilter().Where("ref", OpEqual, ref.String()).Build(), 1},
+		{"uuid in", NewFilter().Where("ref", OpIn, []any{ref.String()}).Build(), 1},
+		{"decimal scale-insensitive equality", NewFilter().Where("amount", OpEqual, decimal.RequireFromString("10.5")).Build(), 1},
+		{"decimal numeric comparison", NewFilter().Where("amount", OpGreaterThan, 5).Build(), 2},
+		{"time across zones", NewFilter().Where("at", OpEqual, base.Add(time.Hour)).Build(), 1},
+		{"time range", NewFilter().Where("at", OpGreaterThanOrEqual, base.Format(time.RFC3339)).Build(), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.Query(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if len(results) != tt.expected {
+				t.Errorf("Expected %d results, got %d", tt.expected, len(results))
+			}
+		})
+	}
+
+	t.Run("sort by decimal and time", func(t *testing.T) {
+		byAmount, _ := repo.Query(ctx, NewFilter().OrderBy("amount", SortAsc).Build())
+		if byAmount[0].ID != 2 || byAmount[2].ID != 3 {
+			t.Errorf("Unexpected decimal order: %v, %v, %v", byAmount[0].ID, byAmount[1].ID, byAmount[2].ID)
+		}
+		byTime, _ := repo.Query(ctx, NewFilter().OrderBy("at", SortDesc).Build())
+		if byTime[0].ID != 2 || byTime[2].ID != 3 {
+			t.Errorf("Unexpected time order: %v, %v, %v", byTime[0].ID, byTime[1].ID, byTime[2].ID)
+		}
+	})
+}
+
+func TestCockroachDBConverters(t *testing.T) {
+	conn, err := NewCockroachDBConnector[ledgerEntry, int64](
+		&pgxpool.Pool{},
+		"ledger",
+		func(e *ledgerEntry) int64 { return e.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	at := time.Date(2024, 1, 1, 9, 0, 0, 0, time.FixedZone("JST", 9*3600))
+	values, err := conn.getValues(&ledgerEntry{ID: 1, At: at})
+	if err != nil {
+		t.Fatalf("getValues failed: %v", err)
+	}
+	if values[3].(time.Time).Location() != time.UTC {
+		t.Errorf("Expected time value normalized to UTC, got %v", values[3])
+	}
+
+	_, args, err := conn.queryBuilder(NewFilter().Where("at", OpGreaterThan, at).Build())
+	if err != nil {
+		t.Fatalf("queryBuilder failed: %v", err)
+	}
+	if args[0].(time.Time).Location() != time.UTC {
+		t.Errorf("Expected time argument normalized to UTC, got %v", args[0])
+	}
+}
+
+func TestRedisMarshalNormalized(t *testing.T) {
+	at := time.Date(2024, 1, 1, 9, 0, 0, 0, time.FixedZone("JST", 9*3600))
+	item := &ledgerEntry{ID: 1, At: at}
+
+	data, err := marshalNormalized(item)
+	if err != nil {
+		t.Fatalf("marshalNormalized failed: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc["at"] != "2024-01-01T00:00:00Z" {
+		t.Errorf("Expected UTC encoding, got %v", doc["at"])
+	}
+	if item.At.Location() == time.UTC {
+		t.Error("Expected original item not to be modified")
+	}
+}
+
+// grade is stored as its letter, so it must be compared by its driver value
+type grade int
+
+func (g grade) Value() (driver.Value, error) {
+	return string(rune('A' + int(g))), nil
+}
+
+// money only implements driver.Valuer through its pointer
+type money struct {
+	cents int64
+}
+
+func (m *money) Value() (driver.Value, error) {
+	return m.cents, nil
+}
+
+func (m *money) Scan(src any) error {
+	cents, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into money", src)
+	}
+	m.cents = cents
+	return nil
+}
+
+type gradedEntry struct {
+	ID      int64                `db:"id"`
+	Grade   grade                `db:"grade"`
+	Price   money                `db:"price"`
+	Score   pgtype.Numeric       `db:"score"`
+	Network netip.Prefix         `db:"network"`
+	Tags    pgtype.Array[string] `db:"tags"`
+}
+
+func TestConverterRegistry_CompareKeys(t *testing.T) {
+	if cmp, ok := DefaultConverters.compareConverted(grade(1), "B"); !ok || cmp != 0 {
+		t.Errorf("Expected grade 1 to equal its driver value, got %d (%v)", cmp, ok)
+	}
+	if cmp, ok := DefaultConverters.compareConverted(money{cents: 150}, 100); !ok || cmp != 1 {
+		t.Errorf("Expected pointer receiver valuer to compare by value, got %d (%v)", cmp, ok)
+	}
+	if _, ok := DefaultConverters.compareConverted(grade(1), 1); ok {
+		t.Error("Expected a string key not to be comparable with a number")
+	}
+
+	type celsius struct{ deg float64 }
+	r := NewConverterRegistry()
+	r.RegisterCompareKey(reflect.TypeOf((*fmt.Stringer)(nil)).Elem(), func(v any) (any, error) {
+		return v.(fmt.Stringer).String(), nil
+	})
+	if _, ok := r.compareConverted(celsius{}, "x"); ok {
+		t.Error("Expected no compare key for unrelated types")
+	}
+	if cmp, ok := r.compareConverted(netip.MustParseAddr("10.0.0.1"), "10.0.0.1"); !ok || cmp != 0 {
+		t.Errorf("Expected custom compare key to be used, got %d (%v)", cmp, ok)
+	}
+}
+
+func TestPgtypeConverters(t *testing.T) {
+	c, _ := DefaultConverters.Lookup(reflect.TypeOf(pgtype.Numeric{}))
+	a, _ := c.Normalize("1.50")
+	b, _ := c.Normalize(decimal.RequireFromString("1.5"))
+	if c.Compare(a, b) != 0 {
+		t.Error("Expected numerics to compare by value")
+	}
+	nan, _ := c.DecodeString("NaN")
+	if c.Compare(nan, a) != 1 || c.Compare(pgtype.Numeric{}, a) != -1 {
+		t.Error("Expected NULL < numbers < NaN")
+	}
+	if s, err := c.EncodeString(a); err != nil || s != "1.50" {
+		t.Errorf("Expected 1.50, got %s (%v)", s, err)
+	}
+
+	inet, _ := DefaultConverters.Lookup(reflect.TypeOf(netip.Prefix{}))
+	host, err := inet.Normalize("192.168.1.1")
+	if err != nil || host.(netip.Prefix) != netip.MustParsePrefix("192.168.1.1/32") {
+		t.Errorf("Expected a single-host prefix, got %v (%v)", host, err)
+	}
+	subnet, _ := inet.Normalize("192.168.1.0/24")
+	if inet.Compare(subnet, host) != -1 {
+		t.Error("Expected prefixes to order by address")
+	}
+}
+
+func TestInMemoryCustomColumnTypes(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[gradedEntry, int64](func(e *gradedEntry) int64 { return e.ID })
+
+	numeric := func(s string) pgtype.Numeric {
+		n, _ := NumericConverter{}.DecodeString(s)
+		return n.(pgtype.Numeric)
+	}
+	tags := func(tags ...string) pgtype.Array[string] {
+		return pgtype.Array[string]{Elements: tags, Dims: []pgtype.ArrayDimension{{Length: int32(len(tags)), LowerBound: 1}}, Valid: true}
+	}
+	repo.BatchCreate(ctx, []gradedEntry{
+		{ID: 1, Grade: 0, Price: money{cents: 500}, Score: numeric("9.5"), Network: netip.MustParsePrefix("10.0.0.0/8"), Tags: tags("a", "b")},
+		{ID: 2, Grade: 2, Price: money{cents: 100}, Score: numeric("10"), Network: netip.MustParsePrefix("192.168.0.0/16"), Tags: tags("c")},
+		{ID: 3, Grade: 1, Price: money{cents: 300}, Score: numeric("9.50"), Network: netip.MustParsePrefix("172.16.0.0/12"), Tags: tags("b", "c")},
+	})
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected int
+	}{
+		{"valuer equality by driver value", NewFilter().Where("grade", OpEqual, "C").Build(), 1},
+		{"valuer range", NewFilter().Where("grade", OpLessThan, "C").Build(), 2},
+		{"pointer receiver valuer", NewFilter().Where("price", OpGreaterThanOrEqual, 300).Build(), 2},
+		{"numeric by value", NewFilter().Where("score", OpEqual, "9.5").Build(), 2},
+		{"numeric range", NewFilter().Where("score", OpGreaterThan, 9.75).Build(), 1},
+		{"inet", NewFilter().Where("network", OpEqual, "10.0.0.0/8").Build(), 1},
+		{"pgtype array contains", NewFilter().Where("tags", OpArrayContains, []string{"b"}).Build(), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.Query(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if len(results) != tt.expected {
+				t.Errorf("Expected %d results, got %d", tt.expected, len(results))
+			}
+		})
+	}
+
+	t.Run("sort by valuer and numeric", func(t *testing.T) {
+		byGrade, _ := repo.Query(ctx, NewFilter().OrderBy("grade", SortDesc).Build())
+		if byGrade[0].ID != 2 || byGrade[2].ID != 1 {
+			t.Errorf("Unexpected grade order: %v, %v, %v", byGrade[0].ID, byGrade[1].ID, byGrade[2].ID)
+		}
+		byScore, _ := repo.Query(ctx, NewFilter().OrderBy("score", SortDesc).OrderBy("id", SortAsc).Build())
+		if byScore[0].ID != 2 || byScore[1].ID != 1 {
+			t.Errorf("Unexpected numeric order: %v, %v, %v", byScore[0].ID, byScore[1].ID, byScore[2].ID)
+		}
+	})
+}
+
+func TestCockroachDBCustomColumnTypes(t *testing.T) {
+	conn, err := NewCockroachDBConnector[gradedEntry, int64](
+		&pgxpool.Pool{},
+		"graded",
+		func(e *gradedEntry) int64 { return e.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	item := gradedEntry{ID: 1, Grade: 2, Price: money{cents: 250}}
+	values, err := conn.getValues(&item)
+	if err != nil {
+		t.Fatalf("getValues failed: %v", err)
+	}
+	if _, ok := values[2].(driver.Valuer); !ok {
+		t.Errorf("Expected the pointer receiver valuer to be bound, got %T", values[2])
+	}
+	if v, _ := values[1].(driver.Valuer).Value(); v != "C" {
+		t.Errorf("Expected grade to encode itself, got %v", v)
+	}
+
+	var scanned gradedEntry
+	dests, err := conn.getScanDestinations(&scanned)
+	if err != nil {
+		t.Fatalf("getScanDestinations failed: %v", err)
+	}
+	if err := dests[2].(sql.Scanner).Scan(int64(250)); err != nil || scanned.Price != item.Price {
+		t.Errorf("Expected price to scan back, got %v (%v)", scanned.Price, err)
+	}
+}
@@ -0,0 +1,682 @@
+package sietch
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// KeyProvider supplies the AES keys of encrypted fields. Keys are 16, 24 or
+// 32 bytes long, selecting AES-128, AES-192 or AES-256. The ID of the key a
+// value was encrypted with is stored with it, so keys can be rotated: new
+// writes use the current key while older values still decrypt.
+type KeyProvider interface {
+	// EncryptionKey returns the key to encrypt new values with and its ID
+	EncryptionKey(ctx context.Context) (id string, key []byte, err error)
+
+	// DecryptionKey returns the key with the given ID
+	DecryptionKey(ctx context.Context, id string) ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider holding its keys in memory
+type StaticKeyProvider struct {
+	Keys    map[string][]byte // keys by ID
+	Current string            // ID of the key encrypting new values
+}
+
+// EncryptionKey implements KeyProvider
+func (p *StaticKeyProvider) EncryptionKey(ctx context.Context) (string, []byte, error) {
+	key, err := p.DecryptionKey(ctx, p.Current)
+	return p.Current, key, err
+}
+
+// DecryptionKey implements KeyProvider
+func (p *StaticKeyProvider) DecryptionKey(_ context.Context, id string) ([]byte, error) {
+	key, ok := p.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrDecryption, id)
+	}
+	return key, nil
+}
+
+// KeyWrapper encrypts data keys with a key encryption key that never leaves
+// it, such as a KMS key
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// EnvelopeKeyProvider is a KeyProvider for envelope encryption: values are
+// encrypted with a random data key, and the data key, wrapped by a
+// KeyWrapper, is stored with them as the key ID. Unwrapped data keys are
+// cached, so the KeyWrapper is called once per data key and process.
+type EnvelopeKeyProvider struct {
+	wrapper KeyWrapper
+
+	mu      sync.Mutex
+	current string
+	keys    map[string][]byte // unwrapped data keys by wrapped key
+}
+
+// NewEnvelopeKeyProvider creates an envelope KeyProvider wrapping its data
+// keys with wrapper
+func NewEnvelopeKeyProvider(wrapper KeyWrapper) *EnvelopeKeyProvider {
+	return &EnvelopeKeyProvider{wrapper: wrapper, keys: make(map[string][]byte)}
+}
+
+// Rotate makes the next write generate a new data key
+func (p *EnvelopeKeyProvider) Rotate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current = ""
+}
+
+// EncryptionKey implements KeyProvider, generating an AES-256 data key on
+// first use
+func (p *EnvelopeKeyProvider) EncryptionKey(ctx context.Context) (string, []byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current != "" {
+		return p.current, p.keys[p.current], nil
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", nil, err
+	}
+	wrapped, err := p.wrapper.WrapKey(ctx, key)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	p.current = base64.StdEncoding.EncodeToString(wrapped)
+	p.keys[p.current] = key
+	return p.current, key, nil
+}
+
+// DecryptionKey implements KeyProvider
+func (p *EnvelopeKeyProvider) DecryptionKey(ctx context.Context, id string) ([]byte, error) {
+	p.mu.Lock()
+	key, ok := p.keys[id]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed data key", ErrDecryption)
+	}
+	key, err = p.wrapper.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecryption, err)
+	}
+
+	p.mu.Lock()
+	p.keys[id] = key
+	p.mu.Unlock()
+	return key, nil
+}
+
+// encryptedPrefix marks encrypted values: string fields hold the prefix and
+// the base64 encoded ciphertext, []byte fields the prefix and the ciphertext
+const encryptedPrefix = "enc:v1:"
+
+// encryptedField is a field tagged `encrypted:"true"`
+type encryptedField struct {
+	index  []int // struct field index path
+	column string
+	name   string // Go field name
+	bytes  bool   // []byte rather than string
+}
+
+// encryptedFields returns the encrypted fields of T
+func encryptedFields[T any]() ([]encryptedField, error) {
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("entity must be a struct")
+	}
+
+	var fields []encryptedField
+	for _, f := range namedFields(typ) {
+		field, column := f.field, f.column
+		if field.Tag.Get("encrypted") != "true" {
+			continue
+		}
+		switch {
+		case field.Type.Kind() == reflect.String:
+			fields = append(fields, encryptedField{index: f.index, column: column, name: field.Name})
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Uint8:
+			fields = append(fields, encryptedField{index: f.index, column: column, name: field.Name, bytes: true})
+		default:
+			return nil, fmt.Errorf("field %s: only string and []byte fields can be encrypted", field.Name)
+		}
+	}
+	return fields, nil
+}
+
+// EncryptedRepository wraps a repository and encrypts the fields tagged
+// `encrypted:"true"` with AES-GCM before they are stored, decrypting them on
+// read, so the backend and anything behind it only ever see ciphertext:
+//
+//	type Customer struct {
+//	    ID    string `db:"id"`
+//	    Email string `db:"email" encrypted:"true"`
+//	}
+//
+// Encrypted string fields are stored as "enc:v1:" and the base64 encoded
+// ciphertext, so their columns must be text and wider than the plaintext.
+// Values without the prefix are read as plaintext, which lets existing rows
+// be encrypted gradually. Each value is bound to its column and the ID of its
+// row, so ciphertext copied to another column or row fails to decrypt with
+// ErrDecryption; for the same reason UpdateWhere cannot set encrypted fields.
+//
+// Encryption is randomized, so encrypted fields cannot be filtered, sorted
+// or grouped on: filters using them fail with ErrInvalidFilter. Caches keep
+// what the repository they wrap returns: wrap the EncryptedRepository to
+// cache plaintext, or wrap the cache with it to keep ciphertext in the cache.
+type EncryptedRepository[T any, ID comparable] struct {
+	base   Repository[T, ID]
+	getID  func(*T) ID
+	keys   KeyProvider
+	fields []encryptedField
+}
+
+// NewEncryptedRepository creates a repository encrypting the tagged fields
+// of T with the keys of keys. getID returns the row ID each value is bound
+// to, see PKAccessor if nil. T must have at least one encrypted field and
+// an application-assigned ID: the ID is sealed into each ciphertext before
+// the item is written, so a database-generated pk is rejected.
+func NewEncryptedRepository[T any, ID comparable](base Repository[T, ID], getID func(*T) ID, keys KeyProvider) (*EncryptedRepository[T, ID], error) {
+	if keys == nil {
+		return nil, fmt.Errorf("key provider cannot be nil")
+	}
+	getID, err := resolveGetID(getID)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := encryptedFields[T]()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("entity has no encrypted fields")
+	}
+	if typ := reflect.TypeFor[T](); typ.Kind() == reflect.Struct {
+		for _, f := range dbFields(typ) {
+			if slices.Contains(f.options, "pk") && (slices.Contains(f.options, "omit") || slices.Contains(f.options, "generated")) {
+				return nil, fmt.Errorf("pk field %s is generated by the database: encrypted entities need an application-assigned ID", f.name)
+			}
+		}
+	}
+	return &EncryptedRepository[T, ID]{base: base, getID: getID, keys: keys, fields: fields}, nil
+}
+
+// associatedData binds a ciphertext to the column and row it is stored in
+func associatedData[ID comparable](column string, id ID) []byte {
+	return fmt.Appendf(nil, "%s\x00%v", column, id)
+}
+
+// encrypt seals plaintext for column of row id with the current key
+func (r *EncryptedRepository[T, ID]) encrypt(ctx context.Context, column string, id ID, plaintext []byte) ([]byte, error) {
+	keyID, key, err := r.keys.EncryptionKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyID) > 0xFFFF {
+		return nil, fmt.Errorf("key ID is too long")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// key ID length, key ID, nonce, sealed plaintext
+	out := binary.BigEndian.AppendUint16(nil, uint16(len(keyID)))
+	out = append(out, keyID...)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, associatedData(column, id)), nil
+}
+
+// decrypt opens a ciphertext of encrypt
+func (r *EncryptedRepository[T, ID]) decrypt(ctx context.Context, column string, id ID, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 2 {
+		return nil, fmt.Errorf("%w: %s: truncated value", ErrDecryption, column)
+	}
+	n := int(binary.BigEndian.Uint16(ciphertext))
+	if len(ciphertext) < 2+n {
+		return nil, fmt.Errorf("%w: %s: truncated value", ErrDecryption, column)
+	}
+	key, err := r.keys.DecryptionKey(ctx, string(ciphertext[2:2+n]))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := ciphertext[2+n:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: %s: truncated value", ErrDecryption, column)
+	}
+	plaintext, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], associatedData(column, id))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrDecryption, column, err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptValue encrypts value, a string or []byte, for field of row id
+func (r *EncryptedRepository[T, ID]) encryptValue(ctx context.Context, field encryptedField, id ID, value any) (any, error) {
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Kind() == reflect.String:
+		sealed, err := r.encrypt(ctx, field.column, id, []byte(v.String()))
+		if err != nil {
+			return nil, err
+		}
+		return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		if v.IsNil() {
+			return []byte(nil), nil
+		}
+		sealed, err := r.encrypt(ctx, field.column, id, v.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(encryptedPrefix), sealed...), nil
+	default:
+		return nil, fmt.Errorf("%s: cannot encrypt a %T", field.column, value)
+	}
+}
+
+// seal returns a copy of item with its encrypted fields encrypted. item
+// must have its ID, as open verifies the ciphertexts against it.
+func (r *EncryptedRepository[T, ID]) seal(ctx context.Context, item *T) (*T, error) {
+	sealed := *item
+	id := r.getID(item)
+	var zero ID
+	if id == zero {
+		return nil, fmt.Errorf("cannot encrypt an item without an ID: %w", ErrInvalidID)
+	}
+	v := reflect.ValueOf(&sealed).Elem()
+	for _, field := range r.fields {
+		if _, ok := fieldByIndex(v, field.index); !ok {
+			continue // in a nil embedded pointer
+		}
+		f := detachedField(v, field.index)
+		value, err := r.encryptValue(ctx, field, id, f.Interface())
+		if err != nil {
+			return nil, err
+		}
+		f.Set(reflect.ValueOf(value).Convert(f.Type()))
+	}
+	return &sealed, nil
+}
+
+// written copies the fields the backend may have set on sealed, such as a
+// version, back to item, keeping the plaintext of its encrypted fields
+func (r *EncryptedRepository[T, ID]) written(item, sealed *T) {
+	v := reflect.ValueOf(item).Elem()
+	plain := make([]reflect.Value, len(r.fields))
+	for i, field := range r.fields {
+		if f, ok := fieldByIndex(v, field.index); ok {
+			plain[i] = reflect.ValueOf(f.Interface())
+		}
+	}
+	*item = *sealed
+	for i, field := range r.fields {
+		if plain[i].IsValid() {
+			settableField(v, field.index).Set(plain[i])
+		}
+	}
+}
+
+// open decrypts the encrypted fields of item in place
+func (r *EncryptedRepository[T, ID]) open(ctx context.Context, item *T) error {
+	id := r.getID(item)
+	v := reflect.ValueOf(item).Elem()
+	for _, field := range r.fields {
+		f, ok := fieldByIndex(v, field.index)
+		if !ok {
+			continue // in a nil embedded pointer
+		}
+		if field.bytes {
+			value := f.Bytes()
+			if !bytes.HasPrefix(value, []byte(encryptedPrefix)) {
+				continue
+			}
+			plaintext, err := r.decrypt(ctx, field.column, id, value[len(encryptedPrefix):])
+			if err != nil {
+				return err
+			}
+			f.SetBytes(plaintext)
+			continue
+		}
+
+		encoded, ok := strings.CutPrefix(f.String(), encryptedPrefix)
+		if !ok {
+			continue
+		}
+		sealed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrDecryption, field.column, err)
+		}
+		plaintext, err := r.decrypt(ctx, field.column, id, sealed)
+		if err != nil {
+			return err
+		}
+		f.SetString(string(plaintext))
+	}
+	return nil
+}
+
+// openAll decrypts the encrypted fields of items in place
+func (r *EncryptedRepository[T, ID]) openAll(ctx context.Context, items []T) error {
+	for i := range items {
+		if err := r.open(ctx, &items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptedColumn returns the encrypted field of a column or Go field name,
+// if any
+func (r *EncryptedRepository[T, ID]) encryptedColumn(name string) (encryptedField, bool) {
+	for _, field := range r.fields {
+		if field.column == name || field.name == name {
+			return field, true
+		}
+	}
+	return encryptedField{}, false
+}
+
+// checkFilter rejects filters on encrypted fields
+func (r *EncryptedRepository[T, ID]) checkFilter(filter *Filter) error {
+	if filter == nil {
+		return nil
+	}
+	var fields []string
+	var walk func(conditions []Condition)
+	walk = func(conditions []Condition) {
+		for _, c := range conditions {
+			fields = append(fields, c.Field)
+			walk(c.Conditions)
+		}
+	}
+	walk(filter.Conditions)
+	walk(filter.Having)
+	for _, s := range filter.Sort {
+		fields = append(fields, s.Field)
+	}
+	fields = append(fields, filter.GroupBy...)
+
+	for _, field := range fields {
+		if _, ok := r.encryptedColumn(field); ok {
+			return fmt.Errorf("%w: %s is encrypted and cannot be filtered, sorted or grouped on", ErrInvalidFilter, field)
+		}
+	}
+	return nil
+}
+
+func (r *EncryptedRepository[T, ID]) Create(ctx context.Context, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	sealed, err := r.seal(ctx, item)
+	if err != nil {
+		return err
+	}
+	if err := r.base.Create(ctx, sealed); err != nil {
+		return err
+	}
+	r.written(item, sealed)
+	return nil
+}
+
+func (r *EncryptedRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	item, err := r.base.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := *item
+	if err := r.open(ctx, &decrypted); err != nil {
+		return nil, err
+	}
+	return &decrypted, nil
+}
+
+func (r *EncryptedRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	items, err := r.base.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make(map[ID]*T, len(items))
+	for id, item := range items {
+		copied := *item
+		if err := r.open(ctx, &copied); err != nil {
+			return nil, err
+		}
+		decrypted[id] = &copied
+	}
+	return decrypted, nil
+}
+
+func (r *EncryptedRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	return r.base.Exists(ctx, id)
+}
+
+func (r *EncryptedRepository[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	sealed, err := r.sealAll(ctx, items)
+	if err != nil {
+		return err
+	}
+	if err := r.base.BatchCreate(ctx, sealed); err != nil {
+		return err
+	}
+	r.writtenAll(items, sealed)
+	return nil
+}
+
+// sealAll returns a copy of items with their encrypted fields encrypted
+func (r *EncryptedRepository[T, ID]) sealAll(ctx context.Context, items []T) ([]T, error) {
+	sealed := make([]T, len(items))
+	for i := range items {
+		s, err := r.seal(ctx, &items[i])
+		if err != nil {
+			return nil, err
+		}
+		sealed[i] = *s
+	}
+	return sealed, nil
+}
+
+func (r *EncryptedRepository[T, ID]) writtenAll(items, sealed []T) {
+	for i := range items {
+		r.written(&items[i], &sealed[i])
+	}
+}
+
+func (r *EncryptedRepository[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
+	if err := r.checkFilter(filter); err != nil {
+		return nil, err
+	}
+	results, err := r.base.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	// copy, as the base may share its items, e.g. a cache
+	results = append([]T(nil), results...)
+	if err := r.openAll(ctx, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *EncryptedRepository[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	if err := r.checkFilter(filter); err != nil {
+		return nil, err
+	}
+	item, err := r.base.FindOne(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := *item
+	if err := r.open(ctx, &decrypted); err != nil {
+		return nil, err
+	}
+	return &decrypted, nil
+}
+
+func (r *EncryptedRepository[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
+	if err := r.checkFilter(filter); err != nil {
+		return 0, err
+	}
+	return r.base.Count(ctx, filter)
+}
+
+func (r *EncryptedRepository[T, ID]) Update(ctx context.Context, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	sealed, err := r.seal(ctx, item)
+	if err != nil {
+		return err
+	}
+	if err := r.base.Update(ctx, sealed); err != nil {
+		return err
+	}
+	r.written(item, sealed)
+	return nil
+}
+
+func (r *EncryptedRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	sealed, err := r.sealAll(ctx, items)
+	if err != nil {
+		return err
+	}
+	if err := r.base.BatchUpdate(ctx, sealed); err != nil {
+		return err
+	}
+	r.writtenAll(items, sealed)
+	return nil
+}
+
+func (r *EncryptedRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	return r.base.Delete(ctx, id)
+}
+
+func (r *EncryptedRepository[T, ID]) BatchDelete(ctx context.Context, ids []ID) error {
+	return r.base.BatchDelete(ctx, ids)
+}
+
+func (r *EncryptedRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	sealed, err := r.seal(ctx, item)
+	if err != nil {
+		return err
+	}
+	if err := r.base.Upsert(ctx, sealed); err != nil {
+		return err
+	}
+	r.written(item, sealed)
+	return nil
+}
+
+func (r *EncryptedRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	sealed, err := r.sealAll(ctx, items)
+	if err != nil {
+		return err
+	}
+	if err := r.base.BatchUpsert(ctx, sealed); err != nil {
+		return err
+	}
+	r.writtenAll(items, sealed)
+	return nil
+}
+
+// UpdateWhere updates the items matching filter; neither the filter nor the
+// updates may use encrypted fields, whose values are bound to their rows
+func (r *EncryptedRepository[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	if err := r.checkFilter(filter); err != nil {
+		return 0, err
+	}
+	for column := range updates {
+		if _, ok := r.encryptedColumn(column); ok {
+			return 0, fmt.Errorf("%w: %s is encrypted and can only be set by Update", ErrUnsupportedOperation, column)
+		}
+	}
+	return r.base.UpdateWhere(ctx, filter, updates)
+}
+
+func (r *EncryptedRepository[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	if err := r.checkFilter(filter); err != nil {
+		return 0, err
+	}
+	return r.base.DeleteWhere(ctx, filter)
+}
+
+// GroupCount delegates to the base repository if it implements Grouper
+func (r *EncryptedRepository[T, ID]) GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error) {
+	grouper, ok := r.base.(Grouper)
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	if err := r.checkFilter(filter); err != nil {
+		return nil, err
+	}
+	return grouper.GroupCount(ctx, filter)
+}
+
+// GetForUpdate delegates to the base repository if it implements RowLocker
+func (r *EncryptedRepository[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
+	locker, ok := r.base.(RowLocker[T, ID])
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	item, err := locker.GetForUpdate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := *item
+	if err := r.open(ctx, &decrypted); err != nil {
+		return nil, err
+	}
+	return &decrypted, nil
+}
+
+// WithTx runs fn in a transaction of the base repository, which must
+// implement Transactional. The repository passed to fn encrypts the same way.
+func (r *EncryptedRepository[T, ID]) WithTx(ctx context.Context, fn TxFunc[T, ID]) error {
+	txRepo, ok := r.base.(Transactional[T, ID])
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	return txRepo.WithTx(ctx, func(tx Repository[T, ID]) error {
+		return fn(&EncryptedRepository[T, ID]{base: tx, getID: r.getID, keys: r.keys, fields: r.fields})
+	})
+}
@@ -0,0 +1,173 @@
+package sietch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type customer struct {
+	ID    int64  `db:"id"`
+	Name  string `db:"name"`
+	Email string `db:"email" encrypted:"true"`
+	Notes []byte `db:"notes" encrypted:"true"`
+}
+
+// xorWrapper wraps keys by XOR-ing them, counting unwraps
+type xorWrapper struct {
+	unwraps int
+}
+
+func (w *xorWrapper) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	wrapped := bytes.Clone(key)
+	for i := range wrapped {
+		wrapped[i] ^= 0x5a
+	}
+	return wrapped, nil
+}
+
+func (w *xorWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	w.unwraps++
+	return w.WrapKey(ctx, wrapped)
+}
+
+func TestEncryptedRepository(t *testing.T) {
+	ctx := context.Background()
+	getID := func(c *customer) int64 { return c.ID }
+	keys := &StaticKeyProvider{
+		Keys:    map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32), "k2": bytes.Repeat([]byte{2}, 16)},
+		Current: "k1",
+	}
+
+	t.Run("Fields are encrypted at rest", func(t *testing.T) {
+		base := NewInMemoryConnector[customer](getID)
+		repo, err := NewEncryptedRepository(base, getID, keys)
+		if err != nil {
+			t.Fatalf("NewEncryptedRepository failed: %v", err)
+		}
+
+		item := &customer{ID: 1, Name: "Ann", Email: "ann@example.com", Notes: []byte("vip")}
+		if err := repo.Create(ctx, item); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if item.Email != "ann@example.com" {
+			t.Errorf("Expected the caller's item to keep its plaintext, got %s", item.Email)
+		}
+
+		stored, _ := base.Get(ctx, 1)
+		if !strings.HasPrefix(stored.Email, encryptedPrefix) || strings.Contains(stored.Email, "ann") || bytes.Contains(stored.Notes, []byte("vip")) {
+			t.Errorf("Expected ciphertext at rest, got %+v", stored)
+		}
+		if stored.Name != "Ann" {
+			t.Errorf("Expected untagged fields to stay plaintext, got %s", stored.Name)
+		}
+
+		keys.Current = "k2"
+		if err := repo.Update(ctx, &customer{ID: 1, Name: "Ann", Email: "ann@example.org", Notes: []byte("vip")}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		keys.Current = "k1"
+		if _, err := repo.UpdateWhere(ctx, &Filter{}, map[string]any{"Email": "x"}); !errors.Is(err, ErrUnsupportedOperation) {
+			t.Errorf("Expected UpdateWhere not to set encrypted fields, got %v", err)
+		}
+
+		got, err := repo.Get(ctx, 1)
+		if err != nil || got.Email != "ann@example.org" || string(got.Notes) != "vip" {
+			t.Fatalf("Expected the decrypted item, got %+v (%v)", got, err)
+		}
+		results, err := repo.Query(ctx, NewFilter().Where("name", OpEqual, "Ann").Build())
+		if err != nil || len(results) != 1 || results[0].Email != "ann@example.org" {
+			t.Errorf("Expected decrypted results, got %+v (%v)", results, err)
+		}
+		if stored, _ := base.Get(ctx, 1); !strings.HasPrefix(stored.Email, encryptedPrefix) {
+			t.Error("Expected reads not to decrypt the stored items")
+		}
+	})
+
+	t.Run("Plaintext and tampered values", func(t *testing.T) {
+		base := NewInMemoryConnector[customer](getID)
+		repo, _ := NewEncryptedRepository(base, getID, keys)
+
+		_ = base.Create(ctx, &customer{ID: 1, Email: "legacy@example.com"})
+		if got, err := repo.Get(ctx, 1); err != nil || got.Email != "legacy@example.com" {
+			t.Errorf("Expected plaintext values to be read as is, got %+v (%v)", got, err)
+		}
+
+		_ = repo.Update(ctx, &customer{ID: 1, Email: "new@example.com"})
+		stored, _ := base.Get(ctx, 1)
+		stored.Notes = []byte(stored.Email) // ciphertext copied to another column
+		_ = base.Update(ctx, stored)
+		if _, err := repo.Get(ctx, 1); !errors.Is(err, ErrDecryption) {
+			t.Errorf("Expected ErrDecryption, got %v", err)
+		}
+
+		_ = repo.Create(ctx, &customer{ID: 2, Email: "other@example.com"})
+		other, _ := base.Get(ctx, 2)
+		_ = base.Create(ctx, &customer{ID: 3, Email: other.Email}) // ciphertext copied to another row
+		if _, err := repo.Get(ctx, 3); !errors.Is(err, ErrDecryption) {
+			t.Errorf("Expected ErrDecryption, got %v", err)
+		}
+	})
+
+	t.Run("Encrypted fields cannot be filtered", func(t *testing.T) {
+		repo, _ := NewEncryptedRepository(NewInMemoryConnector[customer](getID), getID, keys)
+		filter := NewFilter().Or(
+			Condition{Field: "name", Operator: OpEqual, Value: "Ann"},
+			Condition{Field: "email", Operator: OpEqual, Value: "ann@example.com"},
+		).Build()
+		if _, err := repo.Query(ctx, filter); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter, got %v", err)
+		}
+		if _, err := repo.Count(ctx, NewFilter().OrderBy("email", SortAsc).Build()); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter, got %v", err)
+		}
+		if _, err := repo.Query(ctx, NewFilter().Where("Email", OpEqual, "ann@example.com").Build()); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter for the Go field name, got %v", err)
+		}
+	})
+
+	t.Run("Envelope keys", func(t *testing.T) {
+		wrapper := &xorWrapper{}
+		base := NewInMemoryConnector[customer](getID)
+		repo, _ := NewEncryptedRepository(base, getID, NewEnvelopeKeyProvider(wrapper))
+		_ = repo.Create(ctx, &customer{ID: 1, Email: "ann@example.com"})
+
+		// a fresh provider, as in another process, unwraps the stored data key once
+		other, _ := NewEncryptedRepository(base, getID, NewEnvelopeKeyProvider(wrapper))
+		for range 2 {
+			if got, err := other.Get(ctx, 1); err != nil || got.Email != "ann@example.com" {
+				t.Fatalf("Expected the decrypted item, got %+v (%v)", got, err)
+			}
+		}
+		if wrapper.unwraps != 1 {
+			t.Errorf("Expected 1 unwrap, got %d", wrapper.unwraps)
+		}
+	})
+
+	t.Run("Entities need encrypted fields", func(t *testing.T) {
+		accountID := func(a *tenantAccount) int64 { return a.ID }
+		if _, err := NewEncryptedRepository(NewInMemoryConnector[tenantAccount](accountID), accountID, keys); err == nil {
+			t.Error("Expected an error without encrypted fields")
+		}
+	})
+	t.Run("Entities need an assigned ID", func(t *testing.T) {
+		type generatedCustomer struct {
+			ID    int64  `db:"id,pk,omit"`
+			Email string `db:"email" encrypted:"true"`
+		}
+		if _, err := NewEncryptedRepository(NewInMemoryConnector[generatedCustomer, int64](nil), nil, keys); err == nil {
+			t.Error("Expected an error for a database-generated pk")
+		}
+
+		base := NewInMemoryConnector[customer](getID)
+		repo, _ := NewEncryptedRepository(base, getID, keys)
+		if err := repo.Create(ctx, &customer{Email: "ann@example.com"}); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected ErrInvalidID for a zero ID, got %v", err)
+		}
+		if items, _ := base.Query(ctx, &Filter{}); len(items) != 0 {
+			t.Errorf("Expected nothing to be written, got %+v", items)
+		}
+	})
+}
@@ -0,0 +1,325 @@
+package sietch
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// entityCodec holds the reflection metadata of an entity type, computed once
+// per connector so CRUD paths don't walk the struct fields on every call
+type entityCodec struct {
+	columns []string
+	fields  [][]int // struct field index path of each column, see dbFields
+	names   []string
+	flags   []columnFlags
+	pk      int // column of the primary key
+	version int // column of the optimistic locking version, -1 if none
+
+	inserts  []int // columns written by inserts: all but the omitted and generated ones
+	updates  []int // columns set by updates: all but the pk, version, readonly and generated ones
+	returned []int // columns whose values inserts return: the omitted and generated ones
+}
+
+// columnFlags are the db tag options controlling how a column is written
+type columnFlags uint8
+
+const (
+	colReadonly columnFlags = 1 << iota // written by inserts, never by updates
+	colOmit                             // left out of inserts, so the column default applies
+)
+
+// colGenerated columns are computed by the database, never written
+const colGenerated = colReadonly | colOmit
+
+// newEntityCodec builds the codec for T from its db tags
+func newEntityCodec[T any]() (*entityCodec, error) {
+	var t T
+	typ := reflect.TypeOf(t)
+	if typ == nil {
+		return nil, fmt.Errorf("columns must be a struct")
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("columns must be a struct")
+	}
+
+	codec := &entityCodec{pk: -1, version: -1}
+	for _, f := range dbFields(typ) {
+		field, column, options := f.field, f.column, f.options
+		if slices.Contains(options, "version") {
+			if codec.version >= 0 {
+				return nil, fmt.Errorf("field %s: only one version column is allowed", field.Name)
+			}
+			if !isVersionKind(field.Type.Kind()) {
+				return nil, fmt.Errorf("field %s: version column must be an integer", field.Name)
+			}
+			codec.version = len(codec.columns)
+		}
+		if slices.Contains(options, "pk") {
+			if codec.pk >= 0 {
+				return nil, fmt.Errorf("field %s: only one pk column is allowed", field.Name)
+			}
+			codec.pk = len(codec.columns)
+		}
+
+		var flags columnFlags
+		if slices.Contains(options, "readonly") {
+			flags |= colReadonly
+		}
+		if slices.Contains(options, "omit") {
+			flags |= colOmit
+		}
+		if slices.Contains(options, "generated") {
+			flags |= colGenerated
+		}
+		codec.columns = append(codec.columns, column)
+		codec.fields = append(codec.fields, f.index)
+		codec.names = append(codec.names, f.name)
+		codec.flags = append(codec.flags, flags)
+	}
+
+	if len(codec.columns) == 0 {
+		return nil, fmt.Errorf("no columns found")
+	}
+	if codec.pk < 0 {
+		codec.pk = 0 // without a pk tag, the first column is the primary key
+	}
+	if codec.pk == codec.version {
+		return nil, fmt.Errorf("field %s: the primary key cannot be the version column", codec.names[codec.pk])
+	}
+	if codec.version >= 0 && codec.flags[codec.version] != 0 {
+		return nil, fmt.Errorf("field %s: the version column must be writable", codec.names[codec.version])
+	}
+	codec.inserts = codec.columnsWithout(colOmit)
+	codec.updates = codec.columnsWithout(colReadonly, codec.pk, codec.version)
+	for i, f := range codec.flags {
+		if f&colOmit != 0 {
+			codec.returned = append(codec.returned, i)
+		}
+	}
+
+	return codec, nil
+}
+
+// columnsWithout returns the indexes of the columns with none of flags,
+// other than skip
+func (c *entityCodec) columnsWithout(flags columnFlags, skip ...int) []int {
+	cols := make([]int, 0, len(c.columns))
+	for i, f := range c.flags {
+		if f&flags == 0 && !slices.Contains(skip, i) {
+			cols = append(cols, i)
+		}
+	}
+	return cols
+}
+
+// pick returns the elements of values at indexes
+func pick[E any](values []E, indexes []int) []E {
+	picked := make([]E, len(indexes))
+	for i, idx := range indexes {
+		picked[i] = values[idx]
+	}
+	return picked
+}
+
+// values returns the normalized column values of v, a struct value
+func (c *entityCodec) values(v reflect.Value) ([]any, error) {
+	values := make([]any, len(c.fields))
+	for i, idx := range c.fields {
+		field, ok := fieldByIndex(v, idx)
+		if !ok {
+			continue // in a nil embedded struct: NULL
+		}
+		value, err := DefaultConverters.normalizeValue(fieldInterface(field))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", c.names[i], err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// fieldInterface returns the value of field bound to SQL statements: its
+// address when only the pointer implements driver.Valuer, so types with a
+// pointer receiver Value method still encode themselves
+func fieldInterface(field reflect.Value) any {
+	if field.Kind() != reflect.Pointer && field.CanAddr() &&
+		!field.Type().Implements(valuerType) && reflect.PointerTo(field.Type()).Implements(valuerType) {
+		return field.Addr().Interface()
+	}
+	return field.Interface()
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// scanDestinations returns pointers to the column fields of v, an addressable struct value
+func (c *entityCodec) scanDestinations(v reflect.Value) []any {
+	dests := make([]any, len(c.fields))
+	for i, idx := range c.fields {
+		dests[i] = settableField(v, idx).Addr().Interface()
+	}
+	return dests
+}
+
+// columnDestinations returns pointers to the fields of v, an addressable
+// struct value, of the columns at indexes, like scanDestinations
+func (c *entityCodec) columnDestinations(v reflect.Value, indexes []int) []any {
+	dests := make([]any, len(indexes))
+	for i, col := range indexes {
+		dests[i] = settableField(v, c.fields[col]).Addr().Interface()
+	}
+	return dests
+}
+
+// dbField is a column of a struct type
+type dbField struct {
+	column  string
+	options []string
+	index   []int  // see reflect.Value.FieldByIndex
+	name    string // Go field path, e.g. "Base.ID"
+	field   reflect.StructField
+}
+
+// dbFields returns the db-tagged fields of typ, a struct type, in field
+// order. Embedded structs without a db tag are flattened, as are struct
+// fields whose tag is a column prefix ending in "_": with `db:"billing_"`
+// the `db:"city"` field of an Address is the billing_city column. Like
+// promoted Go fields, a column shadows the columns of the same name nested
+// deeper.
+func dbFields(typ reflect.Type) []dbField {
+	return structFields(typ, false)
+}
+
+// namedFields is dbFields with the exported fields without a db tag too,
+// named after the Go field, for the codecs storing every field such as
+// Redis hashes
+func namedFields(typ reflect.Type) []dbField {
+	return structFields(typ, true)
+}
+
+// structFields walks the fields of typ for dbFields and namedFields
+func structFields(typ reflect.Type, untagged bool) []dbField {
+	var fields []dbField
+	var depths []int
+	var walk func(typ reflect.Type, prefix, name string, index []int, depth int)
+	walk = func(typ reflect.Type, prefix, name string, index []int, depth int) {
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			column, options := dbTag(field)
+			path := append(slices.Clip(index), i)
+			if nested, ok := flattenedStruct(field, column); ok {
+				// nil pointers to unexported structs can't be allocated
+				if field.IsExported() || field.Anonymous && field.Type.Kind() == reflect.Struct {
+					walk(nested, prefix+column, name+field.Name+".", path, depth+1)
+				}
+				continue
+			}
+			if column == "" && untagged && field.IsExported() {
+				column = field.Name
+			}
+			if column == "" || column == "-" {
+				continue
+			}
+			fields = append(fields, dbField{
+				column:  prefix + column,
+				options: options,
+				index:   path,
+				name:    name + field.Name,
+				field:   field,
+			})
+			depths = append(depths, depth)
+		}
+	}
+	walk(typ, "", "", nil, 0)
+
+	shallowest := make(map[string]int, len(fields))
+	for i, f := range fields {
+		if d, ok := shallowest[f.column]; !ok || depths[i] < d {
+			shallowest[f.column] = depths[i]
+		}
+	}
+	kept := fields[:0]
+	for i, f := range fields {
+		if depths[i] == shallowest[f.column] {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// flattenedStruct returns the struct type dbFields flattens field into,
+// given its db column: an untagged embedded struct (or pointer to one), or
+// a struct tagged with a prefix ending in "_"
+func flattenedStruct(field reflect.StructField, column string) (reflect.Type, bool) {
+	typ := field.Type
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, false
+	}
+	if field.Anonymous && column == "" {
+		return typ, true
+	}
+	return typ, column != "-" && strings.HasSuffix(column, "_")
+}
+
+// fieldByIndex is v.FieldByIndex, reporting false rather than panicking
+// when index goes through a nil embedded pointer
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	field, err := v.FieldByIndexErr(index)
+	return field, err == nil
+}
+
+// settableField is v.FieldByIndex, allocating the nil embedded pointers
+// index goes through; v must be addressable
+func settableField(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// detachedField is settableField for a shallow copy of an item: the
+// embedded pointers index goes through are copied first, so setting the
+// field leaves the original item untouched
+func detachedField(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			detached := reflect.New(v.Type().Elem())
+			if !v.IsNil() {
+				detached.Elem().Set(v.Elem())
+			}
+			v.Set(detached)
+			v = detached.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// dbTag returns the column name and options of the db tag of field, e.g.
+// `db:"version,version"` names the column "version" and marks it as the
+// optimistic locking version. The other options are pk (the primary key,
+// the first column by default), readonly (never updated, e.g. created_at),
+// omit (left out of inserts so the column default applies, e.g. a serial
+// key) and generated (computed by the database, never written).
+func dbTag(field reflect.StructField) (column string, options []string) {
+	tag := field.Tag.Get("db")
+	column, rest, found := strings.Cut(tag, ",")
+	if found {
+		options = strings.Split(rest, ",")
+	}
+	return column, options
+}
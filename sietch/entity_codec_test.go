@@ -0,0 +1,190 @@
+package sietch
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type codecEntity struct {
+	ID        int64     `db:"id"`
+	Name      string    `db:"name"`
+	Email     string    `db:"email"`
+	Balance   int       `db:"balance"`
+	Active    bool      `db:"active"`
+	Tags      []string  `db:"tags"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+	Notes     string
+}
+
+func newCodecConnector(tb testing.TB) *CockroachDBConnector[codecEntity, int64] {
+	tb.Helper()
+	c, err := NewCockroachDBConnector[codecEntity, int64](&pgxpool.Pool{}, "entities", func(e *codecEntity) int64 { return e.ID })
+	if err != nil {
+		tb.Fatalf("Failed to create connector: %v", err)
+	}
+	return c
+}
+
+func TestEntityCodec(t *testing.T) {
+	c := newCodecConnector(t)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	item := codecEntity{ID: 1, Name: "a", Email: "a@example.com", Balance: 10, Active: true, Tags: []string{"x"}, CreatedAt: now, UpdatedAt: now, Notes: "skipped"}
+
+	values, err := c.getValues(&item)
+	if err != nil {
+		t.Fatalf("getValues failed: %v", err)
+	}
+	expected := []any{int64(1), "a", "a@example.com", 10, true, []string{"x"}, now, now}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected values %v, got %v", expected, values)
+	}
+
+	var scanned codecEntity
+	dests, err := c.getScanDestinations(&scanned)
+	if err != nil {
+		t.Fatalf("getScanDestinations failed: %v", err)
+	}
+	if len(dests) != len(c.columns) {
+		t.Fatalf("Expected %d destinations, got %d", len(c.columns), len(dests))
+	}
+	*dests[1].(*string) = "scanned"
+	*dests[7].(*time.Time) = now
+	if scanned.Name != "scanned" || !scanned.UpdatedAt.Equal(now) {
+		t.Errorf("Destinations do not point at the entity fields: %+v", scanned)
+	}
+}
+
+func TestEntityCodec_Invalid(t *testing.T) {
+	if _, err := newEntityCodec[int](); err == nil {
+		t.Error("Expected an error for a non-struct type")
+	}
+	if _, err := newEntityCodec[struct{ Name string }](); err == nil {
+		t.Error("Expected an error for a struct without db tags")
+	}
+}
+
+type baseEntity struct {
+	ID        int64     `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type address struct {
+	City string `db:"city"`
+	Zip  string `db:"zip"`
+}
+
+type embeddingEntity struct {
+	baseEntity
+	Name    string  `db:"name"`
+	Billing address `db:"billing_"`
+	*AuditFields
+	Zip     string  `db:"zip"` // no clash with the prefixed billing_zip
+	Skipped address `db:"-"`
+	Raw     address `db:"raw"` // a column, not flattened
+}
+
+type AuditFields struct {
+	UpdatedBy string `db:"updated_by"`
+	Name      string `db:"name"` // shadowed by embeddingEntity.Name
+}
+
+func TestEntityCodec_Embedded(t *testing.T) {
+	codec, err := newEntityCodec[embeddingEntity]()
+	if err != nil {
+		t.Fatalf("newEntityCodec failed: %v", err)
+	}
+	expected := []string{"id", "created_at", "name", "billing_city", "billing_zip", "updated_by", "zip", "raw"}
+	if !reflect.DeepEqual(codec.columns, expected) {
+		t.Fatalf("Expected columns %v, got %v", expected, codec.columns)
+	}
+
+	item := embeddingEntity{Name: "a", Billing: address{City: "Oslo", Zip: "0150"}}
+	item.ID = 7
+	values, err := codec.values(reflect.ValueOf(&item).Elem())
+	if err != nil {
+		t.Fatalf("values failed: %v", err)
+	}
+	if values[0] != int64(7) || values[3] != "Oslo" || values[5] != nil {
+		t.Errorf("Unexpected values %v", values)
+	}
+
+	var scanned embeddingEntity
+	dests := codec.scanDestinations(reflect.ValueOf(&scanned).Elem())
+	*dests[0].(*int64) = 9
+	*dests[4].(*string) = "0151"
+	*dests[5].(*string) = "bob"
+	if scanned.ID != 9 || scanned.Billing.Zip != "0151" || scanned.AuditFields == nil || scanned.UpdatedBy != "bob" {
+		t.Errorf("Destinations do not point at the nested fields: %+v", scanned)
+	}
+}
+
+// uncachedValues walks the struct fields on every call, as getValues did
+// before the codec was cached
+func uncachedValues(item any) []any {
+	v := reflect.ValueOf(item).Elem()
+	typ := v.Type()
+	var values []any
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).Tag.Get("db") != "" {
+			value, _ := DefaultConverters.normalizeValue(v.Field(i).Interface())
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// uncachedScanDestinations walks the struct fields on every call, as
+// getScanDestinations did before the codec was cached
+func uncachedScanDestinations(ptr any) []any {
+	v := reflect.ValueOf(ptr).Elem()
+	typ := v.Type()
+	var dests []any
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).Tag.Get("db") != "" {
+			dests = append(dests, v.Field(i).Addr().Interface())
+		}
+	}
+	return dests
+}
+
+func BenchmarkGetValues(b *testing.B) {
+	c := newCodecConnector(b)
+	item := codecEntity{ID: 1, Name: "a", Email: "a@example.com", Tags: []string{"x"}, CreatedAt: time.Now()}
+
+	b.Run("Cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = c.getValues(&item)
+		}
+	})
+
+	b.Run("Uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = uncachedValues(&item)
+		}
+	})
+}
+
+func BenchmarkGetScanDestinations(b *testing.B) {
+	c := newCodecConnector(b)
+	var item codecEntity
+
+	b.Run("Cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = c.getScanDestinations(&item)
+		}
+	})
+
+	b.Run("Uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = uncachedScanDestinations(&item)
+		}
+	})
+}
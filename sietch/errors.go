@@ -1,6 +1,10 @@
 package sietch
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
 	ErrItemNotFound         = errors.New("item not found")
@@ -8,4 +12,130 @@ var (
 	ErrNoUpdateItem         = errors.New("no item has been updated")
 	ErrNoDeleteItem         = errors.New("no item has been deleted")
 	ErrUnsupportedOperation = errors.New("unsupported operation")
+	ErrInvalidID            = errors.New("invalid id")
+	ErrInvalidFilter        = errors.New("invalid filter")
+	ErrConstraintViolation  = errors.New("constraint violation")
+	ErrQueryCeilingExceeded = errors.New("query exceeded the watchdog ceiling")
+	ErrVersionConflict      = errors.New("item was modified concurrently")
+	ErrMissingTenant        = errors.New("no tenant in context")
+	ErrDecryption           = errors.New("cannot decrypt field")
+	ErrValidation           = errors.New("validation failed")
+	ErrRepositoryClosed     = errors.New("repository is closed")
+	ErrPartialCommit        = errors.New("transaction partially committed")
+	ErrTxConflict           = errors.New("transaction conflicts with a concurrent write")
+	ErrNotParticipant       = errors.New("pool does not participate in the transaction")
+
+	// ErrInjectedFault matches the failures injected by test doubles, such
+	// as sietchtest.FaultyRepository, in place of a backend failure
+	ErrInjectedFault = errors.New("injected fault")
+)
+
+// ConstraintKind identifies the type of database constraint that was violated
+type ConstraintKind string
+
+const (
+	ConstraintUnique     ConstraintKind = "unique"
+	ConstraintForeignKey ConstraintKind = "foreign_key"
+	ConstraintNotNull    ConstraintKind = "not_null"
+	ConstraintCheck      ConstraintKind = "check"
 )
+
+// ConstraintError reports which constraint and columns rejected a write.
+// It matches ErrConstraintViolation with errors.Is, and unique violations
+// also match ErrItemAlreadyExists.
+type ConstraintError struct {
+	Kind       ConstraintKind
+	Constraint string   // constraint name, e.g. "accounts_email_key"
+	Table      string   // table the constraint belongs to, when reported
+	Columns    []string // columns involved, when they can be determined
+	Detail     string   // database-provided detail message
+	Err        error    // underlying driver error
+}
+
+func (e *ConstraintError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s constraint violation", e.Kind)
+	if e.Constraint != "" {
+		fmt.Fprintf(&b, " on %s", e.Constraint)
+	}
+	if len(e.Columns) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(e.Columns, ", "))
+	}
+	if e.Detail != "" {
+		fmt.Fprintf(&b, ": %s", e.Detail)
+	}
+	return b.String()
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConstraintError) Is(target error) bool {
+	switch target {
+	case ErrConstraintViolation:
+		return true
+	case ErrItemAlreadyExists:
+		return e.Kind == ConstraintUnique
+	}
+	return false
+}
+
+// MissingItemsError reports the items of a batch update that do not exist.
+// It matches ErrNoUpdateItem with errors.Is.
+type MissingItemsError[ID comparable] struct {
+	IDs []ID // in the order they were passed
+}
+
+func (e *MissingItemsError[ID]) Error() string {
+	ids := make([]string, len(e.IDs))
+	for i, id := range e.IDs {
+		ids[i] = fmt.Sprint(id)
+	}
+	return fmt.Sprintf("%d items do not exist: %s", len(e.IDs), strings.Join(ids, ", "))
+}
+
+func (e *MissingItemsError[ID]) Is(target error) bool {
+	return target == ErrNoUpdateItem
+}
+
+// VersionConflictError reports the items of a batch whose version column
+// no longer matches the stored one. It matches ErrVersionConflict with errors.Is.
+type VersionConflictError[ID comparable] struct {
+	IDs []ID // in the order they were passed
+}
+
+func (e *VersionConflictError[ID]) Error() string {
+	ids := make([]string, len(e.IDs))
+	for i, id := range e.IDs {
+		ids[i] = fmt.Sprint(id)
+	}
+	return fmt.Sprintf("%d items were modified concurrently: %s", len(e.IDs), strings.Join(ids, ", "))
+}
+
+func (e *VersionConflictError[ID]) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+// PartialCommitError reports a coordinated transaction that committed on
+// some participants but failed to commit on another. It matches
+// ErrPartialCommit with errors.Is.
+type PartialCommitError struct {
+	Committed  []string // participants whose commit succeeded, in commit order
+	Failed     string   // participant whose commit failed
+	RolledBack []string // participants rolled back after the failure
+	Err        error    // commit error of Failed
+}
+
+func (e *PartialCommitError) Error() string {
+	return fmt.Sprintf("transaction committed on %s but failed on %s (rolled back on %s): %v",
+		strings.Join(e.Committed, ", "), e.Failed, strings.Join(e.RolledBack, ", "), e.Err)
+}
+
+func (e *PartialCommitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *PartialCommitError) Is(target error) bool {
+	return target == ErrPartialCommit
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/seb7887/gofw/sietch"
+)
+
+// Account is the entity stored by the example
+type Account struct {
+	ID      int64  `db:"id"`
+	Owner   string `db:"owner"`
+	Status  string `db:"status"`
+	Balance int64  `db:"balance"`
+}
+
+// AccountSummary is a projection with only the fields the report needs
+type AccountSummary struct {
+	Owner   string `db:"owner"`
+	Balance int64  `db:"balance"`
+}
+
+func main() {
+	ctx := context.Background()
+
+	// Use CockroachDB when SIETCH_DSN is set, the in-memory connector otherwise
+	repo, cleanup, err := newRepository(ctx, os.Getenv("SIETCH_DSN"))
+	if err != nil {
+		log.Fatalf("create repository: %v", err)
+	}
+	defer cleanup()
+
+	// Create the table (SQL only) and load fixtures
+	b := sietch.NewBootstrap()
+	sietch.AddFixtures(b, repo,
+		Account{ID: 1, Owner: "alice", Status: "active", Balance: 1200},
+		Account{ID: 2, Owner: "bob", Status: "active", Balance: 300},
+		Account{ID: 3, Owner: "carol", Status: "closed", Balance: 0},
+		Account{ID: 4, Owner: "dave", Status: "active", Balance: 4500},
+	)
+	teardown, err := b.Run(ctx)
+	if err != nil {
+		log.Fatalf("bootstrap: %v", err)
+	}
+	defer func() {
+		if err := teardown(context.Background()); err != nil {
+			log.Printf("teardown: %v", err)
+		}
+	}()
+
+	// Filtered, sorted query projected into a DTO
+	active := sietch.NewFilter().
+		Where("status", sietch.OpEqual, "active").
+		OrderBy("balance", sietch.SortDesc).
+		Build()
+
+	summaries, err := sietch.QueryAs[AccountSummary](ctx, repo, active)
+	if err != nil {
+		log.Fatalf("query: %v", err)
+	}
+	fmt.Println("Active accounts by balance:")
+	for _, s := range summaries {
+		fmt.Printf("  %-6s %6d\n", s.Owner, s.Balance)
+	}
+
+	// Single lookups
+	richest, err := repo.FindOne(ctx, active)
+	if err != nil {
+		log.Fatalf("find one: %v", err)
+	}
+	fmt.Printf("Richest active account: %s\n", richest.Owner)
+
+	byID, err := repo.GetMany(ctx, []int64{1, 3, 42})
+	if err != nil {
+		log.Fatalf("get many: %v", err)
+	}
+	fmt.Printf("GetMany found %d of 3 IDs\n", len(byID))
+
+	// Bulk mutation
+	closed, err := repo.UpdateWhere(ctx,
+		sietch.NewFilter().Where("balance", sietch.OpLessThan, 500).Build(),
+		map[string]any{"status": "closed"},
+	)
+	if err != nil {
+		log.Fatalf("update where: %v", err)
+	}
+	fmt.Printf("Closed %d low-balance accounts\n", closed)
+
+	remaining, err := repo.Count(ctx, active)
+	if err != nil {
+		log.Fatalf("count: %v", err)
+	}
+	fmt.Printf("Active accounts left: %d\n", remaining)
+}
+
+func newRepository(ctx context.Context, dsn string) (sietch.Repository[Account, int64], func(), error) {
+	getID := func(a *Account) int64 { return a.ID }
+
+	if dsn == "" {
+		return sietch.NewInMemoryConnector[Account, int64](getID), func() {}, nil
+	}
+
+	pool, err := sietch.NewCockroachDBConnPool(ctx, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	repo, err := sietch.NewCockroachDBConnector[Account, int64](pool, "example_accounts", getID)
+	if err != nil {
+		pool.Close()
+		return nil, nil, err
+	}
+	return repo, pool.Close, nil
+}
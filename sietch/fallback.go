@@ -0,0 +1,422 @@
+package sietch
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultMaxPendingWrites bounds the writes a FallbackRepository keeps for
+// replay when FallbackOptions.MaxPendingWrites is zero
+const DefaultMaxPendingWrites = 10000
+
+// FallbackOptions configures a FallbackRepository
+type FallbackOptions struct {
+	// ShouldFallback reports whether a primary error sends the operation to
+	// the secondary. By default every error does except those describing the
+	// request rather than the primary's health, see IsFallbackError.
+	ShouldFallback func(err error) bool
+
+	// ReplayWrites writes to the secondary when a primary write fails,
+	// keeping the write for Replay. Without it failed writes return the
+	// primary's error.
+	ReplayWrites bool
+
+	// MaxPendingWrites bounds the writes kept for replay; writes failing
+	// once it is reached return the primary's error.
+	// DefaultMaxPendingWrites if zero.
+	MaxPendingWrites int
+
+	// OnFallback is called with the operation and primary error whenever
+	// the secondary serves an operation
+	OnFallback func(op string, err error)
+}
+
+// IsFallbackError reports whether err hints at an unhealthy backend rather
+// than at the request: not found (ErrItemNotFound, or a bare pgx.ErrNoRows
+// from CockroachDB), conflicts, invalid filters and IDs, constraint and
+// validation failures, unsupported operations and cancelled contexts are
+// not fallback errors.
+func IsFallbackError(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, target := range []error{
+		ErrItemNotFound, pgx.ErrNoRows, ErrItemAlreadyExists, ErrNoUpdateItem, ErrNoDeleteItem,
+		ErrInvalidID, ErrInvalidFilter, ErrConstraintViolation, ErrVersionConflict,
+		ErrValidation, ErrUnsupportedOperation, ErrMissingTenant, context.Canceled,
+	} {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+	return true
+}
+
+// FallbackRepository serves operations from a secondary repository while
+// the primary fails, for degraded-mode operation during database incidents.
+// Reads (Get, GetMany, Exists, Query, FindOne, Count, GroupCount) that fail
+// on the primary are retried on the secondary, typically a replica or the
+// cache of a CachedRepository; keeping it in sync is up to the caller.
+//
+// With ReplayWrites, failed Create, Update, Upsert and Delete writes and
+// their batches go to the secondary and are kept until Replay writes them to
+// the primary once it recovered. A write of an item that succeeds on the
+// primary supersedes the pending writes of the item, which are dropped, so
+// Replay never overwrites it or recreates an item it deleted; writes made
+// to the primary by other clients are not tracked. UpdateWhere,
+// DeleteWhere, GetForUpdate and WithTx always run on the primary.
+type FallbackRepository[T any, ID comparable] struct {
+	primary   Repository[T, ID]
+	secondary Repository[T, ID]
+	getID     func(*T) ID
+	opts      FallbackOptions
+
+	mu       sync.Mutex
+	pending  []pendingWrite[T, ID] // writes to replay, in order
+	reserved int                   // pending writes being written to the secondary
+	seq      uint64                // seq of the last pending write
+
+	replaying sync.Mutex // held by Replay
+}
+
+// pendingWrite is a write served by the secondary, waiting for Replay
+type pendingWrite[T any, ID comparable] struct {
+	seq  uint64
+	op   string // "Create", "Update", "Upsert" or "Delete"
+	item T      // unset for deletes
+	id   ID
+}
+
+// NewFallbackRepository creates a repository failing over from primary to
+// secondary. getID extracts the ID of replayed writes, and may be nil
+// without ReplayWrites.
+func NewFallbackRepository[T any, ID comparable](primary, secondary Repository[T, ID], getID func(*T) ID, opts FallbackOptions) (*FallbackRepository[T, ID], error) {
+	if primary == nil || secondary == nil {
+		return nil, fmt.Errorf("primary and secondary cannot be nil")
+	}
+	if opts.ReplayWrites {
+		var err error
+		if getID, err = resolveGetID(getID); err != nil {
+			return nil, fmt.Errorf("ReplayWrites needs getID: %w", err)
+		}
+	}
+	if opts.ShouldFallback == nil {
+		opts.ShouldFallback = IsFallbackError
+	}
+	if opts.MaxPendingWrites <= 0 {
+		opts.MaxPendingWrites = DefaultMaxPendingWrites
+	}
+	return &FallbackRepository[T, ID]{primary: primary, secondary: secondary, getID: getID, opts: opts}, nil
+}
+
+// fallback reports whether the secondary serves op after the primary failed
+// with err
+func (r *FallbackRepository[T, ID]) fallback(ctx context.Context, op string, err error) bool {
+	if err == nil || ctx.Err() != nil || !r.opts.ShouldFallback(err) {
+		return false
+	}
+	if r.opts.OnFallback != nil {
+		r.opts.OnFallback(op, err)
+	}
+	return true
+}
+
+// fallbackRead runs fn on the primary, then on the secondary if it failed
+func fallbackRead[R any, T any, ID comparable](ctx context.Context, r *FallbackRepository[T, ID], op string, fn func(Repository[T, ID]) (R, error)) (R, error) {
+	result, err := fn(r.primary)
+	if r.fallback(ctx, op, err) {
+		return fn(r.secondary)
+	}
+	return result, err
+}
+
+// write runs fn on the primary, dropping the pending writes it supersedes.
+// If it fails and writes are replayed, fn runs on the secondary and the
+// writes are kept for Replay.
+func (r *FallbackRepository[T, ID]) write(ctx context.Context, op string, writes func() []pendingWrite[T, ID], fn func(Repository[T, ID]) error) error {
+	err := fn(r.primary)
+	if err == nil {
+		if r.opts.ReplayWrites {
+			r.supersede(writes())
+		}
+		return nil
+	}
+	if !r.opts.ReplayWrites || !r.opts.ShouldFallback(err) || ctx.Err() != nil {
+		return err
+	}
+
+	queued := writes()
+	r.mu.Lock()
+	if len(r.pending)+r.reserved+len(queued) > r.opts.MaxPendingWrites {
+		r.mu.Unlock()
+		return err
+	}
+	r.reserved += len(queued)
+	r.mu.Unlock()
+
+	if r.opts.OnFallback != nil {
+		r.opts.OnFallback(op, err)
+	}
+	secondaryErr := fn(r.secondary)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reserved -= len(queued)
+	if secondaryErr != nil {
+		return errors.Join(err, secondaryErr)
+	}
+	for _, w := range queued {
+		r.seq++
+		w.seq = r.seq
+		r.pending = append(r.pending, w)
+	}
+	return nil
+}
+
+// supersede drops the pending writes of the items of writes, which reached
+// the primary
+func (r *FallbackRepository[T, ID]) supersede(writes []pendingWrite[T, ID]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) == 0 {
+		return
+	}
+	written := make(map[ID]bool, len(writes))
+	for _, w := range writes {
+		written[w.id] = true
+	}
+	r.pending = slices.DeleteFunc(r.pending, func(w pendingWrite[T, ID]) bool {
+		return written[w.id]
+	})
+}
+
+// itemWrites returns the pending writes of items
+func (r *FallbackRepository[T, ID]) itemWrites(op string, items ...T) func() []pendingWrite[T, ID] {
+	return func() []pendingWrite[T, ID] {
+		writes := make([]pendingWrite[T, ID], len(items))
+		for i, item := range items {
+			writes[i] = pendingWrite[T, ID]{op: op, item: item, id: r.getID(&item)}
+		}
+		return writes
+	}
+}
+
+// deleteWrites returns the pending deletes of ids
+func deleteWrites[T any, ID comparable](ids ...ID) func() []pendingWrite[T, ID] {
+	return func() []pendingWrite[T, ID] {
+		writes := make([]pendingWrite[T, ID], len(ids))
+		for i, id := range ids {
+			writes[i] = pendingWrite[T, ID]{op: "Delete", id: id}
+		}
+		return writes
+	}
+}
+
+// PendingWrites returns the number of writes waiting for Replay
+func (r *FallbackRepository[T, ID]) PendingWrites() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
+
+// Replay writes the writes served by the secondary to the primary, in
+// order, returning how many were replayed. Replays are idempotent: creates
+// and updates are upserted and deletes of missing items succeed, so the
+// last write of an item wins. Writes superseded while Replay runs are
+// skipped. Replay stops at the first failing write, which stays pending
+// with the ones after it.
+func (r *FallbackRepository[T, ID]) Replay(ctx context.Context) (int, error) {
+	r.replaying.Lock()
+	defer r.replaying.Unlock()
+
+	r.mu.Lock()
+	writes := slices.Clone(r.pending)
+	r.mu.Unlock()
+
+	replayed := 0
+	for _, w := range writes {
+		if !r.isPending(w.seq) {
+			continue
+		}
+		var err error
+		if w.op == "Delete" {
+			if err = r.primary.Delete(ctx, w.id); isMissingError(err) {
+				err = nil
+			}
+		} else {
+			item := w.item
+			err = r.primary.Upsert(ctx, &item)
+		}
+		if err != nil {
+			return replayed, fmt.Errorf("failed to replay %s of %v: %w", w.op, w.id, err)
+		}
+		r.replayed(w.seq)
+		replayed++
+	}
+	return replayed, nil
+}
+
+// isPending reports whether the write with seq is still pending
+func (r *FallbackRepository[T, ID]) isPending(seq uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, found := r.pendingIndex(seq)
+	return found
+}
+
+// replayed removes the write with seq from the pending writes
+func (r *FallbackRepository[T, ID]) replayed(seq uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i, found := r.pendingIndex(seq); found {
+		r.pending = slices.Delete(r.pending, i, i+1)
+	}
+}
+
+// pendingIndex finds the write with seq in the pending writes, which are
+// ordered by seq. The caller holds r.mu.
+func (r *FallbackRepository[T, ID]) pendingIndex(seq uint64) (int, bool) {
+	return slices.BinarySearchFunc(r.pending, seq, func(w pendingWrite[T, ID], seq uint64) int {
+		return cmp.Compare(w.seq, seq)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) Create(ctx context.Context, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	return r.write(ctx, "Create", r.itemWrites("Create", *item), func(repo Repository[T, ID]) error {
+		return repo.Create(ctx, item)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	return fallbackRead(ctx, r, "Get", func(repo Repository[T, ID]) (*T, error) {
+		return repo.Get(ctx, id)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	return fallbackRead(ctx, r, "GetMany", func(repo Repository[T, ID]) (map[ID]*T, error) {
+		return repo.GetMany(ctx, ids)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	return fallbackRead(ctx, r, "Exists", func(repo Repository[T, ID]) (bool, error) {
+		return repo.Exists(ctx, id)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	return r.write(ctx, "BatchCreate", r.itemWrites("Create", items...), func(repo Repository[T, ID]) error {
+		return repo.BatchCreate(ctx, items)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
+	return fallbackRead(ctx, r, "Query", func(repo Repository[T, ID]) ([]T, error) {
+		return repo.Query(ctx, filter)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	return fallbackRead(ctx, r, "FindOne", func(repo Repository[T, ID]) (*T, error) {
+		return repo.FindOne(ctx, filter)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
+	return fallbackRead(ctx, r, "Count", func(repo Repository[T, ID]) (int64, error) {
+		return repo.Count(ctx, filter)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) Update(ctx context.Context, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	return r.write(ctx, "Update", r.itemWrites("Update", *item), func(repo Repository[T, ID]) error {
+		return repo.Update(ctx, item)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	return r.write(ctx, "BatchUpdate", r.itemWrites("Update", items...), func(repo Repository[T, ID]) error {
+		return repo.BatchUpdate(ctx, items)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	return r.write(ctx, "Delete", deleteWrites[T](id), func(repo Repository[T, ID]) error {
+		return repo.Delete(ctx, id)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) BatchDelete(ctx context.Context, ids []ID) error {
+	return r.write(ctx, "BatchDelete", deleteWrites[T](ids...), func(repo Repository[T, ID]) error {
+		return repo.BatchDelete(ctx, ids)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	return r.write(ctx, "Upsert", r.itemWrites("Upsert", *item), func(repo Repository[T, ID]) error {
+		return repo.Upsert(ctx, item)
+	})
+}
+
+func (r *FallbackRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	return r.write(ctx, "BatchUpsert", r.itemWrites("Upsert", items...), func(repo Repository[T, ID]) error {
+		return repo.BatchUpsert(ctx, items)
+	})
+}
+
+// UpdateWhere runs on the primary only, as bulk writes cannot be replayed
+func (r *FallbackRepository[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	return r.primary.UpdateWhere(ctx, filter, updates)
+}
+
+// DeleteWhere runs on the primary only, as bulk writes cannot be replayed
+func (r *FallbackRepository[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	return r.primary.DeleteWhere(ctx, filter)
+}
+
+// GroupCount groups on the primary, then on the secondary if it failed;
+// each must implement Grouper
+func (r *FallbackRepository[T, ID]) GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error) {
+	return fallbackRead(ctx, r, "GroupCount", func(repo Repository[T, ID]) ([]GroupCount, error) {
+		grouper, ok := repo.(Grouper)
+		if !ok {
+			return nil, ErrUnsupportedOperation
+		}
+		return grouper.GroupCount(ctx, filter)
+	})
+}
+
+// GetForUpdate locks the row on the primary if it implements RowLocker
+func (r *FallbackRepository[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
+	locker, ok := r.primary.(RowLocker[T, ID])
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	return locker.GetForUpdate(ctx, id)
+}
+
+// WithTx runs fn in a transaction of the primary, which must implement
+// Transactional; there is no fallback within transactions
+func (r *FallbackRepository[T, ID]) WithTx(ctx context.Context, fn TxFunc[T, ID]) error {
+	txRepo, ok := r.primary.(Transactional[T, ID])
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	return txRepo.WithTx(ctx, fn)
+}
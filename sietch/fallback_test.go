@@ -0,0 +1,182 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+var errConnectionRefused = errors.New("connection refused")
+
+// downRepository fails every operation it overrides while down
+type downRepository struct {
+	Repository[testutils.Account, int64]
+	down bool
+}
+
+func (r *downRepository) Get(ctx context.Context, id int64) (*testutils.Account, error) {
+	if r.down {
+		return nil, errConnectionRefused
+	}
+	return r.Repository.Get(ctx, id)
+}
+
+func (r *downRepository) Query(ctx context.Context, filter *Filter) ([]testutils.Account, error) {
+	if r.down {
+		return nil, errConnectionRefused
+	}
+	return r.Repository.Query(ctx, filter)
+}
+
+func (r *downRepository) Create(ctx context.Context, item *testutils.Account) error {
+	if r.down {
+		return errConnectionRefused
+	}
+	return r.Repository.Create(ctx, item)
+}
+
+func (r *downRepository) Update(ctx context.Context, item *testutils.Account) error {
+	if r.down {
+		return errConnectionRefused
+	}
+	return r.Repository.Update(ctx, item)
+}
+
+func (r *downRepository) Upsert(ctx context.Context, item *testutils.Account) error {
+	if r.down {
+		return errConnectionRefused
+	}
+	return r.Repository.Upsert(ctx, item)
+}
+
+func (r *downRepository) Delete(ctx context.Context, id int64) error {
+	if r.down {
+		return errConnectionRefused
+	}
+	return r.Repository.Delete(ctx, id)
+}
+
+func TestFallbackRepository(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	newRepos := func(opts FallbackOptions) (*FallbackRepository[testutils.Account, int64], *downRepository, Repository[testutils.Account, int64]) {
+		primary := &downRepository{Repository: NewInMemoryConnector[testutils.Account](getID)}
+		secondary := NewInMemoryConnector[testutils.Account](getID)
+		for _, repo := range []Repository[testutils.Account, int64]{primary, secondary} {
+			_ = repo.BatchCreate(ctx, []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}})
+		}
+		repo, err := NewFallbackRepository[testutils.Account, int64](primary, secondary, getID, opts)
+		if err != nil {
+			t.Fatalf("NewFallbackRepository failed: %v", err)
+		}
+		return repo, primary, secondary
+	}
+
+	t.Run("Reads fail over", func(t *testing.T) {
+		var fallbacks []string
+		repo, primary, _ := newRepos(FallbackOptions{OnFallback: func(op string, _ error) { fallbacks = append(fallbacks, op) }})
+		primary.down = true
+
+		if got, err := repo.Get(ctx, 1); err != nil || got.Balance != 10 {
+			t.Errorf("Expected the secondary's item, got %+v (%v)", got, err)
+		}
+		if results, err := repo.Query(ctx, nil); err != nil || len(results) != 2 {
+			t.Errorf("Expected the secondary's items, got %v (%v)", results, err)
+		}
+		if err := repo.Create(ctx, &testutils.Account{ID: 3}); !errors.Is(err, errConnectionRefused) {
+			t.Errorf("Expected writes to fail without ReplayWrites, got %v", err)
+		}
+		if len(fallbacks) != 2 || fallbacks[0] != "Get" {
+			t.Errorf("Unexpected fallbacks %v", fallbacks)
+		}
+
+		primary.down = false
+		if _, err := repo.Get(ctx, 42); !errors.Is(err, ErrItemNotFound) {
+			t.Errorf("Expected ErrItemNotFound from the primary, got %v", err)
+		}
+	})
+
+	t.Run("Missing CockroachDB rows do not fail over", func(t *testing.T) {
+		var fallbacks []string
+		primary := &cockroachDBTx[testutils.Account, int64]{connector: newBatchConnector(t), tx: &recordingTx{}, ctx: ctx}
+		secondary := NewInMemoryConnector[testutils.Account](getID)
+		_ = secondary.Create(ctx, &testutils.Account{ID: 1, Balance: 10})
+		repo, _ := NewFallbackRepository[testutils.Account, int64](primary, secondary, getID, FallbackOptions{OnFallback: func(op string, _ error) { fallbacks = append(fallbacks, op) }})
+
+		if _, err := repo.Get(ctx, 1); !errors.Is(err, ErrItemNotFound) || len(fallbacks) != 0 {
+			t.Errorf("Expected ErrItemNotFound from the primary, got %v after fallbacks %v", err, fallbacks)
+		}
+		if IsFallbackError(pgx.ErrNoRows) {
+			t.Error("Expected pgx.ErrNoRows not to be a fallback error")
+		}
+	})
+
+	t.Run("Only chosen errors fail over", func(t *testing.T) {
+		repo, primary, _ := newRepos(FallbackOptions{ShouldFallback: func(err error) bool { return false }})
+		primary.down = true
+		if _, err := repo.Get(ctx, 1); !errors.Is(err, errConnectionRefused) {
+			t.Errorf("Expected the primary's error, got %v", err)
+		}
+	})
+
+	t.Run("Writes are replayed", func(t *testing.T) {
+		repo, primary, secondary := newRepos(FallbackOptions{ReplayWrites: true})
+		primary.down = true
+
+		_ = repo.Create(ctx, &testutils.Account{ID: 3, Balance: 30})
+		_ = repo.Update(ctx, &testutils.Account{ID: 1, Balance: 11})
+		_ = repo.Delete(ctx, 2)
+		if got, _ := secondary.Get(ctx, 3); got == nil || got.Balance != 30 {
+			t.Errorf("Expected the write on the secondary, got %+v", got)
+		}
+		if n := repo.PendingWrites(); n != 3 {
+			t.Errorf("Expected 3 pending writes, got %d", n)
+		}
+
+		if _, err := repo.Replay(ctx); err == nil {
+			t.Error("Expected replay to fail while the primary is down")
+		}
+		primary.down = false
+		n, err := repo.Replay(ctx)
+		if err != nil || n != 3 || repo.PendingWrites() != 0 {
+			t.Fatalf("Expected 3 replayed writes, got %d (%v)", n, err)
+		}
+		if got, _ := primary.Get(ctx, 1); got.Balance != 11 {
+			t.Errorf("Expected the replayed update, got %+v", got)
+		}
+		if exists, _ := primary.Exists(ctx, 2); exists {
+			t.Error("Expected the replayed delete")
+		}
+	})
+
+	t.Run("Direct writes supersede pending writes", func(t *testing.T) {
+		repo, primary, _ := newRepos(FallbackOptions{ReplayWrites: true})
+		primary.down = true
+		_ = repo.Update(ctx, &testutils.Account{ID: 1, Balance: 11})
+		_ = repo.Update(ctx, &testutils.Account{ID: 2, Balance: 21})
+
+		primary.down = false
+		if err := repo.Delete(ctx, 1); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if err := repo.Update(ctx, &testutils.Account{ID: 2, Balance: 22}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if n := repo.PendingWrites(); n != 0 {
+			t.Errorf("Expected the pending writes to be dropped, got %d", n)
+		}
+
+		if n, err := repo.Replay(ctx); err != nil || n != 0 {
+			t.Fatalf("Expected nothing to replay, got %d (%v)", n, err)
+		}
+		if exists, _ := primary.Exists(ctx, 1); exists {
+			t.Error("Expected the deleted item not to be recreated")
+		}
+		if got, _ := primary.Get(ctx, 2); got.Balance != 22 {
+			t.Errorf("Expected the newer update to be kept, got %+v", got)
+		}
+	})
+}
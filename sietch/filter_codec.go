@@ -0,0 +1,634 @@
+package sietch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// knownOperators lists every operator a serialized filter may use
+var knownOperators = map[ComparisonOperator]bool{
+	OpEqual: true, OpNotEqual: true, OpGreaterThan: true, OpLessThan: true,
+	OpGreaterThanOrEqual: true, OpLessThanOrEqual: true,
+	OpIn: true, OpNotIn: true, OpLike: true, OpILike: true, OpRegex: true, OpIRegex: true,
+	OpIsNull: true, OpIsNotNull: true, OpBetween: true,
+	OpJSONContains: true, OpJSONPathExists: true, OpJSONGet: true,
+	OpArrayContains: true, OpArrayContainedBy: true, OpArrayOverlaps: true, OpArrayAny: true,
+	OpFullText: true,
+}
+
+// queryOperators maps the operator suffixes accepted by ParseFilter (field[op]=value)
+var queryOperators = map[string]ComparisonOperator{
+	"eq":      OpEqual,
+	"ne":      OpNotEqual,
+	"gt":      OpGreaterThan,
+	"gte":     OpGreaterThanOrEqual,
+	"lt":      OpLessThan,
+	"lte":     OpLessThanOrEqual,
+	"in":      OpIn,
+	"nin":     OpNotIn,
+	"like":    OpLike,
+	"ilike":   OpILike,
+	"regex":   OpRegex,
+	"iregex":  OpIRegex,
+	"null":    OpIsNull,
+	"notnull": OpIsNotNull,
+	"between": OpBetween,
+	"search":  OpFullText,
+}
+
+// filterJSON is the wire format of a Filter
+type filterJSON struct {
+	Conditions []conditionJSON `json:"conditions,omitempty"`
+	Sort       []sortJSON      `json:"sort,omitempty"`
+	Limit      *int            `json:"limit,omitempty"`
+	Offset     *int            `json:"offset,omitempty"`
+	Distinct   bool            `json:"distinct,omitempty"`
+	GroupBy    []string        `json:"group_by,omitempty"`
+	Having     []conditionJSON `json:"having,omitempty"`
+}
+
+// conditionJSON is the wire format of a Condition: either a leaf (field/op/value)
+// or a logical group (logic/conditions)
+type conditionJSON struct {
+	Field      string          `json:"field,omitempty"`
+	Operator   string          `json:"op,omitempty"`
+	Value      json.RawMessage `json:"value,omitempty"`
+	Logic      string          `json:"logic,omitempty"`
+	Conditions []conditionJSON `json:"conditions,omitempty"`
+}
+
+type sortJSON struct {
+	Field     string `json:"field"`
+	Direction string `json:"direction,omitempty"`
+	Nulls     string `json:"nulls,omitempty"` // "first" or "last"
+}
+
+type jsonPathJSON struct {
+	Path     []string        `json:"path"`
+	Operator string          `json:"op,omitempty"`
+	Value    json.RawMessage `json:"value,omitempty"`
+}
+
+type fullTextJSON struct {
+	Query    string `json:"query"`
+	Language string `json:"language,omitempty"`
+}
+
+// MarshalJSON encodes the filter into its JSON wire format:
+//
+//	{
+//	  "conditions": [
+//	    {"field": "balance", "op": ">", "value": 100},
+//	    {"logic": "OR", "conditions": [{"field": "status", "op": "=", "value": "active"}, ...]}
+//	  ],
+//	  "sort": [{"field": "balance", "direction": "DESC"}],
+//	  "limit": 10, "offset": 20, "distinct": true,
+//	  "group_by": ["status"], "having": [{"field": "COUNT(*)", "op": ">", "value": 5}]
+//	}
+func (f Filter) MarshalJSON() ([]byte, error) {
+	out := filterJSON{
+		Limit:    f.Limit,
+		Offset:   f.Offset,
+		Distinct: f.Distinct,
+		GroupBy:  f.GroupBy,
+	}
+
+	for _, c := range f.Conditions {
+		cj, err := encodeCondition(c)
+		if err != nil {
+			return nil, err
+		}
+		out.Conditions = append(out.Conditions, cj)
+	}
+	for _, c := range f.Having {
+		cj, err := encodeCondition(c)
+		if err != nil {
+			return nil, err
+		}
+		out.Having = append(out.Having, cj)
+	}
+	for _, s := range f.Sort {
+		sj := sortJSON{Field: s.Field, Direction: string(s.Direction)}
+		switch s.Nulls {
+		case NullsFirst:
+			sj.Nulls = "first"
+		case NullsLast:
+			sj.Nulls = "last"
+		}
+		out.Sort = append(out.Sort, sj)
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a filter from its JSON wire format. Operators and
+// logical operators are checked against the known set; field names are not,
+// use FilterAllowList.Validate before running filters received from clients.
+// Integral JSON numbers decode as int64, others as float64.
+func (f *Filter) UnmarshalJSON(data []byte) error {
+	var in filterJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+
+	decoded := Filter{
+		Limit:    in.Limit,
+		Offset:   in.Offset,
+		Distinct: in.Distinct,
+		GroupBy:  in.GroupBy,
+	}
+	for _, cj := range in.Conditions {
+		c, err := decodeCondition(cj)
+		if err != nil {
+			return err
+		}
+		decoded.Conditions = append(decoded.Conditions, c)
+	}
+	for _, cj := range in.Having {
+		c, err := decodeCondition(cj)
+		if err != nil {
+			return err
+		}
+		decoded.Having = append(decoded.Having, c)
+	}
+	for _, sj := range in.Sort {
+		dir := SortDirection(strings.ToUpper(sj.Direction))
+		if dir == "" {
+			dir = SortAsc
+		}
+		if dir != SortAsc && dir != SortDesc {
+			return fmt.Errorf("%w: invalid sort direction '%s'", ErrInvalidFilter, sj.Direction)
+		}
+		var nulls NullsOrder
+		switch strings.ToLower(sj.Nulls) {
+		case "":
+		case "first":
+			nulls = NullsFirst
+		case "last":
+			nulls = NullsLast
+		default:
+			return fmt.Errorf("%w: invalid nulls ordering '%s'", ErrInvalidFilter, sj.Nulls)
+		}
+		decoded.Sort = append(decoded.Sort, SortField{Field: sj.Field, Direction: dir, Nulls: nulls})
+	}
+
+	*f = decoded
+	return nil
+}
+
+func encodeCondition(c Condition) (conditionJSON, error) {
+	if c.IsComposite() {
+		cj := conditionJSON{Logic: string(c.LogicalOp)}
+		for _, nested := range c.Conditions {
+			n, err := encodeCondition(nested)
+			if err != nil {
+				return conditionJSON{}, err
+			}
+			cj.Conditions = append(cj.Conditions, n)
+		}
+		return cj, nil
+	}
+
+	cj := conditionJSON{Field: c.Field, Operator: string(c.Operator)}
+	var value any = c.Value
+	switch v := c.Value.(type) {
+	case JSONPath:
+		raw, err := json.Marshal(v.Value)
+		if err != nil {
+			return conditionJSON{}, err
+		}
+		value = jsonPathJSON{Path: v.Path, Operator: string(v.Operator), Value: raw}
+	case FullTextQuery:
+		value = fullTextJSON{Query: v.Query, Language: v.Language}
+	}
+	if value != nil {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return conditionJSON{}, fmt.Errorf("field %s: %w", c.Field, err)
+		}
+		cj.Value = raw
+	}
+	return cj, nil
+}
+
+func decodeCondition(cj conditionJSON) (Condition, error) {
+	if cj.Logic != "" {
+		op := LogicalOperator(strings.ToUpper(cj.Logic))
+		if op != LogicalAND && op != LogicalOR && op != LogicalNOT {
+			return Condition{}, fmt.Errorf("%w: invalid logical operator '%s'", ErrInvalidFilter, cj.Logic)
+		}
+		if len(cj.Conditions) == 0 {
+			return Condition{}, fmt.Errorf("%w: %s group has no conditions", ErrInvalidFilter, op)
+		}
+		c := Condition{LogicalOp: op}
+		for _, nested := range cj.Conditions {
+			n, err := decodeCondition(nested)
+			if err != nil {
+				return Condition{}, err
+			}
+			c.Conditions = append(c.Conditions, n)
+		}
+		return c, nil
+	}
+
+	op := ComparisonOperator(cj.Operator)
+	if !knownOperators[op] {
+		return Condition{}, fmt.Errorf("%w: unknown operator '%s'", ErrInvalidFilter, cj.Operator)
+	}
+	if cj.Field == "" {
+		return Condition{}, fmt.Errorf("%w: condition field cannot be empty", ErrInvalidFilter)
+	}
+
+	c := Condition{Field: cj.Field, Operator: op}
+	if len(cj.Value) == 0 {
+		return c, nil
+	}
+
+	switch op {
+	case OpJSONGet:
+		var jp jsonPathJSON
+		if err := json.Unmarshal(cj.Value, &jp); err != nil {
+			return Condition{}, fmt.Errorf("%w: field %s: %v", ErrInvalidFilter, cj.Field, err)
+		}
+		value, err := decodeJSONValue(jp.Value)
+		if err != nil {
+			return Condition{}, fmt.Errorf("%w: field %s: %v", ErrInvalidFilter, cj.Field, err)
+		}
+		c.Value = JSONPath{Path: jp.Path, Operator: ComparisonOperator(jp.Operator), Value: value}
+		return c, nil
+	case OpFullText:
+		var ft fullTextJSON
+		if err := json.Unmarshal(cj.Value, &ft); err == nil {
+			c.Value = FullTextQuery{Query: ft.Query, Language: ft.Language}
+			return c, nil
+		}
+	}
+
+	value, err := decodeJSONValue(cj.Value)
+	if err != nil {
+		return Condition{}, fmt.Errorf("%w: field %s: %v", ErrInvalidFilter, cj.Field, err)
+	}
+	c.Value = value
+	return c, nil
+}
+
+// decodeJSONValue decodes a raw JSON value, turning integral numbers into int64
+func decodeJSONValue(raw json.RawMessage) (any, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return convertJSONNumbers(v), nil
+}
+
+func convertJSONNumbers(v any) any {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case []any:
+		for i := range t {
+			t[i] = convertJSONNumbers(t[i])
+		}
+		return t
+	case map[string]any:
+		for k := range t {
+			t[k] = convertJSONNumbers(t[k])
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// FilterAllowList restricts what a client-supplied filter may contain
+type FilterAllowList struct {
+	// Fields maps each filterable field to its allowed operators.
+	// A field with no operators listed only allows OpEqual.
+	Fields map[string][]ComparisonOperator
+
+	// Sortable lists the fields clients may sort by
+	Sortable []string
+
+	// MaxLimit caps the page size; larger limits are clamped. 0 means no cap.
+	MaxLimit int
+
+	// DefaultLimit is applied when the client does not set a limit. 0 means no default.
+	DefaultLimit int
+
+	// AllowDistinct permits clients to request DISTINCT results
+	AllowDistinct bool
+
+	// Groupable lists the fields clients may group by. Having conditions may
+	// use these fields and CountField.
+	Groupable []string
+}
+
+// Validate checks every condition and sort field of f against the allow list
+// and applies MaxLimit and DefaultLimit. Nested groups are checked recursively.
+func (a FilterAllowList) Validate(f *Filter) error {
+	if f == nil {
+		return nil
+	}
+
+	for _, c := range f.Conditions {
+		if err := a.validateCondition(c); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range f.Sort {
+		grouped := len(f.GroupBy) > 0 && (s.Field == CountField || containsString(f.GroupBy, s.Field))
+		if !grouped && !containsString(a.Sortable, s.Field) {
+			return fmt.Errorf("%w: sorting by '%s' is not allowed", ErrInvalidFilter, s.Field)
+		}
+	}
+
+	for _, field := range f.GroupBy {
+		if !containsString(a.Groupable, field) {
+			return fmt.Errorf("%w: grouping by '%s' is not allowed", ErrInvalidFilter, field)
+		}
+	}
+	for _, c := range f.Having {
+		if err := a.validateHaving(c, f.GroupBy); err != nil {
+			return err
+		}
+	}
+
+	if f.Distinct && !a.AllowDistinct {
+		return fmt.Errorf("%w: distinct is not allowed", ErrInvalidFilter)
+	}
+	if f.Offset != nil && *f.Offset < 0 {
+		return fmt.Errorf("%w: offset cannot be negative", ErrInvalidFilter)
+	}
+	if f.Limit != nil && *f.Limit < 0 {
+		return fmt.Errorf("%w: limit cannot be negative", ErrInvalidFilter)
+	}
+
+	if f.Limit == nil && a.DefaultLimit > 0 {
+		limit := a.DefaultLimit
+		f.Limit = &limit
+	}
+	if a.MaxLimit > 0 && (f.Limit == nil || *f.Limit == 0 || *f.Limit > a.MaxLimit) {
+		limit := a.MaxLimit
+		f.Limit = &limit
+	}
+
+	return nil
+}
+
+func (a FilterAllowList) validateCondition(c Condition) error {
+	if c.IsComposite() {
+		for _, nested := range c.Conditions {
+			if err := a.validateCondition(nested); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ops, ok := a.Fields[c.Field]
+	if !ok {
+		return fmt.Errorf("%w: filtering by '%s' is not allowed", ErrInvalidFilter, c.Field)
+	}
+	if len(ops) == 0 {
+		ops = []ComparisonOperator{OpEqual}
+	}
+	for _, op := range ops {
+		if op == c.Operator {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: operator '%s' is not allowed on '%s'", ErrInvalidFilter, c.Operator, c.Field)
+}
+
+func (a FilterAllowList) validateHaving(c Condition, groupBy []string) error {
+	if c.IsComposite() {
+		for _, nested := range c.Conditions {
+			if err := a.validateHaving(nested, groupBy); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if c.Field != CountField && !containsString(groupBy, c.Field) {
+		return fmt.Errorf("%w: having on '%s' is not allowed", ErrInvalidFilter, c.Field)
+	}
+	if !knownOperators[c.Operator] {
+		return fmt.Errorf("%w: unknown operator '%s'", ErrInvalidFilter, c.Operator)
+	}
+	return nil
+}
+
+// ParseFilter builds a filter from URL query parameters and validates it against
+// the allow list. Values are converted to the type of T's field with the matching
+// db tag (including uuid.UUID, decimal.Decimal and time.Time via the converter registry).
+//
+// Supported parameters:
+//
+//	status=active                 equality
+//	balance[gte]=100              eq, ne, gt, gte, lt, lte, like, ilike, search
+//	status[in]=active,pending     in, nin (comma-separated)
+//	balance[between]=100,500      between (two comma-separated values)
+//	deleted_at[null]=true         null, notnull (value ignored)
+//	sort=-balance,name            comma-separated; "-" prefix sorts descending
+//	limit=20&offset=40            pagination
+//	distinct=true
+//
+// Repeated parameters add one condition each; all conditions are ANDed.
+func ParseFilter[T any](params url.Values, allow FilterAllowList) (*Filter, error) {
+	fieldTypes, err := dbFieldTypes[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	fb := NewFilter()
+	for key, values := range params {
+		switch key {
+		case "sort":
+			for _, v := range values {
+				for _, field := range strings.Split(v, ",") {
+					field = strings.TrimSpace(field)
+					if field == "" {
+						continue
+					}
+					if strings.HasPrefix(field, "-") {
+						fb.OrderBy(field[1:], SortDesc)
+					} else {
+						fb.OrderBy(strings.TrimPrefix(field, "+"), SortAsc)
+					}
+				}
+			}
+		case "limit", "offset":
+			n, err := strconv.Atoi(values[len(values)-1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid %s '%s'", ErrInvalidFilter, key, values[len(values)-1])
+			}
+			if key == "limit" {
+				fb.Limit(n)
+			} else {
+				fb.Offset(n)
+			}
+		case "distinct":
+			on, err := strconv.ParseBool(values[len(values)-1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid distinct '%s'", ErrInvalidFilter, values[len(values)-1])
+			}
+			if on {
+				fb.Distinct()
+			}
+		default:
+			field, op, err := parseQueryKey(key)
+			if err != nil {
+				return nil, err
+			}
+			fieldType, ok := fieldTypes[field]
+			if !ok {
+				return nil, fmt.Errorf("%w: filtering by '%s' is not allowed", ErrInvalidFilter, field)
+			}
+			for _, raw := range values {
+				value, err := parseQueryValue(op, raw, fieldType)
+				if err != nil {
+					return nil, fmt.Errorf("%w: field %s: %v", ErrInvalidFilter, field, err)
+				}
+				fb.Where(field, op, value)
+			}
+		}
+	}
+
+	filter := fb.Build()
+	// Map iteration order is random; keep the generated SQL stable
+	sortConditionsByField(filter.Conditions)
+
+	if err := allow.Validate(filter); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// parseQueryKey splits "field[op]" into the field and its operator
+func parseQueryKey(key string) (string, ComparisonOperator, error) {
+	open := strings.IndexByte(key, '[')
+	if open < 0 {
+		return key, OpEqual, nil
+	}
+	if !strings.HasSuffix(key, "]") || open == 0 {
+		return "", "", fmt.Errorf("%w: invalid parameter '%s'", ErrInvalidFilter, key)
+	}
+	op, ok := queryOperators[key[open+1:len(key)-1]]
+	if !ok {
+		return "", "", fmt.Errorf("%w: unknown operator in '%s'", ErrInvalidFilter, key)
+	}
+	return key[:open], op, nil
+}
+
+// parseQueryValue converts a raw parameter value for the given operator
+func parseQueryValue(op ComparisonOperator, raw string, t reflect.Type) (any, error) {
+	switch op {
+	case OpIsNull, OpIsNotNull:
+		return nil, nil
+	case OpLike, OpILike, OpRegex, OpIRegex, OpFullText:
+		return raw, nil
+	case OpIn, OpNotIn, OpBetween:
+		parts := strings.Split(raw, ",")
+		if op == OpBetween && len(parts) != 2 {
+			return nil, fmt.Errorf("between requires exactly two values")
+		}
+		values := make([]any, len(parts))
+		for i, p := range parts {
+			v, err := parseScalar(strings.TrimSpace(p), t)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+	default:
+		return parseScalar(raw, t)
+	}
+}
+
+// parseScalar converts s into a value of type t
+func parseScalar(s string, t reflect.Type) (any, error) {
+	if c, ok := DefaultConverters.Lookup(t); ok {
+		return c.DecodeString(s)
+	}
+	if t.Kind() == reflect.Ptr {
+		return parseScalar(s, t.Elem())
+	}
+
+	out := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		out.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, err
+		}
+		out.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return nil, err
+		}
+		out.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return nil, err
+		}
+		out.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return nil, err
+		}
+		out.SetFloat(f)
+	default:
+		return nil, fmt.Errorf("cannot parse values of type %s", t)
+	}
+	return out.Interface(), nil
+}
+
+// dbFieldTypes maps db tag names to struct field types for type T
+func dbFieldTypes[T any]() (map[string]reflect.Type, error) {
+	index, err := dbFieldIndex[T]()
+	if err != nil {
+		return nil, err
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	types := make(map[string]reflect.Type, len(index))
+	for name, i := range index {
+		types[name] = typ.FieldByIndex(i).Type
+	}
+	return types, nil
+}
+
+func sortConditionsByField(conditions []Condition) {
+	sortSlice(conditions, func(a, b *Condition) bool {
+		return a.Field < b.Field
+	})
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
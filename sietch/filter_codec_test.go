@@ -0,0 +1,189 @@
+package sietch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type listing struct {
+	ID       int64     `db:"id"`
+	Status   string    `db:"status"`
+	Price    float64   `db:"price"`
+	Beds     int       `db:"beds"`
+	ListedAt time.Time `db:"listed_at"`
+	Internal string    `db:"internal_notes"`
+}
+
+var listingAllowList = FilterAllowList{
+	Fields: map[string][]ComparisonOperator{
+		"status":    nil,
+		"price":     {OpGreaterThanOrEqual, OpLessThanOrEqual, OpBetween},
+		"beds":      {OpEqual, OpIn},
+		"listed_at": {OpGreaterThan},
+	},
+	Sortable:     []string{"price", "listed_at"},
+	MaxLimit:     50,
+	DefaultLimit: 20,
+}
+
+func TestFilter_JSONRoundTrip(t *testing.T) {
+	original := NewFilter().
+		Where("beds", OpIn, []any{int64(2), int64(3)}).
+		Where("price", OpGreaterThan, 99.5).
+		WhereJSONGet("meta", []string{"tier"}, OpEqual, "gold").
+		Where("body", OpFullText, FullTextQuery{Query: "sunny", Language: "english"}).
+		Where("deleted_at", OpIsNull, nil).
+		Or(
+			Condition{Field: "status", Operator: OpEqual, Value: "active"},
+			Condition{Field: "status", Operator: OpEqual, Value: "pending"},
+		).
+		OrderBy("price", SortDesc).
+		OrderByNulls("listed_at", SortAsc, NullsLast).
+		Limit(10).
+		Offset(20).
+		Distinct().
+		Build()
+
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var decoded Filter
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(original.Conditions, decoded.Conditions) {
+		t.Errorf("Conditions differ:\nwant %#v\ngot  %#v", original.Conditions, decoded.Conditions)
+	}
+	if !reflect.DeepEqual(original.Sort, decoded.Sort) {
+		t.Errorf("Sort differs: %v vs %v", original.Sort, decoded.Sort)
+	}
+	if *decoded.Limit != 10 || *decoded.Offset != 20 || !decoded.Distinct {
+		t.Errorf("Pagination not preserved: %+v", decoded)
+	}
+}
+
+func TestFilter_UnmarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"unknown operator", `{"conditions":[{"field":"a","op":"; DROP","value":1}]}`},
+		{"empty field", `{"conditions":[{"op":"=","value":1}]}`},
+		{"bad logic", `{"conditions":[{"logic":"XOR","conditions":[{"field":"a","op":"=","value":1}]}]}`},
+		{"empty group", `{"conditions":[{"logic":"OR"}]}`},
+		{"bad direction", `{"sort":[{"field":"a","direction":"sideways"}]}`},
+		{"bad nulls", `{"sort":[{"field":"a","nulls":"middle"}]}`},
+		{"malformed", `{"conditions":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f Filter
+			if err := f.UnmarshalJSON([]byte(tt.json)); !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("Expected ErrInvalidFilter, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFilterAllowList_Validate(t *testing.T) {
+	t.Run("Nested disallowed field", func(t *testing.T) {
+		f := NewFilter().Or(
+			Condition{Field: "status", Operator: OpEqual, Value: "active"},
+			Condition{Field: "internal_notes", Operator: OpLike, Value: "%x%"},
+		).Build()
+		if err := listingAllowList.Validate(f); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter, got %v", err)
+		}
+	})
+
+	t.Run("Disallowed operator", func(t *testing.T) {
+		f := NewFilter().Where("status", OpLike, "%").Build()
+		if err := listingAllowList.Validate(f); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter, got %v", err)
+		}
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		f := NewFilter().Build()
+		if err := listingAllowList.Validate(f); err != nil || *f.Limit != 20 {
+			t.Errorf("Expected default limit 20, got %v (%v)", f.Limit, err)
+		}
+		f = NewFilter().Limit(1000).Build()
+		if err := listingAllowList.Validate(f); err != nil || *f.Limit != 50 {
+			t.Errorf("Expected limit clamped to 50, got %v (%v)", f.Limit, err)
+		}
+	})
+}
+
+func TestParseFilter(t *testing.T) {
+	params, _ := url.ParseQuery("status=active&price[between]=100,250.5&beds[in]=2,3" +
+		"&listed_at[gt]=2024-01-01T00:00:00Z&sort=-price,listed_at&limit=10&offset=5")
+
+	f, err := ParseFilter[listing](params, listingAllowList)
+	if err != nil {
+		t.Fatalf("ParseFilter failed: %v", err)
+	}
+
+	expected := []Condition{
+		{Field: "beds", Operator: OpIn, Value: []any{2, 3}},
+		{Field: "listed_at", Operator: OpGreaterThan, Value: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Field: "price", Operator: OpBetween, Value: []any{100.0, 250.5}},
+		{Field: "status", Operator: OpEqual, Value: "active"},
+	}
+	if !reflect.DeepEqual(f.Conditions, expected) {
+		t.Errorf("Conditions differ:\nwant %#v\ngot  %#v", expected, f.Conditions)
+	}
+	if !reflect.DeepEqual(f.Sort, []SortField{{Field: "price", Direction: SortDesc}, {Field: "listed_at", Direction: SortAsc}}) {
+		t.Errorf("Unexpected sort: %v", f.Sort)
+	}
+	if *f.Limit != 10 || *f.Offset != 5 {
+		t.Errorf("Unexpected pagination: %d/%d", *f.Limit, *f.Offset)
+	}
+
+	t.Run("Runs against a repository", func(t *testing.T) {
+		repo := NewInMemoryConnector[listing, int64](func(l *listing) int64 { return l.ID })
+		repo.BatchCreate(context.Background(), []listing{
+			{ID: 1, Status: "active", Price: 150, Beds: 2, ListedAt: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+			{ID: 2, Status: "active", Price: 300, Beds: 2, ListedAt: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+			{ID: 3, Status: "sold", Price: 150, Beds: 3, ListedAt: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		})
+		params, _ := url.ParseQuery("status=active&price[between]=100,250")
+		f, err := ParseFilter[listing](params, listingAllowList)
+		if err != nil {
+			t.Fatalf("ParseFilter failed: %v", err)
+		}
+		results, err := repo.Query(context.Background(), f)
+		if err != nil || len(results) != 1 || results[0].ID != 1 {
+			t.Errorf("Expected listing 1, got %v (%v)", results, err)
+		}
+	})
+
+	errorCases := []string{
+		"internal_notes=x",
+		"unknown=x",
+		"status[like]=a%25",
+		"price[regex]=1",
+		"beds=two",
+		"price[between]=1",
+		"sort=internal_notes",
+		"limit=ten",
+		"distinct=true",
+	}
+	for _, q := range errorCases {
+		t.Run(q, func(t *testing.T) {
+			params, _ := url.ParseQuery(q)
+			if _, err := ParseFilter[listing](params, listingAllowList); !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("Expected ErrInvalidFilter, got %v", err)
+			}
+		})
+	}
+}
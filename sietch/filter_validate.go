@@ -0,0 +1,415 @@
+package sietch
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FilterValidator defines an optional interface for checking a filter before
+// it is executed. CockroachDBConnector and InMemoryConnector implement it.
+//
+//	if v, ok := repo.(sietch.FilterValidator); ok {
+//	    if err := v.ValidateFilter(filter); err != nil { ... }
+//	}
+type FilterValidator interface {
+	// ValidateFilter checks every referenced field, operator/value combination
+	// and logical group of filter. It returns nil or a *FilterValidationError.
+	ValidateFilter(filter *Filter) error
+}
+
+// FilterIssue is one problem found in a filter
+type FilterIssue struct {
+	Path    string // Location in the filter, e.g. "Conditions[1].Conditions[0]" or "Sort[0]"
+	Field   string // Field involved, if any
+	Message string
+}
+
+func (i FilterIssue) String() string {
+	if i.Field != "" {
+		return fmt.Sprintf("%s (%s): %s", i.Path, i.Field, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// FilterValidationError lists every problem found by ValidateFilter.
+// It matches ErrInvalidFilter with errors.Is.
+type FilterValidationError struct {
+	Issues []FilterIssue
+}
+
+func (e *FilterValidationError) Error() string {
+	msgs := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		msgs[i] = issue.String()
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidFilter, strings.Join(msgs, "; "))
+}
+
+func (e *FilterValidationError) Is(target error) bool {
+	return target == ErrInvalidFilter
+}
+
+// filterValidator collects the issues of one filter. fieldType resolves a
+// field name to its Go type the way the connector resolves columns.
+type filterValidator struct {
+	fieldType func(field string) (reflect.Type, bool)
+	issues    []FilterIssue
+}
+
+// validateFilter checks filter against the fields known to fieldType
+func validateFilter(filter *Filter, fieldType func(string) (reflect.Type, bool)) error {
+	if filter == nil {
+		return nil
+	}
+
+	v := &filterValidator{fieldType: fieldType}
+	for i, c := range filter.Conditions {
+		v.condition(fmt.Sprintf("Conditions[%d]", i), c)
+	}
+
+	grouped := len(filter.GroupBy) > 0
+	for i, field := range filter.GroupBy {
+		v.knownField(fmt.Sprintf("GroupBy[%d]", i), field)
+	}
+	if !grouped && len(filter.Having) > 0 {
+		v.add("Having", "", "HAVING requires GROUP BY")
+	}
+	for i, c := range filter.Having {
+		v.having(fmt.Sprintf("Having[%d]", i), c, filter.GroupBy)
+	}
+
+	for i, sf := range filter.Sort {
+		path := fmt.Sprintf("Sort[%d]", i)
+		if sf.Direction != SortAsc && sf.Direction != SortDesc {
+			v.add(path, sf.Field, fmt.Sprintf("invalid sort direction '%s'", sf.Direction))
+		}
+		if sf.Nulls != NullsDefault && sf.Nulls != NullsFirst && sf.Nulls != NullsLast {
+			v.add(path, sf.Field, fmt.Sprintf("invalid nulls ordering '%s'", sf.Nulls))
+		}
+		switch {
+		case sf.Field == RandomField:
+		case grouped && sf.Field == CountField:
+		case grouped && !containsString(filter.GroupBy, sf.Field):
+			v.add(path, sf.Field, "cannot sort by a field that is not in GROUP BY")
+		default:
+			v.knownField(path, sf.Field)
+		}
+	}
+
+	if filter.Limit != nil && *filter.Limit < 0 {
+		v.add("Limit", "", "limit cannot be negative")
+	}
+	if filter.Offset != nil && *filter.Offset < 0 {
+		v.add("Offset", "", "offset cannot be negative")
+	}
+
+	if len(v.issues) > 0 {
+		return &FilterValidationError{Issues: v.issues}
+	}
+	return nil
+}
+
+func (v *filterValidator) add(path, field, msg string) {
+	v.issues = append(v.issues, FilterIssue{Path: path, Field: field, Message: msg})
+}
+
+func (v *filterValidator) knownField(path, field string) (reflect.Type, bool) {
+	t, ok := v.fieldType(field)
+	if !ok {
+		v.add(path, field, "unknown field")
+	}
+	return t, ok
+}
+
+func (v *filterValidator) condition(path string, c Condition) {
+	if c.LogicalOp != "" || len(c.Conditions) > 0 {
+		v.group(path, c, v.condition)
+		return
+	}
+
+	switch c.Operator {
+	case OpExists:
+		v.subquery(path, c.Value)
+		return
+	case OpInSubquery:
+		v.knownField(path, c.Field)
+		v.inSubquery(path, c)
+		return
+	}
+
+	t, ok := v.knownField(path, c.Field)
+	if !ok {
+		return
+	}
+	v.operatorValue(path, c, t)
+}
+
+// group checks a logical group and its nested conditions with leaf
+func (v *filterValidator) group(path string, c Condition, leaf func(string, Condition)) {
+	if c.Field != "" || c.Operator != "" {
+		v.add(path, c.Field, "a condition cannot be both a comparison and a logical group")
+	}
+	switch c.LogicalOp {
+	case LogicalAND, LogicalOR:
+		if len(c.Conditions) == 0 {
+			v.add(path, "", fmt.Sprintf("%s group has no conditions", c.LogicalOp))
+		}
+	case LogicalNOT:
+		if len(c.Conditions) != 1 {
+			v.add(path, "", "NOT requires exactly one condition")
+		}
+	default:
+		v.add(path, "", fmt.Sprintf("unknown logical operator '%s'", c.LogicalOp))
+	}
+	for i, nested := range c.Conditions {
+		leaf(fmt.Sprintf("%s.Conditions[%d]", path, i), nested)
+	}
+}
+
+func (v *filterValidator) having(path string, c Condition, groupBy []string) {
+	if c.LogicalOp != "" || len(c.Conditions) > 0 {
+		v.group(path, c, func(p string, nested Condition) { v.having(p, nested, groupBy) })
+		return
+	}
+	if c.Field == CountField {
+		if !isNumeric(c.Value) && c.Operator != OpBetween && c.Operator != OpIn && c.Operator != OpNotIn {
+			v.add(path, c.Field, fmt.Sprintf("%s requires a numeric value", CountField))
+		}
+		return
+	}
+	if !containsString(groupBy, c.Field) {
+		v.add(path, c.Field, "HAVING may only reference GROUP BY fields and CountField")
+		return
+	}
+	if t, ok := v.knownField(path, c.Field); ok {
+		v.operatorValue(path, c, t)
+	}
+}
+
+// operatorValue checks that the operator applies to a field of type t and
+// that the value has the shape and type the operator expects
+func (v *filterValidator) operatorValue(path string, c Condition, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch c.Operator {
+	case OpEqual, OpNotEqual, OpGreaterThan, OpLessThan, OpGreaterThanOrEqual, OpLessThanOrEqual:
+		if (c.Operator == OpEqual || c.Operator == OpNotEqual) && isNullValue(c.Value) {
+			return // IS [NOT] NULL
+		}
+		if !valueFitsType(c.Value, t) {
+			v.add(path, c.Field, fmt.Sprintf("%s value %s does not match field type %s", c.Operator, describeValue(c.Value), t))
+		}
+
+	case OpLike, OpILike, OpRegex, OpIRegex:
+		pattern, ok := c.Value.(string)
+		if !ok {
+			v.add(path, c.Field, fmt.Sprintf("%s requires a string pattern, got %s", c.Operator, describeValue(c.Value)))
+		} else if c.Operator == OpRegex || c.Operator == OpIRegex {
+			if _, err := regexp.Compile(pattern); err != nil {
+				v.add(path, c.Field, fmt.Sprintf("invalid regular expression: %v", err))
+			}
+		}
+		if t.Kind() != reflect.String {
+			v.add(path, c.Field, fmt.Sprintf("%s requires a string field, got %s", c.Operator, t))
+		}
+
+	case OpIn, OpNotIn:
+		elems, ok := sliceElements(c.Value)
+		if !ok || len(elems) == 0 {
+			v.add(path, c.Field, fmt.Sprintf("%s requires a non-empty slice, got %s", c.Operator, describeValue(c.Value)))
+			return
+		}
+		for i, e := range elems {
+			if !valueFitsType(e, t) {
+				v.add(path, c.Field, fmt.Sprintf("%s element %d %s does not match field type %s", c.Operator, i, describeValue(e), t))
+			}
+		}
+
+	case OpIsNull, OpIsNotNull:
+
+	case OpBetween:
+		elems, ok := sliceElements(c.Value)
+		if !ok || len(elems) != 2 {
+			v.add(path, c.Field, fmt.Sprintf("BETWEEN requires exactly 2 values, got %s", describeValue(c.Value)))
+			return
+		}
+		for i, e := range elems {
+			if !valueFitsType(e, t) {
+				v.add(path, c.Field, fmt.Sprintf("BETWEEN bound %d %s does not match field type %s", i, describeValue(e), t))
+			}
+		}
+
+	case OpJSONContains:
+		if c.Value == nil {
+			v.add(path, c.Field, "@> requires a JSON document")
+		}
+
+	case OpJSONPathExists:
+		if _, err := toJSONPath(c.Value); err != nil {
+			v.add(path, c.Field, err.Error())
+		}
+
+	case OpJSONGet:
+		jp, ok := c.Value.(JSONPath)
+		if p, isPtr := c.Value.(*JSONPath); isPtr && p != nil {
+			jp, ok = *p, true
+		}
+		if !ok {
+			v.add(path, c.Field, fmt.Sprintf("->> requires a JSONPath value, got %s", describeValue(c.Value)))
+		} else if len(jp.Path) == 0 {
+			v.add(path, c.Field, "JSON path cannot be empty")
+		}
+
+	case OpArrayContains, OpArrayContainedBy, OpArrayOverlaps:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			v.add(path, c.Field, fmt.Sprintf("%s requires an array field, got %s", c.Operator, t))
+		}
+		if _, ok := sliceElements(c.Value); !ok {
+			v.add(path, c.Field, fmt.Sprintf("%s requires a slice value, got %s", c.Operator, describeValue(c.Value)))
+		}
+
+	case OpArrayAny:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			v.add(path, c.Field, fmt.Sprintf("%s requires an array field, got %s", c.Operator, t))
+		} else if !valueFitsType(c.Value, t.Elem()) {
+			v.add(path, c.Field, fmt.Sprintf("%s value %s does not match element type %s", c.Operator, describeValue(c.Value), t.Elem()))
+		}
+
+	case OpFullText:
+		if _, err := toFullTextQuery(c.Value); err != nil {
+			v.add(path, c.Field, err.Error())
+		}
+		if t.Kind() != reflect.String {
+			v.add(path, c.Field, fmt.Sprintf("%s requires a string field, got %s", c.Operator, t))
+		}
+
+	default:
+		v.add(path, c.Field, fmt.Sprintf("unknown operator '%s'", c.Operator))
+	}
+}
+
+// subquery checks the shape of an EXISTS subquery. The columns of other
+// tables are not known here, so only their names are checked.
+func (v *filterValidator) subquery(path string, value any) {
+	sq, ok := value.(Subquery)
+	if p, isPtr := value.(*Subquery); isPtr && p != nil {
+		sq, ok = *p, true
+	}
+	if !ok {
+		v.add(path, "", fmt.Sprintf("EXISTS requires a Subquery value, got %s", describeValue(value)))
+		return
+	}
+	if err := sanitizeIdentifier(sq.Table); err != nil {
+		v.add(path, "", fmt.Sprintf("invalid subquery table: %v", err))
+	}
+	if err := validateSubqueryFilter(sq.Filter); err != nil {
+		v.add(path, "", err.Error())
+	}
+	for i, c := range sq.Correlation {
+		if err := sanitizeIdentifier(c.Column); err != nil {
+			v.add(fmt.Sprintf("%s.Correlation[%d]", path, i), c.Column, err.Error())
+		}
+		v.knownField(fmt.Sprintf("%s.Correlation[%d]", path, i), c.Outer)
+	}
+}
+
+func (v *filterValidator) inSubquery(path string, c Condition) {
+	sq, ok := c.Value.(InSubquery)
+	if p, isPtr := c.Value.(*InSubquery); isPtr && p != nil {
+		sq, ok = *p, true
+	}
+	if !ok || sq.Source == nil {
+		v.add(path, c.Field, fmt.Sprintf("IN SUBQUERY requires an InSubquery value with a source, got %s", describeValue(c.Value)))
+		return
+	}
+	if err := validateSubqueryFilter(sq.Filter); err != nil {
+		v.add(path, c.Field, err.Error())
+	}
+	if fv, ok := sq.Source.(FilterValidator); ok {
+		if err := fv.ValidateFilter(sq.Filter); err != nil {
+			v.add(path, c.Field, fmt.Sprintf("subquery: %v", err))
+		}
+	}
+}
+
+// valueFitsType reports whether value can be compared with a field of type t.
+// Numbers are interchangeable, converter-backed types accept whatever their
+// converter normalizes, and time fields also accept RelativeTime and strings.
+func valueFitsType(value any, t reflect.Type) bool {
+	if value == nil {
+		return false
+	}
+	if t.Kind() == reflect.Interface {
+		return true
+	}
+	if _, ok := DefaultConverters.Lookup(t); ok {
+		if _, err := DefaultConverters.normalizeValue(value); err == nil {
+			return true
+		}
+	}
+
+	vt := reflect.TypeOf(value)
+	for vt.Kind() == reflect.Ptr {
+		vt = vt.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		switch value.(type) {
+		case RelativeTime, string:
+			return true
+		}
+	}
+	switch {
+	case isNumericKind(t.Kind()):
+		return isNumericKind(vt.Kind())
+	case t.Kind() == reflect.String:
+		return vt.Kind() == reflect.String
+	case t.Kind() == reflect.Bool:
+		return vt.Kind() == reflect.Bool
+	}
+	return vt.AssignableTo(t)
+}
+
+func isNumeric(v any) bool {
+	return v != nil && isNumericKind(reflect.TypeOf(v).Kind())
+}
+
+func describeValue(v any) string {
+	if v == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// ValidateFilter checks filter against the connector's columns without
+// running it. See FilterValidator.
+func (r *CockroachDBConnector[T, ID]) ValidateFilter(filter *Filter) error {
+	types, err := dbFieldTypes[T]()
+	if err != nil {
+		return err
+	}
+	return validateFilter(filter, func(field string) (reflect.Type, bool) {
+		if r.validateFilterField(field) != nil {
+			return nil, false
+		}
+		t, ok := types[field]
+		return t, ok
+	})
+}
+
+// ValidateFilter checks filter against the fields of T without running it.
+// Fields resolve as in Query: by db tag or Go field name. See FilterValidator.
+func (r *InMemoryConnector[T, ID]) ValidateFilter(filter *Filter) error {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return validateFilter(filter, func(field string) (reflect.Type, bool) {
+		path, ok := columnPath(typ, field)
+		if !ok {
+			return nil, false
+		}
+		return typ.FieldByIndex(path).Type, true
+	})
+}
@@ -0,0 +1,136 @@
+package sietch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type validatedItem struct {
+	ID        int64      `db:"id"`
+	Name      string     `db:"name"`
+	Score     float64    `db:"score"`
+	Tags      []string   `db:"tags"`
+	Active    bool       `db:"active"`
+	CreatedAt time.Time  `db:"created_at"`
+	DeletedAt *time.Time `db:"deleted_at"`
+	Internal  string
+}
+
+func validators(t *testing.T) map[string]FilterValidator {
+	t.Helper()
+	crdb, err := NewCockroachDBConnector[validatedItem, int64](&pgxpool.Pool{}, "items", func(i *validatedItem) int64 { return i.ID })
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+	return map[string]FilterValidator{
+		"cockroach": crdb,
+		"inmemory":  NewInMemoryConnector[validatedItem, int64](func(i *validatedItem) int64 { return i.ID }),
+	}
+}
+
+func TestValidateFilter_Valid(t *testing.T) {
+	filters := map[string]*Filter{
+		"nil":                               nil,
+		"comparison":                        NewFilter().Where("id", OpEqual, 1).Where("score", OpGreaterThan, 2).Build(),
+		"numeric types are interchangeable": NewFilter().Where("id", OpIn, []int{1, 2}).Where("score", OpBetween, []any{1, 2.5}).Build(),
+		"time values": NewFilter().
+			WhereTimeBetween("created_at", time.Now().Add(-time.Hour), time.Now()).
+			WhereOlderThan("deleted_at", time.Hour).
+			Where("created_at", OpGreaterThan, "2024-01-01T00:00:00Z").
+			Build(),
+		"patterns": NewFilter().Where("name", OpLike, "a%").Where("name", OpIRegex, "^a.*z$").Build(),
+		"arrays":   NewFilter().WhereArrayContains("tags", []string{"a"}).Where("tags", OpArrayAny, "b").Build(),
+		"groups": NewFilter().
+			Or(Condition{Field: "active", Operator: OpEqual, Value: true}, Condition{Field: "deleted_at", Operator: OpIsNull}).
+			Not(Condition{Field: "name", Operator: OpEqual, Value: "x"}).
+			Build(),
+		"sorting":  NewFilter().OrderByNulls("deleted_at", SortDesc, NullsLast).OrderRandom().Limit(5).Offset(10).Build(),
+		"grouping": NewFilter().GroupBy("active").Having(Condition{Field: CountField, Operator: OpGreaterThan, Value: 1}).OrderBy(CountField, SortDesc).Build(),
+		"exists":   NewFilter().WhereExists(NewFilter().Where("status", OpEqual, "open").Build(), "orders", Correlate("item_id", "id")).Build(),
+	}
+
+	for name, v := range validators(t) {
+		for fname, f := range filters {
+			t.Run(name+"/"+fname, func(t *testing.T) {
+				if err := v.ValidateFilter(f); err != nil {
+					t.Errorf("Expected a valid filter, got %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestValidateFilter_Issues(t *testing.T) {
+	negative := -1
+	filter := &Filter{
+		Conditions: []Condition{
+			{Field: "missing", Operator: OpEqual, Value: 1},
+			{Field: "id", Operator: OpEqual, Value: "one"},
+			{LogicalOp: LogicalOR, Conditions: []Condition{
+				{Field: "name", Operator: OpRegex, Value: "("},
+				{Field: "score", Operator: OpLike, Value: "1%"},
+			}},
+			{LogicalOp: LogicalNOT},
+			{Field: "id", Operator: OpIn, Value: []int{}},
+			{Field: "score", Operator: OpBetween, Value: []any{1}},
+			{Field: "name", Operator: "~~"},
+			{Field: "name", Operator: OpArrayContains, Value: []string{"a"}},
+		},
+		Sort:   []SortField{{Field: "name", Direction: "sideways"}},
+		Limit:  &negative,
+		Having: []Condition{{Field: CountField, Operator: OpGreaterThan, Value: 1}},
+	}
+
+	expected := []string{
+		"Conditions[0] (missing): unknown field",
+		"Conditions[1] (id): = value string does not match field type int64",
+		"Conditions[2].Conditions[0] (name): invalid regular expression",
+		"Conditions[2].Conditions[1] (score): LIKE requires a string field, got float64",
+		"Conditions[3]: NOT requires exactly one condition",
+		"Conditions[4] (id): IN requires a non-empty slice",
+		"Conditions[5] (score): BETWEEN requires exactly 2 values",
+		"Conditions[6] (name): unknown operator '~~'",
+		"Conditions[7] (name): ARRAY @> requires an array field, got string",
+		"Having: HAVING requires GROUP BY",
+		"Sort[0] (name): invalid sort direction 'sideways'",
+		"Limit: limit cannot be negative",
+	}
+
+	for name, v := range validators(t) {
+		t.Run(name, func(t *testing.T) {
+			err := v.ValidateFilter(filter)
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Fatalf("Expected ErrInvalidFilter, got %v", err)
+			}
+			var verr *FilterValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Expected *FilterValidationError, got %T", err)
+			}
+			if len(verr.Issues) != len(expected) {
+				t.Fatalf("Expected %d issues, got %d: %v", len(expected), len(verr.Issues), err)
+			}
+			for i, issue := range verr.Issues {
+				if !strings.HasPrefix(issue.String(), expected[i]) {
+					t.Errorf("Issue %d: expected prefix %q, got %q", i, expected[i], issue.String())
+				}
+			}
+		})
+	}
+}
+
+func TestValidateFilter_FieldResolution(t *testing.T) {
+	// InMemory resolves Go field names like Query does; CockroachDB only knows db columns
+	filter := NewFilter().Where("Internal", OpEqual, "x").Build()
+
+	v := validators(t)
+	if err := v["inmemory"].ValidateFilter(filter); err != nil {
+		t.Errorf("Expected InMemory to accept Go field names, got %v", err)
+	}
+	if err := v["cockroach"].ValidateFilter(filter); err == nil {
+		t.Error("Expected CockroachDB to reject a field without a db column")
+	}
+}
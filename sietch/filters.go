@@ -1,5 +1,12 @@
 package sietch
 
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
 // ComparisonOperator represents a type-safe comparison operator
 type ComparisonOperator string
 
@@ -17,11 +24,120 @@ const (
 	OpNotIn     ComparisonOperator = "NOT IN"    // Value should be a slice
 	OpLike      ComparisonOperator = "LIKE"      // Pattern matching (case-sensitive)
 	OpILike     ComparisonOperator = "ILIKE"     // Pattern matching (case-insensitive)
+	OpRegex     ComparisonOperator = "~"         // Regular expression match (case-sensitive)
+	OpIRegex    ComparisonOperator = "~*"        // Regular expression match (case-insensitive)
 	OpIsNull    ComparisonOperator = "IS NULL"   // Value is ignored
 	OpIsNotNull ComparisonOperator = "IS NOT NULL" // Value is ignored
 	OpBetween   ComparisonOperator = "BETWEEN"   // Value should be [2]any{min, max}
+
+	// JSONB operators
+	OpJSONContains   ComparisonOperator = "@>"          // Value is any JSON-encodable document
+	OpJSONPathExists ComparisonOperator = "JSON EXISTS" // Value should be a key string or []string path
+	OpJSONGet        ComparisonOperator = "->>"         // Value should be a JSONPath
+
+	// Array operators
+	OpArrayContains    ComparisonOperator = "ARRAY @>" // Value should be a slice; column contains all elements
+	OpArrayContainedBy ComparisonOperator = "ARRAY <@" // Value should be a slice; column elements are all in value
+	OpArrayOverlaps    ComparisonOperator = "ARRAY &&" // Value should be a slice; column shares at least one element
+	OpArrayAny         ComparisonOperator = "= ANY"    // Value is a single element; column has an element equal to it
+
+	// Text search operators
+	OpFullText ComparisonOperator = "@@" // Value should be a search query string or FullTextQuery
+
+	// Subquery operators
+	OpExists     ComparisonOperator = "EXISTS"      // Value should be a Subquery; Field is ignored
+	OpInSubquery ComparisonOperator = "IN SUBQUERY" // Value should be an InSubquery
 )
 
+// Subquery is the value of an OpExists condition. It matches when Table has
+// at least one row satisfying the conditions of Filter and every Correlation.
+type Subquery struct {
+	Table       string
+	Filter      *Filter // Only conditions are supported; may be nil
+	Correlation []Correlation
+}
+
+// Correlation ties a column of a subquery table to a column of the outer table
+type Correlation struct {
+	Column string // Column of the subquery table
+	Outer  string // Column of the outer table
+}
+
+// Correlate returns a Correlation requiring column of the subquery table to
+// equal outer of the enclosing row
+func Correlate(column, outer string) Correlation {
+	return Correlation{Column: column, Outer: outer}
+}
+
+// Exists returns a condition matching rows for which table has at least one
+// row matching sub and the correlation, generated as
+// EXISTS (SELECT 1 FROM table WHERE ...). sub may be nil.
+func Exists(sub *Filter, table string, correlation ...Correlation) Condition {
+	return Condition{
+		Operator: OpExists,
+		Value:    Subquery{Table: table, Filter: sub, Correlation: correlation},
+	}
+}
+
+// NotExists returns the negation of Exists
+func NotExists(sub *Filter, table string, correlation ...Correlation) Condition {
+	return Condition{
+		LogicalOp:  LogicalNOT,
+		Conditions: []Condition{Exists(sub, table, correlation...)},
+	}
+}
+
+// FullTextQuery is the value of an OpFullText condition when a text search
+// configuration other than the default is needed
+type FullTextQuery struct {
+	Query    string
+	Language string // Text search configuration (e.g. "english"); defaults to "simple"
+}
+
+// JSONPath is the value of an OpJSONGet condition.
+// The text at Path is extracted from the JSONB column and compared against Value.
+type JSONPath struct {
+	Path     []string           // Keys (or array indexes) to traverse
+	Operator ComparisonOperator // Comparison applied to the extracted value; defaults to OpEqual
+	Value    any
+}
+
+// RelativeTime is a condition value resolved against the current time when the
+// query runs rather than when the filter is built, so a stored filter such as
+// "older than 30 days" stays correct. It is resolved with the application clock.
+type RelativeTime struct {
+	Offset time.Duration // Added to the current time; negative for the past
+}
+
+// Ago returns a RelativeTime d before the time the query runs
+func Ago(d time.Duration) RelativeTime {
+	return RelativeTime{Offset: -d}
+}
+
+// FromNow returns a RelativeTime d after the time the query runs
+func FromNow(d time.Duration) RelativeTime {
+	return RelativeTime{Offset: d}
+}
+
+// Time resolves the relative time against the current time
+func (r RelativeTime) Time() time.Time {
+	return time.Now().Add(r.Offset)
+}
+
+// Value implements driver.Valuer, so SQL connectors resolve the time when the
+// query arguments are encoded
+func (r RelativeTime) Value() (driver.Value, error) {
+	return r.Time(), nil
+}
+
+// CountField refers to the number of rows in each group. It can be used as
+// the field of Having conditions and as a sort field of grouped queries.
+const CountField = "COUNT(*)"
+
+// RandomField can be used as a sort field to return rows in random order.
+// Fields sorted after it have no effect. See FilterBuilder.Sample.
+const RandomField = "random()"
+
 // SortDirection represents the sorting direction
 type SortDirection string
 
@@ -30,10 +146,20 @@ const (
 	SortDesc SortDirection = "DESC"
 )
 
+// NullsOrder controls where NULL values are placed when sorting
+type NullsOrder string
+
+const (
+	NullsDefault NullsOrder = ""            // Backend default: NULLs first for ASC, last for DESC
+	NullsFirst   NullsOrder = "NULLS FIRST" // NULLs before every value
+	NullsLast    NullsOrder = "NULLS LAST"  // NULLs after every value
+)
+
 // SortField represents a field to sort by with its direction
 type SortField struct {
 	Field     string
 	Direction SortDirection
+	Nulls     NullsOrder // Placement of NULL values; NullsDefault if empty
 }
 
 // LogicalOperator represents logical operators for combining conditions
@@ -68,6 +194,15 @@ func (c *Condition) IsComposite() bool {
 	return c.LogicalOp != "" && len(c.Conditions) > 0
 }
 
+// LockMode is a row locking clause for queries run within a transaction
+type LockMode string
+
+const (
+	LockNone      LockMode = ""
+	LockForUpdate LockMode = "FOR UPDATE" // block writers and other lockers
+	LockForShare  LockMode = "FOR SHARE"  // block writers only
+)
+
 // Filter groups a set of conditions with sorting, pagination, and distinct options
 type Filter struct {
 	Conditions []Condition
@@ -75,6 +210,9 @@ type Filter struct {
 	Limit      *int        // Pointer to distinguish between 0 and not set
 	Offset     *int        // For pagination
 	Distinct   bool        // Return distinct results
+	GroupBy    []string    // Group results by these fields
+	Having     []Condition // Conditions on groups (grouped fields or CountField)
+	Lock       LockMode    // Lock the returned rows, see FilterBuilder.Lock
 }
 
 // FilterBuilder provides a fluent interface for building filters
@@ -84,6 +222,9 @@ type FilterBuilder struct {
 	limit      *int
 	offset     *int
 	distinct   bool
+	groupBy    []string
+	having     []Condition
+	lock       LockMode
 }
 
 // NewFilter creates a new FilterBuilder
@@ -104,6 +245,111 @@ func (fb *FilterBuilder) Where(field string, op ComparisonOperator, value any) *
 	return fb
 }
 
+// WhereIf adds a condition only when cond is true, so optional parameters
+// can be applied without breaking the chain:
+//
+//	filter := sietch.NewFilter().
+//	    WhereIf(status != "", "status", sietch.OpEqual, status).
+//	    WhereIf(minBalance > 0, "balance", sietch.OpGreaterThanOrEqual, minBalance).
+//	    Build()
+func (fb *FilterBuilder) WhereIf(cond bool, field string, op ComparisonOperator, value any) *FilterBuilder {
+	if !cond {
+		return fb
+	}
+	return fb.Where(field, op, value)
+}
+
+// WhereJSONContains adds a condition matching rows whose JSONB field contains the given document
+func (fb *FilterBuilder) WhereJSONContains(field string, document any) *FilterBuilder {
+	return fb.Where(field, OpJSONContains, document)
+}
+
+// WhereJSONPathExists adds a condition matching rows whose JSONB field has a value at path
+func (fb *FilterBuilder) WhereJSONPathExists(field string, path ...string) *FilterBuilder {
+	return fb.Where(field, OpJSONPathExists, path)
+}
+
+// WhereJSONGet adds a condition comparing the value at path inside a JSONB field
+func (fb *FilterBuilder) WhereJSONGet(field string, path []string, op ComparisonOperator, value any) *FilterBuilder {
+	return fb.Where(field, OpJSONGet, JSONPath{Path: path, Operator: op, Value: value})
+}
+
+// WhereArrayContains adds a condition matching rows whose array field contains all given elements
+func (fb *FilterBuilder) WhereArrayContains(field string, elements any) *FilterBuilder {
+	return fb.Where(field, OpArrayContains, elements)
+}
+
+// WhereArrayOverlaps adds a condition matching rows whose array field shares any given element
+func (fb *FilterBuilder) WhereArrayOverlaps(field string, elements any) *FilterBuilder {
+	return fb.Where(field, OpArrayOverlaps, elements)
+}
+
+// WhereFullText adds a full-text search condition on a text field
+func (fb *FilterBuilder) WhereFullText(field string, query string) *FilterBuilder {
+	return fb.Where(field, OpFullText, query)
+}
+
+// WhereTimeBetween adds a condition matching rows whose timestamp field is within [from, to]
+func (fb *FilterBuilder) WhereTimeBetween(field string, from, to time.Time) *FilterBuilder {
+	return fb.Where(field, OpBetween, []any{from, to})
+}
+
+// WhereOlderThan adds a condition matching rows whose timestamp field is more
+// than d before the time the query runs
+func (fb *FilterBuilder) WhereOlderThan(field string, d time.Duration) *FilterBuilder {
+	return fb.Where(field, OpLessThan, Ago(d))
+}
+
+// WhereNewerThan adds a condition matching rows whose timestamp field is less
+// than d before the time the query runs
+func (fb *FilterBuilder) WhereNewerThan(field string, d time.Duration) *FilterBuilder {
+	return fb.Where(field, OpGreaterThan, Ago(d))
+}
+
+// SubquerySource is a repository whose rows WhereInSubquery can reference.
+// CockroachDBConnector and InMemoryConnector implement it.
+type SubquerySource interface {
+	// subqueryTable returns the table name and column validator used to
+	// generate SQL; ok is false for sources without a SQL table
+	subqueryTable() (table string, validate func(string) error, ok bool)
+	// subqueryValues returns field of every row matching filter
+	subqueryValues(ctx context.Context, filter *Filter, field string) ([]any, error)
+}
+
+// InSubquery is the value of an OpInSubquery condition. It matches when the
+// condition field equals Field of a row of Source matching Filter.
+type InSubquery struct {
+	Source SubquerySource
+	Filter *Filter // Only conditions are supported; may be nil
+	Field  string
+}
+
+// InSubqueryOf returns a condition matching rows whose field equals
+// otherField of a row of other matching otherFilter
+func InSubqueryOf(field string, other SubquerySource, otherFilter *Filter, otherField string) Condition {
+	return Condition{
+		Field:    field,
+		Operator: OpInSubquery,
+		Value:    InSubquery{Source: other, Filter: otherFilter, Field: otherField},
+	}
+}
+
+// WhereInSubquery adds a condition matching rows whose field equals otherField
+// of a row of other matching otherFilter, generated as
+// field IN (SELECT otherField FROM ... WHERE ...). The in-memory connector
+// queries other instead, so other may be any SubquerySource.
+func (fb *FilterBuilder) WhereInSubquery(field string, other SubquerySource, otherFilter *Filter, otherField string) *FilterBuilder {
+	fb.conditions = append(fb.conditions, InSubqueryOf(field, other, otherFilter, otherField))
+	return fb
+}
+
+// WhereExists adds a condition matching rows for which table has at least one
+// row matching sub and the correlation
+func (fb *FilterBuilder) WhereExists(sub *Filter, table string, correlation ...Correlation) *FilterBuilder {
+	fb.conditions = append(fb.conditions, Exists(sub, table, correlation...))
+	return fb
+}
+
 // Or adds an OR condition grouping multiple conditions
 // All conditions within the OR group will be combined with OR logic
 func (fb *FilterBuilder) Or(conditions ...Condition) *FilterBuilder {
@@ -139,6 +385,30 @@ func (fb *FilterBuilder) Not(condition Condition) *FilterBuilder {
 	return fb
 }
 
+// OrIf adds an OR group only when cond is true
+func (fb *FilterBuilder) OrIf(cond bool, conditions ...Condition) *FilterBuilder {
+	if !cond {
+		return fb
+	}
+	return fb.Or(conditions...)
+}
+
+// AndIf adds an AND group only when cond is true
+func (fb *FilterBuilder) AndIf(cond bool, conditions ...Condition) *FilterBuilder {
+	if !cond {
+		return fb
+	}
+	return fb.And(conditions...)
+}
+
+// NotIf adds a negated condition only when cond is true
+func (fb *FilterBuilder) NotIf(cond bool, condition Condition) *FilterBuilder {
+	if !cond {
+		return fb
+	}
+	return fb.Not(condition)
+}
+
 // Group creates a logical grouping of conditions with the specified operator
 func (fb *FilterBuilder) Group(op LogicalOperator, conditions ...Condition) *FilterBuilder {
 	if len(conditions) == 0 {
@@ -160,6 +430,37 @@ func (fb *FilterBuilder) OrderBy(field string, direction SortDirection) *FilterB
 	return fb
 }
 
+// OrderByIf adds a sort field only when cond is true
+func (fb *FilterBuilder) OrderByIf(cond bool, field string, direction SortDirection) *FilterBuilder {
+	if !cond {
+		return fb
+	}
+	return fb.OrderBy(field, direction)
+}
+
+// OrderByNulls adds a sort field with an explicit placement for NULL values,
+// e.g. OrderByNulls("score", SortDesc, NullsLast) ranks unscored rows last
+func (fb *FilterBuilder) OrderByNulls(field string, direction SortDirection, nulls NullsOrder) *FilterBuilder {
+	fb.sort = append(fb.sort, SortField{
+		Field:     field,
+		Direction: direction,
+		Nulls:     nulls,
+	})
+	return fb
+}
+
+// OrderRandom sorts results in random order (ORDER BY random()). Fields
+// sorted before it take precedence; rows that tie on them are shuffled.
+func (fb *FilterBuilder) OrderRandom() *FilterBuilder {
+	return fb.OrderBy(RandomField, SortAsc)
+}
+
+// Sample returns up to n rows chosen uniformly at random among those matching
+// the filter. It is shorthand for OrderRandom().Limit(n).
+func (fb *FilterBuilder) Sample(n int) *FilterBuilder {
+	return fb.OrderRandom().Limit(n)
+}
+
 // Limit sets the maximum number of results to return
 func (fb *FilterBuilder) Limit(n int) *FilterBuilder {
 	fb.limit = &n
@@ -178,6 +479,29 @@ func (fb *FilterBuilder) Distinct() *FilterBuilder {
 	return fb
 }
 
+// GroupBy groups results by the given fields.
+// Query returns one item per group with only the grouped fields set;
+// use GroupCount (see Grouper) to also get the number of rows per group.
+func (fb *FilterBuilder) GroupBy(fields ...string) *FilterBuilder {
+	fb.groupBy = append(fb.groupBy, fields...)
+	return fb
+}
+
+// Having adds conditions on groups. Conditions may target grouped fields
+// or CountField, e.g. Condition{Field: CountField, Operator: OpGreaterThan, Value: 10}
+func (fb *FilterBuilder) Having(conditions ...Condition) *FilterBuilder {
+	fb.having = append(fb.having, conditions...)
+	return fb
+}
+
+// Lock locks the rows returned by the query until the end of the transaction
+// it runs in, e.g. Lock(LockForUpdate) for SELECT ... FOR UPDATE. It cannot
+// be combined with Distinct or GroupBy. The InMemory connector ignores it.
+func (fb *FilterBuilder) Lock(mode LockMode) *FilterBuilder {
+	fb.lock = mode
+	return fb
+}
+
 // Build creates the final Filter
 func (fb *FilterBuilder) Build() *Filter {
 	return &Filter{
@@ -186,5 +510,25 @@ func (fb *FilterBuilder) Build() *Filter {
 		Limit:      fb.limit,
 		Offset:     fb.offset,
 		Distinct:   fb.distinct,
+		GroupBy:    fb.groupBy,
+		Having:     fb.having,
+		Lock:       fb.lock,
+	}
+}
+
+// validateBulkFilter checks that a filter can drive UpdateWhere/DeleteWhere.
+// At least one condition is required so a missing filter cannot mutate every row;
+// pagination, sorting and grouping have no meaning for bulk mutations.
+func validateBulkFilter(filter *Filter) error {
+	if filter == nil {
+		return fmt.Errorf("filter cannot be nil")
+	}
+	if len(filter.Conditions) == 0 {
+		return fmt.Errorf("%w: bulk mutations require at least one condition", ErrInvalidFilter)
+	}
+	if filter.Limit != nil || filter.Offset != nil || len(filter.Sort) > 0 ||
+		len(filter.GroupBy) > 0 || len(filter.Having) > 0 || filter.Distinct {
+		return fmt.Errorf("%w: bulk mutations only support conditions", ErrInvalidFilter)
 	}
+	return nil
 }
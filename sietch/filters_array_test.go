@@ -0,0 +1,94 @@
+package sietch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type taggedItem struct {
+	ID     int64    `db:"id"`
+	Tags   []string `db:"tags"`
+	Scores []int    `db:"scores"`
+}
+
+func TestCockroachDBQueryBuilder_ArrayOperators(t *testing.T) {
+	conn, err := NewCockroachDBConnector[taggedItem, int64](
+		&pgxpool.Pool{},
+		"items",
+		func(i *taggedItem) int64 { return i.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		filter        *Filter
+		expectedWhere string
+	}{
+		{"contains", NewFilter().WhereArrayContains("tags", []string{"go", "sql"}).Build(), `"tags" @> $1`},
+		{"contained by", NewFilter().Where("tags", OpArrayContainedBy, []string{"go", "sql"}).Build(), `"tags" <@ $1`},
+		{"overlaps", NewFilter().WhereArrayOverlaps("scores", []int{1, 2}).Build(), `"scores" && $1`},
+		{"any", NewFilter().Where("tags", OpArrayAny, "go").Build(), `$1 = ANY("tags")`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := conn.queryBuilder(tt.filter)
+			if err != nil {
+				t.Fatalf("queryBuilder failed: %v", err)
+			}
+			expected := `SELECT "id", "tags", "scores" FROM "items" WHERE ` + tt.expectedWhere
+			if query != expected {
+				t.Errorf("Expected: %s\nGot: %s", expected, query)
+			}
+			if len(args) != 1 {
+				t.Errorf("Expected 1 arg, got %d", len(args))
+			}
+		})
+	}
+
+	t.Run("Non-slice value", func(t *testing.T) {
+		if _, _, err := conn.queryBuilder(NewFilter().WhereArrayContains("tags", "go").Build()); err == nil {
+			t.Error("Expected error for non-slice value")
+		}
+	})
+}
+
+func TestInMemoryArrayOperators(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[taggedItem, int64](func(i *taggedItem) int64 { return i.ID })
+	repo.BatchCreate(ctx, []taggedItem{
+		{ID: 1, Tags: []string{"go", "sql", "redis"}, Scores: []int{1, 2}},
+		{ID: 2, Tags: []string{"go"}, Scores: []int{3}},
+		{ID: 3, Tags: []string{"rust"}, Scores: nil},
+	})
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected int
+	}{
+		{"contains", NewFilter().WhereArrayContains("tags", []string{"go", "sql"}).Build(), 1},
+		{"contains single", NewFilter().WhereArrayContains("tags", []string{"go"}).Build(), 2},
+		{"contained by", NewFilter().Where("tags", OpArrayContainedBy, []string{"go", "rust"}).Build(), 2},
+		{"overlaps", NewFilter().WhereArrayOverlaps("tags", []string{"redis", "rust"}).Build(), 2},
+		{"overlaps numeric types", NewFilter().WhereArrayOverlaps("scores", []int64{3}).Build(), 1},
+		{"any", NewFilter().Where("tags", OpArrayAny, "redis").Build(), 1},
+		{"none", NewFilter().WhereArrayOverlaps("tags", []string{"java"}).Build(), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.Query(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if len(results) != tt.expected {
+				t.Errorf("Expected %d results, got %d", tt.expected, len(results))
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package sietch
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type jsonDoc struct {
+	ID       int64           `db:"id"`
+	Metadata json.RawMessage `db:"metadata"`
+}
+
+func TestCockroachDBQueryBuilder_JSONOperators(t *testing.T) {
+	conn, err := NewCockroachDBConnector[jsonDoc, int64](
+		&pgxpool.Pool{},
+		"docs",
+		func(d *jsonDoc) int64 { return d.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		filter        *Filter
+		expectedQuery string
+		expectedArgs  []any
+	}{
+		{
+			name:          "JSON contains",
+			filter:        NewFilter().WhereJSONContains("metadata", map[string]any{"tier": "gold"}).Build(),
+			expectedQuery: `SELECT "id", "metadata" FROM "docs" WHERE "metadata" @> $1::JSONB`,
+			expectedArgs:  []any{`{"tier":"gold"}`},
+		},
+		{
+			name:          "JSON path exists",
+			filter:        NewFilter().WhereJSONPathExists("metadata", "address", "city").Build(),
+			expectedQuery: `SELECT "id", "metadata" FROM "docs" WHERE ("metadata" #> $1) IS NOT NULL`,
+		},
+		{
+			name:          "JSON get single key",
+			filter:        NewFilter().WhereJSONGet("metadata", []string{"tier"}, OpEqual, "gold").Build(),
+			expectedQuery: `SELECT "id", "metadata" FROM "docs" WHERE ("metadata" ->> $1) = $2`,
+			expectedArgs:  []any{"tier", "gold"},
+		},
+		{
+			name: "JSON get nested numeric",
+			filter: NewFilter().
+				Where("id", OpGreaterThan, int64(1)).
+				WhereJSONGet("metadata", []string{"stats", "score"}, OpGreaterThanOrEqual, 10).
+				Build(),
+			expectedQuery: `SELECT "id", "metadata" FROM "docs" WHERE "id" > $1 AND ("metadata" #>> $2)::NUMERIC >= $3`,
+		},
+		{
+			name:          "JSON get boolean",
+			filter:        NewFilter().WhereJSONGet("metadata", []string{"verified"}, "", true).Build(),
+			expectedQuery: `SELECT "id", "metadata" FROM "docs" WHERE ("metadata" ->> $1)::BOOLEAN = $2`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := conn.queryBuilder(tt.filter)
+			if err != nil {
+				t.Fatalf("queryBuilder failed: %v", err)
+			}
+			if query != tt.expectedQuery {
+				t.Errorf("Expected: %s\nGot: %s", tt.expectedQuery, query)
+			}
+			for i, expected := range tt.expectedArgs {
+				if args[i] != expected {
+					t.Errorf("Expected arg[%d] %v, got %v", i, expected, args[i])
+				}
+			}
+		})
+	}
+
+	t.Run("Invalid values", func(t *testing.T) {
+		invalid := []*Filter{
+			NewFilter().Where("metadata", OpJSONPathExists, 5).Build(),
+			NewFilter().Where("metadata", OpJSONPathExists, []string{}).Build(),
+			NewFilter().Where("metadata", OpJSONGet, "tier").Build(),
+			NewFilter().WhereJSONGet("metadata", nil, OpEqual, "x").Build(),
+			NewFilter().WhereJSONGet("metadata", []string{"a"}, OpIn, "x").Build(),
+			NewFilter().WhereJSONContains("metadata", make(chan int)).Build(),
+		}
+		for i, f := range invalid {
+			if _, _, err := conn.queryBuilder(f); err == nil {
+				t.Errorf("Expected error for invalid filter %d", i)
+			}
+		}
+	})
+}
+
+type profile struct {
+	ID       int64          `db:"id"`
+	Metadata map[string]any `db:"metadata"`
+	Raw      string         `db:"raw"`
+}
+
+func TestInMemoryJSONOperators(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[profile, int64](func(p *profile) int64 { return p.ID })
+	repo.BatchCreate(ctx, []profile{
+		{ID: 1, Metadata: map[string]any{"tier": "gold", "tags": []any{"a", "b"}, "stats": map[string]any{"score": 12}}, Raw: `{"active":true}`},
+		{ID: 2, Metadata: map[string]any{"tier": "silver", "tags": []any{"b"}, "stats": map[string]any{"score": 5}}, Raw: `{"active":false}`},
+		{ID: 3, Metadata: map[string]any{"tier": "gold"}, Raw: `not json`},
+	})
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected int
+	}{
+		{"contains object", NewFilter().WhereJSONContains("metadata", map[string]any{"tier": "gold"}).Build(), 2},
+		{"contains nested array", NewFilter().WhereJSONContains("metadata", map[string]any{"tags": []string{"a"}}).Build(), 1},
+		{"contains on raw string", NewFilter().WhereJSONContains("raw", map[string]any{"active": true}).Build(), 1},
+		{"path exists", NewFilter().WhereJSONPathExists("metadata", "stats", "score").Build(), 2},
+		{"path missing", NewFilter().WhereJSONPathExists("metadata", "stats", "missing").Build(), 0},
+		{"get equal", NewFilter().WhereJSONGet("metadata", []string{"tier"}, OpEqual, "silver").Build(), 1},
+		{"get numeric", NewFilter().WhereJSONGet("metadata", []string{"stats", "score"}, OpGreaterThan, 10).Build(), 1},
+		{"get array index", NewFilter().WhereJSONGet("metadata", []string{"tags", "0"}, OpEqual, "b").Build(), 1},
+		{"get like", NewFilter().WhereJSONGet("metadata", []string{"tier"}, OpLike, "g%").Build(), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.Query(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if len(results) != tt.expected {
+				t.Errorf("Expected %d results, got %d", tt.expected, len(results))
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package sietch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type article struct {
+	ID    int64  `db:"id"`
+	Title string `db:"title"`
+	Body  string `db:"body"`
+}
+
+func TestCockroachDBQueryBuilder_FullText(t *testing.T) {
+	conn, err := NewCockroachDBConnector[article, int64](
+		&pgxpool.Pool{},
+		"articles",
+		func(a *article) int64 { return a.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	t.Run("Default configuration", func(t *testing.T) {
+		query, args, err := conn.queryBuilder(NewFilter().WhereFullText("body", "distributed database").Build())
+		if err != nil {
+			t.Fatalf("queryBuilder failed: %v", err)
+		}
+		expected := `SELECT "id", "title", "body" FROM "articles" WHERE to_tsvector($1::REGCONFIG, "body") @@ plainto_tsquery($1::REGCONFIG, $2)`
+		if query != expected {
+			t.Errorf("Expected: %s\nGot: %s", expected, query)
+		}
+		if len(args) != 2 || args[0] != "simple" || args[1] != "distributed database" {
+			t.Errorf("Unexpected args: %v", args)
+		}
+	})
+
+	t.Run("Custom language keeps placeholder numbering", func(t *testing.T) {
+		filter := NewFilter().
+			Where("body", OpFullText, FullTextQuery{Query: "running", Language: "english"}).
+			Where("id", OpGreaterThan, int64(10)).
+			Build()
+		query, args, err := conn.queryBuilder(filter)
+		if err != nil {
+			t.Fatalf("queryBuilder failed: %v", err)
+		}
+		expected := `SELECT "id", "title", "body" FROM "articles" WHERE to_tsvector($1::REGCONFIG, "body") @@ plainto_tsquery($1::REGCONFIG, $2) AND "id" > $3`
+		if query != expected {
+			t.Errorf("Expected: %s\nGot: %s", expected, query)
+		}
+		if args[0] != "english" {
+			t.Errorf("Expected english configuration, got %v", args[0])
+		}
+	})
+
+	t.Run("Invalid values", func(t *testing.T) {
+		for _, v := range []any{"", "   ", 42, FullTextQuery{}} {
+			if _, _, err := conn.queryBuilder(NewFilter().Where("body", OpFullText, v).Build()); err == nil {
+				t.Errorf("Expected error for value %v", v)
+			}
+		}
+	})
+}
+
+func TestInMemoryFullText(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[article, int64](func(a *article) int64 { return a.ID })
+	repo.BatchCreate(ctx, []article{
+		{ID: 1, Title: "Scaling", Body: "Distributed SQL databases scale horizontally."},
+		{ID: 2, Title: "Caching", Body: "A cache in front of the database reduces load."},
+		{ID: 3, Title: "Queues", Body: "Message queues decouple distributed services."},
+	})
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{"distributed", 2},
+		{"DISTRIBUTED sql", 1},
+		{"database", 1},
+		{"cache, database!", 1},
+		{"graph", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := repo.Query(ctx, NewFilter().WhereFullText("body", tt.query).Build())
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if len(results) != tt.expected {
+				t.Errorf("Expected %d results, got %d", tt.expected, len(results))
+			}
+		})
+	}
+}
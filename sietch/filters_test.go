@@ -150,6 +150,8 @@ func TestComparisonOperators(t *testing.T) {
 		{"OpNotIn", OpNotIn, "NOT IN"},
 		{"OpLike", OpLike, "LIKE"},
 		{"OpILike", OpILike, "ILIKE"},
+		{"OpRegex", OpRegex, "~"},
+		{"OpIRegex", OpIRegex, "~*"},
 		{"OpIsNull", OpIsNull, "IS NULL"},
 		{"OpIsNotNull", OpIsNotNull, "IS NOT NULL"},
 		{"OpBetween", OpBetween, "BETWEEN"},
@@ -266,3 +268,53 @@ func TestSortField(t *testing.T) {
 		}
 	})
 }
+
+func TestFilterBuilder_Conditional(t *testing.T) {
+	or := []Condition{
+		{Field: "status", Operator: OpEqual, Value: "active"},
+		{Field: "status", Operator: OpEqual, Value: "pending"},
+	}
+	not := Condition{Field: "deleted", Operator: OpEqual, Value: true}
+
+	t.Run("True conditions are added", func(t *testing.T) {
+		filter := NewFilter().
+			WhereIf(true, "balance", OpGreaterThan, 100).
+			OrIf(true, or...).
+			AndIf(true, or...).
+			NotIf(true, not).
+			OrderByIf(true, "balance", SortDesc).
+			Build()
+
+		if len(filter.Conditions) != 4 {
+			t.Fatalf("Expected 4 conditions, got %d", len(filter.Conditions))
+		}
+		if filter.Conditions[0].Field != "balance" {
+			t.Errorf("Expected field 'balance', got '%s'", filter.Conditions[0].Field)
+		}
+		ops := []LogicalOperator{filter.Conditions[1].LogicalOp, filter.Conditions[2].LogicalOp, filter.Conditions[3].LogicalOp}
+		if ops[0] != LogicalOR || ops[1] != LogicalAND || ops[2] != LogicalNOT {
+			t.Errorf("Unexpected logical operators %v", ops)
+		}
+		if len(filter.Sort) != 1 || filter.Sort[0].Field != "balance" {
+			t.Errorf("Expected sort on 'balance', got %v", filter.Sort)
+		}
+	})
+
+	t.Run("False conditions are skipped", func(t *testing.T) {
+		filter := NewFilter().
+			Where("status", OpEqual, "active").
+			WhereIf(false, "balance", OpGreaterThan, 100).
+			OrIf(false, or...).
+			AndIf(false, or...).
+			NotIf(false, not).
+			OrderByIf(false, "balance", SortDesc).
+			Build()
+
+		if len(filter.Conditions) != 1 || filter.Conditions[0].Field != "status" {
+			t.Errorf("Expected only the unconditional condition, got %v", filter.Conditions)
+		}
+		if len(filter.Sort) != 0 {
+			t.Errorf("Expected no sort fields, got %v", filter.Sort)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package sietch
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type timedItem struct {
+	ID        int64      `db:"id"`
+	CreatedAt time.Time  `db:"created_at"`
+	DeletedAt *time.Time `db:"deleted_at"`
+}
+
+func TestCockroachDBQueryBuilder_TimeHelpers(t *testing.T) {
+	conn, err := NewCockroachDBConnector[timedItem, int64](
+		&pgxpool.Pool{},
+		"items",
+		func(i *timedItem) int64 { return i.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		filter        *Filter
+		expectedWhere string
+	}{
+		{"between", NewFilter().WhereTimeBetween("created_at", from, to).Build(), `"created_at" BETWEEN $1 AND $2`},
+		{"older than", NewFilter().WhereOlderThan("created_at", time.Hour).Build(), `"created_at" < $1`},
+		{"newer than", NewFilter().WhereNewerThan("created_at", time.Hour).Build(), `"created_at" > $1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _, err := conn.queryBuilder(tt.filter)
+			if err != nil {
+				t.Fatalf("queryBuilder failed: %v", err)
+			}
+			expected := `SELECT "id", "created_at", "deleted_at" FROM "items" WHERE ` + tt.expectedWhere
+			if query != expected {
+				t.Errorf("Expected: %s\nGot: %s", expected, query)
+			}
+		})
+	}
+
+	t.Run("Relative time is resolved when encoded", func(t *testing.T) {
+		_, args, err := conn.queryBuilder(NewFilter().WhereOlderThan("created_at", time.Hour).Build())
+		if err != nil {
+			t.Fatalf("queryBuilder failed: %v", err)
+		}
+		valuer, ok := args[0].(driver.Valuer)
+		if !ok {
+			t.Fatalf("Expected a driver.Valuer argument, got %T", args[0])
+		}
+		v, _ := valuer.Value()
+		resolved, ok := v.(time.Time)
+		if !ok {
+			t.Fatalf("Expected time.Time value, got %T", v)
+		}
+		if diff := time.Until(resolved) + time.Hour; diff < -time.Second || diff > time.Second {
+			t.Errorf("Expected about one hour ago, got %v", resolved)
+		}
+	})
+}
+
+func TestInMemoryTimeConditions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	deleted := now.Add(-2 * time.Hour)
+
+	repo := NewInMemoryConnector[timedItem, int64](func(i *timedItem) int64 { return i.ID })
+	repo.BatchCreate(ctx, []timedItem{
+		{ID: 1, CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: 2, CreatedAt: now.Add(-3 * time.Hour), DeletedAt: &deleted},
+		{ID: 3, CreatedAt: now.Add(-10 * time.Minute).In(time.FixedZone("UTC-5", -5*3600))},
+	})
+
+	tests := []struct {
+		name     string
+		filter   *FilterBuilder
+		expected []int64
+	}{
+		{"older than", NewFilter().WhereOlderThan("created_at", 24*time.Hour), []int64{1}},
+		{"newer than", NewFilter().WhereNewerThan("created_at", time.Hour), []int64{3}},
+		{"between", NewFilter().WhereTimeBetween("created_at", now.Add(-4*time.Hour), now.Add(-time.Hour)), []int64{2}},
+		{"relative between", NewFilter().Where("created_at", OpBetween, []any{Ago(4 * time.Hour), FromNow(time.Hour)}), []int64{2, 3}},
+		{"absolute comparison", NewFilter().Where("created_at", OpLessThan, now.Add(-time.Hour).UTC()), []int64{1, 2}},
+		{"RFC 3339 string", NewFilter().Where("created_at", OpGreaterThan, now.Add(-time.Hour).Format(time.RFC3339Nano)), []int64{3}},
+		{"pointer field", NewFilter().WhereOlderThan("deleted_at", time.Hour), []int64{2}},
+		{"nil pointer field", NewFilter().WhereNewerThan("deleted_at", 24*time.Hour), []int64{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.Query(ctx, tt.filter.OrderBy("id", SortAsc).Build())
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			var ids []int64
+			for _, r := range results {
+				ids = append(ids, r.ID)
+			}
+			if len(ids) != len(tt.expected) {
+				t.Fatalf("Expected IDs %v, got %v", tt.expected, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.expected[i] {
+					t.Fatalf("Expected IDs %v, got %v", tt.expected, ids)
+				}
+			}
+		})
+	}
+}
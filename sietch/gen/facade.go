@@ -0,0 +1,335 @@
+// Package gen generates typed repository facades with domain-specific finder methods.
+//
+// A facade embeds sietch.Repository[T, ID] and adds one method per named filter,
+// e.g. FindByEmail(ctx, email string) or ListActiveSince(ctx, since time.Time).
+// Filter shapes are validated when the code is generated, so services call
+// compile-time checked methods instead of building stringly-typed filters.
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// FacadeSpec describes the facade to generate for one entity
+type FacadeSpec struct {
+	// Package is the Go package name of the generated file
+	Package string `json:"package"`
+
+	// Entity is the entity type name, as referenced from Package (e.g. "Account" or "models.Account")
+	Entity string `json:"entity"`
+
+	// IDType is the repository ID type (e.g. "int64")
+	IDType string `json:"id_type"`
+
+	// Name is the facade type name. Default: Entity + "Repository"
+	Name string `json:"name,omitempty"`
+
+	// Imports lists extra import paths needed by parameter or entity types
+	Imports []string `json:"imports,omitempty"`
+
+	// Columns optionally lists the entity's db columns; when set, every
+	// condition and sort field is checked against it
+	Columns []string `json:"columns,omitempty"`
+
+	// Finders are the named filters to generate methods for
+	Finders []FinderSpec `json:"finders"`
+}
+
+// FinderSpec describes one generated finder method
+type FinderSpec struct {
+	// Name is the method name (e.g. "FindByEmail")
+	Name string `json:"name"`
+
+	// Doc is an optional doc comment body for the method
+	Doc string `json:"doc,omitempty"`
+
+	// Single when true returns *T (first match or sietch.ErrItemNotFound) instead of []T
+	Single bool `json:"single,omitempty"`
+
+	// Params are the typed method parameters, in order, after ctx
+	Params []ParamSpec `json:"params,omitempty"`
+
+	// Conditions are ANDed together to build the filter
+	Conditions []ConditionSpec `json:"conditions"`
+
+	// OrderBy lists sort fields applied in order
+	OrderBy []SortSpec `json:"order_by,omitempty"`
+
+	// Limit caps the number of results (ignored for Single finders). 0 means no limit.
+	Limit int `json:"limit,omitempty"`
+}
+
+// ParamSpec is a typed method parameter
+type ParamSpec struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ConditionSpec is a single field comparison in a finder
+type ConditionSpec struct {
+	// Field is the db column name
+	Field string `json:"field"`
+
+	// Operator is the sietch operator constant name (e.g. "OpEqual")
+	Operator string `json:"operator"`
+
+	// Param names the method parameter bound to this condition
+	Param string `json:"param,omitempty"`
+
+	// Value is a Go expression used instead of a parameter (e.g. `"active"`)
+	Value string `json:"value,omitempty"`
+}
+
+// SortSpec is a sort field in a finder
+type SortSpec struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc,omitempty"`
+}
+
+// operatorArity lists the operator constants a finder may use and whether they take a value
+var operatorArity = map[string]bool{
+	"OpEqual":              true,
+	"OpNotEqual":           true,
+	"OpGreaterThan":        true,
+	"OpLessThan":           true,
+	"OpGreaterThanOrEqual": true,
+	"OpLessThanOrEqual":    true,
+	"OpIn":                 true,
+	"OpNotIn":              true,
+	"OpLike":               true,
+	"OpILike":              true,
+	"OpRegex":              true,
+	"OpIRegex":             true,
+	"OpIsNull":             false,
+	"OpIsNotNull":          false,
+	"OpBetween":            true,
+	"OpJSONContains":       true,
+	"OpJSONPathExists":     true,
+	"OpJSONGet":            true,
+	"OpFullText":           true,
+	"OpArrayContains":      true,
+	"OpArrayContainedBy":   true,
+	"OpArrayOverlaps":      true,
+	"OpArrayAny":           true,
+}
+
+// LoadSpec reads a FacadeSpec from a JSON file
+func LoadSpec(path string) (*FacadeSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec FacadeSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec %s: %w", path, err)
+	}
+	return &spec, nil
+}
+
+// Validate checks the spec for structural errors before generation
+func (s *FacadeSpec) Validate() error {
+	if !isIdent(s.Package) {
+		return fmt.Errorf("invalid package name '%s'", s.Package)
+	}
+	if s.Entity == "" {
+		return fmt.Errorf("entity cannot be empty")
+	}
+	if s.IDType == "" {
+		return fmt.Errorf("id type cannot be empty")
+	}
+	if s.Name != "" && !isIdent(s.Name) {
+		return fmt.Errorf("invalid facade name '%s'", s.Name)
+	}
+	if len(s.Finders) == 0 {
+		return fmt.Errorf("at least one finder is required")
+	}
+
+	columns := make(map[string]bool, len(s.Columns))
+	for _, col := range s.Columns {
+		columns[col] = true
+	}
+	checkField := func(finder, field string) error {
+		if !isColumnName(field) {
+			return fmt.Errorf("finder %s: invalid field '%s'", finder, field)
+		}
+		if len(columns) > 0 && !columns[field] {
+			return fmt.Errorf("finder %s: unknown field '%s'", finder, field)
+		}
+		return nil
+	}
+
+	names := make(map[string]bool)
+	for _, f := range s.Finders {
+		if !isIdent(f.Name) || !token.IsExported(f.Name) {
+			return fmt.Errorf("invalid finder name '%s': must be an exported identifier", f.Name)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("duplicate finder '%s'", f.Name)
+		}
+		names[f.Name] = true
+
+		params := make(map[string]bool)
+		for _, p := range f.Params {
+			if !isIdent(p.Name) || p.Name == "ctx" || p.Name == "filter" || p.Name == "r" {
+				return fmt.Errorf("finder %s: invalid parameter name '%s'", f.Name, p.Name)
+			}
+			if params[p.Name] {
+				return fmt.Errorf("finder %s: duplicate parameter '%s'", f.Name, p.Name)
+			}
+			if _, err := parser.ParseExpr(p.Type); err != nil {
+				return fmt.Errorf("finder %s: invalid type '%s' for parameter '%s'", f.Name, p.Type, p.Name)
+			}
+			params[p.Name] = true
+		}
+
+		if len(f.Conditions) == 0 {
+			return fmt.Errorf("finder %s: at least one condition is required", f.Name)
+		}
+		used := make(map[string]bool)
+		for _, c := range f.Conditions {
+			if err := checkField(f.Name, c.Field); err != nil {
+				return err
+			}
+			takesValue, ok := operatorArity[c.Operator]
+			if !ok {
+				return fmt.Errorf("finder %s: unknown operator '%s'", f.Name, c.Operator)
+			}
+			switch {
+			case !takesValue && (c.Param != "" || c.Value != ""):
+				return fmt.Errorf("finder %s: operator %s does not take a value", f.Name, c.Operator)
+			case takesValue && c.Param == "" && c.Value == "":
+				return fmt.Errorf("finder %s: operator %s requires a param or value", f.Name, c.Operator)
+			case c.Param != "" && c.Value != "":
+				return fmt.Errorf("finder %s: condition on '%s' sets both param and value", f.Name, c.Field)
+			case c.Param != "" && !params[c.Param]:
+				return fmt.Errorf("finder %s: unknown parameter '%s'", f.Name, c.Param)
+			}
+			if c.Value != "" {
+				if _, err := parser.ParseExpr(c.Value); err != nil {
+					return fmt.Errorf("finder %s: invalid value expression '%s'", f.Name, c.Value)
+				}
+			}
+			used[c.Param] = true
+		}
+		for _, p := range f.Params {
+			if !used[p.Name] {
+				return fmt.Errorf("finder %s: parameter '%s' is not used by any condition", f.Name, p.Name)
+			}
+		}
+
+		for _, sf := range f.OrderBy {
+			if err := checkField(f.Name, sf.Field); err != nil {
+				return err
+			}
+		}
+		if f.Limit < 0 {
+			return fmt.Errorf("finder %s: limit cannot be negative", f.Name)
+		}
+	}
+
+	return nil
+}
+
+// Generate validates the spec and returns the gofmt-ed facade source
+func Generate(spec *FacadeSpec) ([]byte, error) {
+	if spec == nil {
+		return nil, fmt.Errorf("spec cannot be nil")
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	data := *spec
+	if data.Name == "" {
+		data.Name = strings.TrimPrefix(data.Entity[strings.LastIndex(data.Entity, ".")+1:], "*") + "Repository"
+	}
+
+	var buf bytes.Buffer
+	if err := facadeTemplate.Execute(&buf, &data); err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not compile: %w", err)
+	}
+	return src, nil
+}
+
+func isIdent(s string) bool {
+	return token.IsIdentifier(s)
+}
+
+func isColumnName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+			(r >= '0' && r <= '9') || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+var facadeTemplate = template.Must(template.New("facade").Parse(`// Code generated by sietchgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+
+	"github.com/seb7887/gofw/sietch"
+)
+
+// {{.Name}} is a typed facade over sietch.Repository[{{.Entity}}, {{.IDType}}]
+type {{.Name}} struct {
+	sietch.Repository[{{.Entity}}, {{.IDType}}]
+}
+
+// New{{.Name}} wraps repo with domain-specific finder methods
+func New{{.Name}}(repo sietch.Repository[{{.Entity}}, {{.IDType}}]) *{{.Name}} {
+	return &{{.Name}}{Repository: repo}
+}
+{{range $f := .Finders}}
+// {{$f.Name}}{{if $f.Doc}} {{$f.Doc}}{{else}} runs a pre-validated filter{{end}}
+func (r *{{$.Name}}) {{$f.Name}}(ctx context.Context{{range $f.Params}}, {{.Name}} {{.Type}}{{end}}) ({{if $f.Single}}*{{$.Entity}}{{else}}[]{{$.Entity}}{{end}}, error) {
+	filter := sietch.NewFilter().
+{{- range $f.Conditions}}
+		Where("{{.Field}}", sietch.{{.Operator}}, {{if .Param}}{{.Param}}{{else if .Value}}{{.Value}}{{else}}nil{{end}}).
+{{- end}}
+{{- range $f.OrderBy}}
+		OrderBy("{{.Field}}", {{if .Desc}}sietch.SortDesc{{else}}sietch.SortAsc{{end}}).
+{{- end}}
+{{- if $f.Single}}
+		Limit(1).
+{{- else if $f.Limit}}
+		Limit({{$f.Limit}}).
+{{- end}}
+		Build()
+{{if $f.Single}}
+	results, err := r.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, sietch.ErrItemNotFound
+	}
+	return &results[0], nil
+{{- else}}
+	return r.Query(ctx, filter)
+{{- end}}
+}
+{{end}}`))
@@ -0,0 +1,106 @@
+package gen
+
+import (
+	"strings"
+	"testing"
+)
+
+func accountSpec() *FacadeSpec {
+	return &FacadeSpec{
+		Package: "accounts",
+		Entity:  "Account",
+		IDType:  "int64",
+		Imports: []string{"time"},
+		Columns: []string{"id", "email", "status", "created_at"},
+		Finders: []FinderSpec{
+			{
+				Name:   "FindByEmail",
+				Single: true,
+				Params: []ParamSpec{{Name: "email", Type: "string"}},
+				Conditions: []ConditionSpec{
+					{Field: "email", Operator: "OpEqual", Param: "email"},
+				},
+			},
+			{
+				Name:   "ListActiveSince",
+				Params: []ParamSpec{{Name: "since", Type: "time.Time"}},
+				Conditions: []ConditionSpec{
+					{Field: "status", Operator: "OpEqual", Value: `"active"`},
+					{Field: "created_at", Operator: "OpGreaterThanOrEqual", Param: "since"},
+				},
+				OrderBy: []SortSpec{{Field: "created_at", Desc: true}},
+				Limit:   50,
+			},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := Generate(accountSpec())
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	code := string(src)
+
+	expected := []string{
+		"// Code generated by sietchgen. DO NOT EDIT.",
+		"type AccountRepository struct {",
+		"sietch.Repository[Account, int64]",
+		"func NewAccountRepository(repo sietch.Repository[Account, int64]) *AccountRepository {",
+		"func (r *AccountRepository) FindByEmail(ctx context.Context, email string) (*Account, error) {",
+		`Where("email", sietch.OpEqual, email).`,
+		"return nil, sietch.ErrItemNotFound",
+		"func (r *AccountRepository) ListActiveSince(ctx context.Context, since time.Time) ([]Account, error) {",
+		`Where("status", sietch.OpEqual, "active").`,
+		`OrderBy("created_at", sietch.SortDesc).`,
+		"Limit(50).",
+		`"time"`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(code, e) {
+			t.Errorf("generated code missing %q:\n%s", e, code)
+		}
+	}
+}
+
+func TestGenerateValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(s *FacadeSpec)
+		errMsg string
+	}{
+		{"invalid package", func(s *FacadeSpec) { s.Package = "my-pkg" }, "invalid package name"},
+		{"no finders", func(s *FacadeSpec) { s.Finders = nil }, "at least one finder"},
+		{"unexported finder", func(s *FacadeSpec) { s.Finders[0].Name = "findByEmail" }, "exported identifier"},
+		{"duplicate finder", func(s *FacadeSpec) { s.Finders[1].Name = "FindByEmail" }, "duplicate finder"},
+		{"unknown field", func(s *FacadeSpec) { s.Finders[0].Conditions[0].Field = "mail" }, "unknown field"},
+		{"unknown operator", func(s *FacadeSpec) { s.Finders[0].Conditions[0].Operator = "OpFoo" }, "unknown operator"},
+		{"unknown param", func(s *FacadeSpec) { s.Finders[0].Conditions[0].Param = "mail" }, "unknown parameter"},
+		{"unused param", func(s *FacadeSpec) {
+			s.Finders[0].Params = append(s.Finders[0].Params, ParamSpec{Name: "extra", Type: "int"})
+		}, "not used"},
+		{"missing value", func(s *FacadeSpec) { s.Finders[0].Conditions[0].Param = "" }, "requires a param or value"},
+		{"null op with value", func(s *FacadeSpec) {
+			s.Finders[1].Conditions[0].Operator = "OpIsNull"
+		}, "does not take a value"},
+		{"bad type", func(s *FacadeSpec) { s.Finders[0].Params[0].Type = "map[" }, "invalid type"},
+		{"reserved param", func(s *FacadeSpec) {
+			s.Finders[0].Params[0].Name = "ctx"
+			s.Finders[0].Conditions[0].Param = "ctx"
+		}, "invalid parameter name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := accountSpec()
+			tt.mutate(spec)
+			_, err := Generate(spec)
+			if err == nil {
+				t.Fatal("Expected validation error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %q", tt.errMsg, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestCockroachDBConnector_getManyQuery(t *testing.T) {
+	conn := createTestConnector(t)
+
+	query, args, err := conn.getManyQuery([]int64{3, 1, 3, 2})
+	if err != nil {
+		t.Fatalf("getManyQuery failed: %v", err)
+	}
+	expected := `SELECT "id", "balance" FROM "test" WHERE "id" IN ($1, $2, $3)`
+	if query != expected {
+		t.Errorf("Expected: %s\nGot: %s", expected, query)
+	}
+	if len(args) != 3 || args[0] != int64(3) || args[1] != int64(1) || args[2] != int64(2) {
+		t.Errorf("Expected deduplicated args [3 1 2], got %v", args)
+	}
+}
+
+func TestInMemoryConnector_GetMany(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[testutils.Account, int64](func(a *testutils.Account) int64 { return a.ID })
+	_ = repo.BatchCreate(ctx, []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}})
+
+	got, err := repo.GetMany(ctx, []int64{1, 2, 3})
+	if err != nil {
+		t.Fatalf("GetMany failed: %v", err)
+	}
+	if len(got) != 2 || got[1].Balance != 10 || got[2].Balance != 20 {
+		t.Errorf("Unexpected GetMany result: %v", got)
+	}
+
+	empty, err := repo.GetMany(ctx, nil)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("Expected empty result for no IDs, got %v, %v", empty, err)
+	}
+}
+
+func TestCachedRepository_GetMany(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	base := NewInMemoryConnector[testutils.Account, int64](getID)
+	cache := NewInMemoryConnector[testutils.Account, int64](getID)
+	_ = base.BatchCreate(ctx, []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}})
+	_ = cache.Create(ctx, &testutils.Account{ID: 1, Balance: 99}) // stale, but served from cache
+
+	repo := NewCachedRepository[testutils.Account, int64](base, cache, time.Minute)
+	got, err := repo.GetMany(ctx, []int64{1, 2, 3})
+	if err != nil {
+		t.Fatalf("GetMany failed: %v", err)
+	}
+	if len(got) != 2 || got[1].Balance != 99 || got[2].Balance != 20 {
+		t.Errorf("Unexpected GetMany result: %v", got)
+	}
+
+	// Misses are written back to cache asynchronously
+	deadline := time.Now().Add(time.Second)
+	for {
+		if ok, _ := cache.Exists(ctx, 2); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cache was not populated with fetched items")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRedisConnector_decodeMany(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	for _, n := range []int{3, 1000} {
+		t.Run(fmt.Sprintf("%d values", n), func(t *testing.T) {
+			ids := make([]int64, n)
+			values := make([]any, n)
+			for i := range ids {
+				ids[i] = int64(i)
+				if i%3 != 0 {
+					values[i] = fmt.Sprintf(`{"ID":%d,"Balance":%d}`, i, i*10)
+				}
+			}
+
+			got, err := decodeMany[testutils.Account](ids, values)
+			if err != nil {
+				t.Fatalf("decodeMany failed: %v", err)
+			}
+			if len(got) != n-(n+2)/3 {
+				t.Errorf("Expected missing keys to be skipped, got %d items", len(got))
+			}
+			if _, ok := got[0]; ok {
+				t.Error("Expected id 0 to be missing")
+			}
+			if got[int64(n-2)].Balance != (n-2)*10 {
+				t.Errorf("Unexpected item %+v", got[int64(n-2)])
+			}
+
+			values[n-2] = "not json"
+			if _, err := decodeMany[testutils.Account](ids, values); err == nil {
+				t.Error("Expected an unmarshal error")
+			}
+		})
+	}
+
+	if ids := uniqueIDs([]int64{3, 1, 3, 2, 1}); fmt.Sprint(ids) != "[3 1 2]" {
+		t.Errorf("Expected deduplicated ids [3 1 2], got %v", ids)
+	}
+}
@@ -0,0 +1,173 @@
+package sietch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type order struct {
+	ID       int64  `db:"id"`
+	Status   string `db:"status"`
+	Region   string `db:"region"`
+	Quantity int    `db:"quantity"`
+}
+
+func TestCockroachDBQueryBuilder_GroupBy(t *testing.T) {
+	conn, err := NewCockroachDBConnector[order, int64](
+		&pgxpool.Pool{},
+		"orders",
+		func(o *order) int64 { return o.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	t.Run("Grouped query", func(t *testing.T) {
+		filter := NewFilter().
+			Where("quantity", OpGreaterThan, 0).
+			GroupBy("status", "region").
+			Having(
+				Condition{Field: CountField, Operator: OpGreaterThanOrEqual, Value: 2},
+				Condition{Field: "region", Operator: OpNotEqual, Value: "eu"},
+			).
+			OrderBy("region", SortAsc).
+			Build()
+
+		query, args, err := conn.queryBuilder(filter)
+		if err != nil {
+			t.Fatalf("queryBuilder failed: %v", err)
+		}
+		expected := `SELECT "status", "region" FROM "orders" WHERE "quantity" > $1 GROUP BY "status", "region" HAVING COUNT(*) >= $2 AND "region" != $3 ORDER BY "region" ASC`
+		if query != expected {
+			t.Errorf("Expected: %s\nGot: %s", expected, query)
+		}
+		if len(args) != 3 {
+			t.Errorf("Expected 3 args, got %d", len(args))
+		}
+	})
+
+	t.Run("Group count", func(t *testing.T) {
+		filter := NewFilter().
+			GroupBy("status").
+			Having(Condition{LogicalOp: LogicalOR, Conditions: []Condition{
+				{Field: CountField, Operator: OpGreaterThan, Value: 10},
+				{Field: "status", Operator: OpEqual, Value: "vip"},
+			}}).
+			OrderBy(CountField, SortDesc).
+			Limit(5).
+			Build()
+
+		query, _, err := conn.groupCountQuery(filter)
+		if err != nil {
+			t.Fatalf("groupCountQuery failed: %v", err)
+		}
+		expected := `SELECT "status", COUNT(*) FROM "orders" GROUP BY "status" HAVING (COUNT(*) > $1 OR "status" = $2) ORDER BY COUNT(*) DESC LIMIT 5`
+		if query != expected {
+			t.Errorf("Expected: %s\nGot: %s", expected, query)
+		}
+	})
+
+	errorCases := []struct {
+		name   string
+		filter *Filter
+	}{
+		{"unknown group field", NewFilter().GroupBy("missing").Build()},
+		{"having without group", NewFilter().Having(Condition{Field: CountField, Operator: OpGreaterThan, Value: 1}).Build()},
+		{"having on ungrouped field", NewFilter().GroupBy("status").Having(Condition{Field: "region", Operator: OpEqual, Value: "eu"}).Build()},
+		{"sort by ungrouped field", NewFilter().GroupBy("status").OrderBy("region", SortAsc).Build()},
+	}
+	for _, tt := range errorCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := conn.queryBuilder(tt.filter); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+
+	if _, _, err := conn.groupCountQuery(NewFilter().Build()); err == nil {
+		t.Error("Expected error for GroupCount without GROUP BY")
+	}
+}
+
+func TestInMemoryGroupBy(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[order, int64](func(o *order) int64 { return o.ID })
+	repo.BatchCreate(ctx, []order{
+		{ID: 1, Status: "open", Region: "us", Quantity: 1},
+		{ID: 2, Status: "open", Region: "eu", Quantity: 2},
+		{ID: 3, Status: "open", Region: "us", Quantity: 3},
+		{ID: 4, Status: "closed", Region: "us", Quantity: 4},
+		{ID: 5, Status: "closed", Region: "us", Quantity: 0},
+		{ID: 6, Status: "void", Region: "eu", Quantity: 5},
+	})
+
+	var g Grouper = repo
+
+	t.Run("Count by status", func(t *testing.T) {
+		counts, err := g.GroupCount(ctx, NewFilter().GroupBy("status").OrderBy(CountField, SortDesc).Build())
+		if err != nil {
+			t.Fatalf("GroupCount failed: %v", err)
+		}
+		expected := []struct {
+			status string
+			count  int64
+		}{{"open", 3}, {"closed", 2}, {"void", 1}}
+		if len(counts) != len(expected) {
+			t.Fatalf("Expected %d groups, got %d", len(expected), len(counts))
+		}
+		for i, e := range expected {
+			if counts[i].Key["status"] != e.status || counts[i].Count != e.count {
+				t.Errorf("Group %d: expected %s=%d, got %v=%d", i, e.status, e.count, counts[i].Key["status"], counts[i].Count)
+			}
+		}
+	})
+
+	t.Run("Where and having", func(t *testing.T) {
+		filter := NewFilter().
+			Where("quantity", OpGreaterThan, 0).
+			GroupBy("status", "region").
+			Having(Condition{Field: CountField, Operator: OpGreaterThanOrEqual, Value: 2}).
+			Build()
+		counts, err := g.GroupCount(ctx, filter)
+		if err != nil {
+			t.Fatalf("GroupCount failed: %v", err)
+		}
+		if len(counts) != 1 || counts[0].Key["status"] != "open" || counts[0].Key["region"] != "us" || counts[0].Count != 2 {
+			t.Errorf("Unexpected groups: %+v", counts)
+		}
+	})
+
+	t.Run("Grouped query returns one item per group", func(t *testing.T) {
+		results, err := repo.Query(ctx, NewFilter().GroupBy("region").OrderBy("region", SortAsc).Build())
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(results) != 2 || results[0].Region != "eu" || results[1].Region != "us" {
+			t.Fatalf("Unexpected results: %+v", results)
+		}
+		if results[0].ID != 0 || results[0].Status != "" {
+			t.Errorf("Expected only grouped fields to be set, got %+v", results[0])
+		}
+	})
+
+	t.Run("Limit and offset apply to groups", func(t *testing.T) {
+		counts, _ := g.GroupCount(ctx, NewFilter().GroupBy("status").OrderBy("status", SortAsc).Offset(1).Limit(1).Build())
+		if len(counts) != 1 || counts[0].Key["status"] != "open" {
+			t.Errorf("Unexpected groups: %+v", counts)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := g.GroupCount(ctx, NewFilter().Build()); err == nil {
+			t.Error("Expected error without GROUP BY")
+		}
+		if _, err := g.GroupCount(ctx, NewFilter().GroupBy("status").OrderBy("region", SortAsc).Build()); err == nil {
+			t.Error("Expected error sorting by ungrouped field")
+		}
+		if _, err := repo.Query(ctx, NewFilter().Having(Condition{Field: CountField, Operator: OpGreaterThan, Value: 1}).Build()); err == nil {
+			t.Error("Expected error for HAVING without GROUP BY")
+		}
+	})
+}
@@ -0,0 +1,330 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// HookableRepository wraps a repository and runs the hooks of a registry
+// around its operations, so hooks work the same on every backend:
+//
+//   - Create, Update and Delete run the Before hook, the operation and, if it
+//     succeeded, the After hook. Upsert runs the Create hooks of items that
+//     don't exist yet and the Update hooks of the others; the check is a
+//     separate read, so an item created concurrently may run the Create hooks.
+//   - Batch operations run the Before hook of every item, then the batch,
+//     then the After hook of every item.
+//   - Query, FindOne, Count and GroupCount run BeforeQuery on a copy of the
+//     filter, so hooks may change it without affecting the caller; Query and
+//     FindOne then run AfterQuery on the results.
+//   - UpdateWhere and DeleteWhere run BeforeQuery only, as the entities they
+//     change are not known.
+//   - Get, GetMany and Exists run no hooks.
+//
+// A failing Before hook aborts the operation with its error. After hooks run
+// once the operation succeeded, so an After hook error is returned with the
+// change already applied; set a handler with OnAfterHookError to receive
+// these errors instead.
+type HookableRepository[T any, ID comparable] struct {
+	base    Repository[T, ID]
+	getID   func(*T) ID
+	hooks   *HookRegistry[T, ID]
+	onError func(ctx context.Context, hook string, err error)
+}
+
+// NewHookableRepository creates a repository running the hooks of registry
+// around base; getID tells Upsert which items exist, see PKAccessor if nil.
+// A nil registry starts empty; add hooks with AddHook.
+func NewHookableRepository[T any, ID comparable](base Repository[T, ID], getID func(*T) ID, registry *HookRegistry[T, ID]) *HookableRepository[T, ID] {
+	if registry == nil {
+		registry = NewHookRegistry[T, ID]()
+	}
+	return &HookableRepository[T, ID]{
+		base:  base,
+		getID: mustResolveGetID(getID),
+		hooks: registry,
+	}
+}
+
+// AddHook registers a hook with the repository's registry (see Hookable)
+func (r *HookableRepository[T, ID]) AddHook(hook Hook[T, ID]) {
+	r.hooks.AddHook(hook)
+}
+
+// RemoveAllHooks clears the repository's registry
+func (r *HookableRepository[T, ID]) RemoveAllHooks() {
+	r.hooks.RemoveAllHooks()
+}
+
+// OnAfterHookError sets the function receiving the errors of After hooks,
+// e.g. to log them, which are then no longer returned. hook names the hook,
+// such as "AfterCreate". Not safe to call concurrently with operations.
+func (r *HookableRepository[T, ID]) OnAfterHookError(fn func(ctx context.Context, hook string, err error)) {
+	r.onError = fn
+}
+
+// after returns the error of an After hook, or passes it to the
+// OnAfterHookError handler
+func (r *HookableRepository[T, ID]) after(ctx context.Context, hook string, err error) error {
+	if err != nil && r.onError != nil {
+		r.onError(ctx, hook, err)
+		return nil
+	}
+	return err
+}
+
+// existing returns the stored items among items, for BatchUpsert to choose
+// their hooks
+func (r *HookableRepository[T, ID]) existing(ctx context.Context, items []T) (map[ID]*T, error) {
+	ids := make([]ID, len(items))
+	for i := range items {
+		ids[i] = r.getID(&items[i])
+	}
+	return r.base.GetMany(ctx, ids)
+}
+
+// beforeQuery runs the BeforeQuery hooks on a copy of filter and returns it
+func (r *HookableRepository[T, ID]) beforeQuery(ctx context.Context, filter *Filter) (*Filter, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	copied := *filter
+	copied.Conditions = slices.Clone(filter.Conditions)
+	copied.Sort = slices.Clone(filter.Sort)
+	copied.GroupBy = slices.Clone(filter.GroupBy)
+	copied.Having = slices.Clone(filter.Having)
+	if err := r.hooks.ExecuteBeforeQuery(ctx, &copied); err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
+
+func (r *HookableRepository[T, ID]) Create(ctx context.Context, item *T) error {
+	if err := r.hooks.ExecuteBeforeCreate(ctx, item); err != nil {
+		return err
+	}
+	if err := r.base.Create(ctx, item); err != nil {
+		return err
+	}
+	return r.after(ctx, "AfterCreate", r.hooks.ExecuteAfterCreate(ctx, item))
+}
+
+func (r *HookableRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	return r.base.Get(ctx, id)
+}
+
+func (r *HookableRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	return r.base.GetMany(ctx, ids)
+}
+
+func (r *HookableRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	return r.base.Exists(ctx, id)
+}
+
+func (r *HookableRepository[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	for i := range items {
+		if err := r.hooks.ExecuteBeforeCreate(ctx, &items[i]); err != nil {
+			return err
+		}
+	}
+	if err := r.base.BatchCreate(ctx, items); err != nil {
+		return err
+	}
+	var errs []error
+	for i := range items {
+		errs = append(errs, r.after(ctx, "AfterCreate", r.hooks.ExecuteAfterCreate(ctx, &items[i])))
+	}
+	return errors.Join(errs...)
+}
+
+func (r *HookableRepository[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
+	filter, err := r.beforeQuery(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	results, err := r.base.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return results, r.after(ctx, "AfterQuery", r.hooks.ExecuteAfterQuery(ctx, results))
+}
+
+func (r *HookableRepository[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	filter, err := r.beforeQuery(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	item, err := r.base.FindOne(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return item, r.after(ctx, "AfterQuery", r.hooks.ExecuteAfterQuery(ctx, []T{*item}))
+}
+
+func (r *HookableRepository[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
+	filter, err := r.beforeQuery(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return r.base.Count(ctx, filter)
+}
+
+func (r *HookableRepository[T, ID]) Update(ctx context.Context, item *T) error {
+	if err := r.hooks.ExecuteBeforeUpdate(ctx, item); err != nil {
+		return err
+	}
+	if err := r.base.Update(ctx, item); err != nil {
+		return err
+	}
+	return r.after(ctx, "AfterUpdate", r.hooks.ExecuteAfterUpdate(ctx, item))
+}
+
+func (r *HookableRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	for i := range items {
+		if err := r.hooks.ExecuteBeforeUpdate(ctx, &items[i]); err != nil {
+			return err
+		}
+	}
+	if err := r.base.BatchUpdate(ctx, items); err != nil {
+		return err
+	}
+	var errs []error
+	for i := range items {
+		errs = append(errs, r.after(ctx, "AfterUpdate", r.hooks.ExecuteAfterUpdate(ctx, &items[i])))
+	}
+	return errors.Join(errs...)
+}
+
+func (r *HookableRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	if err := r.hooks.ExecuteBeforeDelete(ctx, id); err != nil {
+		return err
+	}
+	if err := r.base.Delete(ctx, id); err != nil {
+		return err
+	}
+	return r.after(ctx, "AfterDelete", r.hooks.ExecuteAfterDelete(ctx, id))
+}
+
+func (r *HookableRepository[T, ID]) BatchDelete(ctx context.Context, ids []ID) error {
+	for _, id := range ids {
+		if err := r.hooks.ExecuteBeforeDelete(ctx, id); err != nil {
+			return err
+		}
+	}
+	if err := r.base.BatchDelete(ctx, ids); err != nil {
+		return err
+	}
+	var errs []error
+	for _, id := range ids {
+		errs = append(errs, r.after(ctx, "AfterDelete", r.hooks.ExecuteAfterDelete(ctx, id)))
+	}
+	return errors.Join(errs...)
+}
+
+func (r *HookableRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	exists, err := r.base.Exists(ctx, r.getID(item))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = r.hooks.ExecuteBeforeCreate(ctx, item)
+	} else {
+		err = r.hooks.ExecuteBeforeUpdate(ctx, item)
+	}
+	if err != nil {
+		return err
+	}
+	if err := r.base.Upsert(ctx, item); err != nil {
+		return err
+	}
+	if !exists {
+		return r.after(ctx, "AfterCreate", r.hooks.ExecuteAfterCreate(ctx, item))
+	}
+	return r.after(ctx, "AfterUpdate", r.hooks.ExecuteAfterUpdate(ctx, item))
+}
+
+func (r *HookableRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	stored, err := r.existing(ctx, items)
+	if err != nil {
+		return err
+	}
+	created := make([]bool, len(items))
+	for i := range items {
+		_, exists := stored[r.getID(&items[i])]
+		created[i] = !exists
+		if created[i] {
+			err = r.hooks.ExecuteBeforeCreate(ctx, &items[i])
+		} else {
+			err = r.hooks.ExecuteBeforeUpdate(ctx, &items[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := r.base.BatchUpsert(ctx, items); err != nil {
+		return err
+	}
+	var errs []error
+	for i := range items {
+		if created[i] {
+			errs = append(errs, r.after(ctx, "AfterCreate", r.hooks.ExecuteAfterCreate(ctx, &items[i])))
+		} else {
+			errs = append(errs, r.after(ctx, "AfterUpdate", r.hooks.ExecuteAfterUpdate(ctx, &items[i])))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *HookableRepository[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	filter, err := r.beforeQuery(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return r.base.UpdateWhere(ctx, filter, updates)
+}
+
+func (r *HookableRepository[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	filter, err := r.beforeQuery(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return r.base.DeleteWhere(ctx, filter)
+}
+
+// GroupCount runs BeforeQuery and delegates to the base repository if it
+// implements Grouper
+func (r *HookableRepository[T, ID]) GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error) {
+	grouper, ok := r.base.(Grouper)
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	filter, err := r.beforeQuery(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return grouper.GroupCount(ctx, filter)
+}
+
+// GetForUpdate delegates to the base repository if it implements RowLocker
+func (r *HookableRepository[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
+	locker, ok := r.base.(RowLocker[T, ID])
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	return locker.GetForUpdate(ctx, id)
+}
+
+// WithTx runs fn in a transaction of the base repository, which must
+// implement Transactional. The repository passed to fn runs the same hooks.
+// After hooks run as each operation completes, before the transaction
+// commits; hooks writing elsewhere may record changes that are rolled back.
+func (r *HookableRepository[T, ID]) WithTx(ctx context.Context, fn TxFunc[T, ID]) error {
+	txRepo, ok := r.base.(Transactional[T, ID])
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	return txRepo.WithTx(ctx, func(tx Repository[T, ID]) error {
+		return fn(&HookableRepository[T, ID]{base: tx, getID: r.getID, hooks: r.hooks, onError: r.onError})
+	})
+}
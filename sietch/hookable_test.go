@@ -0,0 +1,221 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+// recordingHook logs every hook call and fails the Before hooks of negative
+// balances
+type recordingHook struct {
+	BaseHook[testutils.Account, int64]
+	calls []string
+}
+
+func (h *recordingHook) check(name string, item *testutils.Account) error {
+	h.calls = append(h.calls, fmt.Sprintf("%s %d", name, item.ID))
+	if item.Balance < 0 {
+		return errors.New("negative balance")
+	}
+	return nil
+}
+
+func (h *recordingHook) BeforeCreate(_ context.Context, item *testutils.Account) error {
+	return h.check("BeforeCreate", item)
+}
+
+func (h *recordingHook) AfterCreate(_ context.Context, item *testutils.Account) error {
+	h.calls = append(h.calls, fmt.Sprintf("AfterCreate %d", item.ID))
+	return errors.New("after failed")
+}
+
+func (h *recordingHook) BeforeUpdate(_ context.Context, item *testutils.Account) error {
+	return h.check("BeforeUpdate", item)
+}
+
+func (h *recordingHook) AfterUpdate(_ context.Context, item *testutils.Account) error {
+	h.calls = append(h.calls, fmt.Sprintf("AfterUpdate %d", item.ID))
+	return nil
+}
+
+func (h *recordingHook) BeforeDelete(_ context.Context, id int64) error {
+	h.calls = append(h.calls, fmt.Sprintf("BeforeDelete %d", id))
+	return nil
+}
+
+func (h *recordingHook) AfterDelete(_ context.Context, id int64) error {
+	h.calls = append(h.calls, fmt.Sprintf("AfterDelete %d", id))
+	return nil
+}
+
+func (h *recordingHook) BeforeQuery(_ context.Context, filter *Filter) error {
+	h.calls = append(h.calls, "BeforeQuery")
+	filter.Conditions = append(filter.Conditions, Condition{Field: "balance", Operator: OpGreaterThan, Value: 0})
+	return nil
+}
+
+func (h *recordingHook) AfterQuery(_ context.Context, results []testutils.Account) error {
+	h.calls = append(h.calls, fmt.Sprintf("AfterQuery %d", len(results)))
+	return nil
+}
+
+func TestHookableRepository(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+
+	newRepo := func() (*HookableRepository[testutils.Account, int64], *recordingHook, *[]string) {
+		hook := &recordingHook{}
+		repo := NewHookableRepository(NewInMemoryConnector[testutils.Account](getID), getID, nil)
+		repo.AddHook(hook)
+		var afterErrors []string
+		repo.OnAfterHookError(func(_ context.Context, name string, err error) {
+			afterErrors = append(afterErrors, name+": "+err.Error())
+		})
+		return repo, hook, &afterErrors
+	}
+
+	t.Run("Writes run their hooks", func(t *testing.T) {
+		repo, hook, afterErrors := newRepo()
+
+		if err := repo.Create(ctx, &testutils.Account{ID: 1, Balance: 10}); err != nil {
+			t.Fatalf("Expected After hook errors not to fail the create, got %v", err)
+		}
+		if err := repo.BatchUpdate(ctx, []testutils.Account{{ID: 1, Balance: 5}}); err != nil {
+			t.Fatalf("BatchUpdate failed: %v", err)
+		}
+		if err := repo.Delete(ctx, 1); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+
+		expected := []string{"BeforeCreate 1", "AfterCreate 1", "BeforeUpdate 1", "AfterUpdate 1", "BeforeDelete 1", "AfterDelete 1"}
+		if !reflect.DeepEqual(hook.calls, expected) {
+			t.Errorf("Unexpected hook calls %v", hook.calls)
+		}
+		if !reflect.DeepEqual(*afterErrors, []string{"AfterCreate: after failed"}) {
+			t.Errorf("Expected the After hook error to be reported, got %v", *afterErrors)
+		}
+	})
+
+	t.Run("After hook errors are returned without a handler", func(t *testing.T) {
+		hook := &recordingHook{}
+		repo := NewHookableRepository(NewInMemoryConnector[testutils.Account](getID), getID, nil)
+		repo.AddHook(hook)
+
+		err := repo.BatchCreate(ctx, []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}})
+		if err == nil || err.Error() != "after failed\nafter failed" {
+			t.Fatalf("Expected the After hook errors, got %v", err)
+		}
+		if count, _ := repo.Count(ctx, nil); count != 2 {
+			t.Errorf("Expected the items to be created anyway, got %d", count)
+		}
+	})
+
+	t.Run("Upserts run the Create hooks of new items", func(t *testing.T) {
+		repo, hook, _ := newRepo()
+		_ = repo.Create(ctx, &testutils.Account{ID: 1, Balance: 10})
+		hook.calls = nil
+
+		if err := repo.Upsert(ctx, &testutils.Account{ID: 2, Balance: 20}); err != nil {
+			t.Fatalf("Upsert failed: %v", err)
+		}
+		if err := repo.BatchUpsert(ctx, []testutils.Account{{ID: 1, Balance: 5}, {ID: 3, Balance: 30}}); err != nil {
+			t.Fatalf("BatchUpsert failed: %v", err)
+		}
+
+		expected := []string{
+			"BeforeCreate 2", "AfterCreate 2",
+			"BeforeUpdate 1", "BeforeCreate 3", "AfterUpdate 1", "AfterCreate 3",
+		}
+		if !reflect.DeepEqual(hook.calls, expected) {
+			t.Errorf("Unexpected hook calls %v", hook.calls)
+		}
+	})
+
+	t.Run("Failing Before hooks abort the operation", func(t *testing.T) {
+		repo, hook, _ := newRepo()
+
+		err := repo.BatchCreate(ctx, []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: -1}})
+		if err == nil || err.Error() != "negative balance" {
+			t.Fatalf("Expected the hook error, got %v", err)
+		}
+		if exists, _ := repo.Exists(ctx, 1); exists {
+			t.Error("Expected nothing to be created")
+		}
+		if !reflect.DeepEqual(hook.calls, []string{"BeforeCreate 1", "BeforeCreate 2"}) {
+			t.Errorf("Unexpected hook calls %v", hook.calls)
+		}
+	})
+
+	t.Run("Queries run BeforeQuery on a copy of the filter", func(t *testing.T) {
+		repo, hook, _ := newRepo()
+		_ = repo.BatchCreate(ctx, []testutils.Account{{ID: 1, Balance: 0}, {ID: 2, Balance: 20}})
+		hook.calls = nil
+
+		filter := &Filter{}
+		results, err := repo.Query(ctx, filter)
+		if err != nil || len(results) != 1 || results[0].ID != 2 {
+			t.Fatalf("Expected the hook condition to apply, got %+v (%v)", results, err)
+		}
+		if len(filter.Conditions) != 0 {
+			t.Error("Expected the caller's filter to be unchanged")
+		}
+		if count, _ := repo.Count(ctx, filter); count != 1 {
+			t.Errorf("Expected a count of 1, got %d", count)
+		}
+		if !reflect.DeepEqual(hook.calls, []string{"BeforeQuery", "AfterQuery 1", "BeforeQuery"}) {
+			t.Errorf("Unexpected hook calls %v", hook.calls)
+		}
+	})
+
+	t.Run("Transactions run the hooks", func(t *testing.T) {
+		repo, hook, _ := newRepo()
+
+		err := repo.WithTx(ctx, func(tx Repository[testutils.Account, int64]) error {
+			if err := tx.Create(ctx, &testutils.Account{ID: 1, Balance: 10}); err != nil {
+				return err
+			}
+			return tx.Upsert(ctx, &testutils.Account{ID: 1, Balance: -5})
+		})
+		if err == nil {
+			t.Fatal("Expected the transaction to fail")
+		}
+		if exists, _ := repo.Exists(ctx, 1); exists {
+			t.Error("Expected the transaction to be rolled back")
+		}
+		if !reflect.DeepEqual(hook.calls, []string{"BeforeCreate 1", "AfterCreate 1", "BeforeUpdate 1"}) {
+			t.Errorf("Unexpected hook calls %v", hook.calls)
+		}
+
+		plain := NewHookableRepository[testutils.Account, int64](&countingRepository{}, getID, nil)
+		if err := plain.WithTx(ctx, nil); !errors.Is(err, ErrUnsupportedOperation) {
+			t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+		}
+	})
+}
+
+func TestHookableRepository_Audit(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	base := NewInMemoryConnector[testutils.Account](getID)
+	log := NewInMemoryConnector[AuditEntry](AuditEntryID)
+
+	repo := NewHookableRepository(base, getID, nil)
+	repo.AddHook(NewAuditHook(NewRepositoryAuditStore(log), base, getID, AuditOptions{}))
+
+	_ = repo.BatchCreate(ctx, []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}})
+	_ = repo.Update(ctx, &testutils.Account{ID: 1, Balance: 15})
+	_ = repo.BatchDelete(ctx, []int64{1, 2})
+
+	if count, _ := log.Count(ctx, &Filter{}); count != 5 {
+		t.Errorf("Expected 5 audit entries, got %d", count)
+	}
+	entry, err := log.FindOne(ctx, NewFilter().Where("operation", OpEqual, AuditUpdate).Build())
+	if err != nil || string(entry.Before) != `{"ID":1,"Balance":10}` || string(entry.After) != `{"ID":1,"Balance":15}` {
+		t.Errorf("Unexpected update entry %+v (%v)", entry, err)
+	}
+}
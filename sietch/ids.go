@@ -0,0 +1,230 @@
+package sietch
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// IDCodec converts identifiers to and from their storage representations.
+// Connectors keep accepting bare comparable IDs; a codec is an opt-in layer
+// that gives typed IDs a well-defined SQL value and Redis key form.
+type IDCodec[ID comparable] interface {
+	// EncodeSQL returns the value bound to SQL placeholders for the ID
+	EncodeSQL(id ID) (any, error)
+
+	// DecodeSQL builds an ID from a value scanned from the database
+	DecodeSQL(src any) (ID, error)
+
+	// EncodeKey returns the string form of the ID used in cache keys
+	EncodeKey(id ID) (string, error)
+
+	// DecodeKey parses an ID from its key string form
+	DecodeKey(key string) (ID, error)
+}
+
+// IDValidator is an optional interface for ID types with custom validation rules
+type IDValidator interface {
+	Validate() error
+}
+
+// TypedID wraps a raw identifier value with the entity type it belongs to.
+// TypedID[Account, int64] and TypedID[Order, int64] are distinct types, so the
+// compiler rejects passing an order ID where an account ID is expected.
+//
+// TypedID implements driver.Valuer, sql.Scanner and json.Marshaler so it can be
+// used directly as a db-tagged struct field and as the ID type parameter of
+// any connector.
+type TypedID[E any, V comparable] struct {
+	value V
+}
+
+// NewTypedID wraps a raw value into a TypedID
+func NewTypedID[E any, V comparable](v V) TypedID[E, V] {
+	return TypedID[E, V]{value: v}
+}
+
+// Raw returns the underlying identifier value
+func (id TypedID[E, V]) Raw() V {
+	return id.value
+}
+
+// IsZero returns true if the underlying value is the zero value of V
+func (id TypedID[E, V]) IsZero() bool {
+	var zero V
+	return id.value == zero
+}
+
+// String returns the string form of the underlying value
+func (id TypedID[E, V]) String() string {
+	return fmt.Sprint(id.value)
+}
+
+// Value implements driver.Valuer
+func (id TypedID[E, V]) Value() (driver.Value, error) {
+	return driver.DefaultParameterConverter.ConvertValue(id.value)
+}
+
+// Scan implements sql.Scanner
+func (id *TypedID[E, V]) Scan(src any) error {
+	v, err := convertScalar[V](src)
+	if err != nil {
+		return err
+	}
+	id.value = v
+	return nil
+}
+
+// MarshalJSON encodes the ID as its underlying value
+func (id TypedID[E, V]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(id.value)
+}
+
+// UnmarshalJSON decodes the ID from its underlying value
+func (id *TypedID[E, V]) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &id.value)
+}
+
+// TypedIDCodec is the IDCodec implementation for TypedID
+type TypedIDCodec[E any, V comparable] struct{}
+
+// EncodeSQL implements IDCodec
+func (TypedIDCodec[E, V]) EncodeSQL(id TypedID[E, V]) (any, error) {
+	return id.Value()
+}
+
+// DecodeSQL implements IDCodec
+func (TypedIDCodec[E, V]) DecodeSQL(src any) (TypedID[E, V], error) {
+	var id TypedID[E, V]
+	err := id.Scan(src)
+	return id, err
+}
+
+// EncodeKey implements IDCodec
+func (TypedIDCodec[E, V]) EncodeKey(id TypedID[E, V]) (string, error) {
+	return id.String(), nil
+}
+
+// DecodeKey implements IDCodec
+func (TypedIDCodec[E, V]) DecodeKey(key string) (TypedID[E, V], error) {
+	v, err := convertScalar[V](key)
+	if err != nil {
+		return TypedID[E, V]{}, err
+	}
+	return NewTypedID[E](v), nil
+}
+
+// ScalarIDCodec is the IDCodec for plain scalar IDs (integers, strings, floats)
+type ScalarIDCodec[ID comparable] struct{}
+
+// EncodeSQL implements IDCodec
+func (ScalarIDCodec[ID]) EncodeSQL(id ID) (any, error) {
+	return id, nil
+}
+
+// DecodeSQL implements IDCodec
+func (ScalarIDCodec[ID]) DecodeSQL(src any) (ID, error) {
+	return convertScalar[ID](src)
+}
+
+// EncodeKey implements IDCodec
+func (ScalarIDCodec[ID]) EncodeKey(id ID) (string, error) {
+	return fmt.Sprint(id), nil
+}
+
+// DecodeKey implements IDCodec
+func (ScalarIDCodec[ID]) DecodeKey(key string) (ID, error) {
+	return convertScalar[ID](key)
+}
+
+// ValidateID checks that an ID is not the zero value of its type and, if the
+// ID implements IDValidator, that its custom validation passes
+func ValidateID[ID comparable](id ID) error {
+	var zero ID
+	if id == zero {
+		return fmt.Errorf("%w: zero value", ErrInvalidID)
+	}
+	if v, ok := any(id).(IDValidator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidID, err)
+		}
+	}
+	return nil
+}
+
+// ValidateIDs runs ValidateID on every ID and returns the first failure
+func ValidateIDs[ID comparable](ids []ID) error {
+	for i, id := range ids {
+		if err := ValidateID(id); err != nil {
+			return fmt.Errorf("id at index %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// convertScalar converts a scanned value or key string into a scalar of type V
+func convertScalar[V any](src any) (V, error) {
+	var out V
+	if src == nil {
+		return out, fmt.Errorf("%w: cannot convert nil", ErrInvalidID)
+	}
+	if v, ok := src.(V); ok {
+		return v, nil
+	}
+
+	target := reflect.ValueOf(&out).Elem()
+	if b, ok := src.([]byte); ok {
+		src = string(b)
+	}
+
+	if s, ok := src.(string); ok {
+		switch target.Kind() {
+		case reflect.String:
+			target.SetString(s)
+			return out, nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, target.Type().Bits())
+			if err != nil {
+				return out, fmt.Errorf("%w: %v", ErrInvalidID, err)
+			}
+			target.SetInt(n)
+			return out, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseUint(s, 10, target.Type().Bits())
+			if err != nil {
+				return out, fmt.Errorf("%w: %v", ErrInvalidID, err)
+			}
+			target.SetUint(n)
+			return out, nil
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(s, target.Type().Bits())
+			if err != nil {
+				return out, fmt.Errorf("%w: %v", ErrInvalidID, err)
+			}
+			target.SetFloat(f)
+			return out, nil
+		}
+		return out, fmt.Errorf("%w: cannot parse %q into %s", ErrInvalidID, s, target.Type())
+	}
+
+	sv := reflect.ValueOf(src)
+	if isNumericKind(sv.Kind()) && isNumericKind(target.Kind()) && sv.Type().ConvertibleTo(target.Type()) {
+		target.Set(sv.Convert(target.Type()))
+		return out, nil
+	}
+
+	return out, fmt.Errorf("%w: cannot convert %T into %s", ErrInvalidID, src, target.Type())
+}
+
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,194 @@
+package sietch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type typedAccount struct {
+	ID      TypedID[typedAccount, int64] `db:"id"`
+	Balance int                          `db:"balance"`
+}
+
+type typedOrder struct{}
+
+func TestTypedID(t *testing.T) {
+	t.Run("Value and Scan round trip", func(t *testing.T) {
+		id := NewTypedID[typedAccount](int64(42))
+		v, err := id.Value()
+		if err != nil {
+			t.Fatalf("Value failed: %v", err)
+		}
+		if v != int64(42) {
+			t.Errorf("Expected driver value 42, got %v", v)
+		}
+
+		var scanned TypedID[typedAccount, int64]
+		if err := scanned.Scan(int32(42)); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		if scanned != id {
+			t.Errorf("Expected %v, got %v", id, scanned)
+		}
+
+		if err := scanned.Scan([]byte("7")); err != nil {
+			t.Fatalf("Scan from bytes failed: %v", err)
+		}
+		if scanned.Raw() != 7 {
+			t.Errorf("Expected 7, got %d", scanned.Raw())
+		}
+	})
+
+	t.Run("Scan rejects incompatible values", func(t *testing.T) {
+		var id TypedID[typedOrder, int64]
+		if err := id.Scan("not-a-number"); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected ErrInvalidID, got %v", err)
+		}
+		if err := id.Scan(nil); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected ErrInvalidID for nil, got %v", err)
+		}
+	})
+
+	t.Run("JSON encodes raw value", func(t *testing.T) {
+		acc := typedAccount{ID: NewTypedID[typedAccount](int64(3)), Balance: 10}
+		data, err := json.Marshal(acc)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if string(data) != `{"ID":3,"Balance":10}` {
+			t.Errorf("Unexpected JSON: %s", data)
+		}
+
+		var decoded typedAccount
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if decoded.ID.Raw() != 3 {
+			t.Errorf("Expected ID 3, got %d", decoded.ID.Raw())
+		}
+	})
+
+	t.Run("Works as InMemory ID type", func(t *testing.T) {
+		ctx := context.Background()
+		repo := NewInMemoryConnector[typedAccount, TypedID[typedAccount, int64]](
+			func(a *typedAccount) TypedID[typedAccount, int64] { return a.ID },
+		)
+
+		id := NewTypedID[typedAccount](int64(1))
+		if err := repo.Create(ctx, &typedAccount{ID: id, Balance: 100}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+
+		got, err := repo.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got.Balance != 100 {
+			t.Errorf("Expected balance 100, got %d", got.Balance)
+		}
+	})
+}
+
+func TestIDCodecs(t *testing.T) {
+	t.Run("TypedIDCodec", func(t *testing.T) {
+		codec := TypedIDCodec[typedAccount, string]{}
+		id := NewTypedID[typedAccount]("abc")
+
+		key, err := codec.EncodeKey(id)
+		if err != nil || key != "abc" {
+			t.Fatalf("EncodeKey = %q, %v", key, err)
+		}
+		decoded, err := codec.DecodeKey(key)
+		if err != nil || decoded != id {
+			t.Fatalf("DecodeKey = %v, %v", decoded, err)
+		}
+	})
+
+	t.Run("ScalarIDCodec", func(t *testing.T) {
+		codec := ScalarIDCodec[uint32]{}
+		decoded, err := codec.DecodeKey("12")
+		if err != nil || decoded != 12 {
+			t.Fatalf("DecodeKey = %v, %v", decoded, err)
+		}
+		decoded, err = codec.DecodeSQL(int64(5))
+		if err != nil || decoded != 5 {
+			t.Fatalf("DecodeSQL = %v, %v", decoded, err)
+		}
+		if _, err := codec.DecodeKey("-1"); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected ErrInvalidID, got %v", err)
+		}
+	})
+
+	t.Run("Redis keys use the codec's key form", func(t *testing.T) {
+		repo := NewRedisConnectorWithCodec[typedAccount](nil, time.Minute, func(a *typedAccount) TypedID[typedAccount, int64] { return a.ID }, "account:", TypedIDCodec[typedAccount, int64]{})
+		if key, err := repo.keyFunc(NewTypedID[typedAccount](int64(9))); err != nil || key != "account:9" {
+			t.Errorf("Expected account:9, got %s (%v)", key, err)
+		}
+	})
+
+	t.Run("Redis connectors return encoding errors", func(t *testing.T) {
+		ctx := context.Background()
+		client := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+		defer client.Close()
+		repo := NewRedisConnectorWithCodec[evenEntry](client, time.Minute, func(e *evenEntry) evenID { return e.ID }, "even:", failingKeyCodec{})
+
+		if _, err := repo.Get(ctx, 3); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected the encoding error from Get, got %v", err)
+		}
+		if err := repo.Create(ctx, &evenEntry{ID: 3}); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected the encoding error from Create, got %v", err)
+		}
+		if _, err := repo.GetMany(ctx, []evenID{2, 3}); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected the encoding error from GetMany, got %v", err)
+		}
+	})
+}
+
+type evenID int
+
+type evenEntry struct {
+	ID evenID `db:"id"`
+}
+
+// failingKeyCodec fails to encode the keys of odd IDs
+type failingKeyCodec struct {
+	ScalarIDCodec[evenID]
+}
+
+func (failingKeyCodec) EncodeKey(id evenID) (string, error) {
+	if err := id.Validate(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	return fmt.Sprint(int(id)), nil
+}
+
+func (id evenID) Validate() error {
+	if id%2 != 0 {
+		return errors.New("must be even")
+	}
+	return nil
+}
+
+func TestValidateID(t *testing.T) {
+	if err := ValidateID(int64(0)); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID for zero ID, got %v", err)
+	}
+	if err := ValidateID("x"); err != nil {
+		t.Errorf("Expected valid ID, got %v", err)
+	}
+	if err := ValidateID(evenID(3)); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected custom validation failure, got %v", err)
+	}
+	if err := ValidateIDs([]evenID{2, 4, 5}); err == nil {
+		t.Error("Expected ValidateIDs to fail on odd ID")
+	}
+	if err := ValidateID(TypedID[typedAccount, int64]{}); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected zero TypedID to be invalid, got %v", err)
+	}
+}
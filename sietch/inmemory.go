@@ -1,25 +1,71 @@
 package sietch
 
 import (
+	"container/list"
 	"context"
+	"encoding/json"
 	"fmt"
+	"hash/maphash"
+	"iter"
+	"math/rand/v2"
 	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
 )
 
 // InMemoryConnector in-memory implementation of the Repository interface
 type InMemoryConnector[T any, ID comparable] struct {
-	data  map[ID]*T
-	mu    sync.RWMutex
-	getID func(t *T) ID // function to extract an element ID
+	shards   []*shard[T, ID] // items partitioned by ID hash, see NewShardedInMemoryConnector
+	seed     maphash.Seed
+	mu       sync.RWMutex  // guards the configuration below
+	getID    func(t *T) ID // function to extract an element ID
+	version  []int         // struct field index path of the version column, nil if none
+	readonly [][]int       // struct field index paths of the readonly and generated columns
+
+	softDelete bool // T is SoftDeletable: Delete marks items deleted, reads skip them
+
+	collations       map[string]collation // per-field ORDER BY collations
+	defaultCollation *collation           // applied to string sort fields without their own collation
+
+	tables map[string]SubqueryTable // tables available to EXISTS conditions
+
+	journal atomic.Pointer[appendLog[T, ID]] // appends writes, see EnableAppendOnlyFile
+
+	onEvict func(id ID, item *T) // called with evicted items, see SetMaxItems
+
+	ttl       time.Duration // items expire after it, see NewInMemoryConnectorWithTTL
+	stopSweep chan struct{} // stops the sweeper of expired items, see Close
+	stopOnce  sync.Once
+}
+
+// collation describes a locale-aware string ordering
+type collation struct {
+	tag  language.Tag
+	opts []collate.Option
+}
+
+// newCollator creates a collator. Collators are not safe for concurrent use,
+// so one is created per query.
+func (c collation) newCollator() *collate.Collator {
+	return collate.New(c.tag, c.opts...)
 }
 
+// NewInMemoryConnector creates an in-memory repository. getID may be nil when
+// T has a pk-tagged field of type ID (see PKAccessor); otherwise a nil getID
+// panics.
 func NewInMemoryConnector[T any, ID comparable](getID func(t *T) ID) *InMemoryConnector[T, ID] {
-	return &InMemoryConnector[T, ID]{
-		data:  make(map[ID]*T),
-		getID: getID,
-	}
+	return NewShardedInMemoryConnector(getID, 1)
 }
 
 func (r *InMemoryConnector[T, ID]) Create(_ context.Context, item *T) error {
@@ -27,62 +73,108 @@ func (r *InMemoryConnector[T, ID]) Create(_ context.Context, item *T) error {
 		return fmt.Errorf("item cannot be nil")
 	}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	id := r.getID(item)
-	if _, exists := r.data[id]; exists {
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.get(id); exists {
 		return ErrItemAlreadyExists
 	}
 
-	r.data[id] = item
+	r.put(s, id, item)
 	return nil
 }
 
-func (r *InMemoryConnector[T, ID]) Get(_ context.Context, id ID) (*T, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+func (r *InMemoryConnector[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	s := r.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	item, exists := r.data[id]
-	if !exists {
+	item, exists := s.get(id)
+	if !exists || r.hidden(ctx, item) {
 		return nil, ErrItemNotFound
 	}
 
 	return item, nil
 }
 
+// GetForUpdate is Get: InMemory transactions take no row locks (see RowLocker)
+func (r *InMemoryConnector[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
+	return r.Get(ctx, id)
+}
+
+// GetMany returns the items with the given IDs; missing IDs are absent from the map
+func (r *InMemoryConnector[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	results := make(map[ID]*T, len(ids))
+	for _, id := range ids {
+		s := r.shard(id)
+		s.mu.RLock()
+		if item, exists := s.get(id); exists && !r.hidden(ctx, item) {
+			results[id] = item
+		}
+		s.mu.RUnlock()
+	}
+	return results, nil
+}
+
 func (r *InMemoryConnector[T, ID]) BatchCreate(ctx context.Context, items []T) error {
 	if len(items) == 0 {
 		return nil
 	}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	defer r.lockShards(itemsByID(items, r.getID))()
 
 	for _, item := range items {
 		id := r.getID(&item)
-		if _, exists := r.data[id]; exists {
+		s := r.shard(id)
+		if _, exists := s.get(id); exists {
 			return ErrItemAlreadyExists
 		}
-		r.data[id] = &item
+		r.put(s, id, &item)
 	}
 	return nil
 }
 
-func (r *InMemoryConnector[T, ID]) Query(_ context.Context, filter *Filter) ([]T, error) {
+func (r *InMemoryConnector[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
+	filter, err := r.resolveSubqueries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	matches := r.matcher(ctx, filter)
 	var results []T
-	for _, item := range r.data {
-		if matchesCondition(item, filter) {
-			results = append(results, *item)
+	if n, ok := sampleSize(filter); ok {
+		results = reservoirSample(r.all(), matches, n, r.len())
+	} else {
+		for _, item := range r.all() {
+			if matches(item) {
+				results = append(results, *item)
+			}
 		}
 	}
 
+	// Grouped queries return one item per group with only the grouped fields set
+	if filter != nil && len(filter.GroupBy) > 0 {
+		groups, err := r.groupResults(results, filter)
+		if err != nil {
+			return nil, err
+		}
+		grouped := make([]T, len(groups))
+		for i, g := range groups {
+			grouped[i] = g.item
+		}
+		return grouped, nil
+	} else if filter != nil && len(filter.Having) > 0 {
+		return nil, fmt.Errorf("HAVING requires GROUP BY")
+	}
+
 	// Apply sorting
 	if filter != nil && len(filter.Sort) > 0 {
-		results = sortResults(results, filter.Sort)
+		results = sortResults(results, filter.Sort, r.collators(filter.Sort))
 	}
 
 	// Apply DISTINCT
@@ -109,14 +201,22 @@ func (r *InMemoryConnector[T, ID]) Query(_ context.Context, filter *Filter) ([]T
 	return results, nil
 }
 
+// FindOne returns the first item matching the filter, or ErrItemNotFound
+func (r *InMemoryConnector[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	return findOne(ctx, filter, r.Query)
+}
+
 // Count returns the number of items matching the filter
-func (r *InMemoryConnector[T, ID]) Count(_ context.Context, filter *Filter) (int64, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+func (r *InMemoryConnector[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
+	filter, err := r.resolveSubqueries(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
 
+	matches := r.matcher(ctx, filter)
 	var count int64
-	for _, item := range r.data {
-		if matchesCondition(item, filter) {
+	for _, item := range r.all() {
+		if matches(item) {
 			count++
 		}
 	}
@@ -129,45 +229,76 @@ func (r *InMemoryConnector[T, ID]) Update(_ context.Context, item *T) error {
 		return fmt.Errorf("item cannot be nil")
 	}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	id := r.getID(item)
-	if _, exists := r.data[id]; !exists {
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.get(id)
+	if !exists {
 		return ErrItemNotFound
 	}
+	if r.version != nil {
+		if r.versionOf(stored) != r.versionOf(item) {
+			return ErrVersionConflict
+		}
+		item = r.nextVersion(item)
+	}
 
-	r.data[id] = item
+	r.put(s, id, item)
 	return nil
 }
 
+// BatchUpdate updates items like the CockroachDB connector: if some of them
+// do not exist, or for versioned entities have a different version, nothing
+// is written and a MissingItemsError and a VersionConflictError list all of
+// them. When an ID appears more than once the last item wins.
 func (r *InMemoryConnector[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
 	if len(items) == 0 {
 		return nil
 	}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	items = lastByID(items, r.getID)
+	defer r.lockShards(itemsByID(items, r.getID))()
+
+	var missing, conflicts []ID
+	for i := range items {
+		id := r.getID(&items[i])
+		stored, exists := r.shard(id).get(id)
+		switch {
+		case !exists:
+			missing = append(missing, id)
+		case r.version != nil && r.versionOf(stored) != r.versionOf(&items[i]):
+			conflicts = append(conflicts, id)
+		}
+	}
+	if err := batchUpdateError(missing, conflicts); err != nil {
+		return err
+	}
 
 	for _, item := range items {
 		id := r.getID(&item)
-		if _, exists := r.data[id]; !exists {
-			return ErrItemNotFound
+		s := r.shard(id)
+		if r.version != nil {
+			r.put(s, id, r.nextVersion(&item))
+			continue
 		}
-		r.data[id] = &item
+		r.put(s, id, &item)
 	}
 	return nil
 }
 
 func (r *InMemoryConnector[T, ID]) Delete(_ context.Context, id ID) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if _, exists := r.data[id]; !exists {
+	item, exists := s.get(id)
+	if !exists || (r.softDelete && isEntityDeleted(item)) {
 		return ErrItemNotFound
 	}
 
-	delete(r.data, id)
+	r.remove(s, id, item)
 	return nil
 }
 
@@ -176,14 +307,176 @@ func (r *InMemoryConnector[T, ID]) BatchDelete(ctx context.Context, items []ID)
 		return nil
 	}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	defer r.lockShards(slices.Values(items))()
 
 	for _, id := range items {
-		if _, exists := r.data[id]; !exists {
+		s := r.shard(id)
+		item, exists := s.get(id)
+		if !exists || (r.softDelete && isEntityDeleted(item)) {
 			return ErrItemNotFound
 		}
-		delete(r.data, id)
+		r.remove(s, id, item)
+	}
+	return nil
+}
+
+// remove deletes the item stored under id in s, or replaces it with a copy
+// marked as deleted for SoftDeletable entities. The caller holds the lock of s.
+func (r *InMemoryConnector[T, ID]) remove(s *shard[T, ID], id ID, item *T) {
+	if !r.softDelete {
+		r.drop(s, id)
+		return
+	}
+	copyValue := *item
+	markAsDeleted(&copyValue)
+	r.put(s, id, &copyValue)
+}
+
+// UpdateWhere sets the given fields (by db tag or field name) on every item
+// matching the filter conditions. Either all matching items are updated or none.
+// Versioned items get their version incremented and readonly and generated
+// columns are rejected, as by the CockroachDB connector.
+func (r *InMemoryConnector[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	if err := validateBulkFilter(filter); err != nil {
+		return 0, err
+	}
+	if len(updates) == 0 {
+		return 0, fmt.Errorf("updates cannot be empty")
+	}
+	filter, err := r.resolveSubqueries(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	defer r.lockAll()()
+
+	// Apply updates to copies first so a failing assignment leaves the data untouched
+	matches := r.liveMatcher(filter)
+	updated := make(map[ID]*T)
+	for id, item := range r.entries() {
+		if !matches(item) {
+			continue
+		}
+		copyValue := *item
+		v := reflect.ValueOf(&copyValue).Elem()
+		for column, value := range updates {
+			path, ok := columnPath(v.Type(), column)
+			if !ok {
+				return 0, fmt.Errorf("unknown field '%s' for update", column)
+			}
+			if slices.ContainsFunc(r.readonly, func(p []int) bool { return slices.Equal(p, path) }) {
+				return 0, fmt.Errorf("column '%s' is read-only", column)
+			}
+			if r.version != nil && slices.Equal(path, r.version) {
+				return 0, fmt.Errorf("column '%s' is the version column", column)
+			}
+			field := detachedField(v, path)
+			if !field.CanSet() {
+				return 0, fmt.Errorf("unknown field '%s' for update", column)
+			}
+			if err := assignFieldValue(field, value); err != nil {
+				return 0, fmt.Errorf("field %s: %w", column, err)
+			}
+		}
+		if r.version != nil {
+			updated[id] = r.nextVersion(&copyValue)
+			continue
+		}
+		updated[id] = &copyValue
+	}
+
+	for oldID, item := range updated {
+		if newID := r.getID(item); newID != oldID {
+			if _, exists := r.shard(newID).get(newID); exists {
+				if _, moving := updated[newID]; !moving {
+					return 0, ErrItemAlreadyExists
+				}
+			}
+		}
+	}
+	for oldID := range updated {
+		r.drop(r.shard(oldID), oldID)
+	}
+	for _, item := range updated {
+		id := r.getID(item)
+		r.put(r.shard(id), id, item)
+	}
+
+	return int64(len(updated)), nil
+}
+
+// DeleteWhere deletes every item matching the filter conditions
+func (r *InMemoryConnector[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	if err := validateBulkFilter(filter); err != nil {
+		return 0, err
+	}
+	filter, err := r.resolveSubqueries(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	defer r.lockAll()()
+
+	matches := r.liveMatcher(filter)
+	var deleted []ID
+	for id, item := range r.entries() {
+		if matches(item) {
+			deleted = append(deleted, id)
+		}
+	}
+	for _, id := range deleted {
+		s := r.shard(id)
+		r.remove(s, id, s.data[id])
+	}
+	return int64(len(deleted)), nil
+}
+
+// Clear removes every item, soft-deleted ones included
+func (r *InMemoryConnector[T, ID]) Clear(_ context.Context) error {
+	defer r.lockAll()()
+	r.reset()
+	return nil
+}
+
+// assignFieldValue sets a struct field from an update value, normalizing
+// converter-backed types and converting between numeric kinds. nil resets
+// pointer, slice, map and interface fields.
+func assignFieldValue(field reflect.Value, value any) error {
+	if value == nil {
+		switch field.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		return fmt.Errorf("cannot assign nil to %s", field.Type())
+	}
+
+	if c, ok := DefaultConverters.Lookup(field.Type()); ok {
+		normalized, err := c.Normalize(value)
+		if err != nil {
+			return err
+		}
+		value = normalized
+	}
+
+	v := reflect.ValueOf(value)
+	if field.Kind() == reflect.Ptr && v.Kind() != reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := assignFieldValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	switch {
+	case v.Type().AssignableTo(field.Type()):
+		field.Set(v)
+	case isNumericKind(v.Kind()) && isNumericKind(field.Kind()),
+		v.Kind() == reflect.String && field.Kind() == reflect.String:
+		field.Set(v.Convert(field.Type()))
+	default:
+		return fmt.Errorf("cannot assign %T to %s", value, field.Type())
 	}
 	return nil
 }
@@ -214,6 +507,10 @@ func matchesSingleCondition(item any, condition Condition) bool {
 }
 
 func matchesLeafCondition(item any, condition Condition) bool {
+	if condition.Operator == OpExists {
+		return matchesExists(item, condition.Value)
+	}
+
 	v := reflect.ValueOf(item)
 	if v.Kind() == reflect.Ptr {
 		v = v.Elem()
@@ -222,19 +519,52 @@ func matchesLeafCondition(item any, condition Condition) bool {
 		return false
 	}
 
-	fieldVal := v.FieldByName(strings.ToTitle(string(condition.Field[0])) + condition.Field[1:])
-	if !fieldVal.IsValid() {
+	path, ok := columnPath(v.Type(), condition.Field)
+	if !ok {
 		// field doesn't exist
 		return false
 	}
+	fieldVal, ok := fieldByIndex(v, path)
+	if !ok {
+		// in a nil embedded pointer
+		return condition.Operator == OpIsNull
+	}
+
+	return matchesFieldValue(fieldVal, condition)
+}
 
+// matchesFieldValue evaluates a leaf condition against a resolved field value
+func matchesFieldValue(fieldVal reflect.Value, condition Condition) bool {
+	switch condition.Operator {
+	case OpIsNull:
+		return isNull(fieldVal)
+	case OpIsNotNull:
+		return !isNull(fieldVal)
+	case OpEqual, OpNotEqual:
+		if isNullValue(condition.Value) {
+			return isNull(fieldVal) == (condition.Operator == OpEqual)
+		}
+	case OpGreaterThan, OpLessThan, OpGreaterThanOrEqual, OpLessThanOrEqual:
+		if isNullValue(condition.Value) {
+			return false
+		}
+	}
+
+	// Like SQL NULL, a NULL field only satisfies the null checks. Pointer
+	// fields compare by the value they point to.
+	if isNullable(fieldVal.Type()) && isNull(fieldVal) {
+		return false
+	}
+	if fieldVal.Kind() == reflect.Ptr {
+		fieldVal = fieldVal.Elem()
+	}
 	valueInterface := fieldVal.Interface()
 
 	switch condition.Operator {
 	case OpEqual:
-		return reflect.DeepEqual(valueInterface, condition.Value)
+		return valuesEqual(valueInterface, condition.Value)
 	case OpNotEqual:
-		return !reflect.DeepEqual(valueInterface, condition.Value)
+		return !valuesEqual(valueInterface, condition.Value)
 	case OpGreaterThan:
 		return compare(valueInterface, condition.Value) > 0
 	case OpLessThan:
@@ -248,15 +578,31 @@ func matchesLeafCondition(item any, condition Condition) bool {
 	case OpNotIn:
 		return !inSlice(valueInterface, condition.Value)
 	case OpLike:
-		return matchesLike(valueInterface, condition.Value, false)
+		return matchesLike(textValue(valueInterface), condition.Value, false)
 	case OpILike:
-		return matchesLike(valueInterface, condition.Value, true)
-	case OpIsNull:
-		return fieldVal.IsZero()
-	case OpIsNotNull:
-		return !fieldVal.IsZero()
+		return matchesLike(textValue(valueInterface), condition.Value, true)
+	case OpRegex:
+		return matchesRegex(textValue(valueInterface), condition.Value, false)
+	case OpIRegex:
+		return matchesRegex(textValue(valueInterface), condition.Value, true)
 	case OpBetween:
 		return matchesBetween(valueInterface, condition.Value)
+	case OpJSONContains:
+		return matchesJSONContains(valueInterface, condition.Value)
+	case OpJSONPathExists:
+		return matchesJSONPathExists(valueInterface, condition.Value)
+	case OpJSONGet:
+		return matchesJSONGet(valueInterface, condition.Value)
+	case OpFullText:
+		return matchesFullText(valueInterface, condition.Value)
+	case OpArrayContains:
+		return arrayContainsAll(valueInterface, condition.Value)
+	case OpArrayContainedBy:
+		return arrayContainsAll(condition.Value, valueInterface)
+	case OpArrayOverlaps:
+		return arrayOverlaps(valueInterface, condition.Value)
+	case OpArrayAny:
+		return arrayOverlaps(valueInterface, []any{condition.Value})
 	default:
 		// unsupported operator
 		return false
@@ -295,6 +641,261 @@ func matchesCompositeCondition(item any, condition Condition) bool {
 	}
 }
 
+// GroupCount counts the items matching the filter per distinct combination of
+// the filter's GroupBy fields. Having conditions may reference CountField.
+func (r *InMemoryConnector[T, ID]) GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error) {
+	if filter == nil || len(filter.GroupBy) == 0 {
+		return nil, fmt.Errorf("GroupCount requires GROUP BY fields")
+	}
+	if filter.Distinct {
+		return nil, fmt.Errorf("GroupCount does not support DISTINCT")
+	}
+	filter, err := r.resolveSubqueries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	matches := r.matcher(ctx, filter)
+	var matched []T
+	for _, item := range r.all() {
+		if matches(item) {
+			matched = append(matched, *item)
+		}
+	}
+
+	groups, err := r.groupResults(matched, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]GroupCount, len(groups))
+	for i, g := range groups {
+		counts[i] = GroupCount{Key: g.key, Count: g.count}
+	}
+	return counts, nil
+}
+
+// itemGroup is one group of a grouped query
+type itemGroup[T any] struct {
+	item  T              // zero value with only the grouped fields set
+	key   map[string]any // grouped field -> value
+	count int64
+
+	sortKeys []sortKey // precomputed keys for the filter's sort fields
+}
+
+// groupResults groups items by the filter's GroupBy fields, then applies
+// Having, Sort, Offset and Limit to the groups.
+// Must be called with the lock held.
+func (r *InMemoryConnector[T, ID]) groupResults(items []T, filter *Filter) ([]*itemGroup[T], error) {
+	var zero T
+	typ := reflect.TypeOf(zero)
+	indexes := make([][]int, len(filter.GroupBy))
+	for i, field := range filter.GroupBy {
+		idx, ok := columnIndex(typ)[field]
+		if !ok {
+			return nil, fmt.Errorf("unknown field '%s' for grouping", field)
+		}
+		indexes[i] = idx
+	}
+	for _, sf := range filter.Sort {
+		if sf.Field != CountField && sf.Field != RandomField && !containsString(filter.GroupBy, sf.Field) {
+			return nil, fmt.Errorf("cannot sort by '%s': not in GROUP BY", sf.Field)
+		}
+	}
+
+	byKey := make(map[string]*itemGroup[T])
+	var groups []*itemGroup[T]
+	for _, item := range items {
+		v := reflect.ValueOf(item)
+		values := make([]any, len(indexes))
+		for i, idx := range indexes {
+			if field, ok := fieldByIndex(v, idx); ok {
+				values[i] = field.Interface()
+			}
+		}
+		k := fmt.Sprintf("%#v", values)
+
+		g, ok := byKey[k]
+		if !ok {
+			g = &itemGroup[T]{key: make(map[string]any, len(indexes))}
+			gv := reflect.ValueOf(&g.item).Elem()
+			for i, idx := range indexes {
+				if field, ok := fieldByIndex(v, idx); ok {
+					settableField(gv, idx).Set(field)
+				}
+				g.key[filter.GroupBy[i]] = values[i]
+			}
+			byKey[k] = g
+			groups = append(groups, g)
+		}
+		g.count++
+	}
+
+	// Apply HAVING
+	if len(filter.Having) > 0 {
+		var kept []*itemGroup[T]
+		for _, g := range groups {
+			if matchesGroupConditions(g.key, g.count, filter.Having) {
+				kept = append(kept, g)
+			}
+		}
+		groups = kept
+	}
+
+	// Apply sorting; groups sort by their key fields or their count
+	if len(filter.Sort) > 0 {
+		collators := r.collators(filter.Sort)
+		if hasRandomSort(filter.Sort) {
+			rand.Shuffle(len(groups), func(i, j int) { groups[i], groups[j] = groups[j], groups[i] })
+		}
+		var buf collate.Buffer
+		for _, g := range groups {
+			g.sortKeys = make([]sortKey, len(filter.Sort))
+			for j, sf := range filter.Sort {
+				switch sf.Field {
+				case RandomField: // ordered by the shuffle
+				case CountField:
+					g.sortKeys[j] = sortKey{value: g.count, num: float64(g.count), numeric: true}
+				default:
+					g.sortKeys[j] = newSortKey(reflect.ValueOf(g.key[sf.Field]), sf, collators[sf.Field], &buf)
+				}
+			}
+		}
+		sortSlice(groups, func(a, b **itemGroup[T]) bool {
+			return lessSortKeys((*a).sortKeys, (*b).sortKeys, filter.Sort)
+		})
+	}
+
+	// Apply OFFSET and LIMIT
+	if filter.Offset != nil && *filter.Offset > 0 {
+		if *filter.Offset >= len(groups) {
+			return nil, nil
+		}
+		groups = groups[*filter.Offset:]
+	}
+	if filter.Limit != nil && *filter.Limit > 0 && *filter.Limit < len(groups) {
+		groups = groups[:*filter.Limit]
+	}
+
+	return groups, nil
+}
+
+// matchesGroupConditions evaluates HAVING conditions (ANDed) against a group
+func matchesGroupConditions(key map[string]any, count int64, conditions []Condition) bool {
+	for _, condition := range conditions {
+		if !matchesGroupCondition(key, count, condition) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesGroupCondition(key map[string]any, count int64, condition Condition) bool {
+	if condition.IsComposite() {
+		switch condition.LogicalOp {
+		case LogicalAND:
+			return matchesGroupConditions(key, count, condition.Conditions)
+		case LogicalOR:
+			for _, nested := range condition.Conditions {
+				if matchesGroupCondition(key, count, nested) {
+					return true
+				}
+			}
+			return false
+		case LogicalNOT:
+			return len(condition.Conditions) == 1 && !matchesGroupCondition(key, count, condition.Conditions[0])
+		default:
+			return false
+		}
+	}
+
+	if condition.Field == CountField {
+		return matchesFieldValue(reflect.ValueOf(count), condition)
+	}
+	value, ok := key[condition.Field]
+	if !ok {
+		return false
+	}
+	if value == nil {
+		return condition.Operator == OpIsNull
+	}
+	return matchesFieldValue(reflect.ValueOf(value), condition)
+}
+
+// columnIndexCache caches the column name to field index path mapping per struct type
+var columnIndexCache sync.Map // map[reflect.Type]map[string][]int
+
+// columnIndex returns the column name to field index path mapping for a
+// struct type. Fields are indexed by their db tag, so filters use the same
+// names as the SQL connectors, including the columns of embedded and
+// prefixed structs (see dbFields). Go field names, promoted ones included,
+// are indexed too (without overriding tags), so untagged structs and
+// filters written against field names keep working.
+func columnIndex(t reflect.Type) map[string][]int {
+	if cached, ok := columnIndexCache.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+
+	index := make(map[string][]int, t.NumField()*2)
+	for _, f := range dbFields(t) {
+		index[f.column] = f.index
+	}
+	for _, f := range reflect.VisibleFields(t) {
+		if _, exists := index[f.Name]; !exists {
+			index[f.Name] = f.Index
+		}
+	}
+
+	cached, _ := columnIndexCache.LoadOrStore(t, index)
+	return cached.(map[string][]int)
+}
+
+// columnPath resolves a filter field name to the index path of its struct
+// field. Lookup order: db tag, Go field name, then Go field name with the
+// first letter uppercased.
+func columnPath(t reflect.Type, column string) ([]int, bool) {
+	if column == "" {
+		return nil, false
+	}
+	index := columnIndex(t)
+	if path, ok := index[column]; ok {
+		return path, true
+	}
+	path, ok := index[strings.ToUpper(column[:1])+column[1:]]
+	return path, ok
+}
+
+// readonlyFieldIndexes returns the struct field index paths of the readonly
+// and generated columns of typ, which updates never write
+func readonlyFieldIndexes(typ reflect.Type) [][]int {
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	var paths [][]int
+	for _, f := range dbFields(typ) {
+		if slices.Contains(f.options, "readonly") || slices.Contains(f.options, "generated") {
+			paths = append(paths, f.index)
+		}
+	}
+	return paths
+}
+
+// fieldByColumn resolves a filter field name against a struct value (see
+// columnPath). The value is invalid when the field is unknown or sits in a
+// nil embedded pointer.
+func fieldByColumn(v reflect.Value, column string) reflect.Value {
+	path, ok := columnPath(v.Type(), column)
+	if !ok {
+		return reflect.Value{}
+	}
+	field, _ := fieldByIndex(v, path)
+	return field
+}
+
 // inSlice checks if value is in the slice
 func inSlice(value any, sliceValue any) bool {
 	slice := reflect.ValueOf(sliceValue)
@@ -303,13 +904,31 @@ func inSlice(value any, sliceValue any) bool {
 	}
 
 	for i := 0; i < slice.Len(); i++ {
-		if reflect.DeepEqual(value, slice.Index(i).Interface()) {
+		if valuesEqual(value, slice.Index(i).Interface()) {
 			return true
 		}
 	}
 	return false
 }
 
+// textValue returns the string a pattern is matched against: v itself, or
+// the key of a type compared by one, e.g. the string of a sql.NullString
+func textValue(v any) any {
+	if key, ok := DefaultConverters.compareKeyOf(v); ok {
+		return key
+	}
+	return v
+}
+
+// valuesEqual reports whether a field value equals a condition value, using
+// the field type's converter when one is registered
+func valuesEqual(a, b any) bool {
+	if c, ok := DefaultConverters.compareConverted(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
 // matchesLike checks if string matches LIKE pattern
 func matchesLike(value any, pattern any, caseInsensitive bool) bool {
 	strVal, ok := value.(string)
@@ -330,6 +949,77 @@ func matchesLike(value any, pattern any, caseInsensitive bool) bool {
 	return matchLikePattern(strVal, patternStr)
 }
 
+// regexCacheSize is the number of compiled patterns kept by regexCache
+const regexCacheSize = 1000
+
+// regexCache holds the most recently used compiled patterns, keyed by the
+// pattern as passed to regexp.Compile. It is bounded, so filters built from
+// user input cannot grow it without limit.
+var regexCache = &patternCache{entries: make(map[string]*list.Element)}
+
+// patternCache is an LRU map of compiled patterns
+type patternCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *patternEntry
+	lru     list.List                // most recently used first
+}
+
+type patternEntry struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// compile returns the compiled pattern, compiling and caching it on a miss
+func (c *patternCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[pattern]; ok {
+		c.lru.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*patternEntry).re, nil
+	}
+	c.mu.Unlock()
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[pattern]; ok {
+		c.lru.MoveToFront(el)
+		return re, nil
+	}
+	c.entries[pattern] = c.lru.PushFront(&patternEntry{pattern: pattern, re: re})
+	for c.lru.Len() > regexCacheSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*patternEntry).pattern)
+	}
+	return re, nil
+}
+
+// matchesRegex mirrors CockroachDB's ~ and ~* operators; invalid patterns match nothing
+func matchesRegex(value any, pattern any, caseInsensitive bool) bool {
+	strVal, ok := value.(string)
+	if !ok {
+		return false
+	}
+	patternStr, ok := pattern.(string)
+	if !ok {
+		return false
+	}
+
+	if caseInsensitive {
+		patternStr = "(?i)" + patternStr
+	}
+	re, err := regexCache.compile(patternStr)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(strVal)
+}
+
 func matchLikePattern(str, pattern string) bool {
 	if pattern == "%" {
 		return true
@@ -365,53 +1055,546 @@ func matchesBetween(value any, betweenValue any) bool {
 	return compare(value, min) >= 0 && compare(value, max) <= 0
 }
 
-// sortResults sorts the results based on sort fields
-func sortResults[T any](results []T, sortFields []SortField) []T {
-	if len(sortFields) == 0 {
-		return results
+// sliceElements returns the elements of a slice or array value, including
+// pgtype arrays (flattened in row-major order)
+func sliceElements(value any) ([]any, bool) {
+	v := reflect.ValueOf(value)
+	if arr, ok := value.(pgtype.ArrayGetter); ok && v.Kind() == reflect.Struct {
+		// pgtype.Array; pgtype.FlatArray is a plain slice
+		n := 0
+		if dims := arr.Dimensions(); len(dims) > 0 {
+			n = 1
+			for _, d := range dims {
+				n *= int(d.Length)
+			}
+		}
+		elems := make([]any, n)
+		for i := range elems {
+			elems[i] = arr.Index(i)
+		}
+		return elems, true
 	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, false
+	}
+	elems := make([]any, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		elems[i] = v.Index(i).Interface()
+	}
+	return elems, true
+}
 
-	// Create a copy to avoid modifying original
-	sorted := make([]T, len(results))
-	copy(sorted, results)
-
-	// Sort using multi-field comparison
-	sortSlice(sorted, func(a, b *T) bool {
-		for _, sf := range sortFields {
-			va := reflect.ValueOf(a).Elem()
-			vb := reflect.ValueOf(b).Elem()
+// elementsEqual compares array elements, treating numbers of different types by value
+func elementsEqual(a, b any) bool {
+	if af, ok := toFloat64(a); ok {
+		bf, ok := toFloat64(b)
+		return ok && af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
 
-			fieldA := va.FieldByName(strings.ToTitle(string(sf.Field[0])) + sf.Field[1:])
-			fieldB := vb.FieldByName(strings.ToTitle(string(sf.Field[0])) + sf.Field[1:])
+// arrayContainsAll reports whether every element of subset appears in superset (SQL @>)
+func arrayContainsAll(superset, subset any) bool {
+	have, ok := sliceElements(superset)
+	if !ok {
+		return false
+	}
+	want, ok := sliceElements(subset)
+	if !ok {
+		return false
+	}
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if elementsEqual(h, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
 
-			if !fieldA.IsValid() || !fieldB.IsValid() {
-				continue
+// arrayOverlaps reports whether two arrays share at least one element (SQL &&)
+func arrayOverlaps(a, b any) bool {
+	left, ok := sliceElements(a)
+	if !ok {
+		return false
+	}
+	right, ok := sliceElements(b)
+	if !ok {
+		return false
+	}
+	for _, l := range left {
+		for _, r := range right {
+			if elementsEqual(l, r) {
+				return true
 			}
+		}
+	}
+	return false
+}
+
+// matchesFullText approximates plainto_tsquery: every query token must appear
+// as a token of the field, compared case-insensitively. Stemming is not applied.
+func matchesFullText(value any, query any) bool {
+	text, ok := value.(string)
+	if !ok {
+		return false
+	}
+	fts, err := toFullTextQuery(query)
+	if err != nil {
+		return false
+	}
+
+	tokens := make(map[string]bool)
+	for _, tok := range tokenize(text) {
+		tokens[tok] = true
+	}
+	for _, tok := range tokenize(fts.Query) {
+		if !tokens[tok] {
+			return false
+		}
+	}
+	return true
+}
+
+// tokenize splits text into lower-cased alphanumeric tokens
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// normalizeJSON converts a field value into its decoded JSON form (maps, slices, float64, string, bool).
+// Strings, []byte and json.RawMessage are parsed as JSON documents; other values are round-tripped.
+func normalizeJSON(value any) (any, bool) {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case json.RawMessage:
+		data = v
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, false
+		}
+		data = encoded
+	}
+
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, false
+	}
+	return decoded, true
+}
+
+// matchesJSONContains mimics the JSONB @> operator
+func matchesJSONContains(value any, document any) bool {
+	container, ok := normalizeJSON(value)
+	if !ok {
+		return false
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return false
+	}
+	var contained any
+	if err := json.Unmarshal(encoded, &contained); err != nil {
+		return false
+	}
+	return jsonContains(container, contained)
+}
 
-			cmp := compare(fieldA.Interface(), fieldB.Interface())
-			if cmp != 0 {
-				if sf.Direction == SortAsc {
-					return cmp < 0
+func jsonContains(container, contained any) bool {
+	switch c := contained.(type) {
+	case map[string]any:
+		obj, ok := container.(map[string]any)
+		if !ok {
+			return false
+		}
+		for key, val := range c {
+			inner, exists := obj[key]
+			if !exists || !jsonContains(inner, val) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		arr, ok := container.([]any)
+		if !ok {
+			return false
+		}
+		for _, want := range c {
+			found := false
+			for _, have := range arr {
+				if jsonContains(have, want) {
+					found = true
+					break
 				}
-				return cmp > 0
 			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	default:
+		if arr, ok := container.([]any); ok {
+			// A top-level array contains a matching scalar element
+			for _, have := range arr {
+				if reflect.DeepEqual(have, contained) {
+					return true
+				}
+			}
+			return false
+		}
+		return reflect.DeepEqual(container, contained)
+	}
+}
+
+// jsonLookup walks path through a decoded JSON document
+func jsonLookup(doc any, path []string) (any, bool) {
+	current := doc
+	for _, key := range path {
+		switch node := current.(type) {
+		case map[string]any:
+			next, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []any:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			current = node[idx]
+		default:
+			return nil, false
 		}
+	}
+	return current, true
+}
+
+// matchesJSONPathExists mimics (field #> path) IS NOT NULL
+func matchesJSONPathExists(value any, pathValue any) bool {
+	path, err := toJSONPath(pathValue)
+	if err != nil {
+		return false
+	}
+	doc, ok := normalizeJSON(value)
+	if !ok {
+		return false
+	}
+	found, ok := jsonLookup(doc, path)
+	return ok && found != nil
+}
+
+// matchesJSONGet compares the value at a JSON path
+func matchesJSONGet(value any, condValue any) bool {
+	jp, ok := condValue.(JSONPath)
+	if !ok || len(jp.Path) == 0 {
+		return false
+	}
+	doc, ok := normalizeJSON(value)
+	if !ok {
+		return false
+	}
+	extracted, ok := jsonLookup(doc, jp.Path)
+	if !ok || extracted == nil {
+		return false
+	}
+
+	op := jp.Operator
+	if op == "" {
+		op = OpEqual
+	}
+
+	switch op {
+	case OpEqual:
+		return compare(extracted, jp.Value) == 0 && jsonComparable(extracted, jp.Value)
+	case OpNotEqual:
+		return !(compare(extracted, jp.Value) == 0 && jsonComparable(extracted, jp.Value))
+	case OpGreaterThan:
+		return compare(extracted, jp.Value) > 0
+	case OpLessThan:
+		return compare(extracted, jp.Value) < 0
+	case OpGreaterThanOrEqual:
+		return compare(extracted, jp.Value) >= 0
+	case OpLessThanOrEqual:
+		return compare(extracted, jp.Value) <= 0
+	case OpLike:
+		return matchesLike(extracted, jp.Value, false)
+	case OpILike:
+		return matchesLike(extracted, jp.Value, true)
+	case OpRegex:
+		return matchesRegex(extracted, jp.Value, false)
+	case OpIRegex:
+		return matchesRegex(extracted, jp.Value, true)
+	default:
 		return false
+	}
+}
+
+// jsonComparable reports whether compare() returning 0 means actual equality
+func jsonComparable(a, b any) bool {
+	if _, okA := toFloat64(a); okA {
+		_, okB := toFloat64(b)
+		return okB
+	}
+	if _, okA := a.(string); okA {
+		_, okB := b.(string)
+		return okB
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// SetCollation makes ORDER BY on a string field use the collation rules of the
+// given locale instead of byte order, matching a SQL column declared with
+// COLLATE. Options such as collate.IgnoreCase or collate.Numeric are passed through.
+//
+// Example:
+//
+//	repo.SetCollation("name", language.German)
+func (r *InMemoryConnector[T, ID]) SetCollation(field string, tag language.Tag, opts ...collate.Option) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.collations == nil {
+		r.collations = make(map[string]collation)
+	}
+	r.collations[field] = collation{tag: tag, opts: opts}
+}
+
+// SetDefaultCollation sets the collation used for every string sort field
+// without a per-field collation
+func (r *InMemoryConnector[T, ID]) SetDefaultCollation(tag language.Tag, opts ...collate.Option) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.defaultCollation = &collation{tag: tag, opts: opts}
+}
+
+// collators returns the collators for the given sort fields, keyed by field.
+// Must be called with the lock held.
+func (r *InMemoryConnector[T, ID]) collators(sortFields []SortField) map[string]*collate.Collator {
+	if len(r.collations) == 0 && r.defaultCollation == nil {
+		return nil
+	}
+
+	collators := make(map[string]*collate.Collator, len(sortFields))
+	for _, sf := range sortFields {
+		if c, ok := r.collations[sf.Field]; ok {
+			collators[sf.Field] = c.newCollator()
+		} else if r.defaultCollation != nil {
+			collators[sf.Field] = r.defaultCollation.newCollator()
+		}
+	}
+	return collators
+}
+
+// sortResults sorts the results based on sort fields. String fields with a
+// collator are ordered by it; everything else uses compare. Sort keys are
+// computed once per row, so the comparator does no reflection.
+func sortResults[T any](results []T, sortFields []SortField, collators map[string]*collate.Collator) []T {
+	if len(sortFields) == 0 {
+		return results
+	}
+
+	// Pair each row with its keys so both move together while sorting
+	type keyedRow struct {
+		item T
+		keys []sortKey
+	}
+	var buf collate.Buffer
+	rows := make([]keyedRow, len(results))
+	for i, item := range results {
+		v := reflect.ValueOf(&item).Elem()
+		keys := make([]sortKey, len(sortFields))
+		for j, sf := range sortFields {
+			if sf.Field == RandomField {
+				break
+			}
+			path, ok := columnPath(v.Type(), sf.Field)
+			if !ok {
+				keys[j] = sortKey{skip: true}
+				continue
+			}
+			field, ok := fieldByIndex(v, path)
+			if !ok {
+				keys[j] = sortKey{null: true} // in a nil embedded pointer
+				continue
+			}
+			keys[j] = newSortKey(field, sf, collators[sf.Field], &buf)
+		}
+		rows[i] = keyedRow{item: item, keys: keys}
+	}
+
+	// Random ordering: shuffle, then let the stable sort keep the shuffled
+	// order among rows that tie on the fields before RandomField
+	if hasRandomSort(sortFields) {
+		rand.Shuffle(len(rows), func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+	}
+
+	sortSlice(rows, func(a, b *keyedRow) bool {
+		return lessSortKeys(a.keys, b.keys, sortFields)
 	})
 
+	sorted := make([]T, len(rows))
+	for i, row := range rows {
+		sorted[i] = row.item
+	}
 	return sorted
 }
 
-// sortSlice is a generic sort implementation
-func sortSlice[T any](slice []T, less func(a, b *T) bool) {
-	n := len(slice)
-	for i := 0; i < n-1; i++ {
-		for j := 0; j < n-i-1; j++ {
-			if less(&slice[j+1], &slice[j]) {
-				slice[j], slice[j+1] = slice[j+1], slice[j]
+// sortKey is a sort value precomputed for one row and sort field
+type sortKey struct {
+	skip     bool // the field does not exist, so it doesn't take part in the ordering
+	null     bool
+	conv     Converter // set when value was normalized by a registered converter
+	value    any
+	num      float64
+	numeric  bool
+	str      string // the string value, or its collation key when collated
+	isString bool
+	collated bool
+}
+
+// newSortKey precomputes the sort key of v, dereferencing pointers and
+// resolving converters and collation keys up front
+func newSortKey(v reflect.Value, sf SortField, c *collate.Collator, buf *collate.Buffer) sortKey {
+	if isNull(v) {
+		return sortKey{null: true}
+	}
+
+	v = reflect.Indirect(v)
+	key := sortKey{value: v.Interface()}
+	if c != nil && v.Kind() == reflect.String {
+		key.str, key.isString, key.collated = string(c.KeyFromString(buf, v.String())), true, true
+		return key
+	}
+	if conv, ok := DefaultConverters.Lookup(v.Type()); ok {
+		if normalized, err := conv.Normalize(key.value); err == nil {
+			key.conv, key.value = conv, normalized
+			return key
+		}
+	}
+	if k, ok := DefaultConverters.compareKeyOf(key.value); ok {
+		key.value = k
+	}
+	key.num, key.numeric = toFloat64(key.value)
+	key.str, key.isString = key.value.(string)
+	return key
+}
+
+// compareSortKeys compares two non-NULL sort keys. Strings are ordered by
+// their collation key when one was computed.
+func compareSortKeys(a, b sortKey) int {
+	switch {
+	case a.conv != nil && b.conv != nil:
+		return a.conv.Compare(a.value, b.value)
+	case a.numeric && b.numeric:
+		if a.num < b.num {
+			return -1
+		} else if a.num > b.num {
+			return 1
+		}
+		return 0
+	case a.isString && b.isString && a.collated == b.collated:
+		return strings.Compare(a.str, b.str)
+	}
+	return compare(a.value, b.value)
+}
+
+// lessSortKeys reports whether the row with keys a sorts before the row with
+// keys b. Fields after RandomField are ignored. By default NULLs sort first
+// for ASC and last for DESC, as in CockroachDB.
+func lessSortKeys(a, b []sortKey, sortFields []SortField) bool {
+	for i, sf := range sortFields {
+		if sf.Field == RandomField {
+			return false
+		}
+		ka, kb := a[i], b[i]
+		if ka.skip || kb.skip {
+			continue
+		}
+
+		if ka.null || kb.null {
+			if ka.null && kb.null {
+				continue
+			}
+			nullsFirst := sf.Nulls == NullsFirst || (sf.Nulls == NullsDefault && sf.Direction != SortDesc)
+			return ka.null == nullsFirst
+		}
+
+		if cmp := compareSortKeys(ka, kb); cmp != 0 {
+			if sf.Direction == SortAsc {
+				return cmp < 0
 			}
+			return cmp > 0
 		}
 	}
+	return false
+}
+
+// hasRandomSort reports whether the sort fields include RandomField
+func hasRandomSort(sortFields []SortField) bool {
+	for _, sf := range sortFields {
+		if sf.Field == RandomField {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleSize returns n for filters built with Sample(n): random order is the
+// only sort, a limit is set and nothing else depends on the full result set
+func sampleSize(filter *Filter) (int, bool) {
+	if filter == nil || len(filter.Sort) != 1 || filter.Sort[0].Field != RandomField {
+		return 0, false
+	}
+	if filter.Limit == nil || *filter.Limit <= 0 || (filter.Offset != nil && *filter.Offset > 0) {
+		return 0, false
+	}
+	if len(filter.GroupBy) > 0 || filter.Distinct {
+		return 0, false
+	}
+	return *filter.Limit, true
+}
+
+// reservoirSample picks up to n matching items uniformly at random in a single
+// pass (Algorithm R), without collecting every match first. size is the
+// number of items, which bounds the sample however large n is.
+func reservoirSample[T any, ID comparable](items iter.Seq2[ID, *T], matches func(*T) bool, n, size int) []T {
+	sample := make([]T, 0, min(n, size))
+	seen := 0
+	for _, item := range items {
+		if !matches(item) {
+			continue
+		}
+		seen++
+		if len(sample) < n {
+			sample = append(sample, *item)
+		} else if j := rand.IntN(seen); j < n {
+			sample[j] = *item
+		}
+	}
+	return sample
+}
+
+// sortSlice sorts slice in place, keeping the original order of equal elements
+func sortSlice[T any](slice []T, less func(a, b *T) bool) {
+	sort.SliceStable(slice, func(i, j int) bool {
+		return less(&slice[i], &slice[j])
+	})
 }
 
 // distinctResults removes duplicate items
@@ -436,29 +1619,12 @@ func distinctResults[T any](results []T) []T {
 }
 
 func compare(a, b any) int {
-	af, okA := toFloat64(a)
-	bf, okB := toFloat64(b)
-	if okA && okB {
-		if af < bf {
-			return -1
-		} else if af > bf {
-			return 1
-		}
-		return 0
+	if c, ok := DefaultConverters.compareConverted(a, b); ok {
+		return c
 	}
-
-	// if they are not numeric, we try to compare them as strings
-	as, okA := a.(string)
-	bs, okB := b.(string)
-	if okA && okB {
-		if as < bs {
-			return -1
-		} else if as > bs {
-			return 1
-		}
-		return 0
+	if c, ok := compareKeys(a, b); ok {
+		return c
 	}
-
 	return 0 // fallback
 }
 
@@ -494,12 +1660,13 @@ func toFloat64(v any) (float64, bool) {
 }
 
 // Exists checks if an entity with the given ID exists
-func (r *InMemoryConnector[T, ID]) Exists(_ context.Context, id ID) (bool, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+func (r *InMemoryConnector[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	s := r.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	_, exists := r.data[id]
-	return exists, nil
+	item, exists := s.get(id)
+	return exists && !r.hidden(ctx, item), nil
 }
 
 // Upsert creates a new entity or updates an existing one
@@ -508,26 +1675,60 @@ func (r *InMemoryConnector[T, ID]) Upsert(_ context.Context, item *T) error {
 		return fmt.Errorf("item cannot be nil")
 	}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	id := r.getID(item)
-	r.data[id] = item
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, exists := s.get(id); exists && r.version != nil {
+		if r.versionOf(stored) != r.versionOf(item) {
+			return ErrVersionConflict
+		}
+		item = r.nextVersion(item)
+	}
+
+	r.put(s, id, item)
 	return nil
 }
 
-// BatchUpsert creates or updates multiple entities
+// BatchUpsert creates or updates multiple entities. For versioned entities
+// nothing is written if some existing items have a different version; a
+// VersionConflictError lists them.
 func (r *InMemoryConnector[T, ID]) BatchUpsert(_ context.Context, items []T) error {
 	if len(items) == 0 {
 		return nil
 	}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	defer r.lockShards(itemsByID(items, r.getID))()
 
-	for _, item := range items {
+	// Every item is checked against the stored one, or the one an earlier
+	// item of the batch writes, before anything is written
+	writes := make([]*T, len(items))
+	pending := make(map[ID]*T, len(items))
+	var conflicts []ID
+	for i, item := range items {
 		id := r.getID(&item)
-		r.data[id] = &item
+		current, exists := pending[id]
+		if !exists {
+			current, exists = r.shard(id).get(id)
+		}
+		writes[i] = &item
+		if exists && r.version != nil {
+			if r.versionOf(current) != r.versionOf(&item) {
+				conflicts = append(conflicts, id)
+				continue
+			}
+			writes[i] = r.nextVersion(&item)
+		}
+		pending[id] = writes[i]
+	}
+	if len(conflicts) > 0 {
+		return &VersionConflictError[ID]{IDs: conflicts}
+	}
+
+	for i := range items {
+		id := r.getID(writes[i])
+		r.put(r.shard(id), id, writes[i])
 	}
 	return nil
 }
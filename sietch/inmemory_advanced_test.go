@@ -118,8 +118,8 @@ func TestInMemoryAdvancedOperators(t *testing.T) {
 			t.Fatalf("Query failed: %v", err)
 		}
 
-		if len(results) != 1 {
-			t.Errorf("Expected 1 result (empty string is zero value), got %d", len(results))
+		if len(results) != 0 {
+			t.Errorf("Expected no result (a string field can't hold NULL), got %d", len(results))
 		}
 	})
 
@@ -148,8 +148,8 @@ func TestInMemoryAdvancedOperators(t *testing.T) {
 			t.Fatalf("Query failed: %v", err)
 		}
 
-		if len(results) != 1 {
-			t.Errorf("Expected 1 result (non-empty string), got %d", len(results))
+		if len(results) != 2 {
+			t.Errorf("Expected 2 results (the empty string is not NULL), got %d", len(results))
 		}
 	})
 }
@@ -216,6 +216,60 @@ func TestInMemorySorting(t *testing.T) {
 			t.Errorf("Results not sorted correctly: %v", results)
 		}
 	})
+
+	t.Run("Multi-field sort over many rows", func(t *testing.T) {
+		repo := newSortBenchRepo(ctx, 5000)
+
+		filter := NewFilter().
+			OrderBy("balance", SortDesc).
+			OrderBy("id", SortAsc).
+			Build()
+
+		results, err := repo.Query(ctx, filter)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		if len(results) != 5000 {
+			t.Fatalf("Expected 5000 results, got %d", len(results))
+		}
+		for i := 1; i < len(results); i++ {
+			prev, cur := results[i-1], results[i]
+			if prev.Balance < cur.Balance || (prev.Balance == cur.Balance && prev.ID > cur.ID) {
+				t.Fatalf("Results not sorted correctly at %d: %v before %v", i, prev, cur)
+			}
+		}
+	})
+}
+
+// newSortBenchRepo creates n accounts with balances repeating every 100 ids
+func newSortBenchRepo(ctx context.Context, n int) *InMemoryConnector[testutils.Account, int64] {
+	repo := NewInMemoryConnector[testutils.Account, int64](
+		func(a *testutils.Account) int64 { return a.ID },
+	)
+	accounts := make([]testutils.Account, n)
+	for i := range accounts {
+		accounts[i] = testutils.Account{ID: int64(i), Balance: (i * 37) % 100}
+	}
+	repo.BatchCreate(ctx, accounts)
+	return repo
+}
+
+func BenchmarkInMemoryQuery_Sort(b *testing.B) {
+	ctx := context.Background()
+	repo := newSortBenchRepo(ctx, 100000)
+	filter := NewFilter().
+		OrderBy("balance", SortDesc).
+		OrderBy("id", SortAsc).
+		Build()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Query(ctx, filter); err != nil {
+			b.Fatal(err)
+		}
+	}
 }
 
 func TestInMemoryPagination(t *testing.T) {
@@ -0,0 +1,332 @@
+package sietch
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// predicate reports whether a struct value matches compiled conditions
+type predicate func(v reflect.Value) bool
+
+// matchNone is the predicate of conditions that can never match
+var matchNone predicate = func(reflect.Value) bool { return false }
+
+// compileFilter turns the conditions of filter into a function over *T,
+// resolving fields and choosing the comparison once instead of per item.
+// It evaluates exactly like matchesCondition.
+func compileFilter[T any](filter *Filter) func(*T) bool {
+	if filter == nil || len(filter.Conditions) == 0 {
+		return func(*T) bool { return true }
+	}
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	match := compileConditions(typ, filter.Conditions, LogicalAND)
+	return func(item *T) bool {
+		return match(reflect.ValueOf(item).Elem())
+	}
+}
+
+// compileConditions combines the predicates of conditions with op
+func compileConditions(typ reflect.Type, conditions []Condition, op LogicalOperator) predicate {
+	preds := make([]predicate, len(conditions))
+	for i, c := range conditions {
+		preds[i] = compileCondition(typ, c)
+	}
+	if len(preds) == 1 && op != LogicalNOT {
+		return preds[0]
+	}
+
+	switch op {
+	case LogicalAND:
+		return func(v reflect.Value) bool {
+			for _, p := range preds {
+				if !p(v) {
+					return false
+				}
+			}
+			return true
+		}
+	case LogicalOR:
+		return func(v reflect.Value) bool {
+			for _, p := range preds {
+				if p(v) {
+					return true
+				}
+			}
+			return false
+		}
+	case LogicalNOT:
+		if len(preds) != 1 {
+			return matchNone
+		}
+		return func(v reflect.Value) bool { return !preds[0](v) }
+	default:
+		return matchNone
+	}
+}
+
+func compileCondition(typ reflect.Type, condition Condition) predicate {
+	if condition.IsComposite() {
+		return compileConditions(typ, condition.Conditions, condition.LogicalOp)
+	}
+	if condition.Operator == OpExists {
+		return func(v reflect.Value) bool {
+			return matchesExists(v.Addr().Interface(), condition.Value)
+		}
+	}
+	if typ.Kind() != reflect.Struct {
+		return matchNone
+	}
+
+	idx, ok := columnPath(typ, condition.Field)
+	if !ok {
+		return matchNone
+	}
+	field := fieldGetter(typ, idx)
+	if field == nil {
+		// the field may sit in a nil embedded pointer, i.e. be NULL
+		return func(v reflect.Value) bool {
+			f, ok := fieldByIndex(v, idx)
+			if !ok {
+				return condition.Operator == OpIsNull
+			}
+			return matchesFieldValue(f, condition)
+		}
+	}
+	if match := compileFastPath(typ.FieldByIndex(idx).Type, field, condition); match != nil {
+		return match
+	}
+	return func(v reflect.Value) bool {
+		return matchesFieldValue(field(v), condition)
+	}
+}
+
+// fieldGetter returns the accessor of the field at index path idx of typ,
+// or nil when the path goes through an embedded pointer, which may be nil
+func fieldGetter(typ reflect.Type, idx []int) func(v reflect.Value) reflect.Value {
+	if len(idx) == 1 {
+		i := idx[0]
+		return func(v reflect.Value) reflect.Value { return v.Field(i) }
+	}
+	for _, i := range idx[:len(idx)-1] {
+		typ = typ.Field(i).Type
+		if typ.Kind() == reflect.Pointer {
+			return nil
+		}
+	}
+	return func(v reflect.Value) reflect.Value { return v.FieldByIndex(idx) }
+}
+
+// compileFastPath returns a specialized predicate for common comparisons on
+// plain scalar fields, or nil when the generic path must be used. Fields with
+// a registered converter or compare key, pointer fields and other kinds
+// always use it.
+func compileFastPath(fieldType reflect.Type, field func(reflect.Value) reflect.Value, condition Condition) predicate {
+	if _, ok := DefaultConverters.Lookup(fieldType); ok {
+		return nil
+	}
+	if DefaultConverters.hasCompareKey(fieldType) {
+		return nil
+	}
+
+	switch condition.Operator {
+	case OpEqual, OpNotEqual:
+		// valuesEqual falls back to reflect.DeepEqual, which requires identical types
+		if reflect.TypeOf(condition.Value) != fieldType || !isScalarKind(fieldType.Kind()) {
+			return nil
+		}
+		want := reflect.ValueOf(condition.Value)
+		eq := scalarEqual(fieldType.Kind(), want)
+		if condition.Operator == OpNotEqual {
+			return func(v reflect.Value) bool { return !eq(field(v)) }
+		}
+		return func(v reflect.Value) bool { return eq(field(v)) }
+
+	case OpGreaterThan, OpLessThan, OpGreaterThanOrEqual, OpLessThanOrEqual:
+		cmp := scalarCompare(fieldType, condition.Value)
+		if cmp == nil {
+			return nil
+		}
+		test := compareTest(condition.Operator)
+		return func(v reflect.Value) bool { return test(cmp(field(v))) }
+
+	case OpLike, OpILike:
+		// matchesLike only accepts values of type string
+		pattern, ok := condition.Value.(string)
+		if fieldType != reflect.TypeOf("") || !ok {
+			return nil
+		}
+		if condition.Operator == OpILike {
+			pattern = strings.ToLower(pattern)
+			return func(v reflect.Value) bool {
+				return matchLikePattern(strings.ToLower(field(v).String()), pattern)
+			}
+		}
+		return func(v reflect.Value) bool { return matchLikePattern(field(v).String(), pattern) }
+
+	case OpRegex, OpIRegex:
+		pattern, ok := condition.Value.(string)
+		if fieldType != reflect.TypeOf("") || !ok {
+			return nil
+		}
+		if condition.Operator == OpIRegex {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return matchNone
+		}
+		return func(v reflect.Value) bool { return re.MatchString(field(v).String()) }
+
+	case OpIn, OpNotIn:
+		contains := scalarSet(fieldType, condition.Value)
+		if contains == nil {
+			return nil
+		}
+		if condition.Operator == OpNotIn {
+			return func(v reflect.Value) bool { return !contains(field(v)) }
+		}
+		return func(v reflect.Value) bool { return contains(field(v)) }
+
+	case OpIsNull, OpIsNotNull:
+		null := condition.Operator == OpIsNull
+		return func(v reflect.Value) bool { return isNull(field(v)) == null }
+	}
+	return nil
+}
+
+func isScalarKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// scalarEqual returns an equality test against want, a value of the field's type
+func scalarEqual(kind reflect.Kind, want reflect.Value) func(reflect.Value) bool {
+	switch kind {
+	case reflect.String:
+		s := want.String()
+		return func(f reflect.Value) bool { return f.String() == s }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := want.Int()
+		return func(f reflect.Value) bool { return f.Int() == n }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := want.Uint()
+		return func(f reflect.Value) bool { return f.Uint() == n }
+	case reflect.Float32, reflect.Float64:
+		x := want.Float()
+		return func(f reflect.Value) bool { return f.Float() == x }
+	default:
+		b := want.Bool()
+		return func(f reflect.Value) bool { return f.Bool() == b }
+	}
+}
+
+// scalarCompare returns a three-way comparison of a field against value,
+// following compare: numbers compare as float64, strings lexically, and
+// anything else compares equal. It returns nil for non-scalar fields.
+func scalarCompare(fieldType reflect.Type, value any) func(reflect.Value) int {
+	// compare type-asserts, so named string types don't compare as strings
+	if fieldType == reflect.TypeOf("") {
+		s, ok := value.(string)
+		if !ok {
+			return func(reflect.Value) int { return 0 }
+		}
+		return func(f reflect.Value) int { return strings.Compare(f.String(), s) }
+	}
+
+	var toFloat func(reflect.Value) float64
+	switch fieldType {
+	case reflect.TypeOf(int(0)), reflect.TypeOf(int8(0)), reflect.TypeOf(int16(0)), reflect.TypeOf(int32(0)), reflect.TypeOf(int64(0)):
+		toFloat = func(f reflect.Value) float64 { return float64(f.Int()) }
+	case reflect.TypeOf(uint(0)), reflect.TypeOf(uint8(0)), reflect.TypeOf(uint16(0)), reflect.TypeOf(uint32(0)), reflect.TypeOf(uint64(0)):
+		toFloat = func(f reflect.Value) float64 { return float64(f.Uint()) }
+	case reflect.TypeOf(float32(0)), reflect.TypeOf(float64(0)):
+		toFloat = func(f reflect.Value) float64 { return f.Float() }
+	default:
+		return nil
+	}
+
+	b, ok := toFloat64(value)
+	if !ok {
+		return func(reflect.Value) int { return 0 }
+	}
+	return func(f reflect.Value) int {
+		a := toFloat(f)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+		return 0
+	}
+}
+
+// compareTest maps a comparison operator to a test on a compare result
+func compareTest(op ComparisonOperator) func(int) bool {
+	switch op {
+	case OpGreaterThan:
+		return func(c int) bool { return c > 0 }
+	case OpLessThan:
+		return func(c int) bool { return c < 0 }
+	case OpGreaterThanOrEqual:
+		return func(c int) bool { return c >= 0 }
+	default:
+		return func(c int) bool { return c <= 0 }
+	}
+}
+
+// scalarSet returns a membership test for the elements of an IN list, or nil
+// for non-scalar fields. Like reflect.DeepEqual, elements of a type other
+// than the field's never match.
+func scalarSet(fieldType reflect.Type, list any) func(reflect.Value) bool {
+	if !isScalarKind(fieldType.Kind()) {
+		return nil
+	}
+	slice := reflect.ValueOf(list)
+	if slice.Kind() != reflect.Slice && slice.Kind() != reflect.Array {
+		return nil
+	}
+
+	var elems []reflect.Value
+	for i := 0; i < slice.Len(); i++ {
+		elem := slice.Index(i)
+		if elem.Kind() == reflect.Interface {
+			elem = elem.Elem()
+		}
+		if elem.IsValid() && elem.Type() == fieldType {
+			elems = append(elems, elem)
+		}
+	}
+
+	switch fieldType.Kind() {
+	case reflect.String:
+		return setOf(elems, reflect.Value.String)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return setOf(elems, reflect.Value.Int)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return setOf(elems, reflect.Value.Uint)
+	case reflect.Float32, reflect.Float64:
+		return setOf(elems, reflect.Value.Float)
+	default:
+		return setOf(elems, reflect.Value.Bool)
+	}
+}
+
+// setOf builds a set of the elements keyed by key and tests fields against it
+func setOf[K comparable](elems []reflect.Value, key func(reflect.Value) K) func(reflect.Value) bool {
+	set := make(map[K]struct{}, len(elems))
+	for _, elem := range elems {
+		set[key(elem)] = struct{}{}
+	}
+	return func(f reflect.Value) bool {
+		_, ok := set[key(f)]
+		return ok
+	}
+}
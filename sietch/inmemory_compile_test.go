@@ -0,0 +1,133 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type compiledItem struct {
+	ID      int64      `db:"id"`
+	Name    string     `db:"name"`
+	Level   int        `db:"level"`
+	Score   float64    `db:"score"`
+	Active  bool       `db:"active"`
+	Status  itemStatus `db:"status"`
+	Note    *string    `db:"note"`
+	Created time.Time  `db:"created_at"`
+}
+
+type itemStatus string
+
+// TestCompileFilter checks the compiled evaluator against matchesCondition
+func TestCompileFilter(t *testing.T) {
+	note := "urgent"
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := []compiledItem{
+		{ID: 1, Name: "Alice", Level: 3, Score: 9.5, Active: true, Status: "open", Note: &note, Created: base},
+		{ID: 2, Name: "bob", Level: 1, Score: 2, Status: "closed", Created: base.Add(time.Hour)},
+		{ID: 3, Name: "Carol", Level: 5, Score: 5, Active: true, Status: "open", Created: base.Add(2 * time.Hour)},
+		{},
+	}
+
+	filters := map[string]*Filter{
+		"int equal":             NewFilter().Where("level", OpEqual, 3).Build(),
+		"int equal other type":  NewFilter().Where("level", OpEqual, int64(3)).Build(),
+		"int64 not equal":       NewFilter().Where("id", OpNotEqual, int64(2)).Build(),
+		"int greater than":      NewFilter().Where("level", OpGreaterThan, 2).Build(),
+		"int less than float":   NewFilter().Where("level", OpLessThan, 2.5).Build(),
+		"float at least":        NewFilter().Where("score", OpGreaterThanOrEqual, 5).Build(),
+		"compare to string":     NewFilter().Where("level", OpLessThanOrEqual, "3").Build(),
+		"string compare":        NewFilter().Where("name", OpGreaterThan, "B").Build(),
+		"named string equal":    NewFilter().Where("status", OpEqual, itemStatus("open")).Build(),
+		"named string vs plain": NewFilter().Where("status", OpEqual, "open").Build(),
+		"bool equal":            NewFilter().Where("active", OpEqual, true).Build(),
+		"like":                  NewFilter().Where("name", OpLike, "%o%").Build(),
+		"ilike":                 NewFilter().Where("name", OpILike, "a%").Build(),
+		"regex":                 NewFilter().Where("name", OpRegex, "^[A-C]").Build(),
+		"iregex":                NewFilter().Where("name", OpIRegex, "^b").Build(),
+		"invalid regex":         NewFilter().Where("name", OpRegex, "(").Build(),
+		"in":                    NewFilter().Where("level", OpIn, []any{1, 5, int64(3)}).Build(),
+		"not in":                NewFilter().Where("id", OpNotIn, []int64{1, 3}).Build(),
+		"in not a list":         NewFilter().Where("id", OpNotIn, 3).Build(),
+		"is null":               NewFilter().Where("note", OpIsNull, nil).Build(),
+		"is not null":           NewFilter().Where("name", OpIsNotNull, nil).Build(),
+		"pointer equal":         NewFilter().Where("note", OpEqual, "urgent").Build(),
+		"converter field":       NewFilter().Where("created_at", OpGreaterThan, base).Build(),
+		"go field name":         NewFilter().Where("Level", OpEqual, 5).Build(),
+		"lowercase field name":  NewFilter().Where("score", OpBetween, []any{1, 6}).Build(),
+		"unknown field":         NewFilter().Where("missing", OpEqual, 1).Build(),
+		"or":                    NewFilter().Or(Condition{Field: "level", Operator: OpEqual, Value: 1}, Condition{Field: "name", Operator: OpEqual, Value: "Carol"}).Build(),
+		"not":                   NewFilter().Not(Condition{Field: "active", Operator: OpEqual, Value: true}).Build(),
+		"and":                   NewFilter().Where("active", OpEqual, true).Where("score", OpLessThan, 6).Build(),
+		"no conditions":         {},
+	}
+
+	for name, filter := range filters {
+		t.Run(name, func(t *testing.T) {
+			matches := compileFilter[compiledItem](filter)
+			for i := range items {
+				want := matchesCondition(&items[i], filter)
+				if got := matches(&items[i]); got != want {
+					t.Errorf("Item %d: expected %v, got %v", items[i].ID, want, got)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkInMemoryQuery_Filter(b *testing.B) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[compiledItem, int64](func(c *compiledItem) int64 { return c.ID })
+	items := make([]compiledItem, 100_000)
+	for i := range items {
+		items[i] = compiledItem{ID: int64(i), Name: fmt.Sprintf("item-%d", i), Level: i % 10, Score: float64(i % 100)}
+	}
+	if err := repo.BatchCreate(ctx, items); err != nil {
+		b.Fatal(err)
+	}
+	filter := NewFilter().
+		Where("level", OpGreaterThanOrEqual, 5).
+		Where("name", OpLike, "item-1%").
+		Where("score", OpIn, []float64{10, 20, 30}).
+		Build()
+
+	b.Run("compiled", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.Count(ctx, filter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("interpreted", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			n := 0
+			for _, item := range repo.all() {
+				if matchesCondition(item, filter) {
+					n++
+				}
+			}
+		}
+	})
+}
+
+// TestRegexCacheBounded checks that distinct patterns don't grow the cache of
+// compiled patterns past its size
+func TestRegexCacheBounded(t *testing.T) {
+	for i := 0; i < regexCacheSize+100; i++ {
+		if !matchesRegex(fmt.Sprintf("item-%d", i), fmt.Sprintf("^item-%d$", i), false) {
+			t.Fatalf("pattern %d did not match", i)
+		}
+	}
+
+	regexCache.mu.Lock()
+	size, listed := len(regexCache.entries), regexCache.lru.Len()
+	regexCache.mu.Unlock()
+	if size > regexCacheSize || listed != size {
+		t.Errorf("Expected at most %d cached patterns, got %d (%d listed)", regexCacheSize, size, listed)
+	}
+	if !matchesRegex("ITEM-1", "^item-1$", true) || matchesRegex("item-1", "(", false) {
+		t.Error("unexpected match after eviction")
+	}
+}
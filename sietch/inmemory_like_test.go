@@ -3,6 +3,9 @@ package sietch
 import (
 	"context"
 	"testing"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
 )
 
 func TestInMemoryLikeOperator(t *testing.T) {
@@ -325,3 +328,111 @@ func TestInMemoryCompareEdgeCases(t *testing.T) {
 		}
 	})
 }
+
+func TestInMemoryCollation(t *testing.T) {
+	ctx := context.Background()
+
+	type person struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+		City string `db:"city"`
+	}
+
+	newRepo := func() *InMemoryConnector[person, int64] {
+		repo := NewInMemoryConnector[person, int64](func(p *person) int64 { return p.ID })
+		repo.BatchCreate(ctx, []person{
+			{ID: 1, Name: "Zoe", City: "b"},
+			{ID: 2, Name: "Émile", City: "a"},
+			{ID: 3, Name: "adam", City: "C"},
+		})
+		return repo
+	}
+
+	names := func(results []person) []string {
+		out := make([]string, len(results))
+		for i, p := range results {
+			out[i] = p.Name
+		}
+		return out
+	}
+
+	assertOrder := func(t *testing.T, got, want []string) {
+		t.Helper()
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Expected order %v, got %v", want, got)
+			}
+		}
+	}
+
+	t.Run("Byte order by default", func(t *testing.T) {
+		results, _ := newRepo().Query(ctx, NewFilter().OrderBy("name", SortAsc).Build())
+		assertOrder(t, names(results), []string{"Zoe", "adam", "Émile"})
+	})
+
+	t.Run("Per-field collation", func(t *testing.T) {
+		repo := newRepo()
+		repo.SetCollation("name", language.French)
+
+		results, _ := repo.Query(ctx, NewFilter().OrderBy("name", SortAsc).Build())
+		assertOrder(t, names(results), []string{"adam", "Émile", "Zoe"})
+
+		results, _ = repo.Query(ctx, NewFilter().OrderBy("name", SortDesc).Build())
+		assertOrder(t, names(results), []string{"Zoe", "Émile", "adam"})
+
+		// Other fields keep byte order
+		results, _ = repo.Query(ctx, NewFilter().OrderBy("city", SortAsc).Build())
+		assertOrder(t, names(results), []string{"adam", "Émile", "Zoe"})
+	})
+
+	t.Run("Default collation with options", func(t *testing.T) {
+		repo := newRepo()
+		repo.SetDefaultCollation(language.English, collate.IgnoreCase)
+
+		results, _ := repo.Query(ctx, NewFilter().OrderBy("city", SortAsc).Build())
+		assertOrder(t, names(results), []string{"Émile", "Zoe", "adam"})
+	})
+}
+
+func TestInMemoryRegexOperator(t *testing.T) {
+	ctx := context.Background()
+
+	type TestEntity struct {
+		ID   int64  `db:"id"`
+		Code string `db:"code"`
+	}
+
+	repo := NewInMemoryConnector[TestEntity, int64](
+		func(e *TestEntity) int64 { return e.ID },
+	)
+	repo.BatchCreate(ctx, []TestEntity{
+		{ID: 1, Code: "AB-1234"},
+		{ID: 2, Code: "ab-5678"},
+		{ID: 3, Code: "AB-12"},
+		{ID: 4, Code: "XY-0001"},
+	})
+
+	tests := []struct {
+		name     string
+		operator ComparisonOperator
+		pattern  string
+		expected int
+	}{
+		{"OpRegex anchored", OpRegex, `^AB-[0-9]{4}$`, 1},
+		{"OpRegex unanchored", OpRegex, `-12`, 2},
+		{"OpIRegex case-insensitive", OpIRegex, `^ab-[0-9]{4}$`, 2},
+		{"OpRegex invalid pattern", OpRegex, `(`, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.Query(ctx, NewFilter().Where("code", tt.operator, tt.pattern).Build())
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if len(results) != tt.expected {
+				t.Errorf("Expected %d results, got %d", tt.expected, len(results))
+			}
+		})
+	}
+}
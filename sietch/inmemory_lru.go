@@ -0,0 +1,106 @@
+package sietch
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// SetMaxItems bounds the number of items, evicting the least recently used
+// item when a write exceeds it, so the connector can serve as a bounded
+// cache, e.g. the cache of a CachedRepository. Items are used by writes and
+// by Get, GetMany and Exists; scans don't count. onEvict, if not nil, is
+// called with every evicted item while its shard is locked, so it must not
+// call the connector. Zero removes the bound.
+//
+// Sharded connectors bound each shard to its share of maxItems, so the
+// evicted item is the least recently used of its shard. Call SetMaxItems
+// before the connector is used; items beyond the bound are evicted at once.
+func (r *InMemoryConnector[T, ID]) SetMaxItems(maxItems int, onEvict func(id ID, item *T)) error {
+	if maxItems < 0 {
+		return fmt.Errorf("max items cannot be negative")
+	}
+	defer r.lockAll()()
+
+	r.onEvict = onEvict
+	limit := 0
+	if maxItems > 0 {
+		limit = (maxItems + len(r.shards) - 1) / len(r.shards)
+	}
+	for _, s := range r.shards {
+		s.limit = limit
+		r.track(s)
+	}
+	return nil
+}
+
+// track rebuilds the recency list of s from its items, evicting those beyond
+// its limit. The caller holds the lock of s.
+func (r *InMemoryConnector[T, ID]) track(s *shard[T, ID]) {
+	s.lruMu.Lock()
+	s.lru.Init()
+	s.lruElems = nil
+	s.lruMu.Unlock()
+	if s.limit == 0 {
+		return
+	}
+	for id := range s.data {
+		r.admit(s, id)
+	}
+}
+
+// admit makes id the most recently used item of s and evicts the least
+// recently used ones beyond its limit. The caller holds the lock of s.
+func (r *InMemoryConnector[T, ID]) admit(s *shard[T, ID], id ID) {
+	if s.limit == 0 {
+		return
+	}
+	s.lruMu.Lock()
+	if s.lruElems == nil {
+		s.lruElems = make(map[ID]*list.Element)
+	}
+	if el, ok := s.lruElems[id]; ok {
+		s.lru.MoveToFront(el)
+	} else {
+		s.lruElems[id] = s.lru.PushFront(id)
+	}
+	var victims []ID
+	for el := s.lru.Back(); s.lru.Len()-len(victims) > s.limit && el.Value.(ID) != id; el = el.Prev() {
+		victims = append(victims, el.Value.(ID))
+	}
+	s.lruMu.Unlock()
+
+	for _, victim := range victims {
+		item := s.data[victim]
+		r.drop(s, victim)
+		if r.onEvict != nil {
+			r.onEvict(victim, item)
+		}
+	}
+}
+
+// touch makes id the most recently used item of s. The caller holds the
+// lock of s, for reading at least.
+func (s *shard[T, ID]) touch(id ID) {
+	if s.limit == 0 {
+		return
+	}
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+	if el, ok := s.lruElems[id]; ok {
+		s.lru.MoveToFront(el)
+	}
+}
+
+// forget removes id from the recency list of s. The caller holds the lock
+// of s.
+func (s *shard[T, ID]) forget(id ID) {
+	if s.limit == 0 {
+		return
+	}
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+	if el, ok := s.lruElems[id]; ok {
+		s.lru.Remove(el)
+		delete(s.lruElems, id)
+	}
+}
@@ -0,0 +1,59 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestInMemoryMaxItems(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+
+	t.Run("Least recently used items are evicted", func(t *testing.T) {
+		repo := NewInMemoryConnector[testutils.Account](getID)
+		var evicted []int64
+		if err := repo.SetMaxItems(3, func(id int64, _ *testutils.Account) { evicted = append(evicted, id) }); err != nil {
+			t.Fatalf("SetMaxItems failed: %v", err)
+		}
+		_ = repo.BatchCreate(ctx, []testutils.Account{{ID: 1}, {ID: 2}, {ID: 3}})
+		_, _ = repo.Get(ctx, 1) // 2 is now the least recently used
+		_ = repo.Create(ctx, &testutils.Account{ID: 4})
+		_ = repo.Upsert(ctx, &testutils.Account{ID: 5})
+
+		if len(evicted) != 2 || evicted[0] != 2 || evicted[1] != 3 {
+			t.Errorf("Expected 2 and 3 to be evicted, got %v", evicted)
+		}
+		if count, _ := repo.Count(ctx, &Filter{}); count != 3 {
+			t.Errorf("Expected 3 items, got %d", count)
+		}
+		if _, err := repo.Get(ctx, 2); !errors.Is(err, ErrItemNotFound) {
+			t.Errorf("Expected the evicted item to be gone, got %v", err)
+		}
+	})
+
+	t.Run("Deletes free their slot", func(t *testing.T) {
+		repo := NewInMemoryConnector[testutils.Account](getID)
+		_ = repo.SetMaxItems(2, nil)
+		_ = repo.BatchCreate(ctx, []testutils.Account{{ID: 1}, {ID: 2}})
+		_ = repo.Delete(ctx, 2)
+		_ = repo.Create(ctx, &testutils.Account{ID: 3})
+		if exists, _ := repo.Exists(ctx, 1); !exists {
+			t.Error("Expected no eviction after a delete")
+		}
+	})
+
+	t.Run("Existing items are bounded", func(t *testing.T) {
+		repo := NewInMemoryConnector[testutils.Account](getID)
+		_ = repo.BatchCreate(ctx, []testutils.Account{{ID: 1}, {ID: 2}, {ID: 3}})
+		_ = repo.SetMaxItems(1, nil)
+		if count, _ := repo.Count(ctx, &Filter{}); count != 1 {
+			t.Errorf("Expected 1 item left, got %d", count)
+		}
+		if err := repo.SetMaxItems(-1, nil); err == nil {
+			t.Error("Expected a negative bound to be rejected")
+		}
+	})
+}
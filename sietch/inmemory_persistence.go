@@ -0,0 +1,321 @@
+package sietch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Snapshot writes every item, soft-deleted ones included, to w as JSON
+// lines, one item per line with its expiry, in no particular order. Writes
+// wait until the snapshot is written, so it is consistent. Unexported
+// fields are not written.
+func (r *InMemoryConnector[T, ID]) Snapshot(w io.Writer) error {
+	defer r.lockAll()()
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	now := time.Now()
+	for _, s := range r.shards {
+		for id, item := range s.data {
+			if s.expired(id, now) {
+				continue
+			}
+			if err := enc.Encode(putRecord[ID](item, s.expires[id])); err != nil {
+				return fmt.Errorf("failed to write snapshot: %w", err)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// RestoreSnapshot replaces every item with the items of a snapshot written
+// by Snapshot, which keep their expiry. If the snapshot cannot be read, the
+// items are left as they were.
+func (r *InMemoryConnector[T, ID]) RestoreSnapshot(rd io.Reader) error {
+	var records []journalRecord[T, ID]
+	dec := json.NewDecoder(rd)
+	for {
+		var rec journalRecord[T, ID]
+		if err := dec.Decode(&rec); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if rec.Op != journalPut || rec.Item == nil {
+			return fmt.Errorf("failed to read snapshot: record %d holds no item", len(records)+1)
+		}
+		records = append(records, rec)
+	}
+
+	defer r.lockAll()()
+	r.reset()
+	now := time.Now()
+	for _, rec := range records {
+		var expires time.Time
+		if rec.Expires != nil {
+			if !now.Before(*rec.Expires) {
+				continue // expired since the snapshot
+			}
+			expires = *rec.Expires
+		}
+		id := r.getID(rec.Item)
+		r.store(r.shard(id), id, rec.Item, expires)
+	}
+	return nil
+}
+
+// EnableAppendOnlyFile keeps the items in the file at path: the writes it
+// records are replayed, replacing the current items, and every later write
+// is appended, so a restarted process finds the items it left. Records
+// reach the operating system on every write, surviving a crash of the
+// process but not of the machine; a record cut short by a crash is dropped.
+// Call it before the connector is used.
+//
+// Appending errors don't fail writes; CloseAppendOnlyFile returns the first.
+func (r *InMemoryConnector[T, ID]) EnableAppendOnlyFile(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open append-only file: %w", err)
+	}
+
+	unlock := r.lockAll()
+	defer unlock()
+	if r.journal.Load() != nil {
+		_ = f.Close()
+		return fmt.Errorf("append-only file already enabled")
+	}
+	if err := r.replay(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.journal.Store(&appendLog[T, ID]{file: f, path: path})
+	return nil
+}
+
+// CompactAppendOnlyFile rewrites the append-only file with one record per
+// stored item, dropping the history of overwritten and deleted items
+func (r *InMemoryConnector[T, ID]) CompactAppendOnlyFile() error {
+	defer r.lockAll()()
+	log := r.journal.Load()
+	if log == nil {
+		return fmt.Errorf("append-only file not enabled")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(log.path), filepath.Base(log.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to compact append-only file: %w", err)
+	}
+	bw := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(bw)
+	now := time.Now()
+	for _, s := range r.shards {
+		for id, item := range s.data {
+			if err == nil && !s.expired(id, now) {
+				err = enc.Encode(putRecord[ID](item, s.expires[id]))
+			}
+		}
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), log.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to compact append-only file: %w", err)
+	}
+	return log.reopen()
+}
+
+// CloseAppendOnlyFile stops appending writes and closes the file, returning
+// the first error writes met appending
+func (r *InMemoryConnector[T, ID]) CloseAppendOnlyFile() error {
+	defer r.lockAll()()
+	log := r.journal.Swap(nil)
+	if log == nil {
+		return nil
+	}
+	return log.close()
+}
+
+// replay applies the records of f and truncates a record cut short at its
+// end. The caller holds lockAll.
+func (r *InMemoryConnector[T, ID]) replay(f *os.File) error {
+	r.reset()
+
+	br := bufio.NewReader(f)
+	now := time.Now()
+	var offset int64
+	for {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break // an empty or cut short last record
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read append-only file: %w", err)
+		}
+
+		var rec journalRecord[T, ID]
+		if err := json.Unmarshal(bytes.TrimSpace(line), &rec); err != nil {
+			return fmt.Errorf("corrupt append-only file at offset %d: %w", offset, err)
+		}
+		switch {
+		case rec.Op == journalPut && rec.Item != nil:
+			id := r.getID(rec.Item)
+			s := r.shard(id)
+			delete(s.data, id)
+			delete(s.expires, id)
+			if rec.Expires != nil && !now.Before(*rec.Expires) {
+				break // expired while the process was down
+			}
+			s.data[id] = rec.Item
+			if rec.Expires != nil {
+				s.expires[id] = *rec.Expires
+			}
+		case rec.Op == journalDelete && rec.ID != nil:
+			s := r.shard(*rec.ID)
+			delete(s.data, *rec.ID)
+			delete(s.expires, *rec.ID)
+		case rec.Op == journalClear:
+			r.reset()
+		}
+		offset += int64(len(line))
+	}
+	for _, s := range r.shards {
+		r.track(s)
+	}
+
+	if err := f.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate append-only file: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek append-only file: %w", err)
+	}
+	return nil
+}
+
+// put stores item under id in s, expiring after the TTL of the connector,
+// and journals it. The caller holds the lock of s.
+func (r *InMemoryConnector[T, ID]) put(s *shard[T, ID], id ID, item *T) {
+	var expires time.Time
+	if r.ttl > 0 {
+		expires = time.Now().Add(r.ttl)
+	}
+	r.store(s, id, item, expires)
+}
+
+// store stores item under id in s until expires, forever if zero, and
+// journals it. The caller holds the lock of s.
+func (r *InMemoryConnector[T, ID]) store(s *shard[T, ID], id ID, item *T, expires time.Time) {
+	s.data[id] = item
+	if expires.IsZero() {
+		delete(s.expires, id)
+	} else {
+		s.expires[id] = expires
+	}
+	r.journal.Load().append(putRecord[ID](item, expires))
+	r.admit(s, id)
+}
+
+// drop deletes the item stored under id in s and journals it. The caller
+// holds the lock of s.
+func (r *InMemoryConnector[T, ID]) drop(s *shard[T, ID], id ID) {
+	delete(s.data, id)
+	delete(s.expires, id)
+	s.forget(id)
+	r.journal.Load().append(journalRecord[T, ID]{Op: journalDelete, ID: &id})
+}
+
+// reset deletes every item and journals it. The caller holds lockAll.
+func (r *InMemoryConnector[T, ID]) reset() {
+	for _, s := range r.shards {
+		s.data = make(map[ID]*T)
+		s.expires = make(map[ID]time.Time)
+		r.track(s)
+	}
+	r.journal.Load().append(journalRecord[T, ID]{Op: journalClear})
+}
+
+// Operations of journal records
+const (
+	journalPut    = "put"
+	journalDelete = "del"
+	journalClear  = "clear"
+)
+
+// journalRecord is a write of the append-only file
+type journalRecord[T any, ID comparable] struct {
+	Op      string     `json:"op"`
+	Item    *T         `json:"item,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+	ID      *ID        `json:"id,omitempty"`
+}
+
+// putRecord returns the record storing item until expires, forever if zero
+func putRecord[ID comparable, T any](item *T, expires time.Time) journalRecord[T, ID] {
+	rec := journalRecord[T, ID]{Op: journalPut, Item: item}
+	if !expires.IsZero() {
+		rec.Expires = &expires
+	}
+	return rec
+}
+
+// appendLog appends records to the append-only file
+type appendLog[T any, ID comparable] struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+	err  error // first append error
+}
+
+// append writes rec as one line; records of a nil log are dropped
+func (l *appendLog[T, ID]) append(rec journalRecord[T, ID]) {
+	if l == nil {
+		return
+	}
+	line, err := json.Marshal(rec)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err == nil {
+		_, err = l.file.Write(append(line, '\n'))
+	}
+	if err != nil && l.err == nil {
+		l.err = fmt.Errorf("failed to append to %s: %w", l.path, err)
+	}
+}
+
+// reopen switches to the file at path after it was replaced
+func (l *appendLog[T, ID]) reopen() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen append-only file: %w", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_ = l.file.Close()
+	l.file = f
+	return nil
+}
+
+// close closes the file, returning the first append error
+func (l *appendLog[T, ID]) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return errors.Join(l.err, l.file.Close())
+}
@@ -0,0 +1,120 @@
+package sietch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestInMemorySnapshot(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	repo := NewShardedInMemoryConnector[testutils.Account](getID, 4)
+	_ = repo.BatchCreate(ctx, []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}, {ID: 3, Balance: 30}})
+
+	var buf bytes.Buffer
+	if err := repo.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	restored := NewInMemoryConnector[testutils.Account](getID)
+	_ = restored.Create(ctx, &testutils.Account{ID: 42})
+	if err := restored.RestoreSnapshot(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	if count, _ := restored.Count(ctx, &Filter{}); count != 3 {
+		t.Errorf("Expected the 3 snapshot items, got %d", count)
+	}
+	if got, _ := restored.Get(ctx, 2); got == nil || got.Balance != 20 {
+		t.Errorf("Expected the restored item, got %+v", got)
+	}
+
+	if err := restored.RestoreSnapshot(bytes.NewReader([]byte(`{"ID": 7}` + "\n{"))); err == nil {
+		t.Error("Expected a corrupt snapshot to fail")
+	}
+	if count, _ := restored.Count(ctx, &Filter{}); count != 3 {
+		t.Errorf("Expected a failed restore to keep the items, got %d", count)
+	}
+
+	expiring := NewInMemoryConnectorWithTTL[testutils.Account](getID, time.Hour)
+	defer expiring.Close()
+	_ = expiring.Create(ctx, &testutils.Account{ID: 1})
+	buf.Reset()
+	if err := expiring.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if err := restored.RestoreSnapshot(&buf); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	if want, got := expiring.shard(1).expires[1], restored.shard(1).expires[1]; got.IsZero() || !got.Equal(want) {
+		t.Errorf("Expected the restored item to expire at %v, got %v", want, got)
+	}
+}
+
+func TestInMemoryAppendOnlyFile(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	path := filepath.Join(t.TempDir(), "accounts.aof")
+	open := func() *InMemoryConnector[testutils.Account, int64] {
+		repo := NewInMemoryConnector[testutils.Account](getID)
+		if err := repo.EnableAppendOnlyFile(path); err != nil {
+			t.Fatalf("EnableAppendOnlyFile failed: %v", err)
+		}
+		return repo
+	}
+
+	repo := open()
+	_ = repo.BatchCreate(ctx, []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}, {ID: 3, Balance: 30}})
+	_ = repo.Update(ctx, &testutils.Account{ID: 1, Balance: 11})
+	_ = repo.Delete(ctx, 2)
+	_, _ = repo.UpdateWhere(ctx, NewFilter().Where("id", OpEqual, int64(3)).Build(), map[string]any{"balance": 33})
+	_ = repo.WithTx(ctx, func(tx Repository[testutils.Account, int64]) error {
+		_ = tx.Delete(ctx, 1)
+		return errors.New("rollback")
+	})
+	if err := repo.CloseAppendOnlyFile(); err != nil {
+		t.Fatalf("CloseAppendOnlyFile failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if lines := bytes.Count(data, []byte("\n")); lines != 9 || bytes.Contains(data, []byte(journalClear)) {
+		t.Errorf("Expected the rollback to append only the put undoing its delete, got:\n%s", data)
+	}
+
+	// a crash cut the last record short
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	_, _ = f.WriteString(`{"op":"put","item":{"ID":4`)
+	_ = f.Close()
+
+	repo = open()
+	defer repo.CloseAppendOnlyFile()
+	want := map[int64]int{1: 11, 3: 33}
+	if count, _ := repo.Count(ctx, &Filter{}); count != int64(len(want)) {
+		t.Errorf("Expected %d items after replay, got %d", len(want), count)
+	}
+	for id, balance := range want {
+		if got, err := repo.Get(ctx, id); err != nil || got.Balance != balance {
+			t.Errorf("Expected item %d with balance %d, got %+v (%v)", id, balance, got, err)
+		}
+	}
+
+	if err := repo.CompactAppendOnlyFile(); err != nil {
+		t.Fatalf("CompactAppendOnlyFile failed: %v", err)
+	}
+	_ = repo.Create(ctx, &testutils.Account{ID: 5, Balance: 50})
+	_ = repo.CloseAppendOnlyFile()
+	data, _ = os.ReadFile(path)
+	if lines := bytes.Count(data, []byte("\n")); lines != 3 {
+		t.Errorf("Expected 3 records after compaction, got %d:\n%s", lines, data)
+	}
+	repo = open()
+	defer repo.CloseAppendOnlyFile()
+	if count, _ := repo.Count(ctx, &Filter{}); count != 3 {
+		t.Errorf("Expected 3 items after compaction, got %d", count)
+	}
+}
@@ -0,0 +1,261 @@
+package sietch
+
+import (
+	"container/list"
+	"encoding/binary"
+	"hash/maphash"
+	"io"
+	"iter"
+	"math"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+// shard is a partition of the items of an InMemoryConnector with its own lock.
+//
+// Locking: single-item and batch operations lock the shards of their items;
+// scans read-lock one shard at a time; operations that must see or change
+// the whole store atomically (UpdateWhere, DeleteWhere, WithTx) lock every
+// shard. Shards are always locked in index order. r.mu only guards the
+// connector configuration and is never acquired while holding a shard lock.
+type shard[T any, ID comparable] struct {
+	mu      sync.RWMutex
+	data    map[ID]*T
+	expires map[ID]time.Time // expiry of the items of a connector with a TTL
+
+	// recency of the items when bounded, see SetMaxItems. lruMu lets reads
+	// holding mu for reading update it.
+	limit    int
+	lruMu    sync.Mutex
+	lru      list.List // of ID, most recently used first
+	lruElems map[ID]*list.Element
+}
+
+// NewShardedInMemoryConnector creates an InMemoryConnector whose items are
+// spread over the given number of shards, each with its own lock, so parallel
+// reads and writes of different items don't contend like they would on a
+// single mutex. Useful to make concurrency tests behave more like a real
+// datastore; NewInMemoryConnector uses a single shard.
+//
+// Scans (Query, Count, GroupCount) read one shard at a time, so like a
+// database at read committed they may observe writes that run concurrently
+// with them.
+//
+// Example:
+//
+//	repo := sietch.NewShardedInMemoryConnector[Account, int64](getID, 32)
+func NewShardedInMemoryConnector[T any, ID comparable](getID func(t *T) ID, shards int) *InMemoryConnector[T, ID] {
+	r := &InMemoryConnector[T, ID]{
+		shards:   make([]*shard[T, ID], max(1, shards)),
+		seed:     maphash.MakeSeed(),
+		getID:    mustResolveGetID(getID),
+		version:  versionFieldIndex(reflect.TypeFor[T]()),
+		readonly: readonlyFieldIndexes(reflect.TypeFor[T]()),
+
+		softDelete: isSoftDeletable[T](),
+	}
+	for i := range r.shards {
+		r.shards[i] = &shard[T, ID]{data: make(map[ID]*T), expires: make(map[ID]time.Time)}
+	}
+	return r
+}
+
+// shard returns the shard holding id
+func (r *InMemoryConnector[T, ID]) shard(id ID) *shard[T, ID] {
+	if len(r.shards) == 1 {
+		return r.shards[0]
+	}
+	return r.shards[r.shardIndex(id)]
+}
+
+// shardIndex hashes id to the index of its shard
+func (r *InMemoryConnector[T, ID]) shardIndex(id ID) int {
+	var sum uint64
+	switch v := any(id).(type) {
+	case string:
+		sum = maphash.String(r.seed, v)
+	case int64:
+		sum = mixHash(uint64(v))
+	case int:
+		sum = mixHash(uint64(v))
+	default:
+		var h maphash.Hash
+		h.SetSeed(r.seed)
+		writeHashValue(&h, reflect.ValueOf(id))
+		sum = h.Sum64()
+	}
+	return int(sum % uint64(len(r.shards)))
+}
+
+// mixHash spreads sequential integer IDs evenly (splitmix64 finalizer)
+func mixHash(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// lockShards write-locks the shards of ids, in index order so that concurrent
+// batches cannot deadlock, and returns the function that unlocks them
+func (r *InMemoryConnector[T, ID]) lockShards(ids iter.Seq[ID]) (unlock func()) {
+	indexes := make([]int, 0, len(r.shards))
+	for id := range ids {
+		idx := 0
+		if len(r.shards) > 1 {
+			idx = r.shardIndex(id)
+		}
+		if !slices.Contains(indexes, idx) {
+			indexes = append(indexes, idx)
+		}
+	}
+	slices.Sort(indexes)
+
+	for _, idx := range indexes {
+		r.shards[idx].mu.Lock()
+	}
+	return func() {
+		for _, idx := range indexes {
+			r.shards[idx].mu.Unlock()
+		}
+	}
+}
+
+// lockAll write-locks every shard and returns the function that unlocks them
+func (r *InMemoryConnector[T, ID]) lockAll() (unlock func()) {
+	for _, s := range r.shards {
+		s.mu.Lock()
+	}
+	return func() {
+		for _, s := range r.shards {
+			s.mu.Unlock()
+		}
+	}
+}
+
+// entries iterates the stored items without locking; the caller holds lockAll
+func (r *InMemoryConnector[T, ID]) entries() iter.Seq2[ID, *T] {
+	return func(yield func(ID, *T) bool) {
+		now := time.Now()
+		for _, s := range r.shards {
+			for id, item := range s.data {
+				if s.expired(id, now) {
+					continue
+				}
+				if !yield(id, item) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// all iterates the stored items, read-locking one shard at a time
+func (r *InMemoryConnector[T, ID]) all() iter.Seq2[ID, *T] {
+	return func(yield func(ID, *T) bool) {
+		for _, s := range r.shards {
+			if !s.each(yield) {
+				return
+			}
+		}
+	}
+}
+
+// each calls yield for every item of the shard until it returns false
+func (s *shard[T, ID]) each(yield func(ID, *T) bool) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	for id, item := range s.data {
+		if s.expired(id, now) {
+			continue
+		}
+		if !yield(id, item) {
+			return false
+		}
+	}
+	return true
+}
+
+// len returns the number of stored items
+func (r *InMemoryConnector[T, ID]) len() int {
+	n := 0
+	for _, s := range r.shards {
+		s.mu.RLock()
+		n += len(s.data)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// itemsByID returns the IDs of items, for lockShards
+func itemsByID[T any, ID comparable](items []T, getID func(*T) ID) iter.Seq[ID] {
+	return func(yield func(ID) bool) {
+		for i := range items {
+			if !yield(getID(&items[i])) {
+				return
+			}
+		}
+	}
+}
+
+// hashWriter is implemented by *maphash.Hash and fnvWriter
+type hashWriter interface {
+	io.Writer
+	io.StringWriter
+	io.ByteWriter
+}
+
+// writeHashValue writes v to h. IDs are comparable, so every kind reachable
+// from them is handled; named types (e.g. TypedID) hash like their fields.
+func writeHashValue(h hashWriter, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		h.WriteString(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeHashUint64(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeHashUint64(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f == 0 {
+			f = 0 // -0 == +0
+		}
+		writeHashUint64(h, math.Float64bits(f))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		writeHashValue(h, reflect.ValueOf(real(c)))
+		writeHashValue(h, reflect.ValueOf(imag(c)))
+	case reflect.Bool:
+		if v.Bool() {
+			h.WriteByte(1)
+		} else {
+			h.WriteByte(0)
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			writeHashValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			writeHashValue(h, v.Field(i))
+		}
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		writeHashUint64(h, uint64(v.Pointer()))
+	case reflect.Interface:
+		if !v.IsNil() {
+			writeHashValue(h, v.Elem())
+		}
+	}
+}
+
+// writeHashUint64 writes x to h
+func writeHashUint64(h hashWriter, x uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], x)
+	h.Write(b[:])
+}
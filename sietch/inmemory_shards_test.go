@@ -0,0 +1,145 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func newShardedAccounts(shards int) *InMemoryConnector[testutils.Account, int64] {
+	return NewShardedInMemoryConnector[testutils.Account](func(a *testutils.Account) int64 { return a.ID }, shards)
+}
+
+func TestShardedInMemoryConnector(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Items are spread over the shards", func(t *testing.T) {
+		repo := newShardedAccounts(8)
+		for i := int64(0); i < 800; i++ {
+			if err := repo.Create(ctx, &testutils.Account{ID: i, Balance: int(i)}); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+		}
+		for i, s := range repo.shards {
+			if len(s.data) < 50 {
+				t.Errorf("Expected shard %d to hold about 100 items, got %d", i, len(s.data))
+			}
+		}
+
+		count, err := repo.Count(ctx, NewFilter().Where("balance", OpGreaterThanOrEqual, 400).Build())
+		if err != nil || count != 400 {
+			t.Errorf("Expected 400 items, got %d (%v)", count, err)
+		}
+		acc, err := repo.Get(ctx, 123)
+		if err != nil || acc.Balance != 123 {
+			t.Errorf("Expected account 123, got %v (%v)", acc, err)
+		}
+	})
+
+	t.Run("Batches are applied across shards", func(t *testing.T) {
+		repo := newShardedAccounts(4)
+		accounts := []testutils.Account{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}}
+		if err := repo.BatchCreate(ctx, accounts); err != nil {
+			t.Fatalf("BatchCreate failed: %v", err)
+		}
+		if err := repo.BatchCreate(ctx, accounts[:1]); !errors.Is(err, ErrItemAlreadyExists) {
+			t.Errorf("Expected ErrItemAlreadyExists, got %v", err)
+		}
+		if err := repo.BatchDelete(ctx, []int64{1, 2, 3}); err != nil {
+			t.Fatalf("BatchDelete failed: %v", err)
+		}
+		n, err := repo.UpdateWhere(ctx, NewFilter().Where("id", OpGreaterThan, 0).Build(), map[string]any{"balance": 7})
+		if err != nil || n != 2 {
+			t.Errorf("Expected 2 updated items, got %d (%v)", n, err)
+		}
+		results, err := repo.GetMany(ctx, []int64{1, 4, 5})
+		if err != nil || len(results) != 2 || results[4].Balance != 7 {
+			t.Errorf("Unexpected results %v (%v)", results, err)
+		}
+	})
+
+	t.Run("Rollback restores every shard", func(t *testing.T) {
+		repo := newShardedAccounts(4)
+		for i := int64(0); i < 10; i++ {
+			_ = repo.Create(ctx, &testutils.Account{ID: i})
+		}
+
+		err := repo.WithTx(ctx, func(tx Repository[testutils.Account, int64]) error {
+			if _, err := tx.DeleteWhere(ctx, NewFilter().Where("id", OpLessThan, 5).Build()); err != nil {
+				return err
+			}
+			return fmt.Errorf("abort")
+		})
+		if err == nil {
+			t.Fatal("Expected the transaction to fail")
+		}
+		if count, _ := repo.Count(ctx, &Filter{}); count != 10 {
+			t.Errorf("Expected 10 items after rollback, got %d", count)
+		}
+	})
+
+	t.Run("Typed and string IDs hash consistently", func(t *testing.T) {
+		type userID = TypedID[testutils.Account, string]
+		repo := NewShardedInMemoryConnector[userEntity](func(u *userEntity) userID { return u.ID }, 16)
+		id := NewTypedID[testutils.Account]("alice")
+		if err := repo.Create(ctx, &userEntity{ID: id}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if exists, _ := repo.Exists(ctx, NewTypedID[testutils.Account]("alice")); !exists {
+			t.Error("Expected the item to be found by an equal ID")
+		}
+	})
+
+	t.Run("Parallel writers", func(t *testing.T) {
+		repo := newShardedAccounts(16)
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					id := int64(w*100 + i)
+					_ = repo.Create(ctx, &testutils.Account{ID: id})
+					_ = repo.Upsert(ctx, &testutils.Account{ID: id, Balance: 1})
+					_, _ = repo.Query(ctx, NewFilter().Where("id", OpEqual, id).Build())
+				}
+			}()
+		}
+		wg.Wait()
+
+		if count, _ := repo.Count(ctx, NewFilter().Where("balance", OpEqual, 1).Build()); count != 800 {
+			t.Errorf("Expected 800 items, got %d", count)
+		}
+	})
+}
+
+type userEntity struct {
+	ID TypedID[testutils.Account, string] `db:"id"`
+}
+
+func BenchmarkInMemoryConnector_Parallel(b *testing.B) {
+	ctx := context.Background()
+	for _, shards := range []int{1, 32} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			repo := newShardedAccounts(shards)
+			for i := int64(0); i < 1024; i++ {
+				_ = repo.Create(ctx, &testutils.Account{ID: i})
+			}
+			b.RunParallel(func(pb *testing.PB) {
+				var i int64
+				for pb.Next() {
+					i++
+					if i%4 == 0 {
+						_ = repo.Update(ctx, &testutils.Account{ID: i % 1024, Balance: int(i)})
+					} else {
+						_, _ = repo.Get(ctx, i%1024)
+					}
+				}
+			})
+		})
+	}
+}
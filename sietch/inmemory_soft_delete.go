@@ -0,0 +1,55 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+)
+
+// hidden reports whether item is soft-deleted and the reads of ctx skip it
+func (r *InMemoryConnector[T, ID]) hidden(ctx context.Context, item *T) bool {
+	return r.softDelete && !includesDeleted(ctx) && isEntityDeleted(item)
+}
+
+// matcher compiles filter for a read, skipping the items hidden from ctx
+func (r *InMemoryConnector[T, ID]) matcher(ctx context.Context, filter *Filter) func(*T) bool {
+	matches := compileFilter[T](filter)
+	if !r.softDelete || includesDeleted(ctx) {
+		return matches
+	}
+	return func(item *T) bool {
+		return !isEntityDeleted(item) && matches(item)
+	}
+}
+
+// liveMatcher compiles filter for a bulk write, which never touches
+// soft-deleted items
+func (r *InMemoryConnector[T, ID]) liveMatcher(filter *Filter) func(*T) bool {
+	return r.matcher(context.Background(), filter)
+}
+
+// WithDeleted returns a view of the connector whose reads include
+// soft-deleted items (see SoftDeleter)
+func (r *InMemoryConnector[T, ID]) WithDeleted() Repository[T, ID] {
+	return &withDeletedRepository[T, ID]{repo: r}
+}
+
+// Restore clears the deleted flag and timestamp of a soft-deleted item
+func (r *InMemoryConnector[T, ID]) Restore(_ context.Context, id ID) error {
+	if !r.softDelete {
+		return fmt.Errorf("%w: entity does not implement SoftDeletable", ErrUnsupportedOperation)
+	}
+
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, exists := s.get(id)
+	if !exists || !isEntityDeleted(item) {
+		return ErrNoUpdateItem
+	}
+
+	copyValue := *item
+	markAsRestored(&copyValue)
+	r.put(s, id, &copyValue)
+	return nil
+}
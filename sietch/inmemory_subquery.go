@@ -0,0 +1,234 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+)
+
+// SubqueryTable is a set of rows that EXISTS conditions can be evaluated
+// against in memory. *InMemoryConnector implements it.
+type SubqueryTable interface {
+	subqueryRows() []any
+}
+
+// subqueryRows returns a snapshot of the stored items
+func (r *InMemoryConnector[T, ID]) subqueryRows() []any {
+	rows := make([]any, 0, r.len())
+	for _, item := range r.all() {
+		copyValue := *item
+		rows = append(rows, &copyValue)
+	}
+	return rows
+}
+
+// RegisterTable makes table available to EXISTS conditions under name, the
+// table name used in Exists. Filters referencing unregistered tables fail with
+// ErrInvalidFilter.
+func (r *InMemoryConnector[T, ID]) RegisterTable(name string, table SubqueryTable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the map is replaced rather than written, as resolveSubqueries reads
+	// its snapshot without the lock
+	tables := maps.Clone(r.tables)
+	if tables == nil {
+		tables = make(map[string]SubqueryTable)
+	}
+	tables[name] = table
+	r.tables = tables
+}
+
+// resolvedSubquery is an OpExists value whose table rows have been loaded and
+// filtered by the subquery conditions; only the correlation is left to check
+type resolvedSubquery struct {
+	rows        []any
+	correlation []Correlation
+}
+
+// subqueryTable implements SubquerySource; in-memory data has no SQL table
+func (r *InMemoryConnector[T, ID]) subqueryTable() (string, func(string) error, bool) {
+	return "", nil, false
+}
+
+// subqueryValues implements SubquerySource by querying the repository
+func (r *InMemoryConnector[T, ID]) subqueryValues(ctx context.Context, filter *Filter, field string) ([]any, error) {
+	if err := validateSubqueryFilter(filter); err != nil {
+		return nil, err
+	}
+	results, err := r.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var zero T
+	path, ok := columnPath(reflect.TypeOf(zero), field)
+	if !ok {
+		return nil, fmt.Errorf("unknown field '%s' for subquery", field)
+	}
+	values := make([]any, 0, len(results))
+	for i := range results {
+		v, ok := fieldByIndex(reflect.ValueOf(&results[i]).Elem(), path)
+		if !ok {
+			continue // in a nil embedded pointer, so NULL
+		}
+		if v.Kind() == reflect.Ptr {
+			// NULLs never match IN
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		}
+		values = append(values, v.Interface())
+	}
+	return values, nil
+}
+
+// resolveSubqueries returns filter with every OpExists condition resolved
+// against the registered tables and every OpInSubquery condition replaced by
+// OpIn over the values of its source. It must be called without holding r.mu,
+// as a subquery may reference the same repository.
+func (r *InMemoryConnector[T, ID]) resolveSubqueries(ctx context.Context, filter *Filter) (*Filter, error) {
+	if filter == nil || !hasSubquery(filter.Conditions) {
+		return filter, nil
+	}
+
+	// RegisterTable never writes the map it replaces, so the snapshot can be
+	// read after the lock is released
+	r.mu.RLock()
+	tables := r.tables
+	r.mu.RUnlock()
+
+	conditions, err := resolveConditions(ctx, filter.Conditions, tables)
+	if err != nil {
+		return nil, err
+	}
+	resolved := *filter
+	resolved.Conditions = conditions
+	return &resolved, nil
+}
+
+func hasSubquery(conditions []Condition) bool {
+	for _, c := range conditions {
+		if c.Operator == OpExists || c.Operator == OpInSubquery || hasSubquery(c.Conditions) {
+			return true
+		}
+	}
+	return false
+}
+
+func resolveConditions(ctx context.Context, conditions []Condition, tables map[string]SubqueryTable) ([]Condition, error) {
+	resolved := make([]Condition, len(conditions))
+	for i, c := range conditions {
+		switch {
+		case c.IsComposite():
+			nested, err := resolveConditions(ctx, c.Conditions, tables)
+			if err != nil {
+				return nil, err
+			}
+			c.Conditions = nested
+		case c.Operator == OpExists:
+			value, err := resolveSubquery(ctx, c.Value, tables)
+			if err != nil {
+				return nil, err
+			}
+			c.Value = value
+		case c.Operator == OpInSubquery:
+			values, err := resolveInSubquery(ctx, c.Value)
+			if err != nil {
+				return nil, err
+			}
+			c.Operator, c.Value = OpIn, values
+		}
+		resolved[i] = c
+	}
+	return resolved, nil
+}
+
+func resolveSubquery(ctx context.Context, value any, tables map[string]SubqueryTable) (resolvedSubquery, error) {
+	sq, ok := value.(Subquery)
+	if p, isPtr := value.(*Subquery); isPtr && p != nil {
+		sq, ok = *p, true
+	}
+	if !ok {
+		return resolvedSubquery{}, fmt.Errorf("EXISTS operator requires a Subquery value")
+	}
+	table, ok := tables[sq.Table]
+	if !ok {
+		return resolvedSubquery{}, fmt.Errorf("%w: table %q is not registered for EXISTS", ErrInvalidFilter, sq.Table)
+	}
+
+	// Nested subqueries resolve against the same set of tables
+	sub := sq.Filter
+	if sub != nil && hasSubquery(sub.Conditions) {
+		conditions, err := resolveConditions(ctx, sub.Conditions, tables)
+		if err != nil {
+			return resolvedSubquery{}, err
+		}
+		sub = &Filter{Conditions: conditions}
+	}
+
+	var rows []any
+	for _, row := range table.subqueryRows() {
+		if matchesCondition(row, sub) {
+			rows = append(rows, row)
+		}
+	}
+	return resolvedSubquery{rows: rows, correlation: sq.Correlation}, nil
+}
+
+func resolveInSubquery(ctx context.Context, value any) ([]any, error) {
+	sq, ok := value.(InSubquery)
+	if p, isPtr := value.(*InSubquery); isPtr && p != nil {
+		sq, ok = *p, true
+	}
+	if !ok || sq.Source == nil {
+		return nil, fmt.Errorf("IN SUBQUERY operator requires an InSubquery value with a source")
+	}
+	return sq.Source.subqueryValues(ctx, sq.Filter, sq.Field)
+}
+
+// matchesExists reports whether any subquery row is correlated with item
+func matchesExists(item any, value any) bool {
+	sq, ok := value.(resolvedSubquery)
+	if !ok {
+		return false
+	}
+
+	outer := reflect.Indirect(reflect.ValueOf(item))
+	for _, row := range sq.rows {
+		inner := reflect.Indirect(reflect.ValueOf(row))
+		if correlated(outer, inner, sq.correlation) {
+			return true
+		}
+	}
+	return false
+}
+
+func correlated(outer, inner reflect.Value, correlation []Correlation) bool {
+	for _, c := range correlation {
+		o := fieldByColumn(outer, c.Outer)
+		i := fieldByColumn(inner, c.Column)
+		if !o.IsValid() || !i.IsValid() {
+			return false
+		}
+		// Like SQL NULL, nil pointers never correlate
+		if o.Kind() == reflect.Ptr {
+			if o.IsNil() {
+				return false
+			}
+			o = o.Elem()
+		}
+		if i.Kind() == reflect.Ptr {
+			if i.IsNil() {
+				return false
+			}
+			i = i.Elem()
+		}
+		if !valuesEqual(o.Interface(), i.Interface()) {
+			return false
+		}
+	}
+	return true
+}
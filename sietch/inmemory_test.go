@@ -2,7 +2,9 @@ package sietch
 
 import (
 	"context"
+	"errors"
 	"github.com/seb7887/gofw/sietch/internal/testutils"
+	"slices"
 	"testing"
 	"time"
 )
@@ -114,11 +116,29 @@ func TestInMemoryConnector_Update_BatchUpdate_Delete_BatchDelete(t *testing.T) {
 		t.Errorf("expected balances 460 y 550, got %d y %d", a4.Balance, a5.Balance)
 	}
 
+	// BatchUpdate with missing items writes nothing and lists them all
+	err := repo.BatchUpdate(ctx, []testutils.Account{{ID: 4, Balance: 0}, {ID: 8}, {ID: 9}})
+	var missing *MissingItemsError[int64]
+	if !errors.As(err, &missing) || !errors.Is(err, ErrNoUpdateItem) || !slices.Equal(missing.IDs, []int64{8, 9}) {
+		t.Errorf("expected a MissingItemsError for 8 and 9, got %v", err)
+	}
+	if a4, _ = repo.Get(ctx, 4); a4.Balance != 460 {
+		t.Errorf("expected the failed batch to leave balance 460, got %d", a4.Balance)
+	}
+
+	// The last of several items with the same ID wins
+	if err := repo.BatchUpdate(ctx, []testutils.Account{{ID: 4, Balance: 1}, {ID: 4, Balance: 2}}); err != nil {
+		t.Fatalf("BatchUpdate failed: %v", err)
+	}
+	if a4, _ = repo.Get(ctx, 4); a4.Balance != 2 {
+		t.Errorf("expected balance 2, got %d", a4.Balance)
+	}
+
 	// Test Delete
 	if err := repo.Delete(ctx, 4); err != nil {
 		t.Fatalf("Delete failed: %v", err)
 	}
-	_, err := repo.Get(ctx, 4)
+	_, err = repo.Get(ctx, 4)
 	if err == nil {
 		t.Error("expected error with ID 4")
 	}
@@ -132,3 +152,133 @@ func TestInMemoryConnector_Update_BatchUpdate_Delete_BatchDelete(t *testing.T) {
 		t.Error("expected error with ID 5")
 	}
 }
+
+func TestInMemoryConnector_DBTagFieldResolution(t *testing.T) {
+	type event struct {
+		ID        int64  `db:"id"`
+		CreatedAt int64  `db:"created_at"`
+		OwnerID   string `db:"owner_ref"`
+		Note      string
+	}
+
+	ctx := context.Background()
+	repo := NewInMemoryConnector[event, int64](func(e *event) int64 { return e.ID })
+	repo.BatchCreate(ctx, []event{
+		{ID: 1, CreatedAt: 300, OwnerID: "a", Note: "x"},
+		{ID: 2, CreatedAt: 100, OwnerID: "b", Note: "y"},
+		{ID: 3, CreatedAt: 200, OwnerID: "a", Note: "x"},
+	})
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected []int64
+	}{
+		{"snake_case tag", NewFilter().Where("created_at", OpGreaterThan, 150).OrderBy("created_at", SortAsc).Build(), []int64{3, 1}},
+		{"tag differs from field name", NewFilter().Where("owner_ref", OpEqual, "a").OrderBy("id", SortAsc).Build(), []int64{1, 3}},
+		{"go field name", NewFilter().Where("OwnerID", OpEqual, "b").Build(), []int64{2}},
+		{"untagged field", NewFilter().Where("note", OpEqual, "y").Build(), []int64{2}},
+		{"unknown field", NewFilter().Where("missing", OpEqual, "x").Build(), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.Query(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if len(results) != len(tt.expected) {
+				t.Fatalf("Expected %d results, got %d", len(tt.expected), len(results))
+			}
+			for i, id := range tt.expected {
+				if results[i].ID != id {
+					t.Errorf("Expected ID %d at position %d, got %d", id, i, results[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestInMemoryConnector_FindOne(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[testutils.Account, int64](func(a *testutils.Account) int64 { return a.ID })
+	_ = repo.BatchCreate(ctx, []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}, {ID: 3, Balance: 30}})
+
+	filter := NewFilter().Where("balance", OpGreaterThan, 15).OrderBy("balance", SortDesc).Build()
+	got, err := repo.FindOne(ctx, filter)
+	if err != nil {
+		t.Fatalf("FindOne failed: %v", err)
+	}
+	if got.ID != 3 {
+		t.Errorf("Expected the highest balance account, got %+v", got)
+	}
+	if filter.Limit != nil {
+		t.Error("FindOne should not modify the caller's filter")
+	}
+
+	_, err = repo.FindOne(ctx, NewFilter().Where("balance", OpGreaterThan, 100).Build())
+	if !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+
+	if _, err := repo.FindOne(ctx, nil); err == nil {
+		t.Error("Expected error for nil filter")
+	}
+}
+
+type embeddedCustomer struct {
+	baseEntity
+	Name string `db:"name"`
+	*AuditFields
+}
+
+func TestInMemoryConnector_EmbeddedFields(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[embeddedCustomer, int64](func(c *embeddedCustomer) int64 { return c.ID })
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := []embeddedCustomer{
+		{baseEntity: baseEntity{ID: 1, CreatedAt: day.AddDate(0, 0, 2)}, Name: "a"},
+		{baseEntity: baseEntity{ID: 2, CreatedAt: day}, Name: "b", AuditFields: &AuditFields{UpdatedBy: "bob"}},
+		{baseEntity: baseEntity{ID: 3, CreatedAt: day.AddDate(0, 0, 1)}, Name: "c"},
+	}
+	if err := repo.BatchCreate(ctx, items); err != nil {
+		t.Fatalf("BatchCreate failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected []int64
+	}{
+		{"embedded column", NewFilter().Where("id", OpGreaterThan, int64(1)).OrderBy("id", SortAsc).Build(), []int64{2, 3}},
+		{"promoted Go field name", NewFilter().Where("CreatedAt", OpGreaterThan, day).OrderBy("created_at", SortDesc).Build(), []int64{1, 3}},
+		{"nil embedded pointer is NULL", NewFilter().Where("updated_by", OpIsNull, nil).OrderBy("id", SortAsc).Build(), []int64{1, 3}},
+		{"embedded pointer", NewFilter().Where("updated_by", OpEqual, "bob").Build(), []int64{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.Query(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			var ids []int64
+			for _, r := range results {
+				ids = append(ids, r.ID)
+			}
+			if !slices.Equal(ids, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, ids)
+			}
+		})
+	}
+
+	n, err := repo.UpdateWhere(ctx, NewFilter().Where("id", OpIn, []int64{1, 2}).Build(), map[string]any{"created_at": day, "updated_by": "eve"})
+	if err != nil || n != 2 {
+		t.Fatalf("UpdateWhere failed: %d, %v", n, err)
+	}
+	if got, _ := repo.Get(ctx, 1); !got.CreatedAt.Equal(day) || got.AuditFields == nil || got.UpdatedBy != "eve" {
+		t.Errorf("Expected the embedded fields to be updated, got %+v", got)
+	}
+	if items[1].UpdatedBy != "bob" {
+		t.Error("Expected UpdateWhere to leave the embedded pointer of the stored item alone")
+	}
+}
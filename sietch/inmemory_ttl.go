@@ -0,0 +1,88 @@
+package sietch
+
+import (
+	"time"
+)
+
+// MaxTTLSweepInterval bounds the interval at which a connector created by
+// NewInMemoryConnectorWithTTL deletes expired items
+const MaxTTLSweepInterval = time.Minute
+
+// NewInMemoryConnectorWithTTL creates an InMemoryConnector whose items
+// expire ttl after they were last written, like Redis keys set with a TTL,
+// for tests relying on expiry. Expired items are no longer read, and a
+// background sweeper deletes them every ttl (at most every
+// MaxTTLSweepInterval); Close stops it.
+//
+// Example:
+//
+//	repo := sietch.NewInMemoryConnectorWithTTL[Session, string](getID, 30*time.Minute)
+//	defer repo.Close()
+func NewInMemoryConnectorWithTTL[T any, ID comparable](getID func(t *T) ID, ttl time.Duration) *InMemoryConnector[T, ID] {
+	r := NewInMemoryConnector(getID)
+	if ttl <= 0 {
+		return r
+	}
+	r.ttl = ttl
+	r.stopSweep = make(chan struct{})
+	go r.sweep(min(ttl, MaxTTLSweepInterval))
+	return r
+}
+
+// Close stops the sweeper of expired items, if any
+func (r *InMemoryConnector[T, ID]) Close() error {
+	r.stopOnce.Do(func() {
+		if r.stopSweep != nil {
+			close(r.stopSweep)
+		}
+	})
+	return nil
+}
+
+// sweep deletes expired items every interval until Close
+func (r *InMemoryConnector[T, ID]) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stopSweep:
+			return
+		case now := <-ticker.C:
+			r.dropExpired(now)
+		}
+	}
+}
+
+// dropExpired deletes the items expired at now, locking one shard at a time
+func (r *InMemoryConnector[T, ID]) dropExpired(now time.Time) {
+	for _, s := range r.shards {
+		s.mu.Lock()
+		for id := range s.expires {
+			if s.expired(id, now) {
+				r.drop(s, id)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// get returns the item stored under id, unless it expired, and marks it
+// used. The caller holds the lock of s, for reading at least.
+func (s *shard[T, ID]) get(id ID) (*T, bool) {
+	item, ok := s.data[id]
+	if !ok || s.expired(id, time.Now()) {
+		return nil, false
+	}
+	s.touch(id)
+	return item, true
+}
+
+// expired reports whether the item stored under id expired at now. The
+// caller holds the lock of s.
+func (s *shard[T, ID]) expired(id ID, now time.Time) bool {
+	if len(s.expires) == 0 {
+		return false
+	}
+	expires, ok := s.expires[id]
+	return ok && !now.Before(expires)
+}
@@ -0,0 +1,90 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestInMemoryConnectorWithTTL(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	const ttl = 100 * time.Millisecond
+
+	t.Run("Items expire", func(t *testing.T) {
+		repo := NewInMemoryConnectorWithTTL[testutils.Account](getID, ttl)
+		defer repo.Close()
+		_ = repo.BatchCreate(ctx, []testutils.Account{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}})
+		if exists, _ := repo.Exists(ctx, 1); !exists {
+			t.Fatal("Expected the item before its TTL")
+		}
+
+		time.Sleep(ttl / 2)
+		_ = repo.Update(ctx, &testutils.Account{ID: 2, Balance: 21}) // writes restart the TTL
+		time.Sleep(ttl/2 + 20*time.Millisecond)
+
+		if _, err := repo.Get(ctx, 1); !errors.Is(err, ErrItemNotFound) {
+			t.Errorf("Expected the expired item to be gone, got %v", err)
+		}
+		if count, _ := repo.Count(ctx, &Filter{}); count != 1 {
+			t.Errorf("Expected 1 live item, got %d", count)
+		}
+		if err := repo.Create(ctx, &testutils.Account{ID: 1}); err != nil {
+			t.Errorf("Expected the ID of an expired item to be free, got %v", err)
+		}
+	})
+
+	t.Run("Sweeper deletes expired items", func(t *testing.T) {
+		repo := NewInMemoryConnectorWithTTL[testutils.Account](getID, ttl)
+		defer repo.Close()
+		_ = repo.Create(ctx, &testutils.Account{ID: 1})
+
+		deadline := time.Now().Add(time.Second)
+		for repo.len() != 0 && time.Now().Before(deadline) {
+			time.Sleep(ttl / 2)
+		}
+		if n := repo.len(); n != 0 {
+			t.Errorf("Expected the sweeper to delete the item, %d left", n)
+		}
+	})
+
+	t.Run("Rollbacks keep expiry", func(t *testing.T) {
+		repo := NewInMemoryConnectorWithTTL[testutils.Account](getID, ttl)
+		defer repo.Close()
+		_ = repo.Create(ctx, &testutils.Account{ID: 1})
+		_ = repo.WithTx(ctx, func(tx Repository[testutils.Account, int64]) error {
+			_ = tx.Delete(ctx, 1)
+			return errors.New("rollback")
+		})
+		if exists, _ := repo.Exists(ctx, 1); !exists {
+			t.Fatal("Expected the rolled back delete to restore the item")
+		}
+		time.Sleep(ttl + 20*time.Millisecond)
+		if exists, _ := repo.Exists(ctx, 1); exists {
+			t.Error("Expected the restored item to expire on time")
+		}
+	})
+
+	t.Run("Append-only files keep expiry", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sessions.aof")
+		repo := NewInMemoryConnectorWithTTL[testutils.Account](getID, time.Hour)
+		defer repo.Close()
+		_ = repo.EnableAppendOnlyFile(path)
+		_ = repo.Create(ctx, &testutils.Account{ID: 1})
+		_ = repo.CloseAppendOnlyFile()
+
+		reopened := NewInMemoryConnector[testutils.Account](getID)
+		if err := reopened.EnableAppendOnlyFile(path); err != nil {
+			t.Fatalf("EnableAppendOnlyFile failed: %v", err)
+		}
+		defer reopened.CloseAppendOnlyFile()
+		s := reopened.shard(1)
+		if expires, ok := s.expires[1]; !ok || time.Until(expires) < 59*time.Minute {
+			t.Errorf("Expected the replayed item to keep its expiry, got %v", expires)
+		}
+	})
+}
@@ -3,30 +3,36 @@ package sietch
 import (
 	"context"
 	"fmt"
+	"maps"
+	"reflect"
+	"time"
 )
 
 // WithTx executes the given function within a transaction simulation.
 // For InMemory connector, this creates a snapshot of the data, executes the function,
 // and either commits (keeps changes) or rollbacks (restores snapshot) based on the result.
 func (r *InMemoryConnector[T, ID]) WithTx(ctx context.Context, fn TxFunc[T, ID]) error {
-	r.mu.Lock()
+	unlock := r.lockAll()
 
 	// Create snapshot of current data
-	snapshot := make(map[ID]*T)
-	for k, v := range r.data {
-		// Create a copy of the value
-		copyValue := *v
-		snapshot[k] = &copyValue
+	snapshot := make([]map[ID]*T, len(r.shards))
+	expires := make([]map[ID]time.Time, len(r.shards))
+	for i, s := range r.shards {
+		expires[i] = maps.Clone(s.expires)
+		snapshot[i] = make(map[ID]*T, len(s.data))
+		for k, v := range s.data {
+			// Create a copy of the value
+			copyValue := *v
+			snapshot[i][k] = &copyValue
+		}
 	}
-	r.mu.Unlock()
+	unlock()
 
 	// Defer rollback in case of panic
 	defer func() {
 		if p := recover(); p != nil {
-			r.mu.Lock()
 			// Restore from snapshot
-			r.data = snapshot
-			r.mu.Unlock()
+			r.restore(snapshot, expires)
 			panic(p)
 		}
 	}()
@@ -34,13 +40,38 @@ func (r *InMemoryConnector[T, ID]) WithTx(ctx context.Context, fn TxFunc[T, ID])
 	// Execute the user function
 	err := fn(r)
 	if err != nil {
-		r.mu.Lock()
 		// Rollback: restore from snapshot
-		r.data = snapshot
-		r.mu.Unlock()
+		r.restore(snapshot, expires)
 		return fmt.Errorf("tx error: %w", err)
 	}
 
-	// Commit: changes are already in r.data, just discard snapshot
+	// Commit: changes are already in the shards, just discard snapshot
 	return nil
 }
+
+// restore undoes the writes made since snapshot, keeping the expiry of the
+// items: only the items that changed are written back or dropped, so the
+// append-only file records the inverse of the transaction, not every item.
+func (r *InMemoryConnector[T, ID]) restore(snapshot []map[ID]*T, expires []map[ID]time.Time) {
+	defer r.lockAll()()
+
+	now := time.Now()
+	for i, s := range r.shards {
+		for id := range s.data {
+			if _, ok := snapshot[i][id]; !ok {
+				r.drop(s, id)
+			}
+		}
+		for id, item := range snapshot[i] {
+			current, ok := s.data[id]
+			expiry := expires[i][id]
+			switch {
+			case ok && s.expires[id].Equal(expiry) && reflect.DeepEqual(*current, *item):
+				continue // untouched
+			case !ok && !expiry.IsZero() && !now.Before(expiry):
+				continue // expired during the transaction
+			}
+			r.store(s, id, item, expiry)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package sietch
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// LoggedRepository wraps a repository and logs every operation with its
+// duration and error through a QueryLogger's LogOperation. If the base
+// repository implements LoggableRepository, as CockroachDBConnector does, it
+// is given the same logger, so the SQL and arguments of each statement are
+// logged with LogQuery, tagged with the repository operation they run for.
+//
+//	repo := sietch.NewLoggedRepository(conn, sietch.NewConsoleLogger(sietch.LogLevelInfo))
+//	account, err := repo.Get(ctx, id)
+//	// [INFO] Get - Query: SELECT ... | Duration: 1.2ms | Args: [42] | Error: <nil>
+//	// [INFO] Get on Account | Duration: 1.3ms | Error: <nil>
+type LoggedRepository[T any, ID comparable] struct {
+	base       Repository[T, ID]
+	logger     QueryLogger
+	entityType string
+}
+
+// NewLoggedRepository creates a repository logging the operations of base
+// to logger; a nil logger logs nothing until SetLogger is called
+func NewLoggedRepository[T any, ID comparable](base Repository[T, ID], logger QueryLogger) *LoggedRepository[T, ID] {
+	r := &LoggedRepository[T, ID]{
+		base:       base,
+		entityType: reflect.TypeFor[T]().Name(),
+	}
+	r.SetLogger(logger)
+	return r
+}
+
+// SetLogger sets the logger of the repository and of its base, if the base
+// implements LoggableRepository. Not safe to call concurrently with
+// operations.
+func (r *LoggedRepository[T, ID]) SetLogger(logger QueryLogger) {
+	r.logger = logger
+	if loggable, ok := r.base.(LoggableRepository); ok {
+		loggable.SetLogger(logger)
+	}
+}
+
+// GetLogger returns the repository's logger
+func (r *LoggedRepository[T, ID]) GetLogger() QueryLogger {
+	return r.logger
+}
+
+// start tags ctx with operation for the statements of the base repository
+func (r *LoggedRepository[T, ID]) start(ctx context.Context, operation string) (context.Context, time.Time) {
+	return withOperation(ctx, operation), time.Now()
+}
+
+func (r *LoggedRepository[T, ID]) log(ctx context.Context, operation string, start time.Time, err error) {
+	logOperation(r.logger, ctx, operation, r.entityType, start, err)
+}
+
+func (r *LoggedRepository[T, ID]) Create(ctx context.Context, item *T) error {
+	ctx, start := r.start(ctx, "Create")
+	err := r.base.Create(ctx, item)
+	r.log(ctx, "Create", start, err)
+	return err
+}
+
+func (r *LoggedRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	ctx, start := r.start(ctx, "Get")
+	item, err := r.base.Get(ctx, id)
+	r.log(ctx, "Get", start, err)
+	return item, err
+}
+
+func (r *LoggedRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	ctx, start := r.start(ctx, "GetMany")
+	items, err := r.base.GetMany(ctx, ids)
+	r.log(ctx, "GetMany", start, err)
+	return items, err
+}
+
+func (r *LoggedRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	ctx, start := r.start(ctx, "Exists")
+	exists, err := r.base.Exists(ctx, id)
+	r.log(ctx, "Exists", start, err)
+	return exists, err
+}
+
+func (r *LoggedRepository[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	ctx, start := r.start(ctx, "BatchCreate")
+	err := r.base.BatchCreate(ctx, items)
+	r.log(ctx, "BatchCreate", start, err)
+	return err
+}
+
+func (r *LoggedRepository[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
+	ctx, start := r.start(ctx, "Query")
+	results, err := r.base.Query(ctx, filter)
+	r.log(ctx, "Query", start, err)
+	return results, err
+}
+
+func (r *LoggedRepository[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	ctx, start := r.start(ctx, "FindOne")
+	item, err := r.base.FindOne(ctx, filter)
+	r.log(ctx, "FindOne", start, err)
+	return item, err
+}
+
+func (r *LoggedRepository[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
+	ctx, start := r.start(ctx, "Count")
+	count, err := r.base.Count(ctx, filter)
+	r.log(ctx, "Count", start, err)
+	return count, err
+}
+
+func (r *LoggedRepository[T, ID]) Update(ctx context.Context, item *T) error {
+	ctx, start := r.start(ctx, "Update")
+	err := r.base.Update(ctx, item)
+	r.log(ctx, "Update", start, err)
+	return err
+}
+
+func (r *LoggedRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	ctx, start := r.start(ctx, "BatchUpdate")
+	err := r.base.BatchUpdate(ctx, items)
+	r.log(ctx, "BatchUpdate", start, err)
+	return err
+}
+
+func (r *LoggedRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	ctx, start := r.start(ctx, "Delete")
+	err := r.base.Delete(ctx, id)
+	r.log(ctx, "Delete", start, err)
+	return err
+}
+
+func (r *LoggedRepository[T, ID]) BatchDelete(ctx context.Context, ids []ID) error {
+	ctx, start := r.start(ctx, "BatchDelete")
+	err := r.base.BatchDelete(ctx, ids)
+	r.log(ctx, "BatchDelete", start, err)
+	return err
+}
+
+func (r *LoggedRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	ctx, start := r.start(ctx, "Upsert")
+	err := r.base.Upsert(ctx, item)
+	r.log(ctx, "Upsert", start, err)
+	return err
+}
+
+func (r *LoggedRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	ctx, start := r.start(ctx, "BatchUpsert")
+	err := r.base.BatchUpsert(ctx, items)
+	r.log(ctx, "BatchUpsert", start, err)
+	return err
+}
+
+func (r *LoggedRepository[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	ctx, start := r.start(ctx, "UpdateWhere")
+	n, err := r.base.UpdateWhere(ctx, filter, updates)
+	r.log(ctx, "UpdateWhere", start, err)
+	return n, err
+}
+
+func (r *LoggedRepository[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	ctx, start := r.start(ctx, "DeleteWhere")
+	n, err := r.base.DeleteWhere(ctx, filter)
+	r.log(ctx, "DeleteWhere", start, err)
+	return n, err
+}
+
+// GroupCount delegates to the base repository if it implements Grouper
+func (r *LoggedRepository[T, ID]) GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error) {
+	grouper, ok := r.base.(Grouper)
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	ctx, start := r.start(ctx, "GroupCount")
+	groups, err := grouper.GroupCount(ctx, filter)
+	r.log(ctx, "GroupCount", start, err)
+	return groups, err
+}
+
+// GetForUpdate delegates to the base repository if it implements RowLocker
+func (r *LoggedRepository[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
+	locker, ok := r.base.(RowLocker[T, ID])
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	ctx, start := r.start(ctx, "GetForUpdate")
+	item, err := locker.GetForUpdate(ctx, id)
+	r.log(ctx, "GetForUpdate", start, err)
+	return item, err
+}
+
+// WithTx runs fn in a transaction of the base repository, which must
+// implement Transactional, and logs it as a "WithTx" operation. The
+// repository passed to fn logs its operations too.
+func (r *LoggedRepository[T, ID]) WithTx(ctx context.Context, fn TxFunc[T, ID]) error {
+	txRepo, ok := r.base.(Transactional[T, ID])
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	start := time.Now()
+	err := txRepo.WithTx(ctx, func(tx Repository[T, ID]) error {
+		return fn(&LoggedRepository[T, ID]{base: tx, logger: r.logger, entityType: r.entityType})
+	})
+	r.log(ctx, "WithTx", start, err)
+	return err
+}
@@ -0,0 +1,108 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+// recordingLogger keeps its entries as "operation query args error" and
+// "operation on entity error" lines
+type recordingLogger struct {
+	queries    []string
+	operations []string
+}
+
+func (l *recordingLogger) LogQuery(_ context.Context, operation string, query string, args []any, _ time.Duration, err error) {
+	l.queries = append(l.queries, fmt.Sprintf("%s %s %v %v", operation, query, args, err))
+}
+
+func (l *recordingLogger) LogOperation(_ context.Context, operation string, entityType string, _ time.Duration, err error) {
+	l.operations = append(l.operations, fmt.Sprintf("%s on %s %v", operation, entityType, err))
+}
+
+// batchRecordingTx is a recordingTx that also accepts batches
+type batchRecordingTx struct {
+	*recordingTx
+	fakeBatchSender
+}
+
+func TestLoggedRepository(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+
+	t.Run("Operations are logged", func(t *testing.T) {
+		logger := &recordingLogger{}
+		repo := NewLoggedRepository(NewInMemoryConnector[testutils.Account](getID), logger)
+		var _ LoggableRepository = repo
+
+		_ = repo.Create(ctx, &testutils.Account{ID: 1, Balance: 10})
+		_, _ = repo.Get(ctx, 2)
+		_ = repo.WithTx(ctx, func(tx Repository[testutils.Account, int64]) error {
+			return tx.Delete(ctx, 1)
+		})
+
+		expected := []string{
+			"Create on Account <nil>",
+			"Get on Account " + ErrItemNotFound.Error(),
+			"Delete on Account <nil>",
+			"WithTx on Account <nil>",
+		}
+		if !reflect.DeepEqual(logger.operations, expected) {
+			t.Errorf("Unexpected operations %v", logger.operations)
+		}
+		if len(logger.queries) != 0 {
+			t.Errorf("Expected no queries for the in-memory backend, got %v", logger.queries)
+		}
+
+		plain := NewLoggedRepository[testutils.Account, int64](&countingRepository{}, logger)
+		if err := plain.WithTx(ctx, nil); !errors.Is(err, ErrUnsupportedOperation) {
+			t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+		}
+	})
+
+	t.Run("SQL statements are logged with their operation", func(t *testing.T) {
+		logger := &recordingLogger{}
+		conn := newBatchConnector(t)
+		NewLoggedRepository[testutils.Account, int64](conn, logger)
+		if conn.GetLogger() != logger {
+			t.Fatal("Expected the logger to be set on the connector")
+		}
+
+		tx := &batchRecordingTx{recordingTx: &recordingTx{}}
+		repo := NewLoggedRepository[testutils.Account, int64](&cockroachDBTx[testutils.Account, int64]{connector: conn, tx: conn.loggedTx(tx), ctx: ctx}, logger)
+
+		if _, err := repo.Get(ctx, 1); !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("Expected pgx.ErrNoRows, got %v", err)
+		}
+		if err := repo.BatchDelete(ctx, []int64{1, 2}); err != nil {
+			t.Fatalf("BatchDelete failed: %v", err)
+		}
+		if _, err := conn.loggedTx(tx).Exec(ctx, "select 1"); err != nil {
+			t.Fatalf("Exec failed: %v", err)
+		}
+
+		expected := []string{
+			"Get " + conn.statement(stmtGet) + " [1] " + pgx.ErrNoRows.Error(),
+			"BatchDelete " + conn.statement(stmtDelete) + " [1 2] <nil>",
+			"SELECT select 1 [] <nil>",
+		}
+		if !reflect.DeepEqual(logger.queries, expected) {
+			t.Errorf("Unexpected queries %v", logger.queries)
+		}
+		if len(logger.operations) != 2 {
+			t.Errorf("Expected 2 operations, got %v", logger.operations)
+		}
+
+		conn.SetLogger(nil)
+		if _, ok := conn.getQueryable(ctx).(*loggedQueryable); ok {
+			t.Error("Expected statements not to be logged without a logger")
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package migrate
+
+import "context"
+
+// DefaultRegistry is the registry used by the package-level functions, so
+// Go migrations can register themselves from init functions:
+//
+//	func init() {
+//	    migrate.MustRegister(migrate.Migration{Version: 4, Name: "split_names", Up: splitNames, Down: joinNames})
+//	}
+var DefaultRegistry = NewRegistry()
+
+// Register adds migrations to DefaultRegistry
+func Register(migrations ...Migration) error {
+	return DefaultRegistry.Register(migrations...)
+}
+
+// MustRegister is like Register but panics on error
+func MustRegister(migrations ...Migration) {
+	if err := Register(migrations...); err != nil {
+		panic(err)
+	}
+}
+
+// Migrate applies the pending migrations of DefaultRegistry
+func Migrate(ctx context.Context, db DB) error {
+	return DefaultRegistry.Migrate(ctx, db)
+}
+
+// Rollback reverts the latest applied migration of DefaultRegistry
+func Rollback(ctx context.Context, db DB) error {
+	return DefaultRegistry.Rollback(ctx, db)
+}
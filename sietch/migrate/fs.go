@@ -0,0 +1,85 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// LoadFS registers the SQL migrations in the root directory of fsys. Files
+// are named <version>_<name>.up.sql and, optionally, <version>_<name>.down.sql,
+// e.g. 0001_create_accounts.up.sql. Other files are ignored.
+func (r *Registry) LoadFS(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+
+	files := make(map[int64]*Migration)
+	var versions []int64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		version, name, up, ok := parseFileName(entry.Name())
+		if !ok {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return err
+		}
+
+		m, seen := files[version]
+		if !seen {
+			m = &Migration{Version: version, Name: name}
+			files[version] = m
+			versions = append(versions, version)
+		} else if m.Name != name {
+			return fmt.Errorf("%w: %d (%s and %s)", ErrDuplicateVersion, version, m.Name, name)
+		}
+		if up {
+			m.Up = SQL(string(content))
+		} else {
+			m.Down = SQL(string(content))
+		}
+	}
+
+	for _, version := range versions {
+		m := files[version]
+		if m.Up == nil {
+			return fmt.Errorf("migration %d (%s): missing up file", m.Version, m.Name)
+		}
+		if err := r.Register(*m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseFileName splits 0001_create_accounts.up.sql into its version, name
+// and direction
+func parseFileName(file string) (version int64, name string, up bool, ok bool) {
+	base, found := strings.CutSuffix(file, ".sql")
+	if !found {
+		return 0, "", false, false
+	}
+	switch ext := path.Ext(base); ext {
+	case ".up":
+		up = true
+	case ".down":
+	default:
+		return 0, "", false, false
+	}
+	base = strings.TrimSuffix(base, path.Ext(base))
+
+	num, name, _ := strings.Cut(base, "_")
+	version, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return 0, "", false, false
+	}
+	return version, name, up, true
+}
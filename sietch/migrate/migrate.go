@@ -0,0 +1,338 @@
+// Package migrate applies versioned schema migrations to CockroachDB or
+// PostgreSQL and records them in a tracking table.
+//
+// Migrations are registered with a Registry, either as Go functions or as
+// SQL files, and applied in version order:
+//
+//	//go:embed migrations/*.sql
+//	var files embed.FS
+//
+//	reg := migrate.NewRegistry()
+//	sub, _ := fs.Sub(files, "migrations")
+//	if err := reg.LoadFS(sub); err != nil {
+//	    return err
+//	}
+//	reg.Register(migrate.Migration{
+//	    Version: 3,
+//	    Name:    "backfill_balances",
+//	    Up:      backfillBalances,
+//	})
+//	if err := reg.Migrate(ctx, pool); err != nil {
+//	    return err
+//	}
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DefaultTable is the name of the table recording applied migrations
+const DefaultTable = "schema_migrations"
+
+var (
+	ErrDuplicateVersion = errors.New("duplicate migration version")
+	ErrIrreversible     = errors.New("migration cannot be rolled back")
+	ErrUnknownVersion   = errors.New("applied migration is not registered")
+)
+
+// Executor runs the statements of a migration. It is implemented by pgx.Tx,
+// *pgxpool.Pool and *pgx.Conn.
+type Executor interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// DB is the database migrations are applied to, e.g. *pgxpool.Pool
+type DB interface {
+	Executor
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Func is one direction of a migration
+type Func func(ctx context.Context, exec Executor) error
+
+// SQL returns a Func running the given statements as a single
+// multi-statement query
+func SQL(statements string) Func {
+	return func(ctx context.Context, exec Executor) error {
+		_, err := exec.Exec(ctx, statements)
+		return err
+	}
+}
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version int64  // unique, positive; migrations are applied in ascending order
+	Name    string // recorded in the tracking table, e.g. "create_accounts"
+	Up      Func
+	Down    Func // nil if the migration cannot be rolled back
+
+	// NoTx runs the migration outside a transaction, for statements that
+	// cannot run in one. It is recorded after Up succeeds, so a failure
+	// halfway leaves a partially applied migration to repair by hand.
+	NoTx bool
+}
+
+// Applied is a migration recorded in the tracking table
+type Applied struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+}
+
+// Registry holds the known migrations
+type Registry struct {
+	// Table is the tracking table, DefaultTable if empty
+	Table string
+
+	migrations []Migration // sorted by version
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds migrations to the registry
+func (r *Registry) Register(migrations ...Migration) error {
+	for _, m := range migrations {
+		if m.Version <= 0 {
+			return fmt.Errorf("migration %q: version must be positive", m.Name)
+		}
+		if m.Up == nil {
+			return fmt.Errorf("migration %d: Up cannot be nil", m.Version)
+		}
+		i, found := r.find(m.Version)
+		if found {
+			return fmt.Errorf("%w: %d", ErrDuplicateVersion, m.Version)
+		}
+		r.migrations = slices.Insert(r.migrations, i, m)
+	}
+	return nil
+}
+
+// Migrations returns the registered migrations in version order
+func (r *Registry) Migrations() []Migration {
+	return slices.Clone(r.migrations)
+}
+
+// find returns the position of version in r.migrations, or where it would be
+func (r *Registry) find(version int64) (int, bool) {
+	return slices.BinarySearchFunc(r.migrations, version, func(m Migration, v int64) int {
+		switch {
+		case m.Version < v:
+			return -1
+		case m.Version > v:
+			return 1
+		}
+		return 0
+	})
+}
+
+func (r *Registry) table() string {
+	if r.Table == "" {
+		return DefaultTable
+	}
+	return r.Table
+}
+
+// Migrate applies every pending migration in version order. Migrations with
+// a version lower than the latest applied one, e.g. merged from another
+// branch, are applied too.
+func (r *Registry) Migrate(ctx context.Context, db DB) error {
+	return r.MigrateTo(ctx, db, 0)
+}
+
+// MigrateTo applies the pending migrations up to and including version; 0
+// means all of them. Each migration runs in its own transaction together
+// with its tracking row, so a failing migration leaves the earlier ones
+// applied and nothing of its own. Concurrent migrators conflict on the
+// tracking row and all but one fail.
+func (r *Registry) MigrateTo(ctx context.Context, db DB, version int64) error {
+	if err := r.ensureTable(ctx, db); err != nil {
+		return err
+	}
+	applied, err := r.appliedVersions(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range r.migrations {
+		if version > 0 && m.Version > version {
+			break
+		}
+		if applied[m.Version] {
+			continue
+		}
+		if err := r.apply(ctx, db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// Rollback reverts the latest applied migration. It does nothing if no
+// migration is applied.
+func (r *Registry) Rollback(ctx context.Context, db DB) error {
+	applied, err := r.Applied(ctx, db)
+	if err != nil || len(applied) == 0 {
+		return err
+	}
+	target := int64(0)
+	if len(applied) > 1 {
+		target = applied[len(applied)-2].Version
+	}
+	return r.rollback(ctx, db, applied, target)
+}
+
+// RollbackTo reverts the applied migrations newer than version, latest
+// first; 0 reverts all of them. It fails before reverting anything if one of
+// them is not registered or has no Down.
+func (r *Registry) RollbackTo(ctx context.Context, db DB, version int64) error {
+	applied, err := r.Applied(ctx, db)
+	if err != nil || len(applied) == 0 {
+		return err
+	}
+	return r.rollback(ctx, db, applied, version)
+}
+
+// rollback reverts the applied migrations newer than target
+func (r *Registry) rollback(ctx context.Context, db DB, applied []Applied, target int64) error {
+	var revert []Migration
+	for _, a := range slices.Backward(applied) {
+		if a.Version <= target {
+			break
+		}
+		i, found := r.find(a.Version)
+		if !found {
+			return fmt.Errorf("%w: %d (%s)", ErrUnknownVersion, a.Version, a.Name)
+		}
+		m := r.migrations[i]
+		if m.Down == nil {
+			return fmt.Errorf("%w: %d (%s)", ErrIrreversible, m.Version, m.Name)
+		}
+		revert = append(revert, m)
+	}
+
+	for _, m := range revert {
+		if err := r.revert(ctx, db, m); err != nil {
+			return fmt.Errorf("rollback of migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// Applied returns the applied migrations in version order
+func (r *Registry) Applied(ctx context.Context, db DB) ([]Applied, error) {
+	if err := r.ensureTable(ctx, db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(ctx, fmt.Sprintf(`SELECT "version", "name", "applied_at" FROM %s ORDER BY "version"`, quote(r.table())))
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Applied, error) {
+		var a Applied
+		err := row.Scan(&a.Version, &a.Name, &a.AppliedAt)
+		return a, err
+	})
+}
+
+// Pending returns the registered migrations that are not applied yet
+func (r *Registry) Pending(ctx context.Context, db DB) ([]Migration, error) {
+	if err := r.ensureTable(ctx, db); err != nil {
+		return nil, err
+	}
+	applied, err := r.appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range r.migrations {
+		if !applied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+func (r *Registry) appliedVersions(ctx context.Context, db DB) (map[int64]bool, error) {
+	rows, err := db.Query(ctx, fmt.Sprintf(`SELECT "version" FROM %s`, quote(r.table())))
+	if err != nil {
+		return nil, err
+	}
+	versions, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+func (r *Registry) ensureTable(ctx context.Context, db DB) error {
+	_, err := db.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+  "version" BIGINT PRIMARY KEY,
+  "name" TEXT NOT NULL,
+  "applied_at" TIMESTAMPTZ NOT NULL DEFAULT now()
+)`, quote(r.table())))
+	return err
+}
+
+// apply runs m.Up and records m
+func (r *Registry) apply(ctx context.Context, db DB, m Migration) error {
+	record := fmt.Sprintf(`INSERT INTO %s ("version", "name") VALUES ($1, $2)`, quote(r.table()))
+	return r.run(ctx, db, m.NoTx, func(exec Executor) error {
+		if err := m.Up(ctx, exec); err != nil {
+			return err
+		}
+		_, err := exec.Exec(ctx, record, m.Version, m.Name)
+		return err
+	})
+}
+
+// revert runs m.Down and deletes the record of m
+func (r *Registry) revert(ctx context.Context, db DB, m Migration) error {
+	record := fmt.Sprintf(`DELETE FROM %s WHERE "version" = $1`, quote(r.table()))
+	return r.run(ctx, db, m.NoTx, func(exec Executor) error {
+		if err := m.Down(ctx, exec); err != nil {
+			return err
+		}
+		_, err := exec.Exec(ctx, record, m.Version)
+		return err
+	})
+}
+
+// run calls fn with db, or with a transaction committed if fn succeeds
+func (r *Registry) run(ctx context.Context, db DB, noTx bool, fn func(exec Executor) error) error {
+	if noTx {
+		return fn(db)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
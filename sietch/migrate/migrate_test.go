@@ -0,0 +1,299 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeDB keeps the tracking table in memory and logs every other statement
+type fakeDB struct {
+	applied map[int64]string
+	log     []string
+	failOn  string // statements containing it fail
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{applied: make(map[int64]string)}
+}
+
+func (f *fakeDB) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if f.failOn != "" && strings.Contains(sql, f.failOn) {
+		return pgconn.CommandTag{}, fmt.Errorf("failed: %s", sql)
+	}
+	switch {
+	case strings.HasPrefix(sql, "CREATE TABLE IF NOT EXISTS"):
+	case strings.HasPrefix(sql, "INSERT INTO"):
+		f.applied[args[0].(int64)] = args[1].(string)
+	case strings.HasPrefix(sql, "DELETE FROM"):
+		delete(f.applied, args[0].(int64))
+	default:
+		f.log = append(f.log, sql)
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func (f *fakeDB) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, error) {
+	rows := &fakeRows{}
+	for _, version := range slices.Sorted(maps.Keys(f.applied)) {
+		if strings.Contains(sql, `"name"`) {
+			rows.values = append(rows.values, []any{version, f.applied[version], time.Time{}})
+		} else {
+			rows.values = append(rows.values, []any{version})
+		}
+	}
+	return rows, nil
+}
+
+func (f *fakeDB) QueryRow(context.Context, string, ...any) pgx.Row {
+	panic("not used")
+}
+
+func (f *fakeDB) Begin(context.Context) (pgx.Tx, error) {
+	return &fakeTx{db: f, applied: maps.Clone(f.applied), logged: len(f.log)}, nil
+}
+
+// fakeTx runs statements on its fakeDB and restores it on rollback
+type fakeTx struct {
+	pgx.Tx
+	db      *fakeDB
+	applied map[int64]string
+	logged  int
+}
+
+func (t *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return t.db.Exec(ctx, sql, args...)
+}
+
+func (t *fakeTx) Commit(context.Context) error { return nil }
+
+func (t *fakeTx) Rollback(context.Context) error {
+	t.db.applied = t.applied
+	t.db.log = t.db.log[:t.logged]
+	return nil
+}
+
+type fakeRows struct {
+	pgx.Rows
+	values [][]any
+	next   int
+}
+
+func (r *fakeRows) Next() bool {
+	r.next++
+	return r.next <= len(r.values)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.values[r.next-1][i]))
+	}
+	return nil
+}
+
+func (r *fakeRows) Err() error                    { return nil }
+func (r *fakeRows) Close()                        {}
+func (r *fakeRows) CommandTag() pgconn.CommandTag { return pgconn.CommandTag{} }
+
+func newRegistry(t *testing.T) *Registry {
+	t.Helper()
+	reg := NewRegistry()
+	err := reg.Register(
+		Migration{Version: 2, Name: "add_email", Up: SQL("ALTER 2"), Down: SQL("REVERT 2")},
+		Migration{Version: 1, Name: "create_accounts", Up: SQL("CREATE 1"), Down: SQL("DROP 1")},
+		Migration{Version: 3, Name: "backfill", Up: func(ctx context.Context, exec Executor) error {
+			_, err := exec.Exec(ctx, "UPDATE 3")
+			return err
+		}},
+	)
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	return reg
+}
+
+func TestRegistry_Migrate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Applies pending migrations in order", func(t *testing.T) {
+		reg := newRegistry(t)
+		db := newFakeDB()
+		db.applied[2] = "add_email"
+
+		if err := reg.Migrate(ctx, db); err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		if !reflect.DeepEqual(db.log, []string{"CREATE 1", "UPDATE 3"}) {
+			t.Errorf("Unexpected statements %v", db.log)
+		}
+		if len(db.applied) != 3 || db.applied[3] != "backfill" {
+			t.Errorf("Expected every migration to be recorded, got %v", db.applied)
+		}
+
+		if err := reg.Migrate(ctx, db); err != nil || len(db.log) != 2 {
+			t.Errorf("Expected nothing left to apply, got %v (%v)", db.log, err)
+		}
+	})
+
+	t.Run("Migrates up to a version", func(t *testing.T) {
+		reg := newRegistry(t)
+		db := newFakeDB()
+
+		if err := reg.MigrateTo(ctx, db, 2); err != nil {
+			t.Fatalf("MigrateTo failed: %v", err)
+		}
+		pending, err := reg.Pending(ctx, db)
+		if err != nil || len(pending) != 1 || pending[0].Version != 3 {
+			t.Errorf("Expected migration 3 to be pending, got %v (%v)", pending, err)
+		}
+	})
+
+	t.Run("A failing migration is not recorded", func(t *testing.T) {
+		reg := newRegistry(t)
+		db := newFakeDB()
+		db.failOn = "ALTER 2"
+
+		err := reg.Migrate(ctx, db)
+		if err == nil || !strings.Contains(err.Error(), "migration 2 (add_email)") {
+			t.Fatalf("Expected migration 2 to fail, got %v", err)
+		}
+		if len(db.applied) != 1 || !reflect.DeepEqual(db.log, []string{"CREATE 1"}) {
+			t.Errorf("Expected only migration 1 to be applied, got %v %v", db.applied, db.log)
+		}
+	})
+}
+
+func TestRegistry_Rollback(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Reverts the latest migration", func(t *testing.T) {
+		reg := newRegistry(t)
+		db := newFakeDB()
+		db.applied[1] = "create_accounts"
+		db.applied[2] = "add_email"
+
+		if err := reg.Rollback(ctx, db); err != nil {
+			t.Fatalf("Rollback failed: %v", err)
+		}
+		if len(db.applied) != 1 || !reflect.DeepEqual(db.log, []string{"REVERT 2"}) {
+			t.Errorf("Expected migration 2 to be reverted, got %v %v", db.applied, db.log)
+		}
+	})
+
+	t.Run("Reverts down to a version, latest first", func(t *testing.T) {
+		reg := newRegistry(t)
+		db := newFakeDB()
+		db.applied[1] = "create_accounts"
+		db.applied[2] = "add_email"
+
+		if err := reg.RollbackTo(ctx, db, 0); err != nil {
+			t.Fatalf("RollbackTo failed: %v", err)
+		}
+		if len(db.applied) != 0 || !reflect.DeepEqual(db.log, []string{"REVERT 2", "DROP 1"}) {
+			t.Errorf("Expected every migration to be reverted, got %v %v", db.applied, db.log)
+		}
+	})
+
+	t.Run("Irreversible and unknown migrations stop the rollback", func(t *testing.T) {
+		reg := newRegistry(t)
+		db := newFakeDB()
+		db.applied[2] = "add_email"
+		db.applied[3] = "backfill"
+
+		if err := reg.RollbackTo(ctx, db, 0); !errors.Is(err, ErrIrreversible) {
+			t.Errorf("Expected ErrIrreversible, got %v", err)
+		}
+		if len(db.applied) != 2 || len(db.log) != 0 {
+			t.Errorf("Expected nothing to be reverted, got %v %v", db.applied, db.log)
+		}
+
+		db.applied[4] = "removed"
+		if err := reg.Rollback(ctx, db); !errors.Is(err, ErrUnknownVersion) {
+			t.Errorf("Expected ErrUnknownVersion, got %v", err)
+		}
+	})
+}
+
+func TestRegistry_Register(t *testing.T) {
+	reg := newRegistry(t)
+
+	if err := reg.Register(Migration{Version: 2, Name: "again", Up: SQL("")}); !errors.Is(err, ErrDuplicateVersion) {
+		t.Errorf("Expected ErrDuplicateVersion, got %v", err)
+	}
+	if err := reg.Register(Migration{Version: 0, Up: SQL("")}); err == nil {
+		t.Error("Expected an error for version 0")
+	}
+	if err := reg.Register(Migration{Version: 9}); err == nil {
+		t.Error("Expected an error for a nil Up")
+	}
+
+	var versions []int64
+	for _, m := range reg.Migrations() {
+		versions = append(versions, m.Version)
+	}
+	if !reflect.DeepEqual(versions, []int64{1, 2, 3}) {
+		t.Errorf("Expected migrations in version order, got %v", versions)
+	}
+}
+
+func TestRegistry_LoadFS(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Loads up and down files", func(t *testing.T) {
+		reg := NewRegistry()
+		fsys := fstest.MapFS{
+			"0002_add_email.up.sql":         {Data: []byte("ALTER TABLE accounts ADD COLUMN email TEXT")},
+			"0001_create_accounts.up.sql":   {Data: []byte("CREATE TABLE accounts (id INT8 PRIMARY KEY)")},
+			"0001_create_accounts.down.sql": {Data: []byte("DROP TABLE accounts")},
+			"README.md":                     {Data: []byte("ignored")},
+		}
+		if err := reg.LoadFS(fsys); err != nil {
+			t.Fatalf("LoadFS failed: %v", err)
+		}
+
+		migrations := reg.Migrations()
+		if len(migrations) != 2 || migrations[0].Name != "create_accounts" || migrations[1].Down != nil {
+			t.Fatalf("Unexpected migrations %+v", migrations)
+		}
+
+		db := newFakeDB()
+		if err := reg.Migrate(ctx, db); err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		if err := reg.RollbackTo(ctx, db, 1); !errors.Is(err, ErrIrreversible) {
+			t.Errorf("Expected ErrIrreversible, got %v", err)
+		}
+		if err := reg.MigrateTo(ctx, db, 1); err != nil || db.log[0] != "CREATE TABLE accounts (id INT8 PRIMARY KEY)" {
+			t.Errorf("Unexpected statements %v (%v)", db.log, err)
+		}
+	})
+
+	t.Run("A down file needs an up file", func(t *testing.T) {
+		reg := NewRegistry()
+		fsys := fstest.MapFS{"0001_create_accounts.down.sql": {Data: []byte("DROP TABLE accounts")}}
+		if err := reg.LoadFS(fsys); err == nil {
+			t.Error("Expected an error for a missing up file")
+		}
+	})
+
+	t.Run("Versions must be unique", func(t *testing.T) {
+		reg := NewRegistry()
+		fsys := fstest.MapFS{
+			"0001_create_accounts.up.sql": {Data: []byte("")},
+			"0001_create_orders.up.sql":   {Data: []byte("")},
+		}
+		if err := reg.LoadFS(fsys); !errors.Is(err, ErrDuplicateVersion) {
+			t.Errorf("Expected ErrDuplicateVersion, got %v", err)
+		}
+	})
+}
@@ -163,6 +163,110 @@ func (_c *Repository_BatchUpdate_Call[T, ID]) RunAndReturn(run func(context.Cont
 	return _c
 }
 
+// BatchUpsert provides a mock function with given fields: ctx, items
+func (_m *Repository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	ret := _m.Called(ctx, items)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BatchUpsert")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []T) error); ok {
+		r0 = rf(ctx, items)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_BatchUpsert_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'BatchUpsert'
+type Repository_BatchUpsert_Call[T any, ID comparable] struct {
+	*mock.Call
+}
+
+// BatchUpsert is a helper method to define mock.On call
+//   - ctx context.Context
+//   - items []T
+func (_e *Repository_Expecter[T, ID]) BatchUpsert(ctx interface{}, items interface{}) *Repository_BatchUpsert_Call[T, ID] {
+	return &Repository_BatchUpsert_Call[T, ID]{Call: _e.mock.On("BatchUpsert", ctx, items)}
+}
+
+func (_c *Repository_BatchUpsert_Call[T, ID]) Run(run func(ctx context.Context, items []T)) *Repository_BatchUpsert_Call[T, ID] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]T))
+	})
+	return _c
+}
+
+func (_c *Repository_BatchUpsert_Call[T, ID]) Return(_a0 error) *Repository_BatchUpsert_Call[T, ID] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_BatchUpsert_Call[T, ID]) RunAndReturn(run func(context.Context, []T) error) *Repository_BatchUpsert_Call[T, ID] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Count provides a mock function with given fields: ctx, filter
+func (_m *Repository[T, ID]) Count(ctx context.Context, filter *sietch.Filter) (int64, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Count")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sietch.Filter) (int64, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sietch.Filter) int64); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sietch.Filter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_Count_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Count'
+type Repository_Count_Call[T any, ID comparable] struct {
+	*mock.Call
+}
+
+// Count is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter *sietch.Filter
+func (_e *Repository_Expecter[T, ID]) Count(ctx interface{}, filter interface{}) *Repository_Count_Call[T, ID] {
+	return &Repository_Count_Call[T, ID]{Call: _e.mock.On("Count", ctx, filter)}
+}
+
+func (_c *Repository_Count_Call[T, ID]) Run(run func(ctx context.Context, filter *sietch.Filter)) *Repository_Count_Call[T, ID] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*sietch.Filter))
+	})
+	return _c
+}
+
+func (_c *Repository_Count_Call[T, ID]) Return(_a0 int64, _a1 error) *Repository_Count_Call[T, ID] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_Count_Call[T, ID]) RunAndReturn(run func(context.Context, *sietch.Filter) (int64, error)) *Repository_Count_Call[T, ID] {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Create provides a mock function with given fields: ctx, item
 func (_m *Repository[T, ID]) Create(ctx context.Context, item *T) error {
 	ret := _m.Called(ctx, item)
@@ -257,6 +361,179 @@ func (_c *Repository_Delete_Call[T, ID]) RunAndReturn(run func(context.Context,
 	return _c
 }
 
+// DeleteWhere provides a mock function with given fields: ctx, filter
+func (_m *Repository[T, ID]) DeleteWhere(ctx context.Context, filter *sietch.Filter) (int64, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteWhere")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sietch.Filter) (int64, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sietch.Filter) int64); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sietch.Filter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_DeleteWhere_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteWhere'
+type Repository_DeleteWhere_Call[T any, ID comparable] struct {
+	*mock.Call
+}
+
+// DeleteWhere is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter *sietch.Filter
+func (_e *Repository_Expecter[T, ID]) DeleteWhere(ctx interface{}, filter interface{}) *Repository_DeleteWhere_Call[T, ID] {
+	return &Repository_DeleteWhere_Call[T, ID]{Call: _e.mock.On("DeleteWhere", ctx, filter)}
+}
+
+func (_c *Repository_DeleteWhere_Call[T, ID]) Run(run func(ctx context.Context, filter *sietch.Filter)) *Repository_DeleteWhere_Call[T, ID] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*sietch.Filter))
+	})
+	return _c
+}
+
+func (_c *Repository_DeleteWhere_Call[T, ID]) Return(_a0 int64, _a1 error) *Repository_DeleteWhere_Call[T, ID] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_DeleteWhere_Call[T, ID]) RunAndReturn(run func(context.Context, *sietch.Filter) (int64, error)) *Repository_DeleteWhere_Call[T, ID] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Exists provides a mock function with given fields: ctx, id
+func (_m *Repository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Exists")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, ID) (bool, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, ID) bool); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, ID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_Exists_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Exists'
+type Repository_Exists_Call[T any, ID comparable] struct {
+	*mock.Call
+}
+
+// Exists is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id ID
+func (_e *Repository_Expecter[T, ID]) Exists(ctx interface{}, id interface{}) *Repository_Exists_Call[T, ID] {
+	return &Repository_Exists_Call[T, ID]{Call: _e.mock.On("Exists", ctx, id)}
+}
+
+func (_c *Repository_Exists_Call[T, ID]) Run(run func(ctx context.Context, id ID)) *Repository_Exists_Call[T, ID] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(ID))
+	})
+	return _c
+}
+
+func (_c *Repository_Exists_Call[T, ID]) Return(_a0 bool, _a1 error) *Repository_Exists_Call[T, ID] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_Exists_Call[T, ID]) RunAndReturn(run func(context.Context, ID) (bool, error)) *Repository_Exists_Call[T, ID] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// FindOne provides a mock function with given fields: ctx, filter
+func (_m *Repository[T, ID]) FindOne(ctx context.Context, filter *sietch.Filter) (*T, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindOne")
+	}
+
+	var r0 *T
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sietch.Filter) (*T, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sietch.Filter) *T); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*T)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sietch.Filter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_FindOne_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FindOne'
+type Repository_FindOne_Call[T any, ID comparable] struct {
+	*mock.Call
+}
+
+// FindOne is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter *sietch.Filter
+func (_e *Repository_Expecter[T, ID]) FindOne(ctx interface{}, filter interface{}) *Repository_FindOne_Call[T, ID] {
+	return &Repository_FindOne_Call[T, ID]{Call: _e.mock.On("FindOne", ctx, filter)}
+}
+
+func (_c *Repository_FindOne_Call[T, ID]) Run(run func(ctx context.Context, filter *sietch.Filter)) *Repository_FindOne_Call[T, ID] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*sietch.Filter))
+	})
+	return _c
+}
+
+func (_c *Repository_FindOne_Call[T, ID]) Return(_a0 *T, _a1 error) *Repository_FindOne_Call[T, ID] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_FindOne_Call[T, ID]) RunAndReturn(run func(context.Context, *sietch.Filter) (*T, error)) *Repository_FindOne_Call[T, ID] {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Get provides a mock function with given fields: ctx, id
 func (_m *Repository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
 	ret := _m.Called(ctx, id)
@@ -316,6 +593,65 @@ func (_c *Repository_Get_Call[T, ID]) RunAndReturn(run func(context.Context, ID)
 	return _c
 }
 
+// GetMany provides a mock function with given fields: ctx, ids
+func (_m *Repository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetMany")
+	}
+
+	var r0 map[ID]*T
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []ID) (map[ID]*T, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []ID) map[ID]*T); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[ID]*T)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []ID) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_GetMany_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetMany'
+type Repository_GetMany_Call[T any, ID comparable] struct {
+	*mock.Call
+}
+
+// GetMany is a helper method to define mock.On call
+//   - ctx context.Context
+//   - ids []ID
+func (_e *Repository_Expecter[T, ID]) GetMany(ctx interface{}, ids interface{}) *Repository_GetMany_Call[T, ID] {
+	return &Repository_GetMany_Call[T, ID]{Call: _e.mock.On("GetMany", ctx, ids)}
+}
+
+func (_c *Repository_GetMany_Call[T, ID]) Run(run func(ctx context.Context, ids []ID)) *Repository_GetMany_Call[T, ID] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]ID))
+	})
+	return _c
+}
+
+func (_c *Repository_GetMany_Call[T, ID]) Return(_a0 map[ID]*T, _a1 error) *Repository_GetMany_Call[T, ID] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_GetMany_Call[T, ID]) RunAndReturn(run func(context.Context, []ID) (map[ID]*T, error)) *Repository_GetMany_Call[T, ID] {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Query provides a mock function with given fields: ctx, filter
 func (_m *Repository[T, ID]) Query(ctx context.Context, filter *sietch.Filter) ([]T, error) {
 	ret := _m.Called(ctx, filter)
@@ -422,6 +758,111 @@ func (_c *Repository_Update_Call[T, ID]) RunAndReturn(run func(context.Context,
 	return _c
 }
 
+// UpdateWhere provides a mock function with given fields: ctx, filter, updates
+func (_m *Repository[T, ID]) UpdateWhere(ctx context.Context, filter *sietch.Filter, updates map[string]interface{}) (int64, error) {
+	ret := _m.Called(ctx, filter, updates)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateWhere")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sietch.Filter, map[string]interface{}) (int64, error)); ok {
+		return rf(ctx, filter, updates)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sietch.Filter, map[string]interface{}) int64); ok {
+		r0 = rf(ctx, filter, updates)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sietch.Filter, map[string]interface{}) error); ok {
+		r1 = rf(ctx, filter, updates)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_UpdateWhere_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateWhere'
+type Repository_UpdateWhere_Call[T any, ID comparable] struct {
+	*mock.Call
+}
+
+// UpdateWhere is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter *sietch.Filter
+//   - updates map[string]interface{}
+func (_e *Repository_Expecter[T, ID]) UpdateWhere(ctx interface{}, filter interface{}, updates interface{}) *Repository_UpdateWhere_Call[T, ID] {
+	return &Repository_UpdateWhere_Call[T, ID]{Call: _e.mock.On("UpdateWhere", ctx, filter, updates)}
+}
+
+func (_c *Repository_UpdateWhere_Call[T, ID]) Run(run func(ctx context.Context, filter *sietch.Filter, updates map[string]interface{})) *Repository_UpdateWhere_Call[T, ID] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*sietch.Filter), args[2].(map[string]interface{}))
+	})
+	return _c
+}
+
+func (_c *Repository_UpdateWhere_Call[T, ID]) Return(_a0 int64, _a1 error) *Repository_UpdateWhere_Call[T, ID] {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_UpdateWhere_Call[T, ID]) RunAndReturn(run func(context.Context, *sietch.Filter, map[string]interface{}) (int64, error)) *Repository_UpdateWhere_Call[T, ID] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Upsert provides a mock function with given fields: ctx, item
+func (_m *Repository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	ret := _m.Called(ctx, item)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Upsert")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *T) error); ok {
+		r0 = rf(ctx, item)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_Upsert_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Upsert'
+type Repository_Upsert_Call[T any, ID comparable] struct {
+	*mock.Call
+}
+
+// Upsert is a helper method to define mock.On call
+//   - ctx context.Context
+//   - item *T
+func (_e *Repository_Expecter[T, ID]) Upsert(ctx interface{}, item interface{}) *Repository_Upsert_Call[T, ID] {
+	return &Repository_Upsert_Call[T, ID]{Call: _e.mock.On("Upsert", ctx, item)}
+}
+
+func (_c *Repository_Upsert_Call[T, ID]) Run(run func(ctx context.Context, item *T)) *Repository_Upsert_Call[T, ID] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*T))
+	})
+	return _c
+}
+
+func (_c *Repository_Upsert_Call[T, ID]) Return(_a0 error) *Repository_Upsert_Call[T, ID] {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_Upsert_Call[T, ID]) RunAndReturn(run func(context.Context, *T) error) *Repository_Upsert_Call[T, ID] {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewRepository creates a new instance of Repository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRepository[T any, ID comparable](t interface {
@@ -0,0 +1,81 @@
+package sietch
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"sync"
+)
+
+// NULL semantics shared by the connectors. A field holds NULL when it is a
+// nil pointer, interface, slice or map, or a driver.Valuer whose Value is nil
+// (sql.NullString{}, an invalid pgtype.Numeric, ...). Like in SQL, NULL only
+// matches OpIsNull, any other comparison with it is false, and Where(field,
+// OpEqual, nil) or OpNotEqual with a nil value mean IS NULL and IS NOT NULL.
+//
+// Fields of other types can't hold NULL: their zero value is a value like
+// any other, so OpIsNull never matches them, as in a NOT NULL column.
+
+// nullableTypes caches isNullable per type
+var nullableTypes sync.Map // map[reflect.Type]bool
+
+// isNullable reports whether values of t can hold NULL themselves: pointers,
+// interfaces, slices and maps, and driver.Valuer types whose zero value is NULL
+func isNullable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+		return true
+	}
+	if nullable, ok := nullableTypes.Load(t); ok {
+		return nullable.(bool)
+	}
+	value, ok := driverValue(reflect.New(t).Elem())
+	nullable := ok && value == nil
+	nullableTypes.Store(t, nullable)
+	return nullable
+}
+
+// isNull reports whether v holds NULL. Pointers and interfaces are NULL when
+// nil or when the value they hold is.
+func isNull(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return true
+		}
+		if value, ok := driverValue(v); ok {
+			return value == nil
+		}
+		return isNull(v.Elem())
+	case reflect.Slice, reflect.Map:
+		return v.IsNil()
+	}
+	value, ok := driverValue(v)
+	return ok && value == nil
+}
+
+// isNullValue reports whether a condition or update value is NULL
+func isNullValue(v any) bool {
+	return isNull(reflect.ValueOf(v))
+}
+
+// driverValue calls the Value method of v, through its address when only the
+// pointer implements driver.Valuer. ok is false for other types and when
+// Value fails.
+func driverValue(v reflect.Value) (value driver.Value, ok bool) {
+	if !v.Type().Implements(valuerType) {
+		if v.Kind() == reflect.Pointer || !reflect.PointerTo(v.Type()).Implements(valuerType) {
+			return nil, false
+		}
+		if !v.CanAddr() {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p.Elem()
+		}
+		v = v.Addr()
+	}
+	value, err := v.Interface().(driver.Valuer).Value()
+	return value, err == nil
+}
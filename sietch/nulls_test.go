@@ -0,0 +1,135 @@
+package sietch
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type nullableEntry struct {
+	ID       int64          `db:"id"`
+	Nickname *string        `db:"nickname"`
+	Email    sql.NullString `db:"email"`
+	SeenAt   sql.NullTime   `db:"seen_at"`
+	Level    int64          `db:"level"`
+}
+
+func TestNullSemantics(t *testing.T) {
+	empty := ""
+	tests := []struct {
+		name  string
+		value any
+		null  bool
+	}{
+		{"nil", nil, true},
+		{"nil pointer", (*string)(nil), true},
+		{"pointer to zero value", &empty, false},
+		{"invalid sql.NullString", sql.NullString{String: "x"}, true},
+		{"valid empty sql.NullString", sql.NullString{Valid: true}, false},
+		{"pointer to invalid sql.NullTime", &sql.NullTime{}, true},
+		{"zero int", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNullValue(tt.value); got != tt.null {
+				t.Errorf("Expected isNullValue = %v, got %v", tt.null, got)
+			}
+		})
+	}
+}
+
+func TestInMemoryNullableFields(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[nullableEntry, int64](func(e *nullableEntry) int64 { return e.ID })
+
+	name := func(s string) *string { return &s }
+	seen := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo.BatchCreate(ctx, []nullableEntry{
+		{ID: 1, Nickname: name(""), Email: sql.NullString{String: "a@x.io", Valid: true}, SeenAt: sql.NullTime{Time: seen, Valid: true}, Level: 0},
+		{ID: 2, Nickname: nil, Email: sql.NullString{String: "stale", Valid: false}, Level: 2},
+		{ID: 3, Nickname: name("neo"), Email: sql.NullString{String: "b@x.io", Valid: true}, SeenAt: sql.NullTime{Time: seen.Add(time.Hour), Valid: true}, Level: 1},
+	})
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected []int64
+	}{
+		{"pointer to empty string is not NULL", NewFilter().Where("nickname", OpIsNull, nil).Build(), []int64{2}},
+		{"invalid sql.NullString is NULL", NewFilter().Where("email", OpIsNull, nil).Build(), []int64{2}},
+		{"sql.NullTime is not null", NewFilter().Where("seen_at", OpIsNotNull, nil).Build(), []int64{1, 3}},
+		{"equal nil means IS NULL", NewFilter().Where("seen_at", OpEqual, nil).Build(), []int64{2}},
+		{"not equal nil means IS NOT NULL", NewFilter().Where("nickname", OpNotEqual, nil).Build(), []int64{1, 3}},
+		{"NULL never compares", NewFilter().Where("email", OpNotEqual, "a@x.io").Build(), []int64{3}},
+		{"NULL is not in a list", NewFilter().Where("email", OpNotIn, []any{"a@x.io"}).Build(), []int64{3}},
+		{"null fields compare by value", NewFilter().Where("seen_at", OpGreaterThan, seen).Build(), []int64{3}},
+		{"pattern on sql.NullString", NewFilter().Where("email", OpLike, "%@x.io").Build(), []int64{1, 3}},
+		{"range with a NULL value", NewFilter().Where("level", OpGreaterThan, nil).Build(), nil},
+		{"zero values of non-nullable fields are not NULL", NewFilter().Where("level", OpIsNull, nil).Build(), nil},
+		{"zero values of non-nullable fields are values", NewFilter().Where("level", OpIsNotNull, nil).Where("level", OpLessThan, 2).Build(), []int64{1, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.Query(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			ids := make([]int64, 0, len(results))
+			for _, r := range results {
+				ids = append(ids, r.ID)
+			}
+			slices.Sort(ids)
+			if len(ids) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.expected[i] {
+					t.Fatalf("Expected %v, got %v", tt.expected, ids)
+				}
+			}
+		})
+	}
+
+	t.Run("sql.Null NULLs sort like nil pointers", func(t *testing.T) {
+		asc, _ := repo.Query(ctx, NewFilter().OrderBy("seen_at", SortAsc).Build())
+		if asc[0].ID != 2 || asc[2].ID != 3 {
+			t.Errorf("Expected NULL first for ASC, got %v, %v, %v", asc[0].ID, asc[1].ID, asc[2].ID)
+		}
+		desc, _ := repo.Query(ctx, NewFilter().OrderBy("email", SortDesc).Build())
+		if desc[0].ID != 3 || desc[2].ID != 2 {
+			t.Errorf("Expected NULL last for DESC, got %v, %v, %v", desc[0].ID, desc[1].ID, desc[2].ID)
+		}
+	})
+}
+
+func TestCockroachDBNullConditions(t *testing.T) {
+	conn, err := NewCockroachDBConnector[nullableEntry, int64](
+		&pgxpool.Pool{},
+		"entries",
+		func(e *nullableEntry) int64 { return e.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	query, args, err := conn.queryBuilder(NewFilter().
+		Where("email", OpEqual, sql.NullString{}).
+		Where("nickname", OpNotEqual, nil).
+		Where("level", OpEqual, 0).
+		Build())
+	if err != nil {
+		t.Fatalf("queryBuilder failed: %v", err)
+	}
+	expected := `SELECT "id", "nickname", "email", "seen_at", "level" FROM "entries" WHERE "email" IS NULL AND "nickname" IS NOT NULL AND "level" = $1`
+	if query != expected {
+		t.Errorf("Expected: %s\nGot: %s", expected, query)
+	}
+	if len(args) != 1 {
+		t.Errorf("Expected only the level argument, got %v", args)
+	}
+}
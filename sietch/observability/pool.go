@@ -0,0 +1,94 @@
+// Package observability exports sietch metrics to Prometheus and traces
+// repository operations with OpenTelemetry.
+package observability
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seb7887/gofw/sietch"
+)
+
+// PoolStatsSource is implemented by *sietch.CockroachDBConnector
+type PoolStatsSource interface {
+	Stats() sietch.PoolStats
+}
+
+// PoolCollector is a Prometheus collector reading the statistics of a
+// connection pool on every scrape
+type PoolCollector struct {
+	source PoolStatsSource
+
+	maxConns          *prometheus.Desc
+	totalConns        *prometheus.Desc
+	acquiredConns     *prometheus.Desc
+	idleConns         *prometheus.Desc
+	constructingConns *prometheus.Desc
+	acquires          *prometheus.Desc
+	acquireDuration   *prometheus.Desc
+	emptyAcquires     *prometheus.Desc
+	canceledAcquires  *prometheus.Desc
+	newConns          *prometheus.Desc
+	constructErrors   *prometheus.Desc
+}
+
+// NewPoolCollector creates a collector for the pool of source. Every metric
+// gets the constant label pool="<pool>", so several pools can be registered
+// with the same registry.
+//
+// Example:
+//
+//	prometheus.MustRegister(observability.NewPoolCollector("accounts", repo))
+func NewPoolCollector(pool string, source PoolStatsSource) *PoolCollector {
+	labels := prometheus.Labels{"pool": pool}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("sietch_pool_"+name, help, nil, labels)
+	}
+
+	return &PoolCollector{
+		source:            source,
+		maxConns:          desc("max_connections", "Maximum size of the connection pool"),
+		totalConns:        desc("connections", "Open connections: acquired, idle and being established"),
+		acquiredConns:     desc("acquired_connections", "Connections currently in use"),
+		idleConns:         desc("idle_connections", "Connections ready to be acquired"),
+		constructingConns: desc("constructing_connections", "Connections being established"),
+		acquires:          desc("acquires_total", "Successful connection acquires"),
+		acquireDuration:   desc("acquire_duration_seconds_total", "Total time spent acquiring connections, including waits"),
+		emptyAcquires:     desc("empty_acquires_total", "Acquires that had to wait for a connection"),
+		canceledAcquires:  desc("canceled_acquires_total", "Acquires abandoned because their context ended"),
+		newConns:          desc("new_connections_total", "Connections established"),
+		constructErrors:   desc("construct_errors_total", "Failed connection attempts; requires sietch.CountConnects"),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.maxConns, c.totalConns, c.acquiredConns, c.idleConns, c.constructingConns,
+		c.acquires, c.acquireDuration, c.emptyAcquires, c.canceledAcquires, c.newConns, c.constructErrors,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.source.Stats()
+
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+
+	gauge(c.maxConns, float64(s.MaxConns))
+	gauge(c.totalConns, float64(s.TotalConns))
+	gauge(c.acquiredConns, float64(s.AcquiredConns))
+	gauge(c.idleConns, float64(s.IdleConns))
+	gauge(c.constructingConns, float64(s.ConstructingConns))
+	counter(c.acquires, float64(s.AcquireCount))
+	counter(c.acquireDuration, s.AcquireDuration.Seconds())
+	counter(c.emptyAcquires, float64(s.EmptyAcquireCount))
+	counter(c.canceledAcquires, float64(s.CanceledAcquireCount))
+	counter(c.newConns, float64(s.NewConnsCount))
+	counter(c.constructErrors, float64(s.ConstructErrors))
+}
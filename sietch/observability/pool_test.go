@@ -0,0 +1,60 @@
+package observability_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seb7887/gofw/sietch"
+	"github.com/seb7887/gofw/sietch/observability"
+)
+
+type fixedStats sietch.PoolStats
+
+func (f fixedStats) Stats() sietch.PoolStats { return sietch.PoolStats(f) }
+
+func TestPoolCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(observability.NewPoolCollector("accounts", fixedStats{
+		MaxConns:        10,
+		AcquiredConns:   7,
+		AcquireDuration: 1500 * time.Millisecond,
+		ConstructErrors: 3,
+	}))
+	registry.MustRegister(observability.NewPoolCollector("orders", fixedStats{}))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			if m.GetLabel()[0].GetValue() != "accounts" {
+				continue
+			}
+			if m.Gauge != nil {
+				values[family.GetName()] = m.GetGauge().GetValue()
+			} else {
+				values[family.GetName()] = m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	expected := map[string]float64{
+		"sietch_pool_max_connections":                10,
+		"sietch_pool_acquired_connections":           7,
+		"sietch_pool_acquire_duration_seconds_total": 1.5,
+		"sietch_pool_construct_errors_total":         3,
+		"sietch_pool_idle_connections":               0,
+	}
+	for name, want := range expected {
+		if got, ok := values[name]; !ok || got != want {
+			t.Errorf("Expected %s = %v, got %v", name, want, got)
+		}
+	}
+	if len(values) != 11 {
+		t.Errorf("Expected 11 metrics, got %d", len(values))
+	}
+}
@@ -0,0 +1,330 @@
+package observability
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/seb7887/gofw/sietch"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/seb7887/gofw/sietch"
+)
+
+// TracingOptions configures a TracedRepository
+type TracingOptions struct {
+	// Provider creates the tracer; nil uses the global tracer provider
+	Provider trace.TracerProvider
+
+	// DBSystem is the db.system attribute. Empty detects it from the
+	// backend: "cockroachdb", "redis" or "memory".
+	DBSystem string
+
+	// Table is the db.sql.table attribute and names the spans, e.g.
+	// "Get accounts"; empty uses the entity type name
+	Table string
+}
+
+// TracedRepository wraps a repository and creates an OpenTelemetry client
+// span for each operation, a child of the span in the caller's context, so
+// traces continue from the HTTP layer into the database. Spans carry the
+// db.system and db.operation attributes and record failed operations as
+// errors.
+//
+// If the base repository implements sietch.LoggableRepository, as
+// CockroachDBConnector does, every statement it runs is added to the span of
+// its operation as a "db.query" event, and the db.statement attribute holds
+// the last one. Statement arguments are never recorded. Loggers set later
+// with SetLogger keep receiving the statements.
+type TracedRepository[T any, ID comparable] struct {
+	base     sietch.Repository[T, ID]
+	tracer   trace.Tracer
+	name     string
+	attrs    []attribute.KeyValue
+	loggable sietch.LoggableRepository
+	logger   sietch.QueryLogger
+}
+
+// NewTracedRepository creates a repository tracing the operations of base
+//
+// Example:
+//
+//	repo := observability.NewTracedRepository(conn, observability.TracingOptions{Table: "accounts"})
+//	account, err := repo.Get(r.Context(), id)
+func NewTracedRepository[T any, ID comparable](base sietch.Repository[T, ID], opts TracingOptions) *TracedRepository[T, ID] {
+	provider := opts.Provider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	system := opts.DBSystem
+	if system == "" {
+		system = dbSystem(base)
+	}
+
+	r := &TracedRepository[T, ID]{
+		base:   base,
+		tracer: provider.Tracer(instrumentationName),
+		name:   opts.Table,
+		attrs:  []attribute.KeyValue{attribute.String("db.system", system)},
+	}
+	if r.name == "" {
+		r.name = reflect.TypeFor[T]().Name()
+	} else {
+		r.attrs = append(r.attrs, attribute.String("db.sql.table", opts.Table))
+	}
+
+	if loggable, ok := base.(sietch.LoggableRepository); ok {
+		r.loggable = loggable
+		r.SetLogger(loggable.GetLogger())
+	}
+	return r
+}
+
+// dbSystem names the backend of repo for the db.system attribute
+func dbSystem[T any, ID comparable](repo sietch.Repository[T, ID]) string {
+	switch repo.(type) {
+	case *sietch.CockroachDBConnector[T, ID]:
+		return "cockroachdb"
+	case *sietch.RedisConnector[T, ID]:
+		return "redis"
+	case *sietch.InMemoryConnector[T, ID]:
+		return "memory"
+	default:
+		return "other_sql"
+	}
+}
+
+// SetLogger sets the logger receiving the statements of the base repository
+// alongside the tracer (see sietch.LoggableRepository). It does nothing if
+// the base repository does not log statements.
+func (r *TracedRepository[T, ID]) SetLogger(logger sietch.QueryLogger) {
+	r.logger = logger
+	if r.loggable != nil {
+		r.loggable.SetLogger(&statementTracer{next: logger})
+	}
+}
+
+// GetLogger returns the logger set with SetLogger
+func (r *TracedRepository[T, ID]) GetLogger() sietch.QueryLogger {
+	return r.logger
+}
+
+// start starts the span of operation
+func (r *TracedRepository[T, ID]) start(ctx context.Context, operation string) (context.Context, trace.Span) {
+	ctx, span := r.tracer.Start(ctx, operation+" "+r.name, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(r.attrs...)
+	span.SetAttributes(attribute.String("db.operation", operation))
+	return ctx, span
+}
+
+// end records err, if any, and ends span
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (r *TracedRepository[T, ID]) Create(ctx context.Context, item *T) error {
+	ctx, span := r.start(ctx, "Create")
+	err := r.base.Create(ctx, item)
+	end(span, err)
+	return err
+}
+
+func (r *TracedRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	ctx, span := r.start(ctx, "Get")
+	item, err := r.base.Get(ctx, id)
+	end(span, err)
+	return item, err
+}
+
+func (r *TracedRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	ctx, span := r.start(ctx, "GetMany")
+	span.SetAttributes(attribute.Int("db.sietch.ids", len(ids)))
+	items, err := r.base.GetMany(ctx, ids)
+	end(span, err)
+	return items, err
+}
+
+func (r *TracedRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	ctx, span := r.start(ctx, "Exists")
+	exists, err := r.base.Exists(ctx, id)
+	end(span, err)
+	return exists, err
+}
+
+func (r *TracedRepository[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	ctx, span := r.start(ctx, "BatchCreate")
+	span.SetAttributes(attribute.Int("db.sietch.items", len(items)))
+	err := r.base.BatchCreate(ctx, items)
+	end(span, err)
+	return err
+}
+
+func (r *TracedRepository[T, ID]) Query(ctx context.Context, filter *sietch.Filter) ([]T, error) {
+	ctx, span := r.start(ctx, "Query")
+	results, err := r.base.Query(ctx, filter)
+	if err == nil {
+		span.SetAttributes(attribute.Int("db.sietch.results", len(results)))
+	}
+	end(span, err)
+	return results, err
+}
+
+func (r *TracedRepository[T, ID]) FindOne(ctx context.Context, filter *sietch.Filter) (*T, error) {
+	ctx, span := r.start(ctx, "FindOne")
+	item, err := r.base.FindOne(ctx, filter)
+	end(span, err)
+	return item, err
+}
+
+func (r *TracedRepository[T, ID]) Count(ctx context.Context, filter *sietch.Filter) (int64, error) {
+	ctx, span := r.start(ctx, "Count")
+	count, err := r.base.Count(ctx, filter)
+	end(span, err)
+	return count, err
+}
+
+func (r *TracedRepository[T, ID]) Update(ctx context.Context, item *T) error {
+	ctx, span := r.start(ctx, "Update")
+	err := r.base.Update(ctx, item)
+	end(span, err)
+	return err
+}
+
+func (r *TracedRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	ctx, span := r.start(ctx, "BatchUpdate")
+	span.SetAttributes(attribute.Int("db.sietch.items", len(items)))
+	err := r.base.BatchUpdate(ctx, items)
+	end(span, err)
+	return err
+}
+
+func (r *TracedRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	ctx, span := r.start(ctx, "Delete")
+	err := r.base.Delete(ctx, id)
+	end(span, err)
+	return err
+}
+
+func (r *TracedRepository[T, ID]) BatchDelete(ctx context.Context, ids []ID) error {
+	ctx, span := r.start(ctx, "BatchDelete")
+	span.SetAttributes(attribute.Int("db.sietch.items", len(ids)))
+	err := r.base.BatchDelete(ctx, ids)
+	end(span, err)
+	return err
+}
+
+func (r *TracedRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	ctx, span := r.start(ctx, "Upsert")
+	err := r.base.Upsert(ctx, item)
+	end(span, err)
+	return err
+}
+
+func (r *TracedRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	ctx, span := r.start(ctx, "BatchUpsert")
+	span.SetAttributes(attribute.Int("db.sietch.items", len(items)))
+	err := r.base.BatchUpsert(ctx, items)
+	end(span, err)
+	return err
+}
+
+func (r *TracedRepository[T, ID]) UpdateWhere(ctx context.Context, filter *sietch.Filter, updates map[string]any) (int64, error) {
+	ctx, span := r.start(ctx, "UpdateWhere")
+	n, err := r.base.UpdateWhere(ctx, filter, updates)
+	if err == nil {
+		span.SetAttributes(attribute.Int64("db.sietch.rows_affected", n))
+	}
+	end(span, err)
+	return n, err
+}
+
+func (r *TracedRepository[T, ID]) DeleteWhere(ctx context.Context, filter *sietch.Filter) (int64, error) {
+	ctx, span := r.start(ctx, "DeleteWhere")
+	n, err := r.base.DeleteWhere(ctx, filter)
+	if err == nil {
+		span.SetAttributes(attribute.Int64("db.sietch.rows_affected", n))
+	}
+	end(span, err)
+	return n, err
+}
+
+// GroupCount delegates to the base repository if it implements sietch.Grouper
+func (r *TracedRepository[T, ID]) GroupCount(ctx context.Context, filter *sietch.Filter) ([]sietch.GroupCount, error) {
+	grouper, ok := r.base.(sietch.Grouper)
+	if !ok {
+		return nil, sietch.ErrUnsupportedOperation
+	}
+	ctx, span := r.start(ctx, "GroupCount")
+	groups, err := grouper.GroupCount(ctx, filter)
+	end(span, err)
+	return groups, err
+}
+
+// GetForUpdate delegates to the base repository if it implements
+// sietch.RowLocker
+func (r *TracedRepository[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
+	locker, ok := r.base.(sietch.RowLocker[T, ID])
+	if !ok {
+		return nil, sietch.ErrUnsupportedOperation
+	}
+	ctx, span := r.start(ctx, "GetForUpdate")
+	item, err := locker.GetForUpdate(ctx, id)
+	end(span, err)
+	return item, err
+}
+
+// WithTx runs fn in a transaction of the base repository, which must
+// implement sietch.Transactional, under a "WithTx" span. The operations of
+// the repository passed to fn are traced as its children.
+func (r *TracedRepository[T, ID]) WithTx(ctx context.Context, fn sietch.TxFunc[T, ID]) error {
+	txRepo, ok := r.base.(sietch.Transactional[T, ID])
+	if !ok {
+		return sietch.ErrUnsupportedOperation
+	}
+	ctx, span := r.start(ctx, "WithTx")
+	err := txRepo.WithTx(ctx, func(tx sietch.Repository[T, ID]) error {
+		return fn(&TracedRepository[T, ID]{base: tx, tracer: r.tracer, name: r.name, attrs: r.attrs})
+	})
+	end(span, err)
+	return err
+}
+
+// statementTracer adds the statements of a connector to the span of their
+// operation, then passes them on to next
+type statementTracer struct {
+	next sietch.QueryLogger
+}
+
+// LogQuery implements sietch.QueryLogger
+func (s *statementTracer) LogQuery(ctx context.Context, operation string, query string, args []any, duration time.Duration, err error) {
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		attrs := []attribute.KeyValue{
+			attribute.String("db.statement", query),
+			attribute.Int64("db.sietch.duration_us", duration.Microseconds()),
+		}
+		if err != nil {
+			attrs = append(attrs, attribute.String("error", err.Error()))
+		}
+		span.AddEvent("db.query", trace.WithAttributes(attrs...))
+		span.SetAttributes(attribute.String("db.statement", query))
+	}
+	if s.next != nil {
+		s.next.LogQuery(ctx, operation, query, args, duration, err)
+	}
+}
+
+// LogOperation implements sietch.QueryLogger
+func (s *statementTracer) LogOperation(ctx context.Context, operation string, entityType string, duration time.Duration, err error) {
+	if s.next != nil {
+		s.next.LogOperation(ctx, operation, entityType, duration, err)
+	}
+}
@@ -0,0 +1,166 @@
+package observability_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/sietch"
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+	"github.com/seb7887/gofw/sietch/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordedSpan keeps the attributes, events and status set on a span
+type recordedSpan struct {
+	noop.Span
+	name   string
+	parent trace.SpanContext
+	attrs  map[attribute.Key]attribute.Value
+	events []string
+	status codes.Code
+	ended  bool
+}
+
+func (s *recordedSpan) IsRecording() bool { return true }
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *recordedSpan) AddEvent(name string, opts ...trace.EventOption) {
+	cfg := trace.NewEventConfig(opts...)
+	for _, a := range cfg.Attributes() {
+		if a.Key == "db.statement" {
+			name += " " + a.Value.AsString()
+		}
+	}
+	s.events = append(s.events, name)
+}
+
+func (s *recordedSpan) SetStatus(code codes.Code, _ string) { s.status = code }
+
+func (s *recordedSpan) End(...trace.SpanEndOption) { s.ended = true }
+
+// recordingProvider hands out a tracer recording every span it starts
+type recordingProvider struct {
+	noop.TracerProvider
+	spans []*recordedSpan
+}
+
+func (p *recordingProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{provider: p}
+}
+
+type recordingTracer struct {
+	noop.Tracer
+	provider *recordingProvider
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, _ ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &recordedSpan{name: name, parent: trace.SpanContextFromContext(ctx), attrs: make(map[attribute.Key]attribute.Value)}
+	t.provider.spans = append(t.provider.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// statementRepository is an in-memory repository logging a fake statement
+// for every Get, as the CockroachDB connector would
+type statementRepository struct {
+	*sietch.InMemoryConnector[testutils.Account, int64]
+	logger sietch.QueryLogger
+}
+
+func (r *statementRepository) SetLogger(logger sietch.QueryLogger) { r.logger = logger }
+func (r *statementRepository) GetLogger() sietch.QueryLogger       { return r.logger }
+
+func (r *statementRepository) Get(ctx context.Context, id int64) (*testutils.Account, error) {
+	item, err := r.InMemoryConnector.Get(ctx, id)
+	r.logger.LogQuery(ctx, "Get", `SELECT * FROM "accounts" WHERE "id" = $1`, []any{id}, time.Millisecond, err)
+	return item, err
+}
+
+// countingLogger counts the statements it receives
+type countingLogger struct {
+	sietch.NoOpLogger
+	queries int
+}
+
+func (l *countingLogger) LogQuery(context.Context, string, string, []any, time.Duration, error) {
+	l.queries++
+}
+
+func TestTracedRepository(t *testing.T) {
+	getID := func(a *testutils.Account) int64 { return a.ID }
+
+	t.Run("Operations create client spans", func(t *testing.T) {
+		provider := &recordingProvider{}
+		repo := observability.NewTracedRepository(sietch.NewInMemoryConnector[testutils.Account](getID), observability.TracingOptions{
+			Provider: provider,
+			Table:    "accounts",
+		})
+
+		parent := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}})
+		ctx := trace.ContextWithSpanContext(context.Background(), parent)
+
+		_ = repo.Create(ctx, &testutils.Account{ID: 1, Balance: 10})
+		if _, err := repo.Get(ctx, 2); !errors.Is(err, sietch.ErrItemNotFound) {
+			t.Fatalf("Expected ErrItemNotFound, got %v", err)
+		}
+		_ = repo.WithTx(ctx, func(tx sietch.Repository[testutils.Account, int64]) error {
+			return tx.Delete(ctx, 1)
+		})
+
+		var names []string
+		for _, s := range provider.spans {
+			names = append(names, s.name)
+			if !s.ended {
+				t.Errorf("Expected span %s to be ended", s.name)
+			}
+		}
+		if !reflect.DeepEqual(names, []string{"Create accounts", "Get accounts", "WithTx accounts", "Delete accounts"}) {
+			t.Fatalf("Unexpected spans %v", names)
+		}
+
+		create := provider.spans[0]
+		if !create.parent.Equal(parent) {
+			t.Error("Expected the span to continue the ambient trace")
+		}
+		if create.attrs["db.system"].AsString() != "memory" || create.attrs["db.operation"].AsString() != "Create" || create.attrs["db.sql.table"].AsString() != "accounts" {
+			t.Errorf("Unexpected attributes %v", create.attrs)
+		}
+		if provider.spans[1].status != codes.Error || create.status == codes.Error {
+			t.Error("Expected only the failed Get to be marked as an error")
+		}
+	})
+
+	t.Run("Statements are added to their span", func(t *testing.T) {
+		provider := &recordingProvider{}
+		logger := &countingLogger{}
+		base := &statementRepository{InMemoryConnector: sietch.NewInMemoryConnector[testutils.Account](getID), logger: logger}
+		repo := observability.NewTracedRepository[testutils.Account, int64](base, observability.TracingOptions{Provider: provider, DBSystem: "cockroachdb"})
+
+		if repo.GetLogger() != logger {
+			t.Error("Expected the base logger to be kept")
+		}
+		_, _ = repo.Get(context.Background(), 1)
+
+		span := provider.spans[0]
+		statement := `SELECT * FROM "accounts" WHERE "id" = $1`
+		if span.name != "Get Account" || span.attrs["db.statement"].AsString() != statement || span.attrs["db.system"].AsString() != "cockroachdb" {
+			t.Errorf("Unexpected span %s %v", span.name, span.attrs)
+		}
+		if !reflect.DeepEqual(span.events, []string{"db.query " + statement}) {
+			t.Errorf("Unexpected events %v", span.events)
+		}
+		if logger.queries != 1 {
+			t.Errorf("Expected the statement to reach the base logger, got %d", logger.queries)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package observability
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seb7887/gofw/sietch"
+)
+
+// TxRetryCollector is a Prometheus collector counting the transactions,
+// conflicts and reruns of the connectors it observes, by table. Its
+// conflict rate, rate(sietch_tx_conflicts_total) / rate(sietch_tx_total),
+// shows which tables are contention hot spots.
+type TxRetryCollector struct {
+	transactions *prometheus.CounterVec
+	conflicts    *prometheus.CounterVec
+	retries      *prometheus.CounterVec
+	exhausted    *prometheus.CounterVec
+	latency      *prometheus.HistogramVec
+}
+
+var _ sietch.TxRetryMetrics = (*TxRetryCollector)(nil)
+
+// NewTxRetryCollector creates a collector to set as TxRetryOptions.Metrics.
+// Every metric has a table label, see TxRetryOptions.Table.
+//
+// Example:
+//
+//	metrics := observability.NewTxRetryCollector()
+//	prometheus.MustRegister(metrics)
+//	repo.SetTxRetryOptions(sietch.TxRetryOptions{Metrics: metrics})
+func NewTxRetryCollector() *TxRetryCollector {
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "sietch_tx_" + name, Help: help}, []string{"table"})
+	}
+
+	return &TxRetryCollector{
+		transactions: counter("total", "Transactions run, counting reruns once"),
+		conflicts:    counter("conflicts_total", "Attempts failing with a serialization failure or conflict"),
+		retries:      counter("retries_total", "Reruns of conflicting transactions"),
+		exhausted:    counter("retries_exhausted_total", "Transactions still conflicting after their last rerun"),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "sietch_tx_retry_duration_seconds",
+			Help:    "Time from the first attempt to the end of transactions that were rerun, backoff included",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 10),
+		}, []string{"table"}),
+	}
+}
+
+// ObserveConflict implements sietch.TxRetryMetrics
+func (c *TxRetryCollector) ObserveConflict(table string) {
+	c.conflicts.WithLabelValues(table).Inc()
+}
+
+// ObserveTx implements sietch.TxRetryMetrics
+func (c *TxRetryCollector) ObserveTx(table string, retries int, elapsed time.Duration, exhausted bool) {
+	c.transactions.WithLabelValues(table).Inc()
+	if exhausted {
+		c.exhausted.WithLabelValues(table).Inc()
+	}
+	if retries > 0 {
+		c.retries.WithLabelValues(table).Add(float64(retries))
+		c.latency.WithLabelValues(table).Observe(elapsed.Seconds())
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *TxRetryCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.metrics() {
+		m.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *TxRetryCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.metrics() {
+		m.Collect(ch)
+	}
+}
+
+func (c *TxRetryCollector) metrics() []prometheus.Collector {
+	return []prometheus.Collector{c.transactions, c.conflicts, c.retries, c.exhausted, c.latency}
+}
@@ -0,0 +1,57 @@
+package observability_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seb7887/gofw/sietch/observability"
+)
+
+func TestTxRetryCollector(t *testing.T) {
+	metrics := observability.NewTxRetryCollector()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metrics)
+
+	metrics.ObserveConflict("accounts")
+	metrics.ObserveConflict("accounts")
+	metrics.ObserveTx("accounts", 2, 300*time.Millisecond, false)
+	metrics.ObserveTx("accounts", 0, time.Millisecond, false)
+	metrics.ObserveConflict("orders")
+	metrics.ObserveTx("orders", 0, time.Millisecond, true)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			key := family.GetName() + "{" + m.GetLabel()[0].GetValue() + "}"
+			if m.Histogram != nil {
+				values[key] = float64(m.GetHistogram().GetSampleCount())
+			} else {
+				values[key] = m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	expected := map[string]float64{
+		"sietch_tx_total{accounts}":                  2,
+		"sietch_tx_conflicts_total{accounts}":        2,
+		"sietch_tx_retries_total{accounts}":          2,
+		"sietch_tx_retry_duration_seconds{accounts}": 1,
+		"sietch_tx_total{orders}":                    1,
+		"sietch_tx_conflicts_total{orders}":          1,
+		"sietch_tx_retries_exhausted_total{orders}":  1,
+	}
+	for name, want := range expected {
+		if got, ok := values[name]; !ok || got != want {
+			t.Errorf("Expected %s = %v, got %v", name, want, got)
+		}
+	}
+	if _, ok := values["sietch_tx_retries_exhausted_total{accounts}"]; ok {
+		t.Error("Expected no exhausted retries for accounts")
+	}
+}
@@ -0,0 +1,73 @@
+package sietch
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// pkAccessorKey identifies a derived ID extractor by entity and ID type
+type pkAccessorKey struct {
+	entity, id reflect.Type
+}
+
+// pkAccessors caches the extractors built by PKAccessor
+var pkAccessors sync.Map // map[pkAccessorKey]any (func(*T) ID)
+
+// PKAccessor returns a func reading the ID of a T from its pk-tagged field,
+// e.g. `db:"id,pk"`, which must be of type ID. It is what connectors and
+// repositories use when they are given a nil getID. Accessors are built
+// once per entity and ID type.
+func PKAccessor[T any, ID comparable]() (func(*T) ID, error) {
+	key := pkAccessorKey{entity: reflect.TypeFor[T](), id: reflect.TypeFor[ID]()}
+	if getID, ok := pkAccessors.Load(key); ok {
+		return getID.(func(*T) ID), nil
+	}
+
+	typ := key.entity
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot derive getID: %s is not a struct", typ)
+	}
+	var index []int
+	for _, f := range dbFields(typ) {
+		if !slices.Contains(f.options, "pk") {
+			continue
+		}
+		if f.field.Type != key.id {
+			return nil, fmt.Errorf("cannot derive getID: pk field %s is a %s, not a %s", f.name, f.field.Type, key.id)
+		}
+		index = f.index
+	}
+	if index == nil {
+		return nil, fmt.Errorf("cannot derive getID: %s has no pk-tagged field", typ)
+	}
+
+	getID := func(item *T) ID {
+		field, ok := fieldByIndex(reflect.ValueOf(item).Elem(), index)
+		if !ok {
+			var zero ID
+			return zero // in a nil embedded struct
+		}
+		return field.Interface().(ID)
+	}
+	actual, _ := pkAccessors.LoadOrStore(key, getID)
+	return actual.(func(*T) ID), nil
+}
+
+// resolveGetID returns getID, or the PKAccessor of T when it is nil
+func resolveGetID[T any, ID comparable](getID func(*T) ID) (func(*T) ID, error) {
+	if getID != nil {
+		return getID, nil
+	}
+	return PKAccessor[T, ID]()
+}
+
+// mustResolveGetID is resolveGetID for constructors without an error result
+func mustResolveGetID[T any, ID comparable](getID func(*T) ID) func(*T) ID {
+	getID, err := resolveGetID(getID)
+	if err != nil {
+		panic(fmt.Sprintf("getID function cannot be nil: %v", err))
+	}
+	return getID
+}
@@ -0,0 +1,80 @@
+package sietch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type pkEntity struct {
+	Name string `db:"name"`
+	ID   string `db:"id,pk"`
+}
+
+type embeddedPK struct {
+	*PKBase
+	Extra int `db:"extra"`
+}
+
+type PKBase struct {
+	ID string `db:"id,pk"`
+}
+
+func TestPKAccessor(t *testing.T) {
+	getID, err := PKAccessor[pkEntity, string]()
+	if err != nil {
+		t.Fatalf("PKAccessor failed: %v", err)
+	}
+	if id := getID(&pkEntity{Name: "a", ID: "k1"}); id != "k1" {
+		t.Errorf("Expected k1, got %q", id)
+	}
+	again, _ := PKAccessor[pkEntity, string]()
+	if again(&pkEntity{ID: "k2"}) != "k2" {
+		t.Error("Expected the cached accessor to read the pk")
+	}
+
+	embedded, err := PKAccessor[embeddedPK, string]()
+	if err != nil {
+		t.Fatalf("PKAccessor failed for an embedded pk: %v", err)
+	}
+	if id := embedded(&embeddedPK{}); id != "" {
+		t.Errorf("Expected the zero ID through a nil embedded struct, got %q", id)
+	}
+	if id := embedded(&embeddedPK{PKBase: &PKBase{ID: "k3"}}); id != "k3" {
+		t.Errorf("Expected k3, got %q", id)
+	}
+
+	if _, err := PKAccessor[pkEntity, int64](); err == nil {
+		t.Error("Expected an error when the pk type does not match ID")
+	}
+	if _, err := PKAccessor[codecEntity, int64](); err == nil {
+		t.Error("Expected an error without a pk tag")
+	}
+}
+
+func TestConnectorsDeriveGetID(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewInMemoryConnector[pkEntity, string](nil)
+	if err := repo.Create(ctx, &pkEntity{Name: "a", ID: "k1"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if item, err := repo.Get(ctx, "k1"); err != nil || item.Name != "a" {
+		t.Errorf("Expected the item stored under its pk, got %v (%v)", item, err)
+	}
+
+	if _, err := NewCockroachDBConnector[pkEntity, string](&pgxpool.Pool{}, "entities", nil); err != nil {
+		t.Errorf("Expected the CockroachDB connector to derive getID: %v", err)
+	}
+	if _, err := NewCockroachDBConnector[codecEntity, int64](&pgxpool.Pool{}, "entities", nil); err == nil {
+		t.Error("Expected an error for a nil getID without a pk tag")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected NewInMemoryConnector to panic without getID or pk tag")
+		}
+	}()
+	NewInMemoryConnector[codecEntity, int64](nil)
+}
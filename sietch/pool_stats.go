@@ -0,0 +1,137 @@
+package sietch
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolStats is a snapshot of the connection pool of a CockroachDB connector
+type PoolStats struct {
+	MaxConns          int32 // configured pool size
+	TotalConns        int32 // open connections, acquired + idle + constructing
+	AcquiredConns     int32 // connections in use
+	IdleConns         int32 // connections ready to be acquired
+	ConstructingConns int32 // connections being established
+
+	AcquireCount         int64         // successful acquires
+	AcquireDuration      time.Duration // total time spent acquiring, including waits
+	EmptyAcquireCount    int64         // acquires that had to wait for a connection
+	CanceledAcquireCount int64         // acquires abandoned because their context ended
+
+	NewConnsCount   int64 // connections established
+	ConstructErrors int64 // failed connection attempts, see CountConnects
+}
+
+// Utilization returns the fraction of the pool in use, from 0 to 1.
+// Alert when it stays near 1 together with a growing EmptyAcquireCount.
+func (s PoolStats) Utilization() float64 {
+	if s.MaxConns <= 0 {
+		return 0
+	}
+	return float64(s.AcquiredConns) / float64(s.MaxConns)
+}
+
+// Stats returns the current statistics of the connector's pool. Connectors
+// sharing a pool report the same statistics.
+func (r *CockroachDBConnector[T, ID]) Stats() PoolStats {
+	if r.connects != nil {
+		return r.connects.stats(r.pool)
+	}
+	return newPoolStats(r.pool.Stat())
+}
+
+// SetConnectCounter makes Stats report ConstructErrors from c, the counter
+// returned by CountConnects for the connector's pool config. Not safe to call
+// concurrently with Stats.
+func (r *CockroachDBConnector[T, ID]) SetConnectCounter(c *ConnectCounter) {
+	r.connects = c
+}
+
+func newPoolStats(stat *pgxpool.Stat) PoolStats {
+	return PoolStats{
+		MaxConns:             stat.MaxConns(),
+		TotalConns:           stat.TotalConns(),
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		ConstructingConns:    stat.ConstructingConns(),
+		AcquireCount:         stat.AcquireCount(),
+		AcquireDuration:      stat.AcquireDuration(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		NewConnsCount:        stat.NewConnsCount(),
+	}
+}
+
+// ConnectCounter counts the connection attempts of a pool, which pgxpool
+// does not report, so failures to connect show up in PoolStats
+type ConnectCounter struct {
+	attempts    atomic.Int64
+	established atomic.Int64
+	failed      atomic.Int64 // highest failure count reported so far
+}
+
+// CountConnects hooks a ConnectCounter into cfg, keeping any BeforeConnect
+// and AfterConnect hooks already set. Create the pool from cfg afterwards.
+//
+// Example:
+//
+//	cfg, err := pgxpool.ParseConfig(dsn)
+//	if err != nil {
+//	    return err
+//	}
+//	connects := sietch.CountConnects(cfg)
+//	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+//	// ...
+//	repo.SetConnectCounter(connects)
+func CountConnects(cfg *pgxpool.Config) *ConnectCounter {
+	c := &ConnectCounter{}
+
+	beforeConnect := cfg.BeforeConnect
+	cfg.BeforeConnect = func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+		c.attempts.Add(1)
+		if beforeConnect != nil {
+			return beforeConnect(ctx, connConfig)
+		}
+		return nil
+	}
+
+	afterConnect := cfg.AfterConnect
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		if afterConnect != nil {
+			if err := afterConnect(ctx, conn); err != nil {
+				return err
+			}
+		}
+		c.established.Add(1)
+		return nil
+	}
+
+	return c
+}
+
+// stats returns the statistics of pool with ConstructErrors set. Failures are
+// the attempts that neither succeeded nor are still in progress; reading
+// attempts before and established after the pool statistics means an attempt
+// in progress is never counted as failed, so the count is exact when no
+// connection is being established and otherwise may lag, but never decreases.
+func (c *ConnectCounter) stats(pool *pgxpool.Pool) PoolStats {
+	attempts := c.attempts.Load()
+	stats := newPoolStats(pool.Stat())
+	failed := attempts - c.established.Load() - int64(stats.ConstructingConns)
+
+	for {
+		reported := c.failed.Load()
+		if failed <= reported {
+			stats.ConstructErrors = reported
+			return stats
+		}
+		if c.failed.CompareAndSwap(reported, failed) {
+			stats.ConstructErrors = failed
+			return stats
+		}
+	}
+}
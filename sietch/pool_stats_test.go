@@ -0,0 +1,64 @@
+package sietch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestCockroachDBConnector_Stats(t *testing.T) {
+	ctx := context.Background()
+
+	// Nothing listens on port 1, so every connection attempt fails
+	cfg, err := pgxpool.ParseConfig("postgres://user@127.0.0.1:1/db?connect_timeout=1")
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	cfg.MaxConns = 4
+	var hooked bool
+	cfg.AfterConnect = func(context.Context, *pgx.Conn) error {
+		hooked = true
+		return nil
+	}
+	connects := CountConnects(cfg)
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewWithConfig failed: %v", err)
+	}
+	defer pool.Close()
+
+	repo, err := NewCockroachDBConnector[testutils.Account, int64](pool, "accounts", func(a *testutils.Account) int64 { return a.ID })
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	stats := repo.Stats()
+	if stats.MaxConns != 4 || stats.TotalConns != 0 || stats.ConstructErrors != 0 {
+		t.Errorf("Unexpected stats for an unused pool: %+v", stats)
+	}
+
+	acquireCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if _, err := repo.Exists(acquireCtx, 1); err == nil {
+			t.Fatal("Expected the connection to fail")
+		}
+	}
+
+	if stats := repo.Stats(); stats.ConstructErrors != 0 {
+		t.Errorf("Expected no construct errors without a counter, got %d", stats.ConstructErrors)
+	}
+	repo.SetConnectCounter(connects)
+	stats = repo.Stats()
+	if stats.ConstructErrors != 2 || stats.AcquiredConns != 0 || stats.Utilization() != 0 {
+		t.Errorf("Expected 2 construct errors, got %+v", stats)
+	}
+	if hooked {
+		t.Error("Expected the existing AfterConnect hook to run only for established connections")
+	}
+}
@@ -0,0 +1,144 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// projectionQuerier is implemented by connectors that can select a subset of columns
+type projectionQuerier interface {
+	queryProjection(ctx context.Context, filter *Filter, columns []string) (pgx.Rows, error)
+}
+
+// QueryAs runs a filtered query and returns the results as R instead of the
+// repository entity. R's fields are mapped by db tag and every tagged field
+// must be an entity column. CockroachDB selects only those columns; other
+// connectors run Query and copy the matching fields.
+//
+// Example:
+//
+//	type AccountSummary struct {
+//	    ID     int64  `db:"id"`
+//	    Status string `db:"status"`
+//	}
+//	summaries, err := sietch.QueryAs[AccountSummary](ctx, repo, filter)
+func QueryAs[R any, T any, ID comparable](ctx context.Context, repo Repository[T, ID], filter *Filter) ([]R, error) {
+	if repo == nil {
+		return nil, fmt.Errorf("repository cannot be nil")
+	}
+	if filter == nil {
+		return nil, fmt.Errorf("filter cannot be nil")
+	}
+
+	columns, err := projectionColumns[R]()
+	if err != nil {
+		return nil, err
+	}
+
+	if pq, ok := repo.(projectionQuerier); ok {
+		rows, err := pq.queryProjection(ctx, filter, columns)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		return scanRowsByName[R](rows)
+	}
+
+	items, err := repo.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return projectItems[R](items, columns)
+}
+
+// projectionColumns returns the db-tagged columns of R in field order
+func projectionColumns[R any]() ([]string, error) {
+	index, err := dbFieldIndex[R]()
+	if err != nil {
+		return nil, fmt.Errorf("projection %w", err)
+	}
+
+	typ := reflect.TypeOf((*R)(nil)).Elem()
+	columns := make([]string, 0, len(index))
+	for _, f := range dbFields(typ) {
+		columns = append(columns, f.column)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("projection type %s has no db-tagged fields", typ)
+	}
+	return columns, nil
+}
+
+// projectItems copies the given columns from each entity into a new R
+func projectItems[R any, T any](items []T, columns []string) ([]R, error) {
+	targetIndex, err := dbFieldIndex[R]()
+	if err != nil {
+		return nil, err
+	}
+	sourceIndex, err := dbFieldIndex[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	sourceType := reflect.TypeOf((*T)(nil)).Elem()
+	targetType := reflect.TypeOf((*R)(nil)).Elem()
+	for _, col := range columns {
+		si, ok := sourceIndex[col]
+		if !ok {
+			return nil, fmt.Errorf("unknown field '%s' for projection", col)
+		}
+		from, to := sourceType.FieldByIndex(si).Type, targetType.FieldByIndex(targetIndex[col]).Type
+		if !from.AssignableTo(to) && !from.ConvertibleTo(to) {
+			return nil, fmt.Errorf("field %s: cannot project %s into %s", col, from, to)
+		}
+	}
+
+	results := make([]R, len(items))
+	for i := range items {
+		src := reflect.ValueOf(&items[i]).Elem()
+		dst := reflect.ValueOf(&results[i]).Elem()
+		for _, col := range columns {
+			value, ok := fieldByIndex(src, sourceIndex[col])
+			if !ok {
+				continue // in a nil embedded struct
+			}
+			field := settableField(dst, targetIndex[col])
+			if value.Type().AssignableTo(field.Type()) {
+				field.Set(value)
+			} else {
+				field.Set(value.Convert(field.Type()))
+			}
+		}
+	}
+	return results, nil
+}
+
+// queryProjection selects the given columns for the rows matching the filter
+func (r *CockroachDBConnector[T, ID]) queryProjection(ctx context.Context, filter *Filter, columns []string) (pgx.Rows, error) {
+	query, args, err := r.projectionQuery(r.softDelete.scope(ctx, filter), columns)
+	if err != nil {
+		return nil, err
+	}
+	return r.getQueryable(ctx).Query(ctx, query, args...)
+}
+
+// queryProjection selects the given columns within the transaction
+func (t *cockroachDBTx[T, ID]) queryProjection(ctx context.Context, filter *Filter, columns []string) (pgx.Rows, error) {
+	query, args, err := t.connector.projectionQuery(t.connector.softDelete.scope(ctx, filter), columns)
+	if err != nil {
+		return nil, err
+	}
+	return t.tx.Query(ctx, query, args...)
+}
+
+func (r *CockroachDBConnector[T, ID]) projectionQuery(filter *Filter, columns []string) (string, []any, error) {
+	for _, col := range columns {
+		if err := r.validateFilterField(col); err != nil {
+			return "", nil, fmt.Errorf("projection: %w", err)
+		}
+	}
+	return r.selectQuery(filter, columns)
+}
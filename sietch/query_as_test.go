@@ -0,0 +1,87 @@
+package sietch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type wideAccount struct {
+	ID      int64  `db:"id"`
+	Email   string `db:"email"`
+	Status  string `db:"status"`
+	Balance int    `db:"balance"`
+	Notes   string `db:"notes"`
+}
+
+type accountSummary struct {
+	ID      int64  `db:"id"`
+	Balance int64  `db:"balance"`
+	Label   string // not mapped
+}
+
+func TestCockroachDBConnector_projectionQuery(t *testing.T) {
+	conn, err := NewCockroachDBConnector[wideAccount, int64](
+		&pgxpool.Pool{},
+		"accounts",
+		func(a *wideAccount) int64 { return a.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	columns, err := projectionColumns[accountSummary]()
+	if err != nil {
+		t.Fatalf("projectionColumns failed: %v", err)
+	}
+
+	filter := NewFilter().Where("status", OpEqual, "active").OrderBy("balance", SortDesc).Limit(10).Build()
+	query, args, err := conn.projectionQuery(filter, columns)
+	if err != nil {
+		t.Fatalf("projectionQuery failed: %v", err)
+	}
+	expected := `SELECT "id", "balance" FROM "accounts" WHERE "status" = $1 ORDER BY "balance" DESC LIMIT 10`
+	if query != expected {
+		t.Errorf("Expected: %s\nGot: %s", expected, query)
+	}
+	if len(args) != 1 {
+		t.Errorf("Expected 1 arg, got %d", len(args))
+	}
+
+	if _, _, err := conn.projectionQuery(filter, []string{"id", "missing"}); err == nil {
+		t.Error("Expected error for a column the entity does not have")
+	}
+}
+
+func TestQueryAs_InMemory(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[wideAccount, int64](func(a *wideAccount) int64 { return a.ID })
+	_ = repo.BatchCreate(ctx, []wideAccount{
+		{ID: 1, Email: "a@x.com", Status: "active", Balance: 10},
+		{ID: 2, Email: "b@x.com", Status: "active", Balance: 30},
+		{ID: 3, Email: "c@x.com", Status: "closed", Balance: 20},
+	})
+
+	filter := NewFilter().Where("status", OpEqual, "active").OrderBy("balance", SortDesc).Build()
+	got, err := QueryAs[accountSummary](ctx, repo, filter)
+	if err != nil {
+		t.Fatalf("QueryAs failed: %v", err)
+	}
+	want := []accountSummary{{ID: 2, Balance: 30}, {ID: 1, Balance: 10}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	type badProjection struct {
+		Missing string `db:"missing"`
+	}
+	if _, err := QueryAs[badProjection](ctx, repo, filter); err == nil {
+		t.Error("Expected error for a projection column the entity does not have")
+	}
+
+	type untagged struct{ ID int64 }
+	if _, err := QueryAs[untagged](ctx, repo, filter); err == nil {
+		t.Error("Expected error for a projection without db tags")
+	}
+}
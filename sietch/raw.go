@@ -0,0 +1,100 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RawQuery runs hand-written SQL through the connector and scans every row into T.
+// Result columns are matched to struct fields by their db tag, so the query may
+// select any subset of columns in any order. Columns without a matching db field
+// produce an error. If a transaction is present in the context, it is used.
+//
+// Example:
+//
+//	accounts, err := sietch.RawQuery(ctx, repo,
+//	    `SELECT "id", "balance" FROM "accounts" WHERE "balance" > $1 ORDER BY "balance"`, 100)
+func RawQuery[T any, ID comparable](ctx context.Context, r *CockroachDBConnector[T, ID], sql string, args ...any) ([]T, error) {
+	if r == nil {
+		return nil, fmt.Errorf("connector cannot be nil")
+	}
+	if sql == "" {
+		return nil, fmt.Errorf("sql cannot be empty")
+	}
+
+	rows, err := r.getQueryable(ctx).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRowsByName[T](rows)
+}
+
+// RawExec runs a hand-written statement and returns the number of affected rows.
+// If a transaction is present in the context, it is used. Constraint violations
+// are reported as a *ConstraintError, like the other writes.
+func (r *CockroachDBConnector[T, ID]) RawExec(ctx context.Context, sql string, args ...any) (int64, error) {
+	if sql == "" {
+		return 0, fmt.Errorf("sql cannot be empty")
+	}
+
+	ct, err := r.getQueryable(ctx).Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, translateWriteError(err)
+	}
+	return ct.RowsAffected(), nil
+}
+
+// scanRowsByName scans rows into T matching result column names against db
+// tags, through the scan destinations of the entity codec of T
+func scanRowsByName[T any](rows pgx.Rows) ([]T, error) {
+	codec, err := newEntityCodec[T]()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(codec.columns))
+	for i, col := range codec.columns {
+		columns[col] = i
+	}
+
+	fields := rows.FieldDescriptions()
+	indexes := make([]int, len(fields))
+	for i, fd := range fields {
+		col, ok := columns[fd.Name]
+		if !ok {
+			return nil, fmt.Errorf("column '%s' has no matching db field", fd.Name)
+		}
+		indexes[i] = col
+	}
+
+	var results []T
+	for rows.Next() {
+		var item T
+		if err := rows.Scan(codec.columnDestinations(reflect.ValueOf(&item).Elem(), indexes)...); err != nil {
+			return nil, err
+		}
+		results = append(results, item)
+	}
+
+	return results, rows.Err()
+}
+
+// dbFieldIndex maps db tag names to struct field index paths for type T,
+// see dbFields
+func dbFieldIndex[T any]() (map[string][]int, error) {
+	var t T
+	typ := reflect.TypeOf(t)
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("type must be a struct")
+	}
+
+	index := make(map[string][]int)
+	for _, f := range dbFields(typ) {
+		index[f.column] = f.index
+	}
+	return index, nil
+}
@@ -0,0 +1,92 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestRawQueryValidation(t *testing.T) {
+	ctx := context.Background()
+	conn := createTestConnector(t)
+
+	if _, err := RawQuery[testutils.Account, int64](ctx, nil, "SELECT 1"); err == nil {
+		t.Error("RawQuery should fail with nil connector")
+	}
+	if _, err := RawQuery(ctx, conn, ""); err == nil {
+		t.Error("RawQuery should fail with empty sql")
+	}
+	if _, err := conn.RawExec(ctx, ""); err == nil {
+		t.Error("RawExec should fail with empty sql")
+	}
+}
+
+func TestDBFieldIndex(t *testing.T) {
+	index, err := dbFieldIndex[testutils.Account]()
+	if err != nil {
+		t.Fatalf("dbFieldIndex failed: %v", err)
+	}
+	if !slices.Equal(index["id"], []int{0}) || !slices.Equal(index["balance"], []int{1}) {
+		t.Errorf("Unexpected field index: %v", index)
+	}
+
+	if _, err := dbFieldIndex[int](); err == nil {
+		t.Error("dbFieldIndex should fail for non-struct types")
+	}
+}
+
+// uniqueViolationTx fails every statement with a unique violation
+type uniqueViolationTx struct {
+	pgx.Tx
+}
+
+func (uniqueViolationTx) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, &pgconn.PgError{Code: "23505", ConstraintName: "accounts_pkey"}
+}
+
+func TestRawExec_TranslatesErrors(t *testing.T) {
+	conn := newBatchConnector(t)
+	ctx := context.WithValue(context.Background(), poolTxsKey{}, map[*pgxpool.Pool]pgx.Tx{conn.pool: uniqueViolationTx{}})
+
+	_, err := conn.RawExec(ctx, `INSERT INTO "accounts" ("id", "balance") VALUES (1, 0)`)
+	var ce *ConstraintError
+	if !errors.As(err, &ce) || ce.Kind != ConstraintUnique || !errors.Is(err, ErrItemAlreadyExists) {
+		t.Errorf("Expected a unique ConstraintError, got %v", err)
+	}
+}
+
+// namedRows are fakeRows with result column names
+type namedRows struct {
+	fakeRows
+	names []string
+}
+
+func (r *namedRows) FieldDescriptions() []pgconn.FieldDescription {
+	fields := make([]pgconn.FieldDescription, len(r.names))
+	for i, name := range r.names {
+		fields[i] = pgconn.FieldDescription{Name: name}
+	}
+	return fields
+}
+
+func TestScanRowsByName(t *testing.T) {
+	rows := &namedRows{fakeRows: fakeRows{values: [][]any{{"Ann", int64(1)}}}, names: []string{"name", "id"}}
+	customers, err := scanRowsByName[embeddedCustomer](rows)
+	if err != nil || len(customers) != 1 || customers[0].ID != 1 || customers[0].Name != "Ann" {
+		t.Fatalf("Expected the selected columns to be scanned by name, got %+v (%v)", customers, err)
+	}
+	if customers[0].AuditFields != nil {
+		t.Error("Expected the embedded pointer of unselected columns to stay nil")
+	}
+
+	rows = &namedRows{fakeRows: fakeRows{values: [][]any{{int64(1)}}}, names: []string{"missing"}}
+	if _, err := scanRowsByName[embeddedCustomer](rows); err == nil {
+		t.Error("Expected an error for a column without a db field")
+	}
+}
@@ -4,7 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/go-redis/redis/v8"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -12,41 +16,276 @@ type RedisConnector[T any, ID comparable] struct {
 	client     *redis.Client
 	defaultTTL time.Duration
 	getID      func(*T) ID
-	keyFunc    func(ID) string
+	keyFunc    func(ID) (string, error)
+	keyPrefix  string         // prefix of every key of keyFunc, see SetKeyPrefix
+	indexes    []redisIndex   // fields with index sets, see SetIndexedFields
+	storage    RedisStorage   // representation of items, see SetStorage
+	search     *searchIndex   // RediSearch index of Query, see CreateSearchIndex
+	txRetry    TxRetryOptions // reruns of conflicting transactions, see SetTxRetryOptions
 }
 
+// NewRedisConnector creates a Redis repository. getID may be nil when T has a
+// pk-tagged field of type ID (see PKAccessor); otherwise a nil getID panics.
 func NewRedisConnector[T any, ID comparable](client *redis.Client, defaultTTL time.Duration, getID func(*T) ID, keyFunc func(ID) string) *RedisConnector[T, ID] {
-	return &RedisConnector[T, ID]{client, defaultTTL, getID, keyFunc}
+	return &RedisConnector[T, ID]{
+		client:     client,
+		defaultTTL: defaultTTL,
+		getID:      mustResolveGetID(getID),
+		keyFunc:    func(id ID) (string, error) { return keyFunc(id), nil },
+	}
+}
+
+// NewRedisConnectorWithCodec creates a Redis repository storing items under
+// prefix followed by the key form of their ID in codec. IDs the codec fails
+// to encode fail the operation with the codec's error. The prefix is
+// declared as with SetKeyPrefix.
+//
+// Example:
+//
+//	repo := NewRedisConnectorWithCodec[Account](client, ttl, getID, "account:", TypedIDCodec[Account, int64]{})
+func NewRedisConnectorWithCodec[T any, ID comparable](client *redis.Client, defaultTTL time.Duration, getID func(*T) ID, prefix string, codec IDCodec[ID]) *RedisConnector[T, ID] {
+	return &RedisConnector[T, ID]{
+		client:     client,
+		defaultTTL: defaultTTL,
+		getID:      mustResolveGetID(getID),
+		keyPrefix:  prefix,
+		keyFunc: func(id ID) (string, error) {
+			key, err := codec.EncodeKey(id)
+			if err != nil {
+				return "", fmt.Errorf("failed to encode the key of %v: %w", id, err)
+			}
+			return prefix + key, nil
+		},
+	}
+}
+
+// keys returns the keys of ids
+func (r *RedisConnector[T, ID]) keys(ids []ID) ([]string, error) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		key, err := r.keyFunc(id)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// SetKeyPrefix declares the prefix every key returned by keyFunc starts
+// with, e.g. "account:", so operations scanning the keyspace (Clear) only
+// touch the keys of this connector. No other keys may share the prefix.
+func (r *RedisConnector[T, ID]) SetKeyPrefix(prefix string) {
+	r.keyPrefix = prefix
+}
+
+// ClearScanCount is the number of keys requested per SCAN by Clear
+const ClearScanCount = 1000
+
+// Clear deletes every key of the connector and its index sets, found with
+// SCAN by the key prefix. It returns ErrUnsupportedOperation unless
+// SetKeyPrefix was called, rather than flushing the database. Keys written
+// while it runs may survive.
+func (r *RedisConnector[T, ID]) Clear(ctx context.Context) error {
+	if r.keyPrefix == "" {
+		return fmt.Errorf("%w: Clear needs a key prefix, see SetKeyPrefix", ErrUnsupportedOperation)
+	}
+	if err := r.unlinkMatching(ctx, escapeGlob(r.keyPrefix)+"*"); err != nil {
+		return err
+	}
+	if len(r.indexes) > 0 {
+		return r.unlinkMatching(ctx, escapeGlob(indexSetPrefix+r.keyPrefix)+"*")
+	}
+	return nil
+}
+
+// unlinkMatching deletes the keys matching a SCAN MATCH pattern
+func (r *RedisConnector[T, ID]) unlinkMatching(ctx context.Context, pattern string) error {
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, pattern, ClearScanCount).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := r.client.Unlink(ctx, keys...).Err(); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// escapeGlob escapes the glob metacharacters of a SCAN MATCH pattern
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
 }
 
 func (r *RedisConnector[T, ID]) Create(ctx context.Context, item *T) error {
 	if item == nil {
 		return errors.New("item cannot be nil")
 	}
-	key := r.keyFunc(r.getID(item))
-	data, err := json.Marshal(item)
+	key, err := r.keyFunc(r.getID(item))
+	if err != nil {
+		return err
+	}
+	data, err := r.encode(item)
 	if err != nil {
 		return err
 	}
-	return r.client.Set(ctx, key, data, r.defaultTTL).Err()
+	if len(r.indexes) > 0 {
+		return r.setIndexed(ctx, []string{key}, []*T{item}, []any{data})
+	}
+	if r.storage != RedisStorageString {
+		_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			r.set(ctx, pipe, key, data)
+			return nil
+		})
+		return err
+	}
+	return r.client.Set(ctx, key, data, r.ttl(ctx)).Err()
 }
 
 func (r *RedisConnector[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
-	key := r.keyFunc(id)
-	data, err := r.client.Get(ctx, key).Result()
+	key, err := r.keyFunc(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.getItem(ctx, r.client, key)
+}
+
+// MGetChunkSize is the maximum number of keys per MGET sent by
+// RedisConnector.GetMany; larger reads are split into pipelined MGETs
+const MGetChunkSize = 500
+
+// parallelDecodeThreshold is the number of values per goroutine when GetMany
+// unmarshals in parallel; smaller reads are decoded sequentially
+const parallelDecodeThreshold = 64
+
+// GetMany fetches the items with the given IDs with MGET, pipelining one
+// MGET per MGetChunkSize keys so a large read is still a single round trip.
+// Values are unmarshalled in parallel. IDs without a key are absent from the
+// returned map.
+func (r *RedisConnector[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	if len(ids) == 0 {
+		return make(map[ID]*T), nil
+	}
+
+	ids = uniqueIDs(ids)
+	keys, err := r.keys(ids)
 	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return nil, ErrItemNotFound
-		}
 		return nil, err
 	}
 
-	var item T
-	if err := json.Unmarshal([]byte(data), &item); err != nil {
+	values, err := r.mget(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	return decodeMany[T](ids, values)
+}
+
+// mget reads keys with one MGET, or with pipelined MGETs of MGetChunkSize
+// keys (JSON.MGET with JSON storage)
+func (r *RedisConnector[T, ID]) mget(ctx context.Context, keys []string) ([]any, error) {
+	return r.mgetOn(ctx, r.client, keys)
+}
+
+// mgetOn is mget through c
+func (r *RedisConnector[T, ID]) mgetOn(ctx context.Context, c redisConn, keys []string) ([]any, error) {
+	if len(keys) <= MGetChunkSize && r.storage == RedisStorageString {
+		return c.MGet(ctx, keys...).Result()
+	}
+
+	pipe := c.Pipeline()
+	cmds := make([]func() []any, 0, (len(keys)+MGetChunkSize-1)/MGetChunkSize)
+	for start := 0; start < len(keys); start += MGetChunkSize {
+		cmds = append(cmds, r.mgetCmd(ctx, pipe, keys[start:min(start+MGetChunkSize, len(keys))]))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
 		return nil, err
 	}
 
-	return &item, nil
+	values := make([]any, 0, len(keys))
+	for _, cmd := range cmds {
+		values = append(values, cmd()...)
+	}
+	return values, nil
+}
+
+// uniqueIDs returns ids without duplicates, keeping the first occurrence
+func uniqueIDs[ID comparable](ids []ID) []ID {
+	seen := make(map[ID]struct{}, len(ids))
+	unique := ids[:0:0]
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+	return unique
+}
+
+// decodeMany unmarshals the MGET values of ids, skipping nil (missing) values.
+// Large reads are split across up to GOMAXPROCS goroutines.
+func decodeMany[T any, ID comparable](ids []ID, values []any) (map[ID]*T, error) {
+	items := make([]*T, len(values))
+	decode := func(start, end int) error {
+		for i := start; i < end; i++ {
+			if values[i] == nil {
+				continue // missing key
+			}
+			item, err := decodeValue[T](values[i])
+			if err != nil {
+				return fmt.Errorf("id %v: %w", ids[i], err)
+			}
+			items[i] = item
+		}
+		return nil
+	}
+
+	workers := min(runtime.GOMAXPROCS(0), len(values)/parallelDecodeThreshold)
+	if workers <= 1 {
+		if err := decode(0, len(values)); err != nil {
+			return nil, err
+		}
+	} else {
+		var wg sync.WaitGroup
+		size := (len(values) + workers - 1) / workers
+		errs := make([]error, workers)
+		for w := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[w] = decode(min(w*size, len(values)), min((w+1)*size, len(values)))
+			}()
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	results := make(map[ID]*T, len(values))
+	for i, item := range items {
+		if item != nil {
+			results[ids[i]] = item
+		}
+	}
+	return results, nil
 }
 
 func (r *RedisConnector[T, ID]) BatchCreate(ctx context.Context, items []T) error {
@@ -57,34 +296,46 @@ func (r *RedisConnector[T, ID]) BatchCreate(ctx context.Context, items []T) erro
 	// Preparar todos los datos primero
 	var commands []struct {
 		key  string
-		data []byte
+		data any
 	}
 	
 	for _, item := range items {
-		key := r.keyFunc(r.getID(&item))
-		data, err := json.Marshal(item)
+		key, err := r.keyFunc(r.getID(&item))
+		if err != nil {
+			return err
+		}
+		data, err := r.encode(&item)
 		if err != nil {
 			return err
 		}
 		commands = append(commands, struct {
 			key  string
-			data []byte
+			data any
 		}{key, data})
 	}
 	
+	if len(r.indexes) > 0 {
+		keys := make([]string, len(commands))
+		data := make([]any, len(commands))
+		ptrs := make([]*T, len(items))
+		for i, cmd := range commands {
+			keys[i], data[i], ptrs[i] = cmd.key, cmd.data, &items[i]
+		}
+		return r.setIndexed(ctx, keys, ptrs, data)
+	}
+
 	// Ahora ejecutar todas las operaciones
 	pipe := r.client.Pipeline()
+	if r.storage == RedisStorageHash {
+		pipe = r.client.TxPipeline() // no reads of half written hashes
+	}
 	for _, cmd := range commands {
-		pipe.Set(ctx, cmd.key, cmd.data, r.defaultTTL)
+		r.set(ctx, pipe, cmd.key, cmd.data)
 	}
 	_, err := pipe.Exec(ctx)
 	return err
 }
 
-func (r *RedisConnector[T, ID]) Query(_ context.Context, _ *Filter) ([]T, error) {
-	return nil, ErrUnsupportedOperation
-}
-
 func (r *RedisConnector[T, ID]) Update(ctx context.Context, item *T) error {
 	if item == nil {
 		return errors.New("item cannot be nil")
@@ -100,7 +351,17 @@ func (r *RedisConnector[T, ID]) BatchUpdate(ctx context.Context, items []T) erro
 }
 
 func (r *RedisConnector[T, ID]) Delete(ctx context.Context, id ID) error {
-	key := r.keyFunc(id)
+	key, err := r.keyFunc(id)
+	if err != nil {
+		return err
+	}
+	if len(r.indexes) > 0 {
+		deleted, err := r.deleteIndexed(ctx, []string{key})
+		if err == nil && deleted == 0 {
+			err = ErrItemNotFound
+		}
+		return err
+	}
 	result, err := r.client.Del(ctx, key).Result()
 	if err != nil {
 		return err
@@ -115,28 +376,42 @@ func (r *RedisConnector[T, ID]) BatchDelete(ctx context.Context, items []ID) err
 	if len(items) == 0 {
 		return nil
 	}
+	if len(r.indexes) > 0 {
+		keys, err := r.keys(items)
+		if err != nil {
+			return err
+		}
+		_, err = r.deleteIndexed(ctx, keys)
+		return err
+	}
 	pipe := r.client.Pipeline()
 	for _, item := range items {
-		key := r.keyFunc(item)
+		key, err := r.keyFunc(item)
+		if err != nil {
+			return err
+		}
 		pipe.Del(ctx, key)
 	}
 	_, err := pipe.Exec(ctx)
 	return err
 }
 
-// Count is not supported by Redis connector
-func (r *RedisConnector[T, ID]) Count(_ context.Context, _ *Filter) (int64, error) {
+// UpdateWhere is not supported by Redis connector
+func (r *RedisConnector[T, ID]) UpdateWhere(_ context.Context, _ *Filter, _ map[string]any) (int64, error) {
 	return 0, ErrUnsupportedOperation
 }
 
-// WithTx is not supported by Redis connector
-func (r *RedisConnector[T, ID]) WithTx(_ context.Context, _ TxFunc[T, ID]) error {
-	return ErrUnsupportedOperation
+// DeleteWhere is not supported by Redis connector
+func (r *RedisConnector[T, ID]) DeleteWhere(_ context.Context, _ *Filter) (int64, error) {
+	return 0, ErrUnsupportedOperation
 }
 
 // Exists checks if an entity with the given ID exists in Redis
 func (r *RedisConnector[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
-	key := r.keyFunc(id)
+	key, err := r.keyFunc(id)
+	if err != nil {
+		return false, err
+	}
 	result, err := r.client.Exists(ctx, key).Result()
 	if err != nil {
 		return false, err
@@ -155,3 +430,13 @@ func (r *RedisConnector[T, ID]) Upsert(ctx context.Context, item *T) error {
 func (r *RedisConnector[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
 	return r.BatchCreate(ctx, items)
 }
+
+// marshalNormalized encodes a copy of item with converter-backed fields
+// normalized, so Redis stores the same representation as the other connectors
+func marshalNormalized[T any](item *T) ([]byte, error) {
+	normalized := *item
+	if err := DefaultConverters.normalizeStruct(&normalized); err != nil {
+		return nil, err
+	}
+	return json.Marshal(normalized)
+}
@@ -0,0 +1,322 @@
+package sietch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// FieldIncrement is an UpdateFields value adding to a numeric field
+// atomically, with HINCRBY or HINCRBYFLOAT, see Increment
+type FieldIncrement struct {
+	by    int64
+	byF   float64
+	float bool // HINCRBYFLOAT by byF rather than HINCRBY by
+}
+
+// Increment returns an UpdateFields value adding delta to an integer field
+func Increment(delta int64) FieldIncrement {
+	return FieldIncrement{by: delta}
+}
+
+// IncrementFloat returns an UpdateFields value adding delta to a float field
+func IncrementFloat(delta float64) FieldIncrement {
+	return FieldIncrement{byF: delta, float: true}
+}
+
+// UpdateFields sets the given fields (by db tag) of the item with the
+// given ID, which must exist, without reading or rewriting the others.
+// FieldIncrement values add to numeric fields on the server, so concurrent
+// increments don't overwrite each other. It needs hash storage (see
+// SetStorage) and fails with ErrNoUpdateItem if the item doesn't exist.
+//
+//	err := repo.UpdateFields(ctx, id, map[string]any{
+//	    "status": "active",
+//	    "logins": sietch.Increment(1),
+//	})
+func (r *RedisConnector[T, ID]) UpdateFields(ctx context.Context, id ID, updates map[string]any) error {
+	if r.storage != RedisStorageHash {
+		return fmt.Errorf("%w: UpdateFields needs hash storage, see SetStorage", ErrUnsupportedOperation)
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	codec := hashCodecOf[T]()
+	var sets []any
+	incrs := make(map[string]FieldIncrement)
+	indexed := false
+	for name, value := range updates {
+		f, ok := codec.field(name)
+		if !ok {
+			return fmt.Errorf("unknown field %q", name)
+		}
+		for _, idx := range r.indexes {
+			indexed = indexed || slices.Equal(idx.field, f.index)
+		}
+
+		ft := reflect.TypeOf((*T)(nil)).Elem().FieldByIndex(f.index).Type
+		if inc, ok := value.(FieldIncrement); ok {
+			if inc.float && !isFloatKind(ft) || !inc.float && kindClass(ft) != "int" {
+				return fmt.Errorf("field %q of type %s cannot take this increment", name, ft)
+			}
+			incrs[f.name] = inc
+			continue
+		}
+		v := reflect.New(ft).Elem()
+		if err := assignFieldValue(v, value); err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+		s, present, err := encodeHashValue(v)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+		if !present {
+			return fmt.Errorf("field %q: nil values can't be set by UpdateFields", name)
+		}
+		sets = append(sets, f.name, s)
+	}
+
+	key, err := r.keyFunc(id)
+	if err != nil {
+		return err
+	}
+	return retryTx(ctx, r.txRetry.withTable(r.keyPrefix), func() error {
+		err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+			var stored *T
+			if indexed {
+				item, err := r.getItem(ctx, tx, key)
+				if errors.Is(err, ErrItemNotFound) {
+					return ErrNoUpdateItem
+				} else if err != nil {
+					return err
+				}
+				stored = item
+			} else if n, err := tx.Exists(ctx, key).Result(); err != nil {
+				return err
+			} else if n == 0 {
+				return ErrNoUpdateItem
+			}
+
+			_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				if len(sets) > 0 {
+					pipe.HSet(ctx, key, sets...)
+				}
+				for field, inc := range incrs {
+					if inc.float {
+						pipe.HIncrByFloat(ctx, key, field, inc.byF)
+					} else {
+						pipe.HIncrBy(ctx, key, field, inc.by)
+					}
+				}
+				if stored != nil {
+					updated := *stored
+					if err := codec.apply(&updated, sets, incrs); err != nil {
+						return err
+					}
+					r.moveIndexed(ctx, pipe, key, stored, &updated)
+				}
+				return nil
+			})
+			return err
+		}, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			return fmt.Errorf("%w: %w", ErrTxConflict, err)
+		}
+		return err
+	})
+}
+
+// isFloatKind reports whether t is a float or a pointer to one
+func isFloatKind(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
+
+// hashCodec encodes the items of a struct type as hash fields, one per
+// column
+type hashCodec struct {
+	fields []hashField
+	byName map[string]int // field position by column and Go field name
+}
+
+// hashField is a column stored as a hash field
+type hashField struct {
+	name  string // column, the hash field
+	index []int  // struct field index path
+}
+
+var hashCodecs sync.Map // map[reflect.Type]*hashCodec
+
+// hashCodecOf returns the codec of T, which must be a struct
+func hashCodecOf[T any]() *hashCodec {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if cached, ok := hashCodecs.Load(typ); ok {
+		return cached.(*hashCodec)
+	}
+
+	codec := &hashCodec{byName: make(map[string]int)}
+	fields := slices.DeleteFunc(namedFields(typ), func(f dbField) bool { return !f.field.IsExported() })
+	for i, f := range fields {
+		codec.byName[f.column] = i
+		codec.fields = append(codec.fields, hashField{name: f.column, index: f.index})
+	}
+	for i, f := range fields {
+		if _, exists := codec.byName[f.field.Name]; !exists {
+			codec.byName[f.field.Name] = i
+		}
+	}
+
+	cached, _ := hashCodecs.LoadOrStore(typ, codec)
+	return cached.(*hashCodec)
+}
+
+// field resolves a column or Go field name
+func (c *hashCodec) field(name string) (hashField, bool) {
+	i, ok := c.byName[name]
+	if !ok {
+		return hashField{}, false
+	}
+	return c.fields[i], true
+}
+
+// encode returns the field/value pairs of item for HSET; nil fields are
+// left out
+func (c *hashCodec) encode(item any) ([]any, error) {
+	v := reflect.ValueOf(item).Elem()
+	pairs := make([]any, 0, 2*len(c.fields))
+	for _, f := range c.fields {
+		field, ok := fieldByIndex(v, f.index)
+		if !ok {
+			continue // in a nil embedded pointer, like a nil field
+		}
+		s, present, err := encodeHashValue(field)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+		if present {
+			pairs = append(pairs, f.name, s)
+		}
+	}
+	return pairs, nil
+}
+
+// decode sets the fields of item from the fields of its hash
+func (c *hashCodec) decode(fields map[string]string, item any) error {
+	v := reflect.ValueOf(item).Elem()
+	for _, f := range c.fields {
+		s, ok := fields[f.name]
+		if !ok {
+			continue
+		}
+		if err := decodeHashValue(s, settableField(v, f.index)); err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// apply sets the HSET pairs and increments of UpdateFields on item, a
+// shallow copy of the stored item
+func (c *hashCodec) apply(item any, sets []any, incrs map[string]FieldIncrement) error {
+	v := reflect.ValueOf(item).Elem()
+	for i := 0; i < len(sets); i += 2 {
+		f, _ := c.field(sets[i].(string))
+		if err := decodeHashValue(sets[i+1].(string), detachedField(v, f.index)); err != nil {
+			return err
+		}
+	}
+	for name, inc := range incrs {
+		f, _ := c.field(name)
+		fv := detachedField(v, f.index)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		switch {
+		case inc.float:
+			fv.SetFloat(fv.Float() + inc.byF)
+		case fv.CanInt():
+			fv.SetInt(fv.Int() + inc.by)
+		default:
+			fv.SetUint(uint64(int64(fv.Uint()) + inc.by))
+		}
+	}
+	return nil
+}
+
+// encodeHashValue formats v as a hash field value: numbers and booleans
+// as text, so HINCRBY can add to them, strings as is and anything else as
+// JSON. present is false for nil pointers.
+func encodeHashValue(v reflect.Value) (s string, present bool, err error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", false, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true, nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), true, nil
+	}
+	data, err := json.Marshal(v.Interface())
+	return string(data), true, err
+}
+
+// decodeHashValue parses a value written by encodeHashValue into v
+func decodeHashValue(s string, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type hashItem struct {
+	ID      int64             `db:"id"`
+	Name    string            `db:"name"`
+	Score   float64           `db:"score"`
+	Active  bool              `db:"active"`
+	Visits  *uint32           `db:"visits"`
+	Tags    []string          `db:"tags"`
+	Meta    map[string]string `db:"meta"`
+	Created time.Time         `db:"created_at"`
+	Secret  string            `db:"-"`
+}
+
+func TestHashCodec_RoundTrip(t *testing.T) {
+	codec := hashCodecOf[hashItem]()
+	visits := uint32(7)
+	item := hashItem{
+		ID: 1, Name: "a", Score: 1.5, Active: true, Visits: &visits,
+		Tags: []string{"x"}, Meta: map[string]string{"k": "v"},
+		Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Secret: "s",
+	}
+
+	pairs, err := codec.encode(&item)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	fields := make(map[string]string)
+	for i := 0; i < len(pairs); i += 2 {
+		fields[pairs[i].(string)] = pairs[i+1].(string)
+	}
+	if fields["score"] != "1.5" || fields["visits"] != "7" || fields["active"] != "true" || fields["tags"] != `["x"]` {
+		t.Errorf("Unexpected fields %v", fields)
+	}
+	if _, ok := fields["Secret"]; ok {
+		t.Error("Expected the db:\"-\" field to be left out")
+	}
+
+	var decoded hashItem
+	if err := codec.decode(fields, &decoded); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if decoded.Name != "a" || *decoded.Visits != 7 || decoded.Meta["k"] != "v" || !decoded.Created.Equal(item.Created) || decoded.Secret != "" {
+		t.Errorf("Unexpected decoded item %+v", decoded)
+	}
+
+	item.Visits = nil
+	pairs, _ = codec.encode(&item)
+	for i := 0; i < len(pairs); i += 2 {
+		if pairs[i] == "visits" {
+			t.Error("Expected the nil field to be left out")
+		}
+	}
+
+	if err := codec.decode(map[string]string{"score": "x"}, &decoded); err == nil {
+		t.Error("Expected an error for a malformed number")
+	}
+}
+
+func TestHashCodec_Apply(t *testing.T) {
+	codec := hashCodecOf[hashItem]()
+	item := hashItem{Name: "a", Score: 1}
+	err := codec.apply(&item, []any{"name", "b"}, map[string]FieldIncrement{
+		"score":  IncrementFloat(0.5),
+		"visits": Increment(2),
+	})
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if item.Name != "b" || item.Score != 1.5 || item.Visits == nil || *item.Visits != 2 {
+		t.Errorf("Unexpected item %+v", item)
+	}
+}
+
+func TestRedisConnector_UpdateFieldsValidation(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisConnector[hashItem, int64](nil, 0,
+		func(i *hashItem) int64 { return i.ID },
+		func(id int64) string { return "hash-item:" + string(rune('0'+id)) },
+	)
+	if err := repo.UpdateFields(ctx, 1, map[string]any{"name": "a"}); !errors.Is(err, ErrUnsupportedOperation) {
+		t.Errorf("Expected ErrUnsupportedOperation without hash storage, got %v", err)
+	}
+
+	repo.SetStorage(RedisStorageHash)
+	for name, updates := range map[string]map[string]any{
+		"unknown field":      {"missing": 1},
+		"int increment":      {"score": Increment(1)},
+		"float increment":    {"visits": IncrementFloat(1)},
+		"string increment":   {"name": Increment(1)},
+		"incompatible value": {"active": "yes"},
+		"nil value":          {"visits": nil},
+	} {
+		if err := repo.UpdateFields(ctx, 1, updates); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestHashCodec_EmbeddedFields(t *testing.T) {
+	codec := hashCodecOf[embeddedCustomer]()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	item := embeddedCustomer{baseEntity: baseEntity{ID: 7, CreatedAt: created}, Name: "a"}
+
+	pairs, err := codec.encode(&item)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	fields := make(map[string]string)
+	for i := 0; i < len(pairs); i += 2 {
+		fields[pairs[i].(string)] = pairs[i+1].(string)
+	}
+	if fields["id"] != "7" || fields["name"] != "a" || fields["created_at"] == "" {
+		t.Errorf("Expected the embedded columns, got %v", fields)
+	}
+	if _, ok := fields["updated_by"]; ok {
+		t.Error("Expected the fields of a nil embedded pointer to be left out")
+	}
+
+	fields["updated_by"] = "bob"
+	var decoded embeddedCustomer
+	if err := codec.decode(fields, &decoded); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if decoded.ID != 7 || !decoded.CreatedAt.Equal(created) || decoded.AuditFields == nil || decoded.UpdatedBy != "bob" {
+		t.Errorf("Unexpected decoded item %+v", decoded)
+	}
+	if f, ok := codec.field("CreatedAt"); !ok || f.name != "created_at" {
+		t.Errorf("Expected the promoted Go field name to resolve, got %+v", f)
+	}
+}
@@ -0,0 +1,320 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// redisIndex is a field of the Redis connector with index sets, see
+// SetIndexedFields
+type redisIndex struct {
+	name  string // column name, part of the set keys
+	field []int  // struct field index path
+}
+
+// SetIndexedFields maintains an index set per value of each field, holding
+// the keys of the items with that value. Sets are named
+// idx:{prefix}{field}:{value} after the key prefix, so they are outside the
+// keys Query scans. Query and Count resolve the equality conditions of the
+// top level of a filter on indexed fields by intersecting their sets rather
+// than scanning the keyspace; the other conditions are evaluated on the
+// items found.
+//
+// Fields must be strings, booleans or integers, or pointers to them; nil
+// values aren't indexed. Each write WATCHes its keys and reads the stored
+// items to move the keys between sets in the same transaction, rerunning it
+// when a concurrent write gets in between (see SetTxRetryOptions), so a key
+// is always in the sets of its current values. Sets may keep the keys of
+// expired items: queries skip them, removing those of items which no
+// longer exist. Call it before the connector is used, as items written
+// earlier are missing from the sets.
+func (r *RedisConnector[T, ID]) SetIndexedFields(fields ...string) error {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("indexed fields need a struct type, got %s", typ)
+	}
+
+	indexes := make([]redisIndex, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for _, name := range fields {
+		field, ok := columnPath(typ, name)
+		if !ok {
+			return fmt.Errorf("unknown indexed field %q", name)
+		}
+		key := fmt.Sprint(field)
+		if seen[key] {
+			return fmt.Errorf("field %q is indexed twice", name)
+		}
+		seen[key] = true
+		if ft := typ.FieldByIndex(field).Type; kindClass(ft) == "" {
+			return fmt.Errorf("field %q of type %s cannot be indexed", name, ft)
+		}
+		indexes = append(indexes, redisIndex{name: name, field: field})
+	}
+	r.indexes = indexes
+	return nil
+}
+
+// kindClass groups the indexable types whose values compare equal when
+// their indexValue is equal: "string", "bool" or "int". It returns "" for
+// other types.
+func kindClass(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	}
+	return ""
+}
+
+// indexValue formats v for an index set key; ok is false for nil
+func indexValue(v reflect.Value) (string, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	}
+	return "", false
+}
+
+// indexedField returns the field of v at index, invalid when it sits in a
+// nil embedded pointer, so it isn't indexed
+func indexedField(v reflect.Value, index []int) reflect.Value {
+	field, _ := fieldByIndex(v, index)
+	return field
+}
+
+// indexSetPrefix starts the keys of index sets, followed by the key prefix
+const indexSetPrefix = "idx:"
+
+// indexKey returns the key of the set of the items whose idx field is value
+func (r *RedisConnector[T, ID]) indexKey(idx redisIndex, value string) string {
+	return indexSetPrefix + r.keyPrefix + idx.name + ":" + value
+}
+
+// itemIndexKeys returns the keys of the sets item belongs to, by index
+func (r *RedisConnector[T, ID]) itemIndexKeys(item *T) []string {
+	sets := make([]string, len(r.indexes))
+	if item == nil {
+		return sets
+	}
+	v := reflect.ValueOf(item).Elem()
+	for i, idx := range r.indexes {
+		if value, ok := indexValue(indexedField(v, idx.field)); ok {
+			sets[i] = r.indexKey(idx, value)
+		}
+	}
+	return sets
+}
+
+// setIndexed writes items under keys in one transaction, moving the keys
+// from the sets of the stored items to the sets of the new ones. The keys
+// are WATCHed while the stored items are read, so a concurrent write
+// between the read and the transaction reruns it, see SetTxRetryOptions.
+func (r *RedisConnector[T, ID]) setIndexed(ctx context.Context, keys []string, items []*T, data []any) error {
+	return r.watchIndexed(ctx, keys, func(pipe redis.Pipeliner, stored map[string]*T) {
+		for i, key := range keys {
+			r.set(ctx, pipe, key, data[i])
+			r.moveIndexed(ctx, pipe, key, stored[key], items[i])
+		}
+	})
+}
+
+// watchIndexed WATCHes keys, reads the items stored under them and runs the
+// commands fn queues in MULTI/EXEC, rerunning it all while another client
+// writes one of the keys meanwhile. After the last rerun it fails with
+// ErrTxConflict.
+func (r *RedisConnector[T, ID]) watchIndexed(ctx context.Context, keys []string, fn func(pipe redis.Pipeliner, stored map[string]*T)) error {
+	return retryTx(ctx, r.txRetry.withTable(r.keyPrefix), func() error {
+		err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+			values, err := r.mgetOn(ctx, tx, keys)
+			if err != nil {
+				return err
+			}
+			stored, err := decodeMany[T](keys, values)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				fn(pipe, stored)
+				return nil
+			})
+			return err
+		}, keys...)
+		if errors.Is(err, redis.TxFailedErr) {
+			return fmt.Errorf("%w: %w", ErrTxConflict, err)
+		}
+		return err
+	})
+}
+
+// moveIndexed queues in pipe the move of key from the sets of old to the
+// sets of item, either of which may be nil
+func (r *RedisConnector[T, ID]) moveIndexed(ctx context.Context, pipe redis.Pipeliner, key string, old, item *T) {
+	from, to := r.itemIndexKeys(old), r.itemIndexKeys(item)
+	for i := range r.indexes {
+		if from[i] == to[i] {
+			continue
+		}
+		if from[i] != "" {
+			pipe.SRem(ctx, from[i], key)
+		}
+		if to[i] != "" {
+			pipe.SAdd(ctx, to[i], key)
+		}
+	}
+}
+
+// deleteIndexed deletes keys in one transaction, removing them from the
+// sets of the stored items, and returns the number of keys deleted
+func (r *RedisConnector[T, ID]) deleteIndexed(ctx context.Context, keys []string) (int64, error) {
+	var del *redis.IntCmd
+	err := r.watchIndexed(ctx, keys, func(pipe redis.Pipeliner, stored map[string]*T) {
+		del = pipe.Del(ctx, keys...)
+		for key, item := range stored {
+			r.moveIndexed(ctx, pipe, key, item, nil)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return del.Val(), nil
+}
+
+// indexLookup is an equality condition resolved by an index set
+type indexLookup struct {
+	set   string
+	field []int
+	value string
+}
+
+// indexLookups returns the lookups of the top-level equality conditions of
+// filter on indexed fields whose value has the class of the field
+func (r *RedisConnector[T, ID]) indexLookups(filter *Filter) []indexLookup {
+	if filter == nil || len(r.indexes) == 0 {
+		return nil
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	var lookups []indexLookup
+	for _, c := range filter.Conditions {
+		if !c.IsLeaf() || c.Operator != OpEqual || c.Value == nil {
+			continue
+		}
+		field, ok := columnPath(typ, c.Field)
+		if !ok || kindClass(typ.FieldByIndex(field).Type) != kindClass(reflect.TypeOf(c.Value)) {
+			continue
+		}
+		for _, idx := range r.indexes {
+			if !slices.Equal(idx.field, field) {
+				continue
+			}
+			if value, ok := indexValue(reflect.ValueOf(c.Value)); ok {
+				lookups = append(lookups, indexLookup{set: r.indexKey(idx, value), field: field, value: value})
+			}
+		}
+	}
+	return lookups
+}
+
+// candidates calls fn with the items filter may match until it returns
+// false: the members of the intersection of its index sets, or every item
+func (r *RedisConnector[T, ID]) candidates(ctx context.Context, filter *Filter, fn func(item *T) bool) error {
+	lookups := r.indexLookups(filter)
+	if len(lookups) == 0 {
+		return r.scan(ctx, fn)
+	}
+
+	sets := make([]string, len(lookups))
+	for i, l := range lookups {
+		sets[i] = l.set
+	}
+	keys, err := r.client.SInter(ctx, sets...).Result()
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(keys); start += MGetChunkSize {
+		chunk := keys[start:min(start+MGetChunkSize, len(keys))]
+		values, err := r.mget(ctx, chunk)
+		if err != nil {
+			return err
+		}
+		items, err := decodeMany[T](chunk, values)
+		if err != nil {
+			return err
+		}
+
+		var missing []any
+		for _, key := range chunk {
+			item, ok := items[key]
+			if !ok {
+				missing = append(missing, key)
+				continue
+			}
+			if r.indexed(item, lookups) && !fn(item) {
+				r.prune(ctx, sets, missing)
+				return nil
+			}
+		}
+		r.prune(ctx, sets, missing)
+	}
+	return nil
+}
+
+// indexed reports whether item still has the values of lookups; it may
+// have been written with other values since the sets were read
+func (r *RedisConnector[T, ID]) indexed(item *T, lookups []indexLookup) bool {
+	v := reflect.ValueOf(item).Elem()
+	for _, l := range lookups {
+		if value, ok := indexValue(indexedField(v, l.field)); !ok || value != l.value {
+			return false
+		}
+	}
+	return true
+}
+
+// pruneScript removes from the set KEYS[1] the keys in ARGV which don't
+// exist, atomically, so a key written meanwhile is kept
+var pruneScript = redis.NewScript(`
+for _, key in ipairs(ARGV) do
+	if redis.call('EXISTS', key) == 0 then
+		redis.call('SREM', KEYS[1], key)
+	end
+end
+return 0
+`)
+
+// prune removes the keys of deleted or expired items from sets. It is best
+// effort: keys it misses are skipped and pruned by later queries.
+func (r *RedisConnector[T, ID]) prune(ctx context.Context, sets []string, missing []any) {
+	if len(missing) == 0 {
+		return
+	}
+	for _, set := range sets {
+		_ = pruneScript.Run(ctx, r.client, []string{set}, missing...).Err()
+	}
+}
@@ -0,0 +1,160 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+)
+
+// QueryScanCount is the number of keys requested per SCAN by Query and Count
+const QueryScanCount = 1000
+
+// Query returns the items matching filter by scanning every key of the
+// connector with SCAN and evaluating the filter client-side, as the
+// InMemory connector does. It needs a key prefix, see SetKeyPrefix.
+//
+// Unless equality conditions on indexed fields narrow it (see
+// SetIndexedFields), every query reads the whole keyspace of the connector,
+// QueryScanCount keys per round trip, so its cost grows with the number of
+// items rather than with the number of results: keep it for small datasets
+// and occasional lookups. Only an unsorted, non-distinct query with a limit
+// stops early.
+// Items written while it runs may be missed. Grouping, locking and
+// subqueries are not supported.
+func (r *RedisConnector[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
+	if err := r.checkScanFilter(filter); err != nil {
+		return nil, err
+	}
+	if r.search != nil {
+		return r.searchQuery(ctx, filter)
+	}
+
+	// Without sorting or DISTINCT the first offset+limit matches are a
+	// valid result, so the scan can stop there
+	want := -1
+	if filter != nil && filter.Limit != nil && *filter.Limit > 0 && len(filter.Sort) == 0 && !filter.Distinct {
+		want = *filter.Limit
+		if filter.Offset != nil && *filter.Offset > 0 {
+			want += *filter.Offset
+		}
+	}
+
+	matches := compileFilter[T](filter)
+	var results []T
+	err := r.candidates(ctx, filter, func(item *T) bool {
+		if matches(item) {
+			results = append(results, *item)
+		}
+		return want < 0 || len(results) < want
+	})
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		return results, nil
+	}
+
+	if len(filter.Sort) > 0 {
+		results = sortResults(results, filter.Sort, nil)
+	}
+	if filter.Distinct {
+		results = distinctResults(results)
+	}
+	if filter.Offset != nil && *filter.Offset > 0 {
+		if *filter.Offset >= len(results) {
+			return []T{}, nil
+		}
+		results = results[*filter.Offset:]
+	}
+	if filter.Limit != nil && *filter.Limit > 0 && *filter.Limit < len(results) {
+		results = results[:*filter.Limit]
+	}
+	return results, nil
+}
+
+// FindOne returns the first item matching filter, scanning like Query
+func (r *RedisConnector[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	return findOne(ctx, filter, r.Query)
+}
+
+// Count returns the number of items matching filter, reading the items like
+// Query
+func (r *RedisConnector[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
+	if err := r.checkScanFilter(filter); err != nil {
+		return 0, err
+	}
+	if r.search != nil {
+		return r.searchCount(ctx, filter)
+	}
+
+	matches := compileFilter[T](filter)
+	var count int64
+	err := r.candidates(ctx, filter, func(item *T) bool {
+		if matches(item) {
+			count++
+		}
+		return true
+	})
+	return count, err
+}
+
+// checkScanFilter reports whether filter can be evaluated by a scan
+func (r *RedisConnector[T, ID]) checkScanFilter(filter *Filter) error {
+	if r.keyPrefix == "" {
+		return fmt.Errorf("%w: queries need a key prefix, see SetKeyPrefix", ErrUnsupportedOperation)
+	}
+	if filter == nil {
+		return nil
+	}
+	switch {
+	case len(filter.GroupBy) > 0 || len(filter.Having) > 0:
+		return fmt.Errorf("%w: grouping on Redis", ErrUnsupportedOperation)
+	case filter.Lock != LockNone:
+		return fmt.Errorf("%w: row locks on Redis", ErrUnsupportedOperation)
+	case hasSubquery(filter.Conditions):
+		return fmt.Errorf("%w: subqueries on Redis", ErrUnsupportedOperation)
+	}
+	return nil
+}
+
+// scan calls fn with every item of the connector, SCANning the key prefix
+// in batches of QueryScanCount keys read with MGET, until fn returns false.
+// SCAN may return a key more than once; each key is passed once.
+func (r *RedisConnector[T, ID]) scan(ctx context.Context, fn func(item *T) bool) error {
+	seen := make(map[string]struct{})
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, escapeGlob(r.keyPrefix)+"*", QueryScanCount).Result()
+		if err != nil {
+			return err
+		}
+
+		fresh := keys[:0]
+		for _, key := range keys {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				fresh = append(fresh, key)
+			}
+		}
+		if len(fresh) > 0 {
+			values, err := r.mget(ctx, fresh)
+			if err != nil {
+				return err
+			}
+			// keys deleted or expired since the SCAN are absent
+			items, err := decodeMany[T](fresh, values)
+			if err != nil {
+				return err
+			}
+			for _, key := range fresh {
+				if item, ok := items[key]; ok && !fn(item) {
+					return nil
+				}
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
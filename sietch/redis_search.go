@@ -0,0 +1,465 @@
+package sietch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchPageSize is the number of items requested per FT.SEARCH by queries
+// without a limit
+const SearchPageSize = 1000
+
+// searchIndex is the RediSearch index of the Redis connector
+type searchIndex struct {
+	name   string
+	fields map[string]searchField // by column and Go field name
+}
+
+// searchField is a field of a RediSearch index
+type searchField struct {
+	alias   string // name of the field in queries, its column
+	path    string // JSONPath of the field in the documents
+	numeric bool   // NUMERIC, or else a case-sensitive TAG
+}
+
+// CreateSearchIndex creates the RediSearch index name over the documents of
+// the connector, unless it exists, and makes Query, FindOne and Count run
+// FT.SEARCH on it rather than scanning. It needs JSON storage (see
+// SetStorage), a key prefix and the RediSearch module.
+//
+// Every field with a db tag, or exported field without one, is indexed
+// under its column name: numbers as NUMERIC, strings and times as TAG
+// (exact, case-sensitive matches), all SORTABLE; fields of other types
+// can't be filtered on. An existing index isn't checked against the fields.
+//
+// Filters are translated to the query syntax: comparisons and BETWEEN on
+// numbers; equality, IN and LIKE prefixes ('abc%') on strings and times;
+// nested AND, OR and NOT. Sorting is limited to one field. Other operators,
+// DISTINCT, NULL ordering, grouping, locks and subqueries return
+// ErrUnsupportedOperation. Documents existing when the index is created
+// are indexed in the background.
+func (r *RedisConnector[T, ID]) CreateSearchIndex(ctx context.Context, name string) error {
+	if r.storage != RedisStorageJSON {
+		return fmt.Errorf("%w: search indexes need JSON storage, see SetStorage", ErrUnsupportedOperation)
+	}
+	if r.keyPrefix == "" {
+		return fmt.Errorf("%w: search indexes need a key prefix, see SetKeyPrefix", ErrUnsupportedOperation)
+	}
+
+	index, err := newSearchIndex(name, reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return err
+	}
+
+	args := []any{"FT.CREATE", name, "ON", "JSON", "PREFIX", 1, r.keyPrefix, "SCHEMA"}
+	for _, f := range index.schema() {
+		args = append(args, f.path, "AS", f.alias)
+		if f.numeric {
+			args = append(args, "NUMERIC", "SORTABLE")
+		} else {
+			args = append(args, "TAG", "CASESENSITIVE", "SORTABLE")
+		}
+	}
+	if err := r.client.Do(ctx, args...).Err(); err != nil && !strings.Contains(err.Error(), "Index already exists") {
+		return fmt.Errorf("failed to create search index %s: %w", name, err)
+	}
+	r.search = index
+	return nil
+}
+
+// newSearchIndex derives the fields of a search index from the struct typ
+func newSearchIndex(name string, typ reflect.Type) (*searchIndex, error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("search indexes need a struct type, got %s", typ)
+	}
+
+	index := &searchIndex{name: name, fields: make(map[string]searchField)}
+	for _, df := range namedFields(typ) {
+		sf := df.field
+		path, ok := jsonPath(typ, df.index)
+		if !sf.IsExported() || !ok {
+			continue
+		}
+
+		ft := sf.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		var numeric bool
+		switch {
+		case ft == reflect.TypeOf(time.Time{}), ft.Kind() == reflect.String:
+		case kindClass(ft) == "int", ft.Kind() == reflect.Float32, ft.Kind() == reflect.Float64:
+			numeric = true
+		default:
+			continue
+		}
+
+		f := searchField{alias: df.column, path: path, numeric: numeric}
+		index.fields[df.column] = f
+		if _, exists := index.fields[sf.Name]; !exists {
+			index.fields[sf.Name] = f
+		}
+	}
+	if len(index.schema()) == 0 {
+		return nil, fmt.Errorf("%s has no field a search index can hold", typ)
+	}
+	return index, nil
+}
+
+// jsonPath returns the JSONPath of the field of typ at index in the JSON
+// encoding of typ, where untagged embedded structs are flattened like
+// dbFields does. ok is false if encoding/json leaves the field out.
+func jsonPath(typ reflect.Type, index []int) (path string, ok bool) {
+	path = "$"
+	for k, i := range index {
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		sf := typ.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		switch {
+		case name == "-":
+			return "", false
+		case name == "" && sf.Anonymous && k < len(index)-1:
+			// a struct promoted into the enclosing object
+		case name == "":
+			path += "." + sf.Name
+		default:
+			path += "." + name
+		}
+		typ = sf.Type
+	}
+	return path, true
+}
+
+// schema returns the fields of the index, once each, ordered by alias
+func (idx *searchIndex) schema() []searchField {
+	seen := make(map[string]bool, len(idx.fields))
+	var fields []searchField
+	for _, f := range idx.fields {
+		if !seen[f.alias] {
+			seen[f.alias] = true
+			fields = append(fields, f)
+		}
+	}
+	slices.SortFunc(fields, func(a, b searchField) int { return strings.Compare(a.alias, b.alias) })
+	return fields
+}
+
+// field resolves a filter field name like fieldByColumn
+func (idx *searchIndex) field(name string) (searchField, error) {
+	if name != "" {
+		if f, ok := idx.fields[name]; ok {
+			return f, nil
+		}
+		if f, ok := idx.fields[strings.ToUpper(name[:1])+name[1:]]; ok {
+			return f, nil
+		}
+	}
+	return searchField{}, fmt.Errorf("%w: field %q is not in search index %s", ErrUnsupportedOperation, name, idx.name)
+}
+
+// query translates conditions, combined with op, to the query syntax
+func (idx *searchIndex) query(conditions []Condition, op LogicalOperator) (string, error) {
+	if len(conditions) == 0 {
+		return "*", nil
+	}
+	parts := make([]string, len(conditions))
+	for i, c := range conditions {
+		var err error
+		if c.IsLeaf() {
+			parts[i], err = idx.leaf(c)
+		} else {
+			parts[i], err = idx.composite(c)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	sep := " "
+	if op == LogicalOR {
+		sep = " | "
+	}
+	return "(" + strings.Join(parts, sep) + ")", nil
+}
+
+// composite translates a nested AND, OR or NOT condition
+func (idx *searchIndex) composite(c Condition) (string, error) {
+	switch c.LogicalOp {
+	case LogicalAND, LogicalOR:
+		return idx.query(c.Conditions, c.LogicalOp)
+	case LogicalNOT:
+		q, err := idx.query(c.Conditions, LogicalAND)
+		if err != nil {
+			return "", err
+		}
+		return "-(" + q + ")", nil
+	}
+	return "", fmt.Errorf("%w: logical operator %q on RediSearch", ErrUnsupportedOperation, c.LogicalOp)
+}
+
+// leaf translates a field comparison
+func (idx *searchIndex) leaf(c Condition) (string, error) {
+	f, err := idx.field(c.Field)
+	if err != nil {
+		return "", err
+	}
+	unsupported := fmt.Errorf("%w: %s on %s field %q with RediSearch", ErrUnsupportedOperation, c.Operator, f.kind(), c.Field)
+
+	switch c.Operator {
+	case OpEqual, OpNotEqual:
+		term, err := f.match(c.Value)
+		if err != nil {
+			return "", err
+		}
+		if c.Operator == OpNotEqual {
+			return "-" + term, nil
+		}
+		return term, nil
+	case OpIn, OpNotIn:
+		values, ok := sliceElements(c.Value)
+		if !ok || len(values) == 0 {
+			return "", fmt.Errorf("%s needs a non-empty slice, got %v", c.Operator, c.Value)
+		}
+		terms := make([]string, len(values))
+		for i, v := range values {
+			if terms[i], err = f.match(v); err != nil {
+				return "", err
+			}
+		}
+		q := "(" + strings.Join(terms, " | ") + ")"
+		if c.Operator == OpNotIn {
+			return "-" + q, nil
+		}
+		return q, nil
+	case OpLike:
+		pattern, ok := c.Value.(string)
+		prefix, found := strings.CutSuffix(pattern, "%")
+		if f.numeric || !ok || !found || prefix == "" || strings.ContainsAny(prefix, "%_") {
+			return "", unsupported
+		}
+		return "@" + f.alias + ":{" + escapeSearchTag(prefix) + "*}", nil
+	}
+
+	if !f.numeric {
+		return "", unsupported
+	}
+	switch c.Operator {
+	case OpGreaterThan, OpGreaterThanOrEqual, OpLessThan, OpLessThanOrEqual:
+		n, err := searchNumber(c.Value)
+		if err != nil {
+			return "", err
+		}
+		bound := map[ComparisonOperator]string{
+			OpGreaterThan:        "[(" + n + " +inf]",
+			OpGreaterThanOrEqual: "[" + n + " +inf]",
+			OpLessThan:           "[-inf (" + n + "]",
+			OpLessThanOrEqual:    "[-inf " + n + "]",
+		}[c.Operator]
+		return "@" + f.alias + ":" + bound, nil
+	case OpBetween:
+		bounds, ok := sliceElements(c.Value)
+		if !ok || len(bounds) != 2 {
+			return "", fmt.Errorf("BETWEEN needs two bounds, got %v", c.Value)
+		}
+		lo, err := searchNumber(bounds[0])
+		if err != nil {
+			return "", err
+		}
+		hi, err := searchNumber(bounds[1])
+		if err != nil {
+			return "", err
+		}
+		return "@" + f.alias + ":[" + lo + " " + hi + "]", nil
+	}
+	return "", unsupported
+}
+
+// kind names the type of the field in errors
+func (f searchField) kind() string {
+	if f.numeric {
+		return "numeric"
+	}
+	return "tag"
+}
+
+// match returns the query matching documents whose field equals value
+func (f searchField) match(value any) (string, error) {
+	if f.numeric {
+		n, err := searchNumber(value)
+		if err != nil {
+			return "", err
+		}
+		return "@" + f.alias + ":[" + n + " " + n + "]", nil
+	}
+
+	var tag string
+	switch v := value.(type) {
+	case time.Time:
+		// the text encoding/json writes for the UTC-normalized value
+		data, err := json.Marshal(v.UTC())
+		if err != nil {
+			return "", err
+		}
+		tag = strings.Trim(string(data), `"`)
+	default:
+		rv := reflect.ValueOf(value)
+		if !rv.IsValid() || rv.Kind() != reflect.String || rv.Len() == 0 {
+			return "", fmt.Errorf("%w: tag field %q needs a non-empty string, got %v", ErrUnsupportedOperation, f.alias, value)
+		}
+		tag = rv.String()
+	}
+	return "@" + f.alias + ":{" + escapeSearchTag(tag) + "}", nil
+}
+
+// searchNumber formats a numeric condition value for the query syntax
+func searchNumber(value any) (string, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	}
+	return "", fmt.Errorf("%w: numeric field needs a number, got %v", ErrUnsupportedOperation, value)
+}
+
+// escapeSearchTag escapes the characters of a tag value the query syntax
+// treats as separators or operators
+func escapeSearchTag(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// searchArgs returns the FT.SEARCH arguments of filter, without LIMIT
+func (idx *searchIndex) searchArgs(filter *Filter) ([]any, error) {
+	var conditions []Condition
+	if filter != nil {
+		if filter.Distinct {
+			return nil, fmt.Errorf("%w: DISTINCT with RediSearch", ErrUnsupportedOperation)
+		}
+		conditions = filter.Conditions
+	}
+	q, err := idx.query(conditions, LogicalAND)
+	if err != nil {
+		return nil, err
+	}
+	args := []any{"FT.SEARCH", idx.name, q}
+
+	if filter != nil && len(filter.Sort) > 0 {
+		sf := filter.Sort[0]
+		if len(filter.Sort) > 1 || sf.Field == RandomField || sf.Nulls != NullsDefault {
+			return nil, fmt.Errorf("%w: RediSearch sorts by one field, without NULL ordering", ErrUnsupportedOperation)
+		}
+		f, err := idx.field(sf.Field)
+		if err != nil {
+			return nil, err
+		}
+		dir := "ASC"
+		if sf.Direction == SortDesc {
+			dir = "DESC"
+		}
+		args = append(args, "SORTBY", f.alias, dir)
+	}
+	return args, nil
+}
+
+// searchQuery runs Query with FT.SEARCH, paging by SearchPageSize items
+// when filter has no limit
+func (r *RedisConnector[T, ID]) searchQuery(ctx context.Context, filter *Filter) ([]T, error) {
+	args, err := r.search.searchArgs(filter)
+	if err != nil {
+		return nil, err
+	}
+	offset, limit := 0, -1
+	if filter != nil && filter.Offset != nil && *filter.Offset > 0 {
+		offset = *filter.Offset
+	}
+	if filter != nil && filter.Limit != nil && *filter.Limit > 0 {
+		limit = *filter.Limit
+	}
+
+	results := []T{}
+	for {
+		size := SearchPageSize
+		if limit >= 0 {
+			size = min(size, limit-len(results))
+		}
+		page := append(args[:len(args):len(args)], "RETURN", 1, "$", "LIMIT", offset, size, "DIALECT", 2)
+		reply, err := r.client.Do(ctx, page...).Slice()
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %s: %w", r.search.name, err)
+		}
+		total, items, err := decodeSearchReply[T](reply)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, items...)
+		offset += len(items)
+		if len(items) < size || int64(offset) >= total || len(results) == limit {
+			return results, nil
+		}
+	}
+}
+
+// searchCount runs Count with FT.SEARCH
+func (r *RedisConnector[T, ID]) searchCount(ctx context.Context, filter *Filter) (int64, error) {
+	args, err := r.search.searchArgs(filter)
+	if err != nil {
+		return 0, err
+	}
+	reply, err := r.client.Do(ctx, append(args, "LIMIT", 0, 0, "DIALECT", 2)...).Slice()
+	if err != nil {
+		return 0, fmt.Errorf("failed to search %s: %w", r.search.name, err)
+	}
+	total, _, err := decodeSearchReply[T](reply)
+	return total, err
+}
+
+// decodeSearchReply decodes the total and the documents of an FT.SEARCH
+// reply: the total followed by each key and its ["$", document] pair
+func decodeSearchReply[T any](reply []any) (int64, []T, error) {
+	if len(reply) == 0 {
+		return 0, nil, fmt.Errorf("empty search reply")
+	}
+	total, ok := reply[0].(int64)
+	if !ok {
+		return 0, nil, fmt.Errorf("unexpected search reply total %v", reply[0])
+	}
+
+	items := make([]T, 0, len(reply)/2)
+	for i := 2; i < len(reply); i += 2 {
+		fields, ok := reply[i].([]any)
+		if !ok || len(fields) != 2 {
+			return 0, nil, fmt.Errorf("unexpected search reply fields of %v", reply[i-1])
+		}
+		doc, ok := fields[1].(string)
+		if !ok {
+			return 0, nil, fmt.Errorf("unexpected search reply document of %v", reply[i-1])
+		}
+		var item T
+		if err := json.Unmarshal([]byte(doc), &item); err != nil {
+			return 0, nil, fmt.Errorf("key %v: %w", reply[i-1], err)
+		}
+		items = append(items, item)
+	}
+	return total, items, nil
+}
@@ -0,0 +1,113 @@
+package sietch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type searchItem struct {
+	ID        int64     `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	Score     float64   `db:"score" json:"score"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	Tags      []string  `db:"tags" json:"tags"`
+	Secret    string    `db:"-"`
+}
+
+func TestSearchIndex_Query(t *testing.T) {
+	idx, err := newSearchIndex("items", reflect.TypeOf(searchItem{}))
+	if err != nil {
+		t.Fatalf("newSearchIndex failed: %v", err)
+	}
+	if got := len(idx.schema()); got != 4 {
+		t.Errorf("Expected 4 indexed fields, got %d", got)
+	}
+
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   string
+	}{
+		{"no conditions", NewFilter().Build(), "*"},
+		{"numeric equality", NewFilter().Where("id", OpEqual, 3).Build(), "@id:[3 3]"},
+		{"tag equality", NewFilter().Where("name", OpEqual, "a b").Build(), `@name:{a\ b}`},
+		{"Go field name", NewFilter().Where("Name", OpNotEqual, "x").Build(), "-@name:{x}"},
+		{"range", NewFilter().Where("score", OpGreaterThan, 1.5).Where("score", OpLessThanOrEqual, 3).Build(), "(@score:[(1.5 +inf] @score:[-inf 3])"},
+		{"between", NewFilter().Where("id", OpBetween, []any{1, 9}).Build(), "@id:[1 9]"},
+		{"in", NewFilter().Where("name", OpIn, []string{"a", "b"}).Build(), "(@name:{a} | @name:{b})"},
+		{"prefix", NewFilter().Where("name", OpLike, "ab-%").Build(), `@name:{ab\-*}`},
+		{"time", NewFilter().Where("created_at", OpEqual, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)).Build(), `@created_at:{2024\-01\-02T03\:04\:05Z}`},
+		{"or", NewFilter().Or(
+			Condition{Field: "id", Operator: OpEqual, Value: 1},
+			Condition{Field: "name", Operator: OpEqual, Value: "a"},
+		).Build(), "(@id:[1 1] | @name:{a})"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := idx.searchArgs(tt.filter)
+			if err != nil {
+				t.Fatalf("searchArgs failed: %v", err)
+			}
+			if args[2] != tt.want {
+				t.Errorf("Expected query %s, got %s", tt.want, args[2])
+			}
+		})
+	}
+
+	sorted, err := idx.searchArgs(NewFilter().OrderBy("score", SortDesc).Build())
+	if err != nil || len(sorted) != 6 || sorted[4] != "score" || sorted[5] != "DESC" {
+		t.Errorf("Expected SORTBY score DESC, got %v (%v)", sorted, err)
+	}
+
+	for name, filter := range map[string]*Filter{
+		"unindexed field":  NewFilter().Where("tags", OpEqual, "a").Build(),
+		"tag range":        NewFilter().Where("name", OpGreaterThan, "a").Build(),
+		"infix like":       NewFilter().Where("name", OpLike, "%a%").Build(),
+		"two sort fields":  NewFilter().OrderBy("id", SortAsc).OrderBy("score", SortAsc).Build(),
+		"distinct":         NewFilter().Distinct().Build(),
+		"string on number": NewFilter().Where("id", OpEqual, "3").Build(),
+	} {
+		if _, err := idx.searchArgs(filter); !errors.Is(err, ErrUnsupportedOperation) {
+			t.Errorf("%s: expected ErrUnsupportedOperation, got %v", name, err)
+		}
+	}
+}
+
+type searchCustomer struct {
+	baseEntity
+	Name    string  `db:"name" json:"name"`
+	Billing address `db:"billing_" json:"billing"`
+}
+
+func TestSearchIndex_EmbeddedFields(t *testing.T) {
+	idx, err := newSearchIndex("customers", reflect.TypeOf(searchCustomer{}))
+	if err != nil {
+		t.Fatalf("newSearchIndex failed: %v", err)
+	}
+	for name, path := range map[string]string{
+		"id":           "$.ID",
+		"CreatedAt":    "$.CreatedAt",
+		"name":         "$.name",
+		"billing_city": "$.billing.City",
+	} {
+		if f, err := idx.field(name); err != nil || f.path != path {
+			t.Errorf("Expected %s at %s, got %+v (%v)", name, path, f, err)
+		}
+	}
+}
+
+func TestDecodeSearchReply(t *testing.T) {
+	reply := []any{int64(5), "item:1", []any{"$", `{"id":1,"name":"a"}`}, "item:2", []any{"$", `{"id":2,"name":"b"}`}}
+	total, items, err := decodeSearchReply[searchItem](reply)
+	if err != nil {
+		t.Fatalf("decodeSearchReply failed: %v", err)
+	}
+	if total != 5 || len(items) != 2 || items[1].Name != "b" {
+		t.Errorf("Unexpected total %d and items %v", total, items)
+	}
+	if _, _, err := decodeSearchReply[searchItem]([]any{int64(1), "item:1", "bad"}); err == nil {
+		t.Error("Expected an error for a malformed reply")
+	}
+}
@@ -0,0 +1,165 @@
+package sietch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisStorage is how the Redis connector stores items
+type RedisStorage int
+
+const (
+	// RedisStorageString stores items as JSON encoded string values
+	RedisStorageString RedisStorage = iota
+
+	// RedisStorageJSON stores items as RedisJSON documents, which a
+	// RediSearch index can query, see CreateSearchIndex. It needs the
+	// RedisJSON module.
+	RedisStorageJSON
+
+	// RedisStorageHash stores items as hashes with one field per column,
+	// which UpdateFields can update individually. Numbers, booleans and
+	// strings are stored as text, other values as JSON; nil fields are left
+	// out.
+	RedisStorageHash
+)
+
+// SetStorage sets how items are stored, RedisStorageString by default.
+// Items stored another way can't be read: call it before the connector is
+// used.
+func (r *RedisConnector[T, ID]) SetStorage(storage RedisStorage) {
+	r.storage = storage
+}
+
+// encode encodes item for set: JSON, or the field/value pairs of its hash
+// with hash storage
+func (r *RedisConnector[T, ID]) encode(item *T) (any, error) {
+	if r.storage != RedisStorageHash {
+		return marshalNormalized(item)
+	}
+	normalized := *item
+	if err := DefaultConverters.normalizeStruct(&normalized); err != nil {
+		return nil, err
+	}
+	return hashCodecOf[T]().encode(&normalized)
+}
+
+// set queues the write of the value returned by encode under key in pipe,
+// expiring after the TTL of ctx. With hash storage pipe should be a
+// transaction, as the hash is deleted and written again.
+func (r *RedisConnector[T, ID]) set(ctx context.Context, pipe redis.Pipeliner, key string, value any) {
+	ttl := r.ttl(ctx)
+	switch r.storage {
+	case RedisStorageJSON:
+		pipe.Do(ctx, "JSON.SET", key, "$", value)
+	case RedisStorageHash:
+		pipe.Del(ctx, key)
+		if fields := value.([]any); len(fields) > 0 {
+			pipe.HSet(ctx, key, fields...)
+		}
+		if ttl > 0 {
+			pipe.Expire(ctx, key, ttl)
+		}
+		return
+	default:
+		pipe.Set(ctx, key, value, ttl)
+		return
+	}
+	if ttl > 0 {
+		pipe.Expire(ctx, key, ttl)
+	} else {
+		pipe.Persist(ctx, key) // like SET, drop the TTL of the replaced item
+	}
+}
+
+// redisConn is what the connector reads through: its client, or the
+// connection of a transaction
+type redisConn interface {
+	redis.Cmdable
+	Process(ctx context.Context, cmd redis.Cmder) error
+}
+
+// getItem reads the item stored under key through c, failing with
+// ErrItemNotFound if it doesn't exist
+func (r *RedisConnector[T, ID]) getItem(ctx context.Context, c redisConn, key string) (*T, error) {
+	var value any
+	var err error
+	switch r.storage {
+	case RedisStorageJSON:
+		cmd := redis.NewCmd(ctx, "JSON.GET", key)
+		_ = c.Process(ctx, cmd)
+		value, err = cmd.Text()
+	case RedisStorageHash:
+		var fields map[string]string
+		if fields, err = c.HGetAll(ctx, key).Result(); err == nil && len(fields) == 0 {
+			err = redis.Nil
+		}
+		value = fields
+	default:
+		value, err = c.Get(ctx, key).Result()
+	}
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrItemNotFound
+		}
+		return nil, err
+	}
+	return decodeValue[T](value)
+}
+
+// decodeValue decodes a value read by getItem or mget: a JSON document or
+// the fields of a hash
+func decodeValue[T any](value any) (*T, error) {
+	var item T
+	switch v := value.(type) {
+	case string:
+		if err := json.Unmarshal([]byte(v), &item); err != nil {
+			return nil, err
+		}
+	case map[string]string:
+		if err := hashCodecOf[T]().decode(v, &item); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unexpected value %T", value)
+	}
+	return &item, nil
+}
+
+// mgetCmd queues the read of keys in pipe, returning the values once it
+// ran, nil for missing keys
+func (r *RedisConnector[T, ID]) mgetCmd(ctx context.Context, pipe redis.Pipeliner, keys []string) func() []any {
+	switch r.storage {
+	case RedisStorageString:
+		cmd := pipe.MGet(ctx, keys...)
+		return cmd.Val
+	case RedisStorageHash:
+		cmds := make([]*redis.StringStringMapCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = pipe.HGetAll(ctx, key)
+		}
+		return func() []any {
+			values := make([]any, len(cmds))
+			for i, cmd := range cmds {
+				if fields := cmd.Val(); len(fields) > 0 {
+					values[i] = fields
+				}
+			}
+			return values
+		}
+	}
+	args := make([]any, 0, len(keys)+2)
+	args = append(args, "JSON.MGET")
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	cmd := pipe.Do(ctx, append(args, ".")...)
+	return func() []any {
+		values, _ := cmd.Slice()
+		return values
+	}
+}
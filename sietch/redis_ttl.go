@@ -0,0 +1,52 @@
+package sietch
+
+import (
+	"context"
+	"time"
+)
+
+// ttlKey is the context key type of the TTL of Redis writes
+type ttlKey struct{}
+
+// WithTTL returns a context whose Redis writes (Create, Update, Upsert and
+// their batch variants) expire items after ttl instead of the default TTL
+// of the connector, e.g. to keep pending sessions shorter than active ones.
+// Zero or negative never expires them.
+func WithTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, ttlKey{}, ttl)
+}
+
+// ttl returns the TTL of the writes made with ctx, zero to never expire
+func (r *RedisConnector[T, ID]) ttl(ctx context.Context) time.Duration {
+	if ttl, ok := ctx.Value(ttlKey{}).(time.Duration); ok {
+		return max(ttl, 0)
+	}
+	return r.defaultTTL
+}
+
+// Touch restarts the TTL of the item with the given ID at ttl, or removes
+// it if ttl is zero or negative so the item never expires. It returns
+// ErrItemNotFound if the item doesn't exist.
+func (r *RedisConnector[T, ID]) Touch(ctx context.Context, id ID, ttl time.Duration) error {
+	key, err := r.keyFunc(id)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		ok, err := r.client.Expire(ctx, key, ttl).Result()
+		if err == nil && !ok {
+			err = ErrItemNotFound
+		}
+		return err
+	}
+
+	// PERSIST also reports false for an item without TTL
+	if err := r.client.Persist(ctx, key).Err(); err != nil {
+		return err
+	}
+	exists, err := r.Exists(ctx, id)
+	if err == nil && !exists {
+		err = ErrItemNotFound
+	}
+	return err
+}
@@ -0,0 +1,279 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// WithTx runs fn in an optimistic transaction. The keys fn reads are
+// WATCHed and its writes are buffered, then applied at once with
+// MULTI/EXEC. If a key fn read was written by someone else meanwhile, the
+// writes are discarded and fn runs again, see SetTxRetryOptions; after the
+// last rerun WithTx fails with ErrTxConflict. If fn returns an error or
+// panics, nothing is written.
+//
+// Reads within fn see its buffered writes. Query, FindOne, Count,
+// UpdateWhere and DeleteWhere return ErrUnsupportedOperation. With index
+// sets, the keys fn writes are watched too.
+func (r *RedisConnector[T, ID]) WithTx(ctx context.Context, fn TxFunc[T, ID]) error {
+	return retryTx(ctx, r.txRetry.withTable(r.keyPrefix), func() error {
+		return r.client.Watch(ctx, func(tx *redis.Tx) error {
+			txRepo := &redisTx[T, ID]{connector: r, tx: tx, writes: make(map[string]redisWrite[T])}
+			if err := fn(txRepo); err != nil {
+				return err
+			}
+			return txRepo.commit(ctx)
+		})
+	})
+}
+
+// SetTxRetryOptions configures how WithTx, and writes maintaining index
+// sets, rerun transactions conflicting with concurrent writes,
+// DefaultTxMaxRetries times by default
+func (r *RedisConnector[T, ID]) SetTxRetryOptions(opts TxRetryOptions) {
+	r.txRetry = opts
+}
+
+// redisTx is the repository of a Redis transaction
+type redisTx[T any, ID comparable] struct {
+	connector *RedisConnector[T, ID]
+	tx        *redis.Tx
+	writes    map[string]redisWrite[T] // buffered, by key
+	keys      []string                 // keys of writes, in order
+}
+
+// redisWrite is a buffered write of a Redis transaction
+type redisWrite[T any] struct {
+	item *T  // nil for a delete
+	data any // see RedisConnector.encode
+	ttl  time.Duration
+}
+
+// watch WATCHes keys, so the transaction fails if they are written before
+// it commits
+func (t *redisTx[T, ID]) watch(ctx context.Context, keys ...string) error {
+	return t.tx.Watch(ctx, keys...).Err()
+}
+
+// buffer records a write of item under key, a delete if item is nil
+func (t *redisTx[T, ID]) buffer(ctx context.Context, key string, item *T) error {
+	w := redisWrite[T]{ttl: t.connector.ttl(ctx)}
+	if item != nil {
+		data, err := t.connector.encode(item)
+		if err != nil {
+			return err
+		}
+		copyValue := *item
+		w.item, w.data = &copyValue, data
+	}
+	if _, ok := t.writes[key]; !ok {
+		t.keys = append(t.keys, key)
+	}
+	t.writes[key] = w
+	return nil
+}
+
+// commit applies the buffered writes with MULTI/EXEC
+func (t *redisTx[T, ID]) commit(ctx context.Context) error {
+	if len(t.keys) == 0 {
+		return nil
+	}
+	r := t.connector
+
+	// Index sets move keys from the sets of the stored items
+	var stored map[string]*T
+	if len(r.indexes) > 0 {
+		if err := t.watch(ctx, t.keys...); err != nil {
+			return err
+		}
+		values, err := r.mgetOn(ctx, t.tx, t.keys)
+		if err != nil {
+			return err
+		}
+		if stored, err = decodeMany[T](t.keys, values); err != nil {
+			return err
+		}
+	}
+
+	_, err := t.tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range t.keys {
+			w := t.writes[key]
+			if w.item == nil {
+				pipe.Del(ctx, key)
+			} else {
+				r.set(WithTTL(ctx, w.ttl), pipe, key, w.data)
+			}
+			if len(r.indexes) > 0 {
+				r.moveIndexed(ctx, pipe, key, stored[key], w.item)
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, redis.TxFailedErr) {
+		return fmt.Errorf("%w: %w", ErrTxConflict, err)
+	}
+	return err
+}
+
+func (t *redisTx[T, ID]) Create(ctx context.Context, item *T) error {
+	if item == nil {
+		return errors.New("item cannot be nil")
+	}
+	key, err := t.connector.keyFunc(t.connector.getID(item))
+	if err != nil {
+		return err
+	}
+	return t.buffer(ctx, key, item)
+}
+
+func (t *redisTx[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	key, err := t.connector.keyFunc(id)
+	if err != nil {
+		return nil, err
+	}
+	if w, ok := t.writes[key]; ok {
+		if w.item == nil {
+			return nil, ErrItemNotFound
+		}
+		copyValue := *w.item
+		return &copyValue, nil
+	}
+
+	if err := t.watch(ctx, key); err != nil {
+		return nil, err
+	}
+	return t.connector.getItem(ctx, t.tx, key)
+}
+
+func (t *redisTx[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	results := make(map[ID]*T, len(ids))
+	var readIDs []ID
+	var keys []string
+	for _, id := range uniqueIDs(ids) {
+		key, err := t.connector.keyFunc(id)
+		if err != nil {
+			return nil, err
+		}
+		if w, ok := t.writes[key]; ok {
+			if w.item != nil {
+				copyValue := *w.item
+				results[id] = &copyValue
+			}
+			continue
+		}
+		readIDs, keys = append(readIDs, id), append(keys, key)
+	}
+	if len(keys) == 0 {
+		return results, nil
+	}
+
+	if err := t.watch(ctx, keys...); err != nil {
+		return nil, err
+	}
+	values, err := t.connector.mgetOn(ctx, t.tx, keys)
+	if err != nil {
+		return nil, err
+	}
+	read, err := decodeMany[T](readIDs, values)
+	if err != nil {
+		return nil, err
+	}
+	for id, item := range read {
+		results[id] = item
+	}
+	return results, nil
+}
+
+func (t *redisTx[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	key, err := t.connector.keyFunc(id)
+	if err != nil {
+		return false, err
+	}
+	if w, ok := t.writes[key]; ok {
+		return w.item != nil, nil
+	}
+	if err := t.watch(ctx, key); err != nil {
+		return false, err
+	}
+	n, err := t.tx.Exists(ctx, key).Result()
+	return n > 0, err
+}
+
+func (t *redisTx[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	for i := range items {
+		if err := t.Create(ctx, &items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *redisTx[T, ID]) Update(ctx context.Context, item *T) error {
+	return t.Create(ctx, item)
+}
+
+func (t *redisTx[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	return t.BatchCreate(ctx, items)
+}
+
+func (t *redisTx[T, ID]) Upsert(ctx context.Context, item *T) error {
+	return t.Create(ctx, item)
+}
+
+func (t *redisTx[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	return t.BatchCreate(ctx, items)
+}
+
+// Delete buffers the delete of the item, failing with ErrItemNotFound if it
+// doesn't exist; the check watches its key
+func (t *redisTx[T, ID]) Delete(ctx context.Context, id ID) error {
+	exists, err := t.Exists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrItemNotFound
+	}
+	key, err := t.connector.keyFunc(id)
+	if err != nil {
+		return err
+	}
+	return t.buffer(ctx, key, nil)
+}
+
+func (t *redisTx[T, ID]) BatchDelete(ctx context.Context, ids []ID) error {
+	for _, id := range ids {
+		key, err := t.connector.keyFunc(id)
+		if err != nil {
+			return err
+		}
+		if err := t.buffer(ctx, key, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *redisTx[T, ID]) Query(_ context.Context, _ *Filter) ([]T, error) {
+	return nil, fmt.Errorf("%w: Query in Redis transactions", ErrUnsupportedOperation)
+}
+
+func (t *redisTx[T, ID]) FindOne(_ context.Context, _ *Filter) (*T, error) {
+	return nil, fmt.Errorf("%w: FindOne in Redis transactions", ErrUnsupportedOperation)
+}
+
+func (t *redisTx[T, ID]) Count(_ context.Context, _ *Filter) (int64, error) {
+	return 0, fmt.Errorf("%w: Count in Redis transactions", ErrUnsupportedOperation)
+}
+
+func (t *redisTx[T, ID]) UpdateWhere(_ context.Context, _ *Filter, _ map[string]any) (int64, error) {
+	return 0, fmt.Errorf("%w: UpdateWhere in Redis transactions", ErrUnsupportedOperation)
+}
+
+func (t *redisTx[T, ID]) DeleteWhere(_ context.Context, _ *Filter) (int64, error) {
+	return 0, fmt.Errorf("%w: DeleteWhere in Redis transactions", ErrUnsupportedOperation)
+}
@@ -0,0 +1,278 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaStrategy selects the replica serving a read
+type ReplicaStrategy string
+
+const (
+	// RoundRobin spreads reads evenly over the replicas
+	RoundRobin ReplicaStrategy = "round_robin"
+	// LeastLag reads from the replica with the lowest replication lag
+	LeastLag ReplicaStrategy = "least_lag"
+)
+
+// DefaultLagInterval is how long measured replication lags are trusted
+const DefaultLagInterval = 5 * time.Second
+
+// ReadWriteOptions configures a ReadWriteSplitRepository
+type ReadWriteOptions struct {
+	// Strategy selects the replica of each read; RoundRobin if empty
+	Strategy ReplicaStrategy
+
+	// Lag measures the replication lag of the replica at index replica,
+	// e.g. with now() - pg_last_xact_replay_timestamp(). Required by
+	// LeastLag and MaxLag; replicas whose lag cannot be measured are skipped.
+	Lag func(ctx context.Context, replica int) (time.Duration, error)
+
+	// LagInterval is how often lags are measured, DefaultLagInterval if zero
+	LagInterval time.Duration
+
+	// MaxLag skips replicas lagging more; zero disables the limit. When
+	// every replica is skipped, reads go to the primary.
+	MaxLag time.Duration
+
+	// PrimaryReadsInTx sends reads to the primary while the context holds a
+	// TransactionManager transaction, so they see its writes
+	PrimaryReadsInTx bool
+}
+
+// primaryReadsKey is the context key type forcing reads to the primary
+type primaryReadsKey struct{}
+
+// WithPrimaryReads returns a context whose reads through a
+// ReadWriteSplitRepository go to the primary, e.g. to read a write back
+// before it reached the replicas
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsKey{}, true)
+}
+
+// ReadWriteSplitRepository sends writes to a primary repository and spreads
+// reads over replicas of it. Get, GetMany, Exists, Query, FindOne, Count and
+// GroupCount are reads; every other operation, GetForUpdate and WithTx
+// included, runs on the primary.
+//
+// Replicas lag behind the primary, so a read following a write may not see
+// it. Use WithPrimaryReads for reads that must, or PrimaryReadsInTx for the
+// reads within TransactionManager transactions.
+type ReadWriteSplitRepository[T any, ID comparable] struct {
+	primary  Repository[T, ID]
+	replicas []Repository[T, ID]
+	opts     ReadWriteOptions
+
+	next atomic.Uint64 // round robin counter
+
+	lagMu      sync.Mutex
+	lags       []time.Duration // last measured lags, math.MaxInt64 if unknown
+	measuredAt time.Time
+	measuring  bool
+}
+
+// NewReadWriteSplitRepository creates a repository writing to primary and
+// reading from replicas. Without replicas every operation uses the primary.
+func NewReadWriteSplitRepository[T any, ID comparable](primary Repository[T, ID], replicas []Repository[T, ID], opts ReadWriteOptions) (*ReadWriteSplitRepository[T, ID], error) {
+	if primary == nil {
+		return nil, fmt.Errorf("primary cannot be nil")
+	}
+	switch opts.Strategy {
+	case "":
+		opts.Strategy = RoundRobin
+	case RoundRobin, LeastLag:
+	default:
+		return nil, fmt.Errorf("unknown replica strategy %q", opts.Strategy)
+	}
+	if opts.Lag == nil && (opts.Strategy == LeastLag || opts.MaxLag > 0) {
+		return nil, fmt.Errorf("%s and MaxLag need a Lag function", LeastLag)
+	}
+	if opts.LagInterval <= 0 {
+		opts.LagInterval = DefaultLagInterval
+	}
+	return &ReadWriteSplitRepository[T, ID]{
+		primary:  primary,
+		replicas: replicas,
+		opts:     opts,
+	}, nil
+}
+
+// reader returns the repository serving the reads of ctx
+func (r *ReadWriteSplitRepository[T, ID]) reader(ctx context.Context) Repository[T, ID] {
+	if len(r.replicas) == 0 {
+		return r.primary
+	}
+	if forced, _ := ctx.Value(primaryReadsKey{}).(bool); forced {
+		return r.primary
+	}
+	if inTransaction(ctx) && r.opts.PrimaryReadsInTx {
+		return r.primary
+	}
+	if r.opts.Lag == nil {
+		return r.replicas[(r.next.Add(1)-1)%uint64(len(r.replicas))]
+	}
+
+	lags := r.currentLags(ctx)
+	var eligible []int
+	for i, lag := range lags {
+		if lag == math.MaxInt64 || (r.opts.MaxLag > 0 && lag > r.opts.MaxLag) {
+			continue
+		}
+		eligible = append(eligible, i)
+	}
+	if len(eligible) == 0 {
+		return r.primary
+	}
+	if r.opts.Strategy == RoundRobin {
+		return r.replicas[eligible[(r.next.Add(1)-1)%uint64(len(eligible))]]
+	}
+	best := eligible[0]
+	for _, i := range eligible[1:] {
+		if lags[i] < lags[best] {
+			best = i
+		}
+	}
+	return r.replicas[best]
+}
+
+// currentLags returns the replica lags, measuring them if they are older
+// than the lag interval. One read measures while the others use the
+// previous lags; only the first measurement makes reads wait.
+func (r *ReadWriteSplitRepository[T, ID]) currentLags(ctx context.Context) []time.Duration {
+	r.lagMu.Lock()
+	if r.lags == nil {
+		defer r.lagMu.Unlock()
+		r.lags, r.measuredAt = r.measureLags(ctx), time.Now()
+		return r.lags
+	}
+	lags := r.lags
+	if r.measuring || time.Since(r.measuredAt) < r.opts.LagInterval {
+		r.lagMu.Unlock()
+		return lags
+	}
+	r.measuring = true
+	r.lagMu.Unlock()
+
+	lags = r.measureLags(ctx)
+
+	r.lagMu.Lock()
+	defer r.lagMu.Unlock()
+	r.lags, r.measuredAt, r.measuring = lags, time.Now(), false
+	return lags
+}
+
+// measureLags measures the lag of every replica, math.MaxInt64 if it fails
+func (r *ReadWriteSplitRepository[T, ID]) measureLags(ctx context.Context) []time.Duration {
+	lags := make([]time.Duration, len(r.replicas))
+	for i := range r.replicas {
+		lag, err := r.opts.Lag(ctx, i)
+		if err != nil {
+			lag = math.MaxInt64
+		}
+		lags[i] = lag
+	}
+	return lags
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) Create(ctx context.Context, item *T) error {
+	return r.primary.Create(ctx, item)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	return r.reader(ctx).Get(ctx, id)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	return r.reader(ctx).GetMany(ctx, ids)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	return r.reader(ctx).Exists(ctx, id)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	return r.primary.BatchCreate(ctx, items)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
+	if filter != nil && filter.Lock != LockNone {
+		// row locks need the primary
+		return r.primary.Query(ctx, filter)
+	}
+	return r.reader(ctx).Query(ctx, filter)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	if filter != nil && filter.Lock != LockNone {
+		return r.primary.FindOne(ctx, filter)
+	}
+	return r.reader(ctx).FindOne(ctx, filter)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
+	return r.reader(ctx).Count(ctx, filter)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) Update(ctx context.Context, item *T) error {
+	return r.primary.Update(ctx, item)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	return r.primary.BatchUpdate(ctx, items)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	return r.primary.Delete(ctx, id)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) BatchDelete(ctx context.Context, ids []ID) error {
+	return r.primary.BatchDelete(ctx, ids)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	return r.primary.Upsert(ctx, item)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	return r.primary.BatchUpsert(ctx, items)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	return r.primary.UpdateWhere(ctx, filter, updates)
+}
+
+func (r *ReadWriteSplitRepository[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	return r.primary.DeleteWhere(ctx, filter)
+}
+
+// GroupCount reads from a replica if it implements Grouper
+func (r *ReadWriteSplitRepository[T, ID]) GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error) {
+	grouper, ok := r.reader(ctx).(Grouper)
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	return grouper.GroupCount(ctx, filter)
+}
+
+// GetForUpdate locks the row on the primary if it implements RowLocker
+func (r *ReadWriteSplitRepository[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
+	locker, ok := r.primary.(RowLocker[T, ID])
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	return locker.GetForUpdate(ctx, id)
+}
+
+// WithTx runs fn in a transaction of the primary, which must implement
+// Transactional; every operation of fn, reads included, uses the primary
+func (r *ReadWriteSplitRepository[T, ID]) WithTx(ctx context.Context, fn TxFunc[T, ID]) error {
+	txRepo, ok := r.primary.(Transactional[T, ID])
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	return txRepo.WithTx(ctx, fn)
+}
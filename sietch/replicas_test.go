@@ -0,0 +1,120 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestReadWriteSplitRepository(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+
+	// each node holds one account whose balance names the node
+	newNodes := func() (Repository[testutils.Account, int64], []Repository[testutils.Account, int64]) {
+		nodes := make([]Repository[testutils.Account, int64], 3)
+		for i := range nodes {
+			nodes[i] = NewInMemoryConnector[testutils.Account](getID)
+			_ = nodes[i].Create(ctx, &testutils.Account{ID: 1, Balance: i})
+		}
+		return nodes[0], nodes[1:]
+	}
+	readFrom := func(repo Repository[testutils.Account, int64], ctx context.Context) int {
+		item, err := repo.Get(ctx, 1)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		return item.Balance
+	}
+
+	t.Run("Round robin reads, primary writes", func(t *testing.T) {
+		primary, replicas := newNodes()
+		repo, err := NewReadWriteSplitRepository(primary, replicas, ReadWriteOptions{})
+		if err != nil {
+			t.Fatalf("NewReadWriteSplitRepository failed: %v", err)
+		}
+
+		if a, b, c := readFrom(repo, ctx), readFrom(repo, ctx), readFrom(repo, ctx); a != 1 || b != 2 || c != 1 {
+			t.Errorf("Expected reads to alternate between replicas, got %d %d %d", a, b, c)
+		}
+		if got := readFrom(repo, WithPrimaryReads(ctx)); got != 0 {
+			t.Errorf("Expected WithPrimaryReads to read the primary, got node %d", got)
+		}
+
+		_ = repo.Create(ctx, &testutils.Account{ID: 2})
+		if exists, _ := primary.Exists(ctx, 2); !exists {
+			t.Error("Expected the write on the primary")
+		}
+		if exists, _ := replicas[0].Exists(ctx, 2); exists {
+			t.Error("Expected no write on the replicas")
+		}
+
+		_ = repo.WithTx(ctx, func(tx Repository[testutils.Account, int64]) error {
+			if got := readFrom(tx, ctx); got != 0 {
+				t.Errorf("Expected transactions to read the primary, got node %d", got)
+			}
+			return nil
+		})
+		if got := readFrom(repo, context.WithValue(ctx, txKey{}, &recordingTx{})); got == 0 {
+			t.Error("Expected TransactionManager reads on a replica by default")
+		}
+	})
+
+	t.Run("Transaction reads on the primary", func(t *testing.T) {
+		primary, replicas := newNodes()
+		repo, _ := NewReadWriteSplitRepository(primary, replicas, ReadWriteOptions{PrimaryReadsInTx: true})
+		if got := readFrom(repo, context.WithValue(ctx, txKey{}, &recordingTx{})); got != 0 {
+			t.Errorf("Expected the primary, got node %d", got)
+		}
+	})
+
+	t.Run("Least lag", func(t *testing.T) {
+		primary, replicas := newNodes()
+		lags := []time.Duration{time.Second, 10 * time.Millisecond}
+		var measurements int
+		repo, err := NewReadWriteSplitRepository(primary, replicas, ReadWriteOptions{
+			Strategy: LeastLag,
+			MaxLag:   500 * time.Millisecond,
+			Lag: func(_ context.Context, replica int) (time.Duration, error) {
+				measurements++
+				if lags[replica] < 0 {
+					return 0, errors.New("replica down")
+				}
+				return lags[replica], nil
+			},
+			LagInterval: time.Hour,
+		})
+		if err != nil {
+			t.Fatalf("NewReadWriteSplitRepository failed: %v", err)
+		}
+
+		if a, b := readFrom(repo, ctx), readFrom(repo, ctx); a != 2 || b != 2 {
+			t.Errorf("Expected the least lagging replica, got %d %d", a, b)
+		}
+		if measurements != 2 {
+			t.Errorf("Expected lags to be measured once per replica, got %d", measurements)
+		}
+
+		lags[1] = -1
+		repo.measuredAt = time.Time{}
+		if got := readFrom(repo, ctx); got != 0 {
+			t.Errorf("Expected the primary when no replica qualifies, got node %d", got)
+		}
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		primary, replicas := newNodes()
+		if _, err := NewReadWriteSplitRepository(primary, replicas, ReadWriteOptions{Strategy: LeastLag}); err == nil {
+			t.Error("Expected LeastLag without a Lag function to fail")
+		}
+		if _, err := NewReadWriteSplitRepository(primary, replicas, ReadWriteOptions{Strategy: "random"}); err == nil {
+			t.Error("Expected an unknown strategy to fail")
+		}
+		if _, err := NewReadWriteSplitRepository(nil, replicas, ReadWriteOptions{}); err == nil {
+			t.Error("Expected a nil primary to fail")
+		}
+	})
+}
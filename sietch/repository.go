@@ -1,6 +1,9 @@
 package sietch
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 // Repository defines a generic contract for CRUD operations
 // T represents the entity type and ID the identifier type
@@ -15,6 +18,15 @@ type Repository[T any, ID comparable] interface {
 	BatchDelete(ctx context.Context, items []ID) error
 	Count(ctx context.Context, filter *Filter) (int64, error)
 
+	// FindOne returns the first entity matching the filter, or ErrItemNotFound.
+	// The filter is applied with LIMIT 1; set Sort to choose which entity wins
+	// when several match.
+	FindOne(ctx context.Context, filter *Filter) (*T, error)
+
+	// GetMany fetches several entities by ID in one round trip.
+	// IDs that do not exist are absent from the returned map.
+	GetMany(ctx context.Context, ids []ID) (map[ID]*T, error)
+
 	// Exists checks if an entity with the given ID exists
 	Exists(ctx context.Context, id ID) (bool, error)
 
@@ -23,6 +35,14 @@ type Repository[T any, ID comparable] interface {
 
 	// BatchUpsert creates or updates multiple entities
 	BatchUpsert(ctx context.Context, items []T) error
+
+	// UpdateWhere sets the given fields (by db tag) on every entity matching
+	// the filter conditions and returns the number of entities updated
+	UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error)
+
+	// DeleteWhere deletes every entity matching the filter conditions and
+	// returns the number of entities deleted
+	DeleteWhere(ctx context.Context, filter *Filter) (int64, error)
 }
 
 // TxFunc is a function that operates within a transaction context
@@ -38,3 +58,86 @@ type Transactional[T any, ID comparable] interface {
 	// If the function panics, the transaction is rolled back and the panic is re-raised.
 	WithTx(ctx context.Context, fn TxFunc[T, ID]) error
 }
+
+// GroupCount is the number of rows in one group of a grouped query
+type GroupCount struct {
+	Key   map[string]any // Grouped field -> value
+	Count int64
+}
+
+// Grouper defines an optional interface for count-by-dimension queries.
+// The filter must set GroupBy; Having, Sort, Limit and Offset apply to groups.
+//
+//	filter := sietch.NewFilter().
+//	    GroupBy("status").
+//	    Having(sietch.Condition{Field: sietch.CountField, Operator: sietch.OpGreaterThan, Value: 10}).
+//	    OrderBy(sietch.CountField, sietch.SortDesc).
+//	    Build()
+//	if g, ok := repo.(sietch.Grouper); ok { counts, err := g.GroupCount(ctx, filter) }
+type Grouper interface {
+	GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error)
+}
+
+// RowLocker defines an optional interface for pessimistic row locking.
+// GetForUpdate reads an entity and locks its row until the end of the
+// transaction, so concurrent writers and lockers wait for it to finish:
+//
+//	err := txRepo.WithTx(ctx, func(tx sietch.Repository[Account, int64]) error {
+//	    acc, err := tx.(sietch.RowLocker[Account, int64]).GetForUpdate(ctx, 1)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    acc.Balance -= 100
+//	    return tx.Update(ctx, acc)
+//	})
+//
+// Outside a transaction the lock is released as soon as the statement ends.
+type RowLocker[T any, ID comparable] interface {
+	GetForUpdate(ctx context.Context, id ID) (*T, error)
+}
+
+// SoftDeleter defines an optional interface for repositories of SoftDeletable
+// entities. Their Delete marks an entity as deleted instead of removing it
+// and their reads skip deleted entities; WithDeleted returns a view of the
+// repository whose reads include them:
+//
+//	if sd, ok := repo.(sietch.SoftDeleter[User, string]); ok {
+//	    user, err := sd.WithDeleted().Get(ctx, id)
+//	    ...
+//	    err = sd.Restore(ctx, id)
+//	}
+type SoftDeleter[T any, ID comparable] interface {
+	WithDeleted() Repository[T, ID]
+
+	// Restore clears the deleted flags of a soft-deleted entity. It fails
+	// with ErrNoUpdateItem if no deleted entity has the ID.
+	Restore(ctx context.Context, id ID) error
+}
+
+// Clearable defines an optional interface for repositories that can remove
+// every entity at once, such as caches:
+//
+//	if c, ok := cache.(sietch.Clearable); ok { err = c.Clear(ctx) }
+type Clearable interface {
+	Clear(ctx context.Context) error
+}
+
+// findOne runs query with a copy of filter limited to one result
+func findOne[T any](ctx context.Context, filter *Filter, query func(context.Context, *Filter) ([]T, error)) (*T, error) {
+	if filter == nil {
+		return nil, fmt.Errorf("filter cannot be nil")
+	}
+
+	limited := *filter
+	one := 1
+	limited.Limit = &one
+
+	results, err := query(ctx, &limited)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrItemNotFound
+	}
+	return &results[0], nil
+}
@@ -0,0 +1,354 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// DefaultRetryAttempts is the number of attempts of an operation when
+	// RetryConfig.MaxAttempts is zero, the first one included
+	DefaultRetryAttempts = 3
+	// DefaultRetryBackoff is the delay before the first retry when
+	// RetryConfig.Backoff is nil; it doubles with every retry
+	DefaultRetryBackoff = 50 * time.Millisecond
+	// DefaultMaxRetryBackoff caps the delay of the default backoff
+	DefaultMaxRetryBackoff = 2 * time.Second
+)
+
+// SQLSTATE codes of transient failures
+const (
+	pgCodeSerializationFailure = "40001"
+	pgCodeDeadlockDetected     = "40P01"
+	pgCodeTooManyConnections   = "53300"
+	pgCodeAdminShutdown        = "57P01"
+	pgCodeCrashShutdown        = "57P02"
+	pgCodeCannotConnectNow     = "57P03"
+)
+
+// Backoff computes the delay before a retry, 0 for the first one. The
+// strategies of github.com/seb7887/gofw/httpx/backoff implement it.
+type Backoff interface {
+	Next(retry int) time.Duration
+}
+
+// RetryConfig configures a RetryingRepository
+type RetryConfig struct {
+	// MaxAttempts bounds the attempts of an operation, the first one
+	// included. DefaultRetryAttempts if zero.
+	MaxAttempts int
+
+	// Backoff computes the delay between attempts. By default it doubles
+	// from DefaultRetryBackoff up to DefaultMaxRetryBackoff, with jitter.
+	Backoff Backoff
+
+	// IsTransient reports whether an error may go away on retry,
+	// IsTransientError if nil
+	IsTransient func(err error) bool
+
+	// RetryCreates retries Create and BatchCreate on every transient error.
+	// By default they are only retried when the error guarantees nothing
+	// was written, as a create retried after an ambiguous failure, such as
+	// a connection reset, may insert twice or fail as already existing.
+	RetryCreates bool
+
+	// OnRetry is called with the operation, the failed attempt (from 1) and
+	// its error before every retry
+	OnRetry func(op string, attempt int, err error)
+}
+
+// IsTransientError reports whether err is a failure of the backend expected
+// to go away on retry: broken or refused connections, too many connections,
+// server shutdowns, deadlocks and serialization failures, and injected
+// faults. Cancelled and expired contexts are not transient.
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrInjectedFault) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgCodeSerializationFailure, pgCodeDeadlockDetected, pgCodeTooManyConnections,
+			pgCodeAdminShutdown, pgCodeCrashShutdown, pgCodeCannotConnectNow:
+			return true
+		}
+		return strings.HasPrefix(pgErr.Code, "08") // connection exception
+	}
+
+	if pgconn.SafeToRetry(err) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isUnappliedError reports whether err guarantees the failed statement
+// changed nothing: it was never sent, or the server rejected or rolled it
+// back. Injected faults never reach the backend.
+func isUnappliedError(err error) bool {
+	if errors.Is(err, ErrInjectedFault) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgCodeSerializationFailure, pgCodeDeadlockDetected, pgCodeTooManyConnections, pgCodeCannotConnectNow:
+			return true
+		}
+		return false
+	}
+	var opErr *net.OpError
+	return pgconn.SafeToRetry(err) || errors.Is(err, syscall.ECONNREFUSED) ||
+		(errors.As(err, &opErr) && opErr.Op == "dial")
+}
+
+// exponentialBackoff is the default Backoff of RetryConfig
+type exponentialBackoff struct{}
+
+// Next returns a delay between half and all of DefaultRetryBackoff doubled
+// retry times
+func (exponentialBackoff) Next(retry int) time.Duration {
+	delay := DefaultMaxRetryBackoff
+	if retry < 16 && DefaultRetryBackoff<<retry < delay {
+		delay = DefaultRetryBackoff << retry
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// RetryingRepository retries the operations of a repository failing with
+// transient errors, such as connection resets, too many connections or
+// deadlocks, waiting between attempts as configured by RetryConfig.
+//
+// Reads and Update, Upsert, Delete, UpdateWhere and DeleteWhere are retried
+// on every transient error, as repeating them leaves the same state. A
+// retried Delete whose failed attempt deleted the item succeeds, but a
+// retried versioned Update whose failed attempt was applied fails with
+// ErrVersionConflict. Creates are retried only when nothing was written,
+// unless RetryConfig.RetryCreates is set.
+//
+// GetForUpdate, WithTx and operations within a TransactionManager or
+// TxCoordinator transaction are not retried: a failed transaction must be rerun as a whole,
+// and the repository passed to the function of WithTx is the base's.
+type RetryingRepository[T any, ID comparable] struct {
+	base Repository[T, ID]
+	cfg  RetryConfig
+}
+
+// NewRetryingRepository creates a repository retrying the transient failures
+// of base
+func NewRetryingRepository[T any, ID comparable](base Repository[T, ID], cfg RetryConfig) (*RetryingRepository[T, ID], error) {
+	if base == nil {
+		return nil, fmt.Errorf("base repository cannot be nil")
+	}
+	if cfg.MaxAttempts < 0 {
+		return nil, fmt.Errorf("max attempts cannot be negative")
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = DefaultRetryAttempts
+	}
+	if cfg.Backoff == nil {
+		cfg.Backoff = exponentialBackoff{}
+	}
+	if cfg.IsTransient == nil {
+		cfg.IsTransient = IsTransientError
+	}
+	return &RetryingRepository[T, ID]{base: base, cfg: cfg}, nil
+}
+
+// retryable reports whether an attempt of an operation failing with err is
+// retried. Statements of a transaction in ctx are not: it is aborted.
+func (r *RetryingRepository[T, ID]) retryable(ctx context.Context, err error, idempotent bool) bool {
+	if ctx.Err() != nil || !r.cfg.IsTransient(err) {
+		return false
+	}
+	if inTransaction(ctx) {
+		return false
+	}
+	return idempotent || isUnappliedError(err)
+}
+
+// retry runs fn until it succeeds, fails with an error that is not retried
+// or runs out of attempts, returning its last result
+func retry[R any, T any, ID comparable](ctx context.Context, r *RetryingRepository[T, ID], op string, idempotent bool, fn func() (R, error)) (R, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil || attempt >= r.cfg.MaxAttempts || !r.retryable(ctx, err, idempotent) {
+			return result, err
+		}
+		if r.cfg.OnRetry != nil {
+			r.cfg.OnRetry(op, attempt, err)
+		}
+
+		timer := time.NewTimer(r.cfg.Backoff.Next(attempt - 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryWrite is retry for operations returning only an error
+func (r *RetryingRepository[T, ID]) retryWrite(ctx context.Context, op string, idempotent bool, fn func() error) error {
+	_, err := retry(ctx, r, op, idempotent, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// retryDelete is retryWrite for deletes, which succeed when a retry finds
+// the items gone after a failed attempt: that attempt may have deleted them
+func (r *RetryingRepository[T, ID]) retryDelete(ctx context.Context, op string, fn func() error) error {
+	failed := false
+	return r.retryWrite(ctx, op, true, func() error {
+		err := fn()
+		if failed && isMissingError(err) {
+			return nil
+		}
+		failed = err != nil
+		return err
+	})
+}
+
+func (r *RetryingRepository[T, ID]) Create(ctx context.Context, item *T) error {
+	return r.retryWrite(ctx, "Create", r.cfg.RetryCreates, func() error {
+		return r.base.Create(ctx, item)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	return retry(ctx, r, "Get", true, func() (*T, error) {
+		return r.base.Get(ctx, id)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	return retry(ctx, r, "GetMany", true, func() (map[ID]*T, error) {
+		return r.base.GetMany(ctx, ids)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	return retry(ctx, r, "Exists", true, func() (bool, error) {
+		return r.base.Exists(ctx, id)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	return r.retryWrite(ctx, "BatchCreate", r.cfg.RetryCreates, func() error {
+		return r.base.BatchCreate(ctx, items)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
+	return retry(ctx, r, "Query", true, func() ([]T, error) {
+		return r.base.Query(ctx, filter)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	return retry(ctx, r, "FindOne", true, func() (*T, error) {
+		return r.base.FindOne(ctx, filter)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
+	return retry(ctx, r, "Count", true, func() (int64, error) {
+		return r.base.Count(ctx, filter)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) Update(ctx context.Context, item *T) error {
+	return r.retryWrite(ctx, "Update", true, func() error {
+		return r.base.Update(ctx, item)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	return r.retryWrite(ctx, "BatchUpdate", true, func() error {
+		return r.base.BatchUpdate(ctx, items)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	return r.retryDelete(ctx, "Delete", func() error {
+		return r.base.Delete(ctx, id)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) BatchDelete(ctx context.Context, ids []ID) error {
+	return r.retryDelete(ctx, "BatchDelete", func() error {
+		return r.base.BatchDelete(ctx, ids)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	return r.retryWrite(ctx, "Upsert", true, func() error {
+		return r.base.Upsert(ctx, item)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	return r.retryWrite(ctx, "BatchUpsert", true, func() error {
+		return r.base.BatchUpsert(ctx, items)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	return retry(ctx, r, "UpdateWhere", true, func() (int64, error) {
+		return r.base.UpdateWhere(ctx, filter, updates)
+	})
+}
+
+func (r *RetryingRepository[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	return retry(ctx, r, "DeleteWhere", true, func() (int64, error) {
+		return r.base.DeleteWhere(ctx, filter)
+	})
+}
+
+// GroupCount groups with the base repository, which must implement Grouper
+func (r *RetryingRepository[T, ID]) GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error) {
+	grouper, ok := r.base.(Grouper)
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	return retry(ctx, r, "GroupCount", true, func() ([]GroupCount, error) {
+		return grouper.GroupCount(ctx, filter)
+	})
+}
+
+// GetForUpdate locks the row with the base repository if it implements
+// RowLocker, without retries
+func (r *RetryingRepository[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
+	locker, ok := r.base.(RowLocker[T, ID])
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	return locker.GetForUpdate(ctx, id)
+}
+
+// WithTx runs fn in a transaction of the base repository, which must
+// implement Transactional, without retries
+func (r *RetryingRepository[T, ID]) WithTx(ctx context.Context, fn TxFunc[T, ID]) error {
+	txRepo, ok := r.base.(Transactional[T, ID])
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	return txRepo.WithTx(ctx, fn)
+}
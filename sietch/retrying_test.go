@@ -0,0 +1,164 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+// transientRepository fails the operations it overrides with errs, in order,
+// applying writes before failing when applied is set
+type transientRepository struct {
+	Repository[testutils.Account, int64]
+	errs    []error
+	applied bool
+	calls   int
+}
+
+func (r *transientRepository) fail(apply func() error) error {
+	r.calls++
+	if len(r.errs) == 0 {
+		return apply()
+	}
+	err := r.errs[0]
+	r.errs = r.errs[1:]
+	if r.applied {
+		_ = apply()
+	}
+	return err
+}
+
+func (r *transientRepository) Get(ctx context.Context, id int64) (*testutils.Account, error) {
+	var item *testutils.Account
+	err := r.fail(func() (err error) {
+		item, err = r.Repository.Get(ctx, id)
+		return err
+	})
+	return item, err
+}
+
+func (r *transientRepository) Create(ctx context.Context, item *testutils.Account) error {
+	return r.fail(func() error { return r.Repository.Create(ctx, item) })
+}
+
+func (r *transientRepository) Delete(ctx context.Context, id int64) error {
+	return r.fail(func() error { return r.Repository.Delete(ctx, id) })
+}
+
+// noBackoff retries immediately
+type noBackoff struct{}
+
+func (noBackoff) Next(int) time.Duration { return 0 }
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{ErrItemNotFound, false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+		{errors.New("boom"), false},
+		{&pgconn.PgError{Code: pgCodeUniqueViolation}, false},
+		{&pgconn.PgError{Code: pgCodeSerializationFailure}, true},
+		{&pgconn.PgError{Code: pgCodeDeadlockDetected}, true},
+		{&pgconn.PgError{Code: pgCodeTooManyConnections}, true},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{fmt.Errorf("write: %w", syscall.ECONNRESET), true},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{fmt.Errorf("get: %w", ErrInjectedFault), true},
+	}
+	for _, tt := range tests {
+		if got := IsTransientError(tt.err); got != tt.want {
+			t.Errorf("IsTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryingRepository(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	newRepos := func(cfg RetryConfig, errs ...error) (*RetryingRepository[testutils.Account, int64], *transientRepository) {
+		base := &transientRepository{Repository: NewInMemoryConnector[testutils.Account](getID), errs: errs}
+		_ = base.Repository.Create(ctx, &testutils.Account{ID: 1, Balance: 10})
+		cfg.Backoff = noBackoff{}
+		repo, err := NewRetryingRepository[testutils.Account, int64](base, cfg)
+		if err != nil {
+			t.Fatalf("NewRetryingRepository failed: %v", err)
+		}
+		return repo, base
+	}
+	reset := fmt.Errorf("read: %w", syscall.ECONNRESET)
+
+	t.Run("Reads are retried", func(t *testing.T) {
+		var retries []int
+		repo, base := newRepos(RetryConfig{OnRetry: func(_ string, attempt int, _ error) { retries = append(retries, attempt) }}, reset, reset)
+		if got, err := repo.Get(ctx, 1); err != nil || got.Balance != 10 {
+			t.Fatalf("Expected the item after retries, got %+v (%v)", got, err)
+		}
+		if base.calls != 3 || len(retries) != 2 || retries[1] != 2 {
+			t.Errorf("Expected 3 attempts, got %d calls and retries %v", base.calls, retries)
+		}
+	})
+
+	t.Run("Attempts are bounded", func(t *testing.T) {
+		repo, base := newRepos(RetryConfig{MaxAttempts: 2}, reset, reset, reset)
+		if _, err := repo.Get(ctx, 1); !errors.Is(err, syscall.ECONNRESET) {
+			t.Errorf("Expected the last error, got %v", err)
+		}
+		if base.calls != 2 {
+			t.Errorf("Expected 2 attempts, got %d", base.calls)
+		}
+	})
+
+	t.Run("Permanent errors are not retried", func(t *testing.T) {
+		repo, base := newRepos(RetryConfig{})
+		if _, err := repo.Get(ctx, 42); !errors.Is(err, ErrItemNotFound) || base.calls != 1 {
+			t.Errorf("Expected one attempt failing with ErrItemNotFound, got %d (%v)", base.calls, err)
+		}
+	})
+
+	t.Run("Creates are retried only when unapplied", func(t *testing.T) {
+		repo, base := newRepos(RetryConfig{}, reset)
+		if err := repo.Create(ctx, &testutils.Account{ID: 2}); !errors.Is(err, syscall.ECONNRESET) || base.calls != 1 {
+			t.Errorf("Expected no retry after a reset, got %d (%v)", base.calls, err)
+		}
+
+		repo, base = newRepos(RetryConfig{}, &pgconn.PgError{Code: pgCodeSerializationFailure})
+		if err := repo.Create(ctx, &testutils.Account{ID: 2}); err != nil || base.calls != 2 {
+			t.Errorf("Expected a retry after a serialization failure, got %d (%v)", base.calls, err)
+		}
+
+		repo, base = newRepos(RetryConfig{RetryCreates: true}, reset)
+		if err := repo.Create(ctx, &testutils.Account{ID: 2}); err != nil || base.calls != 2 {
+			t.Errorf("Expected a retry with RetryCreates, got %d (%v)", base.calls, err)
+		}
+	})
+
+	t.Run("Retried deletes of deleted items succeed", func(t *testing.T) {
+		repo, base := newRepos(RetryConfig{}, reset)
+		base.applied = true
+		if err := repo.Delete(ctx, 1); err != nil || base.calls != 2 {
+			t.Errorf("Expected the retried delete to succeed, got %d (%v)", base.calls, err)
+		}
+		if err := repo.Delete(ctx, 1); !errors.Is(err, ErrItemNotFound) {
+			t.Errorf("Expected ErrItemNotFound without a failed attempt, got %v", err)
+		}
+	})
+
+	t.Run("Cancelled contexts stop retries", func(t *testing.T) {
+		repo, base := newRepos(RetryConfig{}, reset, reset)
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := repo.Get(ctx, 1); err == nil || base.calls != 1 {
+			t.Errorf("Expected one attempt, got %d (%v)", base.calls, err)
+		}
+	})
+}
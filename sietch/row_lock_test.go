@@ -0,0 +1,57 @@
+package sietch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestFilterLock(t *testing.T) {
+	conn := newBatchConnector(t)
+
+	tests := map[LockMode]string{
+		LockNone:      `SELECT "id", "balance" FROM "accounts" WHERE "balance" > $1 LIMIT 10`,
+		LockForUpdate: `SELECT "id", "balance" FROM "accounts" WHERE "balance" > $1 LIMIT 10 FOR UPDATE`,
+		LockForShare:  `SELECT "id", "balance" FROM "accounts" WHERE "balance" > $1 LIMIT 10 FOR SHARE`,
+	}
+	for mode, expected := range tests {
+		filter := NewFilter().Where("balance", OpGreaterThan, 0).Limit(10).Lock(mode).Build()
+		query, _, err := conn.queryBuilder(filter)
+		if err != nil || query != expected {
+			t.Errorf("Lock %q: unexpected query %s (%v)", mode, query, err)
+		}
+	}
+
+	if _, _, err := conn.queryBuilder(NewFilter().Lock("FOR NOTHING").Build()); err == nil {
+		t.Error("Expected an error for an unknown lock mode")
+	}
+}
+
+func TestRowLocker(t *testing.T) {
+	var _ RowLocker[testutils.Account, int64] = (*CockroachDBConnector[testutils.Account, int64])(nil)
+	var _ RowLocker[testutils.Account, int64] = (*cockroachDBTx[testutils.Account, int64])(nil)
+
+	ctx := context.Background()
+	repo := NewInMemoryConnector[testutils.Account](func(a *testutils.Account) int64 { return a.ID })
+	_ = repo.Create(ctx, &testutils.Account{ID: 1, Balance: 10})
+
+	err := repo.WithTx(ctx, func(tx Repository[testutils.Account, int64]) error {
+		locker, ok := tx.(RowLocker[testutils.Account, int64])
+		if !ok {
+			t.Fatal("Expected the transaction to support GetForUpdate")
+		}
+		acc, err := locker.GetForUpdate(ctx, 1)
+		if err != nil {
+			return err
+		}
+		acc.Balance -= 5
+		return tx.Update(ctx, acc)
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+	if acc, _ := repo.Get(ctx, 1); acc.Balance != 5 {
+		t.Errorf("Expected balance 5, got %d", acc.Balance)
+	}
+}
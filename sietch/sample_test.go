@@ -0,0 +1,133 @@
+package sietch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestCockroachDBQueryBuilder_RandomOrder(t *testing.T) {
+	conn, err := NewCockroachDBConnector[testutils.Account, int64](
+		&pgxpool.Pool{},
+		"accounts",
+		func(a *testutils.Account) int64 { return a.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected string
+	}{
+		{"sample", NewFilter().Where("balance", OpGreaterThan, 0).Sample(5).Build(),
+			`SELECT "id", "balance" FROM "accounts" WHERE "balance" > $1 ORDER BY random() LIMIT 5`},
+		{"random tie-break", NewFilter().OrderBy("balance", SortDesc).OrderRandom().Build(),
+			`SELECT "id", "balance" FROM "accounts" ORDER BY "balance" DESC, random()`},
+		{"grouped", NewFilter().GroupBy("balance").OrderRandom().Limit(2).Build(),
+			`SELECT "balance" FROM "accounts" GROUP BY "balance" ORDER BY random() LIMIT 2`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _, err := conn.queryBuilder(tt.filter)
+			if err != nil {
+				t.Fatalf("queryBuilder failed: %v", err)
+			}
+			if query != tt.expected {
+				t.Errorf("Expected: %s\nGot: %s", tt.expected, query)
+			}
+		})
+	}
+}
+
+func TestInMemorySample(t *testing.T) {
+	ctx := context.Background()
+
+	type row struct {
+		ID     int64  `db:"id"`
+		Status string `db:"status"`
+	}
+
+	repo := NewInMemoryConnector[row, int64](func(r *row) int64 { return r.ID })
+	var rows []row
+	for i := int64(1); i <= 20; i++ {
+		status := "active"
+		if i%2 == 0 {
+			status = "closed"
+		}
+		rows = append(rows, row{ID: i, Status: status})
+	}
+	repo.BatchCreate(ctx, rows)
+
+	t.Run("Returns distinct matching rows", func(t *testing.T) {
+		results, err := repo.Query(ctx, NewFilter().Where("status", OpEqual, "active").Sample(4).Build())
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(results) != 4 {
+			t.Fatalf("Expected 4 rows, got %d", len(results))
+		}
+		seen := map[int64]bool{}
+		for _, r := range results {
+			if r.Status != "active" || seen[r.ID] {
+				t.Errorf("Unexpected row %+v in %v", r, results)
+			}
+			seen[r.ID] = true
+		}
+	})
+
+	t.Run("Sample larger than matches", func(t *testing.T) {
+		results, _ := repo.Query(ctx, NewFilter().Where("id", OpLessThanOrEqual, 3).Sample(10).Build())
+		if len(results) != 3 {
+			t.Errorf("Expected 3 rows, got %d", len(results))
+		}
+	})
+
+	t.Run("Huge sample sizes are not preallocated", func(t *testing.T) {
+		results, err := repo.Query(ctx, NewFilter().Sample(1<<40).Build())
+		if err != nil || len(results) != 20 || cap(results) != 20 {
+			t.Errorf("Expected 20 rows in a slice of 20, got %d of %d (%v)", len(results), cap(results), err)
+		}
+	})
+
+	t.Run("Roughly uniform", func(t *testing.T) {
+		counts := map[int64]int{}
+		filter := NewFilter().Where("id", OpLessThanOrEqual, 4).Sample(1).Build()
+		for i := 0; i < 4000; i++ {
+			results, _ := repo.Query(ctx, filter)
+			counts[results[0].ID]++
+		}
+		for id := int64(1); id <= 4; id++ {
+			if counts[id] < 800 || counts[id] > 1200 {
+				t.Errorf("Row %d sampled %d times out of 4000: %v", id, counts[id], counts)
+			}
+		}
+	})
+
+	t.Run("Random order after other sort fields", func(t *testing.T) {
+		results, _ := repo.Query(ctx, NewFilter().OrderBy("status", SortAsc).OrderRandom().Build())
+		if len(results) != 20 {
+			t.Fatalf("Expected 20 rows, got %d", len(results))
+		}
+		for i, r := range results {
+			expected := "active"
+			if i >= 10 {
+				expected = "closed"
+			}
+			if r.Status != expected {
+				t.Fatalf("Expected status order to be kept, got %v", results)
+			}
+		}
+	})
+
+	t.Run("Grouped", func(t *testing.T) {
+		results, err := repo.Query(ctx, NewFilter().GroupBy("status").OrderRandom().Build())
+		if err != nil || len(results) != 2 {
+			t.Errorf("Expected 2 groups, got %v (%v)", results, err)
+		}
+	})
+}
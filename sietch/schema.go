@@ -4,7 +4,11 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+
+	"github.com/jackc/pgx/v5"
 )
 
 // ColumnType represents SQL column data types
@@ -23,6 +27,7 @@ const (
 	ColumnTypeJSON      ColumnType = "JSONB"
 	ColumnTypeFloat     ColumnType = "FLOAT8"
 	ColumnTypeNumeric   ColumnType = "NUMERIC"
+	ColumnTypeEnum      ColumnType = "ENUM" // The type named by ColumnDef.Enum
 )
 
 // IndexType represents different types of database indexes
@@ -35,6 +40,18 @@ const (
 	IndexTypeGist  IndexType = "GIST"
 )
 
+// ReferentialAction is what a foreign key does to the referencing rows when
+// the referenced row is deleted or updated
+type ReferentialAction string
+
+const (
+	ActionNoAction   ReferentialAction = "NO ACTION"
+	ActionRestrict   ReferentialAction = "RESTRICT"
+	ActionCascade    ReferentialAction = "CASCADE"
+	ActionSetNull    ReferentialAction = "SET NULL"
+	ActionSetDefault ReferentialAction = "SET DEFAULT"
+)
+
 // ColumnDef defines a table column
 type ColumnDef struct {
 	Name         string
@@ -44,6 +61,38 @@ type ColumnDef struct {
 	Unique       bool
 	DefaultValue string
 	Check        string
+	References   *ForeignKeyRef // Foreign key of the column
+	Enum         *EnumDef       // Type of ColumnTypeEnum columns
+}
+
+// EnumDef defines an enum type, created by GenerateCreateTypeSQL
+type EnumDef struct {
+	Name   string
+	Values []string
+}
+
+// CheckDef defines a table-level check constraint
+type CheckDef struct {
+	Name string // Constraint name, generated by the database if empty
+	Expr string
+}
+
+// ForeignKeyRef is the column a single-column foreign key references
+type ForeignKeyRef struct {
+	Table    string
+	Column   string // The primary key of Table if empty
+	OnDelete ReferentialAction
+	OnUpdate ReferentialAction
+}
+
+// ForeignKeyDef defines a table-level foreign key, e.g. over several columns
+type ForeignKeyDef struct {
+	Name       string // Constraint name, generated by the database if empty
+	Columns    []string
+	Table      string
+	RefColumns []string // The primary key of Table if empty
+	OnDelete   ReferentialAction
+	OnUpdate   ReferentialAction
 }
 
 // IndexDef defines a table index
@@ -57,9 +106,63 @@ type IndexDef struct {
 
 // TableDef defines a complete table schema
 type TableDef struct {
-	Name    string
-	Columns []ColumnDef
-	Indexes []IndexDef
+	Name        string
+	Columns     []ColumnDef
+	Indexes     []IndexDef
+	ForeignKeys []ForeignKeyDef
+	Checks      []CheckDef
+}
+
+// Enums returns the enum types of the columns of def, once each
+func (def *TableDef) Enums() []*EnumDef {
+	var enums []*EnumDef
+	for _, col := range def.Columns {
+		if col.Enum != nil && !slices.ContainsFunc(enums, func(e *EnumDef) bool { return e.Name == col.Enum.Name }) {
+			enums = append(enums, col.Enum)
+		}
+	}
+	return enums
+}
+
+// validateConstraints rejects constraint and referenced table names of def
+// that would not be safe to interpolate into DDL
+func (def *TableDef) validateConstraints() error {
+	for _, col := range def.Columns {
+		if col.References == nil {
+			continue
+		}
+		if err := sanitizeIdentifier(col.References.Table); err != nil {
+			return fmt.Errorf("column %s references an invalid table: %w", col.Name, err)
+		}
+		if col.References.Column != "" {
+			if err := sanitizeIdentifier(col.References.Column); err != nil {
+				return fmt.Errorf("column %s references an invalid column: %w", col.Name, err)
+			}
+		}
+	}
+	for _, fk := range def.ForeignKeys {
+		if fk.Name != "" {
+			if err := sanitizeIdentifier(fk.Name); err != nil {
+				return fmt.Errorf("invalid foreign key name: %w", err)
+			}
+		}
+		if err := sanitizeIdentifier(fk.Table); err != nil {
+			return fmt.Errorf("foreign key %s references an invalid table: %w", fk.Name, err)
+		}
+		for _, column := range slices.Concat(fk.Columns, fk.RefColumns) {
+			if err := sanitizeIdentifier(column); err != nil {
+				return fmt.Errorf("foreign key %s has an invalid column: %w", fk.Name, err)
+			}
+		}
+	}
+	for _, check := range def.Checks {
+		if check.Name != "" {
+			if err := sanitizeIdentifier(check.Name); err != nil {
+				return fmt.Errorf("invalid check constraint name: %w", err)
+			}
+		}
+	}
+	return nil
 }
 
 // SchemaHelper provides utilities for schema management (primarily for testing)
@@ -77,7 +180,23 @@ func NewSchemaHelper[T any, ID comparable](connector *CockroachDBConnector[T, ID
 	}
 }
 
-// InferTableDef infers table definition from a struct type
+// InferTableDef infers table definition from a struct type. Besides the db
+// tag options pk and version, it reads the unique, nullable and default tags
+// and fk, the foreign key of the column:
+//
+//	UserID int64 `db:"user_id" fk:"users(id),ondelete=cascade"`
+//
+// The referenced column defaults to the primary key of the table, and the
+// actions, ondelete and onupdate, to NO ACTION.
+//
+// The enum tag makes the column an enum type, named table_column unless
+// given. The check tag and the min, max, len and oneof rules of the validate
+// tag make up its check constraint, bounding the length of strings:
+//
+//	Status string `db:"status" enum:"account_status(active,closed)"`
+//	Kind   string `db:"kind" enum:"personal,business"`
+//	Score  int    `db:"score" validate:"min=0,max=100"`
+//	Tier   string `db:"tier" validate:"oneof=free pro" check:"tier <> ''"`
 func InferTableDef[T any](tableName string) (*TableDef, error) {
 	var zero T
 	typ := reflect.TypeOf(zero)
@@ -94,17 +213,16 @@ func InferTableDef[T any](tableName string) (*TableDef, error) {
 		Indexes: make([]IndexDef, 0),
 	}
 
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		dbTag := field.Tag.Get("db")
-		if dbTag == "" || dbTag == "-" {
-			continue
-		}
+	// The pk-tagged field is the primary key, the first field otherwise
+	fields := dbFields(typ)
+	hasPK := slices.ContainsFunc(fields, func(f dbField) bool { return slices.Contains(f.options, "pk") })
 
+	for i, f := range fields {
+		field, column, options := f.field, f.column, f.options
 		colDef := ColumnDef{
-			Name:       dbTag,
+			Name:       column,
 			Type:       inferColumnType(field.Type),
-			PrimaryKey: i == 0, // First field is assumed to be primary key
+			PrimaryKey: slices.Contains(options, "pk") || !hasPK && i == 0,
 			NotNull:    true,
 		}
 
@@ -117,7 +235,28 @@ func InferTableDef[T any](tableName string) (*TableDef, error) {
 		}
 		if defaultVal := field.Tag.Get("default"); defaultVal != "" {
 			colDef.DefaultValue = defaultVal
+		} else if slices.Contains(options, "version") {
+			colDef.DefaultValue = "0"
+		}
+		if fk := field.Tag.Get("fk"); fk != "" {
+			ref, err := parseForeignKeyTag(fk)
+			if err != nil {
+				return nil, fmt.Errorf("invalid fk tag of %s: %w", column, err)
+			}
+			colDef.References = ref
 		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			def, err := parseEnumTag(enum, tableName+"_"+column)
+			if err != nil {
+				return nil, fmt.Errorf("invalid enum tag of %s: %w", column, err)
+			}
+			colDef.Type, colDef.Enum = ColumnTypeEnum, def
+		}
+		check, err := checkFromTags(column, field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid check of %s: %w", column, err)
+		}
+		colDef.Check = check
 
 		tableDef.Columns = append(tableDef.Columns, colDef)
 	}
@@ -125,6 +264,135 @@ func InferTableDef[T any](tableName string) (*TableDef, error) {
 	return tableDef, nil
 }
 
+// parseEnumTag parses an enum tag, name(values) or only the values, named
+// defaultName, separated by commas
+func parseEnumTag(tag, defaultName string) (*EnumDef, error) {
+	def := &EnumDef{Name: defaultName}
+	values := tag
+	if name, rest, ok := strings.Cut(tag, "("); ok {
+		rest, ok = strings.CutSuffix(strings.TrimSpace(rest), ")")
+		if !ok {
+			return nil, fmt.Errorf("missing ) in '%s'", tag)
+		}
+		def.Name, values = strings.TrimSpace(name), rest
+	}
+	if err := sanitizeIdentifier(def.Name); err != nil {
+		return nil, err
+	}
+	for _, value := range strings.Split(values, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			def.Values = append(def.Values, value)
+		}
+	}
+	if len(def.Values) == 0 {
+		return nil, fmt.Errorf("enum %s has no values", def.Name)
+	}
+	return def, nil
+}
+
+// checkFromTags joins the check tag of column and the min, max, len and
+// oneof rules of its validate tag into one check constraint
+func checkFromTags(column string, field reflect.StructField) (string, error) {
+	var conds []string
+	if check := field.Tag.Get("check"); check != "" {
+		conds = append(conds, check)
+	}
+
+	typ := field.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	operand := fmt.Sprintf(`"%s"`, column)
+	if typ.Kind() == reflect.String {
+		operand = fmt.Sprintf(`char_length("%s")`, column)
+	}
+	for _, spec := range strings.Split(field.Tag.Get("validate"), ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(spec), "=")
+		switch name {
+		case "min", "max", "len":
+			// Lengths of slices and maps are left to the validation hook
+			if typ.Kind() != reflect.String && (!isNumericKind(typ.Kind()) || name == "len") {
+				continue
+			}
+			if _, err := strconv.ParseFloat(param, 64); err != nil {
+				return "", fmt.Errorf("rule %s needs a number, got '%s'", name, param)
+			}
+			op := map[string]string{"min": ">=", "max": "<=", "len": "="}[name]
+			conds = append(conds, fmt.Sprintf("%s %s %s", operand, op, param))
+		case "oneof":
+			values := strings.Fields(param)
+			if len(values) == 0 {
+				return "", fmt.Errorf("rule oneof needs values")
+			}
+			for i, value := range values {
+				values[i] = quoteLiteral(value)
+			}
+			conds = append(conds, fmt.Sprintf(`"%s" IN (%s)`, column, strings.Join(values, ", ")))
+		}
+	}
+	if len(conds) > 1 {
+		for i, cond := range conds {
+			conds[i] = "(" + cond + ")"
+		}
+	}
+	return strings.Join(conds, " AND "), nil
+}
+
+// quoteLiteral quotes s as a SQL string literal
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// parseForeignKeyTag parses an fk tag, table(column) followed by the
+// optional ondelete=action and onupdate=action
+func parseForeignKeyTag(tag string) (*ForeignKeyRef, error) {
+	target, options, _ := strings.Cut(tag, ",")
+	ref := &ForeignKeyRef{Table: strings.TrimSpace(target)}
+	if table, column, ok := strings.Cut(ref.Table, "("); ok {
+		column, ok = strings.CutSuffix(column, ")")
+		if !ok {
+			return nil, fmt.Errorf("missing ) in '%s'", target)
+		}
+		ref.Table, ref.Column = strings.TrimSpace(table), strings.TrimSpace(column)
+		if err := sanitizeIdentifier(ref.Column); err != nil {
+			return nil, err
+		}
+	}
+	if err := sanitizeIdentifier(ref.Table); err != nil {
+		return nil, err
+	}
+
+	for _, option := range strings.Split(options, ",") {
+		if strings.TrimSpace(option) == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(option, "=")
+		action, err := parseReferentialAction(value)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "ondelete":
+			ref.OnDelete = action
+		case "onupdate":
+			ref.OnUpdate = action
+		default:
+			return nil, fmt.Errorf("unknown option '%s'", key)
+		}
+	}
+	return ref, nil
+}
+
+// parseReferentialAction parses an action such as cascade or set_null
+func parseReferentialAction(s string) (ReferentialAction, error) {
+	action := ReferentialAction(strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), "_", " ")))
+	switch action {
+	case ActionNoAction, ActionRestrict, ActionCascade, ActionSetNull, ActionSetDefault:
+		return action, nil
+	}
+	return "", fmt.Errorf("unknown referential action '%s'", s)
+}
+
 // inferColumnType maps Go types to SQL column types
 func inferColumnType(t reflect.Type) ColumnType {
 	if t.Kind() == reflect.Ptr {
@@ -156,25 +424,19 @@ func GenerateCreateTableSQL(def *TableDef) string {
 
 	// Column definitions
 	for _, col := range def.Columns {
-		colDef := fmt.Sprintf(`"%s" %s`, col.Name, col.Type)
+		parts = append(parts, columnSQL(col))
+	}
 
-		if col.PrimaryKey {
-			colDef += " PRIMARY KEY"
-		}
-		if col.NotNull && !col.PrimaryKey {
-			colDef += " NOT NULL"
-		}
-		if col.Unique && !col.PrimaryKey {
-			colDef += " UNIQUE"
-		}
-		if col.DefaultValue != "" {
-			colDef += " DEFAULT " + col.DefaultValue
-		}
-		if col.Check != "" {
-			colDef += " CHECK (" + col.Check + ")"
+	// Table-level foreign keys and checks
+	for _, fk := range def.ForeignKeys {
+		parts = append(parts, foreignKeySQL(fk))
+	}
+	for _, check := range def.Checks {
+		if check.Name != "" {
+			parts = append(parts, fmt.Sprintf(`CONSTRAINT "%s" CHECK (%s)`, check.Name, check.Expr))
+		} else {
+			parts = append(parts, "CHECK ("+check.Expr+")")
 		}
-
-		parts = append(parts, colDef)
 	}
 
 	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS \"%s\" (\n  %s\n)",
@@ -185,6 +447,90 @@ func GenerateCreateTableSQL(def *TableDef) string {
 	return sql
 }
 
+// GenerateCreateTypeSQL generates CREATE TYPE SQL from an enum definition.
+// PostgreSQL has no CREATE TYPE IF NOT EXISTS, so the statement runs in a DO
+// block that ignores an existing type of the same name.
+func GenerateCreateTypeSQL(enum *EnumDef) string {
+	create := "CREATE TYPE " + enumClause(enum)
+
+	// The dollar quote must not occur in the values
+	tag := "$enum$"
+	for i := 1; strings.Contains(create, tag); i++ {
+		tag = fmt.Sprintf("$enum%d$", i)
+	}
+	return fmt.Sprintf("DO %s BEGIN %s; EXCEPTION WHEN duplicate_object THEN NULL; END %s", tag, create, tag)
+}
+
+// enumClause is the SQL of an enum type
+func enumClause(enum *EnumDef) string {
+	values := make([]string, len(enum.Values))
+	for i, value := range enum.Values {
+		values[i] = quoteLiteral(value)
+	}
+	return fmt.Sprintf(`"%s" AS ENUM (%s)`, enum.Name, strings.Join(values, ", "))
+}
+
+// columnSQL generates the definition of a column
+func columnSQL(col ColumnDef) string {
+	colDef := fmt.Sprintf(`"%s" %s`, col.Name, col.Type)
+	if col.Type == ColumnTypeEnum && col.Enum != nil {
+		colDef = fmt.Sprintf(`"%s" "%s"`, col.Name, col.Enum.Name)
+	}
+
+	if col.PrimaryKey {
+		colDef += " PRIMARY KEY"
+	}
+	if col.NotNull && !col.PrimaryKey {
+		colDef += " NOT NULL"
+	}
+	if col.Unique && !col.PrimaryKey {
+		colDef += " UNIQUE"
+	}
+	if col.DefaultValue != "" {
+		colDef += " DEFAULT " + col.DefaultValue
+	}
+	if col.Check != "" {
+		colDef += " CHECK (" + col.Check + ")"
+	}
+	if ref := col.References; ref != nil {
+		colDef += referencesSQL(ref.Table, columnList(ref.Column), ref.OnDelete, ref.OnUpdate)
+	}
+	return colDef
+}
+
+// foreignKeySQL generates a table-level foreign key constraint
+func foreignKeySQL(fk ForeignKeyDef) string {
+	sql := ""
+	if fk.Name != "" {
+		sql = fmt.Sprintf(`CONSTRAINT "%s" `, fk.Name)
+	}
+	return sql + fmt.Sprintf("FOREIGN KEY (%s)", joinQuotedColumns(fk.Columns)) +
+		referencesSQL(fk.Table, fk.RefColumns, fk.OnDelete, fk.OnUpdate)
+}
+
+// referencesSQL generates the REFERENCES clause of a foreign key
+func referencesSQL(table string, columns []string, onDelete, onUpdate ReferentialAction) string {
+	sql := fmt.Sprintf(` REFERENCES "%s"`, table)
+	if len(columns) > 0 {
+		sql += fmt.Sprintf(" (%s)", joinQuotedColumns(columns))
+	}
+	if onDelete != "" {
+		sql += " ON DELETE " + string(onDelete)
+	}
+	if onUpdate != "" {
+		sql += " ON UPDATE " + string(onUpdate)
+	}
+	return sql
+}
+
+// columnList returns column as a list, empty if it is
+func columnList(column string) []string {
+	if column == "" {
+		return nil
+	}
+	return []string{column}
+}
+
 // GenerateDropTableSQL generates DROP TABLE SQL
 func GenerateDropTableSQL(tableName string) string {
 	return fmt.Sprintf("DROP TABLE IF EXISTS \"%s\" CASCADE", tableName)
@@ -217,14 +563,23 @@ func GenerateCreateIndexSQL(tableName string, idx *IndexDef) string {
 	return sql
 }
 
-// CreateTableFromStruct creates a table based on a struct definition
+// CreateTableFromStruct creates a table based on a struct definition, after
+// the enum types of its columns
 // This is primarily for testing and development purposes
 func CreateTableFromStruct[T any](ctx context.Context, connector *CockroachDBConnector[T, any], tableName string) error {
 	tableDef, err := InferTableDef[T](tableName)
 	if err != nil {
 		return err
 	}
+	if err := tableDef.validateConstraints(); err != nil {
+		return err
+	}
 
+	for _, enum := range tableDef.Enums() {
+		if _, err := connector.pool.Exec(ctx, GenerateCreateTypeSQL(enum)); err != nil {
+			return err
+		}
+	}
 	sql := GenerateCreateTableSQL(tableDef)
 	_, err = connector.pool.Exec(ctx, sql)
 	return err
@@ -250,3 +605,223 @@ func TruncateTable(ctx context.Context, connector *CockroachDBConnector[any, any
 	_, err := connector.pool.Exec(ctx, sql)
 	return err
 }
+
+// TableDiff is the drift between a table definition and the table in the
+// database, as found by DiffTable
+type TableDiff struct {
+	Table          string
+	Missing        bool           // the table does not exist
+	AddedColumns   []ColumnDef    // defined but not in the table
+	AddedIndexes   []IndexDef     // defined but not in the table, all of them if it is missing
+	ChangedColumns []ColumnChange // never altered by AutoMigrate
+	ExtraColumns   []string       // in the table but not defined; never dropped by AutoMigrate
+
+	// ChangedConstraints are the foreign keys, unique constraints, enum
+	// values and checks that differ; only DiffTableDefs compares them and
+	// AutoMigrate never alters them
+	ChangedConstraints []ConstraintChange
+
+	def *TableDef
+}
+
+// ConstraintChange is a constraint that differs from its definition, as
+// SQL; Defined is empty for constraints of the table only, Actual for
+// missing ones
+type ConstraintChange struct {
+	Kind    string // foreign key, unique, enum or check
+	Name    string // constraint, column or enum type
+	Defined string
+	Actual  string
+}
+
+// ColumnChange is a column whose type or nullability differs from its definition
+type ColumnChange struct {
+	Name          string
+	Type          ColumnType // defined type
+	ActualType    string     // data_type reported by information_schema
+	NotNull       bool
+	ActualNotNull bool
+}
+
+// HasChanges reports whether the table differs from its definition
+func (d *TableDiff) HasChanges() bool {
+	return d.Missing || len(d.AddedColumns) > 0 || len(d.AddedIndexes) > 0 ||
+		len(d.ChangedColumns) > 0 || len(d.ExtraColumns) > 0 || len(d.ChangedConstraints) > 0
+}
+
+// Statements returns the statements that create the missing table, or add
+// the missing columns and indexes, after the enum types they use. Changed
+// and extra columns need a manual migration (see the migrate package) and
+// produce no statements.
+func (d *TableDiff) Statements() []string {
+	var stmts []string
+	if d.Missing {
+		for _, enum := range d.def.Enums() {
+			stmts = append(stmts, GenerateCreateTypeSQL(enum))
+		}
+		stmts = append(stmts, GenerateCreateTableSQL(d.def))
+	}
+	added := &TableDef{Columns: d.AddedColumns}
+	for _, enum := range added.Enums() {
+		stmts = append(stmts, GenerateCreateTypeSQL(enum))
+	}
+	for _, col := range d.AddedColumns {
+		stmts = append(stmts, fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN %s`, d.Table, columnSQL(col)))
+	}
+	for i := range d.AddedIndexes {
+		stmts = append(stmts, GenerateCreateIndexSQL(d.Table, &d.AddedIndexes[i]))
+	}
+	return stmts
+}
+
+// existingColumn is a column reported by information_schema
+type existingColumn struct {
+	Name     string
+	DataType string
+	Nullable bool
+}
+
+// DiffTable compares the columns and indexes of def, e.g. from
+// InferTableDef, with the table in the current schema of the connector's
+// database. Constraints are compared by DiffTableDefs with IntrospectTable.
+//
+// Example:
+//
+//	def, _ := sietch.InferTableDef[Account]("accounts")
+//	diff, err := sietch.DiffTable(ctx, repo, def)
+//	if err == nil && diff.HasChanges() {
+//	    log.Printf("schema drift: %+v", diff)
+//	}
+func DiffTable[T any, ID comparable](ctx context.Context, connector *CockroachDBConnector[T, ID], def *TableDef) (*TableDiff, error) {
+	if err := def.validateConstraints(); err != nil {
+		return nil, err
+	}
+	rows, err := connector.pool.Query(ctx, `SELECT column_name, data_type, is_nullable = 'YES'
+FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name = $1
+ORDER BY ordinal_position`, def.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the columns of %s: %w", def.Name, err)
+	}
+	columns, err := pgx.CollectRows(rows, pgx.RowToStructByPos[existingColumn])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the columns of %s: %w", def.Name, err)
+	}
+
+	rows, err = connector.pool.Query(ctx, `SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1`, def.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the indexes of %s: %w", def.Name, err)
+	}
+	indexes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the indexes of %s: %w", def.Name, err)
+	}
+
+	return diffTableDef(def, columns, indexes), nil
+}
+
+// diffTableDef compares def with the columns and index names of the table
+func diffTableDef(def *TableDef, columns []existingColumn, indexes []string) *TableDiff {
+	diff := &TableDiff{Table: def.Name, def: def}
+	if len(columns) == 0 {
+		diff.Missing = true
+		diff.AddedIndexes = def.Indexes
+		return diff
+	}
+
+	existing := make(map[string]existingColumn, len(columns))
+	for _, col := range columns {
+		existing[col.Name] = col
+	}
+	defined := make(map[string]bool, len(def.Columns))
+	for _, col := range def.Columns {
+		defined[col.Name] = true
+
+		actual, ok := existing[col.Name]
+		if !ok {
+			diff.AddedColumns = append(diff.AddedColumns, col)
+			continue
+		}
+		notNull := col.NotNull || col.PrimaryKey
+		if columnTypeFamily(string(col.Type)) != columnTypeFamily(actual.DataType) || notNull == actual.Nullable {
+			diff.ChangedColumns = append(diff.ChangedColumns, ColumnChange{
+				Name:          col.Name,
+				Type:          col.Type,
+				ActualType:    actual.DataType,
+				NotNull:       notNull,
+				ActualNotNull: !actual.Nullable,
+			})
+		}
+	}
+	for _, col := range columns {
+		if !defined[col.Name] {
+			diff.ExtraColumns = append(diff.ExtraColumns, col.Name)
+		}
+	}
+
+	for _, idx := range def.Indexes {
+		if !slices.Contains(indexes, idx.Name) {
+			diff.AddedIndexes = append(diff.AddedIndexes, idx)
+		}
+	}
+	return diff
+}
+
+// columnTypeFamily normalizes a column type, so that e.g. INTEGER matches
+// the "bigint" CockroachDB reports for it and VARCHAR(64) matches TEXT
+func columnTypeFamily(typ string) string {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if i := strings.IndexByte(typ, '('); i >= 0 {
+		typ = strings.TrimSpace(typ[:i])
+	}
+
+	switch typ {
+	case "serial", "bigserial", "smallserial", "integer", "bigint", "smallint", "int", "int2", "int4", "int8":
+		return "int"
+	case "text", "varchar", "character varying", "char", "character", "string":
+		return "string"
+	case "boolean", "bool":
+		return "bool"
+	case "timestamp", "timestamp without time zone", "timestamptz", "timestamp with time zone":
+		return "timestamp"
+	case "float8", "float4", "float", "double precision", "real":
+		return "float"
+	case "numeric", "decimal":
+		return "decimal"
+	case "jsonb", "json":
+		return "json"
+	case "enum", "user-defined":
+		return "enum"
+	}
+	return typ
+}
+
+// AutoMigrateOptions configures AutoMigrate
+type AutoMigrateOptions struct {
+	// DryRun returns the statements without running them
+	DryRun bool
+}
+
+// AutoMigrate brings the table of def up to date by creating it, or adding
+// its missing columns and indexes. It never alters or drops existing
+// columns. It returns the statements it ran, or would run in dry-run mode;
+// on error, those that ran before the failing one.
+//
+// Adding a NOT NULL column without a default fails on a table with rows.
+func AutoMigrate[T any, ID comparable](ctx context.Context, connector *CockroachDBConnector[T, ID], def *TableDef, opts AutoMigrateOptions) ([]string, error) {
+	diff, err := DiffTable(ctx, connector, def)
+	if err != nil {
+		return nil, err
+	}
+
+	stmts := diff.Statements()
+	if opts.DryRun {
+		return stmts, nil
+	}
+	for i, stmt := range stmts {
+		if _, err := connector.pool.Exec(ctx, stmt); err != nil {
+			return stmts[:i], fmt.Errorf("failed to migrate %s: %w", def.Name, err)
+		}
+	}
+	return stmts, nil
+}
@@ -0,0 +1,450 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// introspectedColumn is a column reported by information_schema
+type introspectedColumn struct {
+	Name      string
+	DataType  string
+	UDTName   string
+	MaxLength *int64
+	Nullable  bool
+	Default   *string
+}
+
+// introspectedConstraint is a constraint reported by pg_constraint
+type introspectedConstraint struct {
+	Name       string
+	Type       string // p, u, f or c
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string
+	OnUpdate   string
+	Definition string
+}
+
+// introspectedIndex is an index reported by pg_index
+type introspectedIndex struct {
+	Name    string
+	Method  string
+	Columns []string
+	Unique  bool
+	Primary bool
+	Where   string
+}
+
+// introspectedEnum is an enum type reported by pg_enum
+type introspectedEnum struct {
+	Name   string
+	Values []string
+}
+
+const introspectColumnsSQL = `SELECT column_name, data_type, udt_name, character_maximum_length, is_nullable = 'YES', column_default
+FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name = $1
+ORDER BY ordinal_position`
+
+const introspectConstraintsSQL = `SELECT c.conname, c.contype::TEXT,
+  ARRAY(SELECT a.attname FROM unnest(c.conkey) WITH ORDINALITY AS k(num, pos)
+    JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.num ORDER BY k.pos)::TEXT[],
+  COALESCE(f.relname, ''),
+  ARRAY(SELECT a.attname FROM unnest(c.confkey) WITH ORDINALITY AS k(num, pos)
+    JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.num ORDER BY k.pos)::TEXT[],
+  COALESCE(c.confdeltype::TEXT, ''), COALESCE(c.confupdtype::TEXT, ''),
+  pg_get_constraintdef(c.oid)
+FROM pg_constraint c
+JOIN pg_class t ON t.oid = c.conrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+LEFT JOIN pg_class f ON f.oid = c.confrelid
+WHERE n.nspname = current_schema() AND t.relname = $1
+ORDER BY c.conname`
+
+const introspectIndexesSQL = `SELECT i.relname, am.amname,
+  ARRAY(SELECT a.attname FROM unnest(x.indkey) WITH ORDINALITY AS k(num, pos)
+    JOIN pg_attribute a ON a.attrelid = x.indrelid AND a.attnum = k.num ORDER BY k.pos)::TEXT[],
+  x.indisunique, x.indisprimary, COALESCE(pg_get_expr(x.indpred, x.indrelid), '')
+FROM pg_index x
+JOIN pg_class i ON i.oid = x.indexrelid
+JOIN pg_class t ON t.oid = x.indrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+JOIN pg_am am ON am.oid = i.relam
+WHERE n.nspname = current_schema() AND t.relname = $1
+ORDER BY i.relname`
+
+const introspectEnumsSQL = `SELECT t.typname, array_agg(e.enumlabel ORDER BY e.enumsortorder)::TEXT[]
+FROM pg_enum e
+JOIN pg_type t ON t.oid = e.enumtypid
+JOIN pg_namespace n ON n.oid = t.typnamespace
+WHERE n.nspname = current_schema()
+GROUP BY t.typname`
+
+// IntrospectTable reads the definition of table name in the current schema
+// from information_schema and pg_catalog: its columns with their types,
+// nullability, defaults and enum types, its primary key, unique, foreign
+// key and check constraints, and its secondary indexes. It fails if the
+// table does not exist.
+//
+// Single-column unique and foreign key constraints are set on their column,
+// the others become indexes and table-level foreign keys. Every column of a
+// composite primary key is marked PrimaryKey, so such a definition only
+// serves comparisons. Defaults are the expressions the database reports,
+// e.g. 0:::INT8 on CockroachDB.
+//
+// Example:
+//
+//	def, _ := sietch.InferTableDef[Account]("accounts")
+//	live, err := sietch.IntrospectTable(ctx, pool, "accounts")
+//	if err == nil && sietch.DiffTableDefs(def, live).HasChanges() {
+//	    t.Fatalf("schema drift: %+v", sietch.DiffTableDefs(def, live))
+//	}
+func IntrospectTable(ctx context.Context, pool Queryable, name string) (*TableDef, error) {
+	columns, err := introspect(ctx, pool, introspectColumnsSQL, name, pgx.RowToStructByPos[introspectedColumn])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the columns of %s: %w", name, err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist", name)
+	}
+	constraints, err := introspect(ctx, pool, introspectConstraintsSQL, name, pgx.RowToStructByPos[introspectedConstraint])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the constraints of %s: %w", name, err)
+	}
+	indexes, err := introspect(ctx, pool, introspectIndexesSQL, name, pgx.RowToStructByPos[introspectedIndex])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the indexes of %s: %w", name, err)
+	}
+
+	var enums []introspectedEnum
+	if slices.ContainsFunc(columns, func(col introspectedColumn) bool { return col.DataType == "USER-DEFINED" }) {
+		enums, err = introspect(ctx, pool, introspectEnumsSQL, nil, pgx.RowToStructByPos[introspectedEnum])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the enum types of %s: %w", name, err)
+		}
+	}
+
+	return buildTableDef(name, columns, constraints, indexes, enums), nil
+}
+
+// introspect runs a catalog query, with name as its only argument unless nil
+func introspect[R any](ctx context.Context, pool Queryable, sql string, name any, scan pgx.RowToFunc[R]) ([]R, error) {
+	var args []any
+	if name != nil {
+		args = append(args, name)
+	}
+	rows, err := pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scan)
+}
+
+// buildTableDef assembles the definition of table name from the catalog rows
+func buildTableDef(name string, columns []introspectedColumn, constraints []introspectedConstraint, indexes []introspectedIndex, enums []introspectedEnum) *TableDef {
+	def := &TableDef{Name: name}
+	for _, col := range columns {
+		colDef := ColumnDef{
+			Name:    col.Name,
+			Type:    introspectedColumnType(col),
+			NotNull: !col.Nullable,
+		}
+		if col.Default != nil {
+			colDef.DefaultValue = *col.Default
+		}
+		if col.DataType == "USER-DEFINED" {
+			for _, enum := range enums {
+				if enum.Name == col.UDTName {
+					colDef.Type = ColumnTypeEnum
+					colDef.Enum = &EnumDef{Name: enum.Name, Values: enum.Values}
+				}
+			}
+		}
+		def.Columns = append(def.Columns, colDef)
+	}
+
+	column := func(name string) *ColumnDef {
+		for i := range def.Columns {
+			if def.Columns[i].Name == name {
+				return &def.Columns[i]
+			}
+		}
+		return nil
+	}
+	singleColumn := func(names []string) *ColumnDef {
+		if len(names) != 1 {
+			return nil
+		}
+		return column(names[0])
+	}
+	var constraintNames []string
+	for _, c := range constraints {
+		constraintNames = append(constraintNames, c.Name)
+		switch c.Type {
+		case "p":
+			for _, name := range c.Columns {
+				if col := column(name); col != nil {
+					col.PrimaryKey = true
+				}
+			}
+		case "u":
+			if col := singleColumn(c.Columns); col != nil {
+				col.Unique = true
+			} else {
+				def.Indexes = append(def.Indexes, IndexDef{Name: c.Name, Type: IndexTypeBTree, Columns: c.Columns, Unique: true})
+			}
+		case "f":
+			onDelete, onUpdate := referentialActionCode(c.OnDelete), referentialActionCode(c.OnUpdate)
+			if col := singleColumn(c.Columns); col != nil && len(c.RefColumns) == 1 {
+				col.References = &ForeignKeyRef{Table: c.RefTable, Column: c.RefColumns[0], OnDelete: onDelete, OnUpdate: onUpdate}
+			} else {
+				def.ForeignKeys = append(def.ForeignKeys, ForeignKeyDef{
+					Name: c.Name, Columns: c.Columns, Table: c.RefTable, RefColumns: c.RefColumns, OnDelete: onDelete, OnUpdate: onUpdate,
+				})
+			}
+		case "c":
+			expr := strings.TrimSpace(c.Definition)
+			if inner, ok := strings.CutPrefix(expr, "CHECK ("); ok {
+				expr = strings.TrimSuffix(inner, ")")
+			}
+			def.Checks = append(def.Checks, CheckDef{Name: c.Name, Expr: expr})
+		}
+	}
+
+	// The indexes backing the primary key and unique constraints are
+	// described by the constraints
+	for _, idx := range indexes {
+		if idx.Primary || slices.Contains(constraintNames, idx.Name) {
+			continue
+		}
+		def.Indexes = append(def.Indexes, IndexDef{
+			Name:    idx.Name,
+			Type:    introspectedIndexType(idx.Method),
+			Columns: idx.Columns,
+			Unique:  idx.Unique,
+			Where:   idx.Where,
+		})
+	}
+	return def
+}
+
+// introspectedColumnType maps the data_type reported by information_schema
+// to a column type
+func introspectedColumnType(col introspectedColumn) ColumnType {
+	switch col.DataType {
+	case "bigint":
+		return ColumnTypeBigInt
+	case "integer":
+		return ColumnTypeInteger
+	case "text":
+		return ColumnTypeText
+	case "character varying":
+		if col.MaxLength != nil {
+			return ColumnType(fmt.Sprintf("VARCHAR(%d)", *col.MaxLength))
+		}
+		return ColumnTypeVarchar
+	case "boolean":
+		return ColumnTypeBoolean
+	case "timestamp without time zone":
+		return ColumnTypeTimestamp
+	case "timestamp with time zone":
+		return ColumnType("TIMESTAMPTZ")
+	case "date":
+		return ColumnTypeDate
+	case "jsonb":
+		return ColumnTypeJSON
+	case "double precision":
+		return ColumnTypeFloat
+	case "numeric":
+		return ColumnTypeNumeric
+	case "USER-DEFINED":
+		return ColumnType(strings.ToUpper(col.UDTName))
+	}
+	return ColumnType(strings.ToUpper(col.DataType))
+}
+
+// introspectedIndexType maps an access method to an index type; CockroachDB
+// reports its forward indexes as prefix and its inverted ones as inverted
+func introspectedIndexType(method string) IndexType {
+	switch strings.ToLower(method) {
+	case "hash":
+		return IndexTypeHash
+	case "gin", "inverted":
+		return IndexTypeGin
+	case "gist":
+		return IndexTypeGist
+	}
+	return IndexTypeBTree
+}
+
+// referentialActionCode maps a pg_constraint action code to its action
+func referentialActionCode(code string) ReferentialAction {
+	switch code {
+	case "r":
+		return ActionRestrict
+	case "c":
+		return ActionCascade
+	case "n":
+		return ActionSetNull
+	case "d":
+		return ActionSetDefault
+	}
+	return ActionNoAction
+}
+
+// DiffTableDefs compares def with actual, e.g. from IntrospectTable, like
+// DiffTable compares it with the table in the database, and their
+// constraints: foreign keys, matched by their columns, the unique flag of
+// the columns, the values of enum types and checks. The database rewrites
+// check expressions, so checks are matched by name, and the unnamed ones,
+// of columns too, only counted. Constraints of added or extra columns are
+// not reported again. A nil actual is a missing table.
+func DiffTableDefs(def, actual *TableDef) *TableDiff {
+	if actual == nil {
+		return diffTableDef(def, nil, nil)
+	}
+
+	columns := make([]existingColumn, len(actual.Columns))
+	for i, col := range actual.Columns {
+		columns[i] = existingColumn{Name: col.Name, DataType: string(col.Type), Nullable: !col.NotNull && !col.PrimaryKey}
+	}
+	indexes := make([]string, len(actual.Indexes))
+	for i, idx := range actual.Indexes {
+		indexes[i] = idx.Name
+	}
+	diff := diffTableDef(def, columns, indexes)
+	if !diff.Missing {
+		diff.ChangedConstraints = diffConstraints(def, actual)
+	}
+	return diff
+}
+
+// diffConstraints compares the constraints of the columns def and actual
+// share
+func diffConstraints(def, actual *TableDef) []ConstraintChange {
+	actualColumns := make(map[string]ColumnDef, len(actual.Columns))
+	for _, col := range actual.Columns {
+		actualColumns[col.Name] = col
+	}
+	shared := func(columns []string) bool {
+		return !slices.ContainsFunc(columns, func(name string) bool {
+			_, inActual := actualColumns[name]
+			inDef := slices.ContainsFunc(def.Columns, func(col ColumnDef) bool { return col.Name == name })
+			return !inActual || !inDef
+		})
+	}
+
+	var changes []ConstraintChange
+	live := slices.DeleteFunc(tableForeignKeys(actual), func(fk ForeignKeyDef) bool { return !shared(fk.Columns) })
+	for _, fk := range tableForeignKeys(def) {
+		if !shared(fk.Columns) {
+			continue
+		}
+		i := slices.IndexFunc(live, func(l ForeignKeyDef) bool { return slices.Equal(l.Columns, fk.Columns) })
+		if i < 0 {
+			changes = append(changes, ConstraintChange{Kind: "foreign key", Name: foreignKeyName(fk), Defined: foreignKeyClause(fk)})
+			continue
+		}
+		if l := live[i]; !foreignKeyMatches(fk, l) {
+			changes = append(changes, ConstraintChange{Kind: "foreign key", Name: foreignKeyName(fk), Defined: foreignKeyClause(fk), Actual: foreignKeyClause(l)})
+		}
+		live = slices.Delete(live, i, i+1)
+	}
+	for _, l := range live {
+		changes = append(changes, ConstraintChange{Kind: "foreign key", Name: foreignKeyName(l), Actual: foreignKeyClause(l)})
+	}
+
+	var definedChecks []string
+	for _, col := range def.Columns {
+		actualCol, ok := actualColumns[col.Name]
+		if !ok {
+			continue
+		}
+		if col.Check != "" {
+			definedChecks = append(definedChecks, col.Check)
+		}
+		if !col.PrimaryKey && col.Unique != actualCol.Unique {
+			changes = append(changes, ConstraintChange{Kind: "unique", Name: col.Name, Defined: uniqueClause(col.Unique), Actual: uniqueClause(actualCol.Unique)})
+		}
+		if col.Enum != nil && actualCol.Enum != nil && (col.Enum.Name != actualCol.Enum.Name || !slices.Equal(col.Enum.Values, actualCol.Enum.Values)) {
+			changes = append(changes, ConstraintChange{Kind: "enum", Name: col.Enum.Name, Defined: enumClause(col.Enum), Actual: enumClause(actualCol.Enum)})
+		}
+	}
+
+	actualChecks := slices.Clone(actual.Checks)
+	for _, check := range def.Checks {
+		if check.Name == "" {
+			definedChecks = append(definedChecks, check.Expr)
+			continue
+		}
+		i := slices.IndexFunc(actualChecks, func(c CheckDef) bool { return c.Name == check.Name })
+		if i < 0 {
+			changes = append(changes, ConstraintChange{Kind: "check", Name: check.Name, Defined: check.Expr})
+			continue
+		}
+		actualChecks = slices.Delete(actualChecks, i, i+1)
+	}
+	if len(definedChecks) != len(actualChecks) {
+		var exprs []string
+		for _, check := range actualChecks {
+			exprs = append(exprs, check.Expr)
+		}
+		changes = append(changes, ConstraintChange{Kind: "check", Defined: strings.Join(definedChecks, "; "), Actual: strings.Join(exprs, "; ")})
+	}
+	return changes
+}
+
+// tableForeignKeys returns the foreign keys of def, those of its columns
+// included
+func tableForeignKeys(def *TableDef) []ForeignKeyDef {
+	var fks []ForeignKeyDef
+	for _, col := range def.Columns {
+		if ref := col.References; ref != nil {
+			fks = append(fks, ForeignKeyDef{
+				Columns: []string{col.Name}, Table: ref.Table, RefColumns: columnList(ref.Column), OnDelete: ref.OnDelete, OnUpdate: ref.OnUpdate,
+			})
+		}
+	}
+	return append(fks, def.ForeignKeys...)
+}
+
+// foreignKeyMatches reports whether the live foreign key l satisfies fk,
+// whose referenced columns default to those of l
+func foreignKeyMatches(fk, l ForeignKeyDef) bool {
+	action := func(a ReferentialAction) ReferentialAction {
+		if a == "" {
+			return ActionNoAction
+		}
+		return a
+	}
+	return fk.Table == l.Table && (len(fk.RefColumns) == 0 || slices.Equal(fk.RefColumns, l.RefColumns)) &&
+		action(fk.OnDelete) == action(l.OnDelete) && action(fk.OnUpdate) == action(l.OnUpdate)
+}
+
+// foreignKeyName names a foreign key by its constraint, or its columns
+func foreignKeyName(fk ForeignKeyDef) string {
+	if fk.Name != "" {
+		return fk.Name
+	}
+	return strings.Join(fk.Columns, ", ")
+}
+
+// foreignKeyClause is the SQL of fk without its name
+func foreignKeyClause(fk ForeignKeyDef) string {
+	fk.Name = ""
+	return foreignKeySQL(fk)
+}
+
+// uniqueClause is the SQL of a unique flag
+func uniqueClause(unique bool) string {
+	if unique {
+		return "UNIQUE"
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package sietch
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// catalogQueryable answers the catalog queries of IntrospectTable
+type catalogQueryable struct {
+	Queryable
+	rows map[string][][]any // by the table the query reads
+}
+
+func (q *catalogQueryable) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, error) {
+	for from, values := range q.rows {
+		if strings.Contains(sql, "FROM "+from) {
+			return &catalogRows{fakeRows{values: values}}, nil
+		}
+	}
+	return &catalogRows{}, nil
+}
+
+// catalogRows adds the RawValues pgx.RowToStructByPos counts columns with
+type catalogRows struct {
+	fakeRows
+}
+
+func (r *catalogRows) RawValues() [][]byte {
+	return make([][]byte, len(r.values[r.next-1]))
+}
+
+func TestIntrospectTable(t *testing.T) {
+	ctx := context.Background()
+	length := int64(64)
+	zero := "0:::INT8"
+
+	q := &catalogQueryable{rows: map[string][][]any{
+		"information_schema.columns": {
+			{"id", "bigint", "int8", (*int64)(nil), false, (*string)(nil)},
+			{"user_id", "bigint", "int8", (*int64)(nil), false, (*string)(nil)},
+			{"status", "USER-DEFINED", "account_status", (*int64)(nil), false, (*string)(nil)},
+			{"nickname", "character varying", "varchar", &length, true, (*string)(nil)},
+			{"score", "bigint", "int8", (*int64)(nil), false, &zero},
+		},
+		"pg_constraint": {
+			{"accounts_pkey", "p", []string{"id"}, "", []string{}, "", "", "PRIMARY KEY (id ASC)"},
+			{"accounts_nickname_key", "u", []string{"nickname"}, "", []string{}, "", "", "UNIQUE (nickname ASC)"},
+			{"accounts_user_id_fkey", "f", []string{"user_id"}, "users", []string{"id"}, "c", "a", "FOREIGN KEY (user_id) REFERENCES users(id)"},
+			{"check_score", "c", []string{"score"}, "", []string{}, "", "", "CHECK ((score >= 0:::INT8))"},
+		},
+		"pg_index": {
+			{"accounts_pkey", "prefix", []string{"id"}, true, true, ""},
+			{"accounts_nickname_key", "prefix", []string{"nickname"}, true, false, ""},
+			{"idx_accounts_status", "prefix", []string{"status", "score"}, false, false, "score > 0"},
+		},
+		"pg_enum": {
+			{"account_status", []string{"active", "closed"}},
+		},
+	}}
+
+	def, err := IntrospectTable(ctx, q, "accounts")
+	if err != nil {
+		t.Fatalf("IntrospectTable failed: %v", err)
+	}
+	expected := &TableDef{
+		Name: "accounts",
+		Columns: []ColumnDef{
+			{Name: "id", Type: ColumnTypeBigInt, PrimaryKey: true, NotNull: true},
+			{Name: "user_id", Type: ColumnTypeBigInt, NotNull: true,
+				References: &ForeignKeyRef{Table: "users", Column: "id", OnDelete: ActionCascade, OnUpdate: ActionNoAction}},
+			{Name: "status", Type: ColumnTypeEnum, NotNull: true, Enum: &EnumDef{Name: "account_status", Values: []string{"active", "closed"}}},
+			{Name: "nickname", Type: ColumnType("VARCHAR(64)"), Unique: true},
+			{Name: "score", Type: ColumnTypeBigInt, NotNull: true, DefaultValue: "0:::INT8"},
+		},
+		Indexes: []IndexDef{{Name: "idx_accounts_status", Type: IndexTypeBTree, Columns: []string{"status", "score"}, Where: "score > 0"}},
+		Checks:  []CheckDef{{Name: "check_score", Expr: "(score >= 0:::INT8)"}},
+	}
+	if !reflect.DeepEqual(def, expected) {
+		t.Errorf("Expected:\n%+v\nGot:\n%+v", expected, def)
+	}
+
+	t.Run("Live tables compare with inferred definitions", func(t *testing.T) {
+		inferred := &TableDef{
+			Name: "accounts",
+			Columns: []ColumnDef{
+				{Name: "id", Type: ColumnTypeBigInt, PrimaryKey: true},
+				{Name: "user_id", Type: ColumnTypeInteger, NotNull: true, References: &ForeignKeyRef{Table: "users", OnDelete: ActionCascade}},
+				{Name: "status", Type: ColumnTypeEnum, NotNull: true, Enum: &EnumDef{Name: "account_status", Values: []string{"active", "closed"}}},
+				{Name: "nickname", Type: ColumnTypeText, Unique: true},
+				{Name: "score", Type: ColumnTypeInteger, NotNull: true, Check: `"score" >= 0`},
+			},
+			Indexes: []IndexDef{{Name: "idx_accounts_status"}},
+		}
+		if diff := DiffTableDefs(inferred, def); diff.HasChanges() {
+			t.Errorf("Expected no changes, got %+v", diff)
+		}
+		inferred.Columns[3].NotNull = true
+		if diff := DiffTableDefs(inferred, def); len(diff.ChangedColumns) != 1 || diff.ChangedColumns[0].Name != "nickname" {
+			t.Errorf("Expected nickname to change, got %+v", diff)
+		}
+		inferred.Columns[3].NotNull = false
+
+		inferred.Columns[1].References.OnDelete = ActionRestrict
+		inferred.Columns[2].Enum = &EnumDef{Name: "account_status", Values: []string{"active", "frozen", "closed"}}
+		inferred.Columns[3].Unique = false
+		inferred.Columns[4].Check = ""
+		inferred.ForeignKeys = []ForeignKeyDef{{Name: "fk_accounts_status", Columns: []string{"status", "score"}, Table: "statuses"}}
+		expected := []ConstraintChange{
+			{Kind: "foreign key", Name: "user_id", Defined: `FOREIGN KEY ("user_id") REFERENCES "users" ON DELETE RESTRICT`,
+				Actual: `FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE ON UPDATE NO ACTION`},
+			{Kind: "foreign key", Name: "fk_accounts_status", Defined: `FOREIGN KEY ("status", "score") REFERENCES "statuses"`},
+			{Kind: "enum", Name: "account_status", Defined: `"account_status" AS ENUM ('active', 'frozen', 'closed')`,
+				Actual: `"account_status" AS ENUM ('active', 'closed')`},
+			{Kind: "unique", Name: "nickname", Actual: "UNIQUE"},
+			{Kind: "check", Actual: "(score >= 0:::INT8)"},
+		}
+		if diff := DiffTableDefs(inferred, def); !reflect.DeepEqual(diff.ChangedConstraints, expected) || len(diff.Statements()) != 0 {
+			t.Errorf("Expected:\n%+v\nGot:\n%+v", expected, diff.ChangedConstraints)
+		}
+		if diff := DiffTableDefs(inferred, nil); !diff.Missing {
+			t.Errorf("Expected a nil table to be missing, got %+v", diff)
+		}
+	})
+
+	t.Run("Missing tables fail", func(t *testing.T) {
+		if _, err := IntrospectTable(ctx, &catalogQueryable{}, "missing"); err == nil {
+			t.Error("Expected an error for a missing table")
+		}
+	})
+}
@@ -0,0 +1,250 @@
+package sietch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffTableDef(t *testing.T) {
+	def := &TableDef{
+		Name: "accounts",
+		Columns: []ColumnDef{
+			{Name: "id", Type: ColumnTypeBigInt, PrimaryKey: true},
+			{Name: "balance", Type: ColumnTypeInteger, NotNull: true},
+			{Name: "email", Type: ColumnTypeText, NotNull: true, DefaultValue: "''"},
+			{Name: "nickname", Type: ColumnType("VARCHAR(64)")},
+			{Name: "score", Type: ColumnTypeFloat, NotNull: true},
+		},
+		Indexes: []IndexDef{
+			{Name: "idx_accounts_email", Type: IndexTypeBTree, Columns: []string{"email"}},
+			{Name: "idx_accounts_balance", Type: IndexTypeBTree, Columns: []string{"balance"}},
+		},
+	}
+
+	t.Run("Missing table", func(t *testing.T) {
+		diff := diffTableDef(def, nil, nil)
+		if !diff.Missing || !diff.HasChanges() {
+			t.Fatalf("Expected the table to be missing, got %+v", diff)
+		}
+		stmts := diff.Statements()
+		if len(stmts) != 3 || stmts[0] != GenerateCreateTableSQL(def) {
+			t.Errorf("Expected the table and its indexes to be created, got %v", stmts)
+		}
+	})
+
+	t.Run("Up to date table", func(t *testing.T) {
+		columns := []existingColumn{
+			{Name: "id", DataType: "bigint"},
+			{Name: "balance", DataType: "bigint"},
+			{Name: "email", DataType: "text"},
+			{Name: "nickname", DataType: "character varying", Nullable: true},
+			{Name: "score", DataType: "double precision"},
+		}
+		diff := diffTableDef(def, columns, []string{"accounts_pkey", "idx_accounts_email", "idx_accounts_balance"})
+		if diff.HasChanges() || len(diff.Statements()) != 0 {
+			t.Errorf("Expected no changes, got %+v", diff)
+		}
+	})
+
+	t.Run("Drifted table", func(t *testing.T) {
+		columns := []existingColumn{
+			{Name: "id", DataType: "bigint"},
+			{Name: "balance", DataType: "text"},
+			{Name: "nickname", DataType: "text"},
+			{Name: "legacy", DataType: "text", Nullable: true},
+		}
+		diff := diffTableDef(def, columns, []string{"idx_accounts_email"})
+
+		if !reflect.DeepEqual(diff.ExtraColumns, []string{"legacy"}) {
+			t.Errorf("Expected legacy to be extra, got %v", diff.ExtraColumns)
+		}
+		expectedChanges := []ColumnChange{
+			{Name: "balance", Type: ColumnTypeInteger, ActualType: "text", NotNull: true, ActualNotNull: true},
+			{Name: "nickname", Type: ColumnType("VARCHAR(64)"), ActualType: "text", NotNull: false, ActualNotNull: true},
+		}
+		if !reflect.DeepEqual(diff.ChangedColumns, expectedChanges) {
+			t.Errorf("Unexpected changes %+v", diff.ChangedColumns)
+		}
+
+		expected := []string{
+			`ALTER TABLE "accounts" ADD COLUMN "email" TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE "accounts" ADD COLUMN "score" FLOAT8 NOT NULL`,
+			`CREATE INDEX IF NOT EXISTS "idx_accounts_balance" ON "accounts" USING BTREE ("balance")`,
+		}
+		if stmts := diff.Statements(); !reflect.DeepEqual(stmts, expected) {
+			t.Errorf("Unexpected statements %v", stmts)
+		}
+	})
+}
+
+func TestInferTableDef_PKTag(t *testing.T) {
+	def, err := InferTableDef[taggedDocument]("documents")
+	if err != nil {
+		t.Fatalf("InferTableDef failed: %v", err)
+	}
+	for _, col := range def.Columns {
+		if col.PrimaryKey != (col.Name == "id") {
+			t.Errorf("Unexpected primary key flag on %s: %v", col.Name, col.PrimaryKey)
+		}
+	}
+}
+
+func TestInferTableDef_Embedded(t *testing.T) {
+	def, err := InferTableDef[embeddedCustomer]("customers")
+	if err != nil {
+		t.Fatalf("InferTableDef failed: %v", err)
+	}
+	var names []string
+	for _, col := range def.Columns {
+		names = append(names, col.Name)
+	}
+	if want := []string{"id", "created_at", "name", "updated_by"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Expected columns %v, got %v", want, names)
+	}
+	if !def.Columns[0].PrimaryKey || def.Columns[1].Type != ColumnTypeTimestamp {
+		t.Errorf("Expected the embedded id as primary key and a timestamp created_at, got %+v", def.Columns[:2])
+	}
+}
+
+type orderLine struct {
+	ID        int64  `db:"id"`
+	UserID    int64  `db:"user_id" fk:"users(id),ondelete=cascade"`
+	ProductID *int64 `db:"product_id" nullable:"true" fk:"products,ondelete=set_null,onupdate=restrict"`
+}
+
+func TestInferTableDef_ForeignKeys(t *testing.T) {
+	def, err := InferTableDef[orderLine]("order_lines")
+	if err != nil {
+		t.Fatalf("InferTableDef failed: %v", err)
+	}
+	def.ForeignKeys = []ForeignKeyDef{{
+		Name:       "fk_order_lines_order",
+		Columns:    []string{"id", "user_id"},
+		Table:      "orders",
+		RefColumns: []string{"line_id", "user_id"},
+		OnDelete:   ActionCascade,
+	}}
+
+	expected := "CREATE TABLE IF NOT EXISTS \"order_lines\" (\n" +
+		"  \"id\" BIGINT PRIMARY KEY,\n" +
+		"  \"user_id\" BIGINT NOT NULL REFERENCES \"users\" (\"id\") ON DELETE CASCADE,\n" +
+		"  \"product_id\" BIGINT REFERENCES \"products\" ON DELETE SET NULL ON UPDATE RESTRICT,\n" +
+		"  CONSTRAINT \"fk_order_lines_order\" FOREIGN KEY (\"id\", \"user_id\") REFERENCES \"orders\" (\"line_id\", \"user_id\") ON DELETE CASCADE\n" +
+		")"
+	if sql := GenerateCreateTableSQL(def); sql != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, sql)
+	}
+
+	t.Run("Invalid tags", func(t *testing.T) {
+		for _, tag := range []string{"users(id", "users(id)x", `users("id")`, "users(id),ondelete=drop", "users(id),oninsert=cascade", "(id)"} {
+			if _, err := parseForeignKeyTag(tag); err == nil {
+				t.Errorf("Expected %q to be rejected", tag)
+			}
+		}
+	})
+
+	t.Run("Invalid identifiers", func(t *testing.T) {
+		if err := def.validateConstraints(); err != nil {
+			t.Fatalf("Expected a valid definition, got %v", err)
+		}
+		invalid := []func(def *TableDef){
+			func(def *TableDef) { def.ForeignKeys[0].Name = `fk" CHECK (false) --` },
+			func(def *TableDef) { def.ForeignKeys[0].Table = `orders"; DROP TABLE users; --` },
+			func(def *TableDef) { def.ForeignKeys[0].RefColumns = []string{"line id"} },
+			func(def *TableDef) { def.Columns[1].References = &ForeignKeyRef{Table: `users" --`} },
+			func(def *TableDef) { def.Checks = []CheckDef{{Name: `ck"`, Expr: "id > 0"}} },
+		}
+		for i, mutate := range invalid {
+			def, _ := InferTableDef[orderLine]("order_lines")
+			def.ForeignKeys = []ForeignKeyDef{{Columns: []string{"id"}, Table: "orders"}}
+			mutate(def)
+			if err := def.validateConstraints(); err == nil {
+				t.Errorf("Expected definition %d to be rejected", i)
+			}
+		}
+	})
+}
+
+type enumAccount struct {
+	ID     int64  `db:"id"`
+	Status string `db:"status" enum:"account_status(active,closed)"`
+	Kind   string `db:"kind" enum:"personal, business"`
+	Score  int    `db:"score" validate:"required,min=0,max=100"`
+	Tier   string `db:"tier" validate:"oneof=free o'brien,max=16" check:"tier <> ''"`
+}
+
+func TestInferTableDef_EnumsAndChecks(t *testing.T) {
+	def, err := InferTableDef[enumAccount]("accounts")
+	if err != nil {
+		t.Fatalf("InferTableDef failed: %v", err)
+	}
+	def.Checks = []CheckDef{{Name: "score_tier", Expr: `"score" > 0 OR "tier" = 'free'`}}
+
+	expectedTypes := []string{
+		`DO $enum$ BEGIN CREATE TYPE "account_status" AS ENUM ('active', 'closed'); EXCEPTION WHEN duplicate_object THEN NULL; END $enum$`,
+		`DO $enum$ BEGIN CREATE TYPE "accounts_kind" AS ENUM ('personal', 'business'); EXCEPTION WHEN duplicate_object THEN NULL; END $enum$`,
+	}
+	var types []string
+	for _, enum := range def.Enums() {
+		types = append(types, GenerateCreateTypeSQL(enum))
+	}
+	if !reflect.DeepEqual(types, expectedTypes) {
+		t.Errorf("Unexpected types %v", types)
+	}
+
+	expected := "CREATE TABLE IF NOT EXISTS \"accounts\" (\n" +
+		"  \"id\" BIGINT PRIMARY KEY,\n" +
+		"  \"status\" \"account_status\" NOT NULL,\n" +
+		"  \"kind\" \"accounts_kind\" NOT NULL,\n" +
+		"  \"score\" INTEGER NOT NULL CHECK ((\"score\" >= 0) AND (\"score\" <= 100)),\n" +
+		"  \"tier\" TEXT NOT NULL CHECK ((tier <> '') AND (\"tier\" IN ('free', 'o''brien')) AND (char_length(\"tier\") <= 16)),\n" +
+		"  CONSTRAINT \"score_tier\" CHECK (\"score\" > 0 OR \"tier\" = 'free')\n" +
+		")"
+	if sql := GenerateCreateTableSQL(def); sql != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, sql)
+	}
+
+	t.Run("Missing tables create their types first", func(t *testing.T) {
+		stmts := diffTableDef(def, nil, nil).Statements()
+		if !reflect.DeepEqual(stmts, append(expectedTypes, expected)) {
+			t.Errorf("Unexpected statements %v", stmts)
+		}
+	})
+
+	t.Run("Enum columns match user-defined types", func(t *testing.T) {
+		columns := []existingColumn{
+			{Name: "id", DataType: "bigint"},
+			{Name: "status", DataType: "USER-DEFINED"},
+			{Name: "score", DataType: "bigint"},
+			{Name: "tier", DataType: "text"},
+		}
+		diff := diffTableDef(def, columns, nil)
+		if len(diff.ChangedColumns) != 0 {
+			t.Errorf("Unexpected changes %+v", diff.ChangedColumns)
+		}
+		expected := []string{expectedTypes[1], `ALTER TABLE "accounts" ADD COLUMN "kind" "accounts_kind" NOT NULL`}
+		if stmts := diff.Statements(); !reflect.DeepEqual(stmts, expected) {
+			t.Errorf("Unexpected statements %v", stmts)
+		}
+	})
+
+	t.Run("Invalid tags", func(t *testing.T) {
+		for _, tag := range []string{"status(active", `bad"name(a)`, "status()", ""} {
+			if _, err := parseEnumTag(tag, "accounts_status"); err == nil {
+				t.Errorf("Expected enum %q to be rejected", tag)
+			}
+		}
+		field := reflect.StructField{Name: "Score", Type: reflect.TypeOf(0), Tag: `validate:"min=0; DROP TABLE accounts"`}
+		if _, err := checkFromTags("score", field); err == nil {
+			t.Error("Expected a non-numeric min to be rejected")
+		}
+	})
+
+	t.Run("Dollar quotes in values", func(t *testing.T) {
+		sql := GenerateCreateTypeSQL(&EnumDef{Name: "quoted", Values: []string{"$enum$"}})
+		expected := `DO $enum1$ BEGIN CREATE TYPE "quoted" AS ENUM ('$enum$'); EXCEPTION WHEN duplicate_object THEN NULL; END $enum1$`
+		if sql != expected {
+			t.Errorf("Expected %s, got %s", expected, sql)
+		}
+	})
+}
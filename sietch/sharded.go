@@ -0,0 +1,449 @@
+package sietch
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// ShardingOptions configures a ShardedRepository
+type ShardingOptions struct {
+	// Hash maps an ID to a shard by its value modulo the number of shards.
+	// The default hashes strings with FNV-1a and integers with a bit mixer,
+	// so IDs map to the same shard in every process.
+	Hash func(id any) uint64
+}
+
+// ShardedRepository spreads entities over shards, typically connectors of
+// different pools, by the hash of their ID. Key-based operations run on the
+// shard of their ID; batch operations and GetMany split their items by shard
+// and run the shards concurrently. Query, FindOne, Count, GroupCount,
+// UpdateWhere and DeleteWhere fan out to every shard and merge the results,
+// sorting and paginating them as a single repository would.
+//
+// Operations spanning shards are not atomic: a failing shard does not undo
+// the writes of the others. Transactions are only available within a shard,
+// see Shard.
+//
+// Entities must stay on the shard of their ID, so changing the number of
+// shards or the hash needs the data to be moved.
+type ShardedRepository[T any, ID comparable] struct {
+	shards []Repository[T, ID]
+	getID  func(*T) ID
+	hash   func(id any) uint64
+}
+
+// NewShardedRepository creates a repository over shards, which must be
+// given in the same order everywhere since an ID maps to a shard index
+func NewShardedRepository[T any, ID comparable](shards []Repository[T, ID], getID func(*T) ID, opts ShardingOptions) (*ShardedRepository[T, ID], error) {
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("at least one shard is required")
+	}
+	if slices.Contains(shards, nil) {
+		return nil, fmt.Errorf("shards cannot be nil")
+	}
+	getID, err := resolveGetID(getID)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Hash == nil {
+		opts.Hash = stableHash
+	}
+	return &ShardedRepository[T, ID]{
+		shards: slices.Clone(shards),
+		getID:  getID,
+		hash:   opts.Hash,
+	}, nil
+}
+
+// stableHash hashes an ID independently of the process, unlike the
+// maphash used by the sharded InMemoryConnector
+func stableHash(id any) uint64 {
+	switch v := id.(type) {
+	case string:
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(v))
+		return h.Sum64()
+	case int:
+		return mixHash(uint64(v))
+	case int64:
+		return mixHash(uint64(v))
+	case int32:
+		return mixHash(uint64(v))
+	case uint:
+		return mixHash(uint64(v))
+	case uint64:
+		return mixHash(v)
+	case uint32:
+		return mixHash(uint64(v))
+	}
+	h := fnvWriter{fnv.New64a()}
+	writeHashValue(h, reflect.ValueOf(id))
+	return h.Sum64()
+}
+
+// fnvWriter adapts an FNV hash to writeHashValue
+type fnvWriter struct {
+	hash.Hash64
+}
+
+func (w fnvWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w fnvWriter) WriteByte(b byte) error {
+	_, err := w.Write([]byte{b})
+	return err
+}
+
+// shardIndex returns the index of the shard holding id
+func (r *ShardedRepository[T, ID]) shardIndex(id ID) int {
+	return int(r.hash(id) % uint64(len(r.shards)))
+}
+
+// Shard returns the shard holding id, e.g. to run a transaction on it
+func (r *ShardedRepository[T, ID]) Shard(id ID) Repository[T, ID] {
+	return r.shards[r.shardIndex(id)]
+}
+
+// Shards returns the shards in order
+func (r *ShardedRepository[T, ID]) Shards() []Repository[T, ID] {
+	return slices.Clone(r.shards)
+}
+
+// fanOut runs fn on the given shards concurrently, returning their results
+// by shard index and the errors joined
+func fanOut[R any, T any, ID comparable](shards []Repository[T, ID], indexes []int, fn func(i int, shard Repository[T, ID]) (R, error)) ([]R, error) {
+	results := make([]R, len(shards))
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for _, i := range indexes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = fn(i, shards[i])
+		}()
+	}
+	wg.Wait()
+	return results, errors.Join(errs...)
+}
+
+// allShards returns the index of every shard
+func (r *ShardedRepository[T, ID]) allShards() []int {
+	indexes := make([]int, len(r.shards))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// groupItems splits items by shard, returning the positions of the items of
+// every shard and the shards holding any
+func (r *ShardedRepository[T, ID]) groupItems(items []T) ([][]int, []int) {
+	positions := make([][]int, len(r.shards))
+	var used []int
+	for i := range items {
+		s := r.shardIndex(r.getID(&items[i]))
+		if positions[s] == nil {
+			used = append(used, s)
+		}
+		positions[s] = append(positions[s], i)
+	}
+	return positions, used
+}
+
+// batch runs a batch write split by shard. Items are copied back, as
+// backends may set fields like versions.
+func (r *ShardedRepository[T, ID]) batch(items []T, write func(Repository[T, ID], []T) error) error {
+	positions, used := r.groupItems(items)
+	_, err := fanOut(r.shards, used, func(i int, shard Repository[T, ID]) (struct{}, error) {
+		sub := make([]T, len(positions[i]))
+		for j, p := range positions[i] {
+			sub[j] = items[p]
+		}
+		err := write(shard, sub)
+		for j, p := range positions[i] {
+			items[p] = sub[j]
+		}
+		return struct{}{}, err
+	})
+	return err
+}
+
+// groupIDs splits ids by shard, returning the ids of every shard and the
+// shards holding any
+func (r *ShardedRepository[T, ID]) groupIDs(ids []ID) ([][]ID, []int) {
+	byShard := make([][]ID, len(r.shards))
+	var used []int
+	for _, id := range ids {
+		s := r.shardIndex(id)
+		if byShard[s] == nil {
+			used = append(used, s)
+		}
+		byShard[s] = append(byShard[s], id)
+	}
+	return byShard, used
+}
+
+func (r *ShardedRepository[T, ID]) Create(ctx context.Context, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	return r.Shard(r.getID(item)).Create(ctx, item)
+}
+
+func (r *ShardedRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	return r.Shard(id).Get(ctx, id)
+}
+
+func (r *ShardedRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	byShard, used := r.groupIDs(ids)
+	found, err := fanOut(r.shards, used, func(i int, shard Repository[T, ID]) (map[ID]*T, error) {
+		return shard.GetMany(ctx, byShard[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[ID]*T, len(ids))
+	for _, items := range found {
+		for id, item := range items {
+			result[id] = item
+		}
+	}
+	return result, nil
+}
+
+func (r *ShardedRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	return r.Shard(id).Exists(ctx, id)
+}
+
+func (r *ShardedRepository[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	return r.batch(items, func(shard Repository[T, ID], sub []T) error {
+		return shard.BatchCreate(ctx, sub)
+	})
+}
+
+// Query queries every shard and merges the results. Each shard returns at
+// most Offset+Limit items, which are sorted together before the page is
+// cut, so deep pages read more rows. Grouped queries are not supported.
+func (r *ShardedRepository[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
+	if filter != nil && (len(filter.GroupBy) > 0 || len(filter.Having) > 0) {
+		return nil, fmt.Errorf("%w: grouped queries across shards", ErrUnsupportedOperation)
+	}
+
+	var shardFilter *Filter
+	offset := 0
+	if filter != nil {
+		copied := *filter
+		if filter.Offset != nil {
+			offset = *filter.Offset
+		}
+		if filter.Limit != nil {
+			limit := offset + *filter.Limit
+			copied.Limit = &limit
+		}
+		copied.Offset = nil
+		shardFilter = &copied
+	}
+
+	found, err := fanOut(r.shards, r.allShards(), func(_ int, shard Repository[T, ID]) ([]T, error) {
+		return shard.Query(ctx, shardFilter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var results []T
+	for _, items := range found {
+		results = append(results, items...)
+	}
+	if filter == nil {
+		return results, nil
+	}
+
+	if filter.Distinct {
+		results = distinctResults(results)
+	}
+	if len(filter.Sort) > 0 {
+		results = sortResults(results, filter.Sort, nil)
+	}
+	if offset >= len(results) {
+		return []T{}, nil
+	}
+	results = results[offset:]
+	if filter.Limit != nil && *filter.Limit < len(results) {
+		results = results[:*filter.Limit]
+	}
+	return results, nil
+}
+
+func (r *ShardedRepository[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	return findOne(ctx, filter, r.Query)
+}
+
+func (r *ShardedRepository[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
+	return r.sum(func(shard Repository[T, ID]) (int64, error) {
+		return shard.Count(ctx, filter)
+	})
+}
+
+// sum runs fn on every shard and adds up the results
+func (r *ShardedRepository[T, ID]) sum(fn func(Repository[T, ID]) (int64, error)) (int64, error) {
+	counts, err := fanOut(r.shards, r.allShards(), func(_ int, shard Repository[T, ID]) (int64, error) {
+		return fn(shard)
+	})
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	return total, err
+}
+
+func (r *ShardedRepository[T, ID]) Update(ctx context.Context, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	return r.Shard(r.getID(item)).Update(ctx, item)
+}
+
+func (r *ShardedRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	return r.batch(items, func(shard Repository[T, ID], sub []T) error {
+		return shard.BatchUpdate(ctx, sub)
+	})
+}
+
+func (r *ShardedRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	return r.Shard(id).Delete(ctx, id)
+}
+
+func (r *ShardedRepository[T, ID]) BatchDelete(ctx context.Context, ids []ID) error {
+	byShard, used := r.groupIDs(ids)
+	_, err := fanOut(r.shards, used, func(i int, shard Repository[T, ID]) (struct{}, error) {
+		return struct{}{}, shard.BatchDelete(ctx, byShard[i])
+	})
+	return err
+}
+
+func (r *ShardedRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	return r.Shard(r.getID(item)).Upsert(ctx, item)
+}
+
+func (r *ShardedRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	return r.batch(items, func(shard Repository[T, ID], sub []T) error {
+		return shard.BatchUpsert(ctx, sub)
+	})
+}
+
+// UpdateWhere updates the matching items of every shard. The counts of the
+// shards that succeeded are returned along with any error.
+func (r *ShardedRepository[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	return r.sum(func(shard Repository[T, ID]) (int64, error) {
+		return shard.UpdateWhere(ctx, filter, updates)
+	})
+}
+
+// DeleteWhere deletes the matching items of every shard. The counts of the
+// shards that succeeded are returned along with any error.
+func (r *ShardedRepository[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	return r.sum(func(shard Repository[T, ID]) (int64, error) {
+		return shard.DeleteWhere(ctx, filter)
+	})
+}
+
+// GroupCount groups every shard, which must implement Grouper, and adds up
+// the counts of equal groups. Sort, Offset and Limit apply to the merged
+// groups; Having is not supported, as groups are only complete once merged.
+func (r *ShardedRepository[T, ID]) GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error) {
+	if filter == nil || len(filter.GroupBy) == 0 {
+		return nil, fmt.Errorf("GroupCount requires GROUP BY fields")
+	}
+	if len(filter.Having) > 0 {
+		return nil, fmt.Errorf("%w: HAVING across shards", ErrUnsupportedOperation)
+	}
+	for _, sf := range filter.Sort {
+		if sf.Field != CountField && !containsString(filter.GroupBy, sf.Field) {
+			return nil, fmt.Errorf("cannot sort by '%s': not in GROUP BY", sf.Field)
+		}
+	}
+
+	shardFilter := *filter
+	shardFilter.Sort, shardFilter.Limit, shardFilter.Offset = nil, nil, nil
+	found, err := fanOut(r.shards, r.allShards(), func(_ int, shard Repository[T, ID]) ([]GroupCount, error) {
+		grouper, ok := shard.(Grouper)
+		if !ok {
+			return nil, ErrUnsupportedOperation
+		}
+		return grouper.GroupCount(ctx, &shardFilter)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]int)
+	var groups []GroupCount
+	for _, counts := range found {
+		for _, g := range counts {
+			values := make([]any, len(filter.GroupBy))
+			for i, field := range filter.GroupBy {
+				values[i] = g.Key[field]
+			}
+			k := fmt.Sprintf("%#v", values)
+			if i, ok := byKey[k]; ok {
+				groups[i].Count += g.Count
+				continue
+			}
+			byKey[k] = len(groups)
+			groups = append(groups, g)
+		}
+	}
+
+	if len(filter.Sort) > 0 {
+		slices.SortStableFunc(groups, func(a, b GroupCount) int {
+			for _, sf := range filter.Sort {
+				var c int
+				if sf.Field == CountField {
+					c = cmp.Compare(a.Count, b.Count)
+				} else {
+					c = compare(a.Key[sf.Field], b.Key[sf.Field])
+				}
+				if sf.Direction == SortDesc {
+					c = -c
+				}
+				if c != 0 {
+					return c
+				}
+			}
+			return 0
+		})
+	}
+	if filter.Offset != nil {
+		groups = groups[min(*filter.Offset, len(groups)):]
+	}
+	if filter.Limit != nil && *filter.Limit < len(groups) {
+		groups = groups[:*filter.Limit]
+	}
+	return groups, nil
+}
+
+// GetForUpdate locks the row on its shard if it implements RowLocker
+func (r *ShardedRepository[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
+	locker, ok := r.Shard(id).(RowLocker[T, ID])
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	return locker.GetForUpdate(ctx, id)
+}
+
+// WithTx is not supported, as a transaction cannot span shards. Run
+// single-shard transactions on Shard(id) instead.
+func (r *ShardedRepository[T, ID]) WithTx(ctx context.Context, fn TxFunc[T, ID]) error {
+	return fmt.Errorf("%w: transactions across shards, use Shard", ErrUnsupportedOperation)
+}
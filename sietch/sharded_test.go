@@ -0,0 +1,136 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestShardedRepository(t *testing.T) {
+	ctx := context.Background()
+	getID := func(a *testutils.Account) int64 { return a.ID }
+	newShards := func() []Repository[testutils.Account, int64] {
+		shards := make([]Repository[testutils.Account, int64], 3)
+		for i := range shards {
+			shards[i] = NewInMemoryConnector[testutils.Account](getID)
+		}
+		return shards
+	}
+	byID := ShardingOptions{Hash: func(id any) uint64 { return uint64(id.(int64)) }}
+
+	t.Run("Routes by ID", func(t *testing.T) {
+		shards := newShards()
+		repo, err := NewShardedRepository(shards, getID, byID)
+		if err != nil {
+			t.Fatalf("NewShardedRepository failed: %v", err)
+		}
+
+		accounts := make([]testutils.Account, 9)
+		for i := range accounts {
+			accounts[i] = testutils.Account{ID: int64(i), Balance: i * 10}
+		}
+		if err := repo.BatchCreate(ctx, accounts); err != nil {
+			t.Fatalf("BatchCreate failed: %v", err)
+		}
+		for i, shard := range shards {
+			if n, _ := shard.Count(ctx, nil); n != 3 {
+				t.Errorf("Expected 3 items on shard %d, got %d", i, n)
+			}
+			if exists, _ := shard.Exists(ctx, int64(i+3)); !exists {
+				t.Errorf("Expected item %d on shard %d", i+3, i)
+			}
+		}
+
+		if got, err := repo.Get(ctx, 4); err != nil || got.Balance != 40 {
+			t.Errorf("Expected item 4, got %+v (%v)", got, err)
+		}
+		if repo.Shard(5) != shards[2] {
+			t.Error("Expected Shard to return the shard of the ID")
+		}
+		found, err := repo.GetMany(ctx, []int64{1, 2, 3, 42})
+		if err != nil || len(found) != 3 {
+			t.Errorf("Expected 3 items, got %v (%v)", found, err)
+		}
+
+		if err := repo.BatchDelete(ctx, []int64{0, 1}); err != nil {
+			t.Fatalf("BatchDelete failed: %v", err)
+		}
+		if n, _ := repo.Count(ctx, nil); n != 7 {
+			t.Errorf("Expected 7 items, got %d", n)
+		}
+		n, err := repo.UpdateWhere(ctx, NewFilter().Where("balance", OpGreaterThan, 50).Build(), map[string]any{"balance": 0})
+		if err != nil || n != 3 {
+			t.Errorf("Expected 3 updates, got %d (%v)", n, err)
+		}
+	})
+
+	t.Run("Merges sorted pages", func(t *testing.T) {
+		repo, _ := NewShardedRepository(newShards(), getID, ShardingOptions{})
+		for i := range 20 {
+			_ = repo.Create(ctx, &testutils.Account{ID: int64(i), Balance: (i * 7) % 20})
+		}
+
+		filter := NewFilter().OrderBy("balance", SortDesc).Limit(4).Offset(3).Build()
+		results, err := repo.Query(ctx, filter)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		var balances []int
+		for _, a := range results {
+			balances = append(balances, a.Balance)
+		}
+		if !reflect.DeepEqual(balances, []int{16, 15, 14, 13}) {
+			t.Errorf("Expected the fourth to seventh balances, got %v", balances)
+		}
+
+		first, err := repo.FindOne(ctx, NewFilter().OrderBy("balance", SortAsc).Build())
+		if err != nil || first.Balance != 0 {
+			t.Errorf("Expected the lowest balance, got %+v (%v)", first, err)
+		}
+		if _, err := repo.Query(ctx, NewFilter().GroupBy("balance").Build()); !errors.Is(err, ErrUnsupportedOperation) {
+			t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+		}
+	})
+
+	t.Run("Merges groups", func(t *testing.T) {
+		repo, _ := NewShardedRepository(newShards(), getID, ShardingOptions{})
+		for i := range 10 {
+			_ = repo.Create(ctx, &testutils.Account{ID: int64(i), Balance: i % 3})
+		}
+
+		groups, err := repo.GroupCount(ctx, NewFilter().GroupBy("balance").OrderBy(CountField, SortDesc).Limit(2).Build())
+		if err != nil {
+			t.Fatalf("GroupCount failed: %v", err)
+		}
+		if len(groups) != 2 || groups[0].Key["balance"] != 0 || groups[0].Count != 4 || groups[1].Count != 3 {
+			t.Errorf("Unexpected groups %+v", groups)
+		}
+	})
+
+	t.Run("Stable hash", func(t *testing.T) {
+		if stableHash("account-1") != stableHash("account-1") || stableHash(int64(7)) != mixHash(7) {
+			t.Error("Expected the hash to depend on the ID only")
+		}
+		type compositeID struct {
+			Org  string
+			User int64
+		}
+		if stableHash(compositeID{"a", 1}) == stableHash(compositeID{"a", 2}) {
+			t.Error("Expected different composite IDs to hash differently")
+		}
+	})
+
+	t.Run("Transactions", func(t *testing.T) {
+		repo, _ := NewShardedRepository(newShards(), getID, ShardingOptions{})
+		err := repo.WithTx(ctx, func(Repository[testutils.Account, int64]) error { return nil })
+		if !errors.Is(err, ErrUnsupportedOperation) {
+			t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+		}
+		if _, err := NewShardedRepository(nil, getID, ShardingOptions{}); err == nil {
+			t.Error("Expected an error without shards")
+		}
+	})
+}
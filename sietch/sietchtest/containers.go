@@ -0,0 +1,189 @@
+// Package sietchtest starts CockroachDB and Redis containers with
+// testcontainers-go and hands back ready sietch connectors, so integration
+// tests run against real services instead of skipping when none is running
+// locally. Containers and tables are removed when the test ends.
+//
+// Example:
+//
+//	func TestAccounts(t *testing.T) {
+//	    db := sietchtest.StartCockroachDB(t)
+//	    repo := sietchtest.NewCockroachDBConnector[Account, int64](t, db, "accounts", nil)
+//	    ...
+//	}
+//
+// A Docker daemon is required; without one the helpers fail the test.
+package sietchtest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/seb7887/gofw/sietch"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/cockroachdb"
+	tcredis "github.com/testcontainers/testcontainers-go/modules/redis"
+)
+
+const (
+	// DefaultCockroachDBImage is the image StartCockroachDB runs by default
+	DefaultCockroachDBImage = "cockroachdb/cockroach:latest-v23.1"
+
+	// DefaultRedisImage is the image StartRedis runs by default
+	DefaultRedisImage = "redis:7"
+
+	// RedisStackImage is a Redis with the RediSearch and RedisJSON modules,
+	// for the search indexes of RedisConnector
+	RedisStackImage = "redis/redis-stack-server:7.2.0-v10"
+)
+
+// ContainerConfig configures a container started by StartCockroachDB or
+// StartRedis
+type ContainerConfig struct {
+	// Image overrides the default image
+	Image string
+
+	// Options customize the container request, e.g. cockroachdb.WithDatabase
+	Options []testcontainers.ContainerCustomizer
+}
+
+// CockroachDB is a running CockroachDB container
+type CockroachDB struct {
+	Container *cockroachdb.CockroachDBContainer
+	Pool      *pgxpool.Pool
+}
+
+// StartCockroachDB starts an insecure single-node CockroachDB container and
+// connects a pool to it. Both are closed when the test ends.
+func StartCockroachDB(t testing.TB, config ...ContainerConfig) *CockroachDB {
+	t.Helper()
+	ctx := context.Background()
+	cfg := containerConfig(config, DefaultCockroachDBImage)
+
+	opts := append([]testcontainers.ContainerCustomizer{cockroachdb.WithInsecure()}, cfg.Options...)
+	container, err := cockroachdb.Run(ctx, cfg.Image, opts...)
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("failed to start CockroachDB: %v", err)
+	}
+
+	connConfig, err := container.ConnectionConfig(ctx)
+	if err != nil {
+		t.Fatalf("failed to read the CockroachDB connection config: %v", err)
+	}
+	poolConfig, err := pgxpool.ParseConfig("")
+	if err != nil {
+		t.Fatalf("failed to configure the CockroachDB pool: %v", err)
+	}
+	poolConfig.ConnConfig = connConfig
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		t.Fatalf("failed to connect to CockroachDB: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return &CockroachDB{Container: container, Pool: pool}
+}
+
+// CreateTable creates table, and the enum types it uses, from the db tags of
+// T, and drops it when the test ends
+func CreateTable[T any](t testing.TB, db *CockroachDB, table string) {
+	t.Helper()
+	ctx := context.Background()
+
+	def, err := sietch.InferTableDef[T](table)
+	if err != nil {
+		t.Fatalf("failed to infer the definition of %s: %v", table, err)
+	}
+	for _, enum := range def.Enums() {
+		if _, err := db.Pool.Exec(ctx, sietch.GenerateCreateTypeSQL(enum)); err != nil {
+			t.Fatalf("failed to create the type %s: %v", enum.Name, err)
+		}
+	}
+	if _, err := db.Pool.Exec(ctx, sietch.GenerateCreateTableSQL(def)); err != nil {
+		t.Fatalf("failed to create %s: %v", table, err)
+	}
+
+	t.Cleanup(func() {
+		if _, err := db.Pool.Exec(context.Background(), sietch.GenerateDropTableSQL(table)); err != nil {
+			t.Errorf("failed to drop %s: %v", table, err)
+		}
+	})
+}
+
+// NewCockroachDBConnector creates table from the db tags of T and returns a
+// connector on it; getID may be nil to use the pk-tagged field
+func NewCockroachDBConnector[T any, ID comparable](t testing.TB, db *CockroachDB, table string, getID func(*T) ID) *sietch.CockroachDBConnector[T, ID] {
+	t.Helper()
+	CreateTable[T](t, db, table)
+
+	connector, err := sietch.NewCockroachDBConnector[T, ID](db.Pool, table, getID)
+	if err != nil {
+		t.Fatalf("failed to create the connector of %s: %v", table, err)
+	}
+	return connector
+}
+
+// Redis is a running Redis container
+type Redis struct {
+	Container *tcredis.RedisContainer
+	Client    *redis.Client
+}
+
+// StartRedis starts a Redis container and connects a client to it. Both are
+// closed when the test ends.
+func StartRedis(t testing.TB, config ...ContainerConfig) *Redis {
+	t.Helper()
+	ctx := context.Background()
+	cfg := containerConfig(config, DefaultRedisImage)
+
+	container, err := tcredis.Run(ctx, cfg.Image, cfg.Options...)
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("failed to start Redis: %v", err)
+	}
+
+	uri, err := container.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("failed to read the Redis connection string: %v", err)
+	}
+	opts, err := redis.ParseURL(uri)
+	if err != nil {
+		t.Fatalf("failed to parse the Redis connection string: %v", err)
+	}
+	client := redis.NewClient(opts)
+	t.Cleanup(func() { _ = client.Close() })
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+	return &Redis{Container: container, Client: client}
+}
+
+// NewRedisConnector returns a connector on the Redis of r storing T under
+// prefix:id, after flushing the database so every test starts empty; getID
+// may be nil to use the pk-tagged field
+func NewRedisConnector[T any, ID comparable](t testing.TB, r *Redis, prefix string, ttl time.Duration, getID func(*T) ID) *sietch.RedisConnector[T, ID] {
+	t.Helper()
+	if err := r.Client.FlushDB(context.Background()).Err(); err != nil {
+		t.Fatalf("failed to flush Redis: %v", err)
+	}
+	return sietch.NewRedisConnector[T, ID](r.Client, ttl, getID, func(id ID) string {
+		return fmt.Sprintf("%s:%v", prefix, id)
+	})
+}
+
+// containerConfig returns the first of config with image as its default
+func containerConfig(config []ContainerConfig, image string) ContainerConfig {
+	var cfg ContainerConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Image == "" {
+		cfg.Image = image
+	}
+	return cfg
+}
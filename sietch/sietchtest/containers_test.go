@@ -0,0 +1,49 @@
+package sietchtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/sietch/sietchtest"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+type ticket struct {
+	ID     int64  `db:"id,pk"`
+	Status string `db:"status" enum:"ticket_status(open,closed)"`
+	Seats  int    `db:"seats" validate:"min=1"`
+}
+
+func TestContainers(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx := context.Background()
+
+	t.Run("CockroachDB", func(t *testing.T) {
+		db := sietchtest.StartCockroachDB(t)
+		repo := sietchtest.NewCockroachDBConnector[ticket, int64](t, db, "tickets", nil)
+
+		if err := repo.Create(ctx, &ticket{ID: 1, Status: "open", Seats: 2}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if err := repo.Create(ctx, &ticket{ID: 2, Status: "open", Seats: 0}); err == nil {
+			t.Error("Expected the seats check to reject the ticket")
+		}
+		got, err := repo.Get(ctx, 1)
+		if err != nil || got.Status != "open" {
+			t.Errorf("Unexpected ticket %+v (%v)", got, err)
+		}
+	})
+
+	t.Run("Redis", func(t *testing.T) {
+		r := sietchtest.StartRedis(t)
+		repo := sietchtest.NewRedisConnector[ticket, int64](t, r, "ticket", time.Minute, nil)
+
+		if err := repo.Create(ctx, &ticket{ID: 1, Status: "open", Seats: 2}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if got, err := repo.Get(ctx, 1); err != nil || got.Seats != 2 {
+			t.Errorf("Unexpected ticket %+v (%v)", got, err)
+		}
+	})
+}
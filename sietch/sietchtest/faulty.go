@@ -0,0 +1,317 @@
+package sietchtest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/seb7887/gofw/sietch"
+)
+
+// ErrInjectedFault matches every failure injected by a FaultyRepository
+var ErrInjectedFault = sietch.ErrInjectedFault
+
+// FaultError is the default failure injected by a FaultyRepository. It
+// matches ErrInjectedFault, so sietch.IsTransientError treats it as
+// transient and RetryingRepository retries it, creates included.
+type FaultError struct {
+	Operation string
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("sietchtest: injected fault in %s", e.Operation)
+}
+
+func (e *FaultError) Is(target error) bool { return target == ErrInjectedFault }
+
+// FaultConfig configures the faults a FaultyRepository injects
+type FaultConfig struct {
+	// Latency is the fixed delay before every operation
+	Latency time.Duration
+
+	// Jitter is the maximum random delay added to Latency
+	Jitter time.Duration
+
+	// ErrorRate is the probability (0.0-1.0) of failing an operation
+	ErrorRate float64
+
+	// ErrorRates overrides ErrorRate per operation, by method name
+	// (e.g. "Get", "BatchUpsert")
+	ErrorRates map[string]float64
+
+	// Err is the error injected by ErrorRate and ErrorRates, a FaultError
+	// if nil
+	Err error
+
+	// Seed makes the random failures and jitter reproducible; 0 seeds from
+	// the clock
+	Seed int64
+}
+
+// scriptedFault fails the next remaining calls of operations, of every
+// operation if empty
+type scriptedFault struct {
+	remaining  int
+	err        error
+	operations []string
+}
+
+// FaultyRepository wraps a repository and injects latency and failures
+// into its operations, to test the retry, fallback and caching behavior of
+// services against a misbehaving backend:
+//
+//	repo := sietchtest.NewFaultyRepository[Account, int64](base, sietchtest.FaultConfig{
+//	    Latency:    5 * time.Millisecond,
+//	    ErrorRates: map[string]float64{"Get": 0.2},
+//	})
+//	repo.FailNext(2, nil, "Update") // the next two updates fail
+//
+// Failed operations never reach the base repository. Latency is cut short
+// by the cancellation of the context, which the operation then returns.
+type FaultyRepository[T any, ID comparable] struct {
+	base sietch.Repository[T, ID]
+
+	mu     sync.Mutex
+	config FaultConfig
+	rng    *rand.Rand
+	script []*scriptedFault
+	calls  map[string]int
+	faults map[string]int
+}
+
+// NewFaultyRepository creates a repository injecting the faults of config
+// into the operations of base
+func NewFaultyRepository[T any, ID comparable](base sietch.Repository[T, ID], config FaultConfig) *FaultyRepository[T, ID] {
+	r := &FaultyRepository[T, ID]{base: base}
+	r.SetConfig(config)
+	return r
+}
+
+// SetConfig replaces the fault configuration and reseeds the random
+// failures; the scripted failures are kept
+func (r *FaultyRepository[T, ID]) SetConfig(config FaultConfig) {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config = config
+	r.rng = rand.New(rand.NewSource(seed))
+}
+
+// FailNext makes the next n calls of operations, of any operation if none
+// are given, fail with err, a FaultError if nil; n <= 0 fails none.
+// Scripted failures apply before the error rates, in the order they were
+// added.
+func (r *FaultyRepository[T, ID]) FailNext(n int, err error, operations ...string) {
+	if n <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.script = append(r.script, &scriptedFault{remaining: n, err: err, operations: operations})
+}
+
+// Calls returns the number of calls of operation, failed ones included
+func (r *FaultyRepository[T, ID]) Calls(operation string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[operation]
+}
+
+// Faults returns the number of calls of operation that were failed
+func (r *FaultyRepository[T, ID]) Faults(operation string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.faults[operation]
+}
+
+// Reset drops the scripted failures and the call counts
+func (r *FaultyRepository[T, ID]) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.script = nil
+	r.calls = nil
+	r.faults = nil
+}
+
+// inject waits for the latency of operation and returns the failure to
+// inject, if any
+func (r *FaultyRepository[T, ID]) inject(ctx context.Context, operation string) error {
+	delay, err := r.decide(operation)
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+// decide counts a call of operation and draws its latency and failure
+func (r *FaultyRepository[T, ID]) decide(operation string) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.calls == nil {
+		r.calls = make(map[string]int)
+		r.faults = make(map[string]int)
+	}
+	r.calls[operation]++
+
+	delay := r.config.Latency
+	if r.config.Jitter > 0 {
+		delay += time.Duration(r.rng.Int63n(int64(r.config.Jitter)))
+	}
+
+	var err error
+	if i := slices.IndexFunc(r.script, func(f *scriptedFault) bool {
+		return len(f.operations) == 0 || slices.Contains(f.operations, operation)
+	}); i >= 0 {
+		fault := r.script[i]
+		if fault.remaining--; fault.remaining <= 0 {
+			r.script = slices.Delete(r.script, i, i+1)
+		}
+		err = fault.err
+		if err == nil {
+			err = &FaultError{Operation: operation}
+		}
+	} else if r.rng.Float64() < r.errorRate(operation) {
+		err = r.config.Err
+		if err == nil {
+			err = &FaultError{Operation: operation}
+		}
+	}
+	if err != nil {
+		r.faults[operation]++
+	}
+	return delay, err
+}
+
+// errorRate returns the probability of failing operation
+func (r *FaultyRepository[T, ID]) errorRate(operation string) float64 {
+	if rate, ok := r.config.ErrorRates[operation]; ok {
+		return rate
+	}
+	return r.config.ErrorRate
+}
+
+func (r *FaultyRepository[T, ID]) Create(ctx context.Context, item *T) error {
+	if err := r.inject(ctx, "Create"); err != nil {
+		return err
+	}
+	return r.base.Create(ctx, item)
+}
+
+func (r *FaultyRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	if err := r.inject(ctx, "Get"); err != nil {
+		return nil, err
+	}
+	return r.base.Get(ctx, id)
+}
+
+func (r *FaultyRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	if err := r.inject(ctx, "GetMany"); err != nil {
+		return nil, err
+	}
+	return r.base.GetMany(ctx, ids)
+}
+
+func (r *FaultyRepository[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	if err := r.inject(ctx, "BatchCreate"); err != nil {
+		return err
+	}
+	return r.base.BatchCreate(ctx, items)
+}
+
+func (r *FaultyRepository[T, ID]) Query(ctx context.Context, filter *sietch.Filter) ([]T, error) {
+	if err := r.inject(ctx, "Query"); err != nil {
+		return nil, err
+	}
+	return r.base.Query(ctx, filter)
+}
+
+func (r *FaultyRepository[T, ID]) FindOne(ctx context.Context, filter *sietch.Filter) (*T, error) {
+	if err := r.inject(ctx, "FindOne"); err != nil {
+		return nil, err
+	}
+	return r.base.FindOne(ctx, filter)
+}
+
+func (r *FaultyRepository[T, ID]) Update(ctx context.Context, item *T) error {
+	if err := r.inject(ctx, "Update"); err != nil {
+		return err
+	}
+	return r.base.Update(ctx, item)
+}
+
+func (r *FaultyRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	if err := r.inject(ctx, "BatchUpdate"); err != nil {
+		return err
+	}
+	return r.base.BatchUpdate(ctx, items)
+}
+
+func (r *FaultyRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	if err := r.inject(ctx, "Delete"); err != nil {
+		return err
+	}
+	return r.base.Delete(ctx, id)
+}
+
+func (r *FaultyRepository[T, ID]) BatchDelete(ctx context.Context, ids []ID) error {
+	if err := r.inject(ctx, "BatchDelete"); err != nil {
+		return err
+	}
+	return r.base.BatchDelete(ctx, ids)
+}
+
+func (r *FaultyRepository[T, ID]) Count(ctx context.Context, filter *sietch.Filter) (int64, error) {
+	if err := r.inject(ctx, "Count"); err != nil {
+		return 0, err
+	}
+	return r.base.Count(ctx, filter)
+}
+
+func (r *FaultyRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	if err := r.inject(ctx, "Exists"); err != nil {
+		return false, err
+	}
+	return r.base.Exists(ctx, id)
+}
+
+func (r *FaultyRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	if err := r.inject(ctx, "Upsert"); err != nil {
+		return err
+	}
+	return r.base.Upsert(ctx, item)
+}
+
+func (r *FaultyRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	if err := r.inject(ctx, "BatchUpsert"); err != nil {
+		return err
+	}
+	return r.base.BatchUpsert(ctx, items)
+}
+
+func (r *FaultyRepository[T, ID]) UpdateWhere(ctx context.Context, filter *sietch.Filter, updates map[string]any) (int64, error) {
+	if err := r.inject(ctx, "UpdateWhere"); err != nil {
+		return 0, err
+	}
+	return r.base.UpdateWhere(ctx, filter, updates)
+}
+
+func (r *FaultyRepository[T, ID]) DeleteWhere(ctx context.Context, filter *sietch.Filter) (int64, error) {
+	if err := r.inject(ctx, "DeleteWhere"); err != nil {
+		return 0, err
+	}
+	return r.base.DeleteWhere(ctx, filter)
+}
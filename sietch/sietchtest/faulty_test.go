@@ -0,0 +1,117 @@
+package sietchtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/sietch"
+	"github.com/seb7887/gofw/sietch/sietchtest"
+)
+
+type noBackoff struct{}
+
+func (noBackoff) Next(int) time.Duration { return 0 }
+
+func newFaultyRepository(t *testing.T, config sietchtest.FaultConfig) *sietchtest.FaultyRepository[ticket, int64] {
+	t.Helper()
+	base := sietch.NewInMemoryConnector[ticket, int64](nil)
+	if err := base.Create(context.Background(), &ticket{ID: 1, Status: "open", Seats: 2}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return sietchtest.NewFaultyRepository[ticket, int64](base, config)
+}
+
+func TestFaultyRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Scripted failures", func(t *testing.T) {
+		repo := newFaultyRepository(t, sietchtest.FaultConfig{})
+		custom := errors.New("boom")
+		repo.FailNext(0, nil)
+		repo.FailNext(-1, custom)
+		repo.FailNext(2, nil, "Get")
+		repo.FailNext(1, custom)
+
+		if _, err := repo.Get(ctx, 1); !errors.Is(err, sietchtest.ErrInjectedFault) || !sietch.IsTransientError(err) {
+			t.Errorf("Expected a transient injected fault, got %v", err)
+		}
+		if err := repo.Update(ctx, &ticket{ID: 1, Status: "closed", Seats: 2}); !errors.Is(err, custom) {
+			t.Errorf("Expected the scripted error, got %v", err)
+		}
+		if _, err := repo.Get(ctx, 1); !errors.Is(err, sietchtest.ErrInjectedFault) {
+			t.Errorf("Expected the second scripted fault, got %v", err)
+		}
+		if got, err := repo.Get(ctx, 1); err != nil || got.Status != "open" {
+			t.Errorf("Expected the failed update not to reach the base, got %+v (%v)", got, err)
+		}
+		if repo.Calls("Get") != 3 || repo.Faults("Get") != 2 || repo.Faults("Update") != 1 {
+			t.Errorf("Unexpected counts: %d Get calls, %d Get faults, %d Update faults",
+				repo.Calls("Get"), repo.Faults("Get"), repo.Faults("Update"))
+		}
+	})
+
+	t.Run("Error rates per operation", func(t *testing.T) {
+		repo := newFaultyRepository(t, sietchtest.FaultConfig{
+			ErrorRate:  1,
+			ErrorRates: map[string]float64{"Exists": 0},
+			Err:        sietch.ErrConstraintViolation,
+		})
+		for range 10 {
+			if _, err := repo.Exists(ctx, 1); err != nil {
+				t.Fatalf("Expected Exists never to fail, got %v", err)
+			}
+			if _, err := repo.Count(ctx, sietch.NewFilter().Build()); !errors.Is(err, sietch.ErrConstraintViolation) {
+				t.Fatalf("Expected Count always to fail, got %v", err)
+			}
+		}
+	})
+
+	t.Run("Seeded rates are reproducible", func(t *testing.T) {
+		failures := func() []bool {
+			repo := newFaultyRepository(t, sietchtest.FaultConfig{ErrorRate: 0.5, Seed: 42})
+			var failed []bool
+			for range 20 {
+				_, err := repo.Get(ctx, 1)
+				failed = append(failed, err != nil)
+			}
+			return failed
+		}
+		first, second := failures(), failures()
+		for i := range first {
+			if first[i] != second[i] {
+				t.Fatalf("Expected the same failures with the same seed, got %v and %v", first, second)
+			}
+		}
+	})
+
+	t.Run("Latency honors the context", func(t *testing.T) {
+		repo := newFaultyRepository(t, sietchtest.FaultConfig{Latency: time.Hour})
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := repo.Get(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the deadline to cut the latency short, got %v", err)
+		}
+	})
+
+	t.Run("Retries recover from injected faults", func(t *testing.T) {
+		repo := newFaultyRepository(t, sietchtest.FaultConfig{})
+		retrying, err := sietch.NewRetryingRepository[ticket, int64](repo, sietch.RetryConfig{MaxAttempts: 3, Backoff: noBackoff{}})
+		if err != nil {
+			t.Fatalf("NewRetryingRepository failed: %v", err)
+		}
+		repo.FailNext(2, nil, "Get")
+		if _, err := retrying.Get(ctx, 1); err != nil {
+			t.Errorf("Expected the third attempt to succeed, got %v", err)
+		}
+		if repo.Calls("Get") != 3 {
+			t.Errorf("Expected 3 attempts, got %d", repo.Calls("Get"))
+		}
+
+		repo.Reset()
+		if repo.Calls("Get") != 0 {
+			t.Errorf("Expected Reset to clear the counts")
+		}
+	})
+}
@@ -0,0 +1,425 @@
+package sietchtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seb7887/gofw/sietch"
+	"github.com/seb7887/gofw/sietch/sietchtest"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+type account struct {
+	ID      int64 `db:"id"`
+	Balance int   `db:"balance"`
+}
+
+func accountID(a *account) int64 { return a.ID }
+
+func TestRedisConnector(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	r := sietchtest.StartRedis(t)
+	client := r.Client
+
+	newRepo := func(t *testing.T) (*sietch.RedisConnector[account, int64], context.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		t.Cleanup(cancel)
+		return sietchtest.NewRedisConnector[account, int64](t, r, "account", 5*time.Minute, accountID), ctx
+	}
+
+	t.Run("Create and Get", func(t *testing.T) {
+		repo, ctx := newRepo(t)
+		if err := repo.Create(ctx, &account{ID: 1, Balance: 100}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		acc, err := repo.Get(ctx, 1)
+		if err != nil || acc.Balance != 100 || acc.ID != 1 {
+			t.Fatalf("Expected ID=1, Balance=100, got %+v (%v)", acc, err)
+		}
+		if _, err := repo.Get(ctx, 999); !errors.Is(err, sietch.ErrItemNotFound) {
+			t.Errorf("Expected ErrItemNotFound, got %v", err)
+		}
+		if err := repo.Create(ctx, nil); err == nil || err.Error() != "item cannot be nil" {
+			t.Errorf("Expected 'item cannot be nil', got %v", err)
+		}
+	})
+
+	t.Run("Batches", func(t *testing.T) {
+		repo, ctx := newRepo(t)
+		for _, err := range []error{
+			repo.BatchCreate(ctx, nil),
+			repo.BatchCreate(ctx, []account{}),
+			repo.BatchUpdate(ctx, []account{}),
+			repo.BatchDelete(ctx, []int64{}),
+		} {
+			if err != nil {
+				t.Errorf("Expected no error for an empty batch, got %v", err)
+			}
+		}
+
+		if err := repo.BatchCreate(ctx, []account{{ID: 2, Balance: 200}, {ID: 3, Balance: 300}}); err != nil {
+			t.Fatalf("BatchCreate failed: %v", err)
+		}
+		if err := repo.BatchUpdate(ctx, []account{{ID: 2, Balance: 250}, {ID: 3, Balance: 350}}); err != nil {
+			t.Fatalf("BatchUpdate failed: %v", err)
+		}
+		got, err := repo.GetMany(ctx, []int64{2, 3, 4})
+		if err != nil || len(got) != 2 || got[2].Balance != 250 || got[3].Balance != 350 {
+			t.Fatalf("Unexpected GetMany result %v (%v)", got, err)
+		}
+		if err := repo.BatchDelete(ctx, []int64{2, 3}); err != nil {
+			t.Fatalf("BatchDelete failed: %v", err)
+		}
+		for _, id := range []int64{2, 3} {
+			if _, err := repo.Get(ctx, id); !errors.Is(err, sietch.ErrItemNotFound) {
+				t.Errorf("Expected ErrItemNotFound for ID %d, got %v", id, err)
+			}
+		}
+	})
+
+	t.Run("Update and Delete", func(t *testing.T) {
+		repo, ctx := newRepo(t)
+		_ = repo.Create(ctx, &account{ID: 4, Balance: 400})
+		if err := repo.Update(ctx, &account{ID: 4, Balance: 450}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if acc, err := repo.Get(ctx, 4); err != nil || acc.Balance != 450 {
+			t.Errorf("Expected balance 450, got %+v (%v)", acc, err)
+		}
+		if err := repo.Update(ctx, nil); err == nil || err.Error() != "item cannot be nil" {
+			t.Errorf("Expected 'item cannot be nil', got %v", err)
+		}
+
+		if err := repo.Delete(ctx, 4); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, err := repo.Get(ctx, 4); !errors.Is(err, sietch.ErrItemNotFound) {
+			t.Errorf("Expected ErrItemNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		repo, ctx := newRepo(t)
+		if _, err := repo.Query(ctx, &sietch.Filter{}); !errors.Is(err, sietch.ErrUnsupportedOperation) {
+			t.Errorf("Expected ErrUnsupportedOperation without a key prefix, got %v", err)
+		}
+
+		repo.SetKeyPrefix("account:")
+		_ = repo.BatchCreate(ctx, []account{{ID: 1, Balance: 300}, {ID: 2, Balance: 100}, {ID: 3, Balance: 200}})
+		client.Set(ctx, "other:1", `{"id":9,"balance":500}`, 0)
+
+		rich := sietch.NewFilter().Where("balance", sietch.OpGreaterThanOrEqual, 200).OrderBy("balance", sietch.SortAsc).Build()
+		results, err := repo.Query(ctx, rich)
+		if err != nil || len(results) != 2 || results[0].ID != 3 || results[1].ID != 1 {
+			t.Errorf("Expected accounts 3 and 1, got %v (%v)", results, err)
+		}
+		page, err := repo.Query(ctx, sietch.NewFilter().OrderBy("balance", sietch.SortDesc).Limit(1).Offset(1).Build())
+		if err != nil || len(page) != 1 || page[0].ID != 3 {
+			t.Errorf("Expected account 3, got %v (%v)", page, err)
+		}
+		if n, err := repo.Count(ctx, rich); err != nil || n != 2 {
+			t.Errorf("Expected 2 matches, got %d (%v)", n, err)
+		}
+		if _, err := repo.FindOne(ctx, sietch.NewFilter().Where("balance", sietch.OpEqual, 999).Build()); !errors.Is(err, sietch.ErrItemNotFound) {
+			t.Errorf("Expected ErrItemNotFound, got %v", err)
+		}
+		if _, err := repo.Query(ctx, sietch.NewFilter().GroupBy("balance").Build()); !errors.Is(err, sietch.ErrUnsupportedOperation) {
+			t.Errorf("Expected ErrUnsupportedOperation for grouping, got %v", err)
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		repo, ctx := newRepo(t)
+		if err := repo.Clear(ctx); !errors.Is(err, sietch.ErrUnsupportedOperation) {
+			t.Errorf("Expected ErrUnsupportedOperation without a key prefix, got %v", err)
+		}
+
+		repo.SetKeyPrefix("account:")
+		_ = repo.BatchCreate(ctx, []account{{ID: 1}, {ID: 2}})
+		client.Set(ctx, "other:1", "kept", 0)
+		if err := repo.Clear(ctx); err != nil {
+			t.Fatalf("Clear failed: %v", err)
+		}
+		if exists, _ := repo.Exists(ctx, 1); exists {
+			t.Error("Expected the connector's keys to be deleted")
+		}
+		if client.Exists(ctx, "other:1").Val() != 1 {
+			t.Error("Expected other keys to be kept")
+		}
+	})
+
+	t.Run("Indexed query", func(t *testing.T) {
+		repo, ctx := newRepo(t)
+		repo.SetKeyPrefix("account:")
+		if err := repo.SetIndexedFields("balance"); err != nil {
+			t.Fatalf("SetIndexedFields failed: %v", err)
+		}
+		_ = repo.BatchCreate(ctx, []account{{ID: 1, Balance: 100}, {ID: 2, Balance: 100}, {ID: 3, Balance: 200}})
+
+		byBalance := sietch.NewFilter().Where("balance", sietch.OpEqual, 100).OrderBy("id", sietch.SortAsc).Build()
+		results, err := repo.Query(ctx, byBalance)
+		if err != nil || len(results) != 2 || results[0].ID != 1 || results[1].ID != 2 {
+			t.Fatalf("Expected accounts 1 and 2, got %v (%v)", results, err)
+		}
+
+		// Writes move keys between sets
+		_ = repo.Update(ctx, &account{ID: 2, Balance: 200})
+		_ = repo.Delete(ctx, 1)
+		if members := client.SMembers(ctx, "idx:account:balance:100").Val(); len(members) != 0 {
+			t.Errorf("Expected an empty set, got %v", members)
+		}
+		if n, err := repo.Count(ctx, sietch.NewFilter().Where("balance", sietch.OpEqual, 200).Build()); err != nil || n != 2 {
+			t.Errorf("Expected 2 accounts, got %d (%v)", n, err)
+		}
+
+		// Keys of items gone without a write are skipped and pruned
+		client.Del(ctx, "account:3")
+		if n, _ := repo.Count(ctx, sietch.NewFilter().Where("balance", sietch.OpEqual, 200).Build()); n != 1 {
+			t.Errorf("Expected 1 account, got %d", n)
+		}
+		if members := client.SMembers(ctx, "idx:account:balance:200").Val(); len(members) != 1 {
+			t.Errorf("Expected the deleted key to be pruned, got %v", members)
+		}
+
+		if err := repo.Clear(ctx); err != nil {
+			t.Fatalf("Clear failed: %v", err)
+		}
+		if client.Exists(ctx, "idx:account:balance:200").Val() != 0 {
+			t.Error("Expected Clear to delete the index sets")
+		}
+	})
+
+	t.Run("Concurrent indexed writes", func(t *testing.T) {
+		repo, ctx := newRepo(t)
+		repo.SetKeyPrefix("race:")
+		repo.SetTxRetryOptions(sietch.TxRetryOptions{MaxRetries: 100})
+		if err := repo.SetIndexedFields("balance"); err != nil {
+			t.Fatalf("SetIndexedFields failed: %v", err)
+		}
+		_ = repo.Create(ctx, &account{ID: 1})
+
+		const writers = 4
+		var wg sync.WaitGroup
+		for w := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 10 {
+					if err := repo.Update(ctx, &account{ID: 1, Balance: w + 1}); err != nil {
+						t.Errorf("Update failed: %v", err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		stored, err := repo.Get(ctx, 1)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		for balance := range writers + 1 {
+			member := client.SIsMember(ctx, fmt.Sprintf("idx:race:balance:%d", balance), "race:1").Val()
+			if member != (balance == stored.Balance) {
+				t.Errorf("Expected the key in the set of balance %d only, found it in the set of %d: %v", stored.Balance, balance, member)
+			}
+		}
+	})
+
+	t.Run("Hash storage", func(t *testing.T) {
+		repo, ctx := newRepo(t)
+		repo.SetStorage(sietch.RedisStorageHash)
+		if err := repo.SetIndexedFields("balance"); err != nil {
+			t.Fatalf("SetIndexedFields failed: %v", err)
+		}
+
+		if err := repo.BatchCreate(ctx, []account{{ID: 1, Balance: 100}, {ID: 2, Balance: 50}}); err != nil {
+			t.Fatalf("BatchCreate failed: %v", err)
+		}
+		if balance, _ := client.HGet(ctx, "account:1", "balance").Result(); balance != "100" {
+			t.Errorf("Expected the balance field to be 100, got %q", balance)
+		}
+
+		if err := repo.UpdateFields(ctx, 1, map[string]any{"balance": sietch.Increment(-30)}); err != nil {
+			t.Fatalf("UpdateFields failed: %v", err)
+		}
+		if acc, err := repo.Get(ctx, 1); err != nil || acc.Balance != 70 {
+			t.Errorf("Expected balance 70, got %v (%v)", acc, err)
+		}
+		matches, err := repo.Query(ctx, sietch.NewFilter().Where("balance", sietch.OpEqual, 70).Build())
+		if err != nil || len(matches) != 1 || matches[0].ID != 1 {
+			t.Errorf("Expected account 1 in the new index set, got %v (%v)", matches, err)
+		}
+		items, err := repo.GetMany(ctx, []int64{1, 2, 3})
+		if err != nil || len(items) != 2 || items[2].Balance != 50 {
+			t.Errorf("Expected accounts 1 and 2, got %v (%v)", items, err)
+		}
+		if err := repo.UpdateFields(ctx, 3, map[string]any{"balance": 1}); !errors.Is(err, sietch.ErrNoUpdateItem) {
+			t.Errorf("Expected ErrNoUpdateItem, got %v", err)
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		_, ctx := newRepo(t)
+		short := sietchtest.NewRedisConnector[account, int64](t, r, "ttl_test", time.Second, accountID)
+		_ = short.Create(ctx, &account{ID: 10, Balance: 1000})
+		if ttl := client.TTL(ctx, "ttl_test:10").Val(); ttl <= 0 || ttl > time.Second {
+			t.Errorf("Expected a TTL of at most 1s, got %v", ttl)
+		}
+
+		repo, _ := newRepo(t)
+		_ = repo.Create(sietch.WithTTL(ctx, 30*time.Second), &account{ID: 1})
+		_ = repo.BatchCreate(sietch.WithTTL(ctx, 0), []account{{ID: 2}})
+		if ttl := client.TTL(ctx, "account:1").Val(); ttl <= 0 || ttl > 30*time.Second {
+			t.Errorf("Expected a TTL of at most 30s, got %v", ttl)
+		}
+		if ttl := client.TTL(ctx, "account:2").Val(); ttl != -1 {
+			t.Errorf("Expected no TTL, got %v", ttl)
+		}
+
+		if err := repo.Touch(ctx, 2, time.Hour); err != nil {
+			t.Fatalf("Touch failed: %v", err)
+		}
+		if ttl := client.TTL(ctx, "account:2").Val(); ttl <= 30*time.Minute {
+			t.Errorf("Expected a TTL of about an hour, got %v", ttl)
+		}
+		if err := repo.Touch(ctx, 1, 0); err != nil {
+			t.Fatalf("Touch failed: %v", err)
+		}
+		if ttl := client.TTL(ctx, "account:1").Val(); ttl != -1 {
+			t.Errorf("Expected the TTL to be removed, got %v", ttl)
+		}
+		for _, ttl := range []time.Duration{time.Hour, 0} {
+			if err := repo.Touch(ctx, 9, ttl); !errors.Is(err, sietch.ErrItemNotFound) {
+				t.Errorf("Expected ErrItemNotFound, got %v", err)
+			}
+		}
+	})
+
+	t.Run("Transactions", func(t *testing.T) {
+		repo, ctx := newRepo(t)
+		_ = repo.BatchCreate(ctx, []account{{ID: 1, Balance: 100}, {ID: 2, Balance: 0}})
+		transfer := func(tx sietch.Repository[account, int64]) error {
+			from, err := tx.Get(ctx, 1)
+			if err != nil {
+				return err
+			}
+			to, err := tx.Get(ctx, 2)
+			if err != nil {
+				return err
+			}
+			from.Balance, to.Balance = from.Balance-10, to.Balance+10
+			if err := tx.BatchUpdate(ctx, []account{*from, *to}); err != nil {
+				return err
+			}
+			if buffered, _ := tx.Get(ctx, 1); buffered.Balance != from.Balance {
+				t.Errorf("Expected reads to see buffered writes, got %d", buffered.Balance)
+			}
+			if stored, _ := repo.Get(ctx, 1); stored.Balance != 100 {
+				t.Errorf("Expected writes to wait for the commit, got %d", stored.Balance)
+			}
+			return nil
+		}
+		if err := repo.WithTx(ctx, transfer); err != nil {
+			t.Fatalf("WithTx failed: %v", err)
+		}
+		if acc, _ := repo.Get(ctx, 2); acc.Balance != 10 {
+			t.Errorf("Expected a balance of 10, got %d", acc.Balance)
+		}
+
+		// Conflicts are rerun
+		repo.SetTxRetryOptions(sietch.TxRetryOptions{MaxRetries: 1, Backoff: noBackoff{}})
+		attempts := 0
+		err := repo.WithTx(ctx, func(tx sietch.Repository[account, int64]) error {
+			attempts++
+			acc, err := tx.Get(ctx, 1)
+			if err != nil {
+				return err
+			}
+			if attempts == 1 {
+				_ = repo.Update(ctx, &account{ID: 1, Balance: 500})
+			}
+			acc.Balance++
+			return tx.Update(ctx, acc)
+		})
+		if err != nil || attempts != 2 {
+			t.Fatalf("Expected success after 2 attempts, got %d (%v)", attempts, err)
+		}
+		if acc, _ := repo.Get(ctx, 1); acc.Balance != 501 {
+			t.Errorf("Expected a balance of 501, got %d", acc.Balance)
+		}
+		err = repo.WithTx(ctx, func(tx sietch.Repository[account, int64]) error {
+			_, _ = tx.Get(ctx, 1)
+			_ = repo.Update(ctx, &account{ID: 1})
+			return tx.Delete(ctx, 1)
+		})
+		if !errors.Is(err, sietch.ErrTxConflict) {
+			t.Errorf("Expected ErrTxConflict, got %v", err)
+		}
+
+		// Errors discard the writes
+		boom := errors.New("boom")
+		err = repo.WithTx(ctx, func(tx sietch.Repository[account, int64]) error {
+			_ = tx.Delete(ctx, 2)
+			return boom
+		})
+		if !errors.Is(err, boom) {
+			t.Errorf("Expected the error of the function, got %v", err)
+		}
+		if exists, _ := repo.Exists(ctx, 2); !exists {
+			t.Error("Expected the delete to be discarded")
+		}
+	})
+}
+
+type searchItem struct {
+	ID    int64   `db:"id" json:"id"`
+	Name  string  `db:"name" json:"name"`
+	Score float64 `db:"score" json:"score"`
+}
+
+func TestRedisConnector_Search(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	r := sietchtest.StartRedis(t, sietchtest.ContainerConfig{Image: sietchtest.RedisStackImage})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	repo := sietchtest.NewRedisConnector[searchItem, int64](t, r, "search-item", 0, func(i *searchItem) int64 { return i.ID })
+	repo.SetKeyPrefix("search-item:")
+	if err := repo.CreateSearchIndex(ctx, "search-items"); !errors.Is(err, sietch.ErrUnsupportedOperation) {
+		t.Errorf("Expected ErrUnsupportedOperation without JSON storage, got %v", err)
+	}
+	repo.SetStorage(sietch.RedisStorageJSON)
+	if err := repo.CreateSearchIndex(ctx, "search-items"); err != nil {
+		t.Fatalf("CreateSearchIndex failed: %v", err)
+	}
+
+	_ = repo.BatchCreate(ctx, []searchItem{{ID: 1, Name: "ann", Score: 3}, {ID: 2, Name: "bob", Score: 1}, {ID: 3, Name: "amy", Score: 2}})
+	if item, err := repo.Get(ctx, 2); err != nil || item.Name != "bob" {
+		t.Fatalf("Expected bob, got %v (%v)", item, err)
+	}
+
+	filter := sietch.NewFilter().Where("name", sietch.OpLike, "a%").OrderBy("score", sietch.SortAsc).Build()
+	var results []searchItem
+	for range 20 { // in case indexing lags behind
+		results, _ = repo.Query(ctx, filter)
+		if len(results) == 2 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(results) != 2 || results[0].ID != 3 || results[1].ID != 1 {
+		t.Errorf("Expected items 3 and 1, got %v", results)
+	}
+	if n, err := repo.Count(ctx, sietch.NewFilter().Where("score", sietch.OpGreaterThanOrEqual, 2).Build()); err != nil || n != 2 {
+		t.Errorf("Expected 2 items, got %d (%v)", n, err)
+	}
+	page, err := repo.Query(ctx, sietch.NewFilter().OrderBy("id", sietch.SortAsc).Limit(1).Offset(2).Build())
+	if err != nil || len(page) != 1 || page[0].ID != 3 {
+		t.Errorf("Expected item 3, got %v (%v)", page, err)
+	}
+}
@@ -0,0 +1,48 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+)
+
+// CloneToMemory copies the items of repo matching filter (all items when
+// filter is nil) into a new InMemoryConnector keyed by getID. The rows are
+// read with a single Query, so for CockroachDB the snapshot is consistent as
+// of one statement; later writes to repo do not affect it.
+//
+// Items are copied by value: pointer, slice and map fields still share
+// memory with whatever the source returned, which only matters when the
+// source is itself an InMemoryConnector.
+//
+// Example:
+//
+//	snapshot, err := sietch.CloneToMemory(ctx, repo,
+//	    sietch.NewFilter().Where("status", sietch.OpEqual, "active").Build(),
+//	    func(a *Account) int64 { return a.ID })
+//	// run analyses or property-based tests against snapshot
+func CloneToMemory[T any, ID comparable](ctx context.Context, repo Repository[T, ID], filter *Filter, getID func(*T) ID) (*InMemoryConnector[T, ID], error) {
+	if repo == nil {
+		return nil, fmt.Errorf("repository cannot be nil")
+	}
+	getID, err := resolveGetID(getID)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = &Filter{}
+	}
+	if len(filter.GroupBy) > 0 {
+		return nil, fmt.Errorf("%w: snapshots cannot use GROUP BY", ErrInvalidFilter)
+	}
+
+	items, err := repo.Query(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	snapshot := NewInMemoryConnector[T, ID](getID)
+	if err := snapshot.BatchCreate(ctx, items); err != nil {
+		return nil, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+	return snapshot, nil
+}
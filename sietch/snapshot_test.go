@@ -0,0 +1,57 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCloneToMemory(t *testing.T) {
+	ctx := context.Background()
+	getID := func(i *validatedItem) int64 { return i.ID }
+	source := NewInMemoryConnector[validatedItem, int64](getID)
+	_ = source.BatchCreate(ctx, []validatedItem{
+		{ID: 1, Name: "a", Active: true},
+		{ID: 2, Name: "b", Active: false},
+		{ID: 3, Name: "c", Active: true},
+	})
+
+	t.Run("Copies matching items", func(t *testing.T) {
+		snapshot, err := CloneToMemory[validatedItem, int64](ctx, source, NewFilter().Where("active", OpEqual, true).Build(), getID)
+		if err != nil {
+			t.Fatalf("CloneToMemory failed: %v", err)
+		}
+		if count, _ := snapshot.Count(ctx, nil); count != 2 {
+			t.Errorf("Expected 2 items, got %d", count)
+		}
+		if _, err := snapshot.Get(ctx, 2); !errors.Is(err, ErrItemNotFound) {
+			t.Errorf("Expected the inactive item to be excluded, got %v", err)
+		}
+	})
+
+	t.Run("Nil filter copies everything and is isolated from the source", func(t *testing.T) {
+		snapshot, err := CloneToMemory[validatedItem, int64](ctx, source, nil, getID)
+		if err != nil {
+			t.Fatalf("CloneToMemory failed: %v", err)
+		}
+
+		_ = source.Delete(ctx, 1)
+		_ = snapshot.Update(ctx, &validatedItem{ID: 3, Name: "changed"})
+
+		if count, _ := snapshot.Count(ctx, nil); count != 3 {
+			t.Errorf("Expected 3 items in the snapshot, got %d", count)
+		}
+		if item, _ := source.Get(ctx, 3); item.Name != "c" {
+			t.Errorf("Expected the source to be unchanged, got %q", item.Name)
+		}
+	})
+
+	t.Run("Invalid arguments", func(t *testing.T) {
+		if _, err := CloneToMemory[validatedItem, int64](ctx, source, NewFilter().GroupBy("active").Build(), getID); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter for GROUP BY, got %v", err)
+		}
+		if _, err := CloneToMemory[validatedItem, int64](ctx, source, nil, nil); err == nil {
+			t.Error("Expected an error for a nil getID")
+		}
+	})
+}
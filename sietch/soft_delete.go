@@ -1,6 +1,9 @@
 package sietch
 
 import (
+	"context"
+	"fmt"
+	"slices"
 	"time"
 )
 
@@ -66,3 +69,237 @@ func isEntityDeleted[T any](item *T) bool {
 	}
 	return false
 }
+
+// markAsRestored clears the deleted flag and timestamp of an entity
+func markAsRestored[T any](item *T) {
+	if sd, ok := any(item).(SoftDeletable); ok {
+		sd.SetDeleted(false)
+		sd.SetDeletedAt(nil)
+	}
+}
+
+// withDeletedKey is the context key type marking reads that include
+// soft-deleted entities
+type withDeletedKey struct{}
+
+// includesDeleted reports whether ctx comes from a WithDeleted view
+func includesDeleted(ctx context.Context) bool {
+	included, _ := ctx.Value(withDeletedKey{}).(bool)
+	return included
+}
+
+// softDeleteConfig holds the soft delete columns of a SoftDeletable entity;
+// one of them may be empty if the entity has no such column
+type softDeleteConfig struct {
+	isDeleted      string
+	deletedAt      string
+	includeDeleted bool
+}
+
+// newSoftDeleteConfig returns the soft delete configuration of T, or nil if T
+// is not SoftDeletable. The entity needs at least one of the configured
+// columns.
+func newSoftDeleteConfig[T any](opts *SoftDeleteOptions, columns []string) (*softDeleteConfig, error) {
+	if !isSoftDeletable[T]() {
+		return nil, nil
+	}
+	if opts == nil {
+		opts = DefaultSoftDeleteOptions()
+	}
+
+	c := &softDeleteConfig{includeDeleted: opts.IncludeDeleted}
+	if slices.Contains(columns, opts.IsDeletedField) {
+		c.isDeleted = opts.IsDeletedField
+	}
+	if slices.Contains(columns, opts.DeletedAtField) {
+		c.deletedAt = opts.DeletedAtField
+	}
+	if c.isDeleted == "" && c.deletedAt == "" {
+		return nil, fmt.Errorf("soft-deletable entity needs a %q or %q column", opts.IsDeletedField, opts.DeletedAtField)
+	}
+	return c, nil
+}
+
+// hides reports whether reads with ctx exclude soft-deleted rows
+func (c *softDeleteConfig) hides(ctx context.Context) bool {
+	return c != nil && !c.includeDeleted && !includesDeleted(ctx)
+}
+
+// live returns the condition matching rows that are not soft-deleted
+func (c *softDeleteConfig) live() Condition {
+	if c.isDeleted != "" {
+		return Condition{Field: c.isDeleted, Operator: OpEqual, Value: false}
+	}
+	return Condition{Field: c.deletedAt, Operator: OpIsNull}
+}
+
+// liveSQL is the SQL form of live
+func (c *softDeleteConfig) liveSQL() string {
+	if c.isDeleted != "" {
+		return quoteIdentifier(c.isDeleted) + " = false"
+	}
+	return quoteIdentifier(c.deletedAt) + " IS NULL"
+}
+
+// deletedSQL matches soft-deleted rows
+func (c *softDeleteConfig) deletedSQL() string {
+	if c.isDeleted != "" {
+		return quoteIdentifier(c.isDeleted) + " = true"
+	}
+	return quoteIdentifier(c.deletedAt) + " IS NOT NULL"
+}
+
+// setSQL returns the SET clauses marking a row as deleted or restored
+func (c *softDeleteConfig) setSQL(deleted bool) string {
+	var clauses []string
+	if c.isDeleted != "" {
+		clauses = append(clauses, fmt.Sprintf("%s = %t", quoteIdentifier(c.isDeleted), deleted))
+	}
+	if c.deletedAt != "" {
+		at := "NULL"
+		if deleted {
+			at = "now()"
+		}
+		clauses = append(clauses, quoteIdentifier(c.deletedAt)+" = "+at)
+	}
+	return joinString(clauses, ", ")
+}
+
+// scope returns filter with a condition excluding soft-deleted rows, unless
+// the reads of ctx include them
+func (c *softDeleteConfig) scope(ctx context.Context, filter *Filter) *Filter {
+	if !c.hides(ctx) {
+		return filter
+	}
+	scoped := &Filter{}
+	if filter != nil {
+		*scoped = *filter
+	}
+	scoped.Conditions = append(slices.Clip(scoped.Conditions), c.live())
+	return scoped
+}
+
+// apply turns the Delete statement of s into an UPDATE of the soft delete
+// columns and hides deleted rows from Get, GetForUpdate and Exists. The
+// unfiltered reads are kept as the WithDeleted kinds.
+func (c *softDeleteConfig) apply(s *crudStatements, table, pk string) {
+	quotedTable := quoteIdentifier(table)
+	pk = quoteIdentifier(pk)
+	live := " AND " + c.liveSQL()
+
+	s[stmtGetWithDeleted].SQL = s[stmtGet].SQL
+	s[stmtGetForUpdateWithDeleted].SQL = s[stmtGetForUpdate].SQL
+	s[stmtExistsWithDeleted].SQL = s[stmtExists].SQL
+
+	s[stmtGet].SQL += live
+	s[stmtGetForUpdate].SQL = s[stmtGet].SQL + " FOR UPDATE"
+	s[stmtExists].SQL = fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s = $1%s)", quotedTable, pk, live)
+	s[stmtDelete].SQL = fmt.Sprintf("UPDATE %s SET %s WHERE %s = $1%s", quotedTable, c.setSQL(true), pk, live)
+	s[stmtRestore].SQL = fmt.Sprintf("UPDATE %s SET %s WHERE %s = $1 AND %s", quotedTable, c.setSQL(false), pk, c.deletedSQL())
+}
+
+// withDeletedRepository is the WithDeleted view of a repository: its reads
+// include soft-deleted entities
+type withDeletedRepository[T any, ID comparable] struct {
+	repo Repository[T, ID]
+}
+
+func (r *withDeletedRepository[T, ID]) ctx(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withDeletedKey{}, true)
+}
+
+func (r *withDeletedRepository[T, ID]) Create(ctx context.Context, item *T) error {
+	return r.repo.Create(r.ctx(ctx), item)
+}
+
+func (r *withDeletedRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	return r.repo.Get(r.ctx(ctx), id)
+}
+
+func (r *withDeletedRepository[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	return r.repo.BatchCreate(r.ctx(ctx), items)
+}
+
+func (r *withDeletedRepository[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
+	return r.repo.Query(r.ctx(ctx), filter)
+}
+
+func (r *withDeletedRepository[T, ID]) Update(ctx context.Context, item *T) error {
+	return r.repo.Update(r.ctx(ctx), item)
+}
+
+func (r *withDeletedRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	return r.repo.BatchUpdate(r.ctx(ctx), items)
+}
+
+func (r *withDeletedRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	return r.repo.Delete(r.ctx(ctx), id)
+}
+
+func (r *withDeletedRepository[T, ID]) BatchDelete(ctx context.Context, items []ID) error {
+	return r.repo.BatchDelete(r.ctx(ctx), items)
+}
+
+func (r *withDeletedRepository[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
+	return r.repo.Count(r.ctx(ctx), filter)
+}
+
+func (r *withDeletedRepository[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	return r.repo.FindOne(r.ctx(ctx), filter)
+}
+
+func (r *withDeletedRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	return r.repo.GetMany(r.ctx(ctx), ids)
+}
+
+func (r *withDeletedRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	return r.repo.Exists(r.ctx(ctx), id)
+}
+
+func (r *withDeletedRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	return r.repo.Upsert(r.ctx(ctx), item)
+}
+
+func (r *withDeletedRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	return r.repo.BatchUpsert(r.ctx(ctx), items)
+}
+
+func (r *withDeletedRepository[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	return r.repo.UpdateWhere(r.ctx(ctx), filter, updates)
+}
+
+func (r *withDeletedRepository[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	return r.repo.DeleteWhere(r.ctx(ctx), filter)
+}
+
+// GetForUpdate implements RowLocker if the underlying repository does
+func (r *withDeletedRepository[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
+	locker, ok := r.repo.(RowLocker[T, ID])
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	return locker.GetForUpdate(r.ctx(ctx), id)
+}
+
+// GroupCount implements Grouper if the underlying repository does
+func (r *withDeletedRepository[T, ID]) GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error) {
+	grouper, ok := r.repo.(Grouper)
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	return grouper.GroupCount(r.ctx(ctx), filter)
+}
+
+// WithDeleted returns the view itself
+func (r *withDeletedRepository[T, ID]) WithDeleted() Repository[T, ID] {
+	return r
+}
+
+// Restore implements SoftDeleter if the underlying repository does
+func (r *withDeletedRepository[T, ID]) Restore(ctx context.Context, id ID) error {
+	deleter, ok := r.repo.(SoftDeleter[T, ID])
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	return deleter.Restore(ctx, id)
+}
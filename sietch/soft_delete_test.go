@@ -0,0 +1,202 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type softAccount struct {
+	ID        int64      `db:"id"`
+	Balance   int        `db:"balance"`
+	Deleted   bool       `db:"is_deleted"`
+	DeletedAt *time.Time `db:"deleted_at"`
+}
+
+func (a *softAccount) IsDeleted() bool                   { return a.Deleted }
+func (a *softAccount) SetDeleted(deleted bool)           { a.Deleted = deleted }
+func (a *softAccount) GetDeletedAt() *time.Time          { return a.DeletedAt }
+func (a *softAccount) SetDeletedAt(deletedAt *time.Time) { a.DeletedAt = deletedAt }
+
+func newSoftConnector(t *testing.T) *CockroachDBConnector[softAccount, int64] {
+	t.Helper()
+	conn, err := NewCockroachDBConnector[softAccount, int64](&pgxpool.Pool{}, "accounts", func(a *softAccount) int64 { return a.ID })
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+	return conn
+}
+
+// recordingTx logs the statements run on it; rows are never found and
+// every write affects one row
+type recordingTx struct {
+	pgx.Tx
+	log []string
+}
+
+func (tx *recordingTx) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	tx.log = append(tx.log, sql)
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (tx *recordingTx) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	tx.log = append(tx.log, sql)
+	return versionRow{err: pgx.ErrNoRows}
+}
+
+func TestCockroachDBConnector_SoftDelete(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Statements", func(t *testing.T) {
+		conn := newSoftConnector(t)
+		cols := `"id", "balance", "is_deleted", "deleted_at"`
+		expected := map[statementKind]string{
+			stmtGet:            `SELECT ` + cols + ` FROM "accounts" WHERE "id" = $1 AND "is_deleted" = false`,
+			stmtGetForUpdate:   `SELECT ` + cols + ` FROM "accounts" WHERE "id" = $1 AND "is_deleted" = false FOR UPDATE`,
+			stmtExists:         `SELECT EXISTS(SELECT 1 FROM "accounts" WHERE "id" = $1 AND "is_deleted" = false)`,
+			stmtDelete:         `UPDATE "accounts" SET "is_deleted" = true, "deleted_at" = now() WHERE "id" = $1 AND "is_deleted" = false`,
+			stmtRestore:        `UPDATE "accounts" SET "is_deleted" = false, "deleted_at" = NULL WHERE "id" = $1 AND "is_deleted" = true`,
+			stmtGetWithDeleted: `SELECT ` + cols + ` FROM "accounts" WHERE "id" = $1`,
+		}
+		for kind, sql := range expected {
+			if got := conn.statement(kind); got != sql {
+				t.Errorf("Unexpected %s statement %s", statementSuffixes[kind], got)
+			}
+		}
+		if n := len(conn.PreparedStatements()); n != int(numStatements) {
+			t.Errorf("Expected %d statements, got %d", numStatements, n)
+		}
+	})
+
+	t.Run("Reads skip deleted rows unless included", func(t *testing.T) {
+		conn := newSoftConnector(t)
+		tx := &recordingTx{}
+		repo := &cockroachDBTx[softAccount, int64]{connector: conn, tx: tx, ctx: ctx}
+
+		_, _ = repo.Get(ctx, 1)
+		_, _ = repo.WithDeleted().Get(ctx, 1)
+		if tx.log[0] != conn.statement(stmtGet) || tx.log[1] != conn.statement(stmtGetWithDeleted) {
+			t.Errorf("Unexpected statements %v", tx.log)
+		}
+
+		filter := NewFilter().Where("balance", OpGreaterThan, 0).Build()
+		query, args, err := conn.queryBuilder(conn.softDelete.scope(ctx, filter))
+		if err != nil || query != `SELECT "id", "balance", "is_deleted", "deleted_at" FROM "accounts" WHERE "balance" > $1 AND "is_deleted" = $2` {
+			t.Errorf("Unexpected query %s (%v)", query, err)
+		}
+		if !reflect.DeepEqual(args, []any{0, false}) || len(filter.Conditions) != 1 {
+			t.Errorf("Expected the filter to be copied, got %v and %v", args, filter.Conditions)
+		}
+		if scoped := conn.softDelete.scope(context.WithValue(ctx, withDeletedKey{}, true), filter); scoped != filter {
+			t.Error("Expected WithDeleted reads to keep the filter")
+		}
+	})
+
+	t.Run("Deletes update the soft delete columns", func(t *testing.T) {
+		conn := newSoftConnector(t)
+		tx := &recordingTx{}
+		repo := &cockroachDBTx[softAccount, int64]{connector: conn, tx: tx, ctx: ctx}
+
+		if err := repo.Delete(ctx, 1); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if err := repo.Restore(ctx, 1); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		if !strings.HasPrefix(tx.log[0], `UPDATE "accounts" SET "is_deleted" = true`) || tx.log[1] != conn.statement(stmtRestore) {
+			t.Errorf("Unexpected statements %v", tx.log)
+		}
+
+		query, _, err := conn.deleteWhereQuery(NewFilter().Where("balance", OpEqual, 0).Build())
+		expected := `UPDATE "accounts" SET "is_deleted" = true, "deleted_at" = now() WHERE "balance" = $1 AND "is_deleted" = $2`
+		if err != nil || query != expected {
+			t.Errorf("Unexpected query %s (%v)", query, err)
+		}
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		conn := newSoftConnector(t)
+		err := conn.SetSoftDeleteOptions(&SoftDeleteOptions{DeletedAtField: "deleted_at", IsDeletedField: "missing"})
+		if err != nil {
+			t.Fatalf("SetSoftDeleteOptions failed: %v", err)
+		}
+		if got := conn.statement(stmtDelete); got != `UPDATE "accounts" SET "deleted_at" = now() WHERE "id" = $1 AND "deleted_at" IS NULL` {
+			t.Errorf("Expected a deleted_at only delete, got %s", got)
+		}
+		if err := conn.SetSoftDeleteOptions(&SoftDeleteOptions{DeletedAtField: "a", IsDeletedField: "b"}); err == nil {
+			t.Error("Expected an error without soft delete columns")
+		}
+
+		plain := newBatchConnector(t)
+		if err := plain.Restore(ctx, 1); !errors.Is(err, ErrUnsupportedOperation) {
+			t.Errorf("Expected ErrUnsupportedOperation, got %v", err)
+		}
+	})
+}
+
+func TestInMemoryConnector_SoftDelete(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[softAccount, int64](func(a *softAccount) int64 { return a.ID })
+	var _ SoftDeleter[softAccount, int64] = repo
+
+	_ = repo.BatchCreate(ctx, []softAccount{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}, {ID: 3, Balance: 30}})
+	stored, _ := repo.Get(ctx, 1)
+	if err := repo.Delete(ctx, 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if stored.Deleted {
+		t.Error("Expected a previously read item to be left untouched")
+	}
+	if err := repo.Delete(ctx, 1); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("Expected a second delete to fail, got %v", err)
+	}
+
+	if _, err := repo.Get(ctx, 1); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("Expected the deleted item to be hidden, got %v", err)
+	}
+	if exists, _ := repo.Exists(ctx, 1); exists {
+		t.Error("Expected the deleted item not to exist")
+	}
+	if count, _ := repo.Count(ctx, &Filter{}); count != 2 {
+		t.Errorf("Expected 2 items, got %d", count)
+	}
+	if items, _ := repo.GetMany(ctx, []int64{1, 2}); len(items) != 1 {
+		t.Errorf("Expected only item 2, got %v", items)
+	}
+	if n, _ := repo.UpdateWhere(ctx, NewFilter().Where("balance", OpGreaterThan, 0).Build(), map[string]any{"balance": 0}); n != 2 {
+		t.Errorf("Expected the deleted item not to be updated, got %d updates", n)
+	}
+
+	all := repo.WithDeleted()
+	deleted, err := all.Get(ctx, 1)
+	if err != nil || !deleted.Deleted || deleted.DeletedAt == nil || deleted.Balance != 10 {
+		t.Fatalf("Expected the deleted item, got %+v (%v)", deleted, err)
+	}
+	if count, _ := all.Count(ctx, &Filter{}); count != 3 {
+		t.Errorf("Expected 3 items with the deleted one, got %d", count)
+	}
+
+	if err := repo.Restore(ctx, 1); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if err := repo.Restore(ctx, 1); !errors.Is(err, ErrNoUpdateItem) {
+		t.Errorf("Expected ErrNoUpdateItem for a live item, got %v", err)
+	}
+	if restored, err := repo.Get(ctx, 1); err != nil || restored.DeletedAt != nil {
+		t.Errorf("Expected the item to be restored, got %+v (%v)", restored, err)
+	}
+
+	if n, _ := repo.DeleteWhere(ctx, NewFilter().Where("id", OpIn, []int64{1, 2}).Build()); n != 2 {
+		t.Errorf("Expected 2 deletes, got %d", n)
+	}
+	if results, _ := repo.Query(ctx, &Filter{}); len(results) != 1 || results[0].ID != 3 {
+		t.Errorf("Expected only item 3, got %+v", results)
+	}
+}
@@ -0,0 +1,92 @@
+package sietch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/seb7887/gofw/sietch/internal/testutils"
+)
+
+func TestCockroachDBQueryBuilder_NullsOrdering(t *testing.T) {
+	conn, err := NewCockroachDBConnector[testutils.Account, int64](
+		&pgxpool.Pool{},
+		"accounts",
+		func(a *testutils.Account) int64 { return a.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	filter := NewFilter().
+		OrderByNulls("balance", SortDesc, NullsLast).
+		OrderByNulls("id", SortAsc, NullsFirst).
+		Build()
+
+	query, _, err := conn.queryBuilder(filter)
+	if err != nil {
+		t.Fatalf("queryBuilder failed: %v", err)
+	}
+	expected := `SELECT "id", "balance" FROM "accounts" ORDER BY "balance" DESC NULLS LAST, "id" ASC NULLS FIRST`
+	if query != expected {
+		t.Errorf("Expected: %s\nGot: %s", expected, query)
+	}
+
+	t.Run("Invalid nulls ordering", func(t *testing.T) {
+		filter := &Filter{Sort: []SortField{{Field: "balance", Direction: SortAsc, Nulls: "NULLS; DROP"}}}
+		if _, _, err := conn.queryBuilder(filter); err == nil {
+			t.Error("Expected error for invalid nulls ordering")
+		}
+	})
+}
+
+func TestInMemoryNullsOrdering(t *testing.T) {
+	ctx := context.Background()
+
+	type ranked struct {
+		ID    int64  `db:"id"`
+		Score *int64 `db:"score"`
+		Level int64  `db:"level"`
+	}
+	score := func(v int64) *int64 { return &v }
+
+	repo := NewInMemoryConnector[ranked, int64](func(r *ranked) int64 { return r.ID })
+	repo.BatchCreate(ctx, []ranked{
+		{ID: 1, Score: score(10), Level: 2},
+		{ID: 2, Score: nil, Level: 0},
+		{ID: 3, Score: score(30), Level: 1},
+		{ID: 4, Score: nil, Level: 3},
+		{ID: 5, Score: score(20), Level: 0},
+	})
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected []int64
+	}{
+		{"default ASC puts nil first", NewFilter().OrderBy("score", SortAsc).OrderBy("id", SortAsc).Build(), []int64{2, 4, 1, 5, 3}},
+		{"default DESC puts nil last", NewFilter().OrderBy("score", SortDesc).OrderBy("id", SortAsc).Build(), []int64{3, 5, 1, 2, 4}},
+		{"DESC NULLS FIRST", NewFilter().OrderByNulls("score", SortDesc, NullsFirst).OrderBy("id", SortDesc).Build(), []int64{4, 2, 3, 5, 1}},
+		{"ASC NULLS LAST", NewFilter().OrderByNulls("score", SortAsc, NullsLast).OrderBy("id", SortAsc).Build(), []int64{1, 5, 3, 2, 4}},
+		{"zero values are not NULL with explicit ordering", NewFilter().OrderByNulls("level", SortAsc, NullsLast).OrderBy("id", SortAsc).Build(), []int64{2, 5, 3, 1, 4}},
+		{"zero values sort normally by default", NewFilter().OrderBy("level", SortAsc).OrderBy("id", SortAsc).Build(), []int64{2, 5, 3, 1, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.Query(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			ids := make([]int64, len(results))
+			for i, r := range results {
+				ids[i] = r.ID
+			}
+			for i := range tt.expected {
+				if i >= len(ids) || ids[i] != tt.expected[i] {
+					t.Fatalf("Expected order %v, got %v", tt.expected, ids)
+				}
+			}
+		})
+	}
+}
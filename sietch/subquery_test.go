@@ -0,0 +1,376 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type existsUser struct {
+	ID        int64  `db:"id"`
+	Name      string `db:"name"`
+	ManagerID *int64 `db:"manager_id"`
+}
+
+type existsOrder struct {
+	ID     int64  `db:"id"`
+	UserID int64  `db:"user_id"`
+	Status string `db:"status"`
+}
+
+type existsItem struct {
+	ID      int64  `db:"id"`
+	OrderID int64  `db:"order_id"`
+	SKU     string `db:"sku"`
+}
+
+func TestCockroachDBQueryBuilder_Exists(t *testing.T) {
+	conn, err := NewCockroachDBConnector[existsUser, int64](
+		&pgxpool.Pool{},
+		"users",
+		func(u *existsUser) int64 { return u.ID },
+	)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		filter        *Filter
+		expectedWhere string
+		expectedArgs  int
+	}{
+		{
+			"correlated",
+			NewFilter().WhereExists(NewFilter().Where("status", OpEqual, "open").Build(), "orders", Correlate("user_id", "id")).Build(),
+			`EXISTS (SELECT 1 FROM "orders" AS "orders_1" WHERE "orders_1"."user_id" = "users"."id" AND "orders_1"."status" = $1)`,
+			1,
+		},
+		{
+			"without sub filter",
+			NewFilter().Where("name", OpEqual, "alice").WhereExists(nil, "orders", Correlate("user_id", "id")).Build(),
+			`"name" = $1 AND EXISTS (SELECT 1 FROM "orders" AS "orders_1" WHERE "orders_1"."user_id" = "users"."id")`,
+			1,
+		},
+		{
+			"not exists",
+			NewFilter().Not(Exists(nil, "orders", Correlate("user_id", "id"))).Build(),
+			`NOT (EXISTS (SELECT 1 FROM "orders" AS "orders_1" WHERE "orders_1"."user_id" = "users"."id"))`,
+			0,
+		},
+		{
+			"self correlation",
+			NewFilter().WhereExists(nil, "users", Correlate("manager_id", "id")).Build(),
+			`EXISTS (SELECT 1 FROM "users" AS "users_1" WHERE "users_1"."manager_id" = "users"."id")`,
+			0,
+		},
+		{
+			"nested",
+			NewFilter().WhereExists(
+				NewFilter().
+					Or(Condition{Field: "status", Operator: OpEqual, Value: "open"}, Condition{Field: "status", Operator: OpEqual, Value: "paid"}).
+					WhereExists(NewFilter().Where("sku", OpLike, "BK-%").Build(), "order_items", Correlate("order_id", "id")).
+					Build(),
+				"orders", Correlate("user_id", "id"),
+			).Build(),
+			`EXISTS (SELECT 1 FROM "orders" AS "orders_1" WHERE "orders_1"."user_id" = "users"."id" AND ("orders_1"."status" = $1 OR "orders_1"."status" = $2) AND ` +
+				`EXISTS (SELECT 1 FROM "order_items" AS "order_items_2" WHERE "order_items_2"."order_id" = "orders_1"."id" AND "order_items_2"."sku" LIKE $3))`,
+			3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := conn.queryBuilder(tt.filter)
+			if err != nil {
+				t.Fatalf("queryBuilder failed: %v", err)
+			}
+			expected := `SELECT "id", "name", "manager_id" FROM "users" WHERE ` + tt.expectedWhere
+			if query != expected {
+				t.Errorf("Expected: %s\nGot: %s", expected, query)
+			}
+			if len(args) != tt.expectedArgs {
+				t.Errorf("Expected %d args, got %d", tt.expectedArgs, len(args))
+			}
+		})
+	}
+
+	t.Run("DeleteWhere", func(t *testing.T) {
+		query, _, err := conn.deleteWhereQuery(NewFilter().Not(Exists(nil, "orders", Correlate("user_id", "id"))).Build())
+		if err != nil {
+			t.Fatalf("deleteWhereQuery failed: %v", err)
+		}
+		expected := `DELETE FROM "users" WHERE NOT (EXISTS (SELECT 1 FROM "orders" AS "orders_1" WHERE "orders_1"."user_id" = "users"."id"))`
+		if query != expected {
+			t.Errorf("Expected: %s\nGot: %s", expected, query)
+		}
+	})
+
+	invalid := []struct {
+		name   string
+		filter *Filter
+	}{
+		{"invalid table", NewFilter().WhereExists(nil, "orders; DROP", Correlate("user_id", "id")).Build()},
+		{"unknown outer column", NewFilter().WhereExists(nil, "orders", Correlate("user_id", "missing")).Build()},
+		{"invalid inner column", NewFilter().WhereExists(nil, "orders", Correlate("user id", "id")).Build()},
+		{"invalid sub field", NewFilter().WhereExists(NewFilter().Where("a-b", OpEqual, 1).Build(), "orders").Build()},
+		{"sub filter with limit", NewFilter().WhereExists(NewFilter().Limit(1).Build(), "orders").Build()},
+		{"wrong value", NewFilter().Where("", OpExists, "orders").Build()},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := conn.queryBuilder(tt.filter); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestInMemoryExists(t *testing.T) {
+	ctx := context.Background()
+	manager := int64(1)
+
+	users := NewInMemoryConnector[existsUser, int64](func(u *existsUser) int64 { return u.ID })
+	orders := NewInMemoryConnector[existsOrder, int64](func(o *existsOrder) int64 { return o.ID })
+	items := NewInMemoryConnector[existsItem, int64](func(i *existsItem) int64 { return i.ID })
+	users.RegisterTable("orders", orders)
+	users.RegisterTable("order_items", items)
+	users.RegisterTable("users", users)
+
+	users.BatchCreate(ctx, []existsUser{
+		{ID: 1, Name: "alice"},
+		{ID: 2, Name: "bob", ManagerID: &manager},
+		{ID: 3, Name: "carol", ManagerID: &manager},
+	})
+	orders.BatchCreate(ctx, []existsOrder{
+		{ID: 10, UserID: 1, Status: "open"},
+		{ID: 11, UserID: 2, Status: "paid"},
+		{ID: 12, UserID: 2, Status: "open"},
+	})
+	items.BatchCreate(ctx, []existsItem{
+		{ID: 100, OrderID: 11, SKU: "BK-1"},
+		{ID: 101, OrderID: 10, SKU: "TOY-1"},
+	})
+
+	tests := []struct {
+		name     string
+		filter   *FilterBuilder
+		expected []int64
+	}{
+		{"has orders", NewFilter().WhereExists(nil, "orders", Correlate("user_id", "id")), []int64{1, 2}},
+		{"has paid orders", NewFilter().WhereExists(NewFilter().Where("status", OpEqual, "paid").Build(), "orders", Correlate("user_id", "id")), []int64{2}},
+		{"no orders", NewFilter().Not(Exists(nil, "orders", Correlate("user_id", "id"))), []int64{3}},
+		{"has reports", NewFilter().WhereExists(nil, "users", Correlate("manager_id", "id")), []int64{1}},
+		{"nested", NewFilter().WhereExists(
+			NewFilter().WhereExists(NewFilter().Where("sku", OpLike, "BK-%").Build(), "order_items", Correlate("order_id", "id")).Build(),
+			"orders", Correlate("user_id", "id"),
+		), []int64{2}},
+		{"combined with OR", NewFilter().Or(
+			Condition{Field: "name", Operator: OpEqual, Value: "carol"},
+			Exists(NewFilter().Where("status", OpEqual, "paid").Build(), "orders", Correlate("user_id", "id")),
+		), []int64{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := users.Query(ctx, tt.filter.OrderBy("id", SortAsc).Build())
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			var ids []int64
+			for _, r := range results {
+				ids = append(ids, r.ID)
+			}
+			if len(ids) != len(tt.expected) {
+				t.Fatalf("Expected IDs %v, got %v", tt.expected, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.expected[i] {
+					t.Fatalf("Expected IDs %v, got %v", tt.expected, ids)
+				}
+			}
+		})
+	}
+
+	t.Run("Count and DeleteWhere", func(t *testing.T) {
+		noOrders := NewFilter().Not(Exists(nil, "orders", Correlate("user_id", "id"))).Build()
+		count, err := users.Count(ctx, noOrders)
+		if err != nil || count != 1 {
+			t.Fatalf("Expected count 1, got %d (%v)", count, err)
+		}
+		deleted, err := users.DeleteWhere(ctx, noOrders)
+		if err != nil || deleted != 1 {
+			t.Fatalf("Expected 1 deleted, got %d (%v)", deleted, err)
+		}
+	})
+
+	t.Run("Unregistered table", func(t *testing.T) {
+		_, err := orders.Query(ctx, NewFilter().WhereExists(nil, "users", Correlate("id", "user_id")).Build())
+		if !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter, got %v", err)
+		}
+	})
+
+	t.Run("Tables registered while querying", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				users.RegisterTable(fmt.Sprintf("extra_%d", i), items)
+			}
+		}()
+		for i := 0; i < 100; i++ {
+			if _, err := users.Query(ctx, NewFilter().WhereExists(nil, "orders", Correlate("user_id", "id")).Build()); err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+		}
+		wg.Wait()
+	})
+}
+
+type subqueryOrder struct {
+	ID        int64     `db:"id"`
+	AccountID *int64    `db:"account_id"`
+	PlacedAt  time.Time `db:"placed_at"`
+}
+
+func TestCockroachDBQueryBuilder_InSubquery(t *testing.T) {
+	users, err := NewCockroachDBConnector[existsUser, int64](&pgxpool.Pool{}, "users", func(u *existsUser) int64 { return u.ID })
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+	orders, err := NewCockroachDBConnector[existsOrder, int64](&pgxpool.Pool{}, "orders", func(o *existsOrder) int64 { return o.ID })
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		filter        *Filter
+		expectedWhere string
+		expectedArgs  int
+	}{
+		{
+			"with filter",
+			NewFilter().Where("name", OpNotEqual, "root").WhereInSubquery("id", orders, NewFilter().Where("status", OpEqual, "open").Build(), "user_id").Build(),
+			`"name" != $1 AND "id" IN (SELECT "orders_1"."user_id" FROM "orders" AS "orders_1" WHERE "orders_1"."status" = $2)`,
+			2,
+		},
+		{
+			"without filter",
+			NewFilter().WhereInSubquery("id", orders, nil, "user_id").Build(),
+			`"id" IN (SELECT "orders_1"."user_id" FROM "orders" AS "orders_1")`,
+			0,
+		},
+		{
+			"nested",
+			NewFilter().WhereInSubquery("id", orders,
+				NewFilter().WhereInSubquery("user_id", users, NewFilter().Where("name", OpLike, "a%").Build(), "id").Build(),
+				"user_id").Build(),
+			`"id" IN (SELECT "orders_1"."user_id" FROM "orders" AS "orders_1" WHERE "orders_1"."user_id" IN (SELECT "users_2"."id" FROM "users" AS "users_2" WHERE "users_2"."name" LIKE $1))`,
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := users.queryBuilder(tt.filter)
+			if err != nil {
+				t.Fatalf("queryBuilder failed: %v", err)
+			}
+			expected := `SELECT "id", "name", "manager_id" FROM "users" WHERE ` + tt.expectedWhere
+			if query != expected {
+				t.Errorf("Expected: %s\nGot: %s", expected, query)
+			}
+			if len(args) != tt.expectedArgs {
+				t.Errorf("Expected %d args, got %d", tt.expectedArgs, len(args))
+			}
+		})
+	}
+
+	invalid := []struct {
+		name   string
+		filter *Filter
+	}{
+		{"unknown source field", NewFilter().WhereInSubquery("id", orders, nil, "missing").Build()},
+		{"unknown source filter field", NewFilter().WhereInSubquery("id", orders, NewFilter().Where("missing", OpEqual, 1).Build(), "user_id").Build()},
+		{"unknown outer field", NewFilter().WhereInSubquery("missing", orders, nil, "user_id").Build()},
+		{"in-memory source", NewFilter().WhereInSubquery("id", NewInMemoryConnector[existsOrder, int64](func(o *existsOrder) int64 { return o.ID }), nil, "user_id").Build()},
+		{"sub filter with sort", NewFilter().WhereInSubquery("id", orders, NewFilter().OrderBy("id", SortAsc).Build(), "user_id").Build()},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := users.queryBuilder(tt.filter); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestInMemoryInSubquery(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	one, two := int64(1), int64(2)
+
+	accounts := NewInMemoryConnector[existsUser, int64](func(u *existsUser) int64 { return u.ID })
+	orders := NewInMemoryConnector[subqueryOrder, int64](func(o *subqueryOrder) int64 { return o.ID })
+
+	accounts.BatchCreate(ctx, []existsUser{{ID: 1, Name: "alice"}, {ID: 2, Name: "bob"}, {ID: 3, Name: "carol"}})
+	orders.BatchCreate(ctx, []subqueryOrder{
+		{ID: 10, AccountID: &one, PlacedAt: now.Add(-24 * time.Hour)},
+		{ID: 11, AccountID: &two, PlacedAt: now.Add(-30 * 24 * time.Hour)},
+		{ID: 12, PlacedAt: now},
+	})
+
+	ids := func(t *testing.T, filter *Filter) []int64 {
+		t.Helper()
+		results, err := accounts.Query(ctx, filter)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		var ids []int64
+		for _, r := range results {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	t.Run("Accounts with an order in the last 7 days", func(t *testing.T) {
+		got := ids(t, NewFilter().
+			WhereInSubquery("id", orders, NewFilter().WhereNewerThan("placed_at", 7*24*time.Hour).Build(), "account_id").
+			Build())
+		if len(got) != 1 || got[0] != 1 {
+			t.Errorf("Expected [1], got %v", got)
+		}
+	})
+
+	t.Run("Accounts without orders", func(t *testing.T) {
+		got := ids(t, NewFilter().
+			Not(InSubqueryOf("id", orders, nil, "account_id")).
+			Build())
+		if len(got) != 1 || got[0] != 3 {
+			t.Errorf("Expected [3], got %v", got)
+		}
+	})
+
+	t.Run("Self reference", func(t *testing.T) {
+		got := ids(t, NewFilter().
+			WhereInSubquery("id", accounts, NewFilter().Where("name", OpEqual, "bob").Build(), "id").
+			Build())
+		if len(got) != 1 || got[0] != 2 {
+			t.Errorf("Expected [2], got %v", got)
+		}
+	})
+
+	t.Run("Unknown source field", func(t *testing.T) {
+		if _, err := accounts.Query(ctx, NewFilter().WhereInSubquery("id", orders, nil, "missing").Build()); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
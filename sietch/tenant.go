@@ -0,0 +1,441 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// DefaultTenantColumn is the tenant column used when none is given
+const DefaultTenantColumn = "tenant_id"
+
+// tenantKey is the context key type of the current tenant
+type tenantKey struct{}
+
+// WithTenant returns a context scoping the operations of a
+// TenantScopedRepository to tenant, e.g. set by an authentication middleware
+func WithTenant(ctx context.Context, tenant any) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set with WithTenant
+func TenantFromContext(ctx context.Context) (any, bool) {
+	tenant := ctx.Value(tenantKey{})
+	return tenant, tenant != nil
+}
+
+// TenantScopedRepository wraps a repository and confines every operation to
+// the tenant of its context (see WithTenant), so tenant isolation does not
+// depend on each call site adding the right condition:
+//
+//   - Query, FindOne, Count, UpdateWhere, DeleteWhere and GroupCount get a
+//     "<column> = <tenant>" condition on a copy of the filter.
+//   - Create, Update, Upsert and their batch versions set the tenant field of
+//     the items; UpdateWhere may not change it.
+//   - Get, GetMany, Exists and GetForUpdate hide the items of other tenants,
+//     and Update, Upsert and Delete refuse to touch them.
+//
+// Operations fail with ErrMissingTenant if the context has no tenant. If base
+// implements Transactional, the owner checks of Update, Upsert, Delete and
+// their batch versions run in a transaction with the write, so an item
+// deleted and recreated by another tenant in between is never overwritten;
+// otherwise the check and the write are separate operations.
+type TenantScopedRepository[T any, ID comparable] struct {
+	base   Repository[T, ID]
+	getID  func(*T) ID
+	column string
+	field  []int
+}
+
+// NewTenantScopedRepository creates a repository scoping base to the tenant
+// of each context. column is the tenant column, DefaultTenantColumn if empty;
+// T must have a field with that db tag.
+func NewTenantScopedRepository[T any, ID comparable](base Repository[T, ID], getID func(*T) ID, column string) (*TenantScopedRepository[T, ID], error) {
+	getID, err := resolveGetID(getID)
+	if err != nil {
+		return nil, err
+	}
+	if column == "" {
+		column = DefaultTenantColumn
+	}
+	codec, err := newEntityCodec[T]()
+	if err != nil {
+		return nil, err
+	}
+	i := slices.Index(codec.columns, column)
+	if i < 0 {
+		return nil, fmt.Errorf("entity has no %s column", column)
+	}
+	return &TenantScopedRepository[T, ID]{
+		base:   base,
+		getID:  getID,
+		column: column,
+		field:  codec.fields[i],
+	}, nil
+}
+
+// tenant returns the tenant of ctx as a value of the tenant field's type
+func (r *TenantScopedRepository[T, ID]) tenant(ctx context.Context) (reflect.Value, error) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return reflect.Value{}, ErrMissingTenant
+	}
+	v := reflect.ValueOf(tenant)
+	fieldType := reflect.TypeFor[T]().FieldByIndex(r.field).Type
+	if !v.Type().AssignableTo(fieldType) && (v.Kind() != fieldType.Kind() || !v.CanConvert(fieldType)) {
+		return reflect.Value{}, fmt.Errorf("tenant of type %s cannot be stored in column %s of type %s", v.Type(), r.column, fieldType)
+	}
+	return v.Convert(fieldType), nil
+}
+
+// guarded runs fn, which checks the owners of items and writes them, in a
+// transaction of the base repository if it implements Transactional
+func (r *TenantScopedRepository[T, ID]) guarded(ctx context.Context, fn func(base Repository[T, ID]) error) error {
+	txRepo, ok := r.base.(Transactional[T, ID])
+	if !ok {
+		return fn(r.base)
+	}
+	var fnErr error
+	err := txRepo.WithTx(ctx, func(tx Repository[T, ID]) error {
+		fnErr = fn(tx)
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// owns reports whether item belongs to tenant
+func (r *TenantScopedRepository[T, ID]) owns(item *T, tenant reflect.Value) bool {
+	field, ok := fieldByIndex(reflect.ValueOf(item).Elem(), r.field)
+	return ok && field.Equal(tenant)
+}
+
+// stamp sets the tenant field of item
+func (r *TenantScopedRepository[T, ID]) stamp(item *T, tenant reflect.Value) {
+	settableField(reflect.ValueOf(item).Elem(), r.field).Set(tenant)
+}
+
+// scope returns a copy of filter restricted to tenant
+func (r *TenantScopedRepository[T, ID]) scope(filter *Filter, tenant reflect.Value) *Filter {
+	var scoped Filter
+	if filter != nil {
+		scoped = *filter
+	}
+	scoped.Conditions = append(slices.Clone(scoped.Conditions), Condition{Field: r.column, Operator: OpEqual, Value: tenant.Interface()})
+	return &scoped
+}
+
+// foreign returns the IDs of ids whose items in base exist but belong to
+// another tenant
+func (r *TenantScopedRepository[T, ID]) foreign(ctx context.Context, base Repository[T, ID], ids []ID, tenant reflect.Value) ([]ID, error) {
+	items, err := base.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	var foreign []ID
+	for _, id := range ids {
+		if item, ok := items[id]; ok && !r.owns(item, tenant) {
+			foreign = append(foreign, id)
+		}
+	}
+	return foreign, nil
+}
+
+// stampAll sets the tenant field of items and returns their IDs
+func (r *TenantScopedRepository[T, ID]) stampAll(items []T, tenant reflect.Value) []ID {
+	ids := make([]ID, len(items))
+	for i := range items {
+		r.stamp(&items[i], tenant)
+		ids[i] = r.getID(&items[i])
+	}
+	return ids
+}
+
+func (r *TenantScopedRepository[T, ID]) Create(ctx context.Context, item *T) error {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	r.stamp(item, tenant)
+	return r.base.Create(ctx, item)
+}
+
+func (r *TenantScopedRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	item, err := r.base.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !r.owns(item, tenant) {
+		return nil, ErrItemNotFound
+	}
+	return item, nil
+}
+
+func (r *TenantScopedRepository[T, ID]) GetMany(ctx context.Context, ids []ID) (map[ID]*T, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := r.base.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for id, item := range items {
+		if !r.owns(item, tenant) {
+			delete(items, id)
+		}
+	}
+	return items, nil
+}
+
+func (r *TenantScopedRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	items, err := r.GetMany(ctx, []ID{id})
+	if err != nil {
+		return false, err
+	}
+	return len(items) > 0, nil
+}
+
+func (r *TenantScopedRepository[T, ID]) BatchCreate(ctx context.Context, items []T) error {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	r.stampAll(items, tenant)
+	return r.base.BatchCreate(ctx, items)
+}
+
+func (r *TenantScopedRepository[T, ID]) Query(ctx context.Context, filter *Filter) ([]T, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.base.Query(ctx, r.scope(filter, tenant))
+}
+
+func (r *TenantScopedRepository[T, ID]) FindOne(ctx context.Context, filter *Filter) (*T, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.base.FindOne(ctx, r.scope(filter, tenant))
+}
+
+func (r *TenantScopedRepository[T, ID]) Count(ctx context.Context, filter *Filter) (int64, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return r.base.Count(ctx, r.scope(filter, tenant))
+}
+
+// Update updates an item of the tenant; the items of other tenants are
+// reported as missing with ErrNoUpdateItem
+func (r *TenantScopedRepository[T, ID]) Update(ctx context.Context, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	r.stamp(item, tenant)
+	return r.guarded(ctx, func(base Repository[T, ID]) error {
+		if err := r.checkUpdate(ctx, base, []ID{r.getID(item)}, tenant); err != nil {
+			return err
+		}
+		return base.Update(ctx, item)
+	})
+}
+
+func (r *TenantScopedRepository[T, ID]) BatchUpdate(ctx context.Context, items []T) error {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	ids := r.stampAll(items, tenant)
+	return r.guarded(ctx, func(base Repository[T, ID]) error {
+		if err := r.checkUpdate(ctx, base, ids, tenant); err != nil {
+			return err
+		}
+		return base.BatchUpdate(ctx, items)
+	})
+}
+
+// checkUpdate fails with a MissingItemsError if any of ids belongs to
+// another tenant
+func (r *TenantScopedRepository[T, ID]) checkUpdate(ctx context.Context, base Repository[T, ID], ids []ID, tenant reflect.Value) error {
+	foreign, err := r.foreign(ctx, base, ids, tenant)
+	if err != nil {
+		return err
+	}
+	if len(foreign) > 0 {
+		return &MissingItemsError[ID]{IDs: foreign}
+	}
+	return nil
+}
+
+// Delete deletes an item of the tenant; the items of other tenants are
+// reported as missing with ErrNoDeleteItem
+func (r *TenantScopedRepository[T, ID]) Delete(ctx context.Context, id ID) error {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return r.guarded(ctx, func(base Repository[T, ID]) error {
+		if err := r.checkDelete(ctx, base, []ID{id}, tenant); err != nil {
+			return err
+		}
+		return base.Delete(ctx, id)
+	})
+}
+
+func (r *TenantScopedRepository[T, ID]) BatchDelete(ctx context.Context, ids []ID) error {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return r.guarded(ctx, func(base Repository[T, ID]) error {
+		if err := r.checkDelete(ctx, base, ids, tenant); err != nil {
+			return err
+		}
+		return base.BatchDelete(ctx, ids)
+	})
+}
+
+// checkDelete fails with ErrNoDeleteItem if any of ids belongs to another
+// tenant
+func (r *TenantScopedRepository[T, ID]) checkDelete(ctx context.Context, base Repository[T, ID], ids []ID, tenant reflect.Value) error {
+	foreign, err := r.foreign(ctx, base, ids, tenant)
+	if err != nil {
+		return err
+	}
+	if len(foreign) > 0 {
+		return fmt.Errorf("%w: %v", ErrNoDeleteItem, foreign)
+	}
+	return nil
+}
+
+// Upsert creates or updates an item of the tenant; an item of another
+// tenant with the same ID fails with ErrItemAlreadyExists
+func (r *TenantScopedRepository[T, ID]) Upsert(ctx context.Context, item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	r.stamp(item, tenant)
+	return r.guarded(ctx, func(base Repository[T, ID]) error {
+		if err := r.checkUpsert(ctx, base, []ID{r.getID(item)}, tenant); err != nil {
+			return err
+		}
+		return base.Upsert(ctx, item)
+	})
+}
+
+func (r *TenantScopedRepository[T, ID]) BatchUpsert(ctx context.Context, items []T) error {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	ids := r.stampAll(items, tenant)
+	return r.guarded(ctx, func(base Repository[T, ID]) error {
+		if err := r.checkUpsert(ctx, base, ids, tenant); err != nil {
+			return err
+		}
+		return base.BatchUpsert(ctx, items)
+	})
+}
+
+// checkUpsert fails with ErrItemAlreadyExists if any of ids belongs to
+// another tenant
+func (r *TenantScopedRepository[T, ID]) checkUpsert(ctx context.Context, base Repository[T, ID], ids []ID, tenant reflect.Value) error {
+	foreign, err := r.foreign(ctx, base, ids, tenant)
+	if err != nil {
+		return err
+	}
+	if len(foreign) > 0 {
+		return fmt.Errorf("%w: %v", ErrItemAlreadyExists, foreign)
+	}
+	return nil
+}
+
+func (r *TenantScopedRepository[T, ID]) UpdateWhere(ctx context.Context, filter *Filter, updates map[string]any) (int64, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := updates[r.column]; ok {
+		return 0, fmt.Errorf("%w: the tenant column %s cannot be updated", ErrUnsupportedOperation, r.column)
+	}
+	return r.base.UpdateWhere(ctx, r.scope(filter, tenant), updates)
+}
+
+func (r *TenantScopedRepository[T, ID]) DeleteWhere(ctx context.Context, filter *Filter) (int64, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return r.base.DeleteWhere(ctx, r.scope(filter, tenant))
+}
+
+// GroupCount scopes the filter and delegates to the base repository if it
+// implements Grouper
+func (r *TenantScopedRepository[T, ID]) GroupCount(ctx context.Context, filter *Filter) ([]GroupCount, error) {
+	grouper, ok := r.base.(Grouper)
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return grouper.GroupCount(ctx, r.scope(filter, tenant))
+}
+
+// GetForUpdate delegates to the base repository if it implements RowLocker,
+// hiding the items of other tenants
+func (r *TenantScopedRepository[T, ID]) GetForUpdate(ctx context.Context, id ID) (*T, error) {
+	locker, ok := r.base.(RowLocker[T, ID])
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	item, err := locker.GetForUpdate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !r.owns(item, tenant) {
+		return nil, ErrItemNotFound
+	}
+	return item, nil
+}
+
+// WithTx runs fn in a transaction of the base repository, which must
+// implement Transactional. The repository passed to fn is scoped the same way.
+func (r *TenantScopedRepository[T, ID]) WithTx(ctx context.Context, fn TxFunc[T, ID]) error {
+	txRepo, ok := r.base.(Transactional[T, ID])
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	return txRepo.WithTx(ctx, func(tx Repository[T, ID]) error {
+		scoped := *r
+		scoped.base = tx
+		return fn(&scoped)
+	})
+}
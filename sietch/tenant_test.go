@@ -0,0 +1,103 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type tenantAccount struct {
+	ID       int64  `db:"id"`
+	TenantID string `db:"tenant_id"`
+	Balance  int    `db:"balance"`
+}
+
+type byteTenantAccount struct {
+	ID       int64   `db:"id"`
+	TenantID [2]byte `db:"tenant_id"`
+}
+
+func byteTenantID(a *byteTenantAccount) int64 { return a.ID }
+
+func TestTenantScopedRepository(t *testing.T) {
+	getID := func(a *tenantAccount) int64 { return a.ID }
+	base := NewInMemoryConnector[tenantAccount](getID)
+	repo, err := NewTenantScopedRepository[tenantAccount, int64](base, getID, "")
+	if err != nil {
+		t.Fatalf("NewTenantScopedRepository failed: %v", err)
+	}
+
+	acme := WithTenant(context.Background(), "acme")
+	globex := WithTenant(context.Background(), "globex")
+
+	if err := repo.BatchCreate(acme, []tenantAccount{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}}); err != nil {
+		t.Fatalf("BatchCreate failed: %v", err)
+	}
+	item := &tenantAccount{ID: 3, TenantID: "acme", Balance: 30}
+	if err := repo.Create(globex, item); err != nil || item.TenantID != "globex" {
+		t.Fatalf("Expected the tenant to be set on create, got %+v (%v)", item, err)
+	}
+
+	t.Run("Reads only see the tenant's items", func(t *testing.T) {
+		if count, _ := repo.Count(acme, nil); count != 2 {
+			t.Errorf("Expected 2 acme items, got %d", count)
+		}
+		filter := NewFilter().Where("balance", OpGreaterThan, 15).Build()
+		results, err := repo.Query(globex, filter)
+		if err != nil || len(results) != 1 || results[0].ID != 3 {
+			t.Errorf("Expected only item 3, got %+v (%v)", results, err)
+		}
+		if len(filter.Conditions) != 1 {
+			t.Error("Expected the caller's filter to be unchanged")
+		}
+		if _, err := repo.Get(globex, 1); !errors.Is(err, ErrItemNotFound) {
+			t.Errorf("Expected another tenant's item to be hidden, got %v", err)
+		}
+		if exists, _ := repo.Exists(acme, 3); exists {
+			t.Error("Expected another tenant's item not to exist")
+		}
+		if items, _ := repo.GetMany(acme, []int64{1, 2, 3}); len(items) != 2 {
+			t.Errorf("Expected 2 items, got %v", items)
+		}
+	})
+
+	t.Run("Writes cannot touch other tenants", func(t *testing.T) {
+		if err := repo.Update(globex, &tenantAccount{ID: 1, Balance: 0}); !errors.Is(err, ErrNoUpdateItem) {
+			t.Errorf("Expected ErrNoUpdateItem, got %v", err)
+		}
+		if err := repo.Delete(globex, 1); !errors.Is(err, ErrNoDeleteItem) {
+			t.Errorf("Expected ErrNoDeleteItem, got %v", err)
+		}
+		if err := repo.Upsert(globex, &tenantAccount{ID: 2}); !errors.Is(err, ErrItemAlreadyExists) {
+			t.Errorf("Expected ErrItemAlreadyExists, got %v", err)
+		}
+		if n, _ := repo.DeleteWhere(globex, &Filter{}); n != 1 {
+			t.Errorf("Expected to delete only globex's item, got %d", n)
+		}
+		if _, err := repo.UpdateWhere(acme, &Filter{}, map[string]any{"tenant_id": "globex"}); !errors.Is(err, ErrUnsupportedOperation) {
+			t.Errorf("Expected moving items between tenants to fail, got %v", err)
+		}
+		if stored, _ := base.Get(context.Background(), 1); stored.Balance != 10 || stored.TenantID != "acme" {
+			t.Errorf("Expected item 1 to be untouched, got %+v", stored)
+		}
+	})
+
+	t.Run("Operations need a tenant", func(t *testing.T) {
+		if _, err := repo.Query(context.Background(), nil); !errors.Is(err, ErrMissingTenant) {
+			t.Errorf("Expected ErrMissingTenant, got %v", err)
+		}
+		if _, err := repo.Get(WithTenant(context.Background(), 42), 1); err == nil {
+			t.Error("Expected a tenant of the wrong type to fail")
+		}
+		byteTenants, err := NewTenantScopedRepository(NewInMemoryConnector[byteTenantAccount](byteTenantID), byteTenantID, "")
+		if err != nil {
+			t.Fatalf("NewTenantScopedRepository failed: %v", err)
+		}
+		if _, err := byteTenants.Get(WithTenant(context.Background(), [4]byte{}), 1); err == nil {
+			t.Error("Expected a tenant that cannot be converted to fail")
+		}
+		if _, err := NewTenantScopedRepository[tenantAccount, int64](base, getID, "org_id"); err == nil {
+			t.Error("Expected an error for a missing tenant column")
+		}
+	})
+}
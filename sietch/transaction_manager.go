@@ -10,7 +10,8 @@ import (
 
 // TransactionManager manages database transactions across multiple repositories
 type TransactionManager struct {
-	pool *pgxpool.Pool
+	pool  *pgxpool.Pool
+	retry TxRetryOptions // reruns of serialization failures, see SetRetryOptions
 }
 
 // MultiRepoTxFunc is a function that executes operations within a transaction context
@@ -25,15 +26,36 @@ func NewTransactionManager(pool *pgxpool.Pool) *TransactionManager {
 	return &TransactionManager{pool: pool}
 }
 
+// SetRetryOptions configures how WithTx reruns transactions failing with a
+// serialization failure, DefaultTxMaxRetries times by default
+func (tm *TransactionManager) SetRetryOptions(opts TxRetryOptions) {
+	tm.retry = opts
+}
+
 // WithTx executes the provided function within a transaction
 // If the function returns an error, the transaction is rolled back
 // If the function completes successfully, the transaction is committed
 // The transaction is also rolled back if a panic occurs
+// If the transaction fails with a serialization failure, the function runs
+// again in a new transaction, so it must not have side effects outside it
 func (tm *TransactionManager) WithTx(ctx context.Context, fn MultiRepoTxFunc) error {
+	return tm.WithTxOptions(ctx, TxOptions{}, fn)
+}
+
+// WithTxOptions is WithTx with the isolation level, access mode and priority
+// of opts instead of the pool defaults
+func (tm *TransactionManager) WithTxOptions(ctx context.Context, opts TxOptions, fn MultiRepoTxFunc) error {
+	return retryTx(ctx, tm.retry, func() error {
+		return tm.runTx(ctx, opts, fn)
+	})
+}
+
+// runTx runs fn in one transaction
+func (tm *TransactionManager) runTx(ctx context.Context, opts TxOptions, fn MultiRepoTxFunc) error {
 	// Begin transaction
-	tx, err := tm.pool.Begin(ctx)
+	tx, err := beginTx(ctx, tm.pool, opts)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
 
 	// Setup panic recovery and defer rollback/commit
@@ -78,3 +100,28 @@ func getTxFromContext(ctx context.Context) (pgx.Tx, bool) {
 	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
 	return tx, ok
 }
+
+// getPoolTxFromContext extracts the transaction of pool from context: the
+// one a TxCoordinator started on pool, or else the TransactionManager's.
+// Inside a TxCoordinator transaction, a pool that is not a participant
+// fails with ErrNotParticipant rather than writing outside the transaction.
+func getPoolTxFromContext(ctx context.Context, pool *pgxpool.Pool) (pgx.Tx, bool, error) {
+	txs, coordinated := ctx.Value(poolTxsKey{}).(map[*pgxpool.Pool]pgx.Tx)
+	if tx, ok := txs[pool]; ok {
+		return tx, true, nil
+	}
+	if tx, ok := getTxFromContext(ctx); ok {
+		return tx, true, nil
+	}
+	if coordinated {
+		return nil, false, ErrNotParticipant
+	}
+	return nil, false, nil
+}
+
+// inTransaction reports whether ctx carries a transaction of a
+// TransactionManager or TxCoordinator
+func inTransaction(ctx context.Context) bool {
+	_, inTx := getTxFromContext(ctx)
+	return inTx || ctx.Value(poolTxsKey{}) != nil
+}
@@ -0,0 +1,155 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// poolTxsKey is the context key of the transactions of a TxCoordinator, by pool
+type poolTxsKey struct{}
+
+// TxParticipant is a pool taking part in the transactions of a TxCoordinator
+type TxParticipant struct {
+	Name    string // identifies the participant in errors
+	Pool    *pgxpool.Pool
+	Options TxOptions
+
+	// Prepare runs once the transaction function succeeded, before any
+	// participant commits, with the transactions in ctx. An error rolls
+	// every participant back: check here whatever could fail the commit.
+	Prepare func(ctx context.Context) error
+
+	// AfterCommit runs once the participant committed
+	AfterCommit func(ctx context.Context)
+}
+
+// TxCoordinator runs a MultiRepoTxFunc in one transaction per pool, for
+// repositories backed by different pools or databases, which a
+// TransactionManager cannot span. Connectors find the transaction of their
+// pool in the context passed to the function; those of other pools fail
+// with ErrNotParticipant.
+//
+// The commit is not atomic: every participant is prepared, then the
+// transactions are committed one by one in participant order. A
+// commit failing after others succeeded cannot be undone; the remaining
+// participants are rolled back and a *PartialCommitError reports which
+// committed. Put the participant most likely to fail first.
+type TxCoordinator struct {
+	participants []TxParticipant
+	retry        TxRetryOptions // reruns of serialization failures, see SetRetryOptions
+}
+
+// NewTxCoordinator creates a coordinator of participants, which must have
+// distinct names and pools
+func NewTxCoordinator(participants ...TxParticipant) (*TxCoordinator, error) {
+	if len(participants) == 0 {
+		return nil, fmt.Errorf("at least one participant is required")
+	}
+	names := make(map[string]bool, len(participants))
+	pools := make(map[*pgxpool.Pool]bool, len(participants))
+	for _, p := range participants {
+		if p.Name == "" || p.Pool == nil {
+			return nil, fmt.Errorf("participants need a name and a pool")
+		}
+		if names[p.Name] || pools[p.Pool] {
+			return nil, fmt.Errorf("participant %s repeats a name or pool", p.Name)
+		}
+		if _, err := p.Options.pgxOptions(); err != nil {
+			return nil, fmt.Errorf("participant %s: %w", p.Name, err)
+		}
+		names[p.Name], pools[p.Pool] = true, true
+	}
+	return &TxCoordinator{participants: participants}, nil
+}
+
+// SetRetryOptions configures how WithTx reruns transactions failing with a
+// serialization failure before any participant committed
+func (c *TxCoordinator) SetRetryOptions(opts TxRetryOptions) {
+	c.retry = opts
+}
+
+// WithTx runs fn with a transaction of every participant in its context.
+// If fn returns an error or panics, or a participant fails to prepare,
+// every transaction is rolled back; otherwise they are committed.
+func (c *TxCoordinator) WithTx(ctx context.Context, fn MultiRepoTxFunc) error {
+	return retryTx(ctx, c.retry, func() error {
+		return c.runTx(ctx, fn)
+	})
+}
+
+// runTx runs fn in one transaction per participant
+func (c *TxCoordinator) runTx(ctx context.Context, fn MultiRepoTxFunc) error {
+	txs := make([]pgx.Tx, 0, len(c.participants))
+	byPool := make(map[*pgxpool.Pool]pgx.Tx, len(c.participants))
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollbackAll(ctx, txs)
+			panic(p)
+		}
+	}()
+
+	for _, p := range c.participants {
+		tx, err := beginTx(ctx, p.Pool, p.Options)
+		if err != nil {
+			rollbackAll(ctx, txs)
+			return fmt.Errorf("participant %s: %w", p.Name, err)
+		}
+		txs = append(txs, tx)
+		byPool[p.Pool] = tx
+	}
+	txCtx := context.WithValue(ctx, poolTxsKey{}, byPool)
+
+	if err := fn(txCtx); err != nil {
+		rollbackAll(ctx, txs)
+		return err
+	}
+	for _, p := range c.participants {
+		if p.Prepare == nil {
+			continue
+		}
+		if err := p.Prepare(txCtx); err != nil {
+			rollbackAll(ctx, txs)
+			return fmt.Errorf("participant %s failed to prepare: %w", p.Name, err)
+		}
+	}
+
+	for i, p := range c.participants {
+		if err := txs[i].Commit(ctx); err != nil {
+			rollbackAll(ctx, txs[i+1:])
+			if i == 0 {
+				return fmt.Errorf("failed to commit participant %s: %w", p.Name, err)
+			}
+			return &PartialCommitError{
+				Committed:  c.names(0, i),
+				Failed:     p.Name,
+				RolledBack: c.names(i+1, len(c.participants)),
+				Err:        err,
+			}
+		}
+		if p.AfterCommit != nil {
+			p.AfterCommit(ctx)
+		}
+	}
+	return nil
+}
+
+// names returns the names of the participants from i to j
+func (c *TxCoordinator) names(i, j int) []string {
+	names := make([]string, 0, j-i)
+	for _, p := range c.participants[i:j] {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// rollbackAll rolls back txs, ignoring errors: a transaction whose rollback
+// fails is rolled back by the database when its connection closes
+func rollbackAll(ctx context.Context, txs []pgx.Tx) {
+	for _, tx := range txs {
+		_ = tx.Rollback(ctx)
+	}
+}
@@ -0,0 +1,80 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// stubTx is a pgx.Tx told apart by name
+type stubTx struct {
+	pgx.Tx
+	name string
+}
+
+func TestNewTxCoordinator(t *testing.T) {
+	orders, billing := &pgxpool.Pool{}, &pgxpool.Pool{}
+	if _, err := NewTxCoordinator(TxParticipant{Name: "orders", Pool: orders}, TxParticipant{Name: "billing", Pool: billing}); err != nil {
+		t.Fatalf("NewTxCoordinator failed: %v", err)
+	}
+
+	for name, participants := range map[string][]TxParticipant{
+		"no participants": nil,
+		"missing pool":    {{Name: "orders"}},
+		"missing name":    {{Pool: orders}},
+		"repeated name":   {{Name: "orders", Pool: orders}, {Name: "orders", Pool: billing}},
+		"repeated pool":   {{Name: "orders", Pool: orders}, {Name: "billing", Pool: orders}},
+		"invalid options": {{Name: "orders", Pool: orders, Options: TxOptions{Priority: "URGENT"}}},
+	} {
+		if _, err := NewTxCoordinator(participants...); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestGetPoolTxFromContext(t *testing.T) {
+	orders, billing, other := &pgxpool.Pool{}, &pgxpool.Pool{}, &pgxpool.Pool{}
+	ctx := context.Background()
+	if _, ok, err := getPoolTxFromContext(ctx, orders); ok || err != nil || inTransaction(ctx) {
+		t.Error("Expected no transaction")
+	}
+
+	ctx = context.WithValue(ctx, poolTxsKey{}, map[*pgxpool.Pool]pgx.Tx{
+		orders:  &stubTx{name: "orders"},
+		billing: &stubTx{name: "billing"},
+	})
+	if tx, ok, _ := getPoolTxFromContext(ctx, billing); !ok || tx.(*stubTx).name != "billing" {
+		t.Errorf("Expected the billing transaction, got %v", tx)
+	}
+	if _, ok, err := getPoolTxFromContext(ctx, other); ok || !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("Expected ErrNotParticipant for a pool outside the coordinator, got %v", err)
+	}
+	conn := &CockroachDBConnector[taggedDocument, int64]{pool: other}
+	if err := conn.getQueryable(ctx).QueryRow(ctx, "SELECT 1").Scan(); !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("Expected statements of a pool outside the coordinator to fail, got %v", err)
+	}
+	if !inTransaction(ctx) {
+		t.Error("Expected the coordinator's transactions to count as a transaction")
+	}
+}
+
+func TestPartialCommitError(t *testing.T) {
+	serialization := &pgconn.PgError{Code: pgCodeSerializationFailure}
+	err := error(&PartialCommitError{Committed: []string{"orders"}, Failed: "billing", RolledBack: []string{"audit"}, Err: serialization})
+	if !errors.Is(err, ErrPartialCommit) || !isSerializationFailure(err) {
+		t.Errorf("Expected ErrPartialCommit wrapping the commit error, got %v", err)
+	}
+
+	attempts := 0
+	_ = retryTx(context.Background(), TxRetryOptions{Backoff: noBackoff{}}, func() error {
+		attempts++
+		return err
+	})
+	if attempts != 1 {
+		t.Errorf("Expected partial commits not to be rerun, got %d attempts", attempts)
+	}
+}
@@ -0,0 +1,96 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// IsolationLevel is the isolation level of a transaction
+type IsolationLevel string
+
+const (
+	IsolationDefault        IsolationLevel = "" // the database default
+	IsolationSerializable   IsolationLevel = "serializable"
+	IsolationRepeatableRead IsolationLevel = "repeatable read"
+	IsolationReadCommitted  IsolationLevel = "read committed"
+)
+
+// TxPriority is the priority of a CockroachDB transaction in contention:
+// lower priority transactions are aborted or wait first
+type TxPriority string
+
+const (
+	TxPriorityDefault TxPriority = "" // NORMAL, or the session default
+	TxPriorityLow     TxPriority = "LOW"
+	TxPriorityNormal  TxPriority = "NORMAL"
+	TxPriorityHigh    TxPriority = "HIGH"
+)
+
+// TxOptions configures a transaction started by WithTxOptions. The zero
+// value is a read-write transaction with the database defaults.
+type TxOptions struct {
+	Isolation IsolationLevel
+	ReadOnly  bool
+
+	// Deferrable makes a serializable read-only transaction wait for a
+	// snapshot it can read without serialization failures (PostgreSQL)
+	Deferrable bool
+
+	// Priority sets the CockroachDB transaction priority; PostgreSQL
+	// rejects it
+	Priority TxPriority
+
+	asOf string // AS OF SYSTEM TIME expression of WithReadTx
+}
+
+// pgxOptions validates the options and converts them for pgx
+func (o TxOptions) pgxOptions() (pgx.TxOptions, error) {
+	var opts pgx.TxOptions
+	switch o.Isolation {
+	case IsolationDefault, IsolationSerializable, IsolationRepeatableRead, IsolationReadCommitted:
+		opts.IsoLevel = pgx.TxIsoLevel(o.Isolation)
+	default:
+		return opts, fmt.Errorf("invalid isolation level %q", o.Isolation)
+	}
+	switch o.Priority {
+	case TxPriorityDefault, TxPriorityLow, TxPriorityNormal, TxPriorityHigh:
+	default:
+		return opts, fmt.Errorf("invalid transaction priority %q", o.Priority)
+	}
+	if o.ReadOnly {
+		opts.AccessMode = pgx.ReadOnly
+	}
+	if o.Deferrable {
+		opts.DeferrableMode = pgx.Deferrable
+	}
+	return opts, nil
+}
+
+// beginTx begins a transaction of pool with opts
+func beginTx(ctx context.Context, pool *pgxpool.Pool, opts TxOptions) (pgx.Tx, error) {
+	pgxOpts, err := opts.pgxOptions()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := pool.BeginTx(ctx, pgxOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if opts.asOf != "" {
+		// must be the first statement of the transaction
+		if _, err := tx.Exec(ctx, "SET TRANSACTION AS OF SYSTEM TIME "+opts.asOf); err != nil {
+			_ = tx.Rollback(ctx)
+			return nil, fmt.Errorf("failed to set transaction timestamp: %w", err)
+		}
+	}
+	if opts.Priority != TxPriorityDefault {
+		if _, err := tx.Exec(ctx, "SET TRANSACTION PRIORITY "+string(opts.Priority)); err != nil {
+			_ = tx.Rollback(ctx)
+			return nil, fmt.Errorf("failed to set transaction priority: %w", err)
+		}
+	}
+	return tx, nil
+}
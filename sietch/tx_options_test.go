@@ -0,0 +1,29 @@
+package sietch
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestTxOptionsPgxOptions(t *testing.T) {
+	got, err := TxOptions{}.pgxOptions()
+	if err != nil || got != (pgx.TxOptions{}) {
+		t.Errorf("Expected the pool defaults, got %+v (%v)", got, err)
+	}
+
+	got, err = TxOptions{Isolation: IsolationSerializable, ReadOnly: true, Deferrable: true, Priority: TxPriorityHigh}.pgxOptions()
+	want := pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable}
+	if err != nil || got != want {
+		t.Errorf("Expected %+v, got %+v (%v)", want, got, err)
+	}
+
+	for _, opts := range []TxOptions{
+		{Isolation: "snapshot; DROP TABLE accounts"},
+		{Priority: "URGENT"},
+	} {
+		if _, err := opts.pgxOptions(); err == nil {
+			t.Errorf("Expected %+v to be rejected", opts)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ReadTxOptions configures a transaction started by WithReadTx
+type ReadTxOptions struct {
+	// Staleness reads the data as it was this long ago, with CockroachDB's
+	// AS OF SYSTEM TIME: historical reads don't contend with writes and
+	// never restart. Zero reads the current data; PostgreSQL supports only
+	// that.
+	Staleness time.Duration
+
+	// FollowerRead reads as of follower_read_timestamp(), the most recent
+	// time the nearest replica can serve, instead of Staleness (CockroachDB)
+	FollowerRead bool
+}
+
+// txOptions returns the options of a read-only transaction reading one
+// snapshot: repeatable read on PostgreSQL, serializable or snapshot on
+// CockroachDB
+func (o ReadTxOptions) txOptions() (TxOptions, error) {
+	opts := TxOptions{Isolation: IsolationRepeatableRead, ReadOnly: true}
+	switch {
+	case o.Staleness < 0:
+		return opts, fmt.Errorf("staleness cannot be negative")
+	case o.FollowerRead:
+		opts.asOf = "follower_read_timestamp()"
+	case o.Staleness > 0:
+		opts.asOf = "'-" + strconv.FormatFloat(o.Staleness.Seconds(), 'f', -1, 64) + "s'"
+	}
+	return opts, nil
+}
+
+// WithReadTx runs fn in a read-only transaction, so its queries see one
+// consistent snapshot without taking write locks. Writes through the
+// transaction fail.
+func (r *CockroachDBConnector[T, ID]) WithReadTx(ctx context.Context, opts ReadTxOptions, fn TxFunc[T, ID]) error {
+	txOpts, err := opts.txOptions()
+	if err != nil {
+		return err
+	}
+	return r.WithTxOptions(ctx, txOpts, fn)
+}
+
+// WithReadTx runs fn in a read-only transaction, so the queries of every
+// repository see one consistent snapshot without taking write locks
+func (tm *TransactionManager) WithReadTx(ctx context.Context, opts ReadTxOptions, fn MultiRepoTxFunc) error {
+	txOpts, err := opts.txOptions()
+	if err != nil {
+		return err
+	}
+	return tm.WithTxOptions(ctx, txOpts, fn)
+}
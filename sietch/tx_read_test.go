@@ -0,0 +1,31 @@
+package sietch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadTxOptions(t *testing.T) {
+	tests := []struct {
+		opts ReadTxOptions
+		asOf string
+	}{
+		{ReadTxOptions{}, ""},
+		{ReadTxOptions{Staleness: 10 * time.Second}, "'-10s'"},
+		{ReadTxOptions{Staleness: 4800 * time.Millisecond}, "'-4.8s'"},
+		{ReadTxOptions{Staleness: time.Second, FollowerRead: true}, "follower_read_timestamp()"},
+	}
+	for _, tt := range tests {
+		opts, err := tt.opts.txOptions()
+		if err != nil {
+			t.Fatalf("txOptions(%+v) failed: %v", tt.opts, err)
+		}
+		if !opts.ReadOnly || opts.Isolation != IsolationRepeatableRead || opts.asOf != tt.asOf {
+			t.Errorf("txOptions(%+v) = %+v, want a read-only snapshot as of %q", tt.opts, opts, tt.asOf)
+		}
+	}
+
+	if _, err := (ReadTxOptions{Staleness: -time.Second}).txOptions(); err == nil {
+		t.Error("Expected a negative staleness to be rejected")
+	}
+}
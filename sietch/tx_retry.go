@@ -0,0 +1,122 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DefaultTxMaxRetries is the number of reruns of a transaction failing with a
+// serialization failure when TxRetryOptions.MaxRetries is zero
+const DefaultTxMaxRetries = 5
+
+// TxRetryOptions configures how WithTx reruns transactions failing with a
+// serialization failure (SQLSTATE 40001), which CockroachDB returns under
+// contention and expects clients to retry, or with ErrTxConflict, returned
+// by Redis transactions whose keys were written concurrently
+type TxRetryOptions struct {
+	// MaxRetries bounds the reruns after the first attempt,
+	// DefaultTxMaxRetries if zero; negative disables reruns
+	MaxRetries int
+
+	// Backoff computes the delay before each rerun. By default it doubles
+	// from DefaultRetryBackoff up to DefaultMaxRetryBackoff, with jitter.
+	Backoff Backoff
+
+	// OnRetry is called with the rerun (from 1) and the serialization
+	// failure or conflict before every rerun
+	OnRetry func(retry int, err error)
+
+	// Metrics, if not nil, observes the conflicts and reruns of every
+	// transaction, e.g. to find the tables that are contention hot spots
+	Metrics TxRetryMetrics
+
+	// Table labels the observations of Metrics. Connectors default it to
+	// their table (CockroachDB) or key prefix (Redis); transaction managers
+	// and coordinators leave it empty.
+	Table string
+}
+
+// TxRetryMetrics observes the transactions run with TxRetryOptions, see
+// observability.TxRetryCollector
+type TxRetryMetrics interface {
+	// ObserveConflict is called for every attempt failing with a
+	// serialization failure or conflict, whether it is rerun or not
+	ObserveConflict(table string)
+
+	// ObserveTx is called once per transaction, when it succeeds or fails
+	// for good, with its reruns and the time from its first attempt to its
+	// end, backoff included. exhausted reports a transaction still
+	// conflicting after its last rerun.
+	ObserveTx(table string, retries int, elapsed time.Duration, exhausted bool)
+}
+
+// withTable returns opts with Table defaulting to table
+func (opts TxRetryOptions) withTable(table string) TxRetryOptions {
+	if opts.Table == "" {
+		opts.Table = table
+	}
+	return opts
+}
+
+// isSerializationFailure reports whether err is a serialization failure,
+// returned by a statement of the transaction or by its commit
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgCodeSerializationFailure
+}
+
+// isTxConflict reports whether err is a transaction failure a rerun can fix
+func isTxConflict(err error) bool {
+	return isSerializationFailure(err) || errors.Is(err, ErrTxConflict)
+}
+
+// retryTx runs attempt, rerunning it while it fails with a serialization
+// failure or conflict as configured by opts. attempt runs a whole transaction: the
+// failed one was rolled back, and its function runs again. Partially
+// committed transactions are never rerun.
+func retryTx(ctx context.Context, opts TxRetryOptions, attempt func() error) error {
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultTxMaxRetries
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = exponentialBackoff{}
+	}
+
+	start := time.Now()
+	observe := func(retries int, exhausted bool) {
+		if opts.Metrics != nil {
+			opts.Metrics.ObserveTx(opts.Table, retries, time.Since(start), exhausted)
+		}
+	}
+
+	for retry := 0; ; retry++ {
+		err := attempt()
+		conflict := err != nil && isTxConflict(err) && !errors.Is(err, ErrPartialCommit)
+		if conflict && opts.Metrics != nil {
+			opts.Metrics.ObserveConflict(opts.Table)
+		}
+		if !conflict || ctx.Err() != nil {
+			observe(retry, false)
+			return err
+		}
+		if retry >= opts.MaxRetries {
+			observe(retry, true)
+			return err
+		}
+		if opts.OnRetry != nil {
+			opts.OnRetry(retry+1, err)
+		}
+
+		timer := time.NewTimer(opts.Backoff.Next(retry))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			observe(retry, false)
+			return err
+		case <-timer.C:
+		}
+	}
+}
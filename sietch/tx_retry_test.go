@@ -0,0 +1,103 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRetryTx(t *testing.T) {
+	ctx := context.Background()
+	serialization := fmt.Errorf("failed to commit transaction: %w", &pgconn.PgError{Code: pgCodeSerializationFailure})
+	failing := func(errs ...error) (func() error, *int) {
+		attempts := 0
+		return func() error {
+			attempts++
+			if attempts <= len(errs) {
+				return errs[attempts-1]
+			}
+			return nil
+		}, &attempts
+	}
+
+	t.Run("Serialization failures are rerun", func(t *testing.T) {
+		var retries []int
+		attempt, attempts := failing(serialization, serialization)
+		err := retryTx(ctx, TxRetryOptions{Backoff: noBackoff{}, OnRetry: func(retry int, _ error) { retries = append(retries, retry) }}, attempt)
+		if err != nil || *attempts != 3 {
+			t.Errorf("Expected success after 3 attempts, got %d (%v)", *attempts, err)
+		}
+		if len(retries) != 2 || retries[1] != 2 {
+			t.Errorf("Unexpected retries %v", retries)
+		}
+	})
+
+	t.Run("Reruns are bounded", func(t *testing.T) {
+		attempt, attempts := failing(serialization, serialization, serialization)
+		if err := retryTx(ctx, TxRetryOptions{MaxRetries: 1, Backoff: noBackoff{}}, attempt); !isSerializationFailure(err) || *attempts != 2 {
+			t.Errorf("Expected the failure after 2 attempts, got %d (%v)", *attempts, err)
+		}
+
+		attempt, attempts = failing(serialization)
+		if err := retryTx(ctx, TxRetryOptions{MaxRetries: -1}, attempt); err == nil || *attempts != 1 {
+			t.Errorf("Expected no rerun when disabled, got %d (%v)", *attempts, err)
+		}
+	})
+
+	t.Run("Other errors are not rerun", func(t *testing.T) {
+		deadlock := &pgconn.PgError{Code: pgCodeDeadlockDetected}
+		for _, want := range []error{ErrItemNotFound, deadlock} {
+			attempt, attempts := failing(want)
+			if err := retryTx(ctx, TxRetryOptions{Backoff: noBackoff{}}, attempt); !errors.Is(err, want) || *attempts != 1 {
+				t.Errorf("Expected %v after one attempt, got %d (%v)", want, *attempts, err)
+			}
+		}
+	})
+
+	t.Run("Cancelled contexts stop reruns", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		attempt, attempts := failing(serialization, serialization)
+		if err := retryTx(ctx, TxRetryOptions{}, attempt); err == nil || *attempts != 1 {
+			t.Errorf("Expected one attempt, got %d (%v)", *attempts, err)
+		}
+	})
+
+	t.Run("Metrics observe conflicts and reruns", func(t *testing.T) {
+		metrics := &recordingTxMetrics{}
+		opts := TxRetryOptions{MaxRetries: 1, Backoff: noBackoff{}, Metrics: metrics}.withTable("accounts")
+
+		attempt, _ := failing(serialization)
+		if err := retryTx(ctx, opts, attempt); err != nil {
+			t.Fatalf("Expected success, got %v", err)
+		}
+		attempt, _ = failing(serialization, ErrTxConflict)
+		if err := retryTx(ctx, opts, attempt); err == nil {
+			t.Fatal("Expected the conflict")
+		}
+		attempt, _ = failing()
+		_ = retryTx(ctx, opts, attempt)
+
+		want := []string{"conflict accounts", "tx accounts 1 false", "conflict accounts", "conflict accounts", "tx accounts 1 true", "tx accounts 0 false"}
+		if fmt.Sprint(metrics.events) != fmt.Sprint(want) {
+			t.Errorf("Expected %v, got %v", want, metrics.events)
+		}
+	})
+}
+
+// recordingTxMetrics records the observations of retryTx
+type recordingTxMetrics struct {
+	events []string
+}
+
+func (m *recordingTxMetrics) ObserveConflict(table string) {
+	m.events = append(m.events, "conflict "+table)
+}
+
+func (m *recordingTxMetrics) ObserveTx(table string, retries int, _ time.Duration, exhausted bool) {
+	m.events = append(m.events, fmt.Sprintf("tx %s %d %v", table, retries, exhausted))
+}
@@ -0,0 +1,256 @@
+package sietch
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// FieldError is a constraint a field failed
+type FieldError struct {
+	Field   string // db column of the field, or its name if untagged
+	Rule    string // failed rule, e.g. "max"
+	Param   string // rule parameter, e.g. "255"; empty for rules without one
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists the fields of an entity that failed their
+// `validate` tag constraints. It matches ErrValidation with errors.Is.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Error()
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// validationRule checks a field value, dereferenced unless nil, and returns
+// a message if it fails
+type validationRule struct {
+	name  string
+	param string
+	check func(v reflect.Value) string
+}
+
+// validatedField is a field with a validate tag
+type validatedField struct {
+	index    []int // struct field index path
+	name     string
+	required bool
+	rules    []validationRule
+}
+
+// ValidationHook is a hook validating entities in BeforeCreate and
+// BeforeUpdate against the constraints of their `validate` tags, so invalid
+// entities never reach the backend:
+//
+//	type Account struct {
+//	    ID    string `db:"id"`
+//	    Name  string `db:"name" validate:"required,max=255"`
+//	    Email string `db:"email" validate:"email"`
+//	    Tier  string `db:"tier" validate:"oneof=free pro"`
+//	    Age   int    `db:"age" validate:"min=18"`
+//	}
+//
+// The rules are:
+//
+//   - required: not the zero value, and not nil for pointers
+//   - min=N, max=N: bounds for numbers, and for the length in characters of
+//     strings or the number of elements of slices and maps
+//   - len=N: exact length of a string, slice or map
+//   - oneof=a b c: one of the space separated values
+//   - email, uuid: well formed email address or UUID string
+//
+// Rules other than required skip nil pointers and empty strings, so optional
+// fields only need to be valid when set. Failing entities are rejected with
+// a *ValidationError listing every failed field.
+type ValidationHook[T any, ID comparable] struct {
+	BaseHook[T, ID]
+	fields []validatedField
+}
+
+// NewValidationHook creates a validation hook for T, failing if a validate
+// tag has an unknown rule or a malformed parameter
+func NewValidationHook[T any, ID comparable]() (*ValidationHook[T, ID], error) {
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("entity must be a struct")
+	}
+
+	h := &ValidationHook[T, ID]{}
+	for _, f := range namedFields(typ) {
+		field := f.field
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+		vf := validatedField{index: f.index, name: f.column}
+		for _, spec := range strings.Split(tag, ",") {
+			ruleName, param, _ := strings.Cut(strings.TrimSpace(spec), "=")
+			if ruleName == "required" {
+				vf.required = true
+				continue
+			}
+			rule, err := newValidationRule(field.Type, ruleName, param)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			vf.rules = append(vf.rules, rule)
+		}
+		h.fields = append(h.fields, vf)
+	}
+	return h, nil
+}
+
+// newValidationRule compiles a rule for fields of type typ
+func newValidationRule(typ reflect.Type, name, param string) (validationRule, error) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	rule := validationRule{name: name, param: param}
+
+	switch name {
+	case "min", "max", "len":
+		bound, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return rule, fmt.Errorf("rule %s needs a number, got %q", name, param)
+		}
+		measure, unit := measureOf(typ)
+		if measure == nil || (name == "len" && unit == "") {
+			return rule, fmt.Errorf("rule %s does not apply to %s", name, typ)
+		}
+		rule.check = func(v reflect.Value) string {
+			n := measure(v)
+			switch {
+			case name == "min" && n < bound:
+				return fmt.Sprintf("must be at least %s%s", param, unit)
+			case name == "max" && n > bound:
+				return fmt.Sprintf("must be at most %s%s", param, unit)
+			case name == "len" && n != bound:
+				return fmt.Sprintf("must be exactly %s%s", param, unit)
+			}
+			return ""
+		}
+	case "oneof":
+		values := strings.Fields(param)
+		if len(values) == 0 {
+			return rule, fmt.Errorf("rule oneof needs values")
+		}
+		rule.check = func(v reflect.Value) string {
+			if slices.Contains(values, fmt.Sprint(v.Interface())) {
+				return ""
+			}
+			return "must be one of " + strings.Join(values, ", ")
+		}
+	case "email":
+		if typ.Kind() != reflect.String {
+			return rule, fmt.Errorf("rule email only applies to strings")
+		}
+		rule.check = func(v reflect.Value) string {
+			addr, err := mail.ParseAddress(v.String())
+			if err != nil || addr.Address != v.String() {
+				return "must be an email address"
+			}
+			return ""
+		}
+	case "uuid":
+		if typ.Kind() != reflect.String {
+			return rule, fmt.Errorf("rule uuid only applies to strings")
+		}
+		rule.check = func(v reflect.Value) string {
+			if uuid.Validate(v.String()) != nil {
+				return "must be a UUID"
+			}
+			return ""
+		}
+	default:
+		return rule, fmt.Errorf("unknown validation rule %q", name)
+	}
+	return rule, nil
+}
+
+// measureOf returns how min and max measure values of typ, and the unit of
+// lengths; nil if they do not apply
+func measureOf(typ reflect.Type) (func(reflect.Value) float64, string) {
+	switch typ.Kind() {
+	case reflect.String:
+		return func(v reflect.Value) float64 { return float64(utf8.RuneCountInString(v.String())) }, " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return func(v reflect.Value) float64 { return float64(v.Len()) }, " elements"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value) float64 { return float64(v.Int()) }, ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value) float64 { return float64(v.Uint()) }, ""
+	case reflect.Float32, reflect.Float64:
+		return func(v reflect.Value) float64 { return v.Float() }, ""
+	}
+	return nil, ""
+}
+
+// Validate checks item against the validate tags of T, returning a
+// *ValidationError listing every failed field
+func (h *ValidationHook[T, ID]) Validate(item *T) error {
+	if item == nil {
+		return fmt.Errorf("item cannot be nil")
+	}
+	v := reflect.ValueOf(item).Elem()
+
+	var failed []FieldError
+	for _, field := range h.fields {
+		f, ok := fieldByIndex(v, field.index)
+		if !ok {
+			f = reflect.Zero(v.Type().FieldByIndex(field.index).Type) // in a nil embedded pointer
+		}
+		if f.IsZero() {
+			if field.required {
+				failed = append(failed, FieldError{Field: field.name, Rule: "required", Message: "is required"})
+			}
+			// optional fields are only checked when set; zero numbers still
+			// have to satisfy their bounds
+			if f.Kind() == reflect.Ptr || f.Kind() == reflect.String || field.required {
+				continue
+			}
+		}
+		if f.Kind() == reflect.Ptr {
+			f = f.Elem()
+		}
+		for _, rule := range field.rules {
+			if msg := rule.check(f); msg != "" {
+				failed = append(failed, FieldError{Field: field.name, Rule: rule.name, Param: rule.param, Message: msg})
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return &ValidationError{Fields: failed}
+	}
+	return nil
+}
+
+// BeforeCreate validates the item
+func (h *ValidationHook[T, ID]) BeforeCreate(_ context.Context, item *T) error {
+	return h.Validate(item)
+}
+
+// BeforeUpdate validates the item
+func (h *ValidationHook[T, ID]) BeforeUpdate(_ context.Context, item *T) error {
+	return h.Validate(item)
+}
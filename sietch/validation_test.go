@@ -0,0 +1,88 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type signup struct {
+	ID    string   `db:"id" validate:"required,uuid"`
+	Name  string   `db:"name" validate:"required,max=5"`
+	Email string   `db:"email" validate:"email"`
+	Tier  string   `db:"tier" validate:"oneof=free pro"`
+	Age   int      `db:"age" validate:"min=18,max=130"`
+	Tags  []string `db:"tags" validate:"max=2"`
+	Score *float64 `db:"score" validate:"min=0"`
+}
+
+func TestValidationHook(t *testing.T) {
+	hook, err := NewValidationHook[signup, string]()
+	if err != nil {
+		t.Fatalf("NewValidationHook failed: %v", err)
+	}
+
+	valid := signup{ID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8", Name: "Ann", Tier: "pro", Age: 30}
+	if err := hook.Validate(&valid); err != nil {
+		t.Errorf("Expected a valid item, got %v", err)
+	}
+
+	score := -1.0
+	invalid := signup{ID: "nope", Name: "Annabelle", Email: "ann at example", Tier: "gold", Tags: []string{"a", "b", "c"}, Score: &score}
+	err = hook.Validate(&invalid)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !errors.Is(err, ErrValidation) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	var failed []string
+	for _, f := range validationErr.Fields {
+		failed = append(failed, f.Field+" "+f.Rule)
+	}
+	expected := []string{"id uuid", "name max", "email email", "tier oneof", "age min", "tags max", "score min"}
+	if !reflect.DeepEqual(failed, expected) {
+		t.Errorf("Unexpected failed fields %v", failed)
+	}
+	if validationErr.Fields[1].Message != "must be at most 5 characters" || validationErr.Fields[1].Param != "5" {
+		t.Errorf("Unexpected field error %+v", validationErr.Fields[1])
+	}
+
+	t.Run("Runs before writes", func(t *testing.T) {
+		repo := NewHookableRepository(NewInMemoryConnector[signup](func(s *signup) string { return s.ID }), func(s *signup) string { return s.ID }, nil)
+		repo.AddHook(hook)
+		ctx := context.Background()
+
+		if err := repo.Create(ctx, &signup{Name: "Ann", Age: 30}); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected ErrValidation, got %v", err)
+		}
+		if err := repo.Create(ctx, &valid); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		update := valid
+		update.Age = 10
+		if err := repo.Update(ctx, &update); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected ErrValidation, got %v", err)
+		}
+	})
+
+	t.Run("Malformed tags", func(t *testing.T) {
+		type badRule struct {
+			Name string `validate:"shiny"`
+		}
+		type badParam struct {
+			Age int `validate:"max=old"`
+		}
+		type badType struct {
+			Age int `validate:"email"`
+		}
+		if _, err := NewValidationHook[badRule, string](); err == nil {
+			t.Error("Expected an unknown rule to fail")
+		}
+		if _, err := NewValidationHook[badParam, string](); err == nil {
+			t.Error("Expected a malformed parameter to fail")
+		}
+		if _, err := NewValidationHook[badType, string](); err == nil {
+			t.Error("Expected a rule of another type to fail")
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package sietch
+
+import (
+	"reflect"
+	"slices"
+)
+
+// Optimistic locking: a field tagged `db:"<column>,version"` holds the
+// version of the row. Update and Upsert only write a row whose stored
+// version equals the item's, increment it, and fail with ErrVersionConflict
+// otherwise; on success the item's field holds the new version.
+//
+// Example:
+//
+//	type Account struct {
+//	    ID      int64 `db:"id"`
+//	    Balance int   `db:"balance"`
+//	    Version int64 `db:"version,version"`
+//	}
+
+// isVersionKind reports whether a field of kind k can be a version column
+func isVersionKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// versionFieldIndex returns the struct field index path of the version
+// column of typ, or nil if it has none
+func versionFieldIndex(typ reflect.Type) []int {
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	for _, f := range dbFields(typ) {
+		if slices.Contains(f.options, "version") && isVersionKind(f.field.Type.Kind()) {
+			return f.index
+		}
+	}
+	return nil
+}
+
+// versionOf returns the value of f, an integer version field
+func versionOf(f reflect.Value) int64 {
+	if f.CanInt() {
+		return f.Int()
+	}
+	return int64(f.Uint())
+}
+
+// setVersion sets f, an integer version field, to version
+func setVersion(f reflect.Value, version int64) {
+	if f.CanInt() {
+		f.SetInt(version)
+	} else {
+		f.SetUint(uint64(version))
+	}
+}
+
+// versionOf returns the version of item, an entity of a versioned connector
+func (r *InMemoryConnector[T, ID]) versionOf(item *T) int64 {
+	f, ok := fieldByIndex(reflect.ValueOf(item).Elem(), r.version)
+	if !ok {
+		return 0 // in a nil embedded pointer
+	}
+	return versionOf(f)
+}
+
+// nextVersion returns a copy of item with the next version to store, and
+// sets the version of item to it
+func (r *InMemoryConnector[T, ID]) nextVersion(item *T) *T {
+	next := *item
+	version := r.versionOf(item) + 1
+	setVersion(detachedField(reflect.ValueOf(&next).Elem(), r.version), version)
+	setVersion(settableField(reflect.ValueOf(item).Elem(), r.version), version)
+	return &next
+}
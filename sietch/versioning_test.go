@@ -0,0 +1,289 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type versionedAccount struct {
+	ID      int64  `db:"id"`
+	Version uint32 `db:"version,version"`
+	Balance int    `db:"balance"`
+}
+
+func newVersionedConnector(t *testing.T) *CockroachDBConnector[versionedAccount, int64] {
+	t.Helper()
+	conn, err := NewCockroachDBConnector[versionedAccount, int64](&pgxpool.Pool{}, "accounts", func(a *versionedAccount) int64 { return a.ID })
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+	return conn
+}
+
+// versionStore answers the versioned update, upsert and exists statements
+// from stored versions
+type versionStore struct {
+	Queryable
+	versions map[int64]int64
+}
+
+func (s *versionStore) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	switch {
+	case strings.HasPrefix(sql, "UPDATE"):
+		id, version := args[1].(int64), int64(args[2].(uint32))
+		if stored, ok := s.versions[id]; !ok || stored != version {
+			return versionRow{err: pgx.ErrNoRows}
+		}
+		s.versions[id]++
+		return versionRow{value: s.versions[id]}
+	case strings.HasPrefix(sql, "INSERT"):
+		id, version := args[0].(int64), int64(args[1].(uint32))
+		stored, ok := s.versions[id]
+		if !ok {
+			s.versions[id] = version
+			return versionRow{value: version}
+		}
+		if stored != version {
+			return versionRow{err: pgx.ErrNoRows}
+		}
+		s.versions[id]++
+		return versionRow{value: s.versions[id]}
+	default:
+		_, ok := s.versions[args[0].(int64)]
+		return versionRow{value: ok}
+	}
+}
+
+type versionRow struct {
+	value any
+	err   error
+}
+
+func (r versionRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	reflect.ValueOf(dest[0]).Elem().Set(reflect.ValueOf(r.value))
+	return nil
+}
+
+func TestCockroachDBConnector_VersionedStatements(t *testing.T) {
+	conn := newVersionedConnector(t)
+
+	expected := `UPDATE "accounts" SET "balance" = $1, "version" = "version" + 1 WHERE "id" = $2 AND "version" = $3 RETURNING "version"`
+	if sql := conn.statements[stmtUpdate].SQL; sql != expected {
+		t.Errorf("Unexpected update statement %s", sql)
+	}
+	expected = `INSERT INTO "accounts" ("id", "version", "balance") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET "balance" = EXCLUDED."balance", ` +
+		`"version" = "accounts"."version" + 1 WHERE "accounts"."version" = EXCLUDED."version" RETURNING "version"`
+	if sql := conn.statements[stmtUpsert].SQL; sql != expected {
+		t.Errorf("Unexpected upsert statement %s", sql)
+	}
+
+	args := conn.updateArgs([]any{int64(1), uint32(4), 10}, 1)
+	if !reflect.DeepEqual(args, []any{10, int64(1), uint32(4)}) {
+		t.Errorf("Unexpected update arguments %v", args)
+	}
+
+	expected = `UPDATE "accounts" AS t SET "version" = t."version" + 1, "balance" = v."balance" ` +
+		`FROM (SELECT "id", "version", "balance" FROM "accounts" WHERE false UNION ALL VALUES ($1, $2, $3)) AS v ("id", "version", "balance") ` +
+		`WHERE t."id" = v."id" AND t."version" = v."version" RETURNING t."id"`
+	if sql, _, _ := conn.updateValuesQuery([]versionedAccount{{ID: 1}}); sql != expected {
+		t.Errorf("Unexpected batch update statement %s", sql)
+	}
+
+	filter := NewFilter().Where("balance", OpEqual, 0).Build()
+	expected = `UPDATE "accounts" SET "balance" = $1, "version" = "version" + 1 WHERE "balance" = $2`
+	if sql, _, err := conn.updateWhereQuery(filter, map[string]any{"balance": 10}); err != nil || sql != expected {
+		t.Errorf("Unexpected bulk update statement %s (%v)", sql, err)
+	}
+	if _, _, err := conn.updateWhereQuery(filter, map[string]any{"version": 1}); err == nil {
+		t.Error("Expected bulk updates of the version column to be rejected")
+	}
+}
+
+func TestCockroachDBConnector_OptimisticLocking(t *testing.T) {
+	ctx := context.Background()
+	conn := newVersionedConnector(t)
+	store := &versionStore{versions: map[int64]int64{1: 3}}
+
+	item := &versionedAccount{ID: 1, Version: 3, Balance: 10}
+	if err := conn.update(ctx, store, item); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if item.Version != 4 {
+		t.Errorf("Expected the item to get version 4, got %d", item.Version)
+	}
+
+	stale := &versionedAccount{ID: 1, Version: 3}
+	if err := conn.update(ctx, store, stale); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict, got %v", err)
+	}
+	if err := conn.upsert(ctx, store, stale); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict from Upsert, got %v", err)
+	}
+	if err := conn.update(ctx, store, &versionedAccount{ID: 2}); !errors.Is(err, ErrNoUpdateItem) {
+		t.Errorf("Expected ErrNoUpdateItem, got %v", err)
+	}
+
+	if err := conn.upsert(ctx, store, item); err != nil || item.Version != 5 {
+		t.Errorf("Expected the upsert to update to version 5, got %d (%v)", item.Version, err)
+	}
+	created := &versionedAccount{ID: 2}
+	if err := conn.upsert(ctx, store, created); err != nil || created.Version != 0 {
+		t.Errorf("Expected the upsert to insert version 0, got %d (%v)", created.Version, err)
+	}
+}
+
+func TestCockroachDBConnector_VersionedBatches(t *testing.T) {
+	ctx := context.Background()
+	items := []versionedAccount{{ID: 1, Version: 1}, {ID: 2, Version: 1}, {ID: 3, Version: 1}}
+
+	t.Run("Update splits conflicts from missing items", func(t *testing.T) {
+		conn := newVersionedConnector(t)
+		sender := &fakeBatchSender{rows: func(sql string, args []any) ([][]any, error) {
+			if strings.HasPrefix(sql, "SELECT EXISTS") {
+				return [][]any{{args[0] == int64(2)}}, nil
+			}
+			return [][]any{{int64(1)}}, nil
+		}}
+
+		err := conn.batchUpdate(ctx, sender, items)
+		var missing *MissingItemsError[int64]
+		var conflicts *VersionConflictError[int64]
+		if !errors.As(err, &missing) || !reflect.DeepEqual(missing.IDs, []int64{3}) {
+			t.Errorf("Expected item 3 to be missing, got %v", err)
+		}
+		if !errors.As(err, &conflicts) || !reflect.DeepEqual(conflicts.IDs, []int64{2}) {
+			t.Errorf("Expected item 2 to conflict, got %v", err)
+		}
+		if !errors.Is(err, ErrVersionConflict) || !errors.Is(err, ErrNoUpdateItem) {
+			t.Errorf("Expected both sentinels to match, got %v", err)
+		}
+	})
+
+	t.Run("Upsert reports conflicts", func(t *testing.T) {
+		conn := newVersionedConnector(t)
+		sender := &fakeBatchSender{tag: func(_ string, args []any) (pgconn.CommandTag, error) {
+			if args[0] == int64(3) {
+				return pgconn.NewCommandTag("INSERT 0 0"), nil
+			}
+			return pgconn.NewCommandTag("INSERT 0 1"), nil
+		}}
+
+		var conflicts *VersionConflictError[int64]
+		err := conn.batchUpsert(ctx, sender, items)
+		if !errors.As(err, &conflicts) || !reflect.DeepEqual(conflicts.IDs, []int64{3}) {
+			t.Errorf("Expected item 3 to conflict, got %v", err)
+		}
+	})
+}
+
+func TestInMemoryConnector_OptimisticLocking(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryConnector[versionedAccount, int64](func(a *versionedAccount) int64 { return a.ID })
+	if err := repo.Create(ctx, &versionedAccount{ID: 1}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	first := versionedAccount{ID: 1, Balance: 10}
+	second := versionedAccount{ID: 1, Balance: 20}
+	if err := repo.Update(ctx, &first); err != nil || first.Version != 1 {
+		t.Fatalf("Expected the update to bump the version to 1, got %d (%v)", first.Version, err)
+	}
+	if err := repo.Update(ctx, &second); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict, got %v", err)
+	}
+	if err := repo.Upsert(ctx, &second); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict from Upsert, got %v", err)
+	}
+	if err := repo.BatchUpdate(ctx, []versionedAccount{second}); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict from BatchUpdate, got %v", err)
+	}
+	if err := repo.BatchUpsert(ctx, []versionedAccount{{ID: 1, Version: 1, Balance: 30}, {ID: 2}}); err != nil {
+		t.Fatalf("BatchUpsert failed: %v", err)
+	}
+
+	stored, _ := repo.Get(ctx, 1)
+	if stored.Version != 2 || stored.Balance != 30 {
+		t.Errorf("Expected version 2 with balance 30, got %+v", stored)
+	}
+
+	err := repo.WithTx(ctx, func(tx Repository[versionedAccount, int64]) error {
+		return tx.Update(ctx, &versionedAccount{ID: 2, Version: 5})
+	})
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict within a transaction, got %v", err)
+	}
+
+	t.Run("Bulk updates bump the version", func(t *testing.T) {
+		repo := NewInMemoryConnector[versionedAccount, int64](func(a *versionedAccount) int64 { return a.ID })
+		_ = repo.Create(ctx, &versionedAccount{ID: 1})
+		stale, _ := repo.Get(ctx, 1)
+
+		filter := NewFilter().Where("id", OpEqual, int64(1)).Build()
+		if n, err := repo.UpdateWhere(ctx, filter, map[string]any{"balance": 10}); err != nil || n != 1 {
+			t.Fatalf("UpdateWhere failed: %d (%v)", n, err)
+		}
+		if stored, _ := repo.Get(ctx, 1); stored.Version != 1 || stored.Balance != 10 {
+			t.Errorf("Expected version 1 with balance 10, got %+v", stored)
+		}
+		if err := repo.Update(ctx, stale); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("Expected ErrVersionConflict after a bulk update, got %v", err)
+		}
+		if _, err := repo.UpdateWhere(ctx, filter, map[string]any{"version": 0}); err == nil {
+			t.Error("Expected bulk updates of the version column to be rejected")
+		}
+	})
+
+	t.Run("Batches are all or nothing", func(t *testing.T) {
+		// 1 is at version 2 and 2 at version 0
+		batch := []versionedAccount{{ID: 2, Balance: 40}, {ID: 1, Version: 1, Balance: 40}, {ID: 3}}
+		err := repo.BatchUpdate(ctx, batch)
+		var missing *MissingItemsError[int64]
+		var conflict *VersionConflictError[int64]
+		if !errors.As(err, &missing) || !slices.Equal(missing.IDs, []int64{3}) {
+			t.Errorf("Expected 3 to be missing, got %v", err)
+		}
+		if !errors.As(err, &conflict) || !slices.Equal(conflict.IDs, []int64{1}) {
+			t.Errorf("Expected a version conflict on 1, got %v", err)
+		}
+
+		err = repo.BatchUpsert(ctx, []versionedAccount{{ID: 2, Balance: 40}, {ID: 3}, {ID: 1, Version: 1}, {ID: 2, Balance: 50}})
+		if !errors.As(err, &conflict) || !slices.Equal(conflict.IDs, []int64{1, 2}) {
+			t.Errorf("Expected version conflicts on 1 and 2, got %v", err)
+		}
+
+		for id, balance := range map[int64]int{1: 30, 2: 0} {
+			if stored, _ := repo.Get(ctx, id); stored.Balance != balance {
+				t.Errorf("Expected the failed batches to leave %d unchanged, got %+v", id, stored)
+			}
+		}
+		if exists, _ := repo.Exists(ctx, 3); exists {
+			t.Error("Expected the failed upsert not to create 3")
+		}
+	})
+}
+
+func TestEntityCodec_VersionTag(t *testing.T) {
+	type badVersion struct {
+		ID      int64  `db:"id"`
+		Version string `db:"version,version"`
+	}
+	if _, err := newEntityCodec[badVersion](); err == nil {
+		t.Error("Expected a non-integer version column to be rejected")
+	}
+
+	codec, err := newEntityCodec[versionedAccount]()
+	if err != nil || codec.version != 1 || codec.columns[1] != "version" {
+		t.Errorf("Expected column 1 to be the version, got %+v (%v)", codec, err)
+	}
+}
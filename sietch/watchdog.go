@@ -0,0 +1,260 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// WatchdogConfig configures a QueryWatchdog
+type WatchdogConfig struct {
+	// Ceiling is the longest a query may run before it is canceled. Required.
+	Ceiling time.Duration
+
+	// Logger receives a "watchdog_cancel" LogQuery record for every canceled
+	// query, with the query shape and no arguments. Optional.
+	Logger QueryLogger
+
+	// OnCancel is called for every canceled query, e.g. to increment a metric.
+	// It runs on its own goroutine while the query is being aborted. Optional.
+	OnCancel func(RunawayQuery)
+}
+
+// ActiveQuery describes a query tracked by a QueryWatchdog
+type ActiveQuery struct {
+	Table    string        // table of the connector that issued the query
+	Shape    string        // SQL with whitespace collapsed; arguments are never included
+	Caller   string        // set with WithQueryCaller, empty if unknown
+	Started  time.Time     // when the query was issued
+	Duration time.Duration // how long it has been running
+}
+
+// RunawayQuery describes a query canceled by a QueryWatchdog
+type RunawayQuery = ActiveQuery
+
+// QueryWatchdog tracks the queries of the connectors it is attached to and
+// cancels those that run longer than its ceiling, protecting shared clusters
+// from runaway queries issued through the repository layer. Canceled queries
+// fail with an error matching ErrQueryCeilingExceeded. A watchdog can be
+// shared by several connectors.
+//
+// Queries run through WithTx repositories and the batch operations are not
+// tracked; queries run in a TransactionManager transaction are.
+//
+// Example:
+//
+//	watchdog, err := sietch.NewQueryWatchdog(sietch.WatchdogConfig{
+//	    Ceiling:  30 * time.Second,
+//	    OnCancel: func(q sietch.RunawayQuery) { runawayQueries.WithLabelValues(q.Table, q.Caller).Inc() },
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	repo.SetWatchdog(watchdog)
+//
+//	ctx = sietch.WithQueryCaller(ctx, "reports.MonthlyRevenue")
+//	rows, err := repo.Query(ctx, filter)
+type QueryWatchdog struct {
+	config WatchdogConfig
+
+	mu     sync.Mutex
+	nextID uint64
+	active map[uint64]*trackedQuery
+}
+
+// NewQueryWatchdog creates a watchdog; attach it with SetWatchdog
+func NewQueryWatchdog(cfg WatchdogConfig) (*QueryWatchdog, error) {
+	if cfg.Ceiling <= 0 {
+		return nil, fmt.Errorf("watchdog ceiling must be positive")
+	}
+	return &QueryWatchdog{
+		config: cfg,
+		active: make(map[uint64]*trackedQuery),
+	}, nil
+}
+
+// SetWatchdog attaches a watchdog to the connector; nil detaches it.
+// Not safe to call concurrently with queries.
+func (r *CockroachDBConnector[T, ID]) SetWatchdog(w *QueryWatchdog) {
+	r.watchdog = w
+}
+
+// Active returns the queries currently running, longest running first
+func (w *QueryWatchdog) Active() []ActiveQuery {
+	now := time.Now()
+
+	w.mu.Lock()
+	active := make([]ActiveQuery, 0, len(w.active))
+	for _, q := range w.active {
+		info := q.info
+		info.Duration = now.Sub(info.Started)
+		active = append(active, info)
+	}
+	w.mu.Unlock()
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].Started.Before(active[j].Started)
+	})
+	return active
+}
+
+// track registers a query and returns the context it must run with
+func (w *QueryWatchdog) track(ctx context.Context, table, sql string) (context.Context, *trackedQuery) {
+	q := &trackedQuery{
+		watchdog: w,
+		info: ActiveQuery{
+			Table:   table,
+			Shape:   strings.Join(strings.Fields(sql), " "),
+			Caller:  QueryCaller(ctx),
+			Started: time.Now(),
+		},
+	}
+
+	ctx, q.cancel = context.WithTimeoutCause(ctx, w.config.Ceiling, ErrQueryCeilingExceeded)
+	q.ctx = ctx
+	q.stopReport = context.AfterFunc(ctx, func() {
+		if errors.Is(context.Cause(ctx), ErrQueryCeilingExceeded) {
+			w.report(q)
+		}
+	})
+
+	w.mu.Lock()
+	w.nextID++
+	q.id = w.nextID
+	w.active[q.id] = q
+	w.mu.Unlock()
+
+	return ctx, q
+}
+
+// report logs a canceled query and notifies OnCancel
+func (w *QueryWatchdog) report(q *trackedQuery) {
+	runaway := q.info
+	runaway.Duration = time.Since(runaway.Started)
+
+	if w.config.Logger != nil {
+		w.config.Logger.LogQuery(context.WithoutCancel(q.ctx), "watchdog_cancel", runaway.Shape, nil, runaway.Duration, ErrQueryCeilingExceeded)
+	}
+	if w.config.OnCancel != nil {
+		w.config.OnCancel(runaway)
+	}
+}
+
+// trackedQuery is a query registered with a watchdog
+type trackedQuery struct {
+	watchdog   *QueryWatchdog
+	id         uint64
+	info       ActiveQuery
+	ctx        context.Context
+	cancel     context.CancelFunc
+	stopReport func() bool
+	once       sync.Once
+}
+
+// finish unregisters the query and releases its context
+func (q *trackedQuery) finish() {
+	q.once.Do(func() {
+		q.stopReport()
+		q.cancel()
+
+		q.watchdog.mu.Lock()
+		delete(q.watchdog.active, q.id)
+		q.watchdog.mu.Unlock()
+	})
+}
+
+// wrap marks err as a watchdog cancellation when the ceiling was hit
+func (q *trackedQuery) wrap(err error) error {
+	if err == nil || errors.Is(err, ErrQueryCeilingExceeded) || !errors.Is(context.Cause(q.ctx), ErrQueryCeilingExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrQueryCeilingExceeded, err)
+}
+
+// queryCallerKey is the context key for WithQueryCaller
+type queryCallerKey struct{}
+
+// WithQueryCaller names the code issuing queries with ctx, so watchdog
+// reports can point at it (e.g. "billing.InvoiceExport")
+func WithQueryCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, queryCallerKey{}, caller)
+}
+
+// QueryCaller returns the caller set with WithQueryCaller, or ""
+func QueryCaller(ctx context.Context) string {
+	caller, _ := ctx.Value(queryCallerKey{}).(string)
+	return caller
+}
+
+// watchedQueryable runs every statement under a watchdog
+type watchedQueryable struct {
+	Queryable
+	watchdog *QueryWatchdog
+	table    string
+}
+
+func (w *watchedQueryable) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	ctx, q := w.watchdog.track(ctx, w.table, sql)
+	defer q.finish()
+
+	ct, err := w.Queryable.Exec(ctx, sql, args...)
+	return ct, q.wrap(err)
+}
+
+func (w *watchedQueryable) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	ctx, q := w.watchdog.track(ctx, w.table, sql)
+
+	rows, err := w.Queryable.Query(ctx, sql, args...)
+	if err != nil {
+		q.finish()
+		return nil, q.wrap(err)
+	}
+	return &watchedRows{Rows: rows, query: q}, nil
+}
+
+func (w *watchedQueryable) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	ctx, q := w.watchdog.track(ctx, w.table, sql)
+	return &watchedRow{row: w.Queryable.QueryRow(ctx, sql, args...), query: q}
+}
+
+// watchedRows keeps its query tracked until the rows are closed
+type watchedRows struct {
+	pgx.Rows
+	query *trackedQuery
+}
+
+func (r *watchedRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.query.finish()
+	return false
+}
+
+func (r *watchedRows) Close() {
+	r.Rows.Close()
+	r.query.finish()
+}
+
+func (r *watchedRows) Err() error {
+	return r.query.wrap(r.Rows.Err())
+}
+
+// watchedRow keeps its query tracked until it is scanned
+type watchedRow struct {
+	row   pgx.Row
+	query *trackedQuery
+}
+
+func (r *watchedRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	r.query.finish()
+	return r.query.wrap(err)
+}
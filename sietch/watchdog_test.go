@@ -0,0 +1,159 @@
+package sietch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// blockingQueryable runs statements until release is closed or ctx is done
+type blockingQueryable struct {
+	release chan struct{}
+}
+
+func (b *blockingQueryable) wait(ctx context.Context) error {
+	select {
+	case <-b.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *blockingQueryable) Exec(ctx context.Context, _ string, _ ...any) (pgconn.CommandTag, error) {
+	return pgconn.NewCommandTag("UPDATE 1"), b.wait(ctx)
+}
+
+func (b *blockingQueryable) Query(ctx context.Context, _ string, _ ...any) (pgx.Rows, error) {
+	return nil, b.wait(ctx)
+}
+
+func (b *blockingQueryable) QueryRow(ctx context.Context, _ string, _ ...any) pgx.Row {
+	return blockingRow{err: b.wait(ctx)}
+}
+
+type blockingRow struct{ err error }
+
+func (r blockingRow) Scan(...any) error { return r.err }
+
+func newTestWatchdog(t *testing.T, ceiling time.Duration, onCancel func(RunawayQuery)) *QueryWatchdog {
+	t.Helper()
+	w, err := NewQueryWatchdog(WatchdogConfig{Ceiling: ceiling, OnCancel: onCancel})
+	if err != nil {
+		t.Fatalf("NewQueryWatchdog failed: %v", err)
+	}
+	return w
+}
+
+func TestQueryWatchdog(t *testing.T) {
+	t.Run("Cancels and reports runaway queries", func(t *testing.T) {
+		var mu sync.Mutex
+		var reported []RunawayQuery
+		w := newTestWatchdog(t, 20*time.Millisecond, func(q RunawayQuery) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, q)
+		})
+		q := &watchedQueryable{Queryable: &blockingQueryable{release: make(chan struct{})}, watchdog: w, table: "orders"}
+
+		ctx := WithQueryCaller(context.Background(), "reports.Revenue")
+		_, err := q.Exec(ctx, "SELECT sum(total)\n\tFROM orders", 42)
+		if !errors.Is(err, ErrQueryCeilingExceeded) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected ErrQueryCeilingExceeded, got %v", err)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			mu.Lock()
+			n := len(reported)
+			mu.Unlock()
+			if n > 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(reported) != 1 {
+			t.Fatalf("Expected 1 report, got %d", len(reported))
+		}
+		r := reported[0]
+		if r.Table != "orders" || r.Caller != "reports.Revenue" || r.Shape != "SELECT sum(total) FROM orders" {
+			t.Errorf("Unexpected report %+v", r)
+		}
+		if r.Duration < 20*time.Millisecond {
+			t.Errorf("Expected the duration to reach the ceiling, got %v", r.Duration)
+		}
+		if active := w.Active(); len(active) != 0 {
+			t.Errorf("Expected no active queries, got %v", active)
+		}
+	})
+
+	t.Run("Tracks active queries until they finish", func(t *testing.T) {
+		w := newTestWatchdog(t, time.Minute, func(q RunawayQuery) {
+			t.Errorf("Unexpected report %+v", q)
+		})
+		release := make(chan struct{})
+		q := &watchedQueryable{Queryable: &blockingQueryable{release: release}, watchdog: w, table: "orders"}
+
+		done := make(chan error)
+		go func() {
+			var n int
+			done <- q.QueryRow(context.Background(), "SELECT count(*) FROM orders").Scan(&n)
+		}()
+
+		deadline := time.Now().Add(time.Second)
+		for len(w.Active()) == 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		active := w.Active()
+		if len(active) != 1 || active[0].Shape != "SELECT count(*) FROM orders" {
+			t.Fatalf("Expected the running query to be active, got %v", active)
+		}
+
+		close(release)
+		if err := <-done; err != nil {
+			t.Errorf("Expected the query to succeed, got %v", err)
+		}
+		if active := w.Active(); len(active) != 0 {
+			t.Errorf("Expected no active queries, got %v", active)
+		}
+	})
+
+	t.Run("Caller cancellation is not reported", func(t *testing.T) {
+		w := newTestWatchdog(t, time.Minute, func(q RunawayQuery) {
+			t.Errorf("Unexpected report %+v", q)
+		})
+		q := &watchedQueryable{Queryable: &blockingQueryable{release: make(chan struct{})}, watchdog: w, table: "orders"}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := q.Query(ctx, "SELECT * FROM orders")
+		if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrQueryCeilingExceeded) {
+			t.Errorf("Expected the caller's deadline, got %v", err)
+		}
+	})
+
+	t.Run("Attached connectors run queries under the watchdog", func(t *testing.T) {
+		conn := newBatchConnector(t)
+		if _, ok := conn.getQueryable(context.Background()).(*watchedQueryable); ok {
+			t.Error("Expected no watchdog by default")
+		}
+		conn.SetWatchdog(newTestWatchdog(t, time.Minute, nil))
+		if _, ok := conn.getQueryable(context.Background()).(*watchedQueryable); !ok {
+			t.Error("Expected the queryable to be watched")
+		}
+	})
+
+	t.Run("Ceiling is required", func(t *testing.T) {
+		if _, err := NewQueryWatchdog(WatchdogConfig{}); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}